@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// The admin API is an opt-in HTTP/JSON server on a loopback address that
+// exposes the node's status getters and read-only forum queries, plus a few
+// maintenance actions, to scripts and health checks on the same host. Every
+// request must carry "Authorization: Bearer <token>".
+const (
+	defaultAdminAPIAddr   = "127.0.0.1:40180"
+	adminAPITokenFileName = "admin-api.token"
+	adminAPIMaxLimit      = 500
+)
+
+var (
+	errAdminAPINotLoopback = errors.New("admin api address must be a loopback address")
+	errAdminAPINotFound    = errors.New("not found")
+)
+
+type adminAPIConfig struct {
+	Enabled bool
+	Addr    string
+	Token   string
+}
+
+// resolveAdminAPIConfig reads AEGIS_ADMIN_API_ENABLED, AEGIS_ADMIN_API_ADDR and
+// AEGIS_ADMIN_API_TOKEN. Setting an address alone also enables the API.
+func resolveAdminAPIConfig() adminAPIConfig {
+	config := adminAPIConfig{
+		Addr:  strings.TrimSpace(os.Getenv("AEGIS_ADMIN_API_ADDR")),
+		Token: strings.TrimSpace(os.Getenv("AEGIS_ADMIN_API_TOKEN")),
+	}
+	switch strings.TrimSpace(strings.ToLower(os.Getenv("AEGIS_ADMIN_API_ENABLED"))) {
+	case "1", "true", "yes", "on":
+		config.Enabled = true
+	case "0", "false", "no", "off":
+		return config
+	default:
+		config.Enabled = config.Addr != ""
+	}
+	if config.Addr == "" {
+		config.Addr = defaultAdminAPIAddr
+	}
+	return config
+}
+
+func validateAdminAPIAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(host, "localhost") {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return errAdminAPINotLoopback
+	}
+	return nil
+}
+
+// loadOrCreateAdminAPIToken returns the configured token, or the one stored
+// next to the database, generating and storing one on first use.
+func (a *App) loadOrCreateAdminAPIToken(configured string) (string, string, error) {
+	if configured != "" {
+		return configured, "", nil
+	}
+
+	path := filepath.Join(filepath.Dir(a.dbPath), adminAPITokenFileName)
+	if raw, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(raw)); token != "" {
+			return token, path, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", "", err
+	}
+
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buffer)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", "", err
+	}
+	return token, path, nil
+}
+
+// startAdminAPI starts the admin API when it is enabled and returns the address
+// it listens on, or "" when it is disabled.
+func (a *App) startAdminAPI() (string, error) {
+	config := resolveAdminAPIConfig()
+	if !config.Enabled {
+		return "", nil
+	}
+	if err := validateAdminAPIAddr(config.Addr); err != nil {
+		return "", err
+	}
+	token, tokenPath, err := a.loadOrCreateAdminAPIToken(config.Token)
+	if err != nil {
+		return "", fmt.Errorf("admin api token: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		return "", err
+	}
+	server := &http.Server{
+		Handler:           a.adminAPIHandler(token),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      3 * time.Minute,
+	}
+
+	a.adminAPIMu.Lock()
+	if a.adminAPIServer != nil {
+		a.adminAPIMu.Unlock()
+		_ = listener.Close()
+		return "", errors.New("admin api already started")
+	}
+	a.adminAPIServer = server
+	a.adminAPIMu.Unlock()
+
+	go func() {
+		if serveErr := server.Serve(listener); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) && a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "admin_api.serve failed err=%v", serveErr)
+		}
+	}()
+
+	addr := listener.Addr().String()
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "admin_api.started addr=%s token_file=%s", addr, tokenPath)
+	}
+	return addr, nil
+}
+
+func (a *App) stopAdminAPI() error {
+	a.adminAPIMu.Lock()
+	server := a.adminAPIServer
+	a.adminAPIServer = nil
+	a.adminAPIMu.Unlock()
+	if server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+func (a *App) adminAPIHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/health", func(w http.ResponseWriter, r *http.Request) {
+		status := a.GetP2PStatus()
+		writeAdminAPIJSON(w, http.StatusOK, map[string]interface{}{
+			"ok":             a.db != nil,
+			"p2pStarted":     status.Started,
+			"connectedPeers": len(status.ConnectedPeers),
+			"time":           time.Now().Unix(),
+		})
+	})
+	mux.HandleFunc("GET /api/v1/p2p/status", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminAPIJSON(w, http.StatusOK, a.GetP2PStatus())
+	})
+	mux.HandleFunc("GET /api/v1/anti-entropy/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminAPIJSON(w, http.StatusOK, a.GetAntiEntropyStats())
+	})
+	mux.HandleFunc("GET /api/v1/release/metrics", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminAPIJSON(w, http.StatusOK, a.GetReleaseMetrics())
+	})
+	mux.HandleFunc("GET /api/v1/release/alerts", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminAPIJSON(w, http.StatusOK, a.GetReleaseAlerts())
+	})
+	mux.HandleFunc("GET /api/v1/metrics/history", func(w http.ResponseWriter, r *http.Request) {
+		points, err := a.GetMetricHistory(
+			r.URL.Query().Get("metric"),
+			adminAPIQueryInt64(r, "from"),
+			adminAPIQueryInt64(r, "to"),
+			adminAPIQueryInt64(r, "step"),
+		)
+		writeAdminAPIResult(w, points, err)
+	})
+	mux.HandleFunc("GET /api/v1/alerts/history", func(w http.ResponseWriter, r *http.Request) {
+		events, err := a.GetAlertHistory(
+			adminAPIQueryInt64(r, "from"),
+			adminAPIQueryInt64(r, "to"),
+			adminAPIQueryInt(r, "limit", defaultAlertHistoryLimit),
+		)
+		writeAdminAPIResult(w, events, err)
+	})
+	mux.HandleFunc("GET /api/v1/alerts/rules", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminAPIJSON(w, http.StatusOK, a.GetAlertRules())
+	})
+	mux.HandleFunc("POST /api/v1/alerts/rules/reload", func(w http.ResponseWriter, r *http.Request) {
+		status, err := a.ReloadAlertRules()
+		if err != nil {
+			writeAdminAPIJSON(w, http.StatusUnprocessableEntity, status)
+			return
+		}
+		writeAdminAPIJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("GET /api/v1/recommendation/strategies", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminAPIJSON(w, http.StatusOK, a.GetRecommendationStrategies())
+	})
+	mux.HandleFunc("POST /api/v1/recommendation/strategies/reload", func(w http.ResponseWriter, r *http.Request) {
+		status, err := a.ReloadRecommendationStrategies()
+		if err != nil {
+			writeAdminAPIJSON(w, http.StatusUnprocessableEntity, status)
+			return
+		}
+		writeAdminAPIJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("GET /api/v1/storage/usage", func(w http.ResponseWriter, r *http.Request) {
+		usage, err := a.GetStorageUsage()
+		writeAdminAPIResult(w, usage, err)
+	})
+	mux.HandleFunc("GET /api/v1/storage/quotas", func(w http.ResponseWriter, r *http.Request) {
+		quotas, err := a.GetStorageQuotas()
+		writeAdminAPIResult(w, quotas, err)
+	})
+	mux.HandleFunc("GET /api/v1/storage/evictions", func(w http.ResponseWriter, r *http.Request) {
+		evictions, err := a.GetStorageEvictions(adminAPIQueryInt(r, "limit", defaultStorageEvictionLimit))
+		writeAdminAPIResult(w, evictions, err)
+	})
+	mux.HandleFunc("POST /api/v1/storage/quotas", func(w http.ResponseWriter, r *http.Request) {
+		// Fields left out of the body keep their current value.
+		quotas, err := a.GetStorageQuotas()
+		if err != nil {
+			writeAdminAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(&quotas); err != nil {
+			writeAdminAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		quotas, err = a.SetStorageQuotas(quotas.TotalBytes, quotas.PrivateBytes, quotas.PublicBytes, quotas.MediaBytes, quotas.PinnedBytes)
+		writeAdminAPIResult(w, quotas, err)
+	})
+	// Pin changes answer with the updated pin list; running out of pinned
+	// budget is a conflict rather than a server error.
+	writePins := func(w http.ResponseWriter, err error) {
+		if errors.Is(err, errPinnedBudgetExceeded) {
+			writeAdminAPIError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			writeAdminAPIResult(w, nil, err)
+			return
+		}
+		pins, err := a.ListPinned()
+		writeAdminAPIResult(w, pins, err)
+	}
+	mux.HandleFunc("GET /api/v1/pins", func(w http.ResponseWriter, r *http.Request) {
+		writePins(w, nil)
+	})
+	mux.HandleFunc("POST /api/v1/posts/{id}/pin", func(w http.ResponseWriter, r *http.Request) {
+		writePins(w, a.PinPost(r.PathValue("id")))
+	})
+	mux.HandleFunc("DELETE /api/v1/posts/{id}/pin", func(w http.ResponseWriter, r *http.Request) {
+		writePins(w, a.UnpinPost(r.PathValue("id")))
+	})
+	mux.HandleFunc("PUT /api/v1/subs/{id}/pin", func(w http.ResponseWriter, r *http.Request) {
+		writePins(w, a.SetSubPinPolicy(r.PathValue("id"), true))
+	})
+	mux.HandleFunc("DELETE /api/v1/subs/{id}/pin", func(w http.ResponseWriter, r *http.Request) {
+		writePins(w, a.SetSubPinPolicy(r.PathValue("id"), false))
+	})
+	mux.HandleFunc("GET /api/v1/moderation/logs", func(w http.ResponseWriter, r *http.Request) {
+		logs, err := a.GetModerationLogs(adminAPIQueryInt(r, "limit", 100))
+		writeAdminAPIResult(w, logs, err)
+	})
+	mux.HandleFunc("GET /api/v1/moderation/state", func(w http.ResponseWriter, r *http.Request) {
+		states, err := a.GetModerationState()
+		writeAdminAPIResult(w, states, err)
+	})
+	mux.HandleFunc("GET /api/v1/subs", func(w http.ResponseWriter, r *http.Request) {
+		subs, err := a.GetSubs()
+		writeAdminAPIResult(w, subs, err)
+	})
+	mux.HandleFunc("GET /api/v1/feed", func(w http.ResponseWriter, r *http.Request) {
+		subID := strings.TrimSpace(r.URL.Query().Get("sub"))
+		if subID == "" {
+			subID = defaultSubID
+		}
+		posts, err := a.GetFeedIndexBySubSorted(subID, r.URL.Query().Get("sort"))
+		if limit := adminAPIQueryInt(r, "limit", 50); err == nil && len(posts) > limit {
+			posts = posts[:limit]
+		}
+		writeAdminAPIResult(w, posts, err)
+	})
+	mux.HandleFunc("GET /api/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		page, err := a.Search(SearchRequest{
+			Query:  query.Get("q"),
+			Kind:   query.Get("kind"),
+			SubID:  query.Get("sub"),
+			Author: query.Get("author"),
+			Since:  adminAPIQueryInt64(r, "since"),
+			Until:  adminAPIQueryInt64(r, "until"),
+			Limit:  adminAPIQueryInt(r, "limit", 20),
+			Cursor: query.Get("cursor"),
+		})
+		writeAdminAPIResult(w, page, err)
+	})
+	mux.HandleFunc("GET /api/v1/search/network", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		result, err := a.SearchNetwork(SearchRequest{
+			Query:  query.Get("q"),
+			SubID:  query.Get("sub"),
+			Author: query.Get("author"),
+			Since:  adminAPIQueryInt64(r, "since"),
+			Until:  adminAPIQueryInt64(r, "until"),
+			Limit:  adminAPIQueryInt(r, "limit", 20),
+		})
+		if errors.Is(err, errNetworkSearchNoPeers) {
+			writeAdminAPIError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		writeAdminAPIResult(w, result, err)
+	})
+	mux.HandleFunc("GET /api/v1/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		post, err := a.GetPostIndexByID(r.PathValue("id"))
+		writeAdminAPIResult(w, post, err)
+	})
+	mux.HandleFunc("GET /api/v1/posts/{id}/body", func(w http.ResponseWriter, r *http.Request) {
+		body, err := a.GetPostBodyByID(r.PathValue("id"))
+		writeAdminAPIResult(w, body, err)
+	})
+	mux.HandleFunc("GET /api/v1/posts/{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+		comments, err := a.GetCommentsByPost(r.PathValue("id"))
+		writeAdminAPIResult(w, comments, err)
+	})
+	mux.HandleFunc("GET /api/v1/posts/{id}/comments/page", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		page, err := a.GetCommentPage(CommentPageRequest{
+			PostID:     r.PathValue("id"),
+			ParentID:   query.Get("parent"),
+			Sort:       query.Get("sort"),
+			Limit:      adminAPIQueryInt(r, "limit", defaultCommentPageLimit),
+			Cursor:     query.Get("cursor"),
+			Depth:      adminAPIQueryInt(r, "depth", 0),
+			ReplyLimit: adminAPIQueryInt(r, "replyLimit", defaultCommentReplyLimit),
+		})
+		writeAdminAPIResult(w, page, err)
+	})
+	mux.HandleFunc("GET /api/v1/posts/{id}/revisions", func(w http.ResponseWriter, r *http.Request) {
+		revisions, err := a.GetPostRevisions(r.PathValue("id"))
+		writeAdminAPIResult(w, revisions, err)
+	})
+	mux.HandleFunc("GET /api/v1/comments/{id}/revisions", func(w http.ResponseWriter, r *http.Request) {
+		revisions, err := a.GetCommentRevisions(r.PathValue("id"))
+		writeAdminAPIResult(w, revisions, err)
+	})
+	mux.HandleFunc("POST /api/v1/sync", func(w http.ResponseWriter, r *http.Request) {
+		if err := a.TriggerAntiEntropySyncNow(); err != nil {
+			writeAdminAPIError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		writeAdminAPIJSON(w, http.StatusOK, a.GetAntiEntropyStats())
+	})
+	mux.HandleFunc("POST /api/v1/gc", func(w http.ResponseWriter, r *http.Request) {
+		result, err := a.RunTombstoneGC(
+			adminAPIQueryInt(r, "retentionDays", 0),
+			adminAPIQueryInt(r, "stablePasses", 0),
+			adminAPIQueryInt(r, "batch", 0),
+		)
+		writeAdminAPIResult(w, result, err)
+	})
+	mux.HandleFunc("GET /api/v1/maintenance/runs", func(w http.ResponseWriter, r *http.Request) {
+		runs, err := a.GetMaintenanceRuns(adminAPIQueryInt(r, "limit", defaultMaintenanceRunLimit))
+		writeAdminAPIResult(w, runs, err)
+	})
+	mux.HandleFunc("POST /api/v1/maintenance/run", func(w http.ResponseWriter, r *http.Request) {
+		run, err := a.RunMaintenanceNow()
+		if errors.Is(err, errMaintenanceRunning) {
+			writeAdminAPIError(w, http.StatusConflict, err)
+			return
+		}
+		writeAdminAPIResult(w, run, err)
+	})
+	mux.Handle("GET /metrics", a.metricsHandler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminAPIError(w, http.StatusNotFound, errAdminAPINotFound)
+	})
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="aegis-admin"`)
+			writeAdminAPIError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func adminAPIQueryInt(r *http.Request, name string, fallback int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get(name)))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	if value > adminAPIMaxLimit {
+		return adminAPIMaxLimit
+	}
+	return value
+}
+
+// adminAPIQueryInt64 reads an uncapped integer such as a unix timestamp; a
+// missing or malformed value reads as 0.
+func adminAPIQueryInt64(r *http.Request, name string) int64 {
+	value, err := strconv.ParseInt(strings.TrimSpace(r.URL.Query().Get(name)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func writeAdminAPIResult(w http.ResponseWriter, result interface{}, err error) {
+	switch {
+	case err == nil:
+		writeAdminAPIJSON(w, http.StatusOK, result)
+	case errors.Is(err, sql.ErrNoRows), strings.Contains(strings.ToLower(err.Error()), "not found"):
+		writeAdminAPIError(w, http.StatusNotFound, err)
+	case strings.Contains(strings.ToLower(err.Error()), "required"), strings.Contains(strings.ToLower(err.Error()), "invalid"):
+		writeAdminAPIError(w, http.StatusBadRequest, err)
+	default:
+		writeAdminAPIError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func writeAdminAPIError(w http.ResponseWriter, status int, err error) {
+	writeAdminAPIJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeAdminAPIJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAPIRequiresBearerToken(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.adminAPIHandler("secret-token")
+
+	for _, header := range []string{"", "Bearer wrong", "secret-token"} {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/subs", nil)
+		if header != "" {
+			request.Header.Set("Authorization", header)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("authorization %q: expected 401, got %d", header, recorder.Code)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/subs", nil)
+	request.Header.Set("Authorization", "Bearer secret-token")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var subs []Sub
+	if err := json.Unmarshal(recorder.Body.Bytes(), &subs); err != nil || len(subs) == 0 {
+		t.Fatalf("unexpected subs payload %s err=%v", recorder.Body.String(), err)
+	}
+}
+
+func TestAdminAPIUnknownPostIsNotFound(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.adminAPIHandler("secret-token")
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/posts/missing", nil)
+	request.Header.Set("Authorization", "Bearer secret-token")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestValidateAdminAPIAddrRequiresLoopback(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:40180", "[::1]:40180", "localhost:40180"} {
+		if err := validateAdminAPIAddr(addr); err != nil {
+			t.Fatalf("%s rejected: %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"0.0.0.0:40180", "192.168.1.10:40180", ":40180"} {
+		if err := validateAdminAPIAddr(addr); !errors.Is(err, errAdminAPINotLoopback) {
+			t.Fatalf("%s accepted, err=%v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Alert transitions are pushed to the configured sinks: an HTTP webhook
+// (AEGIS_ALERT_WEBHOOK_URL), a local command (AEGIS_ALERT_EXEC) and, in the
+// desktop app, an "alerts:updated" Wails event that the frontend turns into a
+// toast and OS notification. Delivery is asynchronous so a slow sink never
+// delays alert evaluation. Dedup follows each alert key's state: a
+// notification that repeats the last event sent for its key within the dedup
+// window is dropped, so a flapping alert still reports every fire and
+// resolve. All sinks share one rate limit, and the transitions it drops are
+// counted and logged as warnings.
+const (
+	alertNotifyEventName       = "alerts:updated"
+	defaultAlertNotifyDedupSec = 600
+	defaultAlertNotifyLimit    = 20
+	defaultAlertNotifyWindow   = 600
+	alertWebhookTimeout        = 5 * time.Second
+	alertWebhookAttempts       = 2
+	alertExecTimeout           = 10 * time.Second
+)
+
+type AlertNotification struct {
+	Event string       `json:"event"`
+	Alert ReleaseAlert `json:"alert"`
+	At    int64        `json:"at"`
+	Node  string       `json:"node"`
+	Text  string       `json:"text"`
+}
+
+type alertNotifier interface {
+	name() string
+	notify(ctx context.Context, notification AlertNotification) error
+}
+
+type alertDispatcher struct {
+	notifiers     []alertNotifier
+	dedupSec      int64
+	limit         int
+	windowSec     int64
+	mu            sync.Mutex
+	sent          map[string]alertSentState
+	windowStarted int64
+	windowCount   int
+	rateLimited   int64
+}
+
+// alertSentState is the last event sent for an alert key.
+type alertSentState struct {
+	event string
+	at    int64
+}
+
+func newAlertDispatcher(a *App) *alertDispatcher {
+	dispatcher := &alertDispatcher{
+		dedupSec:  resolveAlertNotifyInt("AEGIS_ALERT_NOTIFY_DEDUP_SEC", defaultAlertNotifyDedupSec),
+		limit:     int(resolveAlertNotifyInt("AEGIS_ALERT_NOTIFY_RATE_LIMIT", defaultAlertNotifyLimit)),
+		windowSec: resolveAlertNotifyInt("AEGIS_ALERT_NOTIFY_RATE_WINDOW_SEC", defaultAlertNotifyWindow),
+		sent:      make(map[string]alertSentState),
+	}
+	if url := strings.TrimSpace(os.Getenv("AEGIS_ALERT_WEBHOOK_URL")); url != "" {
+		dispatcher.notifiers = append(dispatcher.notifiers, webhookAlertNotifier{
+			url:    url,
+			token:  strings.TrimSpace(os.Getenv("AEGIS_ALERT_WEBHOOK_TOKEN")),
+			client: &http.Client{Timeout: alertWebhookTimeout},
+		})
+	}
+	if command := strings.TrimSpace(os.Getenv("AEGIS_ALERT_EXEC")); command != "" {
+		dispatcher.notifiers = append(dispatcher.notifiers, execAlertNotifier{command: command})
+	}
+	switch strings.TrimSpace(strings.ToLower(os.Getenv("AEGIS_ALERT_DESKTOP_NOTIFY"))) {
+	case "0", "false", "no", "off":
+	default:
+		dispatcher.notifiers = append(dispatcher.notifiers, desktopAlertNotifier{app: a})
+	}
+	return dispatcher
+}
+
+func resolveAlertNotifyInt(name string, fallback int64) int64 {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// admit applies dedup and the rate limit. Only a repeat of the last event
+// sent for the same key is a duplicate; a change of state always gets past
+// dedup. A dedup window of 0 disables dedup and a limit of 0 disables rate
+// limiting.
+func (d *alertDispatcher) admit(notification AlertNotification) (bool, string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := notification.At
+	key := notification.Alert.Key
+	if last, ok := d.sent[key]; ok && d.dedupSec > 0 && last.event == notification.Event && now-last.at < d.dedupSec {
+		return false, "duplicate"
+	}
+	if d.limit > 0 {
+		if now-d.windowStarted >= d.windowSec {
+			d.windowStarted = now
+			d.windowCount = 0
+		}
+		if d.windowCount >= d.limit {
+			d.rateLimited++
+			return false, "rate_limited"
+		}
+		d.windowCount++
+	}
+	d.sent[key] = alertSentState{event: notification.Event, at: now}
+	for sentKey, last := range d.sent {
+		if now-last.at >= d.dedupSec {
+			delete(d.sent, sentKey)
+		}
+	}
+	return true, ""
+}
+
+// rateLimitedCount returns how many transitions the rate limit has dropped.
+func (d *alertDispatcher) rateLimitedCount() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rateLimited
+}
+
+// notifyAlertTransitions hands raised and recovered alerts to the sinks.
+func (a *App) notifyAlertTransitions(now int64, raised []ReleaseAlert, recovered []ReleaseAlert) {
+	if len(raised) == 0 && len(recovered) == 0 {
+		return
+	}
+
+	a.alertNotifyMu.Lock()
+	if a.alertNotify == nil {
+		a.alertNotify = newAlertDispatcher(a)
+	}
+	dispatcher := a.alertNotify
+	a.alertNotifyMu.Unlock()
+	if len(dispatcher.notifiers) == 0 {
+		return
+	}
+
+	node := a.GetP2PStatus().PeerID
+	transitions := make([]AlertNotification, 0, len(raised)+len(recovered))
+	for _, alert := range raised {
+		transitions = append(transitions, newAlertNotification(releaseAlertEventFired, alert, now, node))
+	}
+	for _, alert := range recovered {
+		transitions = append(transitions, newAlertNotification(releaseAlertEventResolved, alert, now, node))
+	}
+
+	for _, notification := range transitions {
+		if ok, reason := dispatcher.admit(notification); !ok {
+			if a.ctx == nil {
+				continue
+			}
+			if reason == "rate_limited" {
+				runtime.LogWarningf(a.ctx, "alert_notify.skipped key=%s event=%s reason=%s dropped_total=%d", notification.Alert.Key, notification.Event, reason, dispatcher.rateLimitedCount())
+			} else {
+				runtime.LogInfof(a.ctx, "alert_notify.skipped key=%s event=%s reason=%s", notification.Alert.Key, notification.Event, reason)
+			}
+			continue
+		}
+		for _, notifier := range dispatcher.notifiers {
+			go a.deliverAlertNotification(notifier, notification)
+		}
+	}
+}
+
+func (a *App) deliverAlertNotification(notifier alertNotifier, notification AlertNotification) {
+	if err := notifier.notify(context.Background(), notification); err != nil && a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "alert_notify.failed sink=%s key=%s event=%s err=%v", notifier.name(), notification.Alert.Key, notification.Event, err)
+	}
+}
+
+func newAlertNotification(event string, alert ReleaseAlert, now int64, node string) AlertNotification {
+	text := fmt.Sprintf("[%s] %s %s: %s=%g (threshold %g)", alert.Level, alert.Key, event, alert.Metric, alert.Value, alert.Threshold)
+	if node != "" {
+		text += " on " + node
+	}
+	return AlertNotification{Event: event, Alert: alert, At: now, Node: node, Text: text}
+}
+
+// webhookAlertNotifier POSTs the notification as JSON. The "text" field makes
+// the payload usable as a Slack or Mattermost incoming webhook as is.
+type webhookAlertNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (n webhookAlertNotifier) name() string {
+	return "webhook"
+}
+
+func (n webhookAlertNotifier) notify(ctx context.Context, notification AlertNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < alertWebhookAttempts; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		if n.token != "" {
+			request.Header.Set("Authorization", "Bearer "+n.token)
+		}
+
+		response, err := n.client.Do(request)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		response.Body.Close()
+		if response.StatusCode >= 200 && response.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("webhook status %d", response.StatusCode)
+		if response.StatusCode < 500 {
+			break
+		}
+	}
+	return lastErr
+}
+
+// execAlertNotifier runs a local command, without a shell, with the
+// notification as JSON on stdin and its fields in AEGIS_ALERT_* variables.
+type execAlertNotifier struct {
+	command string
+}
+
+func (n execAlertNotifier) name() string {
+	return "exec"
+}
+
+func (n execAlertNotifier) notify(ctx context.Context, notification AlertNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, alertExecTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, n.command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"AEGIS_ALERT_EVENT="+notification.Event,
+		"AEGIS_ALERT_KEY="+notification.Alert.Key,
+		"AEGIS_ALERT_METRIC="+notification.Alert.Metric,
+		"AEGIS_ALERT_LEVEL="+notification.Alert.Level,
+		"AEGIS_ALERT_VALUE="+strconv.FormatFloat(notification.Alert.Value, 'g', -1, 64),
+		"AEGIS_ALERT_THRESHOLD="+strconv.FormatFloat(notification.Alert.Threshold, 'g', -1, 64),
+		"AEGIS_ALERT_AT="+strconv.FormatInt(notification.At, 10),
+		"AEGIS_ALERT_TEXT="+notification.Text,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// desktopAlertNotifier emits a Wails event for the frontend. It does nothing
+// outside the desktop app, where there is no Wails context.
+type desktopAlertNotifier struct {
+	app *App
+}
+
+func (n desktopAlertNotifier) name() string {
+	return "desktop"
+}
+
+func (n desktopAlertNotifier) notify(ctx context.Context, notification AlertNotification) error {
+	if n.app.ctx == nil {
+		return nil
+	}
+	runtime.EventsEmit(n.app.ctx, alertNotifyEventName, notification)
+	return nil
+}
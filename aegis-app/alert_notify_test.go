@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertWebhookReceivesTransitions(t *testing.T) {
+	received := make(chan AlertNotification, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer hook-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var notification AlertNotification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- notification
+	}))
+	defer server.Close()
+
+	t.Setenv("AEGIS_ALERT_WEBHOOK_URL", server.URL)
+	t.Setenv("AEGIS_ALERT_WEBHOOK_TOKEN", "hook-secret")
+	app := newTestApp(t)
+
+	now := time.Now().Unix()
+	lagging := releaseAlertSnapshot(ReleaseMetrics{SyncLagSeconds: 700})
+	app.evaluateReleaseAlertsAt(now-400, lagging)
+	app.evaluateReleaseAlertsAt(now-100, lagging)
+	app.evaluateReleaseAlertsAt(now, releaseAlertSnapshot(ReleaseMetrics{}))
+
+	events := make(map[string]int)
+	for len(events) < 4 {
+		select {
+		case notification := <-received:
+			events[notification.Alert.Key+"|"+notification.Event]++
+			if !strings.Contains(notification.Text, notification.Alert.Key) {
+				t.Fatalf("text should name the alert: %+v", notification)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for webhook, got %v", events)
+		}
+	}
+	for _, key := range []string{"sync_lag_seconds_warning", "sync_lag_seconds_critical"} {
+		if events[key+"|fired"] != 1 || events[key+"|resolved"] != 1 {
+			t.Fatalf("expected fired and resolved for %s, got %v", key, events)
+		}
+	}
+}
+
+func TestAlertExecHookGetsNotificationOnStdin(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("exec hook test uses a shell script")
+	}
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.json")
+	script := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$AEGIS_ALERT_EVENT $AEGIS_ALERT_KEY\" > \""+output+".env\"\ncat > \""+output+"\"\n"), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	notification := newAlertNotification(releaseAlertEventFired, ReleaseAlert{Key: "k", Metric: "connected_peers", Level: "warning", Threshold: 1}, 42, "")
+	if err := (execAlertNotifier{command: script}).notify(t.Context(), notification); err != nil {
+		t.Fatalf("exec hook: %v", err)
+	}
+
+	raw, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read hook output: %v", err)
+	}
+	var got AlertNotification
+	if err := json.Unmarshal(raw, &got); err != nil || got.Alert.Key != "k" || got.At != 42 {
+		t.Fatalf("unexpected stdin payload %s err=%v", raw, err)
+	}
+	env, _ := os.ReadFile(output + ".env")
+	if strings.TrimSpace(string(env)) != "fired k" {
+		t.Fatalf("unexpected env %q", env)
+	}
+}
+
+func TestAlertDispatcherDedupsAndRateLimits(t *testing.T) {
+	dispatcher := &alertDispatcher{dedupSec: 600, limit: 3, windowSec: 300, sent: make(map[string]alertSentState)}
+	notification := func(key string, event string, at int64) AlertNotification {
+		return newAlertNotification(event, ReleaseAlert{Key: key}, at, "")
+	}
+
+	if ok, _ := dispatcher.admit(notification("a", "fired", 1000)); !ok {
+		t.Fatalf("first notification should pass")
+	}
+	if ok, reason := dispatcher.admit(notification("a", "fired", 1100)); ok || reason != "duplicate" {
+		t.Fatalf("repeat within dedup window should be dropped, got ok=%v reason=%s", ok, reason)
+	}
+	if ok, _ := dispatcher.admit(notification("a", "resolved", 1100)); !ok {
+		t.Fatalf("resolve is a different transition")
+	}
+	if ok, _ := dispatcher.admit(notification("b", "fired", 1100)); !ok {
+		t.Fatalf("third notification in window should pass")
+	}
+	if ok, reason := dispatcher.admit(notification("c", "fired", 1100)); ok || reason != "rate_limited" {
+		t.Fatalf("fourth notification in window should be rate limited, got ok=%v reason=%s", ok, reason)
+	}
+	if dropped := dispatcher.rateLimitedCount(); dropped != 1 {
+		t.Fatalf("expected one rate limited transition, got %d", dropped)
+	}
+	if ok, _ := dispatcher.admit(notification("c", "fired", 1300)); !ok {
+		t.Fatalf("new rate window should pass")
+	}
+	if ok, _ := dispatcher.admit(notification("a", "fired", 1700)); !ok {
+		t.Fatalf("repeat after dedup window should pass")
+	}
+}
+
+func TestAlertDispatcherReportsFlappingAlerts(t *testing.T) {
+	dispatcher := &alertDispatcher{dedupSec: 600, sent: make(map[string]alertSentState)}
+	notification := func(event string, at int64) AlertNotification {
+		return newAlertNotification(event, ReleaseAlert{Key: "a"}, at, "")
+	}
+
+	for i, event := range []string{"fired", "resolved", "fired", "resolved"} {
+		if ok, reason := dispatcher.admit(notification(event, 1000+int64(i))); !ok {
+			t.Fatalf("transition %d (%s) should pass, got reason=%s", i, event, reason)
+		}
+	}
+	if ok, reason := dispatcher.admit(notification("resolved", 1010)); ok || reason != "duplicate" {
+		t.Fatalf("repeat of the current state should be dropped, got ok=%v reason=%s", ok, reason)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gopkg.in/yaml.v3"
+)
+
+// Alert rules come from the file named by AEGIS_ALERT_RULES_FILE, YAML or
+// JSON (by extension), or from defaultReleaseAlertRules when it is unset. The
+// alert worker re-reads the file whenever its size or modification time
+// changes. A file that fails validation is rejected as a whole and the rules
+// already in effect stay active.
+const alertRulesSourceBuiltin = "builtin"
+
+var errNoAlertRules = errors.New("alert rules file defines no rules")
+
+type AlertRulesStatus struct {
+	Source   string      `json:"source"`
+	LoadedAt int64       `json:"loadedAt"`
+	Error    string      `json:"error"`
+	Rules    []AlertRule `json:"rules"`
+}
+
+// alertRuleSet is the rule set in effect, guarded by releaseAlertMu.
+type alertRuleSet struct {
+	Rules    []AlertRule
+	Source   string
+	Path     string
+	ModTime  time.Time
+	Size     int64
+	LoadedAt int64
+	Error    string
+}
+
+type alertRulesFile struct {
+	Rules []alertRuleSpec `json:"rules" yaml:"rules"`
+}
+
+// alertRuleSpec is a rule as written in the file. window takes a duration
+// such as "5m" or a number of seconds; windowSec is accepted as well.
+type alertRuleSpec struct {
+	Key        string   `json:"key" yaml:"key"`
+	Metric     string   `json:"metric" yaml:"metric"`
+	Comparator string   `json:"comparator" yaml:"comparator"`
+	Threshold  *float64 `json:"threshold" yaml:"threshold"`
+	Window     string   `json:"window" yaml:"window"`
+	WindowSec  *int64   `json:"windowSec" yaml:"windowSec"`
+	Severity   string   `json:"severity" yaml:"severity"`
+	MinSamples int64    `json:"minSamples" yaml:"minSamples"`
+}
+
+func resolveAlertRulesPath() string {
+	return strings.TrimSpace(os.Getenv("AEGIS_ALERT_RULES_FILE"))
+}
+
+// parseAlertRules decodes and validates a rules file. Unknown fields are
+// errors so that typos do not silently drop a guard.
+func parseAlertRules(path string, raw []byte) ([]AlertRule, error) {
+	var file alertRulesFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+		}
+	}
+	if len(file.Rules) == 0 {
+		return nil, errNoAlertRules
+	}
+
+	rules := make([]AlertRule, 0, len(file.Rules))
+	seen := make(map[string]bool, len(file.Rules))
+	for index, spec := range file.Rules {
+		rule, err := spec.toRule()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", index+1, spec.Key, err)
+		}
+		if seen[rule.Key] {
+			return nil, fmt.Errorf("rule %d (%s): duplicate key", index+1, rule.Key)
+		}
+		seen[rule.Key] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (spec alertRuleSpec) toRule() (AlertRule, error) {
+	rule := AlertRule{
+		Key:        strings.TrimSpace(spec.Key),
+		Metric:     strings.TrimSpace(spec.Metric),
+		Comparator: strings.TrimSpace(spec.Comparator),
+		Severity:   strings.ToLower(strings.TrimSpace(spec.Severity)),
+		MinSamples: spec.MinSamples,
+	}
+
+	if rule.Key == "" {
+		return AlertRule{}, errors.New("key is required")
+	}
+	if !isAlertMetricName(rule.Metric) {
+		return AlertRule{}, fmt.Errorf("unknown metric %q", rule.Metric)
+	}
+	switch rule.Comparator {
+	case "<", "<=", ">", ">=":
+	default:
+		return AlertRule{}, fmt.Errorf("comparator must be one of <, <=, >, >=, got %q", rule.Comparator)
+	}
+	if spec.Threshold == nil {
+		return AlertRule{}, errors.New("threshold is required")
+	}
+	if math.IsNaN(*spec.Threshold) || math.IsInf(*spec.Threshold, 0) {
+		return AlertRule{}, errors.New("threshold must be a finite number")
+	}
+	rule.Threshold = *spec.Threshold
+
+	window := strings.TrimSpace(spec.Window)
+	switch {
+	case window != "" && spec.WindowSec != nil:
+		return AlertRule{}, errors.New("set either window or windowSec, not both")
+	case spec.WindowSec != nil:
+		rule.WindowSec = *spec.WindowSec
+	case window != "":
+		if seconds, err := strconv.ParseInt(window, 10, 64); err == nil {
+			rule.WindowSec = seconds
+		} else if duration, err := time.ParseDuration(window); err == nil {
+			rule.WindowSec = int64(duration / time.Second)
+		} else {
+			return AlertRule{}, fmt.Errorf("invalid window %q", spec.Window)
+		}
+	}
+	if rule.WindowSec < 0 {
+		return AlertRule{}, errors.New("window must not be negative")
+	}
+
+	switch rule.Severity {
+	case "info", "warning", "critical":
+	default:
+		return AlertRule{}, fmt.Errorf("severity must be info, warning or critical, got %q", spec.Severity)
+	}
+	if rule.MinSamples < 0 {
+		return AlertRule{}, errors.New("minSamples must not be negative")
+	}
+	if rule.MinSamples > 0 && !sampledAlertMetrics[rule.Metric] {
+		return AlertRule{}, fmt.Errorf("minSamples does not apply to metric %q", rule.Metric)
+	}
+	return rule, nil
+}
+
+// loadAlertRulesFile reads and validates a rules file without applying it.
+func loadAlertRulesFile(path string) ([]AlertRule, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseAlertRules(path, raw)
+}
+
+// refreshAlertRules applies the configured rules file if it changed since the
+// last load, or unconditionally when force is set.
+func (a *App) refreshAlertRules(force bool) (AlertRulesStatus, error) {
+	path := resolveAlertRulesPath()
+	if path == "" {
+		a.releaseAlertMu.Lock()
+		if a.releaseAlertRules.Source != alertRulesSourceBuiltin {
+			a.applyAlertRulesLocked(alertRuleSet{Rules: defaultReleaseAlertRules(), Source: alertRulesSourceBuiltin, LoadedAt: time.Now().Unix()})
+		}
+		status := a.alertRulesStatusLocked()
+		a.releaseAlertMu.Unlock()
+		return status, nil
+	}
+
+	info, err := os.Stat(path)
+	a.releaseAlertMu.Lock()
+	current := a.releaseAlertRules
+	a.releaseAlertMu.Unlock()
+	if err == nil && !force && current.Path == path && current.Size == info.Size() && current.ModTime.Equal(info.ModTime()) {
+		return a.GetAlertRules(), nil
+	}
+
+	var rules []AlertRule
+	if err == nil {
+		rules, err = loadAlertRulesFile(path)
+	}
+
+	a.releaseAlertMu.Lock()
+	defer a.releaseAlertMu.Unlock()
+	if err != nil {
+		// Keep the rules in effect; remember the file version so a broken
+		// file is reported once rather than on every evaluation.
+		if a.releaseAlertRules.Rules == nil {
+			a.releaseAlertRules.Rules = defaultReleaseAlertRules()
+			a.releaseAlertRules.Source = alertRulesSourceBuiltin
+		}
+		changed := a.releaseAlertRules.Error != err.Error()
+		a.releaseAlertRules.Error = err.Error()
+		a.releaseAlertRules.Path = path
+		if info != nil {
+			a.releaseAlertRules.ModTime = info.ModTime()
+			a.releaseAlertRules.Size = info.Size()
+		}
+		if changed && a.ctx != nil {
+			runtime.LogErrorf(a.ctx, "alert_rules.load_failed path=%s err=%v", path, err)
+		}
+		return a.alertRulesStatusLocked(), err
+	}
+
+	a.applyAlertRulesLocked(alertRuleSet{
+		Rules:    rules,
+		Source:   path,
+		Path:     path,
+		ModTime:  info.ModTime(),
+		Size:     info.Size(),
+		LoadedAt: time.Now().Unix(),
+	})
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "alert_rules.loaded path=%s rules=%d", path, len(rules))
+	}
+	return a.alertRulesStatusLocked(), nil
+}
+
+// applyAlertRulesLocked swaps the rule set and forgets breach timers of rules
+// that no longer exist; their active alerts resolve on the next evaluation.
+func (a *App) applyAlertRulesLocked(set alertRuleSet) {
+	keys := make(map[string]bool, len(set.Rules))
+	for _, rule := range set.Rules {
+		keys[rule.Key] = true
+	}
+	for key := range a.releaseAlertState {
+		if !keys[key] {
+			delete(a.releaseAlertState, key)
+		}
+	}
+	a.releaseAlertRules = set
+}
+
+func (a *App) alertRulesStatusLocked() AlertRulesStatus {
+	set := a.releaseAlertRules
+	rules := set.Rules
+	source := set.Source
+	if rules == nil {
+		rules = defaultReleaseAlertRules()
+		source = alertRulesSourceBuiltin
+	}
+	return AlertRulesStatus{
+		Source:   source,
+		LoadedAt: set.LoadedAt,
+		Error:    set.Error,
+		Rules:    append([]AlertRule(nil), rules...),
+	}
+}
+
+// GetAlertRules returns the alert rules in effect and the last load error, if
+// the rules file was rejected.
+func (a *App) GetAlertRules() AlertRulesStatus {
+	a.releaseAlertMu.Lock()
+	defer a.releaseAlertMu.Unlock()
+	return a.alertRulesStatusLocked()
+}
+
+// ReloadAlertRules re-reads the rules file now instead of waiting for the
+// next alert evaluation.
+func (a *App) ReloadAlertRules() (AlertRulesStatus, error) {
+	return a.refreshAlertRules(true)
+}
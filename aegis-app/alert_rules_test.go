@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAlertRulesValidatesEveryRule(t *testing.T) {
+	rules, err := parseAlertRules("rules.yaml", []byte(`
+rules:
+  - key: fetch_rate
+    metric: content_fetch_success_rate
+    comparator: "<"
+    threshold: 0.9
+    window: 5m
+    severity: warning
+    minSamples: 20
+  - key: few_peers
+    metric: connected_peers
+    comparator: "<="
+    threshold: 1
+    window: 120
+    severity: critical
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rules) != 2 || rules[0].WindowSec != 300 || rules[0].MinSamples != 20 || rules[1].WindowSec != 120 {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	if _, err := parseAlertRules("rules.json", []byte(`{"rules":[{"key":"q","metric":"storage_quota_usage","comparator":">","threshold":0.9,"windowSec":60,"severity":"info"}]}`)); err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+
+	for name, body := range map[string]string{
+		"unknown metric":     `{"rules":[{"key":"a","metric":"nope","comparator":">","threshold":1,"severity":"warning"}]}`,
+		"bad comparator":     `{"rules":[{"key":"a","metric":"connected_peers","comparator":"~","threshold":1,"severity":"warning"}]}`,
+		"missing threshold":  `{"rules":[{"key":"a","metric":"connected_peers","comparator":"<","severity":"warning"}]}`,
+		"bad severity":       `{"rules":[{"key":"a","metric":"connected_peers","comparator":"<","threshold":1,"severity":"page"}]}`,
+		"unknown field":      `{"rules":[{"key":"a","metric":"connected_peers","comparator":"<","threshold":1,"severity":"warning","minSample":3}]}`,
+		"gauge min samples":  `{"rules":[{"key":"a","metric":"connected_peers","comparator":"<","threshold":1,"severity":"warning","minSamples":3}]}`,
+		"duplicate key":      `{"rules":[{"key":"a","metric":"connected_peers","comparator":"<","threshold":1,"severity":"warning"},{"key":"a","metric":"sync_lag_seconds","comparator":">","threshold":1,"severity":"warning"}]}`,
+		"both window fields": `{"rules":[{"key":"a","metric":"connected_peers","comparator":"<","threshold":1,"severity":"warning","window":"1m","windowSec":60}]}`,
+		"empty":              `{"rules":[]}`,
+	} {
+		if _, err := parseAlertRules("rules.json", []byte(body)); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestAlertRulesHotReloadKeepsLastGoodRules(t *testing.T) {
+	app := newTestApp(t)
+	path := filepath.Join(t.TempDir(), "alert-rules.yaml")
+	t.Setenv("AEGIS_ALERT_RULES_FILE", path)
+
+	writeRules := func(body string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatalf("write rules: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	base := time.Now().Add(-time.Hour)
+
+	writeRules(`
+rules:
+  - key: greylist_burst
+    metric: peer_greylist_growth
+    comparator: ">="
+    threshold: 2
+    window: 0s
+    severity: critical
+`, base)
+	if _, err := app.refreshAlertRules(false); err != nil {
+		t.Fatalf("initial load: %v", err)
+	}
+
+	now := time.Now().Unix()
+	app.markPeerGreylisted("peer-a", "test")
+	app.markPeerGreylisted("peer-a", "test")
+	if alerts := app.evaluateReleaseAlertsAt(now, app.collectAlertMetrics(now, app.GetReleaseMetrics())); len(alerts) != 0 {
+		t.Fatalf("one greylisted peer should not alert, got %+v", alerts)
+	}
+	app.markPeerGreylisted("peer-b", "test")
+	alerts := app.evaluateReleaseAlertsAt(now, app.collectAlertMetrics(now, app.GetReleaseMetrics()))
+	if len(alerts) != 1 || alerts[0].Key != "greylist_burst" || alerts[0].Value != 2 || alerts[0].Level != "critical" {
+		t.Fatalf("expected greylist alert, got %+v", alerts)
+	}
+
+	writeRules(`rules: [{key: broken, metric: nope}]`, base.Add(time.Minute))
+	status, err := app.refreshAlertRules(false)
+	if err == nil || !strings.Contains(status.Error, "unknown metric") {
+		t.Fatalf("expected rejected reload, got status=%+v err=%v", status, err)
+	}
+	if len(status.Rules) != 1 || status.Rules[0].Key != "greylist_burst" || status.Source != path {
+		t.Fatalf("expected previous rules to stay active, got %+v", status)
+	}
+
+	writeRules(`{rules: [{key: lag, metric: sync_lag_seconds, comparator: ">", threshold: 60, severity: warning}]}`, base.Add(2*time.Minute))
+	status, err = app.refreshAlertRules(false)
+	if err != nil || status.Error != "" || len(status.Rules) != 1 || status.Rules[0].Key != "lag" {
+		t.Fatalf("expected reloaded rules, got status=%+v err=%v", status, err)
+	}
+	if alerts := app.evaluateReleaseAlertsAt(now, app.collectAlertMetrics(now, app.GetReleaseMetrics())); len(alerts) != 0 {
+		t.Fatalf("removed rule should resolve, got %+v", alerts)
+	}
+}
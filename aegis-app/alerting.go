@@ -20,12 +20,139 @@ type ReleaseAlert struct {
 	TriggeredAt int64   `json:"triggeredAt"`
 }
 
-type releaseAlertRule struct {
-	Key       string
-	Metric    string
-	Level     string
-	WindowSec int64
-	Breached  func(metrics ReleaseMetrics) (bool, float64, float64)
+// AlertRule raises an alert once Metric has compared true against Threshold
+// for WindowSec. MinSamples guards sampled metrics (rates, latency) against
+// firing on too few observations.
+type AlertRule struct {
+	Key        string  `json:"key"`
+	Metric     string  `json:"metric"`
+	Comparator string  `json:"comparator"`
+	Threshold  float64 `json:"threshold"`
+	WindowSec  int64   `json:"windowSec"`
+	Severity   string  `json:"severity"`
+	MinSamples int64   `json:"minSamples"`
+}
+
+// alertMetricSnapshot holds every metric alert rules and metric history can
+// refer to. Sampled metrics are absent while nothing has been observed.
+type alertMetricSnapshot struct {
+	Values  map[string]float64
+	Samples map[string]int64
+}
+
+// alertMetricNames lists the metrics rules and GetMetricHistory accept. The
+// first nine are the json names of ReleaseMetrics.
+var alertMetricNames = []string{
+	"content_fetch_success_rate",
+	"content_fetch_latency_p95",
+	"blob_cache_hit_rate",
+	"sync_lag_seconds",
+	"content_fetch_attempts",
+	"content_fetch_success",
+	"content_fetch_failures",
+	"blob_cache_hits",
+	"blob_cache_misses",
+	"connected_peers",
+	"peer_greylist_size",
+	"peer_greylist_growth",
+	"peer_blacklist_size",
+	"storage_quota_usage",
+	"storage_private_quota_usage",
+	"storage_public_quota_usage",
+	"storage_media_quota_usage",
+}
+
+// sampledAlertMetrics are the metrics backed by a sample count, which is what
+// MinSamples is compared against.
+var sampledAlertMetrics = map[string]bool{
+	"content_fetch_success_rate": true,
+	"content_fetch_latency_p95":  true,
+	"blob_cache_hit_rate":        true,
+}
+
+func isAlertMetricName(name string) bool {
+	for _, known := range alertMetricNames {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+func releaseAlertSnapshot(metrics ReleaseMetrics) alertMetricSnapshot {
+	snapshot := alertMetricSnapshot{
+		Values: map[string]float64{
+			"sync_lag_seconds":       float64(metrics.SyncLagSeconds),
+			"content_fetch_attempts": float64(metrics.ContentFetchAttempts),
+			"content_fetch_success":  float64(metrics.ContentFetchSuccess),
+			"content_fetch_failures": float64(metrics.ContentFetchFailures),
+			"blob_cache_hits":        float64(metrics.BlobCacheHits),
+			"blob_cache_misses":      float64(metrics.BlobCacheMisses),
+		},
+		Samples: make(map[string]int64, len(sampledAlertMetrics)),
+	}
+	if metrics.ContentFetchAttempts > 0 {
+		snapshot.Values["content_fetch_success_rate"] = metrics.ContentFetchSuccessRate
+		snapshot.Values["content_fetch_latency_p95"] = float64(metrics.ContentFetchLatencyP95)
+		snapshot.Samples["content_fetch_success_rate"] = metrics.ContentFetchAttempts
+		snapshot.Samples["content_fetch_latency_p95"] = metrics.ContentFetchAttempts
+	}
+	if lookups := metrics.BlobCacheHits + metrics.BlobCacheMisses; lookups > 0 {
+		snapshot.Values["blob_cache_hit_rate"] = metrics.BlobCacheHitRate
+		snapshot.Samples["blob_cache_hit_rate"] = lookups
+	}
+	return snapshot
+}
+
+// collectAlertMetrics extends the release metrics with peer, greylist and
+// storage quota gauges.
+func (a *App) collectAlertMetrics(now int64, metrics ReleaseMetrics) alertMetricSnapshot {
+	snapshot := releaseAlertSnapshot(metrics)
+
+	snapshot.Values["connected_peers"] = float64(len(a.GetP2PStatus().ConnectedPeers))
+	greylisted, blacklisted := a.peerPolicySizes()
+	snapshot.Values["peer_greylist_size"] = float64(greylisted)
+	snapshot.Values["peer_blacklist_size"] = float64(blacklisted)
+	snapshot.Values["peer_greylist_growth"] = float64(a.recentGreylistAdditions(now))
+
+	if usage, err := a.GetStorageUsage(); err == nil {
+		if usage.TotalQuota > 0 {
+			snapshot.Values["storage_quota_usage"] = float64(usage.TotalUsedBytes) / float64(usage.TotalQuota)
+		}
+		if usage.PrivateQuota > 0 {
+			snapshot.Values["storage_private_quota_usage"] = float64(usage.PrivateUsedBytes) / float64(usage.PrivateQuota)
+		}
+		if usage.PublicQuota > 0 {
+			snapshot.Values["storage_public_quota_usage"] = float64(usage.PublicUsedBytes) / float64(usage.PublicQuota)
+		}
+		if usage.MediaQuota > 0 {
+			snapshot.Values["storage_media_quota_usage"] = float64(usage.MediaUsedBytes) / float64(usage.MediaQuota)
+		}
+	}
+	return snapshot
+}
+
+// breached reports whether the rule's condition holds in snapshot, and the
+// metric value it was checked against.
+func (rule AlertRule) breached(snapshot alertMetricSnapshot) (bool, float64) {
+	value, ok := snapshot.Values[rule.Metric]
+	if !ok {
+		return false, 0
+	}
+	if rule.MinSamples > 0 && snapshot.Samples[rule.Metric] < rule.MinSamples {
+		return false, value
+	}
+	switch rule.Comparator {
+	case "<":
+		return value < rule.Threshold, value
+	case "<=":
+		return value <= rule.Threshold, value
+	case ">":
+		return value > rule.Threshold, value
+	case ">=":
+		return value >= rule.Threshold, value
+	}
+	return false, value
 }
 
 func (a *App) GetReleaseAlerts() []ReleaseAlert {
@@ -40,7 +167,8 @@ func (a *App) GetReleaseAlerts() []ReleaseAlert {
 }
 
 func (a *App) TriggerReleaseAlertEvaluationNow() []ReleaseAlert {
-	return a.evaluateReleaseAlertsAt(time.Now().Unix(), a.GetReleaseMetrics())
+	now := time.Now().Unix()
+	return a.evaluateReleaseAlertsAt(now, a.collectAlertMetrics(now, a.GetReleaseMetrics()))
 }
 
 func (a *App) runReleaseAlertWorker(ctx context.Context) {
@@ -48,25 +176,50 @@ func (a *App) runReleaseAlertWorker(ctx context.Context) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	a.evaluateReleaseAlertsAt(time.Now().Unix(), a.GetReleaseMetrics())
+	lastPrune := int64(0)
+	evaluate := func() {
+		now := time.Now().Unix()
+		_, _ = a.refreshAlertRules(false)
+		snapshot := a.collectAlertMetrics(now, a.GetReleaseMetrics())
+		if err := a.recordMetricHistoryAt(now, snapshot); err != nil && a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "metric_history.record failed err=%v", err)
+		}
+		a.evaluateReleaseAlertsAt(now, snapshot)
+
+		if now-lastPrune < int64(metricHistoryPruneInterval/time.Second) {
+			return
+		}
+		lastPrune = now
+		if removed, err := a.pruneMetricHistoryAt(now); err != nil {
+			if a.ctx != nil {
+				runtime.LogWarningf(a.ctx, "metric_history.prune failed err=%v", err)
+			}
+		} else if removed > 0 && a.ctx != nil {
+			runtime.LogInfof(a.ctx, "metric_history.pruned rows=%d", removed)
+		}
+	}
+
+	evaluate()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			a.evaluateReleaseAlertsAt(time.Now().Unix(), a.GetReleaseMetrics())
+			evaluate()
 		}
 	}
 }
 
-func (a *App) evaluateReleaseAlertsAt(now int64, metrics ReleaseMetrics) []ReleaseAlert {
-	rules := releaseAlertRules()
-	current := make(map[string]ReleaseAlert, len(rules))
-
+func (a *App) evaluateReleaseAlertsAt(now int64, snapshot alertMetricSnapshot) []ReleaseAlert {
 	a.releaseAlertMu.Lock()
+	rules := a.releaseAlertRules.Rules
+	if rules == nil {
+		rules = defaultReleaseAlertRules()
+	}
+	current := make(map[string]ReleaseAlert, len(rules))
 	for _, rule := range rules {
-		breached, value, threshold := rule.Breached(metrics)
+		breached, value := rule.breached(snapshot)
 		if !breached {
 			delete(a.releaseAlertState, rule.Key)
 			continue
@@ -84,9 +237,9 @@ func (a *App) evaluateReleaseAlertsAt(now int64, metrics ReleaseMetrics) []Relea
 		current[rule.Key] = ReleaseAlert{
 			Key:         rule.Key,
 			Metric:      rule.Metric,
-			Level:       rule.Level,
+			Level:       rule.Severity,
 			Value:       value,
-			Threshold:   threshold,
+			Threshold:   rule.Threshold,
 			WindowSec:   rule.WindowSec,
 			TriggeredAt: now,
 		}
@@ -96,21 +249,27 @@ func (a *App) evaluateReleaseAlertsAt(now int64, metrics ReleaseMetrics) []Relea
 	a.releaseAlertActive = current
 	a.releaseAlertMu.Unlock()
 
-	if a.ctx != nil {
-		for key, alert := range current {
-			if _, existed := previous[key]; existed {
-				continue
-			}
+	raised := make([]ReleaseAlert, 0)
+	for key, alert := range current {
+		if _, existed := previous[key]; existed {
+			continue
+		}
+		raised = append(raised, alert)
+		if a.ctx != nil {
 			runtime.LogWarningf(
 				a.ctx,
 				"release_alert.raised key=%s metric=%s level=%s value=%.6f threshold=%.6f window_sec=%d",
 				alert.Key, alert.Metric, alert.Level, alert.Value, alert.Threshold, alert.WindowSec,
 			)
 		}
-		for key, alert := range previous {
-			if _, stillActive := current[key]; stillActive {
-				continue
-			}
+	}
+	recovered := make([]ReleaseAlert, 0)
+	for key, alert := range previous {
+		if _, stillActive := current[key]; stillActive {
+			continue
+		}
+		recovered = append(recovered, alert)
+		if a.ctx != nil {
 			runtime.LogInfof(
 				a.ctx,
 				"release_alert.recovered key=%s metric=%s level=%s",
@@ -118,6 +277,13 @@ func (a *App) evaluateReleaseAlertsAt(now int64, metrics ReleaseMetrics) []Relea
 			)
 		}
 	}
+	if err := a.recordReleaseAlertEvents(now, releaseAlertEventFired, raised); err != nil && a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "release_alert.history_write failed err=%v", err)
+	}
+	if err := a.recordReleaseAlertEvents(now, releaseAlertEventResolved, recovered); err != nil && a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "release_alert.history_write failed err=%v", err)
+	}
+	a.notifyAlertTransitions(now, raised, recovered)
 
 	result := make([]ReleaseAlert, 0, len(current))
 	for _, alert := range current {
@@ -126,93 +292,17 @@ func (a *App) evaluateReleaseAlertsAt(now int64, metrics ReleaseMetrics) []Relea
 	return result
 }
 
-func releaseAlertRules() []releaseAlertRule {
-	return []releaseAlertRule{
-		{
-			Key:       "content_fetch_success_rate_warning",
-			Metric:    "content_fetch_success_rate",
-			Level:     "warning",
-			WindowSec: 300,
-			Breached: func(metrics ReleaseMetrics) (bool, float64, float64) {
-				threshold := 0.95
-				if metrics.ContentFetchAttempts == 0 {
-					return false, metrics.ContentFetchSuccessRate, threshold
-				}
-				return metrics.ContentFetchSuccessRate < threshold, metrics.ContentFetchSuccessRate, threshold
-			},
-		},
-		{
-			Key:       "content_fetch_success_rate_critical",
-			Metric:    "content_fetch_success_rate",
-			Level:     "critical",
-			WindowSec: 180,
-			Breached: func(metrics ReleaseMetrics) (bool, float64, float64) {
-				threshold := 0.85
-				if metrics.ContentFetchAttempts == 0 {
-					return false, metrics.ContentFetchSuccessRate, threshold
-				}
-				return metrics.ContentFetchSuccessRate < threshold, metrics.ContentFetchSuccessRate, threshold
-			},
-		},
-		{
-			Key:       "content_fetch_latency_p95_warning",
-			Metric:    "content_fetch_latency_p95",
-			Level:     "warning",
-			WindowSec: 300,
-			Breached: func(metrics ReleaseMetrics) (bool, float64, float64) {
-				threshold := 3000.0
-				if metrics.ContentFetchAttempts == 0 {
-					return false, float64(metrics.ContentFetchLatencyP95), threshold
-				}
-				return float64(metrics.ContentFetchLatencyP95) > threshold, float64(metrics.ContentFetchLatencyP95), threshold
-			},
-		},
-		{
-			Key:       "content_fetch_latency_p95_critical",
-			Metric:    "content_fetch_latency_p95",
-			Level:     "critical",
-			WindowSec: 180,
-			Breached: func(metrics ReleaseMetrics) (bool, float64, float64) {
-				threshold := 5000.0
-				if metrics.ContentFetchAttempts == 0 {
-					return false, float64(metrics.ContentFetchLatencyP95), threshold
-				}
-				return float64(metrics.ContentFetchLatencyP95) > threshold, float64(metrics.ContentFetchLatencyP95), threshold
-			},
-		},
-		{
-			Key:       "blob_cache_hit_rate_warning",
-			Metric:    "blob_cache_hit_rate",
-			Level:     "warning",
-			WindowSec: 600,
-			Breached: func(metrics ReleaseMetrics) (bool, float64, float64) {
-				threshold := 0.60
-				if metrics.BlobCacheHits+metrics.BlobCacheMisses == 0 {
-					return false, metrics.BlobCacheHitRate, threshold
-				}
-				return metrics.BlobCacheHitRate < threshold, metrics.BlobCacheHitRate, threshold
-			},
-		},
-		{
-			Key:       "sync_lag_seconds_warning",
-			Metric:    "sync_lag_seconds",
-			Level:     "warning",
-			WindowSec: 300,
-			Breached: func(metrics ReleaseMetrics) (bool, float64, float64) {
-				threshold := 180.0
-				return float64(metrics.SyncLagSeconds) > threshold, float64(metrics.SyncLagSeconds), threshold
-			},
-		},
-		{
-			Key:       "sync_lag_seconds_critical",
-			Metric:    "sync_lag_seconds",
-			Level:     "critical",
-			WindowSec: 180,
-			Breached: func(metrics ReleaseMetrics) (bool, float64, float64) {
-				threshold := 600.0
-				return float64(metrics.SyncLagSeconds) > threshold, float64(metrics.SyncLagSeconds), threshold
-			},
-		},
+// defaultReleaseAlertRules are the R5 runbook thresholds, used when no rules
+// file is configured.
+func defaultReleaseAlertRules() []AlertRule {
+	return []AlertRule{
+		{Key: "content_fetch_success_rate_warning", Metric: "content_fetch_success_rate", Comparator: "<", Threshold: 0.95, WindowSec: 300, Severity: "warning", MinSamples: 1},
+		{Key: "content_fetch_success_rate_critical", Metric: "content_fetch_success_rate", Comparator: "<", Threshold: 0.85, WindowSec: 180, Severity: "critical", MinSamples: 1},
+		{Key: "content_fetch_latency_p95_warning", Metric: "content_fetch_latency_p95", Comparator: ">", Threshold: 3000, WindowSec: 300, Severity: "warning", MinSamples: 1},
+		{Key: "content_fetch_latency_p95_critical", Metric: "content_fetch_latency_p95", Comparator: ">", Threshold: 5000, WindowSec: 180, Severity: "critical", MinSamples: 1},
+		{Key: "blob_cache_hit_rate_warning", Metric: "blob_cache_hit_rate", Comparator: "<", Threshold: 0.60, WindowSec: 600, Severity: "warning", MinSamples: 1},
+		{Key: "sync_lag_seconds_warning", Metric: "sync_lag_seconds", Comparator: ">", Threshold: 180, WindowSec: 300, Severity: "warning"},
+		{Key: "sync_lag_seconds_critical", Metric: "sync_lag_seconds", Comparator: ">", Threshold: 600, WindowSec: 180, Severity: "critical"},
 	}
 }
 
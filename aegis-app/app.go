@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -38,13 +39,19 @@ type App struct {
 	dbMu   sync.Mutex
 	dbPath string
 
-	p2pMu     sync.Mutex
-	p2pCtx    context.Context
-	p2pCancel context.CancelFunc
-	p2pHost   host.Host
-	p2pTopic  *pubsub.Topic
-	p2pSub    *pubsub.Subscription
-	mdnsSvc   io.Closer
+	// evictionScorer ranks blobs for quota eviction; nil means the policy
+	// named by AEGIS_EVICTION_POLICY.
+	evictionScorer evictionScorer
+
+	p2pMu        sync.Mutex
+	p2pCtx       context.Context
+	p2pCancel    context.CancelFunc
+	p2pHost      host.Host
+	p2pPubsub    *pubsub.PubSub
+	p2pTopic     *pubsub.Topic
+	p2pSub       *pubsub.Subscription
+	p2pSubTopics map[string]*subTopic
+	mdnsSvc      io.Closer
 
 	fetchRateMu    sync.Mutex
 	fetchRateState map[string]fetchRateWindow
@@ -52,10 +59,19 @@ type App struct {
 	peerBlacklist  map[string]struct{}
 	peerGreylist   map[string]int64
 
-	contentFetchGroup   singleflight.Group
-	contentFetchWaiters map[string]chan IncomingMessage
-	mediaFetchGroup     singleflight.Group
-	mediaFetchWaiters   map[string]chan IncomingMessage
+	blobMismatchStrikes map[string]int
+
+	contentFetchGroup singleflight.Group
+	mediaFetchGroup   singleflight.Group
+	searchWaitMu      sync.Mutex
+	searchWaiters     map[string]chan IncomingMessage
+	revisionSyncMu    sync.Mutex
+	revisionSyncAsked map[string]time.Time
+	reconcileSkipMu   sync.Mutex
+	reconcileSkips    map[string]reconcileSkip
+	maintenanceMu     sync.Mutex
+	blobPartialMu     sync.Mutex
+	blobPartials      map[string]*blobPartial
 
 	antiEntropyMu      sync.Mutex
 	antiEntropyStats   AntiEntropyStats
@@ -64,10 +80,23 @@ type App struct {
 	releaseAlertMu     sync.Mutex
 	releaseAlertState  map[string]int64
 	releaseAlertActive map[string]ReleaseAlert
+	releaseAlertRules  alertRuleSet
+	alertNotifyMu      sync.Mutex
+	alertNotify        *alertDispatcher
 	voteBroadcastMu    sync.Mutex
 	voteBroadcastSeq   map[string]int64
 
+	adminAPIMu     sync.Mutex
+	adminAPIServer *http.Server
+	metricsServer  *http.Server
+
 	defaultRecStrategy string
+	recStrategyMu      sync.Mutex
+	recStrategies      recommendationStrategySet
+
+	identityMu     sync.RWMutex
+	identityKey    ed25519.PrivateKey
+	identityPubkey string
 }
 
 type AntiEntropyStats struct {
@@ -82,10 +111,17 @@ type AntiEntropyStats struct {
 	LastSyncAt             int64 `json:"lastSyncAt"`
 	LastRemoteSummaryTs    int64 `json:"lastRemoteSummaryTs"`
 	LastObservedSyncLagSec int64 `json:"lastObservedSyncLagSec"`
+	ReconcileSessions      int64 `json:"reconcileSessions"`
+	ReconcileRanges        int64 `json:"reconcileRanges"`
+	ReconcileItemsFetched  int64 `json:"reconcileItemsFetched"`
+	LastReconcileAt        int64 `json:"lastReconcileAt"`
 }
 
+// Identity is what crosses the Wails bridge. Mnemonic is only filled by
+// GenerateIdentity so the user can back it up once; it is never stored in
+// plaintext and never returned by load/unlock.
 type Identity struct {
-	Mnemonic  string `json:"mnemonic"`
+	Mnemonic  string `json:"mnemonic,omitempty"`
 	PublicKey string `json:"publicKey"`
 }
 
@@ -102,16 +138,16 @@ func NewApp() *App {
 	}
 
 	return &App{
-		dbPath:              databasePath,
-		contentFetchWaiters: make(map[string]chan IncomingMessage),
-		mediaFetchWaiters:   make(map[string]chan IncomingMessage),
-		fetchRateState:      make(map[string]fetchRateWindow),
-		peerBlacklist:       make(map[string]struct{}),
-		peerGreylist:        make(map[string]int64),
-		releaseAlertState:   make(map[string]int64),
-		releaseAlertActive:  make(map[string]ReleaseAlert),
-		voteBroadcastSeq:    make(map[string]int64),
-		defaultRecStrategy:  defaultStrategy,
+		dbPath:             databasePath,
+		blobPartials:       make(map[string]*blobPartial),
+		p2pSubTopics:       make(map[string]*subTopic),
+		fetchRateState:     make(map[string]fetchRateWindow),
+		peerBlacklist:      make(map[string]struct{}),
+		peerGreylist:       make(map[string]int64),
+		releaseAlertState:  make(map[string]int64),
+		releaseAlertActive: make(map[string]ReleaseAlert),
+		voteBroadcastSeq:   make(map[string]int64),
+		defaultRecStrategy: defaultStrategy,
 	}
 }
 
@@ -138,6 +174,13 @@ func (a *App) startup(ctx context.Context) {
 		runtime.LogErrorf(ctx, "database initialization failed: %v", err)
 		return
 	}
+	a.unlockIdentityFromEnv()
+	if _, err := a.startAdminAPI(); err != nil {
+		runtime.LogErrorf(ctx, "admin api start failed: %v", err)
+	}
+	if _, err := a.startMetricsExporter(); err != nil {
+		runtime.LogErrorf(ctx, "metrics exporter start failed: %v", err)
+	}
 
 	trustedAdminsEnv := strings.TrimSpace(os.Getenv("AEGIS_TRUSTED_ADMINS"))
 	if trustedAdminsEnv != "" {
@@ -181,6 +224,12 @@ func (a *App) startup(ctx context.Context) {
 }
 
 func (a *App) shutdown(ctx context.Context) {
+	if err := a.stopAdminAPI(); err != nil {
+		runtime.LogErrorf(ctx, "admin api shutdown failed: %v", err)
+	}
+	if err := a.stopMetricsExporter(); err != nil {
+		runtime.LogErrorf(ctx, "metrics exporter shutdown failed: %v", err)
+	}
 	if err := a.StopP2P(); err != nil {
 		runtime.LogErrorf(ctx, "p2p shutdown failed: %v", err)
 	}
@@ -205,64 +254,16 @@ func (a *App) deriveKeypairFromMnemonic(mnemonic string) (ed25519.PublicKey, ed2
 	return publicKey, privateKey, nil
 }
 
-func (a *App) GenerateIdentity() (Identity, error) {
-	entropy, err := bip39.NewEntropy(128)
-	if err != nil {
-		return Identity{}, err
-	}
-
-	mnemonic, err := bip39.NewMnemonic(entropy)
-	if err != nil {
-		return Identity{}, err
-	}
-
-	publicKey, _, err := a.deriveKeypairFromMnemonic(mnemonic)
-	if err != nil {
-		return Identity{}, err
-	}
-
-	identity := Identity{
-		Mnemonic:  mnemonic,
-		PublicKey: hex.EncodeToString(publicKey),
-	}
-
-	if err = a.saveLocalIdentity(identity); err != nil {
-		return Identity{}, err
-	}
-
-	return identity, nil
+func (a *App) GenerateIdentity(passphrase string) (Identity, error) {
+	return a.CreateIdentity("", passphrase)
 }
 
 func (a *App) LoadSavedIdentity() (Identity, error) {
 	return a.getLocalIdentity()
 }
 
-func (a *App) ImportIdentityFromMnemonic(mnemonic string) (Identity, error) {
-	publicKey, _, err := a.deriveKeypairFromMnemonic(strings.TrimSpace(mnemonic))
-	if err != nil {
-		return Identity{}, err
-	}
-
-	identity := Identity{
-		Mnemonic:  strings.TrimSpace(mnemonic),
-		PublicKey: hex.EncodeToString(publicKey),
-	}
-
-	if err = a.saveLocalIdentity(identity); err != nil {
-		return Identity{}, err
-	}
-
-	return identity, nil
-}
-
-func (a *App) SignMessage(mnemonic string, message string) (string, error) {
-	_, privateKey, err := a.deriveKeypairFromMnemonic(mnemonic)
-	if err != nil {
-		return "", err
-	}
-
-	signature := ed25519.Sign(privateKey, []byte(message))
-	return hex.EncodeToString(signature), nil
+func (a *App) ImportIdentityFromMnemonic(mnemonic string, passphrase string) (Identity, error) {
+	return a.storeAndUnlockIdentity(strings.TrimSpace(mnemonic), passphrase)
 }
 
 func (a *App) VerifyMessage(publicKeyHex string, message string, signatureHex string) (bool, error) {
@@ -370,7 +371,7 @@ func (a *App) GetFeedStreamWithStrategy(limit int, algorithm string) (FeedStream
 		algorithm = a.defaultRecStrategy
 	}
 
-	strategy, err := GetStrategy(algorithm)
+	strategy, err := a.resolveRecommendationStrategy(algorithm)
 	if err != nil {
 		// Try fallback to hot-v1
 		strategy, err = GetStrategy("hot-v1")
@@ -417,15 +418,32 @@ func (a *App) GetFeedStreamWithStrategy(limit int, algorithm string) (FeedStream
 	// Or, if strategy is just "hot-v1", use optimized SQL.
 	// For N2 MVP, we can keep using SQL for candidates, but apply strategy logic for re-ranking/scoring.
 
-	// Fetching raw candidates (latest 200 from non-subscribed subs)
-	candidatePosts, err := a.queryRecommendedCandidates(viewerPubkey, subscribedSubIDs, max(limit*4, 100))
+	// Fetching raw candidates from non-subscribed subs: the latest posts, or
+	// the window a strategy asks for
+	candidateWindow := RecommendationCandidateWindow{}
+	if windowed, ok := strategy.(CandidateWindowStrategy); ok {
+		candidateWindow = windowed.CandidateWindow(now)
+	}
+	candidatePosts, err := a.queryRecommendedCandidates(viewerPubkey, subscribedSubIDs, candidateWindow, max(limit*4, 100))
 	if err != nil {
 		return FeedStream{}, err
 	}
 
-	rankedRecommendations, err := strategy.Rank(candidatePosts, viewerPubkey, now)
-	if err != nil {
-		return FeedStream{}, err
+	var rankedRecommendations []FeedStreamItem
+	if signalStrategy, ok := strategy.(SignalRankingStrategy); ok {
+		signals, err := a.loadRankingSignals(candidatePosts, viewerPubkey, now)
+		if err != nil {
+			return FeedStream{}, err
+		}
+		rankedRecommendations, err = signalStrategy.RankWithSignals(candidatePosts, signals, viewerPubkey, now)
+		if err != nil {
+			return FeedStream{}, err
+		}
+	} else {
+		rankedRecommendations, err = strategy.Rank(candidatePosts, viewerPubkey, now)
+		if err != nil {
+			return FeedStream{}, err
+		}
 	}
 
 	items := make([]FeedStreamItem, 0, limit)
@@ -515,39 +533,37 @@ func (a *App) GetFeedStreamWithStrategy(limit int, algorithm string) (FeedStream
 	}, nil
 }
 
-func (a *App) queryRecommendedCandidates(viewerPubkey string, subscribedSubIDs []string, limit int) ([]ForumMessage, error) {
+func (a *App) queryRecommendedCandidates(viewerPubkey string, subscribedSubIDs []string, window RecommendationCandidateWindow, limit int) ([]ForumMessage, error) {
 	if limit <= 0 {
 		limit = 100
 	}
 
-	if len(subscribedSubIDs) == 0 {
-		return a.queryForumMessages(`
-			SELECT id, pubkey, title, body, content_cid, content, score, timestamp, size_bytes, zone, sub_id, is_protected, visibility
-			FROM messages
-			WHERE zone = 'public'
-			  AND (visibility = 'normal' OR (pubkey = ? AND visibility != 'deleted'))
-			ORDER BY timestamp DESC
-			LIMIT ?;
-		`, viewerPubkey, limit)
+	args := make([]interface{}, 0, len(subscribedSubIDs)+3)
+	args = append(args, viewerPubkey, window.Since)
+	subFilter := ""
+	if len(subscribedSubIDs) > 0 {
+		subFilter = fmt.Sprintf("AND sub_id NOT IN (%s)", makeSQLPlaceholders(len(subscribedSubIDs)))
+		for _, subID := range subscribedSubIDs {
+			args = append(args, normalizeSubID(subID))
+		}
 	}
+	args = append(args, limit)
 
-	placeholders := makeSQLPlaceholders(len(subscribedSubIDs))
-	args := make([]interface{}, 0, len(subscribedSubIDs)+2)
-	args = append(args, viewerPubkey)
-	for _, subID := range subscribedSubIDs {
-		args = append(args, normalizeSubID(subID))
+	order := "timestamp DESC"
+	if window.ByScore {
+		order = "score DESC, timestamp DESC"
 	}
-	args = append(args, limit)
 
 	query := fmt.Sprintf(`
 		SELECT id, pubkey, title, body, content_cid, content, score, timestamp, size_bytes, zone, sub_id, is_protected, visibility
 		FROM messages
 		WHERE zone = 'public'
 		  AND (visibility = 'normal' OR (pubkey = ? AND visibility != 'deleted'))
-		  AND sub_id NOT IN (%s)
-		ORDER BY timestamp DESC
+		  AND timestamp >= ?
+		  %s
+		ORDER BY %s
 		LIMIT ?;
-	`, placeholders)
+	`, subFilter, order)
 
 	return a.queryForumMessages(query, args...)
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newTestApp opens a fresh file database in a temp dir, the way the app does
+// on startup.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	app := NewApp()
+	app.SetDatabasePath(filepath.Join(t.TempDir(), "aegis_test.db"))
+	if err := app.initDatabase(); err != nil {
+		t.Fatalf("init database: %v", err)
+	}
+	t.Cleanup(func() {
+		if app.db != nil {
+			_ = app.db.Close()
+		}
+	})
+	return app
+}
+
+func countRows(t *testing.T, app *App, query string, args ...any) int {
+	t.Helper()
+	var count int
+	if err := app.db.QueryRow(query, args...).Scan(&count); err != nil {
+		t.Fatalf("count rows: %v", err)
+	}
+	return count
+}
+
+// startTestP2P gives app a loopback host and a gossipsub router joined to the
+// control topic, without the discovery and workers StartP2P runs.
+func startTestP2P(t *testing.T, app *App) {
+	t.Helper()
+	node, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("start host: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	gossip, err := pubsub.NewGossipSub(ctx, node)
+	if err != nil {
+		cancel()
+		_ = node.Close()
+		t.Fatalf("start gossipsub: %v", err)
+	}
+	topic, err := gossip.Join(forumTopicName)
+	if err != nil {
+		cancel()
+		_ = node.Close()
+		t.Fatalf("join control topic: %v", err)
+	}
+	subscription, err := topic.Subscribe()
+	if err != nil {
+		cancel()
+		_ = node.Close()
+		t.Fatalf("subscribe control topic: %v", err)
+	}
+
+	app.p2pMu.Lock()
+	app.p2pCtx = ctx
+	app.p2pCancel = cancel
+	app.p2pHost = node
+	app.p2pPubsub = gossip
+	app.p2pTopic = topic
+	app.p2pSub = subscription
+	app.p2pMu.Unlock()
+	go app.consumeP2PMessages(ctx, node.ID(), subscription)
+	t.Cleanup(func() { _ = app.StopP2P() })
+}
+
+// testPubsubPeer is a bare gossipsub node that watches what app publishes.
+type testPubsubPeer struct {
+	host   host.Host
+	gossip *pubsub.PubSub
+	ctx    context.Context
+}
+
+// newTestPubsubPeer starts a gossipsub node connected to app, which must
+// already run startTestP2P.
+func newTestPubsubPeer(t *testing.T, app *App) *testPubsubPeer {
+	t.Helper()
+	node, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("start peer host: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		_ = node.Close()
+	})
+	gossip, err := pubsub.NewGossipSub(ctx, node)
+	if err != nil {
+		t.Fatalf("start peer gossipsub: %v", err)
+	}
+	if err = node.Connect(ctx, peer.AddrInfo{ID: app.p2pHost.ID(), Addrs: app.p2pHost.Addrs()}); err != nil {
+		t.Fatalf("connect peer: %v", err)
+	}
+	return &testPubsubPeer{host: node, gossip: gossip, ctx: ctx}
+}
+
+// subscribe subscribes the peer to topicName and waits until app sees it.
+func (p *testPubsubPeer) subscribe(t *testing.T, app *App, topicName string) *pubsub.Subscription {
+	t.Helper()
+	topic, err := p.gossip.Join(topicName)
+	if err != nil {
+		t.Fatalf("join %s: %v", topicName, err)
+	}
+	subscription, err := topic.Subscribe()
+	if err != nil {
+		t.Fatalf("subscribe %s: %v", topicName, err)
+	}
+	waitForTestCondition(t, "app to see the subscription to "+topicName, func() bool {
+		return topicHasPeer(app.p2pPubsub.ListPeers(topicName), p.host.ID())
+	})
+	return subscription
+}
+
+// next returns the next message on subscription, or nil after a second.
+func (p *testPubsubPeer) next(subscription *pubsub.Subscription) *pubsub.Message {
+	ctx, cancel := context.WithTimeout(p.ctx, time.Second)
+	defer cancel()
+	message, err := subscription.Next(ctx)
+	if err != nil {
+		return nil
+	}
+	return message
+}
+
+func topicHasPeer(peers []peer.ID, id peer.ID) bool {
+	for _, candidate := range peers {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func waitForTestCondition(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
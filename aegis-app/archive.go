@@ -0,0 +1,911 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// A node archive is a gzipped tar holding the dataset of a node, so it can
+// move to another machine or be restored after a reset. manifest.json comes
+// first and carries the format version. Every domain is a JSONL file with one
+// record per line, and blobs are stored as files named by their CID: content
+// bodies under blobs/content/ and media under blobs/media/, with the media
+// metadata in media.jsonl. Entries are written in the order an import applies
+// them, so blobs are in place before the posts that point at them.
+//
+// Import replays every record through the same write paths sync uses, which
+// keep the newest Lamport version of a post or comment, skip op IDs they have
+// already seen and treat votes and favorites as state. Importing an archive
+// twice, or into a node that already holds part of it, therefore changes
+// nothing that is already current. An archive is only as trustworthy as the
+// file, so posts and comments are checked against the signatures of their
+// current ops like synced digests, favorites against their own signatures and
+// moderation entries against their admin's; records that fail are skipped.
+// Entity ops are only kept for verified posts and comments by the same
+// author. Votes, subs and profiles are stored as state without the signed ops
+// that produced them and are imported as is.
+const (
+	archiveFormatName    = "aegis-archive"
+	archiveFormatVersion = 1
+
+	archiveManifestFile      = "manifest.json"
+	archiveSubsFile          = "subs.jsonl"
+	archiveSubscriptionsFile = "subscriptions.jsonl"
+	archiveProfilesFile      = "profiles.jsonl"
+	archiveMediaFile         = "media.jsonl"
+	archivePostsFile         = "posts.jsonl"
+	archiveCommentsFile      = "comments.jsonl"
+	archiveOpsFile           = "entity_ops.jsonl"
+	archiveSignaturesFile    = "entity_signatures.jsonl"
+	archiveVotesFile         = "votes.jsonl"
+	archiveVoteOpsFile       = "vote_ops.jsonl"
+	archiveFavoritesFile     = "favorites.jsonl"
+	archiveModerationFile    = "moderation.jsonl"
+	archiveModerationLogFile = "moderation_logs.jsonl"
+
+	archiveContentBlobDir = "blobs/content/"
+	archiveMediaBlobDir   = "blobs/media/"
+
+	archiveMaxEntryBytes = 256 * 1024 * 1024
+	archiveMaxLineBytes  = 16 * 1024 * 1024
+)
+
+var (
+	errArchiveInvalid     = errors.New("invalid archive")
+	errArchiveUnsupported = errors.New("unsupported archive version")
+)
+
+type ArchiveOptions struct {
+	ExcludeMedia   bool `json:"excludeMedia"`
+	ExcludePrivate bool `json:"excludePrivate"`
+}
+
+type ArchiveManifest struct {
+	Format    string         `json:"format"`
+	Version   int            `json:"version"`
+	CreatedAt int64          `json:"createdAt"`
+	Pubkey    string         `json:"pubkey"`
+	Options   ArchiveOptions `json:"options"`
+}
+
+// ArchiveSummary counts the records of each archive file. On import, Skipped
+// counts the records that were malformed or rejected, e.g. a post whose
+// author differs from the one already stored, or a vote on a missing post.
+type ArchiveSummary struct {
+	Path      string          `json:"path"`
+	Manifest  ArchiveManifest `json:"manifest"`
+	Records   map[string]int  `json:"records"`
+	Skipped   map[string]int  `json:"skipped,omitempty"`
+	SizeBytes int64           `json:"sizeBytes"`
+}
+
+type archivePost struct {
+	ForumMessage
+	DeletedAtLamport int64 `json:"deletedAtLamport,omitempty"`
+}
+
+type archiveComment struct {
+	Comment
+	DeletedAtLamport int64 `json:"deletedAtLamport,omitempty"`
+}
+
+type archiveSubscription struct {
+	Pubkey       string `json:"pubkey"`
+	SubID        string `json:"subId"`
+	SubscribedAt int64  `json:"subscribedAt"`
+}
+
+type archiveMedia struct {
+	CID         string `json:"cid"`
+	Mime        string `json:"mime"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	IsThumbnail bool   `json:"isThumbnail"`
+}
+
+type archiveSignature struct {
+	OpID         string `json:"opId"`
+	EntityType   string `json:"entityType"`
+	EntityID     string `json:"entityId"`
+	SignerPubkey string `json:"signerPubkey"`
+	Signature    string `json:"signature"`
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+type archiveVote struct {
+	Target      string `json:"target"`
+	TargetID    string `json:"targetId"`
+	PostID      string `json:"postId"`
+	VoterPubkey string `json:"voterPubkey"`
+	State       string `json:"state"`
+	Timestamp   int64  `json:"timestamp,omitempty"`
+}
+
+type archiveVoteOp struct {
+	OpID      string `json:"opId"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// ExportArchive writes the node's dataset to path. The file is written next
+// to path first and renamed into place once complete.
+func (a *App) ExportArchive(path string, options ArchiveOptions) (ArchiveSummary, error) {
+	if a.db == nil {
+		return ArchiveSummary{}, errors.New("database not initialized")
+	}
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ArchiveSummary{}, errors.New("archive path is required")
+	}
+
+	manifest := ArchiveManifest{
+		Format:    archiveFormatName,
+		Version:   archiveFormatVersion,
+		CreatedAt: time.Now().Unix(),
+		Pubkey:    a.activeIdentityPubkey(),
+		Options:   options,
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return ArchiveSummary{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	// One read transaction keeps every file on the same snapshot.
+	tx, err := a.db.Begin()
+	if err != nil {
+		_ = tmp.Close()
+		return ArchiveSummary{}, err
+	}
+	defer tx.Rollback()
+
+	gz := gzip.NewWriter(tmp)
+	writer := &archiveWriter{tw: tar.NewWriter(gz), modTime: time.Unix(manifest.CreatedAt, 0), records: make(map[string]int)}
+	err = writer.writeJSON(archiveManifestFile, manifest)
+	if err == nil {
+		err = exportArchiveTx(tx, writer, options)
+	}
+	if err == nil {
+		err = writer.tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ArchiveSummary{}, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return ArchiveSummary{}, err
+	}
+
+	summary := ArchiveSummary{Path: path, Manifest: manifest, Records: writer.records}
+	if info, statErr := os.Stat(path); statErr == nil {
+		summary.SizeBytes = info.Size()
+	}
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "archive.exported path=%s size=%d posts=%d comments=%d", path, summary.SizeBytes, summary.Records[archivePostsFile], summary.Records[archiveCommentsFile])
+	}
+	return summary, nil
+}
+
+func exportArchiveTx(tx *sql.Tx, writer *archiveWriter, options ArchiveOptions) error {
+	postScope := `SELECT id FROM messages`
+	if options.ExcludePrivate {
+		postScope += ` WHERE zone = 'public'`
+	}
+	entityScope := `(entity_type = '` + entityTypePost + `' AND entity_id IN (` + postScope + `))
+		OR (entity_type = '` + entityTypeComment + `' AND entity_id IN (SELECT id FROM comments WHERE post_id IN (` + postScope + `)))`
+
+	if err := writer.writeRows(tx, archiveSubsFile, `
+		SELECT id, title, description, created_at FROM subs ORDER BY id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var sub Sub
+		err := rows.Scan(&sub.ID, &sub.Title, &sub.Description, &sub.CreatedAt)
+		return sub, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archiveSubscriptionsFile, `
+		SELECT pubkey, sub_id, subscribed_at FROM sub_subscriptions ORDER BY pubkey ASC, sub_id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var subscription archiveSubscription
+		err := rows.Scan(&subscription.Pubkey, &subscription.SubID, &subscription.SubscribedAt)
+		return subscription, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archiveProfilesFile, `
+		SELECT p.pubkey, p.display_name, p.avatar_url, COALESCE(d.bio, ''), MAX(p.updated_at, COALESCE(d.updated_at, 0))
+		FROM profiles p
+		LEFT JOIN profile_details d ON d.pubkey = p.pubkey
+		ORDER BY p.pubkey ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var profile ProfileDetails
+		err := rows.Scan(&profile.Pubkey, &profile.DisplayName, &profile.AvatarURL, &profile.Bio, &profile.UpdatedAt)
+		return profile, err
+	}); err != nil {
+		return err
+	}
+
+	if err := writer.writeBlobs(tx, archiveContentBlobDir, `
+		SELECT content_cid, CAST(body AS BLOB) FROM content_blobs
+		WHERE content_cid IN (SELECT content_cid FROM messages WHERE id IN (`+postScope+`))
+		ORDER BY content_cid ASC;
+	`); err != nil {
+		return err
+	}
+	if !options.ExcludeMedia {
+		if err := writer.writeRows(tx, archiveMediaFile, `
+			SELECT content_cid, mime, width, height, is_thumbnail FROM media_blobs ORDER BY content_cid ASC;
+		`, func(rows *sql.Rows) (any, error) {
+			var media archiveMedia
+			var thumb int
+			err := rows.Scan(&media.CID, &media.Mime, &media.Width, &media.Height, &thumb)
+			media.IsThumbnail = thumb == 1
+			return media, err
+		}); err != nil {
+			return err
+		}
+		if err := writer.writeBlobs(tx, archiveMediaBlobDir, `
+			SELECT content_cid, data FROM media_blobs ORDER BY content_cid ASC;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.writeRows(tx, archiveSignaturesFile, `
+		SELECT op_id, entity_type, entity_id, signer_pubkey, signature, created_at
+		FROM entity_signatures
+		WHERE `+entityScope+`
+		ORDER BY op_id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var signature archiveSignature
+		err := rows.Scan(&signature.OpID, &signature.EntityType, &signature.EntityID, &signature.SignerPubkey, &signature.Signature, &signature.CreatedAt)
+		return signature, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archivePostsFile, `
+		SELECT id, pubkey, current_op_id, title, body, content_cid, image_cid, thumb_cid, image_mime, image_size, image_width, image_height,
+			timestamp, lamport, zone, sub_id, is_protected, visibility, deleted_at_lamport, deleted_at_ts, deleted_by
+		FROM messages
+		WHERE id IN (`+postScope+`)
+		ORDER BY lamport ASC, id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var post archivePost
+		err := rows.Scan(&post.ID, &post.Pubkey, &post.OpID, &post.Title, &post.Body, &post.ContentCID, &post.ImageCID, &post.ThumbCID,
+			&post.ImageMIME, &post.ImageSize, &post.ImageWidth, &post.ImageHeight, &post.Timestamp, &post.Lamport, &post.Zone, &post.SubID,
+			&post.IsProtected, &post.Visibility, &post.DeletedAtLamport, &post.DeletedAt, &post.DeletedBy)
+		return post, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archiveCommentsFile, `
+		SELECT id, post_id, parent_id, pubkey, current_op_id, body, attachments_json, timestamp, lamport, deleted_at_lamport, deleted_at, deleted_by
+		FROM comments
+		WHERE post_id IN (`+postScope+`)
+		ORDER BY lamport ASC, id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var comment archiveComment
+		var attachments string
+		err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Pubkey, &comment.OpID, &comment.Body, &attachments,
+			&comment.Timestamp, &comment.Lamport, &comment.DeletedAtLamport, &comment.DeletedAt, &comment.DeletedBy)
+		comment.Attachments = decodeCommentAttachmentsJSON(attachments)
+		return comment, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archiveOpsFile, `
+		SELECT op_id, entity_type, entity_id, op_type, author_pubkey, lamport, timestamp, schema_version, auth_scope, payload_json
+		FROM entity_ops
+		WHERE `+entityScope+`
+		ORDER BY lamport ASC, op_id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var op EntityOpRecord
+		err := rows.Scan(&op.OpID, &op.EntityType, &op.EntityID, &op.OpType, &op.AuthorPubkey, &op.Lamport, &op.Timestamp, &op.SchemaVersion, &op.AuthScope, &op.PayloadJSON)
+		return op, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archiveVotesFile, `
+		SELECT 'post', post_id, post_id, voter_pubkey, 'UP', timestamp FROM post_votes WHERE post_id IN (`+postScope+`)
+		UNION ALL
+		SELECT 'post', post_id, post_id, voter_pubkey, 'DOWN', timestamp FROM post_downvotes WHERE post_id IN (`+postScope+`)
+		UNION ALL
+		SELECT 'comment', v.comment_id, c.post_id, v.voter_pubkey, 'UP', v.timestamp FROM comment_votes v JOIN comments c ON c.id = v.comment_id WHERE c.post_id IN (`+postScope+`)
+		UNION ALL
+		SELECT 'comment', v.comment_id, c.post_id, v.voter_pubkey, 'DOWN', v.timestamp FROM comment_downvotes v JOIN comments c ON c.id = v.comment_id WHERE c.post_id IN (`+postScope+`);
+	`, func(rows *sql.Rows) (any, error) {
+		var vote archiveVote
+		err := rows.Scan(&vote.Target, &vote.TargetID, &vote.PostID, &vote.VoterPubkey, &vote.State, &vote.Timestamp)
+		return vote, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archiveVoteOpsFile, `
+		SELECT op_id, created_at FROM vote_ops ORDER BY created_at ASC, op_id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var op archiveVoteOp
+		err := rows.Scan(&op.OpID, &op.CreatedAt)
+		return op, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archiveFavoritesFile, `
+		SELECT op_id, pubkey, post_id, op, created_at, signature FROM post_favorite_ops ORDER BY created_at ASC, op_id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var record FavoriteOpRecord
+		err := rows.Scan(&record.OpID, &record.Pubkey, &record.PostID, &record.Op, &record.CreatedAt, &record.Signature)
+		return record, err
+	}); err != nil {
+		return err
+	}
+	if err := writer.writeRows(tx, archiveModerationFile, `
+		SELECT target_pubkey, action, source_admin, timestamp, lamport, reason, signature FROM moderation ORDER BY lamport ASC, target_pubkey ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var state ModerationState
+		err := rows.Scan(&state.TargetPubkey, &state.Action, &state.SourceAdmin, &state.Timestamp, &state.Lamport, &state.Reason, &state.Signature)
+		return state, err
+	}); err != nil {
+		return err
+	}
+	return writer.writeRows(tx, archiveModerationLogFile, `
+		SELECT id, target_pubkey, action, source_admin, timestamp, lamport, reason, result, signature FROM moderation_logs ORDER BY id ASC;
+	`, func(rows *sql.Rows) (any, error) {
+		var log ModerationLog
+		err := rows.Scan(&log.ID, &log.TargetPubkey, &log.Action, &log.SourceAdmin, &log.Timestamp, &log.Lamport, &log.Reason, &log.Result, &log.Signature)
+		return log, err
+	})
+}
+
+type archiveWriter struct {
+	tw      *tar.Writer
+	modTime time.Time
+	records map[string]int
+}
+
+func (w *archiveWriter) writeFile(name string, data []byte) error {
+	if err := w.tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o600,
+		Size:     int64(len(data)),
+		ModTime:  w.modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := w.tw.Write(data)
+	return err
+}
+
+func (w *archiveWriter) writeJSON(name string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return w.writeFile(name, append(data, '\n'))
+}
+
+// writeRows writes one JSONL file with a record per row of query.
+func (w *archiveWriter) writeRows(tx *sql.Tx, name string, query string, scan func(rows *sql.Rows) (any, error)) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer rows.Close()
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	count := 0
+	for rows.Next() {
+		record, scanErr := scan(rows)
+		if scanErr != nil {
+			return fmt.Errorf("%s: %w", name, scanErr)
+		}
+		if err = encoder.Encode(record); err != nil {
+			return err
+		}
+		count++
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	w.records[name] = count
+	return w.writeFile(name, buffer.Bytes())
+}
+
+// writeBlobs writes one file per (cid, data) row of query under dir.
+func (w *archiveWriter) writeBlobs(tx *sql.Tx, dir string, query string) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var cid string
+		var data []byte
+		if err = rows.Scan(&cid, &data); err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		if !isArchiveBlobName(cid) {
+			continue
+		}
+		if err = w.writeFile(dir+cid, data); err != nil {
+			return err
+		}
+		count++
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	w.records[dir] = count
+	return nil
+}
+
+func isArchiveBlobName(cid string) bool {
+	if cid == "" || len(cid) > 128 {
+		return false
+	}
+	for _, r := range cid {
+		if !(r == '-' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z')) {
+			return false
+		}
+	}
+	return true
+}
+
+// ImportArchive applies an archive written by ExportArchive. It can be run
+// again after a failure; records that are already current are left alone.
+func (a *App) ImportArchive(path string) (ArchiveSummary, error) {
+	if a.db == nil {
+		return ArchiveSummary{}, errors.New("database not initialized")
+	}
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ArchiveSummary{}, errors.New("archive path is required")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ArchiveSummary{}, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return ArchiveSummary{}, fmt.Errorf("%w: %v", errArchiveInvalid, err)
+	}
+	defer gz.Close()
+
+	importer := &archiveImporter{
+		app:        a,
+		summary:    ArchiveSummary{Path: path, Records: make(map[string]int), Skipped: make(map[string]int)},
+		media:      make(map[string]archiveMedia),
+		signatures: make(map[string]string),
+		authors:    make(map[string]string),
+		posts:      make(map[string]struct{}),
+		comments:   make(map[string]struct{}),
+	}
+	reader := tar.NewReader(gz)
+	manifestSeen := false
+	for {
+		header, nextErr := reader.Next()
+		if errors.Is(nextErr, io.EOF) {
+			break
+		}
+		if nextErr != nil {
+			return importer.summary, fmt.Errorf("%w: %v", errArchiveInvalid, nextErr)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > archiveMaxEntryBytes {
+			return importer.summary, fmt.Errorf("%w: %s is too large", errArchiveInvalid, header.Name)
+		}
+		if !manifestSeen && header.Name != archiveManifestFile {
+			return importer.summary, fmt.Errorf("%w: %s must come first", errArchiveInvalid, archiveManifestFile)
+		}
+		if err = importer.apply(header.Name, reader); err != nil {
+			return importer.summary, err
+		}
+		manifestSeen = true
+	}
+	if !manifestSeen {
+		return ArchiveSummary{}, fmt.Errorf("%w: %s is missing", errArchiveInvalid, archiveManifestFile)
+	}
+
+	if err = importer.recountScores(); err != nil {
+		return importer.summary, err
+	}
+	if info, statErr := file.Stat(); statErr == nil {
+		importer.summary.SizeBytes = info.Size()
+	}
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "archive.imported path=%s posts=%d comments=%d skipped=%v", path, importer.summary.Records[archivePostsFile], importer.summary.Records[archiveCommentsFile], importer.summary.Skipped)
+		runtime.EventsEmit(a.ctx, "feed:updated")
+		runtime.EventsEmit(a.ctx, "subs:updated")
+	}
+	return importer.summary, nil
+}
+
+type archiveImporter struct {
+	app        *App
+	summary    ArchiveSummary
+	media      map[string]archiveMedia
+	signatures map[string]string
+	// authors maps entityType/entityID of every verified post and comment
+	// to its author.
+	authors  map[string]string
+	posts    map[string]struct{}
+	comments map[string]struct{}
+}
+
+func (im *archiveImporter) apply(name string, reader io.Reader) error {
+	a := im.app
+	switch {
+	case name == archiveManifestFile:
+		var manifest ArchiveManifest
+		if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+			return fmt.Errorf("%w: %v", errArchiveInvalid, err)
+		}
+		if manifest.Format != archiveFormatName {
+			return fmt.Errorf("%w: format %q", errArchiveInvalid, manifest.Format)
+		}
+		if manifest.Version < 1 || manifest.Version > archiveFormatVersion {
+			return fmt.Errorf("%w: %d", errArchiveUnsupported, manifest.Version)
+		}
+		im.summary.Manifest = manifest
+		return nil
+	case strings.HasPrefix(name, archiveContentBlobDir), strings.HasPrefix(name, archiveMediaBlobDir):
+		return im.applyBlob(name, reader)
+	}
+
+	switch name {
+	case archiveSubsFile:
+		return archiveEachRecord(reader, im, name, func(sub Sub) error {
+			_, err := a.upsertSub(sub.ID, sub.Title, sub.Description, sub.CreatedAt)
+			return err
+		})
+	case archiveSubscriptionsFile:
+		return archiveEachRecord(reader, im, name, func(subscription archiveSubscription) error {
+			_, err := a.db.Exec(`
+				INSERT INTO sub_subscriptions (pubkey, sub_id, subscribed_at)
+				VALUES (?, ?, ?)
+				ON CONFLICT(pubkey, sub_id) DO NOTHING;
+			`, strings.TrimSpace(subscription.Pubkey), normalizeSubID(subscription.SubID), subscription.SubscribedAt)
+			return err
+		})
+	case archiveProfilesFile:
+		return archiveEachRecord(reader, im, name, a.importArchiveProfile)
+	case archiveMediaFile:
+		return archiveEachRecord(reader, im, name, func(media archiveMedia) error {
+			im.media[media.CID] = media
+			return nil
+		})
+	case archivePostsFile:
+		return archiveEachRecord(reader, im, name, func(post archivePost) error {
+			if err := a.importArchivePost(post, im.signatures[strings.TrimSpace(post.OpID)]); err != nil {
+				return err
+			}
+			im.authors[entityTypePost+"/"+post.ID] = post.Pubkey
+			im.posts[post.ID] = struct{}{}
+			return nil
+		})
+	case archiveCommentsFile:
+		return archiveEachRecord(reader, im, name, func(comment archiveComment) error {
+			if err := a.importArchiveComment(comment, im.signatures[strings.TrimSpace(comment.OpID)]); err != nil {
+				return err
+			}
+			im.authors[entityTypeComment+"/"+comment.ID] = comment.Pubkey
+			im.comments[comment.ID] = struct{}{}
+			return nil
+		})
+	case archiveOpsFile:
+		return archiveEachRecord(reader, im, name, func(op EntityOpRecord) error {
+			if strings.TrimSpace(op.OpID) == "" || strings.TrimSpace(op.EntityID) == "" {
+				return errArchiveInvalid
+			}
+			author, verified := im.authors[op.EntityType+"/"+op.EntityID]
+			if !verified || author != op.AuthorPubkey {
+				return errMessageSignatureInvalid
+			}
+			_, err := a.db.Exec(`
+				INSERT INTO entity_ops (op_id, entity_type, entity_id, op_type, author_pubkey, lamport, timestamp, schema_version, auth_scope, payload_json)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT(op_id) DO NOTHING;
+			`, op.OpID, op.EntityType, op.EntityID, op.OpType, op.AuthorPubkey, op.Lamport, op.Timestamp, op.SchemaVersion, op.AuthScope, op.PayloadJSON)
+			return err
+		})
+	case archiveSignaturesFile:
+		return archiveEachRecord(reader, im, name, func(signature archiveSignature) error {
+			if strings.TrimSpace(signature.OpID) == "" || strings.TrimSpace(signature.Signature) == "" {
+				return errArchiveInvalid
+			}
+			// Kept until the post or comment they sign is verified.
+			im.signatures[strings.TrimSpace(signature.OpID)] = strings.TrimSpace(signature.Signature)
+			return nil
+		})
+	case archiveVotesFile:
+		return archiveEachRecord(reader, im, name, func(vote archiveVote) error {
+			if vote.Target == "comment" {
+				im.comments[vote.TargetID] = struct{}{}
+				return a.applyCommentVoteState(vote.VoterPubkey, vote.TargetID, vote.PostID, vote.State, "", vote.Timestamp)
+			}
+			im.posts[vote.TargetID] = struct{}{}
+			return a.applyPostVoteState(vote.VoterPubkey, vote.TargetID, vote.State, "", vote.Timestamp)
+		})
+	case archiveVoteOpsFile:
+		return archiveEachRecord(reader, im, name, func(op archiveVoteOp) error {
+			_, err := a.db.Exec(`INSERT INTO vote_ops (op_id, created_at) VALUES (?, ?) ON CONFLICT(op_id) DO NOTHING;`, op.OpID, op.CreatedAt)
+			return err
+		})
+	case archiveFavoritesFile:
+		return archiveEachRecord(reader, im, name, func(record FavoriteOpRecord) error {
+			_, err := a.applyFavoriteOperation(record, true)
+			return err
+		})
+	case archiveModerationFile:
+		return archiveEachRecord(reader, im, name, a.importArchiveModeration)
+	case archiveModerationLogFile:
+		return archiveEachRecord(reader, im, name, func(log ModerationLog) error {
+			if err := a.verifyModerationLogSignature(log); err != nil {
+				return err
+			}
+			_, err := a.insertModerationLogIfAbsent(log)
+			return err
+		})
+	}
+	// Files from newer minor revisions of the format are ignored.
+	return nil
+}
+
+// archiveEachRecord decodes every line of a JSONL file and applies it. A
+// record that is malformed or rejected is counted as skipped and the import
+// goes on; only a file that cannot be read stops it.
+func archiveEachRecord[T any](reader io.Reader, im *archiveImporter, name string, apply func(record T) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), archiveMaxLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		im.summary.Records[name]++
+		var record T
+		if err := json.Unmarshal(line, &record); err != nil {
+			im.summary.Skipped[name]++
+			continue
+		}
+		if err := apply(record); err != nil {
+			im.summary.Skipped[name]++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %s: %v", errArchiveInvalid, name, err)
+	}
+	return nil
+}
+
+func (im *archiveImporter) applyBlob(name string, reader io.Reader) error {
+	a := im.app
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errArchiveInvalid, name, err)
+	}
+
+	if cid, found := strings.CutPrefix(name, archiveContentBlobDir); found {
+		im.summary.Records[archiveContentBlobDir]++
+		if err = a.upsertContentBlob(cid, string(data), int64(len(data))); err != nil {
+			im.summary.Skipped[archiveContentBlobDir]++
+		}
+		return nil
+	}
+
+	cid := strings.TrimPrefix(name, archiveMediaBlobDir)
+	im.summary.Records[archiveMediaBlobDir]++
+	media := im.media[cid]
+	if err = a.upsertMediaBlobRaw(cid, media.Mime, data, media.Width, media.Height, media.IsThumbnail); err != nil {
+		im.summary.Skipped[archiveMediaBlobDir]++
+	}
+	return nil
+}
+
+func (a *App) importArchiveProfile(profile ProfileDetails) error {
+	pubkey := strings.TrimSpace(profile.Pubkey)
+	if pubkey == "" {
+		return errArchiveInvalid
+	}
+	var updatedAt int64
+	err := a.db.QueryRow(`SELECT updated_at FROM profiles WHERE pubkey = ?;`, pubkey).Scan(&updatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil && updatedAt >= profile.UpdatedAt {
+		return nil
+	}
+	if _, err = a.upsertProfile(pubkey, profile.DisplayName, profile.AvatarURL, profile.UpdatedAt); err != nil {
+		return err
+	}
+	_, err = a.db.Exec(`
+		INSERT INTO profile_details (pubkey, bio, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(pubkey) DO UPDATE SET
+			bio = excluded.bio,
+			updated_at = excluded.updated_at;
+	`, pubkey, strings.TrimSpace(profile.Bio), profile.UpdatedAt)
+	return err
+}
+
+// importArchivePost verifies a post against the signature of its current op
+// and restores it with its full body when the body blob is stored, and as an
+// index entry or tombstone otherwise, like a synced digest.
+func (a *App) importArchivePost(post archivePost, signature string) error {
+	deleted := strings.EqualFold(strings.TrimSpace(post.Visibility), "deleted")
+	digest := SyncPostDigest{
+		ID:               post.ID,
+		Pubkey:           post.Pubkey,
+		OpID:             post.OpID,
+		Deleted:          deleted,
+		DeletedAtLamport: post.DeletedAtLamport,
+		Title:            post.Title,
+		ContentCID:       post.ContentCID,
+		ImageCID:         post.ImageCID,
+		ThumbCID:         post.ThumbCID,
+		ImageMIME:        post.ImageMIME,
+		ImageSize:        post.ImageSize,
+		ImageWidth:       post.ImageWidth,
+		ImageHeight:      post.ImageHeight,
+		Timestamp:        post.Timestamp,
+		Lamport:          post.Lamport,
+		SubID:            post.SubID,
+		Signature:        signature,
+	}
+	if deleted {
+		digest.OpType = postOpTypeDelete
+	}
+	if err := a.verifyPostDigestSignature(digest); err != nil {
+		return err
+	}
+	if err := a.importArchivePostBody(post, digest); err != nil {
+		return err
+	}
+	return a.recordIncomingMessageSignature(postDigestSigningMessage(digest))
+}
+
+func (a *App) importArchivePostBody(post archivePost, digest SyncPostDigest) error {
+	if digest.Deleted {
+		if post.Zone != "public" {
+			return nil
+		}
+		_, err := a.upsertPublicPostIndexFromDigest(digest)
+		return err
+	}
+
+	var body string
+	err := a.db.QueryRow(`SELECT body FROM content_blobs WHERE content_cid = ?;`, strings.TrimSpace(post.ContentCID)).Scan(&body)
+	if errors.Is(err, sql.ErrNoRows) {
+		if post.Zone != "public" {
+			return errors.New("private post body missing")
+		}
+		_, err = a.upsertPublicPostIndexFromDigest(digest)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	message := post.ForumMessage
+	message.Body = body
+	message.Score = 0
+	message.SizeBytes = 0
+	_, err = a.insertMessage(message)
+	return err
+}
+
+// importArchiveComment verifies a comment or its tombstone against the
+// signature of its current op before restoring it.
+func (a *App) importArchiveComment(comment archiveComment, signature string) error {
+	digest := SyncCommentDigest{
+		ID:               comment.ID,
+		PostID:           comment.PostID,
+		ParentID:         comment.ParentID,
+		Pubkey:           comment.Pubkey,
+		OpID:             comment.OpID,
+		Deleted:          comment.DeletedAtLamport > 0,
+		DeletedAtLamport: comment.DeletedAtLamport,
+		Body:             comment.Body,
+		Attachments:      comment.Attachments,
+		Timestamp:        comment.Timestamp,
+		Lamport:          comment.Lamport,
+		Signature:        signature,
+	}
+	if err := a.verifyCommentDigestSignature(digest); err != nil {
+		return err
+	}
+
+	var err error
+	if digest.Deleted {
+		err = a.upsertCommentTombstone(comment.ID, comment.PostID, comment.Pubkey, comment.DeletedAt, comment.DeletedAtLamport, comment.OpID)
+	} else {
+		comment.Score = 0
+		_, err = a.insertComment(comment.Comment)
+	}
+	if err != nil {
+		return err
+	}
+	return a.recordIncomingMessageSignature(commentDigestSigningMessage(digest))
+}
+
+// importArchiveModeration applies a moderation state signed by an admin
+// trusted here, unless the same state is already in place, so that importing
+// twice does not log the action again.
+func (a *App) importArchiveModeration(state ModerationState) error {
+	trusted, err := a.isTrustedAdmin(state.SourceAdmin)
+	if err != nil {
+		return err
+	}
+	if !trusted {
+		return errors.New("admin pubkey is not trusted")
+	}
+	if err = a.verifyModerationStateSignature(state); err != nil {
+		return err
+	}
+	var current int
+	err = a.db.QueryRow(`
+		SELECT COUNT(1) FROM moderation
+		WHERE target_pubkey = ? AND action = ? AND source_admin = ? AND timestamp = ? AND lamport = ?;
+	`, state.TargetPubkey, strings.ToUpper(strings.TrimSpace(state.Action)), state.SourceAdmin, state.Timestamp, state.Lamport).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if current > 0 {
+		return nil
+	}
+	return a.upsertModeration(state.TargetPubkey, state.Action, state.SourceAdmin, state.Timestamp, state.Lamport, state.Reason, state.Signature)
+}
+
+// recountScores sets the score of every imported post and comment from the
+// stored votes, which the vote paths only adjust by delta.
+func (im *archiveImporter) recountScores() error {
+	a := im.app
+	for id := range im.posts {
+		if _, err := a.db.Exec(`
+			UPDATE messages
+			SET score = (SELECT COUNT(1) FROM post_votes WHERE post_id = ?) - (SELECT COUNT(1) FROM post_downvotes WHERE post_id = ?)
+			WHERE id = ?;
+		`, id, id, id); err != nil {
+			return err
+		}
+	}
+	for id := range im.comments {
+		if _, err := a.db.Exec(`
+			UPDATE comments
+			SET score = (SELECT COUNT(1) FROM comment_votes WHERE comment_id = ?) - (SELECT COUNT(1) FROM comment_downvotes WHERE comment_id = ?)
+			WHERE id = ?;
+		`, id, id, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
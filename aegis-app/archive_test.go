@@ -0,0 +1,259 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveRoundTripIsIdempotent(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	source := newTestApp(t)
+	identity, err := source.CreateIdentity("", "correct horse battery")
+	if err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+	if _, err := source.upsertSub("tech", "Tech", "Computers", 5); err != nil {
+		t.Fatalf("create sub: %v", err)
+	}
+	if _, err := source.upsertProfile("alice", "Alice", "", 7); err != nil {
+		t.Fatalf("create profile: %v", err)
+	}
+	image := []byte{0x89, 'P', 'N', 'G', 9, 9, 9}
+	if err := source.upsertMediaBlobRaw(buildBinaryCID(image), "image/png", image, 2, 2, false); err != nil {
+		t.Fatalf("store media: %v", err)
+	}
+	for _, post := range []ForumMessage{
+		{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Hello", Body: "First body", ImageCID: buildBinaryCID(image), ImageMIME: "image/png", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "tech"},
+		{ID: "p-1", Pubkey: "alice", OpID: "op-1b", Title: "Hello again", Body: "Edited body", ImageCID: buildBinaryCID(image), ImageMIME: "image/png", Timestamp: 11, Lamport: 11, Zone: "public", SubID: "tech"},
+		{ID: "p-2", Pubkey: "bob", OpID: "op-2", Title: "Gone", Body: "Soon deleted", Timestamp: 20, Lamport: 20, Zone: "public", SubID: defaultSubID},
+		{ID: "p-3", Pubkey: "alice", OpID: "op-3", Title: "Diary", Body: "Private notes", Timestamp: 30, Lamport: 30, Zone: "private", SubID: defaultSubID},
+	} {
+		if _, err := source.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+	if err := source.deleteLocalPostAsAuthor("bob", "p-2", 25, 25, "op-2-delete"); err != nil {
+		t.Fatalf("delete post: %v", err)
+	}
+	for _, comment := range []Comment{
+		{ID: "c-1", PostID: "p-1", Pubkey: "bob", OpID: "op-c1", Body: "Nice", Timestamp: 40, Lamport: 40},
+		{ID: "c-2", PostID: "p-1", ParentID: "c-1", Pubkey: "carol", OpID: "op-c2", Body: "Agreed", Timestamp: 41, Lamport: 41},
+	} {
+		if _, err := source.insertComment(comment); err != nil {
+			t.Fatalf("insert comment %s: %v", comment.ID, err)
+		}
+	}
+	now := time.Now().Unix()
+	for _, apply := range []func() error{
+		func() error { return source.applyPostVoteState("bob", "p-1", voteStateUp, "v-1", now) },
+		func() error { return source.applyPostVoteState("carol", "p-1", voteStateUp, "v-2", now) },
+		func() error { return source.applyCommentVoteState("alice", "c-1", "p-1", voteStateDown, "v-3", now) },
+		func() error {
+			record := FavoriteOpRecord{OpID: "f-1", Pubkey: identity.PublicKey, PostID: "p-1", Op: "ADD", CreatedAt: 50}
+			signature, err := source.signWithLocalIdentity(record.Pubkey, buildFavoriteSignaturePayload(record.Pubkey, record.PostID, record.Op, record.CreatedAt, record.OpID))
+			if err != nil {
+				return err
+			}
+			record.Signature = signature
+			_, err = source.applyFavoriteOperation(record, true)
+			return err
+		},
+	} {
+		if err := apply(); err != nil {
+			t.Fatalf("seed votes and favorites: %v", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "node.aegis.tar.gz")
+	exported, err := source.ExportArchive(path, ArchiveOptions{ExcludePrivate: true})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if exported.Records[archivePostsFile] != 2 || exported.Records[archiveCommentsFile] != 2 || exported.Records[archiveMediaBlobDir] != 1 {
+		t.Fatalf("unexpected export counts %+v", exported.Records)
+	}
+
+	target := newTestApp(t)
+	tables := []string{"messages", "comments", "entity_ops", "post_votes", "comment_downvotes", "post_favorite_ops", "media_blobs", "content_blobs", "subs", "profiles"}
+	snapshot := func() map[string]int {
+		counts := make(map[string]int, len(tables))
+		for _, table := range tables {
+			counts[table] = countRows(t, target, `SELECT COUNT(1) FROM `+table+`;`)
+		}
+		return counts
+	}
+
+	first, err := target.ImportArchive(path)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(first.Skipped) != 0 {
+		t.Fatalf("unexpected skipped records %+v", first.Skipped)
+	}
+	post, err := target.GetPostBodyByID("p-1")
+	if err != nil || post.Body != "Edited body" {
+		t.Fatalf("unexpected imported body %+v, err %v", post, err)
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM messages WHERE id = 'p-2' AND visibility = 'deleted';`); count != 1 {
+		t.Fatalf("expected the deleted post to come back as a tombstone")
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM messages WHERE id = 'p-3';`); count != 0 {
+		t.Fatalf("private post was exported despite ExcludePrivate")
+	}
+	if score := countRows(t, target, `SELECT score FROM messages WHERE id = 'p-1';`); score != 2 {
+		t.Fatalf("expected post score 2, got %d", score)
+	}
+	if score := countRows(t, target, `SELECT score FROM comments WHERE id = 'c-1';`); score != -1 {
+		t.Fatalf("expected comment score -1, got %d", score)
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM post_favorites_state WHERE pubkey = ? AND post_id = 'p-1';`, identity.PublicKey); count != 1 {
+		t.Fatalf("favorite was not restored")
+	}
+
+	before := snapshot()
+	if _, err = target.ImportArchive(path); err != nil {
+		t.Fatalf("second import: %v", err)
+	}
+	after := snapshot()
+	for _, table := range tables {
+		if before[table] != after[table] {
+			t.Fatalf("second import changed %s from %d to %d rows", table, before[table], after[table])
+		}
+	}
+	if score := countRows(t, target, `SELECT score FROM messages WHERE id = 'p-1';`); score != 2 {
+		t.Fatalf("second import changed the post score to %d", score)
+	}
+}
+
+// rewriteArchive passes every entry of the archive at path through edit and
+// writes the result back in place.
+func rewriteArchive(t *testing.T, path string, edit func(name string, data []byte) []byte) {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	var out bytes.Buffer
+	gzOut := gzip.NewWriter(&out)
+	writer := tar.NewWriter(gzOut)
+	reader := tar.NewReader(gz)
+	for {
+		header, nextErr := reader.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			t.Fatalf("read entry: %v", nextErr)
+		}
+		data, readErr := io.ReadAll(reader)
+		if readErr != nil {
+			t.Fatalf("read entry: %v", readErr)
+		}
+		data = edit(header.Name, data)
+		header.Size = int64(len(data))
+		if err = writer.WriteHeader(header); err != nil {
+			t.Fatalf("write entry: %v", err)
+		}
+		if _, err = writer.Write(data); err != nil {
+			t.Fatalf("write entry: %v", err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+	if err = gzOut.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+	if err = os.WriteFile(path, out.Bytes(), 0o600); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+}
+
+func TestArchiveImportSkipsForgedRecords(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "0")
+	source := newTestApp(t)
+	identity, err := source.CreateIdentity("", "correct horse battery")
+	if err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+	for _, message := range []IncomingMessage{
+		{Type: "POST", OpID: "op-1", ID: "p-1", Pubkey: identity.PublicKey, Title: "First", Body: "first body", SubID: defaultSubID, Timestamp: 10, Lamport: 10},
+		{Type: "POST", OpID: "op-2", ID: "p-2", Pubkey: identity.PublicKey, Title: "Second", Body: "second body", SubID: defaultSubID, Timestamp: 20, Lamport: 20},
+		{Type: "COMMENT", OpID: "op-c1", ID: "c-1", PostID: "p-1", Pubkey: identity.PublicKey, Body: "reply", Timestamp: 30, Lamport: 30},
+	} {
+		if err = source.signIncomingMessage(&message); err != nil {
+			t.Fatalf("sign %s: %v", message.ID, err)
+		}
+		payload, _ := json.Marshal(message)
+		if err = source.ProcessIncomingMessage(payload); err != nil {
+			t.Fatalf("apply %s: %v", message.ID, err)
+		}
+	}
+	banSignature := source.signLocalModeration("SHADOW_BAN", "spammer", identity.PublicKey, 40, 40, "spam")
+	if err = source.upsertModeration("spammer", "SHADOW_BAN", identity.PublicKey, 40, 40, "spam", banSignature); err != nil {
+		t.Fatalf("moderate: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "node.aegis.tar.gz")
+	if _, err = source.ExportArchive(path, ArchiveOptions{}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	appendLine := func(data []byte, record any) []byte {
+		line, _ := json.Marshal(record)
+		return append(append(data, line...), '\n')
+	}
+	rewriteArchive(t, path, func(name string, data []byte) []byte {
+		switch name {
+		case archivePostsFile:
+			return bytes.Replace(data, []byte(`"Second"`), []byte(`"Forged"`), 1)
+		case archiveOpsFile:
+			return appendLine(data, EntityOpRecord{OpID: "op-forged", EntityType: entityTypePost, EntityID: "p-1", OpType: postOpTypeCreate, AuthorPubkey: "mallory", Lamport: 50, Timestamp: 50})
+		case archiveModerationFile:
+			return appendLine(data, ModerationState{TargetPubkey: "friend", Action: "SHADOW_BAN", SourceAdmin: identity.PublicKey, Timestamp: 60, Lamport: 60, Signature: banSignature})
+		case archiveModerationLogFile:
+			return appendLine(data, ModerationLog{TargetPubkey: "friend", Action: "SHADOW_BAN", SourceAdmin: identity.PublicKey, Timestamp: 60, Lamport: 60, Result: "applied"})
+		}
+		return data
+	})
+
+	target := newTestApp(t)
+	if err = target.AddTrustedAdmin(identity.PublicKey, adminRoleGenesis); err != nil {
+		t.Fatalf("trust admin: %v", err)
+	}
+	summary, err := target.ImportArchive(path)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.Skipped[archivePostsFile] != 1 || summary.Skipped[archiveCommentsFile] != 0 || summary.Skipped[archiveModerationFile] != 1 || summary.Skipped[archiveModerationLogFile] != 1 {
+		t.Fatalf("unexpected skipped records %+v", summary.Skipped)
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM messages WHERE id = 'p-2';`); count != 0 {
+		t.Fatalf("forged post was imported")
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM comments WHERE id = 'c-1';`); count != 1 {
+		t.Fatalf("signed comment was not imported")
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM entity_ops WHERE op_id IN ('op-2', 'op-forged');`); count != 0 {
+		t.Fatalf("ops of rejected records were imported")
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM entity_signatures WHERE op_id IN ('op-1', 'op-c1');`); count != 2 {
+		t.Fatalf("expected the verified signatures to be kept, got %d", count)
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM moderation WHERE target_pubkey = 'spammer';`); count != 1 {
+		t.Fatalf("signed moderation was not imported")
+	}
+	if count := countRows(t, target, `SELECT COUNT(1) FROM moderation WHERE target_pubkey = 'friend';`); count != 0 {
+		t.Fatalf("forged moderation was imported")
+	}
+}
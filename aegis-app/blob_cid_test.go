@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// fakeBlobPeers serves each peer's copy of a blob from data, from the
+// partial's offset on, as readBlobFromPeer would.
+func fakeBlobPeers(kind string, data map[peer.ID][]byte) blobPeerReader {
+	return func(ctx context.Context, remote peer.ID, _ string, cid string, partial *blobPartial) (bool, error) {
+		body, found := data[remote]
+		if !found {
+			return false, nil
+		}
+		partial.header = blobResponseHeader{Found: true, Kind: kind, CID: cid, Size: int64(len(body))}
+		start := int64(len(partial.data))
+		partial.data = append(partial.data, body[start:]...)
+		partial.spans = append(partial.spans, blobSpan{Peer: remote.String(), Start: start, End: int64(len(body))})
+		return true, nil
+	}
+}
+
+func TestUpsertContentBlobRejectsCIDMismatch(t *testing.T) {
+	app := newTestApp(t)
+
+	cid := buildContentCID("original body")
+	err := app.upsertContentBlob(cid, "poisoned body", 0)
+	if !errors.Is(err, errBlobCIDMismatch) {
+		t.Fatalf("expected cid mismatch, got %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE content_cid = ?;`, cid); count != 0 {
+		t.Fatalf("poisoned body stored, rows=%d", count)
+	}
+
+	if err = app.upsertContentBlob(cid, "  original body\n", 0); err != nil {
+		t.Fatalf("matching body rejected: %v", err)
+	}
+	body, err := app.getContentBlobLocal(cid)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if body.Body != "original body" {
+		t.Fatalf("unexpected body %q", body.Body)
+	}
+}
+
+func TestUpsertMediaBlobRejectsCIDMismatch(t *testing.T) {
+	app := newTestApp(t)
+
+	original := []byte{0x89, 'P', 'N', 'G', 1, 2, 3}
+	cid := buildBinaryCID(original)
+	err := app.upsertMediaBlobRaw(cid, "image/png", []byte{0x89, 'P', 'N', 'G', 9, 9, 9}, 1, 1, false)
+	if !errors.Is(err, errBlobCIDMismatch) {
+		t.Fatalf("expected cid mismatch, got %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM media_blobs WHERE content_cid = ?;`, cid); count != 0 {
+		t.Fatalf("poisoned media stored, rows=%d", count)
+	}
+
+	if err = app.upsertMediaBlobRaw(cid, "image/png", original, 1, 1, false); err != nil {
+		t.Fatalf("matching media rejected: %v", err)
+	}
+	_, raw, err := app.getMediaBlobRawLocal(cid)
+	if err != nil {
+		t.Fatalf("read media: %v", err)
+	}
+	if string(raw) != string(original) {
+		t.Fatalf("unexpected media bytes %v", raw)
+	}
+}
+
+func TestInsertMessageRejectsMismatchedContentCID(t *testing.T) {
+	app := newTestApp(t)
+
+	_, err := app.insertMessage(ForumMessage{
+		ID:         "post-poisoned",
+		Pubkey:     "alice",
+		OpID:       "op-create-1",
+		Title:      "hello",
+		Body:       "poisoned body",
+		ContentCID: buildContentCID("original body"),
+		Timestamp:  1,
+		Lamport:    1,
+		Zone:       "public",
+		SubID:      defaultSubID,
+	})
+	if !errors.Is(err, errBlobCIDMismatch) {
+		t.Fatalf("expected cid mismatch, got %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM messages WHERE id = ?;`, "post-poisoned"); count != 0 {
+		t.Fatalf("poisoned post stored, rows=%d", count)
+	}
+}
+
+func TestBlobCIDMismatchGreylistsOnlyWrongChunks(t *testing.T) {
+	app := newTestApp(t)
+	body := []byte("first half|second half")
+	cid := buildContentCID(string(body))
+	honest, liar, good := peer.ID("peer-honest"), peer.ID("peer-liar"), peer.ID("peer-good")
+
+	// A resumed transfer holds a correct prefix from one peer; another peer
+	// completes it with a forged suffix, and a third has the real blob.
+	resume := func() {
+		app.keepBlobPartial(blobKindContent, cid, &blobPartial{
+			header: blobResponseHeader{Found: true, Kind: blobKindContent, CID: cid, Size: int64(len(body))},
+			data:   append([]byte(nil), body[:11]...),
+			spans:  []blobSpan{{Peer: honest.String(), Start: 0, End: 11}},
+		})
+	}
+	resume()
+	read := fakeBlobPeers(blobKindContent, map[peer.ID][]byte{liar: []byte("first half|forged half"), good: body})
+	_, data, err := app.fetchBlobFromCandidates(context.Background(), blobKindContent, cid, []peer.ID{liar, good}, read)
+	if err != nil || string(data) != string(body) {
+		t.Fatalf("expected the verified body, got %q, err %v", data, err)
+	}
+	if blocked, _ := app.isPeerBlocked(liar.String()); !blocked {
+		t.Fatalf("the peer that sent the forged chunk should be greylisted")
+	}
+	for _, innocent := range []peer.ID{honest, good} {
+		if blocked, _ := app.isPeerBlocked(innocent.String()); blocked {
+			t.Fatalf("%s sent correct bytes and should not be greylisted", innocent)
+		}
+	}
+
+	// Without a verified copy the sources share the blame and are
+	// greylisted on a repeat.
+	other := newTestApp(t)
+	shared := map[string]struct{}{"peer-a": {}, "peer-b": {}}
+	other.noteBlobCIDMismatch(shared)
+	if blocked, _ := other.isPeerBlocked("peer-a"); blocked {
+		t.Fatalf("shared source should not be greylisted on first strike")
+	}
+	other.noteBlobCIDMismatch(shared)
+	for peerID := range shared {
+		if blocked, _ := other.isPeerBlocked(peerID); !blocked {
+			t.Fatalf("shared source %s should be greylisted on repeat", peerID)
+		}
+	}
+	other.noteBlobCIDMismatch(map[string]struct{}{"peer-sole": {}})
+	if blocked, _ := other.isPeerBlocked("peer-sole"); !blocked {
+		t.Fatalf("sole source of a mismatched blob should be greylisted")
+	}
+}
+
+func TestVerifyBlobCIDUsesKindHash(t *testing.T) {
+	data := []byte("same bytes")
+	if err := verifyBlobCID(blobKindContent, buildContentCID(string(data)), data); err != nil {
+		t.Fatalf("content cid rejected: %v", err)
+	}
+	if err := verifyBlobCID(blobKindMedia, buildBinaryCID(data), data); err != nil {
+		t.Fatalf("media cid rejected: %v", err)
+	}
+	if err := verifyBlobCID(blobKindMedia, buildContentCID(string(data)), data); !errors.Is(err, errBlobCIDMismatch) {
+		t.Fatalf("content cid accepted for media, got %v", err)
+	}
+}
+
+func TestFetchBlobSkipsMismatchedPeer(t *testing.T) {
+	app := newTestApp(t)
+	body := []byte("the real body")
+	cid := buildContentCID(string(body))
+	bad, good := peer.ID("peer-bad"), peer.ID("peer-good")
+	read := fakeBlobPeers(blobKindContent, map[peer.ID][]byte{bad: []byte("a forged body"), good: body})
+
+	_, data, err := app.fetchBlobFromCandidates(context.Background(), blobKindContent, cid, []peer.ID{bad, good}, read)
+	if err != nil || string(data) != string(body) {
+		t.Fatalf("expected the good peer's body, got %q, err %v", data, err)
+	}
+	if blocked, _ := app.isPeerBlocked(bad.String()); !blocked {
+		t.Fatalf("the bad peer should be greylisted")
+	}
+	if blocked, _ := app.isPeerBlocked(good.String()); blocked {
+		t.Fatalf("the good peer should not be greylisted")
+	}
+
+	other := []byte("another body")
+	if _, _, err = app.fetchBlobFromCandidates(context.Background(), blobKindContent, buildContentCID(string(other)), []peer.ID{good}, fakeBlobPeers(blobKindContent, map[peer.ID][]byte{good: []byte("not that body")})); !errors.Is(err, errBlobCIDMismatch) {
+		t.Fatalf("expected a mismatch when no peer has a good copy, got %v", err)
+	}
+}
@@ -0,0 +1,948 @@
+//go:build relay
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The relay build doubles as a headless CLI. Without arguments, or with
+// "run", it starts the node and serves until SIGINT as before. Every other
+// subcommand opens the same database, does one thing and prints its result as
+// JSON on stdout; errors go to stderr as {"error": "..."} with exit code 1
+// (2 for usage errors). Subcommands that talk to the network start P2P for
+// the duration of the command, so they can run next to a live node.
+const (
+	cliExitOK    = 0
+	cliExitError = 1
+	cliExitUsage = 2
+
+	cliDefaultWait      = 5 * time.Second
+	cliDefaultLinger    = 5 * time.Second
+	cliDefaultFetchWait = 15 * time.Second
+)
+
+var errCLIUsage = errors.New("usage error")
+
+type cliCommand struct {
+	name    string
+	summary string
+	run     func(session *cliSession, args []string) (interface{}, error)
+}
+
+// cliSession is the state shared by one CLI invocation.
+type cliSession struct {
+	app     *App
+	network bool
+}
+
+type cliStatus struct {
+	DatabasePath string            `json:"databasePath"`
+	Identity     *LocalIdentity    `json:"identity,omitempty"`
+	FollowedSubs []string          `json:"followedSubs"`
+	Storage      StorageUsage      `json:"storage"`
+	AntiEntropy  AntiEntropyStats  `json:"antiEntropy"`
+	P2PConfig    P2PConfig         `json:"p2pConfig"`
+	P2P          *P2PStatus        `json:"p2p,omitempty"`
+	Moderation   []ModerationState `json:"moderation"`
+}
+
+type cliSyncResult struct {
+	Peers       []string         `json:"peers"`
+	AntiEntropy AntiEntropyStats `json:"antiEntropy"`
+}
+
+func relayCLICommands() []cliCommand {
+	return []cliCommand{
+		{name: "run", summary: "start the node and serve until interrupted (default)"},
+		{name: "status", summary: "show identity, storage, sync and optionally [--network] P2P status", run: runCLIStatus},
+		{name: "post", summary: "publish a post: --title T (--body B | --body-file F) [--sub S]", run: runCLIPost},
+		{name: "comment", summary: "publish a comment: --post ID [--parent ID] (--body B | --body-file F)", run: runCLIComment},
+		{name: "feed", summary: "list post index: [--sub S] [--sort hot|new] [--limit N]", run: runCLIFeed},
+		{name: "comments", summary: "read a comment thread page: --post ID [--parent ID] [--sort new|old|top|controversial] [--limit N] [--depth N] [--replies N] [--cursor C]", run: runCLIComments},
+		{name: "search", summary: "full-text search of posts and comments: [--sub S] [--kind post|comment] [--author PUBKEY] [--since D] [--limit N] [--cursor C] [--network [--wait D]] QUERY...", run: runCLISearch},
+		{name: "subs", summary: "subs list | subscribed | subscribe ID | unsubscribe ID | create ID [--title T] [--description D]", run: runCLISubs},
+		{name: "identity", summary: "identity list | create [--label L] | import [--label L] | export | switch PUBKEY", run: runCLIIdentity},
+		{name: "peers", summary: "connect to the network and list peers: [--wait D]", run: runCLIPeers},
+		{name: "sync", summary: "sync now [--wait D] [--linger D] [--fetch-wait D]: reconcile with a peer right away", run: runCLISync},
+		{name: "gc", summary: "collect tombstones: [--retention-days N] [--stable-passes N] [--batch N]", run: runCLIGC},
+		{name: "maintenance", summary: "maintenance run | runs [--limit N]: gc, op-log compaction, vacuum and analyze now, or past runs", run: runCLIMaintenance},
+		{name: "history", summary: "history metric NAME [--since D] [--step D] | alerts [--since D] [--limit N]", run: runCLIHistory},
+		{name: "quota", summary: "quota show | set [--total SIZE] [--private SIZE] [--public SIZE] [--media SIZE] [--pinned SIZE] | evictions [--limit N]: sizes like 512MiB or 2GB", run: runCLIQuota},
+		{name: "pin", summary: "pin list | post ID | unpin ID | sub ID [--off]: keep posts and their media for offline reading", run: runCLIPin},
+		{name: "alert-rules", summary: "alert-rules show | check FILE: print the rules in effect or validate a rules file", run: runCLIAlertRules},
+		{name: "rec-strategies", summary: "rec-strategies show | check FILE: list the feed recommendation strategies or validate a strategy file", run: runCLIRecStrategies},
+		{name: "revisions", summary: "revisions post ID | comment ID: list the versions of a post or comment with diffs", run: runCLIRevisions},
+		{name: "archive", summary: "archive export FILE [--no-media] [--public-only] | import FILE: back up or restore the node's content", run: runCLIArchive},
+		{name: "moderation", summary: "moderation list | logs [--limit N] | ban PUBKEY [--reason R] | unban PUBKEY [--reason R]", run: runCLIModeration},
+	}
+}
+
+func runRelayCLI(args []string) int {
+	if len(args) == 0 || args[0] == "run" {
+		return runRelayNode()
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printRelayCLIUsage(os.Stdout)
+		return cliExitOK
+	}
+
+	var command *cliCommand
+	for _, candidate := range relayCLICommands() {
+		if candidate.name == args[0] && candidate.run != nil {
+			command = &candidate
+			break
+		}
+	}
+	if command == nil {
+		writeCLIError(fmt.Errorf("unknown command %q", args[0]))
+		printRelayCLIUsage(os.Stderr)
+		return cliExitUsage
+	}
+
+	app := NewApp()
+	if err := app.initDatabase(); err != nil {
+		writeCLIError(fmt.Errorf("init database: %w", err))
+		return cliExitError
+	}
+	app.unlockIdentityFromEnv()
+
+	session := &cliSession{app: app}
+	result, err := command.run(session, args[1:])
+	session.close()
+	if err != nil {
+		writeCLIError(err)
+		if errors.Is(err, errCLIUsage) || errors.Is(err, flag.ErrHelp) {
+			return cliExitUsage
+		}
+		return cliExitError
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(result); err != nil {
+		writeCLIError(err)
+		return cliExitError
+	}
+	return cliExitOK
+}
+
+func printRelayCLIUsage(out io.Writer) {
+	fmt.Fprintln(out, "usage: aegis-relay [command] [flags] [args]")
+	fmt.Fprintln(out, "commands:")
+	for _, command := range relayCLICommands() {
+		fmt.Fprintf(out, "  %-11s %s\n", command.name, command.summary)
+	}
+	fmt.Fprintln(out, "configuration comes from the AEGIS_* environment, e.g. AEGIS_DB_PATH and AEGIS_IDENTITY_PASSPHRASE")
+}
+
+func writeCLIError(err error) {
+	encoded, _ := json.Marshal(map[string]string{"error": err.Error()})
+	fmt.Fprintln(os.Stderr, string(encoded))
+}
+
+func cliUsageError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errCLIUsage, fmt.Sprintf(format, args...))
+}
+
+func newCLIFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+func parseCLIFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return cliUsageError("%s: %v", flags.Name(), err)
+	}
+	return nil
+}
+
+// startNetwork starts P2P and waits up to wait for a first connected peer.
+func (s *cliSession) startNetwork(wait time.Duration) (P2PStatus, error) {
+	status, err := s.app.StartP2P(resolveAutoStartP2PPort(), resolveBootstrapPeers())
+	if err != nil {
+		return P2PStatus{}, fmt.Errorf("start p2p: %w", err)
+	}
+	s.network = true
+
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		status = s.app.GetP2PStatus()
+		if len(status.ConnectedPeers) > 0 {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return s.app.GetP2PStatus(), nil
+}
+
+// linger keeps P2P up after a publish so gossip and peers' reconciliation
+// can pick the change up before the process exits.
+func (s *cliSession) linger(duration time.Duration) {
+	if s.network && duration > 0 {
+		time.Sleep(duration)
+	}
+}
+
+// waitForBlobFetches waits up to timeout for the body and media fetches that
+// sync responses started in the background, so closing P2P does not cut them
+// off and leave posts indexed without their bodies.
+func (s *cliSession) waitForBlobFetches(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		stats := s.app.GetAntiEntropyStats()
+		if stats.BlobFetchSuccess+stats.BlobFetchFailures >= stats.BlobFetchAttempts || !time.Now().Before(deadline) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (s *cliSession) close() {
+	if s.network {
+		_ = s.app.StopP2P()
+	}
+	if s.app.db != nil {
+		_ = s.app.db.Close()
+	}
+}
+
+func (s *cliSession) activeIdentity() (Identity, error) {
+	identity, err := s.app.getLocalIdentity()
+	if err != nil {
+		return Identity{}, fmt.Errorf("active identity unavailable (set AEGIS_IDENTITY_PASSPHRASE): %w", err)
+	}
+	return identity, nil
+}
+
+// readCLIPassphrase reads the passphrase from a file when given, otherwise
+// from AEGIS_IDENTITY_PASSPHRASE. It is never accepted as a flag value.
+func readCLIPassphrase(path string) (string, error) {
+	if strings.TrimSpace(path) != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(raw), "\r\n"), nil
+	}
+	if passphrase := resolveIdentityPassphraseFromEnv(); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", cliUsageError("passphrase required: set AEGIS_IDENTITY_PASSPHRASE or --passphrase-file")
+}
+
+// readCLIText returns value, or the contents of path when value is empty;
+// a path of "-" reads stdin.
+func readCLIText(value string, path string) (string, error) {
+	if value != "" || path == "" {
+		return value, nil
+	}
+	if path == "-" {
+		raw, err := io.ReadAll(os.Stdin)
+		return string(raw), err
+	}
+	raw, err := os.ReadFile(path)
+	return string(raw), err
+}
+
+func runCLIStatus(session *cliSession, args []string) (interface{}, error) {
+	flags := newCLIFlagSet("status")
+	withNetwork := flags.Bool("network", false, "start P2P and include its status")
+	wait := flags.Duration("wait", cliDefaultWait, "how long to wait for peers with --network")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+
+	app := session.app
+	result := cliStatus{DatabasePath: app.dbPath}
+	identities, err := app.ListIdentities()
+	if err != nil {
+		return nil, err
+	}
+	for index := range identities {
+		if identities[index].Active {
+			result.Identity = &identities[index]
+		}
+	}
+	if result.FollowedSubs, err = app.listFollowedSubIDs(); err != nil {
+		return nil, err
+	}
+	if result.Storage, err = app.GetStorageUsage(); err != nil {
+		return nil, err
+	}
+	if result.P2PConfig, err = app.GetP2PConfig(); err != nil {
+		return nil, err
+	}
+	if result.Moderation, err = app.GetModerationState(); err != nil {
+		return nil, err
+	}
+	if *withNetwork {
+		status, startErr := session.startNetwork(*wait)
+		if startErr != nil {
+			return nil, startErr
+		}
+		result.P2P = &status
+	}
+	result.AntiEntropy = app.GetAntiEntropyStats()
+	return result, nil
+}
+
+func runCLIPost(session *cliSession, args []string) (interface{}, error) {
+	flags := newCLIFlagSet("post")
+	subID := flags.String("sub", defaultSubID, "sub to post into")
+	title := flags.String("title", "", "post title")
+	body := flags.String("body", "", "post body")
+	bodyFile := flags.String("body-file", "", "read the body from a file, - for stdin")
+	wait := flags.Duration("wait", cliDefaultWait, "how long to wait for peers before publishing")
+	linger := flags.Duration("linger", cliDefaultLinger, "how long to stay online after publishing")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+	text, err := readCLIText(*body, *bodyFile)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, cliUsageError("post: --body or --body-file is required")
+	}
+
+	identity, err := session.activeIdentity()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = session.startNetwork(*wait); err != nil {
+		return nil, err
+	}
+	post, err := session.app.publishPostStructuredToSub(identity.PublicKey, *title, text, *subID)
+	if err != nil {
+		return nil, err
+	}
+	session.linger(*linger)
+	return post, nil
+}
+
+func runCLIComment(session *cliSession, args []string) (interface{}, error) {
+	flags := newCLIFlagSet("comment")
+	postID := flags.String("post", "", "post to comment on")
+	parentID := flags.String("parent", "", "parent comment for a reply")
+	body := flags.String("body", "", "comment body")
+	bodyFile := flags.String("body-file", "", "read the body from a file, - for stdin")
+	wait := flags.Duration("wait", cliDefaultWait, "how long to wait for peers before publishing")
+	linger := flags.Duration("linger", cliDefaultLinger, "how long to stay online after publishing")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(*postID) == "" {
+		return nil, cliUsageError("comment: --post is required")
+	}
+	text, err := readCLIText(*body, *bodyFile)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, cliUsageError("comment: --body or --body-file is required")
+	}
+
+	identity, err := session.activeIdentity()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = session.startNetwork(*wait); err != nil {
+		return nil, err
+	}
+	comment, err := session.app.publishComment(identity.PublicKey, *postID, *parentID, text)
+	if err != nil {
+		return nil, err
+	}
+	session.linger(*linger)
+	return comment, nil
+}
+
+func runCLIFeed(session *cliSession, args []string) (interface{}, error) {
+	flags := newCLIFlagSet("feed")
+	subID := flags.String("sub", defaultSubID, "sub to list")
+	sortMode := flags.String("sort", "hot", "hot or new")
+	limit := flags.Int("limit", 50, "maximum number of posts, 0 for all")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+
+	posts, err := session.app.GetFeedIndexBySubSorted(*subID, *sortMode)
+	if err != nil {
+		return nil, err
+	}
+	if *limit > 0 && len(posts) > *limit {
+		posts = posts[:*limit]
+	}
+	return posts, nil
+}
+
+func runCLISearch(session *cliSession, args []string) (interface{}, error) {
+	flags := newCLIFlagSet("search")
+	subID := flags.String("sub", "", "restrict to one sub")
+	kind := flags.String("kind", "", "post or comment (default: both)")
+	author := flags.String("author", "", "restrict to one author pubkey")
+	since := flags.Duration("since", 0, "only content from this far back")
+	limit := flags.Int("limit", 50, "maximum number of results")
+	cursor := flags.String("cursor", "", "nextCursor of the previous page")
+	network := flags.Bool("network", false, "ask connected peers and index the posts they return")
+	wait := flags.Duration("wait", cliDefaultWait, "with --network, how long to wait for peers")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+	query := strings.TrimSpace(strings.Join(flags.Args(), " "))
+	if query == "" {
+		return nil, cliUsageError("search: query is required")
+	}
+	request := SearchRequest{Query: query, Kind: *kind, SubID: *subID, Author: *author, Limit: *limit, Cursor: *cursor}
+	if *since > 0 {
+		request.Since = time.Now().Unix() - int64(since.Seconds())
+	}
+	if *network {
+		if _, err := session.startNetwork(*wait); err != nil {
+			return nil, err
+		}
+		return session.app.SearchNetwork(request)
+	}
+	return session.app.Search(request)
+}
+
+func runCLISubs(session *cliSession, args []string) (interface{}, error) {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "list":
+		return session.app.GetSubs()
+	case "subscribed":
+		return session.app.GetSubscribedSubs()
+	case "subscribe":
+		if len(args) != 1 {
+			return nil, cliUsageError("subs subscribe: sub id is required")
+		}
+		return session.app.SubscribeSub(args[0])
+	case "unsubscribe":
+		if len(args) != 1 {
+			return nil, cliUsageError("subs unsubscribe: sub id is required")
+		}
+		if err := session.app.UnsubscribeSub(args[0]); err != nil {
+			return nil, err
+		}
+		return session.app.GetSubscribedSubs()
+	case "create":
+		if len(args) == 0 {
+			return nil, cliUsageError("subs create: sub id is required")
+		}
+		subID := args[0]
+		flags := newCLIFlagSet("subs create")
+		title := flags.String("title", "", "sub title")
+		description := flags.String("description", "", "sub description")
+		wait := flags.Duration("wait", cliDefaultWait, "how long to wait for peers before publishing")
+		linger := flags.Duration("linger", cliDefaultLinger, "how long to stay online after publishing")
+		if err := parseCLIFlags(flags, args[1:]); err != nil {
+			return nil, err
+		}
+		if _, err := session.startNetwork(*wait); err != nil {
+			return nil, err
+		}
+		if err := session.app.PublishCreateSub(subID, *title, *description); err != nil {
+			return nil, err
+		}
+		session.linger(*linger)
+		return session.app.SubscribeSub(subID)
+	}
+	return nil, cliUsageError("subs: unknown action %q", action)
+}
+
+func runCLIIdentity(session *cliSession, args []string) (interface{}, error) {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+	app := session.app
+
+	flags := newCLIFlagSet("identity " + action)
+	label := flags.String("label", "", "identity label")
+	passphraseFile := flags.String("passphrase-file", "", "read the passphrase from a file")
+	mnemonicFile := flags.String("mnemonic-file", "", "import: read the mnemonic from a file instead of stdin")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case "list":
+		return app.ListIdentities()
+	case "create":
+		passphrase, err := readCLIPassphrase(*passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		return app.CreateIdentity(*label, passphrase)
+	case "import":
+		passphrase, err := readCLIPassphrase(*passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic, err := readCLIText("", *mnemonicFile)
+		if err != nil {
+			return nil, err
+		}
+		if *mnemonicFile == "" {
+			line, readErr := bufio.NewReader(os.Stdin).ReadString('\n')
+			if readErr != nil && !errors.Is(readErr, io.EOF) {
+				return nil, readErr
+			}
+			mnemonic = line
+		}
+		if strings.TrimSpace(mnemonic) == "" {
+			return nil, cliUsageError("identity import: mnemonic is required on stdin or via --mnemonic-file")
+		}
+		identity, err := app.ImportIdentityFromMnemonic(mnemonic, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if *label != "" {
+			if err = app.RenameIdentity(identity.PublicKey, *label); err != nil {
+				return nil, err
+			}
+		}
+		return identity, nil
+	case "export":
+		passphrase, err := readCLIPassphrase(*passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic, err := app.ExportIdentityMnemonic(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return Identity{Mnemonic: mnemonic, PublicKey: app.activeIdentityPubkey()}, nil
+	case "switch":
+		if flags.NArg() != 1 {
+			return nil, cliUsageError("identity switch: pubkey is required")
+		}
+		passphrase, err := readCLIPassphrase(*passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		return app.SwitchIdentity(flags.Arg(0), passphrase)
+	}
+	return nil, cliUsageError("identity: unknown action %q", action)
+}
+
+func runCLIPeers(session *cliSession, args []string) (interface{}, error) {
+	flags := newCLIFlagSet("peers")
+	wait := flags.Duration("wait", cliDefaultWait, "how long to wait for peers")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+
+	status, err := session.startNetwork(*wait)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(status.ConnectedPeers)
+	return status, nil
+}
+
+func runCLISync(session *cliSession, args []string) (interface{}, error) {
+	if len(args) == 0 || args[0] != "now" {
+		return nil, cliUsageError("sync: only \"sync now\" is supported")
+	}
+	flags := newCLIFlagSet("sync now")
+	wait := flags.Duration("wait", cliDefaultWait, "how long to wait for peers")
+	linger := flags.Duration("linger", cliDefaultLinger, "how long to wait for responses to window sync requests")
+	fetchWait := flags.Duration("fetch-wait", cliDefaultFetchWait, "how long to wait for the post bodies and media the sync turned up")
+	if err := parseCLIFlags(flags, args[1:]); err != nil {
+		return nil, err
+	}
+
+	status, err := session.startNetwork(*wait)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.ConnectedPeers) == 0 {
+		return nil, errReconcileNoPeers
+	}
+
+	// Peers may still be identifying; give them until the deadline to
+	// announce the reconcile protocol before falling back to window sync.
+	deadline := time.Now().Add(*wait)
+	for len(session.app.reconcileCandidates()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	reconciled := len(session.app.reconcileCandidates()) > 0
+	if err = session.app.TriggerAntiEntropySyncNow(); err != nil {
+		return nil, err
+	}
+	if !reconciled {
+		session.linger(*linger)
+	}
+	session.waitForBlobFetches(*fetchWait)
+
+	status = session.app.GetP2PStatus()
+	sort.Strings(status.ConnectedPeers)
+	return cliSyncResult{Peers: status.ConnectedPeers, AntiEntropy: session.app.GetAntiEntropyStats()}, nil
+}
+
+func runCLIGC(session *cliSession, args []string) (interface{}, error) {
+	flags := newCLIFlagSet("gc")
+	retentionDays := flags.Int("retention-days", 0, "keep tombstones younger than this many days (default 30)")
+	stablePasses := flags.Int("stable-passes", 0, "passes a tombstone must survive before removal (default 2)")
+	batchSize := flags.Int("batch", 0, "maximum tombstones removed per table (default 200)")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+	return session.app.RunTombstoneGC(*retentionDays, *stablePasses, *batchSize)
+}
+
+func runCLIComments(session *cliSession, args []string) (interface{}, error) {
+	flags := newCLIFlagSet("comments")
+	postID := flags.String("post", "", "post ID")
+	parentID := flags.String("parent", "", "read the replies under this comment instead of the top level")
+	sort := flags.String("sort", commentSortNew, "new, old, top or controversial")
+	limit := flags.Int("limit", defaultCommentPageLimit, "comments per page")
+	depth := flags.Int("depth", 2, "levels of replies to include below each comment")
+	replies := flags.Int("replies", defaultCommentReplyLimit, "replies per comment at each level")
+	cursor := flags.String("cursor", "", "nextCursor or repliesCursor of a previous page")
+	if err := parseCLIFlags(flags, args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(*postID) == "" {
+		return nil, cliUsageError("comments: --post is required")
+	}
+	return session.app.GetCommentPage(CommentPageRequest{
+		PostID:     *postID,
+		ParentID:   *parentID,
+		Sort:       *sort,
+		Limit:      *limit,
+		Cursor:     *cursor,
+		Depth:      *depth,
+		ReplyLimit: *replies,
+	})
+}
+
+func runCLIMaintenance(session *cliSession, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, cliUsageError("maintenance: expected run or runs")
+	}
+	action, args := args[0], args[1:]
+
+	switch action {
+	case "run":
+		if err := parseCLIFlags(newCLIFlagSet("maintenance run"), args); err != nil {
+			return nil, err
+		}
+		return session.app.RunMaintenanceNow()
+	case "runs":
+		flags := newCLIFlagSet("maintenance runs")
+		limit := flags.Int("limit", defaultMaintenanceRunLimit, "maximum number of runs")
+		if err := parseCLIFlags(flags, args); err != nil {
+			return nil, err
+		}
+		return session.app.GetMaintenanceRuns(*limit)
+	}
+	return nil, cliUsageError("maintenance: unknown action %q", action)
+}
+
+func runCLIHistory(session *cliSession, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, cliUsageError("history: expected metric NAME or alerts")
+	}
+	action, args := args[0], args[1:]
+
+	switch action {
+	case "metric":
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return nil, cliUsageError("history metric: metric name is required (%s)", strings.Join(alertMetricNames, ", "))
+		}
+		name := args[0]
+		flags := newCLIFlagSet("history metric")
+		since := flags.Duration("since", time.Hour, "how far back to read")
+		step := flags.Duration("step", 0, "bucket width (default: at most 720 points)")
+		if err := parseCLIFlags(flags, args[1:]); err != nil {
+			return nil, err
+		}
+		now := time.Now().Unix()
+		return session.app.GetMetricHistory(name, now-int64(since.Seconds()), now, int64(step.Seconds()))
+	case "alerts":
+		flags := newCLIFlagSet("history alerts")
+		since := flags.Duration("since", 0, "how far back to read (default: everything retained)")
+		limit := flags.Int("limit", defaultAlertHistoryLimit, "maximum number of events")
+		if err := parseCLIFlags(flags, args); err != nil {
+			return nil, err
+		}
+		from := int64(0)
+		if *since > 0 {
+			from = time.Now().Unix() - int64(since.Seconds())
+		}
+		return session.app.GetAlertHistory(from, 0, *limit)
+	}
+	return nil, cliUsageError("history: unknown action %q", action)
+}
+
+func runCLIQuota(session *cliSession, args []string) (interface{}, error) {
+	action := "show"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "show":
+		return session.app.GetStorageUsage()
+	case "set":
+		quotas, err := session.app.GetStorageQuotas()
+		if err != nil {
+			return nil, err
+		}
+		flags := newCLIFlagSet("quota set")
+		for _, option := range []struct {
+			name  string
+			usage string
+			value *int64
+		}{
+			{"total", "node quota over messages, content and media", &quotas.TotalBytes},
+			{"private", "private zone quota", &quotas.PrivateBytes},
+			{"public", "public zone quota", &quotas.PublicBytes},
+			{"media", "media blob budget", &quotas.MediaBytes},
+			{"pinned", "pinned blob budget", &quotas.PinnedBytes},
+		} {
+			value := option.value
+			flags.Func(option.name, option.usage, func(raw string) error {
+				size, err := parseCLIByteSize(raw)
+				if err != nil {
+					return err
+				}
+				*value = size
+				return nil
+			})
+		}
+		if err = parseCLIFlags(flags, args); err != nil {
+			return nil, err
+		}
+		return session.app.SetStorageQuotas(quotas.TotalBytes, quotas.PrivateBytes, quotas.PublicBytes, quotas.MediaBytes, quotas.PinnedBytes)
+	case "evictions":
+		flags := newCLIFlagSet("quota evictions")
+		limit := flags.Int("limit", defaultStorageEvictionLimit, "maximum number of entries")
+		if err := parseCLIFlags(flags, args); err != nil {
+			return nil, err
+		}
+		return session.app.GetStorageEvictions(*limit)
+	}
+	return nil, cliUsageError("quota: unknown action %q", action)
+}
+
+func runCLIPin(session *cliSession, args []string) (interface{}, error) {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	var err error
+	switch action {
+	case "list":
+		return session.app.ListPinned()
+	case "post", "unpin":
+		if len(args) != 1 {
+			return nil, cliUsageError("pin %s: expected one post ID", action)
+		}
+		if action == "post" {
+			err = session.app.PinPost(args[0])
+		} else {
+			err = session.app.UnpinPost(args[0])
+		}
+	case "sub":
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return nil, cliUsageError("pin sub: sub ID is required")
+		}
+		subID := args[0]
+		flags := newCLIFlagSet("pin sub")
+		off := flags.Bool("off", false, "stop pinning the sub")
+		if err = parseCLIFlags(flags, args[1:]); err != nil {
+			return nil, err
+		}
+		err = session.app.SetSubPinPolicy(subID, !*off)
+	default:
+		return nil, cliUsageError("pin: unknown action %q", action)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session.app.ListPinned()
+}
+
+// parseCLIByteSize reads a size such as 1048576, 512MiB or 2GB. KiB, MiB,
+// GiB and TiB and their one-letter forms are powers of 1024; KB, MB, GB and
+// TB are powers of 1000.
+func parseCLIByteSize(raw string) (int64, error) {
+	raw = strings.TrimSpace(raw)
+	index := strings.IndexFunc(raw, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	number, unit := raw, ""
+	if index >= 0 {
+		number, unit = raw[:index], strings.ToUpper(strings.TrimSpace(raw[index:]))
+	}
+
+	multipliers := map[string]float64{
+		"": 1, "B": 1,
+		"K": 1 << 10, "KIB": 1 << 10, "KB": 1e3,
+		"M": 1 << 20, "MIB": 1 << 20, "MB": 1e6,
+		"G": 1 << 30, "GIB": 1 << 30, "GB": 1e9,
+		"T": 1 << 40, "TIB": 1 << 40, "TB": 1e12,
+	}
+	multiplier, ok := multipliers[unit]
+	value, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size %q", raw)
+	}
+	return int64(value * multiplier), nil
+}
+
+func runCLIAlertRules(session *cliSession, args []string) (interface{}, error) {
+	action := "show"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "show":
+		return session.app.refreshAlertRules(true)
+	case "check":
+		if len(args) != 1 {
+			return nil, cliUsageError("alert-rules check: expected one FILE")
+		}
+		rules, err := loadAlertRulesFile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return AlertRulesStatus{Source: args[0], LoadedAt: time.Now().Unix(), Rules: rules}, nil
+	}
+	return nil, cliUsageError("alert-rules: unknown action %q", action)
+}
+
+func runCLIRecStrategies(session *cliSession, args []string) (interface{}, error) {
+	action := "show"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "show":
+		status, _ := session.app.refreshRecommendationStrategies(true)
+		return status, nil
+	case "check":
+		if len(args) != 1 {
+			return nil, cliUsageError("rec-strategies check: expected one FILE")
+		}
+		strategy, err := loadRecommendationStrategyFile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return strategy.info(args[0]), nil
+	}
+	return nil, cliUsageError("rec-strategies: unknown action %q", action)
+}
+
+func runCLIModeration(session *cliSession, args []string) (interface{}, error) {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "list":
+		return session.app.GetModerationState()
+	case "logs":
+		flags := newCLIFlagSet("moderation logs")
+		limit := flags.Int("limit", 100, "maximum number of entries")
+		if err := parseCLIFlags(flags, args); err != nil {
+			return nil, err
+		}
+		return session.app.GetModerationLogs(*limit)
+	case "ban", "unban":
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return nil, cliUsageError("moderation %s: target pubkey is required", action)
+		}
+		target := args[0]
+		flags := newCLIFlagSet("moderation " + action)
+		reason := flags.String("reason", "", "reason recorded with the action")
+		wait := flags.Duration("wait", cliDefaultWait, "how long to wait for peers before publishing")
+		linger := flags.Duration("linger", cliDefaultLinger, "how long to stay online after publishing")
+		if err := parseCLIFlags(flags, args[1:]); err != nil {
+			return nil, err
+		}
+
+		identity, err := session.activeIdentity()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = session.startNetwork(*wait); err != nil {
+			return nil, err
+		}
+		if action == "ban" {
+			err = session.app.PublishShadowBan(target, identity.PublicKey, *reason)
+		} else {
+			err = session.app.PublishUnban(target, identity.PublicKey, *reason)
+		}
+		if err != nil {
+			return nil, err
+		}
+		session.linger(*linger)
+		return session.app.GetModerationState()
+	}
+	return nil, cliUsageError("moderation: unknown action %q", action)
+}
+
+func runCLIRevisions(session *cliSession, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, cliUsageError("revisions: expected post ID or comment ID")
+	}
+	switch args[0] {
+	case "post":
+		return session.app.GetPostRevisions(args[1])
+	case "comment":
+		return session.app.GetCommentRevisions(args[1])
+	}
+	return nil, cliUsageError("revisions: unknown kind %q", args[0])
+}
+
+func runCLIArchive(session *cliSession, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, cliUsageError("archive: action is required (export or import)")
+	}
+	action, args := args[0], args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return nil, cliUsageError("archive %s: file path is required", action)
+	}
+	path := args[0]
+
+	switch action {
+	case "export":
+		flags := newCLIFlagSet("archive export")
+		noMedia := flags.Bool("no-media", false, "leave media blobs out of the archive")
+		publicOnly := flags.Bool("public-only", false, "leave private posts and their comments out")
+		if err := parseCLIFlags(flags, args[1:]); err != nil {
+			return nil, err
+		}
+		return session.app.ExportArchive(path, ArchiveOptions{ExcludeMedia: *noMedia, ExcludePrivate: *publicOnly})
+	case "import":
+		if len(args) != 1 {
+			return nil, cliUsageError("archive import: unexpected arguments %v", args[1:])
+		}
+		return session.app.ImportArchive(path)
+	}
+	return nil, cliUsageError("archive: unknown action %q", action)
+}
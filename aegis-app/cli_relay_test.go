@@ -0,0 +1,107 @@
+//go:build relay
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newCLITestSession(t *testing.T) *cliSession {
+	t.Helper()
+	session := &cliSession{app: newTestApp(t)}
+	t.Cleanup(session.close)
+	return session
+}
+
+func TestRelayCLIUsageListsEveryCommand(t *testing.T) {
+	var out bytes.Buffer
+	printRelayCLIUsage(&out)
+	for _, command := range relayCLICommands() {
+		if !strings.Contains(out.String(), "  "+command.name+" ") {
+			t.Fatalf("usage does not list %q:\n%s", command.name, out.String())
+		}
+	}
+
+	t.Setenv("AEGIS_DB_PATH", filepath.Join(t.TempDir(), "aegis_cli.db"))
+	if code := runRelayCLI([]string{"no-such-command"}); code != cliExitUsage {
+		t.Fatalf("expected usage exit for an unknown command, got %d", code)
+	}
+	if code := runRelayCLI([]string{"feed", "--limit", "many"}); code != cliExitUsage {
+		t.Fatalf("expected usage exit for a bad flag, got %d", code)
+	}
+	if code := runRelayCLI([]string{"feed", "--limit", "1"}); code != cliExitOK {
+		t.Fatalf("expected feed to succeed, got %d", code)
+	}
+}
+
+func TestRelayCLIRejectsBadFlagsAndArguments(t *testing.T) {
+	session := newCLITestSession(t)
+	for _, test := range []struct {
+		name string
+		run  func(*cliSession, []string) (interface{}, error)
+		args []string
+	}{
+		{"sync without now", runCLISync, nil},
+		{"sync later", runCLISync, []string{"later"}},
+		{"sync unknown flag", runCLISync, []string{"now", "--bogus"}},
+		{"sync bad duration", runCLISync, []string{"now", "--fetch-wait", "soon"}},
+		{"feed bad limit", runCLIFeed, []string{"--limit", "many"}},
+		{"quota bad size", runCLIQuota, []string{"set", "--total", "lots"}},
+		{"identity unknown action", runCLIIdentity, []string{"rename"}},
+	} {
+		if _, err := test.run(session, test.args); !errors.Is(err, errCLIUsage) {
+			t.Fatalf("%s: expected usage error, got %v", test.name, err)
+		}
+	}
+}
+
+func TestRelayCLISyncNowFetchesPostsWithTheirBodies(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	t.Setenv("AEGIS_JOIN_ALL_SUB_TOPICS", "0")
+	post := ForumMessage{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Hello", Body: "A body worth fetching.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "tech"}
+	responder := newTestApp(t)
+	if _, err := responder.insertMessage(post); err != nil {
+		t.Fatalf("insert post: %v", err)
+	}
+	startTestP2P(t, responder)
+	responder.p2pHost.SetStreamHandler(blobProtocolID, responder.handleBlobStream)
+	responder.p2pHost.SetStreamHandler(reconcileProtocolID, responder.handleReconcileStream)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	t.Setenv("AEGIS_P2P_PORT", strconv.Itoa(port))
+	t.Setenv("AEGIS_BOOTSTRAP_PEERS", fmt.Sprintf("%s/p2p/%s", responder.p2pHost.Addrs()[0], responder.p2pHost.ID()))
+
+	session := newCLITestSession(t)
+	if _, err = session.app.SubscribeSub("tech"); err != nil {
+		t.Fatalf("follow tech: %v", err)
+	}
+	result, err := runCLISync(session, []string{"now", "--wait", "10s", "--fetch-wait", "10s"})
+	if err != nil {
+		t.Fatalf("sync now: %v", err)
+	}
+	synced := result.(cliSyncResult)
+	if len(synced.Peers) == 0 || synced.AntiEntropy.ReconcileSessions != 1 {
+		t.Fatalf("unexpected sync result %+v", synced)
+	}
+
+	// The body arrives before sync now returns, not after P2P is closed.
+	_ = session.app.StopP2P()
+	if count := countRows(t, session.app, `SELECT COUNT(1) FROM messages WHERE id = 'p-1';`); count != 1 {
+		t.Fatalf("post was not indexed")
+	}
+	if count := countRows(t, session.app, `SELECT COUNT(1) FROM content_blobs WHERE content_cid = ?;`, buildContentCID(post.Body)); count != 1 {
+		t.Fatalf("post body was not fetched before the command returned")
+	}
+}
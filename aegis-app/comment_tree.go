@@ -0,0 +1,478 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Comment pages read one level of a thread at a time: the top-level comments
+// of a post, or the replies under one comment. Each returned comment can
+// carry its first replies down to Depth levels, and says how many direct
+// replies and descendants it has, so a client renders a large thread without
+// loading it whole. A comment whose parent is deleted or hidden is read as
+// top-level, as the full comment list has always been shown.
+//
+// Pages are ordered by a sort key and the comment ID, and NextCursor holds
+// both for the last comment returned. A cursor only continues the sort it
+// was issued for.
+const (
+	commentSortNew           = "new"
+	commentSortOld           = "old"
+	commentSortTop           = "top"
+	commentSortControversial = "controversial"
+
+	defaultCommentPageLimit  = 20
+	maxCommentPageLimit      = 100
+	defaultCommentReplyLimit = 5
+	maxCommentReplyLimit     = 50
+	maxCommentTreeDepth      = 8
+)
+
+var (
+	errInvalidCommentSort   = errors.New("invalid comment sort")
+	errInvalidCommentCursor = errors.New("invalid comment cursor")
+)
+
+type CommentPageRequest struct {
+	PostID string `json:"postId"`
+	// ParentID reads the replies under one comment; empty reads the
+	// top-level comments.
+	ParentID string `json:"parentId"`
+	Sort     string `json:"sort"`
+	Limit    int    `json:"limit"`
+	Cursor   string `json:"cursor"`
+	// Depth is how many levels of replies to include below each comment,
+	// ReplyLimit replies per comment at each level.
+	Depth      int `json:"depth"`
+	ReplyLimit int `json:"replyLimit"`
+}
+
+type CommentNode struct {
+	Comment
+	Upvotes         int64         `json:"upvotes"`
+	Downvotes       int64         `json:"downvotes"`
+	ReplyCount      int           `json:"replyCount"`
+	DescendantCount int           `json:"descendantCount"`
+	Replies         []CommentNode `json:"replies"`
+	// RepliesCursor continues Replies when more replies exist than were
+	// included.
+	RepliesCursor string `json:"repliesCursor"`
+}
+
+type CommentPage struct {
+	PostID     string `json:"postId"`
+	ParentID   string `json:"parentId"`
+	Sort       string `json:"sort"`
+	TotalCount int    `json:"totalCount"`
+	// ThreadCount counts every comment of the post the viewer can see.
+	ThreadCount int           `json:"threadCount"`
+	Comments    []CommentNode `json:"comments"`
+	NextCursor  string        `json:"nextCursor"`
+}
+
+type commentCursor struct {
+	Key float64
+	ID  string
+}
+
+// commentView decides which comments the local viewer sees: tombstones never,
+// and a shadow-banned author's comments only if they are the viewer's own or,
+// when the policy keeps history, were written before the ban.
+type commentView struct {
+	viewerPubkey           string
+	hideHistoryOnShadowBan bool
+}
+
+func (a *App) currentCommentView() commentView {
+	view := commentView{hideHistoryOnShadowBan: true}
+	if identity, err := a.getLocalIdentity(); err == nil {
+		view.viewerPubkey = strings.TrimSpace(identity.PublicKey)
+	}
+	if policy, err := a.GetGovernancePolicy(); err == nil {
+		view.hideHistoryOnShadowBan = policy.HideHistoryOnShadowBan
+	}
+	return view
+}
+
+// clause filters comment rows aliased comment, joined with their author's
+// moderation row aliased moderation.
+func (v commentView) clause(comment string, moderation string) (string, []any) {
+	clause := fmt.Sprintf(`%[1]s.deleted_at = 0 AND (
+			%[2]s.action IS NULL
+			OR UPPER(%[2]s.action) != 'SHADOW_BAN'
+			OR %[1]s.pubkey = ?`, comment, moderation)
+	if !v.hideHistoryOnShadowBan {
+		clause += fmt.Sprintf(`
+			OR %[1]s.lamport < %[2]s.lamport
+			OR (%[1]s.lamport = 0 OR %[2]s.lamport = 0) AND %[1]s.timestamp < %[2]s.timestamp`, comment, moderation)
+	}
+	return clause + `
+		)`, []any{v.viewerPubkey}
+}
+
+func normalizeCommentSort(sort string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(sort)) {
+	case "", commentSortNew:
+		return commentSortNew, nil
+	case commentSortOld:
+		return commentSortOld, nil
+	case commentSortTop:
+		return commentSortTop, nil
+	case commentSortControversial:
+		return commentSortControversial, nil
+	}
+	return "", errInvalidCommentSort
+}
+
+// commentSortOrder returns the sort key expression over a comment row
+// aliased c, and whether it sorts ascending. New, old and top sort on an
+// indexed column. Controversial ranks comments by vote volume, scaled down
+// the more one side outweighs the other, and puts comments without both up
+// and down votes last; its key needs the vote counts of every comment on
+// the level.
+func commentSortOrder(sort string) (string, bool) {
+	switch sort {
+	case commentSortOld:
+		return "c.timestamp", true
+	case commentSortTop:
+		return "c.score", false
+	case commentSortControversial:
+		ups, downs := commentUpvotesSQL("c"), commentDownvotesSQL("c")
+		return fmt.Sprintf("CASE WHEN %[1]s > 0 AND %[2]s > 0 THEN (%[1]s + %[2]s) * MIN(%[1]s, %[2]s) * 1.0 / MAX(%[1]s, %[2]s) ELSE 0 END", ups, downs), false
+	}
+	return "c.timestamp", false
+}
+
+func commentUpvotesSQL(comment string) string {
+	return "(SELECT COUNT(1) FROM comment_votes v WHERE v.comment_id = " + comment + ".id)"
+}
+
+func commentDownvotesSQL(comment string) string {
+	return "(SELECT COUNT(1) FROM comment_downvotes d WHERE d.comment_id = " + comment + ".id)"
+}
+
+// GetCommentPage returns one page of top-level comments of a post, or of the
+// replies under request.ParentID, in the requested sort: new (default), old,
+// top or controversial.
+func (a *App) GetCommentPage(request CommentPageRequest) (CommentPage, error) {
+	if a.db == nil {
+		return CommentPage{}, errors.New("database not initialized")
+	}
+
+	postID := strings.TrimSpace(request.PostID)
+	if postID == "" {
+		return CommentPage{}, errors.New("post id is required")
+	}
+	parentID := strings.TrimSpace(request.ParentID)
+	sort, err := normalizeCommentSort(request.Sort)
+	if err != nil {
+		return CommentPage{}, err
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultCommentPageLimit
+	}
+	if limit > maxCommentPageLimit {
+		limit = maxCommentPageLimit
+	}
+	replyLimit := request.ReplyLimit
+	if replyLimit <= 0 {
+		replyLimit = defaultCommentReplyLimit
+	}
+	if replyLimit > maxCommentReplyLimit {
+		replyLimit = maxCommentReplyLimit
+	}
+	depth := request.Depth
+	if depth < 0 {
+		depth = 0
+	}
+	if depth > maxCommentTreeDepth {
+		depth = maxCommentTreeDepth
+	}
+	var cursor *commentCursor
+	if raw := strings.TrimSpace(request.Cursor); raw != "" {
+		if cursor, err = decodeCommentCursor(sort, raw); err != nil {
+			return CommentPage{}, err
+		}
+	}
+
+	view := a.currentCommentView()
+	page := CommentPage{PostID: postID, ParentID: parentID, Sort: sort}
+	if page.TotalCount, err = a.countCommentLevel(view, postID, parentID, false); err != nil {
+		return CommentPage{}, err
+	}
+	if page.ThreadCount, err = a.countCommentLevel(view, postID, "", true); err != nil {
+		return CommentPage{}, err
+	}
+	levels, cursors, err := a.queryCommentLevels(view, postID, []string{parentID}, sort, cursor, limit)
+	if err != nil {
+		return CommentPage{}, err
+	}
+	page.Comments = levels[parentID]
+	if page.Comments == nil {
+		page.Comments = make([]CommentNode, 0)
+	}
+	page.NextCursor = cursors[parentID]
+
+	frontier := make([]*CommentNode, 0, len(page.Comments))
+	for i := range page.Comments {
+		frontier = append(frontier, &page.Comments[i])
+	}
+	nodes := append([]*CommentNode(nil), frontier...)
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		parentIDs := make([]string, 0, len(frontier))
+		for _, node := range frontier {
+			parentIDs = append(parentIDs, node.ID)
+		}
+		replies, replyCursors, err := a.queryCommentLevels(view, postID, parentIDs, sort, nil, replyLimit)
+		if err != nil {
+			return CommentPage{}, err
+		}
+		next := make([]*CommentNode, 0)
+		for _, node := range frontier {
+			if children, ok := replies[node.ID]; ok {
+				node.Replies = children
+			}
+			node.RepliesCursor = replyCursors[node.ID]
+			for i := range node.Replies {
+				next = append(next, &node.Replies[i])
+			}
+		}
+		nodes = append(nodes, next...)
+		frontier = next
+	}
+
+	if err = a.fillCommentCounts(view, postID, nodes); err != nil {
+		return CommentPage{}, err
+	}
+	return page, nil
+}
+
+// commentLevelFilter selects the comments of one level: the replies under
+// the given parents, or the top-level comments when parentIDs is [""].
+func commentLevelFilter(view commentView, parentIDs []string) (string, string, []any) {
+	if len(parentIDs) == 1 && parentIDs[0] == "" {
+		clause, args := view.clause("p", "pm")
+		return "''", `(c.parent_id = '' OR NOT EXISTS (
+				SELECT 1
+				FROM comments p
+				LEFT JOIN moderation pm ON pm.target_pubkey = p.pubkey
+				WHERE p.id = c.parent_id AND p.post_id = c.post_id AND ` + clause + `
+			))`, args
+	}
+	args := make([]any, 0, len(parentIDs))
+	for _, parentID := range parentIDs {
+		args = append(args, parentID)
+	}
+	return "c.parent_id", "c.parent_id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(parentIDs)), ", ") + ")", args
+}
+
+// countCommentLevel counts the visible comments under parentID, or in the
+// whole thread when wholeThread is set.
+func (a *App) countCommentLevel(view commentView, postID string, parentID string, wholeThread bool) (int, error) {
+	visible, visibleArgs := view.clause("c", "m")
+	_, level, levelArgs := commentLevelFilter(view, []string{parentID})
+	if wholeThread {
+		level, levelArgs = "1 = 1", nil
+	}
+	args := append([]any{postID}, visibleArgs...)
+	args = append(args, levelArgs...)
+
+	var count int
+	err := a.db.QueryRow(`
+		SELECT COUNT(1)
+		FROM comments c
+		LEFT JOIN moderation m ON m.target_pubkey = c.pubkey
+		WHERE c.post_id = ? AND `+visible+` AND `+level+`;
+	`, args...).Scan(&count)
+	return count, err
+}
+
+// queryCommentLevels reads up to limit comments under each of parentIDs in
+// one query, and a cursor for every parent that has more. The cursor and
+// the limit apply to the sort key before anything else is read, and the
+// vote counts are only computed for the comments returned. A single parent
+// is read with ORDER BY and LIMIT, which the post, parent and sort column
+// indexes serve; several parents are numbered per parent instead.
+func (a *App) queryCommentLevels(view commentView, postID string, parentIDs []string, sort string, cursor *commentCursor, limit int) (map[string][]CommentNode, map[string]string, error) {
+	visible, visibleArgs := view.clause("c", "m")
+	levelParent, level, levelArgs := commentLevelFilter(view, parentIDs)
+	key, ascending := commentSortOrder(sort)
+	direction, after := "DESC", "<"
+	if ascending {
+		direction, after = "ASC", ">"
+	}
+	hasCursor := 0
+	var cursorKey float64
+	var cursorID string
+	if cursor != nil {
+		hasCursor, cursorKey, cursorID = 1, cursor.Key, cursor.ID
+	}
+
+	args := append([]any{postID}, visibleArgs...)
+	args = append(args, levelArgs...)
+	args = append(args, hasCursor, cursorKey, cursorKey, cursorID)
+	pageLimit := ""
+	if len(parentIDs) == 1 {
+		pageLimit = `
+			ORDER BY ` + key + ` ` + direction + `, c.id ` + direction + `
+			LIMIT ?`
+		args = append(args, limit+1)
+	}
+	args = append(args, limit+1)
+	rows, err := a.db.Query(`
+		WITH page AS (
+			SELECT c.id, c.post_id, c.parent_id, c.pubkey, c.body, c.attachments_json, c.score, c.timestamp, c.lamport,
+				`+levelParent+` AS level_parent,
+				`+key+` AS sort_key
+			FROM comments c
+			LEFT JOIN moderation m ON m.target_pubkey = c.pubkey
+			WHERE c.post_id = ? AND `+visible+` AND `+level+`
+				AND (? = 0 OR `+key+` `+after+` ? OR (`+key+` = ? AND c.id `+after+` ?))`+pageLimit+`
+		), ranked AS (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY level_parent ORDER BY sort_key `+direction+`, id `+direction+`) AS position
+			FROM page
+		)
+		SELECT c.id, c.post_id, c.parent_id, c.pubkey, c.body, c.attachments_json, c.score, c.timestamp, c.lamport,
+			`+commentUpvotesSQL("c")+`, `+commentDownvotesSQL("c")+`, c.sort_key, c.level_parent
+		FROM ranked c
+		WHERE c.position <= ?
+		ORDER BY c.level_parent, c.position;
+	`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	levels := make(map[string][]CommentNode, len(parentIDs))
+	cursors := make(map[string]string)
+	lastKeys := make(map[string]float64, len(parentIDs))
+	for rows.Next() {
+		var node CommentNode
+		var attachmentsJSON string
+		var sortKey float64
+		var parent string
+		if err = rows.Scan(&node.ID, &node.PostID, &node.ParentID, &node.Pubkey, &node.Body, &attachmentsJSON, &node.Score, &node.Timestamp, &node.Lamport,
+			&node.Upvotes, &node.Downvotes, &sortKey, &parent); err != nil {
+			return nil, nil, err
+		}
+		siblings := levels[parent]
+		if len(siblings) == limit {
+			cursors[parent] = encodeCommentCursor(sort, commentCursor{Key: lastKeys[parent], ID: siblings[limit-1].ID})
+			continue
+		}
+		node.Attachments = decodeCommentAttachmentsJSON(attachmentsJSON)
+		node.Replies = make([]CommentNode, 0)
+		levels[parent] = append(siblings, node)
+		lastKeys[parent] = sortKey
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return levels, cursors, nil
+}
+
+// fillCommentCounts sets the direct reply and descendant counts of nodes.
+// The walk follows parent links through visible comments only, and UNION
+// keeps a malformed parent cycle from looping.
+func (a *App) fillCommentCounts(view commentView, postID string, nodes []*CommentNode) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	ids := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	visible, visibleArgs := view.clause("c", "m")
+
+	replies := make(map[string]int, len(nodes))
+	args := append([]any{postID}, ids...)
+	args = append(args, visibleArgs...)
+	rows, err := a.db.Query(`
+		SELECT c.parent_id, COUNT(1)
+		FROM comments c
+		LEFT JOIN moderation m ON m.target_pubkey = c.pubkey
+		WHERE c.post_id = ? AND c.parent_id IN (`+placeholders+`) AND `+visible+`
+		GROUP BY c.parent_id;
+	`, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var parentID string
+		var count int
+		if err = rows.Scan(&parentID, &count); err != nil {
+			rows.Close()
+			return err
+		}
+		replies[parentID] = count
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	descendants := make(map[string]int, len(nodes))
+	args = append([]any{postID}, ids...)
+	args = append(args, postID)
+	args = append(args, visibleArgs...)
+	rows, err = a.db.Query(`
+		WITH RECURSIVE subtree(root_id, id) AS (
+			SELECT id, id FROM comments WHERE post_id = ? AND id IN (`+placeholders+`)
+			UNION
+			SELECT s.root_id, c.id
+			FROM subtree s
+			JOIN comments c ON c.parent_id = s.id
+			LEFT JOIN moderation m ON m.target_pubkey = c.pubkey
+			WHERE c.post_id = ? AND `+visible+`
+		)
+		SELECT root_id, COUNT(1) - 1 FROM subtree GROUP BY root_id;
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rootID string
+		var count int
+		if err = rows.Scan(&rootID, &count); err != nil {
+			return err
+		}
+		descendants[rootID] = count
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		node.ReplyCount = replies[node.ID]
+		node.DescendantCount = descendants[node.ID]
+	}
+	return nil
+}
+
+func encodeCommentCursor(sort string, cursor commentCursor) string {
+	raw := sort + ":" + strconv.FormatFloat(cursor.Key, 'g', -1, 64) + ":" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCommentCursor(sort string, cursor string) (*commentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCommentCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || parts[0] != sort || parts[2] == "" {
+		return nil, errInvalidCommentCursor
+	}
+	key, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return nil, errInvalidCommentCursor
+	}
+	return &commentCursor{Key: key, ID: parts[2]}, nil
+}
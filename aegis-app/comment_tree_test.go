@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func commentNodeIDs(nodes []CommentNode) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	return ids
+}
+
+func TestCommentPageSortsPaginatesAndCounts(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	if _, err := app.insertMessage(ForumMessage{ID: "p-1", Pubkey: "alice", OpID: "op-p1", Title: "Thread", Body: "body", Timestamp: 100, Lamport: 1, Zone: "public", SubID: defaultSubID}); err != nil {
+		t.Fatalf("insert post: %v", err)
+	}
+
+	// a, b and c are top-level; a has replies a1..a3 and a1 has a11. o's
+	// parent is gone, so it reads as top-level too.
+	comments := []Comment{
+		{ID: "a", Timestamp: 10},
+		{ID: "b", Timestamp: 20},
+		{ID: "c", Timestamp: 30},
+		{ID: "a1", ParentID: "a", Timestamp: 11},
+		{ID: "a2", ParentID: "a", Timestamp: 12},
+		{ID: "a3", ParentID: "a", Timestamp: 13},
+		{ID: "a11", ParentID: "a1", Timestamp: 14},
+		{ID: "o", ParentID: "gone", Timestamp: 5},
+	}
+	for i, comment := range comments {
+		comment.PostID = "p-1"
+		comment.Pubkey = "bob"
+		comment.OpID = "op-" + comment.ID
+		comment.Body = "comment " + comment.ID
+		comment.Lamport = int64(10 + i)
+		if _, err := app.insertComment(comment); err != nil {
+			t.Fatalf("insert comment %s: %v", comment.ID, err)
+		}
+	}
+	votes := map[string][2]int{"a": {5, 0}, "b": {3, 3}, "c": {4, 1}}
+	for commentID, count := range votes {
+		for i := 0; i < count[0]; i++ {
+			if _, err := app.db.Exec(`INSERT INTO comment_votes (comment_id, voter_pubkey, timestamp) VALUES (?, ?, 1);`, commentID, "up-"+string(rune('a'+i))); err != nil {
+				t.Fatalf("upvote: %v", err)
+			}
+		}
+		for i := 0; i < count[1]; i++ {
+			if _, err := app.db.Exec(`INSERT INTO comment_downvotes (comment_id, voter_pubkey, timestamp) VALUES (?, ?, 1);`, commentID, "down-"+string(rune('a'+i))); err != nil {
+				t.Fatalf("downvote: %v", err)
+			}
+		}
+		if _, err := app.db.Exec(`UPDATE comments SET score = ? WHERE id = ?;`, count[0]-count[1], commentID); err != nil {
+			t.Fatalf("score: %v", err)
+		}
+	}
+
+	order := map[string][]string{
+		commentSortNew:           {"c", "b", "a", "o"},
+		commentSortOld:           {"o", "a", "b", "c"},
+		commentSortTop:           {"a", "c", "o", "b"},
+		commentSortControversial: {"b", "c", "o", "a"},
+	}
+	for sort, want := range order {
+		page, err := app.GetCommentPage(CommentPageRequest{PostID: "p-1", Sort: sort, Limit: 10})
+		if err != nil || !reflect.DeepEqual(commentNodeIDs(page.Comments), want) || page.TotalCount != 4 || page.ThreadCount != 8 || page.NextCursor != "" {
+			t.Fatalf("%s: unexpected page %v, err %v", sort, commentNodeIDs(page.Comments), err)
+		}
+	}
+
+	// One at a time, every sort walks the same order through its cursors,
+	// including ties on the sort key.
+	for sort, want := range order {
+		walked := make([]string, 0, len(want))
+		cursor := ""
+		for range want {
+			page, err := app.GetCommentPage(CommentPageRequest{PostID: "p-1", Sort: sort, Limit: 1, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("%s: page after %v: %v", sort, walked, err)
+			}
+			walked = append(walked, commentNodeIDs(page.Comments)...)
+			cursor = page.NextCursor
+		}
+		if !reflect.DeepEqual(walked, want) || cursor != "" {
+			t.Fatalf("%s: walked %v, cursor %q", sort, walked, cursor)
+		}
+	}
+
+	// Two at a time, with the first replies of each comment inlined.
+	first, err := app.GetCommentPage(CommentPageRequest{PostID: "p-1", Sort: commentSortOld, Limit: 2, Depth: 2, ReplyLimit: 2})
+	if err != nil || !reflect.DeepEqual(commentNodeIDs(first.Comments), []string{"o", "a"}) || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v, err %v", first, err)
+	}
+	a := first.Comments[1]
+	if a.ReplyCount != 3 || a.DescendantCount != 4 || a.Upvotes != 5 || !reflect.DeepEqual(commentNodeIDs(a.Replies), []string{"a1", "a2"}) || a.RepliesCursor == "" {
+		t.Fatalf("unexpected node %+v", a)
+	}
+	if a1 := a.Replies[0]; a1.ReplyCount != 1 || !reflect.DeepEqual(commentNodeIDs(a1.Replies), []string{"a11"}) || a1.RepliesCursor != "" {
+		t.Fatalf("unexpected reply %+v", a1)
+	}
+	second, err := app.GetCommentPage(CommentPageRequest{PostID: "p-1", Sort: commentSortOld, Limit: 2, Cursor: first.NextCursor})
+	if err != nil || !reflect.DeepEqual(commentNodeIDs(second.Comments), []string{"b", "c"}) || second.NextCursor != "" {
+		t.Fatalf("unexpected second page %+v, err %v", second, err)
+	}
+
+	more, err := app.GetCommentPage(CommentPageRequest{PostID: "p-1", ParentID: "a", Sort: commentSortOld, Limit: 2, Cursor: a.RepliesCursor})
+	if err != nil || !reflect.DeepEqual(commentNodeIDs(more.Comments), []string{"a3"}) || more.TotalCount != 3 {
+		t.Fatalf("unexpected replies page %+v, err %v", more, err)
+	}
+	if _, err = app.GetCommentPage(CommentPageRequest{PostID: "p-1", Sort: commentSortTop, Cursor: first.NextCursor}); !errors.Is(err, errInvalidCommentCursor) {
+		t.Fatalf("expected a cursor from another sort to be rejected, got %v", err)
+	}
+
+	// Deleting a1 moves a11 to the top level and out of a's counts.
+	if _, err = app.db.Exec(`UPDATE comments SET deleted_at = 1 WHERE id = 'a1';`); err != nil {
+		t.Fatalf("delete a1: %v", err)
+	}
+	page, err := app.GetCommentPage(CommentPageRequest{PostID: "p-1", Sort: commentSortOld})
+	if err != nil || !reflect.DeepEqual(commentNodeIDs(page.Comments), []string{"o", "a", "a11", "b", "c"}) || page.Comments[1].DescendantCount != 2 {
+		t.Fatalf("unexpected page after delete %+v, err %v", page, err)
+	}
+}
@@ -30,12 +30,6 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-const (
-	totalQuotaBytes   int64 = 100 * 1024 * 1024
-	privateQuotaBytes int64 = 20 * 1024 * 1024
-	publicQuotaBytes  int64 = 80 * 1024 * 1024
-)
-
 type ForumMessage struct {
 	ID          string `json:"id"`
 	Pubkey      string `json:"pubkey"`
@@ -150,6 +144,7 @@ type ModerationState struct {
 	Timestamp    int64  `json:"timestamp"`
 	Lamport      int64  `json:"lamport"`
 	Reason       string `json:"reason"`
+	Signature    string `json:"signature,omitempty"`
 }
 
 type ModerationLog struct {
@@ -161,6 +156,7 @@ type ModerationLog struct {
 	Lamport      int64  `json:"lamport"`
 	Reason       string `json:"reason"`
 	Result       string `json:"result"`
+	Signature    string `json:"signature,omitempty"`
 }
 
 type GovernancePolicy struct {
@@ -192,8 +188,15 @@ type IdentityState struct {
 type StorageUsage struct {
 	PrivateUsedBytes int64 `json:"privateUsedBytes"`
 	PublicUsedBytes  int64 `json:"publicUsedBytes"`
+	MediaUsedBytes   int64 `json:"mediaUsedBytes"`
+	PinnedUsedBytes  int64 `json:"pinnedUsedBytes"`
+	MessageBytes     int64 `json:"messageBytes"`
+	ContentBytes     int64 `json:"contentBytes"`
+	TotalUsedBytes   int64 `json:"totalUsedBytes"`
 	PrivateQuota     int64 `json:"privateQuota"`
 	PublicQuota      int64 `json:"publicQuota"`
+	MediaQuota       int64 `json:"mediaQuota"`
+	PinnedQuota      int64 `json:"pinnedQuota"`
 	TotalQuota       int64 `json:"totalQuota"`
 }
 
@@ -242,12 +245,16 @@ type TombstoneGCResult struct {
 	DeletedPosts    int `json:"deletedPosts"`
 	ScannedComments int `json:"scannedComments"`
 	DeletedComments int `json:"deletedComments"`
+	// ReleasedRevisionBodies counts revision bodies dropped by the
+	// revision retention policy.
+	ReleasedRevisionBodies int `json:"releasedRevisionBodies"`
 }
 
 type GovernanceAdmin struct {
 	AdminPubkey string `json:"adminPubkey"`
 	Role        string `json:"role"`
 	Active      bool   `json:"active"`
+	Source      string `json:"source"`
 }
 
 type SyncPostDigest struct {
@@ -268,6 +275,7 @@ type SyncPostDigest struct {
 	Timestamp        int64  `json:"timestamp"`
 	Lamport          int64  `json:"lamport"`
 	SubID            string `json:"sub_id"`
+	Signature        string `json:"signature,omitempty"`
 }
 
 type SyncCommentDigest struct {
@@ -286,6 +294,7 @@ type SyncCommentDigest struct {
 	Score            int64               `json:"score"`
 	Timestamp        int64               `json:"timestamp"`
 	Lamport          int64               `json:"lamport"`
+	Signature        string              `json:"signature,omitempty"`
 }
 
 type IncomingMessage struct {
@@ -329,11 +338,20 @@ type IncomingMessage struct {
 	GovernanceLogLimit     int                 `json:"governance_log_limit,omitempty"`
 	GovernanceStates       []ModerationState   `json:"governance_states,omitempty"`
 	GovernanceLogs         []ModerationLog     `json:"governance_logs,omitempty"`
+	GovernanceDelegations  []AdminDelegation   `json:"governance_delegations,omitempty"`
+	AdminRole              string              `json:"admin_role,omitempty"`
 	FavoriteOpID           string              `json:"favorite_op_id,omitempty"`
 	FavoriteOp             string              `json:"favorite_op,omitempty"`
 	FavoriteSinceTs        int64               `json:"favorite_since_ts,omitempty"`
 	FavoriteBatchSize      int                 `json:"favorite_batch_size,omitempty"`
 	FavoriteOps            []FavoriteOpRecord  `json:"favorite_ops,omitempty"`
+	SearchQuery            string              `json:"search_query,omitempty"`
+	SearchAuthor           string              `json:"search_author,omitempty"`
+	SearchSinceTs          int64               `json:"search_since_ts,omitempty"`
+	SearchUntilTs          int64               `json:"search_until_ts,omitempty"`
+	SearchLimit            int                 `json:"search_limit,omitempty"`
+	RevisionOpIDs          []string            `json:"revision_op_ids,omitempty"`
+	RevisionBodies         map[string]string   `json:"revision_bodies,omitempty"`
 	Found                  bool                `json:"found"`
 	SizeBytes              int64               `json:"size_bytes"`
 	Content                string              `json:"content"`
@@ -744,6 +762,11 @@ func (a *App) RunTombstoneGC(retentionDays int, requiredStablePasses int, batchS
 			_ = tx.Rollback()
 			return TombstoneGCResult{}, err
 		}
+		if err = deleteEntityRevisionsTx(tx, entityTypePost, postID); err != nil {
+			postRows.Close()
+			_ = tx.Rollback()
+			return TombstoneGCResult{}, err
+		}
 		if _, err = tx.Exec(`DELETE FROM tombstone_gc_marks WHERE entity_type = ? AND entity_id = ?;`, entityTypePost, postID); err != nil {
 			postRows.Close()
 			_ = tx.Rollback()
@@ -797,6 +820,11 @@ func (a *App) RunTombstoneGC(retentionDays int, requiredStablePasses int, batchS
 			_ = tx.Rollback()
 			return TombstoneGCResult{}, err
 		}
+		if err = deleteEntityRevisionsTx(tx, entityTypeComment, commentID); err != nil {
+			commentRows.Close()
+			_ = tx.Rollback()
+			return TombstoneGCResult{}, err
+		}
 		if _, err = tx.Exec(`DELETE FROM tombstone_gc_marks WHERE entity_type = ? AND entity_id = ?;`, entityTypeComment, commentID); err != nil {
 			commentRows.Close()
 			_ = tx.Rollback()
@@ -811,6 +839,11 @@ func (a *App) RunTombstoneGC(retentionDays int, requiredStablePasses int, batchS
 	}
 	commentRows.Close()
 
+	if result.ReleasedRevisionBodies, err = pruneRevisionBodiesTx(tx, resolveRevisionRetention(), now, "", ""); err != nil {
+		_ = tx.Rollback()
+		return TombstoneGCResult{}, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return TombstoneGCResult{}, err
 	}
@@ -843,6 +876,12 @@ func (a *App) initDatabase() error {
 		return err
 	}
 
+	// Only takes effect on a new database; maintenance converts older ones.
+	if _, err = db.Exec("PRAGMA auto_vacuum = INCREMENTAL;"); err != nil {
+		_ = db.Close()
+		return err
+	}
+
 	if _, err = db.Exec("PRAGMA journal_mode = WAL;"); err != nil {
 		_ = db.Close()
 		return err
@@ -920,8 +959,10 @@ func (a *App) ensureSchema(db *sql.DB) error {
 			created_at INTEGER NOT NULL
 		);`,
 		`CREATE TABLE IF NOT EXISTS sub_subscriptions (
-			sub_id TEXT PRIMARY KEY,
+			pubkey TEXT NOT NULL DEFAULT '',
+			sub_id TEXT NOT NULL,
 			subscribed_at INTEGER NOT NULL,
+			PRIMARY KEY (pubkey, sub_id),
 			FOREIGN KEY(sub_id) REFERENCES subs(id) ON DELETE CASCADE
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_sub_subscriptions_subscribed_at ON sub_subscriptions(subscribed_at DESC);`,
@@ -973,6 +1014,8 @@ func (a *App) ensureSchema(db *sql.DB) error {
 			deleted_by TEXT NOT NULL DEFAULT ''
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_comments_post_timestamp ON comments(post_id, timestamp);`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_post_parent_timestamp ON comments(post_id, parent_id, timestamp);`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_post_parent_score ON comments(post_id, parent_id, score);`,
 		`CREATE TABLE IF NOT EXISTS entity_ops (
 			op_id TEXT PRIMARY KEY,
 			entity_type TEXT NOT NULL,
@@ -987,6 +1030,15 @@ func (a *App) ensureSchema(db *sql.DB) error {
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_entity_ops_entity_lamport ON entity_ops(entity_type, entity_id, lamport DESC, op_id DESC);`,
 		`CREATE INDEX IF NOT EXISTS idx_entity_ops_timestamp ON entity_ops(timestamp DESC);`,
+		`CREATE TABLE IF NOT EXISTS entity_signatures (
+			op_id TEXT PRIMARY KEY,
+			entity_type TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			signer_pubkey TEXT NOT NULL,
+			signature TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_entity_signatures_entity ON entity_signatures(entity_type, entity_id);`,
 		`CREATE TABLE IF NOT EXISTS tombstone_gc_marks (
 			entity_type TEXT NOT NULL,
 			entity_id TEXT NOT NULL,
@@ -1034,13 +1086,22 @@ func (a *App) ensureSchema(db *sql.DB) error {
 			op_id TEXT PRIMARY KEY,
 			created_at INTEGER NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS vote_clocks (
+			target_type TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			voter_pubkey TEXT NOT NULL,
+			voted_at INTEGER NOT NULL,
+			op_id TEXT NOT NULL,
+			PRIMARY KEY (target_type, target_id, voter_pubkey)
+		);`,
 		`CREATE TABLE IF NOT EXISTS moderation (
 			target_pubkey TEXT PRIMARY KEY,
 			action TEXT NOT NULL,
 			source_admin TEXT NOT NULL,
 			timestamp INTEGER NOT NULL,
 			lamport INTEGER NOT NULL DEFAULT 0,
-			reason TEXT NOT NULL DEFAULT ''
+			reason TEXT NOT NULL DEFAULT '',
+			signature TEXT NOT NULL DEFAULT ''
 		);`,
 		`CREATE TABLE IF NOT EXISTS moderation_logs (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -1050,7 +1111,8 @@ func (a *App) ensureSchema(db *sql.DB) error {
 			timestamp INTEGER NOT NULL,
 			lamport INTEGER NOT NULL DEFAULT 0,
 			reason TEXT NOT NULL DEFAULT '',
-			result TEXT NOT NULL DEFAULT 'applied'
+			result TEXT NOT NULL DEFAULT 'applied',
+			signature TEXT NOT NULL DEFAULT ''
 		);`,
 		`CREATE INDEX IF NOT EXISTS idx_moderation_logs_timestamp ON moderation_logs(timestamp DESC);`,
 		`CREATE TABLE IF NOT EXISTS governance_config (
@@ -1094,12 +1156,40 @@ func (a *App) ensureSchema(db *sql.DB) error {
 		`CREATE TABLE IF NOT EXISTS governance_admins (
 			admin_pubkey TEXT PRIMARY KEY,
 			role TEXT NOT NULL,
-			active INTEGER NOT NULL DEFAULT 1
+			active INTEGER NOT NULL DEFAULT 1,
+			source TEXT NOT NULL DEFAULT 'local'
+		);`,
+		`CREATE TABLE IF NOT EXISTS governance_delegations (
+			op_id TEXT PRIMARY KEY,
+			grantor_pubkey TEXT NOT NULL,
+			grantee_pubkey TEXT NOT NULL,
+			action TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT '',
+			timestamp INTEGER NOT NULL,
+			lamport INTEGER NOT NULL,
+			signature TEXT NOT NULL,
+			received_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_governance_delegations_lamport ON governance_delegations(lamport ASC, timestamp ASC, op_id ASC);`,
+		`CREATE TABLE IF NOT EXISTS governance_delegations_pending (
+			op_id TEXT PRIMARY KEY,
+			grantor_pubkey TEXT NOT NULL,
+			grantee_pubkey TEXT NOT NULL,
+			action TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT '',
+			timestamp INTEGER NOT NULL,
+			lamport INTEGER NOT NULL,
+			signature TEXT NOT NULL,
+			received_at INTEGER NOT NULL
 		);`,
 		`CREATE TABLE IF NOT EXISTS local_identity (
-			id INTEGER PRIMARY KEY CHECK (id = 1),
-			mnemonic TEXT NOT NULL,
-			pubkey TEXT NOT NULL,
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			pubkey TEXT NOT NULL UNIQUE,
+			label TEXT NOT NULL DEFAULT '',
+			mnemonic TEXT NOT NULL DEFAULT '',
+			sealed_mnemonic TEXT NOT NULL DEFAULT '',
+			is_active INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL DEFAULT 0,
 			updated_at INTEGER NOT NULL
 		);`,
 		`CREATE TABLE IF NOT EXISTS logical_clock (
@@ -1107,6 +1197,79 @@ func (a *App) ensureSchema(db *sql.DB) error {
 			value INTEGER NOT NULL,
 			updated_at INTEGER NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS metric_history (
+			metric TEXT NOT NULL,
+			resolution_sec INTEGER NOT NULL,
+			bucket_ts INTEGER NOT NULL,
+			sample_count INTEGER NOT NULL,
+			value_sum REAL NOT NULL,
+			value_min REAL NOT NULL,
+			value_max REAL NOT NULL,
+			PRIMARY KEY (metric, resolution_sec, bucket_ts)
+		);`,
+		`CREATE TABLE IF NOT EXISTS release_alert_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			alert_key TEXT NOT NULL,
+			metric TEXT NOT NULL,
+			level TEXT NOT NULL,
+			event TEXT NOT NULL,
+			value REAL NOT NULL,
+			threshold REAL NOT NULL,
+			window_sec INTEGER NOT NULL,
+			at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_release_alert_events_at ON release_alert_events(at);`,
+		`CREATE TABLE IF NOT EXISTS storage_quotas (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			total_bytes INTEGER NOT NULL,
+			private_bytes INTEGER NOT NULL,
+			public_bytes INTEGER NOT NULL,
+			media_bytes INTEGER NOT NULL,
+			pinned_bytes INTEGER NOT NULL DEFAULT 0,
+			updated_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS storage_evictions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			at INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			content_cid TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			scope TEXT NOT NULL,
+			policy TEXT NOT NULL,
+			score REAL NOT NULL,
+			post_id TEXT NOT NULL DEFAULT '',
+			sub_id TEXT NOT NULL DEFAULT '',
+			reason TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS pinned_posts (
+			post_id TEXT PRIMARY KEY,
+			pinned_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS pinned_subs (
+			sub_id TEXT PRIMARY KEY,
+			pinned_at INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS entity_revisions (
+			op_id TEXT PRIMARY KEY,
+			entity_type TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			author_pubkey TEXT NOT NULL,
+			lamport INTEGER NOT NULL,
+			timestamp INTEGER NOT NULL,
+			content_cid TEXT NOT NULL DEFAULT '',
+			digest_json TEXT NOT NULL DEFAULT '{}',
+			recorded_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_entity_revisions_entity ON entity_revisions(entity_type, entity_id, lamport);`,
+		`CREATE INDEX IF NOT EXISTS idx_entity_revisions_content ON entity_revisions(content_cid);`,
+		`CREATE TABLE IF NOT EXISTS maintenance_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			triggered_by TEXT NOT NULL,
+			started_at INTEGER NOT NULL,
+			finished_at INTEGER NOT NULL,
+			error_count INTEGER NOT NULL DEFAULT 0,
+			result_json TEXT NOT NULL DEFAULT '{}'
+		);`,
 	}
 
 	for _, statement := range schema {
@@ -1199,6 +1362,18 @@ func (a *App) ensureSchema(db *sql.DB) error {
 		}
 	}
 
+	if _, err := db.Exec(`ALTER TABLE media_blobs ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;`); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+			return err
+		}
+	}
+
+	if _, err := db.Exec(`ALTER TABLE storage_quotas ADD COLUMN pinned_bytes INTEGER NOT NULL DEFAULT 0;`); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+			return err
+		}
+	}
+
 	if _, err := db.Exec(`ALTER TABLE messages ADD COLUMN score INTEGER NOT NULL DEFAULT 0;`); err != nil {
 		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
 			return err
@@ -1305,6 +1480,34 @@ func (a *App) ensureSchema(db *sql.DB) error {
 		}
 	}
 
+	if _, err := db.Exec(`ALTER TABLE moderation ADD COLUMN signature TEXT NOT NULL DEFAULT '';`); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+			return err
+		}
+	}
+
+	if _, err := db.Exec(`ALTER TABLE moderation_logs ADD COLUMN signature TEXT NOT NULL DEFAULT '';`); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+			return err
+		}
+	}
+
+	if _, err := db.Exec(`ALTER TABLE governance_admins ADD COLUMN source TEXT NOT NULL DEFAULT 'local';`); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+			return err
+		}
+	}
+
+	if _, err := db.Exec(`ALTER TABLE local_identity ADD COLUMN sealed_mnemonic TEXT NOT NULL DEFAULT '';`); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column name") {
+			return err
+		}
+	}
+
+	if err := migrateLocalIdentityTables(db); err != nil {
+		return err
+	}
+
 	if _, err := db.Exec(`UPDATE messages SET sub_id = ? WHERE COALESCE(TRIM(sub_id), '') = '';`, defaultSubID); err != nil {
 		return err
 	}
@@ -1469,6 +1672,10 @@ func (a *App) ensureSchema(db *sql.DB) error {
 		return err
 	}
 
+	if err := ensureSearchIndex(db); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -2073,14 +2280,31 @@ func (a *App) upsertMediaBlobRaw(contentCID string, mime string, data []byte, wi
 	if contentCID == "" || len(data) == 0 {
 		return errors.New("invalid media blob")
 	}
+	if err := verifyBlobCID(blobKindMedia, contentCID, data); err != nil {
+		return err
+	}
 
 	thumbFlag := 0
 	if isThumbnail {
 		thumbFlag = 1
 	}
 
+	quotas := a.currentStorageQuotas()
 	now := time.Now().Unix()
-	_, err := a.db.Exec(`
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = a.newStorageEvictor(tx, quotas).ensure(storageWrite{MediaCID: contentCID, MediaBytes: int64(len(data))}); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`
 		INSERT INTO media_blobs (content_cid, data, mime, size_bytes, width, height, is_thumbnail, created_at, last_accessed_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(content_cid) DO UPDATE SET
@@ -2091,8 +2315,13 @@ func (a *App) upsertMediaBlobRaw(contentCID string, mime string, data []byte, wi
 			height = excluded.height,
 			is_thumbnail = excluded.is_thumbnail,
 			last_accessed_at = excluded.last_accessed_at;
-	`, contentCID, data, mime, int64(len(data)), width, height, thumbFlag, now, now)
-	return err
+	`, contentCID, data, mime, int64(len(data)), width, height, thumbFlag, now, now); err != nil {
+		return err
+	}
+	if err = pinArrivedBlobsTx(tx, quotas, storageBlobKindMedia, contentCID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (a *App) getContentBlobLocal(contentCID string) (PostBodyBlob, error) {
@@ -2253,20 +2482,63 @@ func (a *App) upsertContentBlob(contentCID string, body string, sizeBytes int64)
 	if contentCID == "" || body == "" {
 		return errors.New("invalid content blob")
 	}
+	if err := verifyBlobCID(blobKindContent, contentCID, []byte(body)); err != nil {
+		return err
+	}
 	if sizeBytes <= 0 {
 		sizeBytes = int64(len([]byte(body)))
 	}
 
+	quotas := a.currentStorageQuotas()
 	now := time.Now().Unix()
-	_, err := a.db.Exec(`
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A fetched blob counts toward the zone of the posts that reference it,
+	// and toward the public zone when none is indexed yet.
+	zone := "public"
+	var privateRefs, publicRefs int
+	if err = tx.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN zone = 'private' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN zone = 'private' THEN 0 ELSE 1 END), 0)
+		FROM messages
+		WHERE content_cid = ?;
+	`, contentCID).Scan(&privateRefs, &publicRefs); err != nil {
+		return err
+	}
+	if privateRefs > 0 && publicRefs == 0 {
+		zone = "private"
+	}
+
+	if err = a.newStorageEvictor(tx, quotas).ensure(storageWrite{Zone: zone, ContentCID: contentCID, ContentBytes: sizeBytes}); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`
 		INSERT INTO content_blobs (content_cid, body, size_bytes, created_at, last_accessed_at)
 		VALUES (?, ?, ?, ?, ?)
 		ON CONFLICT(content_cid) DO UPDATE SET
 			body = excluded.body,
 			size_bytes = excluded.size_bytes,
 			last_accessed_at = excluded.last_accessed_at;
-	`, contentCID, body, sizeBytes, now, now)
-	return err
+	`, contentCID, body, sizeBytes, now, now); err != nil {
+		return err
+	}
+	if err = pinArrivedBlobsTx(tx, quotas, storageBlobKindContent, contentCID); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	a.reindexPostsWithContent(contentCID)
+	return nil
 }
 
 func (a *App) listRecentPublicPostDigests(limit int) ([]SyncPostDigest, error) {
@@ -2306,10 +2578,21 @@ func (a *App) listRecentPublicPostDigests(limit int) ([]SyncPostDigest, error) {
 		result = append(result, digest)
 	}
 
-	return result, rows.Err()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = a.attachPostDigestSignatures(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 func (a *App) getLatestPublicPostTimestamp() (int64, error) {
+	return a.getLatestPublicPostTimestampForSub("")
+}
+
+func (a *App) getLatestPublicPostTimestampForSub(subID string) (int64, error) {
 	if a.db == nil {
 		return 0, errors.New("database not initialized")
 	}
@@ -2318,8 +2601,8 @@ func (a *App) getLatestPublicPostTimestamp() (int64, error) {
 	if err := a.db.QueryRow(`
 		SELECT MAX(timestamp)
 		FROM messages
-		WHERE zone = 'public' AND visibility = 'normal';
-	`).Scan(&latest); err != nil {
+		WHERE zone = 'public' AND visibility = 'normal' AND (? = '' OR sub_id = ?);
+	`, subID, subID).Scan(&latest); err != nil {
 		return 0, err
 	}
 
@@ -2331,6 +2614,10 @@ func (a *App) getLatestPublicPostTimestamp() (int64, error) {
 }
 
 func (a *App) getLatestPublicCommentTimestamp() (int64, error) {
+	return a.getLatestPublicCommentTimestampForSub("")
+}
+
+func (a *App) getLatestPublicCommentTimestampForSub(subID string) (int64, error) {
 	if a.db == nil {
 		return 0, errors.New("database not initialized")
 	}
@@ -2340,8 +2627,8 @@ func (a *App) getLatestPublicCommentTimestamp() (int64, error) {
 		SELECT MAX(c.timestamp)
 		FROM comments c
 		JOIN messages m ON m.id = c.post_id
-		WHERE m.zone = 'public' AND m.visibility = 'normal' AND c.deleted_at = 0;
-	`).Scan(&latest); err != nil {
+		WHERE m.zone = 'public' AND m.visibility = 'normal' AND c.deleted_at = 0 AND (? = '' OR m.sub_id = ?);
+	`, subID, subID).Scan(&latest); err != nil {
 		return 0, err
 	}
 
@@ -2353,6 +2640,12 @@ func (a *App) getLatestPublicCommentTimestamp() (int64, error) {
 }
 
 func (a *App) listPublicPostDigestsSince(sinceTimestamp int64, limit int) ([]SyncPostDigest, error) {
+	return a.listPublicPostDigestsForSubSince("", sinceTimestamp, limit)
+}
+
+// listPublicPostDigestsForSubSince lists post digests of subID, or of every
+// sub when subID is empty.
+func (a *App) listPublicPostDigestsForSubSince(subID string, sinceTimestamp int64, limit int) ([]SyncPostDigest, error) {
 	if a.db == nil {
 		return nil, errors.New("database not initialized")
 	}
@@ -2374,17 +2667,17 @@ func (a *App) listPublicPostDigestsSince(sinceTimestamp int64, limit int) ([]Syn
 				SELECT m.id, m.pubkey, m.current_op_id, m.visibility, m.deleted_at_lamport, m.title, m.content_cid, m.image_cid, m.thumb_cid, m.image_mime, m.image_size, m.image_width, m.image_height, m.timestamp, m.lamport, m.sub_id
 				FROM messages m
 				LEFT JOIN moderation mo ON mo.target_pubkey = m.pubkey
-				WHERE m.zone = 'public' AND m.timestamp >= ?
+				WHERE m.zone = 'public' AND m.timestamp >= ? AND (? = '' OR m.sub_id = ?)
 				  AND (mo.action IS NULL OR UPPER(mo.action) != 'SHADOW_BAN')
 				ORDER BY m.timestamp ASC
 				LIMIT ?;
-			`, sinceTimestamp, limit)
+			`, sinceTimestamp, subID, subID, limit)
 		} else {
 			rows, err = a.db.Query(`
 				SELECT m.id, m.pubkey, m.current_op_id, m.visibility, m.deleted_at_lamport, m.title, m.content_cid, m.image_cid, m.thumb_cid, m.image_mime, m.image_size, m.image_width, m.image_height, m.timestamp, m.lamport, m.sub_id
 				FROM messages m
 				LEFT JOIN moderation mo ON mo.target_pubkey = m.pubkey
-				WHERE m.zone = 'public' AND m.timestamp >= ?
+				WHERE m.zone = 'public' AND m.timestamp >= ? AND (? = '' OR m.sub_id = ?)
 				  AND (
 					mo.action IS NULL
 					OR UPPER(mo.action) != 'SHADOW_BAN'
@@ -2393,7 +2686,7 @@ func (a *App) listPublicPostDigestsSince(sinceTimestamp int64, limit int) ([]Syn
 				  )
 				ORDER BY m.timestamp ASC
 				LIMIT ?;
-			`, sinceTimestamp, limit)
+			`, sinceTimestamp, subID, subID, limit)
 		}
 	} else {
 		if policy.HideHistoryOnShadowBan {
@@ -2401,17 +2694,17 @@ func (a *App) listPublicPostDigestsSince(sinceTimestamp int64, limit int) ([]Syn
 				SELECT m.id, m.pubkey, m.current_op_id, m.visibility, m.deleted_at_lamport, m.title, m.content_cid, m.image_cid, m.thumb_cid, m.image_mime, m.image_size, m.image_width, m.image_height, m.timestamp, m.lamport, m.sub_id
 				FROM messages m
 				LEFT JOIN moderation mo ON mo.target_pubkey = m.pubkey
-				WHERE m.zone = 'public'
+				WHERE m.zone = 'public' AND (? = '' OR m.sub_id = ?)
 				  AND (mo.action IS NULL OR UPPER(mo.action) != 'SHADOW_BAN')
 				ORDER BY m.timestamp DESC
 				LIMIT ?;
-			`, limit)
+			`, subID, subID, limit)
 		} else {
 			rows, err = a.db.Query(`
 				SELECT m.id, m.pubkey, m.current_op_id, m.visibility, m.deleted_at_lamport, m.title, m.content_cid, m.image_cid, m.thumb_cid, m.image_mime, m.image_size, m.image_width, m.image_height, m.timestamp, m.lamport, m.sub_id
 				FROM messages m
 				LEFT JOIN moderation mo ON mo.target_pubkey = m.pubkey
-				WHERE m.zone = 'public'
+				WHERE m.zone = 'public' AND (? = '' OR m.sub_id = ?)
 				  AND (
 					mo.action IS NULL
 					OR UPPER(mo.action) != 'SHADOW_BAN'
@@ -2420,7 +2713,7 @@ func (a *App) listPublicPostDigestsSince(sinceTimestamp int64, limit int) ([]Syn
 				  )
 				ORDER BY m.timestamp DESC
 				LIMIT ?;
-			`, limit)
+			`, subID, subID, limit)
 		}
 	}
 	if err != nil {
@@ -2428,11 +2721,17 @@ func (a *App) listPublicPostDigestsSince(sinceTimestamp int64, limit int) ([]Syn
 	}
 	defer rows.Close()
 
-	result := make([]SyncPostDigest, 0, limit)
+	return a.scanPostDigestRows(rows, limit)
+}
+
+// scanPostDigestRows reads rows selected with the column list shared by the
+// post digest queries and attaches the stored operation signatures.
+func (a *App) scanPostDigestRows(rows *sql.Rows, capacity int) ([]SyncPostDigest, error) {
+	result := make([]SyncPostDigest, 0, capacity)
 	for rows.Next() {
 		var digest SyncPostDigest
 		var visibility string
-		if err = rows.Scan(&digest.ID, &digest.Pubkey, &digest.OpID, &visibility, &digest.DeletedAtLamport, &digest.Title, &digest.ContentCID, &digest.ImageCID, &digest.ThumbCID, &digest.ImageMIME, &digest.ImageSize, &digest.ImageWidth, &digest.ImageHeight, &digest.Timestamp, &digest.Lamport, &digest.SubID); err != nil {
+		if err := rows.Scan(&digest.ID, &digest.Pubkey, &digest.OpID, &visibility, &digest.DeletedAtLamport, &digest.Title, &digest.ContentCID, &digest.ImageCID, &digest.ThumbCID, &digest.ImageMIME, &digest.ImageSize, &digest.ImageWidth, &digest.ImageHeight, &digest.Timestamp, &digest.Lamport, &digest.SubID); err != nil {
 			return nil, err
 		}
 		digest.Deleted = strings.EqualFold(strings.TrimSpace(visibility), "deleted")
@@ -2445,10 +2744,77 @@ func (a *App) listPublicPostDigestsSince(sinceTimestamp int64, limit int) ([]Syn
 		result = append(result, digest)
 	}
 
-	return result, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := a.attachPostDigestSignatures(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// listPublicPostDigestsByIDs returns the digests of the given public posts,
+// applying the same shadow-ban rules as the timestamp-window queries.
+func (a *App) listPublicPostDigestsByIDs(ids []string) ([]SyncPostDigest, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if len(ids) == 0 {
+		return []SyncPostDigest{}, nil
+	}
+
+	policy, err := a.GetGovernancePolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := a.db.Query(`
+		SELECT m.id, m.pubkey, m.current_op_id, m.visibility, m.deleted_at_lamport, m.title, m.content_cid, m.image_cid, m.thumb_cid, m.image_mime, m.image_size, m.image_width, m.image_height, m.timestamp, m.lamport, m.sub_id
+		FROM messages m
+		LEFT JOIN moderation mo ON mo.target_pubkey = m.pubkey
+		WHERE m.zone = 'public' AND m.id IN (`+sqlPlaceholders(len(ids))+`)
+		  AND `+shadowBanVisibleClause(policy, "m")+`
+		ORDER BY m.lamport ASC, m.id ASC;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return a.scanPostDigestRows(rows, len(ids))
+}
+
+// shadowBanVisibleClause filters rows of alias joined against moderation as
+// mo down to what the governance policy lets peers see.
+func shadowBanVisibleClause(policy GovernancePolicy, alias string) string {
+	if policy.HideHistoryOnShadowBan {
+		return `(mo.action IS NULL OR UPPER(mo.action) != 'SHADOW_BAN')`
+	}
+	return `(
+			mo.action IS NULL
+			OR UPPER(mo.action) != 'SHADOW_BAN'
+			OR ` + alias + `.lamport < mo.lamport
+			OR (` + alias + `.lamport = 0 OR mo.lamport = 0) AND ` + alias + `.timestamp < mo.timestamp
+		  )`
+}
+
+func sqlPlaceholders(count int) string {
+	if count <= 0 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", count), ",")
 }
 
 func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]SyncCommentDigest, error) {
+	return a.listPublicCommentDigestsForSubSince("", sinceTimestamp, limit)
+}
+
+func (a *App) listPublicCommentDigestsForSubSince(subID string, sinceTimestamp int64, limit int) ([]SyncCommentDigest, error) {
 	if a.db == nil {
 		return nil, errors.New("database not initialized")
 	}
@@ -2475,11 +2841,11 @@ func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]
 				JOIN messages m ON m.id = c.post_id
 				LEFT JOIN profiles p ON p.pubkey = c.pubkey
 				LEFT JOIN moderation mo ON mo.target_pubkey = c.pubkey
-				WHERE m.zone = 'public' AND c.timestamp >= ?
+				WHERE m.zone = 'public' AND c.timestamp >= ? AND (? = '' OR m.sub_id = ?)
 				  AND (mo.action IS NULL OR UPPER(mo.action) != 'SHADOW_BAN')
 				ORDER BY c.timestamp ASC
 				LIMIT ?;
-			`, sinceTimestamp, limit)
+			`, sinceTimestamp, subID, subID, limit)
 		} else {
 			rows, err = a.db.Query(`
 				SELECT c.id, c.post_id, c.parent_id, c.pubkey, c.current_op_id, c.body, c.attachments_json, c.score, c.timestamp, c.lamport, c.deleted_at_lamport, c.deleted_at,
@@ -2488,7 +2854,7 @@ func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]
 				JOIN messages m ON m.id = c.post_id
 				LEFT JOIN profiles p ON p.pubkey = c.pubkey
 				LEFT JOIN moderation mo ON mo.target_pubkey = c.pubkey
-				WHERE m.zone = 'public' AND c.timestamp >= ?
+				WHERE m.zone = 'public' AND c.timestamp >= ? AND (? = '' OR m.sub_id = ?)
 				  AND (
 					mo.action IS NULL
 					OR UPPER(mo.action) != 'SHADOW_BAN'
@@ -2497,7 +2863,7 @@ func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]
 				  )
 				ORDER BY c.timestamp ASC
 				LIMIT ?;
-			`, sinceTimestamp, limit)
+			`, sinceTimestamp, subID, subID, limit)
 		}
 	} else {
 		if policy.HideHistoryOnShadowBan {
@@ -2508,11 +2874,11 @@ func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]
 				JOIN messages m ON m.id = c.post_id
 				LEFT JOIN profiles p ON p.pubkey = c.pubkey
 				LEFT JOIN moderation mo ON mo.target_pubkey = c.pubkey
-				WHERE m.zone = 'public'
+				WHERE m.zone = 'public' AND (? = '' OR m.sub_id = ?)
 				  AND (mo.action IS NULL OR UPPER(mo.action) != 'SHADOW_BAN')
 				ORDER BY c.timestamp DESC
 				LIMIT ?;
-			`, limit)
+			`, subID, subID, limit)
 		} else {
 			rows, err = a.db.Query(`
 				SELECT c.id, c.post_id, c.parent_id, c.pubkey, c.current_op_id, c.body, c.attachments_json, c.score, c.timestamp, c.lamport, c.deleted_at_lamport, c.deleted_at,
@@ -2521,7 +2887,7 @@ func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]
 				JOIN messages m ON m.id = c.post_id
 				LEFT JOIN profiles p ON p.pubkey = c.pubkey
 				LEFT JOIN moderation mo ON mo.target_pubkey = c.pubkey
-				WHERE m.zone = 'public'
+				WHERE m.zone = 'public' AND (? = '' OR m.sub_id = ?)
 				  AND (
 					mo.action IS NULL
 					OR UPPER(mo.action) != 'SHADOW_BAN'
@@ -2530,7 +2896,7 @@ func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]
 				  )
 				ORDER BY c.timestamp DESC
 				LIMIT ?;
-			`, limit)
+			`, subID, subID, limit)
 		}
 	}
 	if err != nil {
@@ -2538,13 +2904,19 @@ func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]
 	}
 	defer rows.Close()
 
-	result := make([]SyncCommentDigest, 0, limit)
+	return a.scanCommentDigestRows(rows, limit)
+}
+
+// scanCommentDigestRows reads rows selected with the column list shared by
+// the comment digest queries and attaches the stored operation signatures.
+func (a *App) scanCommentDigestRows(rows *sql.Rows, capacity int) ([]SyncCommentDigest, error) {
+	result := make([]SyncCommentDigest, 0, capacity)
 	for rows.Next() {
 		var item SyncCommentDigest
 		var attachmentsJSON string
 		var deletedAtLamport int64
 		var deletedAt int64
-		if err = rows.Scan(
+		if err := rows.Scan(
 			&item.ID,
 			&item.PostID,
 			&item.ParentID,
@@ -2575,7 +2947,52 @@ func (a *App) listPublicCommentDigestsSince(sinceTimestamp int64, limit int) ([]
 		result = append(result, item)
 	}
 
-	return result, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := a.attachCommentDigestSignatures(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// listPublicCommentDigestsByIDs returns the digests of the given comments on
+// public posts, applying the same shadow-ban rules as the window queries.
+func (a *App) listPublicCommentDigestsByIDs(ids []string) ([]SyncCommentDigest, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if len(ids) == 0 {
+		return []SyncCommentDigest{}, nil
+	}
+
+	policy, err := a.GetGovernancePolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := a.db.Query(`
+		SELECT c.id, c.post_id, c.parent_id, c.pubkey, c.current_op_id, c.body, c.attachments_json, c.score, c.timestamp, c.lamport, c.deleted_at_lamport, c.deleted_at,
+		       COALESCE(p.display_name, ''), COALESCE(p.avatar_url, '')
+		FROM comments c
+		JOIN messages m ON m.id = c.post_id
+		LEFT JOIN profiles p ON p.pubkey = c.pubkey
+		LEFT JOIN moderation mo ON mo.target_pubkey = c.pubkey
+		WHERE m.zone = 'public' AND c.id IN (`+sqlPlaceholders(len(ids))+`)
+		  AND `+shadowBanVisibleClause(policy, "c")+`
+		ORDER BY c.lamport ASC, c.id ASC;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return a.scanCommentDigestRows(rows, len(ids))
 }
 
 func (a *App) listPublicCommentDigestsByPostSince(postID string, sinceTimestamp int64, limit int) ([]SyncCommentDigest, error) {
@@ -2672,7 +3089,14 @@ func (a *App) listPublicCommentDigestsByPostSince(postID string, sinceTimestamp
 		result = append(result, item)
 	}
 
-	return result, rows.Err()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = a.attachCommentDigestSignatures(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 func (a *App) getLatestFavoriteOpTimestamp(pubkey string) (int64, error) {
@@ -2880,6 +3304,7 @@ func (a *App) upsertPublicPostIndexFromDigest(digest SyncPostDigest) (bool, erro
 			); logErr != nil {
 				return false, logErr
 			}
+			a.reindexForSearch(searchKindPost, digest.ID)
 		}
 		return affected > 0, nil
 	}
@@ -2942,6 +3367,7 @@ func (a *App) upsertPublicPostIndexFromDigest(digest SyncPostDigest) (bool, erro
 		); logErr != nil {
 			return false, logErr
 		}
+		a.reindexForSearch(searchKindPost, digest.ID)
 	}
 
 	return affected > 0, nil
@@ -3053,51 +3479,15 @@ func (a *App) GetCommentsByPost(postID string) ([]Comment, error) {
 		return nil, errors.New("post id is required")
 	}
 
-	viewerPubkey := ""
-	if identity, err := a.getLocalIdentity(); err == nil {
-		viewerPubkey = strings.TrimSpace(identity.PublicKey)
-	}
-
-	policy, policyErr := a.GetGovernancePolicy()
-	hideHistoryOnShadowBan := true
-	if policyErr == nil {
-		hideHistoryOnShadowBan = policy.HideHistoryOnShadowBan
-	}
-
+	visible, visibleArgs := a.currentCommentView().clause("c", "m")
 	query := `
 		SELECT c.id, c.post_id, c.parent_id, c.pubkey, c.body, c.attachments_json, c.score, c.timestamp, c.lamport
 		FROM comments c
 		LEFT JOIN moderation m ON m.target_pubkey = c.pubkey
-	`
-	args := []interface{}{}
-	query += `
-		WHERE c.post_id = ? AND c.deleted_at = 0
-	`
-	args = append(args, postID)
-	if hideHistoryOnShadowBan {
-		query += `
-		  AND (
-			m.action IS NULL
-			OR UPPER(m.action) != 'SHADOW_BAN'
-			OR c.pubkey = ?
-		  )
-		`
-		args = append(args, viewerPubkey)
-	} else {
-		query += `
-		  AND (
-			m.action IS NULL
-			OR UPPER(m.action) != 'SHADOW_BAN'
-			OR c.pubkey = ?
-			OR c.lamport < m.lamport
-			OR (c.lamport = 0 OR m.lamport = 0) AND c.timestamp < m.timestamp
-		  )
-		`
-		args = append(args, viewerPubkey)
-	}
-	query += `
+		WHERE c.post_id = ? AND ` + visible + `
 		ORDER BY c.timestamp ASC;
 	`
+	args := append([]interface{}{postID}, visibleArgs...)
 
 	rows, err := a.db.Query(query, args...)
 	if err != nil {
@@ -3178,10 +3568,10 @@ func (a *App) SubscribeSub(subID string) (Sub, error) {
 	}
 
 	result, err := a.db.Exec(`
-		INSERT INTO sub_subscriptions (sub_id, subscribed_at)
-		VALUES (?, ?)
-		ON CONFLICT(sub_id) DO NOTHING;
-	`, subID, now)
+		INSERT INTO sub_subscriptions (pubkey, sub_id, subscribed_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(pubkey, sub_id) DO NOTHING;
+	`, a.activeIdentityPubkey(), subID, now)
 	if err != nil {
 		return Sub{}, err
 	}
@@ -3189,6 +3579,7 @@ func (a *App) SubscribeSub(subID string) (Sub, error) {
 	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 && a.ctx != nil {
 		runtime.EventsEmit(a.ctx, "subs:subscriptions_updated")
 	}
+	a.syncSubTopicMembership(subID)
 
 	return sub, nil
 }
@@ -3199,7 +3590,7 @@ func (a *App) UnsubscribeSub(subID string) error {
 	}
 
 	subID = normalizeSubID(subID)
-	result, err := a.db.Exec(`DELETE FROM sub_subscriptions WHERE sub_id = ?;`, subID)
+	result, err := a.db.Exec(`DELETE FROM sub_subscriptions WHERE pubkey = ? AND sub_id = ?;`, a.activeIdentityPubkey(), subID)
 	if err != nil {
 		return err
 	}
@@ -3207,6 +3598,7 @@ func (a *App) UnsubscribeSub(subID string) error {
 	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 && a.ctx != nil {
 		runtime.EventsEmit(a.ctx, "subs:subscriptions_updated")
 	}
+	a.syncSubTopicMembership(subID)
 
 	return nil
 }
@@ -3220,8 +3612,9 @@ func (a *App) GetSubscribedSubs() ([]Sub, error) {
 		SELECT s.id, s.title, s.description, s.created_at
 		FROM sub_subscriptions ss
 		INNER JOIN subs s ON s.id = ss.sub_id
+		WHERE ss.pubkey = ?
 		ORDER BY ss.subscribed_at DESC, s.id ASC;
-	`)
+	`, a.activeIdentityPubkey())
 	if err != nil {
 		return nil, err
 	}
@@ -3285,69 +3678,26 @@ func (a *App) SearchSubs(keyword string, limit int) ([]Sub, error) {
 	return result, rows.Err()
 }
 
+// SearchPosts returns the public posts matching keyword, best match first.
+// It is Search limited to posts, for callers that want full post rows.
 func (a *App) SearchPosts(keyword string, subID string, limit int) ([]ForumMessage, error) {
 	if a.db == nil {
 		return nil, errors.New("database not initialized")
 	}
 
-	keyword = strings.TrimSpace(keyword)
-	if keyword == "" {
-		return []ForumMessage{}, nil
-	}
-	limit = normalizeSearchLimit(limit)
-
-	viewerPubkey := ""
-	if identity, err := a.getLocalIdentity(); err == nil {
-		viewerPubkey = strings.TrimSpace(identity.PublicKey)
-	}
-
-	lowerKeyword := strings.ToLower(keyword)
-	pattern := "%" + lowerKeyword + "%"
-
-	subID = strings.TrimSpace(subID)
-	var rows *sql.Rows
-	var err error
-	if subID != "" {
-		rows, err = a.db.Query(`
-			SELECT m.id, m.pubkey, m.title, m.body, m.content_cid, m.content, m.score, m.timestamp, m.size_bytes, m.zone, m.sub_id, m.is_protected, m.visibility
-			FROM messages m
-			LEFT JOIN content_blobs cb ON cb.content_cid = m.content_cid
-			WHERE m.zone = 'public'
-			  AND (m.visibility = 'normal' OR m.pubkey = ?)
-			  AND m.sub_id = ?
-			  AND (
-				LOWER(m.title) LIKE ?
-				OR LOWER(m.body) LIKE ?
-				OR LOWER(COALESCE(cb.body, '')) LIKE ?
-			  )
-			ORDER BY m.timestamp DESC
-			LIMIT ?;
-		`, viewerPubkey, normalizeSubID(subID), pattern, pattern, pattern, limit)
-	} else {
-		rows, err = a.db.Query(`
-			SELECT m.id, m.pubkey, m.title, m.body, m.content_cid, m.content, m.score, m.timestamp, m.size_bytes, m.zone, m.sub_id, m.is_protected, m.visibility
-			FROM messages m
-			LEFT JOIN content_blobs cb ON cb.content_cid = m.content_cid
-			WHERE m.zone = 'public'
-			  AND (m.visibility = 'normal' OR m.pubkey = ?)
-			  AND (
-				LOWER(m.title) LIKE ?
-				OR LOWER(m.body) LIKE ?
-				OR LOWER(COALESCE(cb.body, '')) LIKE ?
-			  )
-			ORDER BY m.timestamp DESC
-			LIMIT ?;
-		`, viewerPubkey, pattern, pattern, pattern, limit)
-	}
+	page, err := a.Search(SearchRequest{Query: keyword, Kind: searchKindPost, SubID: subID, Limit: limit})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	result := make([]ForumMessage, 0, limit)
-	for rows.Next() {
+	result := make([]ForumMessage, 0, len(page.Hits))
+	for _, hit := range page.Hits {
 		var message ForumMessage
-		if err := rows.Scan(
+		err := a.db.QueryRow(`
+			SELECT id, pubkey, title, body, content_cid, content, score, timestamp, size_bytes, zone, sub_id, is_protected, visibility
+			FROM messages
+			WHERE id = ?;
+		`, hit.PostID).Scan(
 			&message.ID,
 			&message.Pubkey,
 			&message.Title,
@@ -3361,13 +3711,17 @@ func (a *App) SearchPosts(keyword string, subID string, limit int) ([]ForumMessa
 			&message.SubID,
 			&message.IsProtected,
 			&message.Visibility,
-		); err != nil {
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
 			return nil, err
 		}
 		result = append(result, message)
 	}
 
-	return result, rows.Err()
+	return result, nil
 }
 
 func (a *App) UpdateProfile(displayName string, avatarURL string) (Profile, error) {
@@ -3583,7 +3937,7 @@ func (a *App) GetModerationState() ([]ModerationState, error) {
 	}
 
 	rows, err := a.db.Query(`
-		SELECT target_pubkey, action, source_admin, timestamp, lamport, reason
+		SELECT target_pubkey, action, source_admin, timestamp, lamport, reason, signature
 		FROM moderation
 		ORDER BY timestamp DESC;
 	`)
@@ -3595,7 +3949,7 @@ func (a *App) GetModerationState() ([]ModerationState, error) {
 	result := make([]ModerationState, 0)
 	for rows.Next() {
 		var state ModerationState
-		if err := rows.Scan(&state.TargetPubkey, &state.Action, &state.SourceAdmin, &state.Timestamp, &state.Lamport, &state.Reason); err != nil {
+		if err := rows.Scan(&state.TargetPubkey, &state.Action, &state.SourceAdmin, &state.Timestamp, &state.Lamport, &state.Reason, &state.Signature); err != nil {
 			return nil, err
 		}
 		result = append(result, state)
@@ -3632,7 +3986,7 @@ func (a *App) listModerationSince(sinceTimestamp int64, limit int) ([]Moderation
 	}
 
 	rows, err := a.db.Query(`
-		SELECT target_pubkey, action, source_admin, timestamp, lamport, reason
+		SELECT target_pubkey, action, source_admin, timestamp, lamport, reason, signature
 		FROM moderation
 		WHERE timestamp >= ?
 		ORDER BY timestamp ASC
@@ -3646,7 +4000,7 @@ func (a *App) listModerationSince(sinceTimestamp int64, limit int) ([]Moderation
 	result := make([]ModerationState, 0, limit)
 	for rows.Next() {
 		var row ModerationState
-		if err = rows.Scan(&row.TargetPubkey, &row.Action, &row.SourceAdmin, &row.Timestamp, &row.Lamport, &row.Reason); err != nil {
+		if err = rows.Scan(&row.TargetPubkey, &row.Action, &row.SourceAdmin, &row.Timestamp, &row.Lamport, &row.Reason, &row.Signature); err != nil {
 			return nil, err
 		}
 		result = append(result, row)
@@ -3683,7 +4037,7 @@ func (a *App) listAppliedModerationLogsSince(sinceTimestamp int64, limit int) ([
 	}
 
 	rows, err := a.db.Query(`
-		SELECT id, target_pubkey, action, source_admin, timestamp, lamport, reason, result
+		SELECT id, target_pubkey, action, source_admin, timestamp, lamport, reason, result, signature
 		FROM moderation_logs
 		WHERE result = 'applied' AND timestamp >= ?
 		ORDER BY timestamp ASC, id ASC
@@ -3697,7 +4051,7 @@ func (a *App) listAppliedModerationLogsSince(sinceTimestamp int64, limit int) ([
 	result := make([]ModerationLog, 0, limit)
 	for rows.Next() {
 		var row ModerationLog
-		if err = rows.Scan(&row.ID, &row.TargetPubkey, &row.Action, &row.SourceAdmin, &row.Timestamp, &row.Lamport, &row.Reason, &row.Result); err != nil {
+		if err = rows.Scan(&row.ID, &row.TargetPubkey, &row.Action, &row.SourceAdmin, &row.Timestamp, &row.Lamport, &row.Reason, &row.Result, &row.Signature); err != nil {
 			return nil, err
 		}
 		result = append(result, row)
@@ -3716,6 +4070,7 @@ func (a *App) insertModerationLogIfAbsent(log ModerationLog) (bool, error) {
 	log.SourceAdmin = strings.TrimSpace(log.SourceAdmin)
 	log.Reason = strings.TrimSpace(log.Reason)
 	log.Result = strings.TrimSpace(log.Result)
+	log.Signature = strings.TrimSpace(log.Signature)
 	if log.Result == "" {
 		log.Result = "applied"
 	}
@@ -3749,9 +4104,9 @@ func (a *App) insertModerationLogIfAbsent(log ModerationLog) (bool, error) {
 	}
 
 	_, err = a.db.Exec(`
-		INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, reason, result)
-		VALUES (?, ?, ?, ?, ?, ?, ?);
-	`, log.TargetPubkey, log.Action, log.SourceAdmin, log.Timestamp, log.Lamport, log.Reason, log.Result)
+		INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, reason, result, signature)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+	`, log.TargetPubkey, log.Action, log.SourceAdmin, log.Timestamp, log.Lamport, log.Reason, log.Result, log.Signature)
 	if err != nil {
 		return false, err
 	}
@@ -3768,7 +4123,7 @@ func (a *App) GetModerationLogs(limit int) ([]ModerationLog, error) {
 	}
 
 	rows, err := a.db.Query(`
-		SELECT id, target_pubkey, action, source_admin, timestamp, lamport, reason, result
+		SELECT id, target_pubkey, action, source_admin, timestamp, lamport, reason, result, signature
 		FROM moderation_logs
 		ORDER BY timestamp DESC, id DESC
 		LIMIT ?;
@@ -3781,7 +4136,7 @@ func (a *App) GetModerationLogs(limit int) ([]ModerationLog, error) {
 	result := make([]ModerationLog, 0)
 	for rows.Next() {
 		var row ModerationLog
-		if err = rows.Scan(&row.ID, &row.TargetPubkey, &row.Action, &row.SourceAdmin, &row.Timestamp, &row.Lamport, &row.Reason, &row.Result); err != nil {
+		if err = rows.Scan(&row.ID, &row.TargetPubkey, &row.Action, &row.SourceAdmin, &row.Timestamp, &row.Lamport, &row.Reason, &row.Result, &row.Signature); err != nil {
 			return nil, err
 		}
 		result = append(result, row)
@@ -3893,78 +4248,54 @@ func (a *App) GetIdentityState() ([]IdentityState, error) {
 	return result, rows.Err()
 }
 
+// GetStorageUsage reports bytes stored against each quota. Zone usage counts
+// the zone's message rows and the unpinned content blobs its posts
+// reference, so a blob shared by both zones counts toward each. Pinned blobs
+// count toward PinnedUsedBytes instead of their zone or media usage.
+// TotalUsedBytes counts every row once, including blobs no post references.
 func (a *App) GetStorageUsage() (StorageUsage, error) {
 	if a.db == nil {
 		return StorageUsage{}, errors.New("database not initialized")
 	}
 
+	quotas, err := a.GetStorageQuotas()
+	if err != nil {
+		return StorageUsage{}, err
+	}
 	usage := StorageUsage{
-		PrivateQuota: privateQuotaBytes,
-		PublicQuota:  publicQuotaBytes,
-		TotalQuota:   totalQuotaBytes,
+		PrivateQuota: quotas.PrivateBytes,
+		PublicQuota:  quotas.PublicBytes,
+		MediaQuota:   quotas.MediaBytes,
+		PinnedQuota:  quotas.PinnedBytes,
+		TotalQuota:   quotas.TotalBytes,
 	}
 
-	rows, err := a.db.Query(`
-		SELECT content_cid, size_bytes
-		FROM content_blobs;
-	`)
+	tx, err := a.db.Begin()
 	if err != nil {
 		return StorageUsage{}, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var (
-			cid  string
-			size int64
-		)
-		if err = rows.Scan(&cid, &size); err != nil {
-			return StorageUsage{}, err
-		}
+	defer tx.Rollback()
 
-		shareable, shareErr := a.canServeContentBlobToNetwork(cid)
-		if shareErr != nil {
-			return StorageUsage{}, shareErr
-		}
-		if shareable {
-			usage.PublicUsedBytes += size
-		} else {
-			usage.PrivateUsedBytes += size
-		}
+	if usage.PrivateUsedBytes, err = zoneStorageUsedTx(tx, "private"); err != nil {
+		return StorageUsage{}, err
 	}
-	if err = rows.Err(); err != nil {
+	if usage.PublicUsedBytes, err = zoneStorageUsedTx(tx, "public"); err != nil {
 		return StorageUsage{}, err
 	}
-
-	mediaRows, err := a.db.Query(`
-		SELECT content_cid, size_bytes
-		FROM media_blobs;
-	`)
-	if err != nil {
+	if usage.MediaUsedBytes, err = mediaStorageUsedTx(tx); err != nil {
 		return StorageUsage{}, err
 	}
-	defer mediaRows.Close()
-
-	for mediaRows.Next() {
-		var (
-			cid  string
-			size int64
-		)
-		if err = mediaRows.Scan(&cid, &size); err != nil {
-			return StorageUsage{}, err
-		}
-
-		shareable, shareErr := a.canServeMediaBlobToNetwork(cid)
-		if shareErr != nil {
-			return StorageUsage{}, shareErr
-		}
-		if shareable {
-			usage.PublicUsedBytes += size
-		} else {
-			usage.PrivateUsedBytes += size
-		}
+	if usage.PinnedUsedBytes, err = pinnedStorageUsedTx(tx); err != nil {
+		return StorageUsage{}, err
 	}
-	if err = mediaRows.Err(); err != nil {
+	if err = tx.QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM messages),
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM content_blobs);
+	`).Scan(&usage.MessageBytes, &usage.ContentBytes); err != nil {
+		return StorageUsage{}, err
+	}
+	if usage.TotalUsedBytes, err = totalStorageUsedTx(tx); err != nil {
 		return StorageUsage{}, err
 	}
 
@@ -3989,17 +4320,29 @@ func (a *App) ResetLocalTestData() error {
 		`DELETE FROM comment_downvotes;`,
 		`DELETE FROM post_downvotes;`,
 		`DELETE FROM vote_ops;`,
+		`DELETE FROM vote_clocks;`,
 		`DELETE FROM post_favorite_ops;`,
 		`DELETE FROM post_favorites_state;`,
 		`DELETE FROM entity_ops;`,
+		`DELETE FROM entity_signatures;`,
+		`DELETE FROM entity_revisions;`,
 		`DELETE FROM tombstone_gc_marks;`,
 		`DELETE FROM messages;`,
 		`DELETE FROM content_blobs;`,
 		`DELETE FROM media_blobs;`,
+		`DELETE FROM storage_evictions;`,
+		`DELETE FROM maintenance_runs;`,
+		`DELETE FROM pinned_posts;`,
+		`DELETE FROM pinned_subs;`,
+		`DELETE FROM search_docs;`,
+		`DELETE FROM search_fts;`,
 		`DELETE FROM sub_subscriptions;`,
 		`DELETE FROM subs;`,
 		`DELETE FROM moderation_logs;`,
 		`DELETE FROM moderation;`,
+		`DELETE FROM governance_delegations;`,
+		`DELETE FROM governance_delegations_pending;`,
+		`DELETE FROM governance_admins WHERE source = 'delegation';`,
 		`DELETE FROM known_peers;`,
 		`DELETE FROM identity_state;`,
 		`DELETE FROM profiles;`,
@@ -4131,7 +4474,18 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 	}
 
 	message.Type = strings.ToUpper(strings.TrimSpace(message.Type))
+	if err := a.verifyIncomingMessageSignature(message); err != nil {
+		return err
+	}
+
+	if err := a.applyIncomingMessage(message); err != nil {
+		return err
+	}
 
+	return a.recordIncomingMessageSignature(message)
+}
+
+func (a *App) applyIncomingMessage(message IncomingMessage) error {
 	switch message.Type {
 	case "SUB_CREATE":
 		_, err := a.upsertSub(message.SubID, message.SubTitle, message.SubDesc, message.Timestamp)
@@ -4143,12 +4497,12 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		}
 		return nil
 	case "GOVERNANCE_POLICY_UPDATE":
-		trusted, trustErr := a.isTrustedAdmin(message.AdminPubkey)
-		if trustErr != nil {
-			return trustErr
+		root, rootErr := a.isRootAdmin(message.AdminPubkey)
+		if rootErr != nil {
+			return rootErr
 		}
-		if !trusted {
-			return errors.New("admin pubkey is not trusted")
+		if !root {
+			return errGovernancePolicyNotRoot
 		}
 		_, policyErr := a.SetGovernancePolicy(message.HideHistoryOnShadowBan)
 		return policyErr
@@ -4227,7 +4581,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 			return errors.New("invalid post upvote payload")
 		}
 
-		return a.applyPostUpvote(voterPubkey, message.PostID, message.OpID)
+		return a.applyPostUpvote(voterPubkey, message.PostID, message.OpID, message.Timestamp)
 	case "POST_DOWNVOTE":
 		voterPubkey := strings.TrimSpace(message.VoterPubkey)
 		if voterPubkey == "" {
@@ -4237,7 +4591,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 			return errors.New("invalid post downvote payload")
 		}
 
-		return a.applyPostDownvote(voterPubkey, message.PostID, message.OpID)
+		return a.applyPostDownvote(voterPubkey, message.PostID, message.OpID, message.Timestamp)
 	case "POST_VOTE_SET":
 		voterPubkey := strings.TrimSpace(message.VoterPubkey)
 		if voterPubkey == "" {
@@ -4246,7 +4600,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		if voterPubkey == "" || strings.TrimSpace(message.PostID) == "" {
 			return errors.New("invalid post vote set payload")
 		}
-		return a.applyPostVoteState(voterPubkey, message.PostID, message.VoteState, message.OpID)
+		return a.applyPostVoteState(voterPubkey, message.PostID, message.VoteState, message.OpID, message.Timestamp)
 	case "COMMENT_UPVOTE":
 		voterPubkey := strings.TrimSpace(message.VoterPubkey)
 		if voterPubkey == "" {
@@ -4256,7 +4610,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 			return errors.New("invalid comment upvote payload")
 		}
 
-		return a.applyCommentUpvote(voterPubkey, message.CommentID, message.PostID, message.OpID)
+		return a.applyCommentUpvote(voterPubkey, message.CommentID, message.PostID, message.OpID, message.Timestamp)
 	case "COMMENT_DOWNVOTE":
 		voterPubkey := strings.TrimSpace(message.VoterPubkey)
 		if voterPubkey == "" {
@@ -4266,7 +4620,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 			return errors.New("invalid comment downvote payload")
 		}
 
-		return a.applyCommentDownvote(voterPubkey, message.CommentID, message.PostID, message.OpID)
+		return a.applyCommentDownvote(voterPubkey, message.CommentID, message.PostID, message.OpID, message.Timestamp)
 	case "COMMENT_VOTE_SET":
 		voterPubkey := strings.TrimSpace(message.VoterPubkey)
 		if voterPubkey == "" {
@@ -4275,7 +4629,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		if voterPubkey == "" || strings.TrimSpace(message.CommentID) == "" || strings.TrimSpace(message.PostID) == "" {
 			return errors.New("invalid comment vote set payload")
 		}
-		return a.applyCommentVoteState(voterPubkey, message.CommentID, message.PostID, message.VoteState, message.OpID)
+		return a.applyCommentVoteState(voterPubkey, message.CommentID, message.PostID, message.VoteState, message.OpID, message.Timestamp)
 	case messageTypeFavoriteOp:
 		localIdentity, err := a.getLocalIdentity()
 		if err != nil {
@@ -4344,7 +4698,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		}
 
 		if strings.TrimSpace(message.DisplayName) != "" || strings.TrimSpace(message.AvatarURL) != "" {
-			if _, err := a.upsertProfile(message.Pubkey, message.DisplayName, message.AvatarURL, message.Timestamp); err != nil {
+			if err := a.upsertProfileHint(message.Pubkey, message.DisplayName, message.AvatarURL, message.Timestamp); err != nil {
 				return err
 			}
 		}
@@ -4363,13 +4717,16 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 			PostID:      strings.TrimSpace(message.PostID),
 			ParentID:    strings.TrimSpace(message.ParentID),
 			Pubkey:      message.Pubkey,
-			OpID:        resolveOperationID(message.OpID, message.ID, message.Pubkey, message.Lamport, normalizeOperationType(message.OpType, postOpTypeCreate)),
+			OpID:        resolveOperationID(message.OpID, message.ID, message.Pubkey, message.Lamport, postOpTypeCreate),
 			Body:        commentBody,
 			Attachments: attachments,
 			Timestamp:   message.Timestamp,
 			Lamport:     message.Lamport,
 		})
 		return err
+	case messageTypeAdminDelegation:
+		_, err := a.applyAdminDelegation(adminDelegationFromMessage(message))
+		return err
 	case "SHADOW_BAN":
 		trusted, err := a.isTrustedAdmin(message.AdminPubkey)
 		if err != nil {
@@ -4385,7 +4742,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		if err != nil {
 			return err
 		}
-		return a.upsertModeration(message.TargetPubkey, "SHADOW_BAN", message.AdminPubkey, message.Timestamp, lamport, message.Reason)
+		return a.upsertModeration(message.TargetPubkey, "SHADOW_BAN", message.AdminPubkey, message.Timestamp, lamport, message.Reason, message.Signature)
 	case "UNBAN":
 		trusted, err := a.isTrustedAdmin(message.AdminPubkey)
 		if err != nil {
@@ -4401,7 +4758,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		if err != nil {
 			return err
 		}
-		return a.upsertModeration(message.TargetPubkey, "UNBAN", message.AdminPubkey, message.Timestamp, lamport, message.Reason)
+		return a.upsertModeration(message.TargetPubkey, "UNBAN", message.AdminPubkey, message.Timestamp, lamport, message.Reason, message.Signature)
 	case "POST":
 		if scope := strings.TrimSpace(strings.ToLower(message.AuthScope)); scope != "" && scope != authScopeUser {
 			return errors.New("invalid post auth scope")
@@ -4419,7 +4776,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		message.Lamport = lamport
 
 		if strings.TrimSpace(message.DisplayName) != "" || strings.TrimSpace(message.AvatarURL) != "" {
-			if _, err := a.upsertProfile(message.Pubkey, message.DisplayName, message.AvatarURL, message.Timestamp); err != nil {
+			if err := a.upsertProfileHint(message.Pubkey, message.DisplayName, message.AvatarURL, message.Timestamp); err != nil {
 				return err
 			}
 		}
@@ -4434,6 +4791,9 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		if title == "" || body == "" {
 			return errors.New("invalid post payload")
 		}
+		if contentCID := strings.TrimSpace(message.ContentCID); contentCID != "" && contentCID != buildContentCID(body) {
+			return errors.New("post body does not match content cid")
+		}
 
 		viewerPubkey := ""
 		if identity, idErr := a.getLocalIdentity(); idErr == nil {
@@ -4455,7 +4815,7 @@ func (a *App) ProcessIncomingMessage(payload []byte) error {
 		insertedMessage, err := a.insertMessage(ForumMessage{
 			ID:          message.ID,
 			Pubkey:      message.Pubkey,
-			OpID:        resolveOperationID(message.OpID, message.ID, message.Pubkey, message.Lamport, normalizeOperationType(message.OpType, postOpTypeCreate)),
+			OpID:        resolveOperationID(message.OpID, message.ID, message.Pubkey, message.Lamport, postOpTypeCreate),
 			Title:       title,
 			Body:        body,
 			ContentCID:  strings.TrimSpace(message.ContentCID),
@@ -4621,6 +4981,7 @@ func (a *App) deleteLocalPostAsAuthor(pubkey string, postID string, deletedAt in
 	if err != nil {
 		return err
 	}
+	a.reindexForSearch(searchKindPost, postID)
 	return a.appendEntityOperation(
 		entityTypePost,
 		postID,
@@ -4709,6 +5070,7 @@ func (a *App) deleteLocalCommentAsAuthor(pubkey string, commentID string, delete
 	if err != nil {
 		return "", err
 	}
+	a.reindexForSearch(searchKindComment, commentID)
 	if err = a.appendEntityOperation(
 		entityTypeComment,
 		commentID,
@@ -4737,7 +5099,7 @@ func (a *App) UpvotePost(postID string) error {
 		return err
 	}
 
-	return a.applyPostUpvote(identity.PublicKey, postID, generateOperationID(postID, identity.PublicKey, time.Now().UnixNano()))
+	return a.applyPostUpvote(identity.PublicKey, postID, generateOperationID(postID, identity.PublicKey, time.Now().UnixNano()), time.Now().Unix())
 }
 
 func (a *App) DownvotePost(postID string) error {
@@ -4750,7 +5112,7 @@ func (a *App) DownvotePost(postID string) error {
 		return err
 	}
 
-	return a.applyPostDownvote(identity.PublicKey, postID, generateOperationID(postID, identity.PublicKey, time.Now().UnixNano()))
+	return a.applyPostDownvote(identity.PublicKey, postID, generateOperationID(postID, identity.PublicKey, time.Now().UnixNano()), time.Now().Unix())
 }
 
 func (a *App) UpvoteComment(commentID string) error {
@@ -4763,7 +5125,7 @@ func (a *App) UpvoteComment(commentID string) error {
 		return err
 	}
 
-	return a.applyCommentUpvote(identity.PublicKey, commentID, "", generateOperationID(commentID, identity.PublicKey, time.Now().UnixNano()))
+	return a.applyCommentUpvote(identity.PublicKey, commentID, "", generateOperationID(commentID, identity.PublicKey, time.Now().UnixNano()), time.Now().Unix())
 }
 
 func (a *App) DownvoteComment(commentID string) error {
@@ -4776,7 +5138,7 @@ func (a *App) DownvoteComment(commentID string) error {
 		return err
 	}
 
-	return a.applyCommentDownvote(identity.PublicKey, commentID, "", generateOperationID(commentID, identity.PublicKey, time.Now().UnixNano()))
+	return a.applyCommentDownvote(identity.PublicKey, commentID, "", generateOperationID(commentID, identity.PublicKey, time.Now().UnixNano()), time.Now().Unix())
 }
 
 func (a *App) AddFavorite(postID string) error {
@@ -5117,6 +5479,32 @@ func (a *App) upsertProfile(pubkey string, displayName string, avatarURL string,
 	return Profile{Pubkey: pubkey, DisplayName: displayName, AvatarURL: avatarURL, UpdatedAt: updatedAt}, nil
 }
 
+// upsertProfileHint stores display fields carried on posts/comments only when
+// the author has no profile yet; those fields sit outside the signed envelope,
+// so they must never overwrite a signed PROFILE_UPDATE.
+func (a *App) upsertProfileHint(pubkey string, displayName string, avatarURL string, updatedAt int64) error {
+	pubkey = strings.TrimSpace(pubkey)
+	if pubkey == "" {
+		return errors.New("pubkey is required")
+	}
+
+	displayName = strings.TrimSpace(displayName)
+	avatarURL = strings.TrimSpace(avatarURL)
+	if len([]rune(displayName)) > 64 {
+		displayName = string([]rune(displayName)[:64])
+	}
+	if updatedAt <= 0 {
+		updatedAt = time.Now().Unix()
+	}
+
+	_, err := a.db.Exec(`
+		INSERT INTO profiles (pubkey, display_name, avatar_url, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(pubkey) DO NOTHING;
+	`, pubkey, displayName, avatarURL, updatedAt)
+	return err
+}
+
 func (a *App) AddLocalPostStructuredToSub(pubkey string, title string, body string, zone string, subID string) (ForumMessage, error) {
 	zone = strings.ToLower(strings.TrimSpace(zone))
 	if zone != "private" && zone != "public" {
@@ -5384,12 +5772,16 @@ func (a *App) ApplyShadowBan(targetPubkey string, adminPubkey string, reason str
 		return errors.New("admin pubkey is not trusted")
 	}
 
+	targetPubkey = strings.TrimSpace(targetPubkey)
+	adminPubkey = strings.TrimSpace(adminPubkey)
+	reason = strings.TrimSpace(reason)
 	now := time.Now().Unix()
 	lamport, err := a.nextLamport()
 	if err != nil {
 		return err
 	}
-	return a.upsertModeration(targetPubkey, "SHADOW_BAN", adminPubkey, now, lamport, reason)
+	signature := a.signLocalModeration("SHADOW_BAN", targetPubkey, adminPubkey, now, lamport, reason)
+	return a.upsertModeration(targetPubkey, "SHADOW_BAN", adminPubkey, now, lamport, reason, signature)
 }
 
 func (a *App) ApplyUnban(targetPubkey string, adminPubkey string, reason string) error {
@@ -5401,12 +5793,16 @@ func (a *App) ApplyUnban(targetPubkey string, adminPubkey string, reason string)
 		return errors.New("admin pubkey is not trusted")
 	}
 
+	targetPubkey = strings.TrimSpace(targetPubkey)
+	adminPubkey = strings.TrimSpace(adminPubkey)
+	reason = strings.TrimSpace(reason)
 	now := time.Now().Unix()
 	lamport, err := a.nextLamport()
 	if err != nil {
 		return err
 	}
-	return a.upsertModeration(targetPubkey, "UNBAN", adminPubkey, now, lamport, reason)
+	signature := a.signLocalModeration("UNBAN", targetPubkey, adminPubkey, now, lamport, reason)
+	return a.upsertModeration(targetPubkey, "UNBAN", adminPubkey, now, lamport, reason, signature)
 }
 
 func (a *App) AddTrustedAdmin(pubkey string, role string) error {
@@ -5425,13 +5821,19 @@ func (a *App) AddTrustedAdmin(pubkey string, role string) error {
 	}
 
 	_, err := a.db.Exec(`
-		INSERT INTO governance_admins (admin_pubkey, role, active)
-		VALUES (?, ?, 1)
+		INSERT INTO governance_admins (admin_pubkey, role, active, source)
+		VALUES (?, ?, 1, 'local')
 		ON CONFLICT(admin_pubkey) DO UPDATE SET
 			role = excluded.role,
-			active = 1;
+			active = 1,
+			source = 'local';
 	`, pubkey, role)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// A new local root may validate delegations that were dangling before.
+	return a.rebuildDelegatedAdmins()
 }
 
 func (a *App) GetTrustedAdmins() ([]GovernanceAdmin, error) {
@@ -5440,7 +5842,7 @@ func (a *App) GetTrustedAdmins() ([]GovernanceAdmin, error) {
 	}
 
 	rows, err := a.db.Query(`
-		SELECT admin_pubkey, role, active
+		SELECT admin_pubkey, role, active, source
 		FROM governance_admins
 		WHERE active = 1
 		ORDER BY role, admin_pubkey;
@@ -5454,7 +5856,7 @@ func (a *App) GetTrustedAdmins() ([]GovernanceAdmin, error) {
 	for rows.Next() {
 		var admin GovernanceAdmin
 		var active int
-		if err = rows.Scan(&admin.AdminPubkey, &admin.Role, &active); err != nil {
+		if err = rows.Scan(&admin.AdminPubkey, &admin.Role, &active, &admin.Source); err != nil {
 			return nil, err
 		}
 		admin.Active = active == 1
@@ -5521,6 +5923,8 @@ func (a *App) insertMessage(message ForumMessage) (ForumMessage, error) {
 	message.ContentCID = strings.TrimSpace(message.ContentCID)
 	if message.ContentCID == "" {
 		message.ContentCID = buildContentCID(fullBody)
+	} else if err := verifyBlobCID(blobKindContent, message.ContentCID, []byte(fullBody)); err != nil {
+		return ForumMessage{}, err
 	}
 	blobSizeBytes := int64(len([]byte(fullBody)))
 	message.Body = deriveBodyPreview(fullBody, 180)
@@ -5531,10 +5935,7 @@ func (a *App) insertMessage(message ForumMessage) (ForumMessage, error) {
 		message.Visibility = "normal"
 	}
 
-	quota := publicQuotaBytes
-	if message.Zone == "private" {
-		quota = privateQuotaBytes
-	}
+	quotas := a.currentStorageQuotas()
 
 	var (
 		existingPubkey       string
@@ -5570,6 +5971,11 @@ func (a *App) insertMessage(message ForumMessage) (ForumMessage, error) {
 		incomingVersion := LamportVersion{Lamport: message.Lamport, Author: message.Pubkey, OpID: message.OpID}
 		currentVersion := LamportVersion{Lamport: existingLamport, Author: existingAuthorPubkey, OpID: existingOpID}
 		if compareLamportVersion(incomingVersion, currentVersion) <= 0 {
+			if compareLamportVersion(incomingVersion, currentVersion) < 0 && existingDeletedL == 0 {
+				if _, err = a.recordPastRevision(postRevisionRecord(postRevisionDigest(message)), fullBody); err != nil {
+					return ForumMessage{}, err
+				}
+			}
 			return message, nil
 		}
 
@@ -5593,10 +5999,26 @@ func (a *App) insertMessage(message ForumMessage) (ForumMessage, error) {
 		return ForumMessage{}, err
 	}
 
-	if err = ensureBlobQuotaWithLRU(tx, message.Zone, quota, blobSizeBytes, message.ContentCID); err != nil {
+	messageBytes := message.SizeBytes
+	if appliedOpType == postOpTypeUpdate {
+		messageBytes = 0
+	}
+	if err = a.newStorageEvictor(tx, quotas).ensure(storageWrite{
+		Zone:         message.Zone,
+		MessageID:    message.ID,
+		MessageBytes: messageBytes,
+		ContentCID:   message.ContentCID,
+		ContentBytes: blobSizeBytes,
+	}); err != nil {
 		_ = tx.Rollback()
 		return ForumMessage{}, err
 	}
+	if appliedOpType == postOpTypeUpdate {
+		if err = a.snapshotCurrentRevisionTx(tx, quotas, entityTypePost, message.ID); err != nil {
+			_ = tx.Rollback()
+			return ForumMessage{}, err
+		}
+	}
 
 	if _, err = tx.Exec(`
 		INSERT INTO content_blobs (content_cid, body, size_bytes, created_at, last_accessed_at)
@@ -5668,6 +6090,14 @@ func (a *App) insertMessage(message ForumMessage) (ForumMessage, error) {
 		_ = tx.Rollback()
 		return ForumMessage{}, err
 	}
+	if err = pinArrivedBlobsTx(tx, quotas, storageBlobKindContent, message.ContentCID); err != nil {
+		_ = tx.Rollback()
+		return ForumMessage{}, err
+	}
+	if err = pinArrivedBlobsTx(tx, quotas, storageBlobKindMedia, message.ImageCID, message.ThumbCID); err != nil {
+		_ = tx.Rollback()
+		return ForumMessage{}, err
+	}
 	if err = a.appendEntityOperationTx(
 		tx,
 		entityTypePost,
@@ -5684,10 +6114,19 @@ func (a *App) insertMessage(message ForumMessage) (ForumMessage, error) {
 		_ = tx.Rollback()
 		return ForumMessage{}, err
 	}
+	if _, err = insertRevisionTx(tx, postRevisionRecord(postRevisionDigest(message))); err != nil {
+		_ = tx.Rollback()
+		return ForumMessage{}, err
+	}
+	if _, err = pruneRevisionBodiesTx(tx, resolveRevisionRetention(), time.Now().Unix(), entityTypePost, message.ID); err != nil {
+		_ = tx.Rollback()
+		return ForumMessage{}, err
+	}
 
 	if err = tx.Commit(); err != nil {
 		return ForumMessage{}, err
 	}
+	a.reindexForSearch(searchKindPost, message.ID)
 
 	return message, nil
 }
@@ -5753,6 +6192,12 @@ func (a *App) insertComment(comment Comment) (Comment, error) {
 		incomingVersion := LamportVersion{Lamport: comment.Lamport, Author: comment.Pubkey, OpID: comment.OpID}
 		currentVersion := LamportVersion{Lamport: existingLamport, Author: existingAuthorPubkey, OpID: existingOpID}
 		if compareLamportVersion(incomingVersion, currentVersion) <= 0 {
+			if compareLamportVersion(incomingVersion, currentVersion) < 0 && existingDeletedL == 0 && comment.DeletedAt <= 0 {
+				record := commentRevisionRecord(commentRevisionDigest(comment))
+				if _, err = a.recordPastRevision(record, comment.Body); err != nil {
+					return Comment{}, err
+				}
+			}
 			return comment, nil
 		}
 		if existingDeletedL > 0 {
@@ -5769,11 +6214,18 @@ func (a *App) insertComment(comment Comment) (Comment, error) {
 		return Comment{}, err
 	}
 	mediaCIDs := mediaCIDsFromAttachments(comment.Attachments)
+	quotas := a.currentStorageQuotas()
 
 	tx, err := a.db.Begin()
 	if err != nil {
 		return Comment{}, err
 	}
+	if appliedOpType == postOpTypeUpdate {
+		if err = a.snapshotCurrentRevisionTx(tx, quotas, entityTypeComment, comment.ID); err != nil {
+			_ = tx.Rollback()
+			return Comment{}, err
+		}
+	}
 
 	_, err = tx.Exec(`
 		INSERT INTO comments (
@@ -5815,6 +6267,10 @@ func (a *App) insertComment(comment Comment) (Comment, error) {
 			return Comment{}, err
 		}
 	}
+	if err = pinArrivedBlobsTx(tx, quotas, storageBlobKindMedia, mediaCIDs...); err != nil {
+		_ = tx.Rollback()
+		return Comment{}, err
+	}
 	if err = a.appendEntityOperationTx(
 		tx,
 		entityTypeComment,
@@ -5831,10 +6287,21 @@ func (a *App) insertComment(comment Comment) (Comment, error) {
 		_ = tx.Rollback()
 		return Comment{}, err
 	}
+	if comment.DeletedAt <= 0 {
+		if _, err = insertRevisionTx(tx, commentRevisionRecord(commentRevisionDigest(comment))); err != nil {
+			_ = tx.Rollback()
+			return Comment{}, err
+		}
+		if _, err = pruneRevisionBodiesTx(tx, resolveRevisionRetention(), time.Now().Unix(), entityTypeComment, comment.ID); err != nil {
+			_ = tx.Rollback()
+			return Comment{}, err
+		}
+	}
 
 	if err = tx.Commit(); err != nil {
 		return Comment{}, err
 	}
+	a.reindexForSearch(searchKindComment, comment.ID)
 
 	return comment, nil
 }
@@ -5927,6 +6394,7 @@ func (a *App) upsertCommentTombstone(commentID string, postID string, pubkey str
 	if affected == 0 {
 		return nil
 	}
+	a.reindexForSearch(searchKindComment, commentID)
 	return a.appendEntityOperation(
 		entityTypeComment,
 		commentID,
@@ -5941,7 +6409,7 @@ func (a *App) upsertCommentTombstone(commentID string, postID string, pubkey str
 	)
 }
 
-func (a *App) applyPostUpvote(voterPubkey string, postID string, opID string) error {
+func (a *App) applyPostUpvote(voterPubkey string, postID string, opID string, votedAt int64) error {
 	current, err := a.getPostVoteState(voterPubkey, postID)
 	if err != nil {
 		return err
@@ -5950,10 +6418,10 @@ func (a *App) applyPostUpvote(voterPubkey string, postID string, opID string) er
 	if current == voteStateUp {
 		target = voteStateNone
 	}
-	return a.applyPostVoteState(voterPubkey, postID, target, opID)
+	return a.applyPostVoteState(voterPubkey, postID, target, opID, votedAt)
 }
 
-func (a *App) applyPostDownvote(voterPubkey string, postID string, opID string) error {
+func (a *App) applyPostDownvote(voterPubkey string, postID string, opID string, votedAt int64) error {
 	current, err := a.getPostVoteState(voterPubkey, postID)
 	if err != nil {
 		return err
@@ -5962,10 +6430,10 @@ func (a *App) applyPostDownvote(voterPubkey string, postID string, opID string)
 	if current == voteStateDown {
 		target = voteStateNone
 	}
-	return a.applyPostVoteState(voterPubkey, postID, target, opID)
+	return a.applyPostVoteState(voterPubkey, postID, target, opID, votedAt)
 }
 
-func (a *App) applyCommentUpvote(voterPubkey string, commentID string, postID string, opID string) error {
+func (a *App) applyCommentUpvote(voterPubkey string, commentID string, postID string, opID string, votedAt int64) error {
 	current, err := a.getCommentVoteState(voterPubkey, commentID)
 	if err != nil {
 		return err
@@ -5974,10 +6442,10 @@ func (a *App) applyCommentUpvote(voterPubkey string, commentID string, postID st
 	if current == voteStateUp {
 		target = voteStateNone
 	}
-	return a.applyCommentVoteState(voterPubkey, commentID, postID, target, opID)
+	return a.applyCommentVoteState(voterPubkey, commentID, postID, target, opID, votedAt)
 }
 
-func (a *App) applyCommentDownvote(voterPubkey string, commentID string, postID string, opID string) error {
+func (a *App) applyCommentDownvote(voterPubkey string, commentID string, postID string, opID string, votedAt int64) error {
 	current, err := a.getCommentVoteState(voterPubkey, commentID)
 	if err != nil {
 		return err
@@ -5986,7 +6454,7 @@ func (a *App) applyCommentDownvote(voterPubkey string, commentID string, postID
 	if current == voteStateDown {
 		target = voteStateNone
 	}
-	return a.applyCommentVoteState(voterPubkey, commentID, postID, target, opID)
+	return a.applyCommentVoteState(voterPubkey, commentID, postID, target, opID, votedAt)
 }
 
 func (a *App) currentPostVoteStateTx(tx *sql.Tx, voterPubkey string, postID string) (string, error) {
@@ -6069,6 +6537,60 @@ func (a *App) getCommentVoteState(voterPubkey string, commentID string) (string,
 	return state, nil
 }
 
+// voteTimestamp is the time a vote row records: the vote op's timestamp,
+// capped at now so a clock running ahead cannot keep a post rising, or now
+// when the op has none.
+func voteTimestamp(votedAt int64, now int64) int64 {
+	if votedAt <= 0 || votedAt > now {
+		return now
+	}
+	return votedAt
+}
+
+// acceptVoteOpTx reports whether a vote op should be applied, recording it
+// when it is. An op is dropped when its ID was seen before, when it is older
+// than the op retention horizon, past which its vote_ops row may have been
+// compacted, or when it is older than the last op applied for the same voter
+// and target, or is that op. Votes restored without an op ID are always
+// applied.
+func acceptVoteOpTx(tx *sql.Tx, targetType string, targetID string, voterPubkey string, opID string, votedAt int64) (bool, error) {
+	if opID == "" {
+		return true, nil
+	}
+	now := time.Now()
+	if votedAt < maintenanceOpCutoff(now) {
+		return false, nil
+	}
+	result, err := tx.Exec(`INSERT INTO vote_ops (op_id, created_at) VALUES (?, ?) ON CONFLICT(op_id) DO NOTHING;`, opID, now.Unix())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+
+	var lastVotedAt int64
+	var lastOpID string
+	err = tx.QueryRow(`
+		SELECT voted_at, op_id FROM vote_clocks
+		WHERE target_type = ? AND target_id = ? AND voter_pubkey = ?;
+	`, targetType, targetID, voterPubkey).Scan(&lastVotedAt, &lastOpID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return false, err
+	case votedAt < lastVotedAt || opID == lastOpID:
+		return false, nil
+	}
+	_, err = tx.Exec(`
+		INSERT INTO vote_clocks (target_type, target_id, voter_pubkey, voted_at, op_id)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(target_type, target_id, voter_pubkey) DO UPDATE SET voted_at = excluded.voted_at, op_id = excluded.op_id;
+	`, targetType, targetID, voterPubkey, votedAt, opID)
+	return err == nil, err
+}
+
 func voteDelta(before string, after string) int64 {
 	value := func(state string) int64 {
 		switch normalizeVoteState(state) {
@@ -6083,7 +6605,9 @@ func voteDelta(before string, after string) int64 {
 	return value(after) - value(before)
 }
 
-func (a *App) applyPostVoteState(voterPubkey string, postID string, targetState string, opID string) error {
+// applyPostVoteState records a voter's vote on a post. votedAt is the vote
+// op's own timestamp, which rising ranks by.
+func (a *App) applyPostVoteState(voterPubkey string, postID string, targetState string, opID string, votedAt int64) error {
 	targetState = normalizeVoteState(targetState)
 	voterPubkey = strings.TrimSpace(voterPubkey)
 	postID = strings.TrimSpace(postID)
@@ -6097,18 +6621,12 @@ func (a *App) applyPostVoteState(voterPubkey string, postID string, targetState
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	if opID != "" {
-		result, err := tx.Exec(`INSERT INTO vote_ops (op_id, created_at) VALUES (?, ?) ON CONFLICT(op_id) DO NOTHING;`, opID, time.Now().Unix())
-		if err != nil {
-			return err
-		}
-		affected, err := result.RowsAffected()
-		if err != nil {
-			return err
-		}
-		if affected == 0 {
-			return tx.Commit()
-		}
+	accepted, err := acceptVoteOpTx(tx, entityTypePost, postID, voterPubkey, opID, votedAt)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return tx.Commit()
 	}
 
 	current, err := a.currentPostVoteStateTx(tx, voterPubkey, postID)
@@ -6126,11 +6644,11 @@ func (a *App) applyPostVoteState(voterPubkey string, postID string, targetState
 	}
 	switch targetState {
 	case voteStateUp:
-		if _, err = tx.Exec(`INSERT INTO post_votes (post_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, postID, voterPubkey, time.Now().Unix()); err != nil {
+		if _, err = tx.Exec(`INSERT INTO post_votes (post_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, postID, voterPubkey, voteTimestamp(votedAt, time.Now().Unix())); err != nil {
 			return err
 		}
 	case voteStateDown:
-		if _, err = tx.Exec(`INSERT INTO post_downvotes (post_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, postID, voterPubkey, time.Now().Unix()); err != nil {
+		if _, err = tx.Exec(`INSERT INTO post_downvotes (post_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, postID, voterPubkey, voteTimestamp(votedAt, time.Now().Unix())); err != nil {
 			return err
 		}
 	}
@@ -6151,7 +6669,7 @@ func (a *App) applyPostVoteState(voterPubkey string, postID string, targetState
 	return tx.Commit()
 }
 
-func (a *App) applyCommentVoteState(voterPubkey string, commentID string, postID string, targetState string, opID string) error {
+func (a *App) applyCommentVoteState(voterPubkey string, commentID string, postID string, targetState string, opID string, votedAt int64) error {
 	targetState = normalizeVoteState(targetState)
 	voterPubkey = strings.TrimSpace(voterPubkey)
 	commentID = strings.TrimSpace(commentID)
@@ -6174,18 +6692,12 @@ func (a *App) applyCommentVoteState(voterPubkey string, commentID string, postID
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	if opID != "" {
-		result, err := tx.Exec(`INSERT INTO vote_ops (op_id, created_at) VALUES (?, ?) ON CONFLICT(op_id) DO NOTHING;`, opID, time.Now().Unix())
-		if err != nil {
-			return err
-		}
-		affected, err := result.RowsAffected()
-		if err != nil {
-			return err
-		}
-		if affected == 0 {
-			return tx.Commit()
-		}
+	accepted, err := acceptVoteOpTx(tx, entityTypeComment, commentID, voterPubkey, opID, votedAt)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return tx.Commit()
 	}
 
 	current, err := a.currentCommentVoteStateTx(tx, voterPubkey, commentID)
@@ -6203,11 +6715,11 @@ func (a *App) applyCommentVoteState(voterPubkey string, commentID string, postID
 	}
 	switch targetState {
 	case voteStateUp:
-		if _, err = tx.Exec(`INSERT INTO comment_votes (comment_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, commentID, voterPubkey, time.Now().Unix()); err != nil {
+		if _, err = tx.Exec(`INSERT INTO comment_votes (comment_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, commentID, voterPubkey, voteTimestamp(votedAt, time.Now().Unix())); err != nil {
 			return err
 		}
 	case voteStateDown:
-		if _, err = tx.Exec(`INSERT INTO comment_downvotes (comment_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, commentID, voterPubkey, time.Now().Unix()); err != nil {
+		if _, err = tx.Exec(`INSERT INTO comment_downvotes (comment_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, commentID, voterPubkey, voteTimestamp(votedAt, time.Now().Unix())); err != nil {
 			return err
 		}
 	}
@@ -6279,13 +6791,12 @@ func (a *App) isFavoritedByPubkey(pubkey string, postID string) (bool, error) {
 
 func (a *App) buildLocalFavoriteOperation(identity Identity, postID string, op string) (FavoriteOpRecord, error) {
 	pubkey := strings.TrimSpace(identity.PublicKey)
-	mnemonic := strings.TrimSpace(identity.Mnemonic)
 	postID = strings.TrimSpace(postID)
 	normalizedOp, err := normalizeFavoriteOperation(op)
 	if err != nil {
 		return FavoriteOpRecord{}, err
 	}
-	if pubkey == "" || mnemonic == "" || postID == "" {
+	if pubkey == "" || postID == "" {
 		return FavoriteOpRecord{}, errors.New("invalid favorite operation identity")
 	}
 
@@ -6293,7 +6804,7 @@ func (a *App) buildLocalFavoriteOperation(identity Identity, postID string, op s
 	createdAt := now.Unix()
 	opID := buildMessageID(pubkey, fmt.Sprintf("favorite|%s|%s|%d", normalizedOp, postID, now.UnixNano()), createdAt)
 	signaturePayload := buildFavoriteSignaturePayload(pubkey, postID, normalizedOp, createdAt, opID)
-	signature, err := a.SignMessage(mnemonic, signaturePayload)
+	signature, err := a.signWithLocalIdentity(pubkey, signaturePayload)
 	if err != nil {
 		return FavoriteOpRecord{}, err
 	}
@@ -6441,158 +6952,7 @@ func (a *App) emitFavoritesUpdated(postID string) {
 	runtime.EventsEmit(a.ctx, "feed:updated")
 }
 
-func ensureZoneQuota(tx *sql.Tx, zone string, quota int64, incomingBytes int64) error {
-	if incomingBytes > quota {
-		return errors.New("message exceeds zone quota")
-	}
-
-	for {
-		var used int64
-		if err := tx.QueryRow(`SELECT COALESCE(SUM(size_bytes), 0) FROM messages WHERE zone = ?;`, zone).Scan(&used); err != nil {
-			return err
-		}
-
-		if used+incomingBytes <= quota {
-			return nil
-		}
-
-		result, err := tx.Exec(`
-			DELETE FROM messages
-			WHERE id IN (
-				SELECT id
-				FROM messages
-				WHERE zone = ? AND is_protected = 0
-				ORDER BY timestamp ASC
-				LIMIT 1
-			);
-		`, zone)
-		if err != nil {
-			return err
-		}
-
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			return err
-		}
-
-		if rowsAffected == 0 {
-			return errors.New("quota exceeded and no evictable records")
-		}
-	}
-}
-
-func ensureBlobQuotaWithLRU(tx *sql.Tx, zone string, quota int64, incomingBytes int64, incomingContentCID string) error {
-	if incomingBytes > quota {
-		return errors.New("content blob exceeds zone quota")
-	}
-
-	incomingContentCID = strings.TrimSpace(incomingContentCID)
-	if incomingContentCID == "" {
-		return errors.New("content cid is required")
-	}
-
-	var existingCount int
-	if err := tx.QueryRow(`
-		SELECT COUNT(1)
-		FROM content_blobs cb
-		WHERE cb.content_cid = ?
-			AND EXISTS (
-				SELECT 1 FROM messages m
-				WHERE m.zone = ? AND m.content_cid = cb.content_cid
-			);
-	`, incomingContentCID, zone).Scan(&existingCount); err != nil {
-		return err
-	}
-
-	effectiveIncoming := incomingBytes
-	if existingCount > 0 {
-		effectiveIncoming = 0
-	}
-
-	var total int64
-	if err := tx.QueryRow(`
-		SELECT COALESCE(SUM(cb.size_bytes), 0)
-		FROM content_blobs cb
-		WHERE EXISTS (
-			SELECT 1 FROM messages m
-			WHERE m.zone = ? AND m.content_cid = cb.content_cid
-		);
-	`, zone).Scan(&total); err != nil {
-		return err
-	}
-
-	if total+effectiveIncoming <= quota {
-		return nil
-	}
-
-	rows, err := tx.Query(`
-		SELECT cb.content_cid, cb.size_bytes
-		FROM content_blobs cb
-		WHERE cb.pinned = 0
-			AND EXISTS (
-				SELECT 1 FROM messages m
-				WHERE m.zone = ? AND m.content_cid = cb.content_cid
-			)
-		ORDER BY cb.last_accessed_at ASC, cb.created_at ASC;
-	`, zone)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	type candidate struct {
-		cid  string
-		size int64
-	}
-	candidates := make([]candidate, 0)
-	for rows.Next() {
-		var c candidate
-		if scanErr := rows.Scan(&c.cid, &c.size); scanErr != nil {
-			return scanErr
-		}
-		candidates = append(candidates, c)
-	}
-	if err = rows.Err(); err != nil {
-		return err
-	}
-
-	for _, c := range candidates {
-		if total+effectiveIncoming <= quota {
-			return nil
-		}
-		if c.cid == incomingContentCID {
-			continue
-		}
-
-		var zoneCount int
-		if err = tx.QueryRow(`SELECT COUNT(DISTINCT zone) FROM messages WHERE content_cid = ?;`, c.cid).Scan(&zoneCount); err != nil {
-			return err
-		}
-		if zoneCount > 1 {
-			continue
-		}
-
-		result, delErr := tx.Exec(`DELETE FROM content_blobs WHERE content_cid = ? AND pinned = 0;`, c.cid)
-		if delErr != nil {
-			return delErr
-		}
-		affected, affectedErr := result.RowsAffected()
-		if affectedErr != nil {
-			return affectedErr
-		}
-		if affected > 0 {
-			total -= c.size
-		}
-	}
-
-	if total+effectiveIncoming > quota {
-		return errors.New("content blob quota exceeded and no evictable records")
-	}
-
-	return nil
-}
-
-func (a *App) upsertModeration(targetPubkey string, action string, sourceAdmin string, timestamp int64, lamport int64, reason string) error {
+func (a *App) upsertModeration(targetPubkey string, action string, sourceAdmin string, timestamp int64, lamport int64, reason string, signature string) error {
 	if a.db == nil {
 		return errors.New("database not initialized")
 	}
@@ -6625,32 +6985,33 @@ func (a *App) upsertModeration(targetPubkey string, action string, sourceAdmin s
 
 	if err == nil && existingTimestamp > timestamp {
 		if _, logErr := a.db.Exec(`
-			INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, reason, result)
-			VALUES (?, ?, ?, ?, ?, ?, 'ignored_older');
-		`, targetPubkey, action, sourceAdmin, timestamp, lamport, reason); logErr != nil {
+			INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, reason, result, signature)
+			VALUES (?, ?, ?, ?, ?, ?, 'ignored_older', ?);
+		`, targetPubkey, action, sourceAdmin, timestamp, lamport, reason, signature); logErr != nil {
 			return logErr
 		}
 		return nil
 	}
 
 	_, err = a.db.Exec(`
-		INSERT INTO moderation (target_pubkey, action, source_admin, timestamp, lamport, reason)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO moderation (target_pubkey, action, source_admin, timestamp, lamport, reason, signature)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(target_pubkey) DO UPDATE SET
 			action = excluded.action,
 			source_admin = excluded.source_admin,
 			timestamp = excluded.timestamp,
 			lamport = excluded.lamport,
-			reason = excluded.reason;
-	`, targetPubkey, action, sourceAdmin, timestamp, lamport, reason)
+			reason = excluded.reason,
+			signature = excluded.signature;
+	`, targetPubkey, action, sourceAdmin, timestamp, lamport, reason, signature)
 	if err != nil {
 		return err
 	}
 
 	if _, err = a.db.Exec(`
-		INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, reason, result)
-		VALUES (?, ?, ?, ?, ?, ?, 'applied');
-	`, targetPubkey, action, sourceAdmin, timestamp, lamport, reason); err != nil {
+		INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, reason, result, signature)
+		VALUES (?, ?, ?, ?, ?, ?, 'applied', ?);
+	`, targetPubkey, action, sourceAdmin, timestamp, lamport, reason, signature); err != nil {
 		return err
 	}
 
@@ -6683,6 +7044,7 @@ func (a *App) upsertModeration(targetPubkey string, action string, sourceAdmin s
 	if action == "SHADOW_BAN" {
 		state = "shadow_banned"
 	}
+	quotas := a.currentStorageQuotas()
 
 	_, err = a.db.Exec(`
 		INSERT INTO identity_state (pubkey, state, storage_commit_bytes, public_quota_bytes, private_quota_bytes, updated_at)
@@ -6693,7 +7055,7 @@ func (a *App) upsertModeration(targetPubkey string, action string, sourceAdmin s
 			public_quota_bytes = excluded.public_quota_bytes,
 			private_quota_bytes = excluded.private_quota_bytes,
 			updated_at = excluded.updated_at;
-	`, targetPubkey, state, totalQuotaBytes, publicQuotaBytes, privateQuotaBytes, time.Now().Unix())
+	`, targetPubkey, state, quotas.TotalBytes, quotas.PublicBytes, quotas.PrivateBytes, time.Now().Unix())
 
 	return err
 }
@@ -7143,7 +7505,7 @@ func countFeedItemsByReason(items []FeedStreamItem, reason string) int {
 }
 
 func (a *App) listSubscribedSubIDs() ([]string, error) {
-	rows, err := a.db.Query(`SELECT sub_id FROM sub_subscriptions ORDER BY subscribed_at DESC;`)
+	rows, err := a.db.Query(`SELECT sub_id FROM sub_subscriptions WHERE pubkey = ? ORDER BY subscribed_at DESC;`, a.activeIdentityPubkey())
 	if err != nil {
 		return nil, err
 	}
@@ -7283,8 +7645,8 @@ func (a *App) isSubSubscribed(subID string) (bool, error) {
 	if err := a.db.QueryRow(`
 		SELECT COUNT(1)
 		FROM sub_subscriptions
-		WHERE sub_id = ?;
-	`, subID).Scan(&count); err != nil {
+		WHERE pubkey = ? AND sub_id = ?;
+	`, a.activeIdentityPubkey(), subID).Scan(&count); err != nil {
 		return false, err
 	}
 
@@ -7313,38 +7675,67 @@ func (a *App) emitSubscribedSubUpdate(message ForumMessage) {
 	})
 }
 
-func (a *App) saveLocalIdentity(identity Identity) error {
+// saveLocalIdentity stores (or re-seals) an identity and makes it the active
+// one. The label of an existing identity is kept.
+func (a *App) saveLocalIdentity(pubkey string, sealedMnemonic string) error {
 	if a.db == nil {
 		return errors.New("database not initialized")
 	}
 
-	mnemonic := strings.TrimSpace(identity.Mnemonic)
-	pubkey := strings.TrimSpace(identity.PublicKey)
-	if mnemonic == "" || pubkey == "" {
+	pubkey = strings.TrimSpace(pubkey)
+	sealedMnemonic = strings.TrimSpace(sealedMnemonic)
+	if pubkey == "" || sealedMnemonic == "" {
 		return errors.New("identity is incomplete")
 	}
 
-	_, err := a.db.Exec(`
-		INSERT INTO local_identity (id, mnemonic, pubkey, updated_at)
-		VALUES (1, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
-			mnemonic = excluded.mnemonic,
-			pubkey = excluded.pubkey,
-			updated_at = excluded.updated_at;
-	`, mnemonic, pubkey, time.Now().Unix())
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
-	return err
+	if err = saveLocalIdentityTx(tx, pubkey, sealedMnemonic); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// saveLocalIdentityTx stores the sealed mnemonic, blanks any plaintext one
+// and makes pubkey the active identity.
+func saveLocalIdentityTx(tx *sql.Tx, pubkey string, sealedMnemonic string) error {
+	now := time.Now().Unix()
+	if _, err := tx.Exec(`
+		INSERT INTO local_identity (pubkey, mnemonic, sealed_mnemonic, is_active, created_at, updated_at)
+		VALUES (?, '', ?, 0, ?, ?)
+		ON CONFLICT(pubkey) DO UPDATE SET
+			mnemonic = '',
+			sealed_mnemonic = excluded.sealed_mnemonic,
+			updated_at = excluded.updated_at;
+	`, pubkey, sealedMnemonic, now, now); err != nil {
+		return err
+	}
+	return setActiveIdentityTx(tx, pubkey)
 }
 
+// getLocalIdentity resolves the active local identity, i.e. the viewer.
 func (a *App) getLocalIdentity() (Identity, error) {
 	if a.db == nil {
 		return Identity{}, errors.New("database not initialized")
 	}
 
 	var identity Identity
-	err := a.db.QueryRow(`SELECT mnemonic, pubkey FROM local_identity WHERE id = 1;`).Scan(&identity.Mnemonic, &identity.PublicKey)
+	err := a.db.QueryRow(`
+		SELECT pubkey
+		FROM local_identity
+		WHERE is_active = 1
+		ORDER BY updated_at DESC
+		LIMIT 1;
+	`).Scan(&identity.PublicKey)
 	if errors.Is(err, sql.ErrNoRows) {
-		return Identity{}, errors.New("identity not found")
+		return Identity{}, errIdentityNotFound
 	}
 	if err != nil {
 		return Identity{}, err
@@ -8,7 +8,8 @@ import {
   SubscribeSub,
   UnsubscribeSub,
   SearchSubs,
-  SearchPosts,
+  Search,
+  SearchNetwork,
   CreateSub,
   PublishCreateSub,
   PublishPostStructuredToSub,
@@ -18,13 +19,16 @@ import {
   LoadSavedIdentity,
   GenerateIdentity,
   ImportIdentityFromMnemonic,
+  GetIdentityLockStatus,
+  UnlockIdentity,
+  LockIdentity,
   GetProfileDetails,
   GetProfile,
   GetTrustedAdmins,
   GetModerationState,
   GetPostIndexByID,
   GetPostBodyByID,
-  GetCommentsByPost,
+  GetCommentPage,
   PublishCommentWithAttachments,
   PublishCommentUpvote,
   PublishCommentDownvote,
@@ -41,6 +45,9 @@ import {
   GetFavoritePostIDs,
   AddFavorite,
   RemoveFavorite,
+  ListPinned,
+  PinPost,
+  UnpinPost,
 } from '../wailsjs/go/main/App';
 import { Sidebar } from './components/Sidebar';
 import { Header } from './components/Header';
@@ -55,7 +62,7 @@ import { CreateSubModal } from './components/CreateSubModal';
 import { CreatePostModal } from './components/CreatePostModal';
 import { LoginModal } from './components/LoginModal';
 import { ToastContainer, useToasts } from './components/Toast';
-import { Sub, Profile, Post, GovernanceAdmin, Identity, Comment, ModerationLog, ModerationState } from './types';
+import { Sub, Profile, Post, GovernanceAdmin, Identity, Comment, CommentNode, CommentSort, CommentThread, ModerationLog, ModerationState, SearchHit } from './types';
 import { EventsOn } from '../wailsjs/runtime/runtime';
 
 type SortMode = 'hot' | 'new';
@@ -76,6 +83,21 @@ function getErrorMessage(error: unknown, fallback: string): string {
   return fallback;
 }
 
+// Comment threads load COMMENT_PAGE_LIMIT top-level comments at a time, each
+// with up to COMMENT_REPLY_LIMIT replies per level, COMMENT_PAGE_DEPTH deep.
+const COMMENT_PAGE_LIMIT = 20;
+const COMMENT_REPLY_LIMIT = 5;
+const COMMENT_PAGE_DEPTH = 3;
+
+function flattenCommentNodes(nodes: CommentNode[], comments: Comment[] = [], meta: CommentThread['nodes'] = {}) {
+  for (const node of nodes) {
+    comments.push(node);
+    meta[node.id] = { replyCount: node.replyCount, repliesCursor: node.repliesCursor };
+    flattenCommentNodes(node.replies || [], comments, meta);
+  }
+  return { comments, meta };
+}
+
 function App() {
   const [identity, setIdentity] = useState<Identity | null>(null);
   const [profile, setProfile] = useState<Profile | null>(null);
@@ -95,14 +117,16 @@ function App() {
   const [showSettingsPanel, setShowSettingsPanel] = useState(false);
   const [consistencyFocus, setConsistencyFocus] = useState<ConsistencyFocus | null>(null);
   const [loading, setLoading] = useState(false);
-  const [searchResults, setSearchResults] = useState<{ subs: Sub[]; posts: any[] } | null>(null);
+  const [searchResults, setSearchResults] = useState<{ subs: Sub[]; hits: SearchHit[] } | null>(null);
   const [searchQuery, setSearchQuery] = useState('');
   const [unreadSubs, setUnreadSubs] = useState<Set<string>>(new Set());
   const [favoritePostIds, setFavoritePostIds] = useState<Set<string>>(new Set());
+  const [pinnedPostIds, setPinnedPostIds] = useState<Set<string>>(new Set());
 
   const [selectedPost, setSelectedPost] = useState<Post | null>(null);
   const [postBody, setPostBody] = useState<string>('');
   const [postComments, setPostComments] = useState<Comment[]>([]);
+  const [commentThread, setCommentThread] = useState<CommentThread>({ sort: 'top', threadCount: 0, nextCursor: '', nodes: {} });
   const [governanceAdmins, setGovernanceAdmins] = useState<GovernanceAdmin[]>([]);
   const [moderationStates, setModerationStates] = useState<ModerationState[]>([]);
   const [moderationLogs, setModerationLogs] = useState<ModerationLog[]>([]);
@@ -154,9 +178,21 @@ function App() {
     }
   }, []);
 
+  const loadPinnedPosts = useCallback(async () => {
+    if (!hasWailsRuntime()) return;
+    try {
+      const pinned = await ListPinned();
+      setPinnedPostIds(new Set((pinned.posts || []).map((p) => p.postId)));
+    } catch (e) {
+      console.error('Failed to load pinned posts:', e);
+    }
+  }, []);
+
   const loadIdentity = useCallback(async () => {
     if (!hasWailsRuntime()) return;
     try {
+      const status = await GetIdentityLockStatus();
+      if (!status.unlocked) return;
       const id = await LoadSavedIdentity();
       setIdentity(id);
       if (id.publicKey) {
@@ -272,44 +308,111 @@ function App() {
     }
   }, [loadRecommendedFeed]);
 
+  const loadProfilesFor = useCallback(async (pubkeys: string[]) => {
+    const uniquePubkeys = Array.from(new Set(pubkeys));
+    const resolvedProfiles = await Promise.all(
+      uniquePubkeys.map(async (pk) => {
+        try {
+          const profile = await GetProfile(pk);
+          return [pk, profile] as const;
+        } catch {
+          return null;
+        }
+      })
+    );
+    const mergedProfiles: Record<string, Profile> = {};
+    for (const entry of resolvedProfiles) {
+      if (!entry) continue;
+      mergedProfiles[entry[0]] = entry[1];
+    }
+    if (Object.keys(mergedProfiles).length > 0) {
+      setProfiles((prev) => ({ ...prev, ...mergedProfiles }));
+    }
+  }, []);
+
+  const loadCommentThread = useCallback(async (postId: string, sort: CommentSort) => {
+    const page = await GetCommentPage({
+      postId,
+      parentId: '',
+      sort,
+      limit: COMMENT_PAGE_LIMIT,
+      cursor: '',
+      depth: COMMENT_PAGE_DEPTH,
+      replyLimit: COMMENT_REPLY_LIMIT,
+    });
+    const { comments, meta } = flattenCommentNodes(page.comments);
+    setPostComments(comments);
+    setCommentThread({ sort, threadCount: page.threadCount, nextCursor: page.nextCursor, nodes: meta });
+    return comments;
+  }, []);
+
   const loadPostDetail = useCallback(async (post: Post) => {
     if (!hasWailsRuntime()) return;
     try {
       await TriggerCommentSyncNow(post.id);
       const body = await GetPostBodyByID(post.id);
       setPostBody(body.body || '');
-      const comments = await GetCommentsByPost(post.id);
-      setPostComments(comments);
-
-      const uniquePubkeys = Array.from(new Set([post.pubkey, ...comments.map((c: Comment) => c.pubkey)]));
-      const resolvedProfiles = await Promise.all(
-        uniquePubkeys.map(async (pk) => {
-          try {
-            const profile = await GetProfile(pk);
-            return [pk, profile] as const;
-          } catch {
-            return null;
-          }
-        })
-      );
-      const mergedProfiles: Record<string, Profile> = {};
-      for (const entry of resolvedProfiles) {
-        if (!entry) continue;
-        mergedProfiles[entry[0]] = entry[1];
-      }
-      if (Object.keys(mergedProfiles).length > 0) {
-        setProfiles((prev) => ({ ...prev, ...mergedProfiles }));
-      }
+      const comments = await loadCommentThread(post.id, commentThread.sort);
+      await loadProfilesFor([post.pubkey, ...comments.map((c: Comment) => c.pubkey)]);
     } catch (e) {
       console.error('Failed to load post detail:', e);
     }
-  }, []);
+  }, [loadCommentThread, loadProfilesFor, commentThread.sort]);
+
+  // loadMoreComments appends the next page of top-level comments, or with a
+  // parentId the next replies under that comment.
+  const loadMoreComments = useCallback(async (parentId: string = '') => {
+    if (!hasWailsRuntime() || !selectedPost) return;
+    const cursor = parentId ? commentThread.nodes[parentId]?.repliesCursor || '' : commentThread.nextCursor;
+    if (!parentId && !cursor) return;
+    try {
+      const page = await GetCommentPage({
+        postId: selectedPost.id,
+        parentId,
+        sort: commentThread.sort,
+        limit: parentId ? COMMENT_REPLY_LIMIT * 2 : COMMENT_PAGE_LIMIT,
+        cursor,
+        depth: COMMENT_PAGE_DEPTH - 1,
+        replyLimit: COMMENT_REPLY_LIMIT,
+      });
+      const { comments, meta } = flattenCommentNodes(page.comments);
+      setPostComments((prev) => {
+        const known = new Set(prev.map((c) => c.id));
+        return [...prev, ...comments.filter((c) => !known.has(c.id))];
+      });
+      setCommentThread((prev) => {
+        const nodes = { ...prev.nodes, ...meta };
+        if (parentId) {
+          nodes[parentId] = { replyCount: page.totalCount, repliesCursor: page.nextCursor };
+        }
+        return {
+          ...prev,
+          threadCount: page.threadCount,
+          nextCursor: parentId ? prev.nextCursor : page.nextCursor,
+          nodes,
+        };
+      });
+      await loadProfilesFor(comments.map((c) => c.pubkey));
+    } catch (e) {
+      console.error('Failed to load more comments:', e);
+    }
+  }, [selectedPost, commentThread, loadProfilesFor]);
+
+  const handleCommentSortChange = async (sort: CommentSort) => {
+    if (!hasWailsRuntime() || !selectedPost) return;
+    try {
+      const comments = await loadCommentThread(selectedPost.id, sort);
+      await loadProfilesFor(comments.map((c) => c.pubkey));
+    } catch (e) {
+      console.error('Failed to sort comments:', e);
+    }
+  };
 
-  const createIdentity = async (): Promise<Identity | null> => {
+  const createIdentity = async (passphrase: string): Promise<Identity | null> => {
     if (!hasWailsRuntime()) return null;
     setLoading(true);
     try {
-      const id = await GenerateIdentity();
+      const id = await GenerateIdentity(passphrase);
       return id;
     } catch (e) {
       console.error('Failed to create identity:', e);
@@ -319,11 +422,11 @@ function App() {
     }
   };
 
-  const importIdentity = async (mnemonic: string) => {
+  const importIdentity = async (mnemonic: string, passphrase: string) => {
     if (!hasWailsRuntime()) return;
     setLoading(true);
     try {
-      const id = await ImportIdentityFromMnemonic(mnemonic);
+      const id = await ImportIdentityFromMnemonic(mnemonic, passphrase);
       await activateIdentity(id);
     } catch (e) {
       console.error('Failed to import identity:', e);
@@ -333,6 +436,20 @@ function App() {
     }
   };
 
+  const unlockIdentity = async (passphrase: string) => {
+    if (!hasWailsRuntime()) return;
+    setLoading(true);
+    try {
+      const id = await UnlockIdentity(passphrase);
+      await activateIdentity(id);
+    } catch (e) {
+      console.error('Failed to unlock identity:', e);
+      throw e;
+    } finally {
+      setLoading(false);
+    }
+  };
+
   const handleCreateSub = async (id: string, title: string, description: string) => {
     if (!hasWailsRuntime() || !identity) return;
     try {
@@ -472,6 +589,23 @@ function App() {
     }
   };
 
+  const handleTogglePin = async (postId: string) => {
+    if (!hasWailsRuntime()) return;
+    try {
+      if (pinnedPostIds.has(postId)) {
+        await UnpinPost(postId);
+        addToast({ title: 'Unpinned', message: 'Post can be evicted again', type: 'info' });
+      } else {
+        await PinPost(postId);
+        addToast({ title: 'Pinned', message: 'Post and its images are kept for offline reading', type: 'success' });
+      }
+      await loadPinnedPosts();
+    } catch (e) {
+      console.error('Failed to toggle pin:', e);
+      addToast({ title: 'Error', message: `Failed to update pin: ${String(e)}`, type: 'error' });
+    }
+  };
+
   const handlePostClick = async (post: Post) => {
     setSelectedPost(post);
     setView('post-detail');
@@ -482,12 +616,12 @@ function App() {
     if (!hasWailsRuntime()) return;
     if (!selectedPost || selectedPost.id !== postId) return;
     try {
-      const comments = await GetCommentsByPost(postId);
-      setPostComments(comments);
+      const comments = await loadCommentThread(postId, commentThread.sort);
+      await loadProfilesFor(comments.map((c) => c.pubkey));
     } catch (e) {
       console.error('Failed to refresh comments:', e);
     }
-  }, [selectedPost]);
+  }, [selectedPost, loadCommentThread, loadProfilesFor, commentThread.sort]);
 
   const handleBackToFeed = () => {
     setSelectedPost(null);
@@ -557,16 +691,29 @@ function App() {
     }
     if (!hasWailsRuntime()) return;
     try {
-      const [subResults, postResults] = await Promise.all([
+      const [subResults, page] = await Promise.all([
         scope ? Promise.resolve([]) : SearchSubs(query, 10),
-        SearchPosts(query, scope || '', 10),
+        Search({ query, kind: '', subId: scope || '', author: '', since: 0, until: 0, limit: 10, cursor: '' }),
       ]);
-      setSearchResults({ subs: subResults, posts: postResults });
+      setSearchResults({ subs: subResults, hits: (page.hits || []) as SearchHit[] });
     } catch (e) {
       console.error('Failed to search:', e);
     }
   };
 
+  const handleSearchNetwork = async (query: string, scope?: string) => {
+    if (!query.trim() || !hasWailsRuntime()) return;
+    try {
+      const result = await SearchNetwork({ query, kind: '', subId: scope || '', author: '', since: 0, until: 0, limit: 20, cursor: '' });
+      if (result.responders === 0) {
+        console.warn('Network search: no peer answered');
+      }
+      await handleSearch(query, scope);
+    } catch (e) {
+      console.error('Failed to search the network:', e);
+    }
+  };
+
   const handleSearchResultClick = async (type: 'sub' | 'post', id: string) => {
     setSearchResults(null);
     setSearchQuery('');
@@ -651,8 +798,7 @@ function App() {
     if (!hasWailsRuntime() || !identity || !selectedPost) return;
     try {
       await PublishDeleteComment(identity.publicKey, commentId);
-      const comments = await GetCommentsByPost(selectedPost.id);
-      setPostComments(comments);
+      await loadCommentThread(selectedPost.id, commentThread.sort);
       bumpViewSyncToken();
       addToast({
         title: 'Comment Deleted',
@@ -772,6 +918,9 @@ function App() {
   };
 
   const handleSignOut = () => {
+    if (hasWailsRuntime()) {
+      LockIdentity();
+    }
     setIdentity(null);
     setProfile(null);
     setShowLoginModal(true);
@@ -881,6 +1030,17 @@ function App() {
     };
   }, [loadFavorites, bumpViewSyncToken]);
 
+  useEffect(() => {
+    if (!hasWailsRuntime()) return;
+    void loadPinnedPosts();
+    const unsubscribe = EventsOn('pins:updated', () => {
+      void loadPinnedPosts();
+    });
+    return () => {
+      unsubscribe();
+    };
+  }, [loadPinnedPosts]);
+
   useEffect(() => {
     if (!hasWailsRuntime() || !identity) return;
     const timer = window.setInterval(() => {
@@ -892,6 +1052,36 @@ function App() {
     };
   }, [identity, loadSubs, loadSubscribedSubs]);
 
+  useEffect(() => {
+    if (!hasWailsRuntime()) return;
+    const unsubscribe = EventsOn('alerts:updated', (payload: any) => {
+      const alert = payload?.alert;
+      if (!alert) return;
+      const fired = payload.event === 'fired';
+      const title = fired ? `Alert: ${alert.key}` : `Resolved: ${alert.key}`;
+      const message = `${alert.metric} = ${alert.value} (threshold ${alert.threshold})`;
+      addToast({
+        title,
+        message,
+        type: fired ? (alert.level === 'critical' ? 'error' : 'warning') : 'success',
+        duration: 10000,
+      });
+      if (typeof Notification === 'undefined') return;
+      if (Notification.permission === 'granted') {
+        new Notification(title, { body: message });
+      } else if (Notification.permission === 'default') {
+        void Notification.requestPermission().then((permission) => {
+          if (permission === 'granted') {
+            new Notification(title, { body: message });
+          }
+        });
+      }
+    });
+    return () => {
+      unsubscribe();
+    };
+  }, [addToast]);
+
   useEffect(() => {
     if (!hasWailsRuntime()) return;
     const unsubscribe = EventsOn('feed:updated', () => {
@@ -978,6 +1168,7 @@ function App() {
               setSearchQuery('');
               setSearchResults(null);
             }}
+            onSearchNetwork={handleSearchNetwork}
           />
 
           {view === 'feed' && (
@@ -1003,7 +1194,7 @@ function App() {
 
           {view === 'post-detail' && selectedPost && (
             <PostDetail
-              post={{ ...selectedPost, isFavorited: favoritePostIds.has(selectedPost.id) }}
+              post={{ ...selectedPost, isFavorited: favoritePostIds.has(selectedPost.id), isPinned: pinnedPostIds.has(selectedPost.id) }}
               body={postBody}
               comments={postComments}
               profiles={profiles}
@@ -1019,6 +1210,11 @@ function App() {
               onViewOperationTimeline={handleViewOperationTimeline}
               isDevMode={isDevMode}
               onToggleFavorite={handleToggleFavorite}
+              onTogglePin={handleTogglePin}
+              commentThread={commentThread}
+              onCommentSortChange={handleCommentSortChange}
+              onLoadMoreComments={() => loadMoreComments()}
+              onLoadMoreReplies={(parentId) => loadMoreComments(parentId)}
             />
           )}
 
@@ -1077,7 +1273,7 @@ function App() {
         onClose={() => setShowLoginModal(false)}
         onCreateIdentity={createIdentity}
         onActivateIdentity={activateIdentity}
-        onLoadIdentity={loadIdentity}
+        onUnlockIdentity={unlockIdentity}
         onImportMnemonic={importIdentity}
       />
 
@@ -1,5 +1,5 @@
 import { useEffect, useState } from 'react';
-import { Comment, Profile } from '../types';
+import { Comment, CommentThread, Profile } from '../types';
 import { GetMediaByCID } from '../../wailsjs/go/main/App';
 
 interface CommentItemProps {
@@ -252,9 +252,12 @@ interface CommentTreeProps {
   currentPubkey?: string;
   onDelete?: (commentId: string) => Promise<void> | void;
   onImageClick?: (src: string) => void;
+  thread?: CommentThread;
+  onLoadMore?: () => Promise<void> | void;
+  onLoadMoreReplies?: (parentId: string) => Promise<void> | void;
 }
 
-export function CommentTree({ comments, profiles, onReply, onUpvote, onDownvote, currentPubkey, onDelete, onImageClick }: CommentTreeProps) {
+export function CommentTree({ comments, profiles, onReply, onUpvote, onDownvote, currentPubkey, onDelete, onImageClick, thread, onLoadMore, onLoadMoreReplies }: CommentTreeProps) {
   const [previewImageSrc, setPreviewImageSrc] = useState<string | null>(null);
 
   const handleImageClick = (src: string) => {
@@ -270,6 +273,7 @@ export function CommentTree({ comments, profiles, onReply, onUpvote, onDownvote,
 
   const renderComment = (comment: Comment, depth: number = 0) => {
     const children = comments.filter(c => c.parentId === comment.id);
+    const unloadedReplies = (thread?.nodes[comment.id]?.replyCount ?? 0) - children.length;
 
     return (
       <div key={comment.id}>
@@ -285,6 +289,16 @@ export function CommentTree({ comments, profiles, onReply, onUpvote, onDownvote,
           depth={depth}
         />
         {children.map(child => renderComment(child, depth + 1))}
+        {unloadedReplies > 0 && onLoadMoreReplies && (
+          <div className="ml-5 md:ml-10 mt-2 pl-6">
+            <button
+              onClick={() => void onLoadMoreReplies(comment.id)}
+              className="text-xs font-medium text-warm-accent hover:underline"
+            >
+              {unloadedReplies === 1 ? 'Load 1 more reply' : `Load ${unloadedReplies} more replies`}
+            </button>
+          </div>
+        )}
       </div>
     );
   };
@@ -301,6 +315,14 @@ export function CommentTree({ comments, profiles, onReply, onUpvote, onDownvote,
   return (
     <div>
       {rootComments.map(comment => renderComment(comment))}
+      {thread?.nextCursor && onLoadMore && (
+        <button
+          onClick={() => void onLoadMore()}
+          className="w-full py-2 text-sm font-medium text-warm-accent hover:underline"
+        >
+          Load more comments
+        </button>
+      )}
       {previewImageSrc && (
         <div className="fixed inset-0 z-[90] bg-black/80 flex items-center justify-center p-4" onClick={() => setPreviewImageSrc(null)}>
           <img
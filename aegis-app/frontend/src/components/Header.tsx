@@ -1,5 +1,5 @@
 import { useState, useRef, useEffect } from 'react';
-import { Profile, Sub, ForumMessage } from '../types';
+import { Profile, Sub, SearchHit } from '../types';
 
 interface HeaderProps {
   currentSubId: string;
@@ -12,10 +12,11 @@ interface HeaderProps {
   isDark: boolean;
   onThemeToggle: () => void;
   searchQuery: string;
-  searchResults: { subs: Sub[]; posts: ForumMessage[] } | null;
+  searchResults: { subs: Sub[]; hits: SearchHit[] } | null;
   onSearch: (query: string, scope?: string) => void;
   onSearchResultClick: (type: 'sub' | 'post', id: string) => void;
   onSearchClear: () => void;
+  onSearchNetwork: (query: string, scope?: string) => Promise<void>;
 }
 
 function getInitials(name: string): string {
@@ -36,11 +37,13 @@ export function Header({
   searchResults,
   onSearch,
   onSearchResultClick,
-  onSearchClear
+  onSearchClear,
+  onSearchNetwork
 }: HeaderProps) {
   const [showDropdown, setShowDropdown] = useState(false);
   const [showUserMenu, setShowUserMenu] = useState(false);
   const [searchScope, setSearchScope] = useState<'global' | 'sub'>('global');
+  const [searchingNetwork, setSearchingNetwork] = useState(false);
   const searchRef = useRef<HTMLDivElement>(null);
   const userMenuRef = useRef<HTMLDivElement>(null);
   const inputRef = useRef<HTMLInputElement>(null);
@@ -76,6 +79,16 @@ export function Header({
     onSearch(value, scope);
   };
 
+  const handleSearchNetwork = async () => {
+    const scope = searchScope === 'sub' && currentSubId !== 'recommended' ? currentSubId : undefined;
+    setSearchingNetwork(true);
+    try {
+      await onSearchNetwork(searchQuery, scope);
+    } finally {
+      setSearchingNetwork(false);
+    }
+  };
+
   const toggleSearchScope = () => {
     const newScope = searchScope === 'global' ? 'sub' : 'global';
     setSearchScope(newScope);
@@ -174,35 +187,58 @@ export function Header({
                 </div>
               )}
               
-              {searchResults.posts.length > 0 && (
+              {searchResults.hits.length > 0 && (
                 <div className="p-2">
                   <div className="text-xs font-semibold text-warm-text-secondary dark:text-slate-400 uppercase mb-1 px-1">
-                    Posts
+                    Posts & Comments
                   </div>
-                  {searchResults.posts.map((post) => (
+                  {searchResults.hits.map((hit) => (
                     <button
-                      key={post.id}
+                      key={`${hit.kind}:${hit.id}`}
                       onClick={() => {
-                        onSearchResultClick('post', post.id);
+                        onSearchResultClick('post', hit.postId);
                         setShowDropdown(false);
                       }}
                       className="w-full flex items-start gap-2 px-2 py-2 hover:bg-warm-sidebar dark:hover:bg-surface-lighter rounded-lg transition-colors text-left"
                     >
-                      <span className="material-icons-outlined text-base text-warm-text-secondary mt-0.5">article</span>
+                      <span className="material-icons-outlined text-base text-warm-text-secondary mt-0.5">
+                        {hit.kind === 'comment' ? 'chat_bubble_outline' : 'article'}
+                      </span>
                       <div className="flex-1 min-w-0">
-                        <div className="text-sm font-medium text-warm-text-primary dark:text-white truncate">{post.title}</div>
-                        <div className="text-xs text-warm-text-secondary dark:text-slate-400 truncate">{post.body}</div>
+                        <div className="text-sm font-medium text-warm-text-primary dark:text-white truncate">
+                          {hit.kind === 'comment' ? `Comment on ${hit.postTitle}` : hit.postTitle}
+                        </div>
+                        <div className="text-xs text-warm-text-secondary dark:text-slate-400 line-clamp-2">
+                          {(hit.snippet || []).map((part, index) =>
+                            part.match ? (
+                              <mark key={index} className="bg-warm-accent/20 text-warm-text-primary dark:text-white rounded-sm">{part.text}</mark>
+                            ) : (
+                              <span key={index}>{part.text}</span>
+                            )
+                          )}
+                        </div>
                       </div>
                     </button>
                   ))}
                 </div>
               )}
               
-              {searchResults.subs.length === 0 && searchResults.posts.length === 0 && (
+              {searchResults.subs.length === 0 && searchResults.hits.length === 0 && (
                 <div className="p-4 text-center text-warm-text-secondary dark:text-slate-400 text-sm">
                   No results found
                 </div>
               )}
+
+              <div className="border-t border-warm-border dark:border-border-dark p-2">
+                <button
+                  onClick={handleSearchNetwork}
+                  disabled={searchingNetwork}
+                  className="w-full flex items-center justify-center gap-1 px-2 py-1.5 text-xs font-medium text-warm-accent hover:bg-warm-sidebar dark:hover:bg-surface-lighter rounded-lg transition-colors disabled:opacity-60"
+                >
+                  <span className="material-icons-outlined text-sm">travel_explore</span>
+                  {searchingNetwork ? 'Asking peers...' : 'Search the network'}
+                </button>
+              </div>
             </div>
           )}
         </div>
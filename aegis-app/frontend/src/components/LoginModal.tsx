@@ -4,14 +4,17 @@ import { Identity } from '../types';
 interface LoginModalProps {
   isOpen: boolean;
   onClose: () => void;
-  onCreateIdentity: () => Promise<Identity | null>;
+  onCreateIdentity: (passphrase: string) => Promise<Identity | null>;
   onActivateIdentity: (identity: Identity) => Promise<void> | void;
-  onLoadIdentity: () => void;
-  onImportMnemonic: (mnemonic: string) => Promise<void> | void;
+  onUnlockIdentity: (passphrase: string) => Promise<void> | void;
+  onImportMnemonic: (mnemonic: string, passphrase: string) => Promise<void> | void;
 }
 
-export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdentity, onLoadIdentity, onImportMnemonic }: LoginModalProps) {
+const MIN_PASSPHRASE_LENGTH = 8;
+
+export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdentity, onUnlockIdentity, onImportMnemonic }: LoginModalProps) {
   const [mnemonicInput, setMnemonicInput] = useState('');
+  const [passphrase, setPassphrase] = useState('');
   const [mode, setMode] = useState<'select' | 'import' | 'backup'>('select');
   const [createdIdentity, setCreatedIdentity] = useState<Identity | null>(null);
   const [hasBackedUp, setHasBackedUp] = useState(false);
@@ -21,6 +24,7 @@ export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdenti
   useEffect(() => {
     if (!isOpen) {
       setMnemonicInput('');
+      setPassphrase('');
       setMode('select');
       setCreatedIdentity(null);
       setHasBackedUp(false);
@@ -31,13 +35,32 @@ export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdenti
 
   if (!isOpen) return null;
 
+  const passphraseValid = passphrase.length >= MIN_PASSPHRASE_LENGTH;
+
+  const handleUnlock = async () => {
+    if (!passphrase) return;
+    setBusy(true);
+    setErrorMessage('');
+    try {
+      await onUnlockIdentity(passphrase);
+      setPassphrase('');
+      onClose();
+    } catch (error) {
+      console.error('Unlock identity failed:', error);
+      setErrorMessage('Unlock failed. Please check your passphrase.');
+    } finally {
+      setBusy(false);
+    }
+  };
+
   const handleImport = async () => {
-    if (!mnemonicInput.trim()) return;
+    if (!mnemonicInput.trim() || !passphraseValid) return;
     setBusy(true);
     setErrorMessage('');
     try {
-      await onImportMnemonic(mnemonicInput.trim());
+      await onImportMnemonic(mnemonicInput.trim(), passphrase);
       setMnemonicInput('');
+      setPassphrase('');
       onClose();
     } catch (error) {
       console.error('Import identity failed:', error);
@@ -48,15 +71,20 @@ export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdenti
   };
 
   const handleCreate = async () => {
+    if (!passphraseValid) {
+      setErrorMessage(`Choose a passphrase of at least ${MIN_PASSPHRASE_LENGTH} characters.`);
+      return;
+    }
     setBusy(true);
     setErrorMessage('');
     try {
-      const identity = await onCreateIdentity();
+      const identity = await onCreateIdentity(passphrase);
       if (!identity || !identity.mnemonic) {
         setErrorMessage('Failed to create identity. Please try again.');
         return;
       }
       setCreatedIdentity(identity);
+      setPassphrase('');
       setHasBackedUp(false);
       setMode('backup');
     } catch (error) {
@@ -124,7 +152,15 @@ export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdenti
             <div className="space-y-4">
               <p className="text-sm text-warm-text-secondary dark:text-slate-400 text-center mb-6">
                 Join the decentralized forum. Create a new identity or import an existing one.
+                Your key is encrypted on this device with your passphrase.
               </p>
+              <input
+                type="password"
+                value={passphrase}
+                onChange={(e) => setPassphrase(e.target.value)}
+                placeholder="Passphrase"
+                className="w-full px-4 py-2.5 rounded-lg border border-warm-border dark:border-border-dark bg-warm-bg dark:bg-background-dark text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent outline-none"
+              />
               <button
                 onClick={handleCreate}
                 disabled={busy}
@@ -133,10 +169,11 @@ export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdenti
                 {busy ? 'Creating...' : 'Create New Identity'}
               </button>
               <button
-                onClick={onLoadIdentity}
+                onClick={handleUnlock}
+                disabled={busy || !passphrase}
                 className="w-full py-3 bg-warm-card dark:bg-surface-lighter border border-warm-border dark:border-border-dark text-warm-text-primary dark:text-white font-medium rounded-lg hover:bg-warm-sidebar dark:hover:bg-border-dark transition-colors"
               >
-                Load Existing Identity
+                Unlock Existing Identity
               </button>
               <div className="relative my-4">
                 <div className="absolute inset-0 flex items-center">
@@ -168,6 +205,13 @@ export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdenti
                 rows={4}
                 className="w-full px-4 py-2.5 rounded-lg border border-warm-border dark:border-border-dark bg-warm-bg dark:bg-background-dark text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent outline-none resize-none"
               />
+              <input
+                type="password"
+                value={passphrase}
+                onChange={(e) => setPassphrase(e.target.value)}
+                placeholder="Passphrase"
+                className="w-full px-4 py-2.5 rounded-lg border border-warm-border dark:border-border-dark bg-warm-bg dark:bg-background-dark text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent outline-none"
+              />
               <div className="flex gap-3">
                 <button
                   onClick={() => setMode('select')}
@@ -178,7 +222,7 @@ export function LoginModal({ isOpen, onClose, onCreateIdentity, onActivateIdenti
                 </button>
                 <button
                   onClick={handleImport}
-                  disabled={!mnemonicInput.trim() || busy}
+                  disabled={!mnemonicInput.trim() || !passphraseValid || busy}
                   className="flex-1 py-2 bg-warm-accent hover:bg-warm-accent-hover text-white text-sm font-medium rounded-lg transition-colors disabled:opacity-50"
                 >
                   {busy ? 'Importing...' : 'Import'}
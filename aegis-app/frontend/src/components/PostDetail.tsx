@@ -1,10 +1,11 @@
 import { useState, useRef, useEffect } from 'react';
-import { Post, Comment, Profile } from '../types';
+import { Post, Comment, CommentSort, CommentThread, Profile, EntityRevision } from '../types';
 import { CommentTree } from './CommentTree';
-import { StoreCommentImageDataURL } from '../../wailsjs/go/main/App';
+import { GetPostRevisions, StoreCommentImageDataURL } from '../../wailsjs/go/main/App';
+import { EventsOn } from '../../wailsjs/runtime/runtime';
 
 interface PostDetailProps {
-  post: Post & { isFavorited?: boolean };
+  post: Post & { isFavorited?: boolean; isPinned?: boolean };
   body: string;
   comments: Comment[];
   profiles: Record<string, Profile>;
@@ -20,6 +21,11 @@ interface PostDetailProps {
   onViewOperationTimeline: (entityType: 'post' | 'comment', entityId: string) => void;
   isDevMode?: boolean;
   onToggleFavorite?: (postId: string) => void;
+  onTogglePin?: (postId: string) => void;
+  commentThread?: CommentThread;
+  onCommentSortChange?: (sort: CommentSort) => void;
+  onLoadMoreComments?: () => Promise<void>;
+  onLoadMoreReplies?: (parentId: string) => Promise<void>;
 }
 
 function formatTimeAgo(timestamp: number): string {
@@ -96,6 +102,11 @@ export function PostDetail({
   onViewOperationTimeline,
   isDevMode,
   onToggleFavorite,
+  onTogglePin,
+  commentThread,
+  onCommentSortChange,
+  onLoadMoreComments,
+  onLoadMoreReplies,
 }: PostDetailProps) {
   const [replyContent, setReplyContent] = useState('');
   const [replyToId, setReplyToId] = useState<string | null>(null);
@@ -108,6 +119,8 @@ export function PostDetail({
   const [imageInsertBusy, setImageInsertBusy] = useState(false);
   const [previewImageSrc, setPreviewImageSrc] = useState<string | null>(null);
   const [deletePostArmed, setDeletePostArmed] = useState(false);
+  const [revisions, setRevisions] = useState<EntityRevision[]>([]);
+  const [historyOpen, setHistoryOpen] = useState(false);
 
   const authorProfile = profiles[post.pubkey];
   const displayName = authorProfile?.displayName || post.pubkey.slice(0, 8);
@@ -115,6 +128,29 @@ export function PostDetail({
   const canDeletePost = currentPubkey && currentPubkey === post.pubkey;
 
   const replyingToComment = replyToId ? comments.find((c) => c.id === replyToId) : null;
+  const commentCount = commentThread?.threadCount ?? comments.length;
+
+  useEffect(() => {
+    if (!(window as any)?.go?.main?.App) return;
+    let cancelled = false;
+    const loadRevisions = () => {
+      GetPostRevisions(post.id)
+        .then((items) => {
+          if (!cancelled) setRevisions((items || []) as EntityRevision[]);
+        })
+        .catch(() => {
+          if (!cancelled) setRevisions([]);
+        });
+    };
+    loadRevisions();
+    const unsubscribe = EventsOn('revisions:updated', (payload: { postId?: string; commentId?: string } | undefined) => {
+      if (payload?.postId === post.id && !payload?.commentId) loadRevisions();
+    });
+    return () => {
+      cancelled = true;
+      unsubscribe();
+    };
+  }, [post.id, post.contentCid]);
 
   useEffect(() => {
     if (replyToId && replyInputRef.current) {
@@ -248,6 +284,18 @@ export function PostDetail({
                 </div>
                 <div className="text-xs text-warm-text-secondary dark:text-slate-400 flex items-center gap-1">
                   <span>{formatTimeAgo(post.timestamp)}</span>
+                  {revisions.length > 1 && (
+                    <>
+                      <span>•</span>
+                      <button
+                        onClick={() => setHistoryOpen(true)}
+                        className="hover:text-warm-accent underline"
+                        title="View edit history"
+                      >
+                        edited
+                      </button>
+                    </>
+                  )}
                   {isDevMode && (
                     <>
                       <span>•</span>
@@ -319,7 +367,7 @@ export function PostDetail({
                 className="flex items-center gap-2 text-sm font-medium text-warm-text-secondary hover:text-warm-text-primary px-3 py-1.5 rounded-lg hover:bg-warm-sidebar dark:hover:bg-surface-lighter transition-colors"
               >
                 <span className="material-icons-outlined text-lg">chat_bubble_outline</span>
-                {comments.length} Comments
+                {commentCount} Comments
               </button>
 
               <button className="flex items-center gap-2 text-sm font-medium text-warm-text-secondary hover:text-warm-text-primary px-3 py-1.5 rounded-lg hover:bg-warm-sidebar dark:hover:bg-surface-lighter transition-colors">
@@ -342,6 +390,23 @@ export function PostDetail({
                 </span>
                 {post.isFavorited ? 'Saved' : 'Save'}
               </button>
+
+              <button
+                onClick={() => {
+                  if (onTogglePin) onTogglePin(post.id);
+                }}
+                title="Keep this post, its images and comment images for offline reading"
+                className={`flex items-center gap-2 text-sm font-medium px-3 py-1.5 rounded-lg transition-colors ${
+                  post.isPinned
+                    ? 'text-warm-accent bg-warm-accent/10 hover:bg-warm-accent/20'
+                    : 'text-warm-text-secondary hover:text-warm-text-primary hover:bg-warm-sidebar dark:hover:bg-surface-lighter'
+                }`}
+              >
+                <span className="material-icons-outlined text-lg">
+                  {post.isPinned ? 'push_pin' : 'offline_pin'}
+                </span>
+                {post.isPinned ? 'Pinned' : 'Pin'}
+              </button>
             </div>
 
             <div className="text-xs text-warm-text-secondary dark:text-slate-400 font-mono">
@@ -353,16 +418,19 @@ export function PostDetail({
         <div className="mb-8">
           <div className="flex items-center justify-between mb-4">
             <h3 className="text-lg font-bold text-warm-text-primary dark:text-white">
-              Comments <span className="text-warm-text-secondary dark:text-slate-400 text-sm font-normal">({comments.length})</span>
+              Comments <span className="text-warm-text-secondary dark:text-slate-400 text-sm font-normal">({commentCount})</span>
             </h3>
             <div className="flex items-center gap-2">
               <span className="text-xs font-medium text-warm-text-secondary dark:text-slate-400 uppercase tracking-wide">Sort by:</span>
               <div className="relative">
                 <select
+                  value={commentThread?.sort ?? 'top'}
+                  onChange={(e) => onCommentSortChange?.(e.target.value as CommentSort)}
                   className="appearance-none bg-warm-card dark:bg-surface-dark border border-warm-border dark:border-border-dark text-sm font-bold text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent cursor-pointer py-1.5 pl-3 pr-8 rounded-lg outline-none transition-colors"
                 >
-                  <option value="best">Best</option>
-                  <option value="newest">Newest</option>
+                  <option value="top">Top</option>
+                  <option value="new">Newest</option>
+                  <option value="old">Oldest</option>
                   <option value="controversial">Controversial</option>
                 </select>
                 <span className="material-icons absolute right-2 top-1/2 -translate-y-1/2 pointer-events-none text-warm-text-secondary dark:text-slate-400 text-base">
@@ -493,6 +561,9 @@ export function PostDetail({
             currentPubkey={currentPubkey}
             onDelete={onDeleteComment}
             onImageClick={(src) => setPreviewImageSrc(src)}
+            thread={commentThread}
+            onLoadMore={onLoadMoreComments}
+            onLoadMoreReplies={onLoadMoreReplies}
           />
         </div>
       </div>
@@ -514,6 +585,67 @@ export function PostDetail({
           </button>
         </div>
       )}
+      {historyOpen && (
+        <div className="fixed inset-0 z-[85] flex items-center justify-center bg-black/60 backdrop-blur-sm px-4" onClick={() => setHistoryOpen(false)}>
+          <div
+            className="w-full max-w-2xl max-h-[80vh] overflow-y-auto rounded-2xl border border-warm-border dark:border-border-dark bg-warm-card dark:bg-surface-dark p-6 shadow-2xl"
+            onClick={(e) => e.stopPropagation()}
+          >
+            <div className="flex items-center justify-between mb-4">
+              <h4 className="text-lg font-bold text-warm-text-primary dark:text-white">Edit History</h4>
+              <button
+                onClick={() => setHistoryOpen(false)}
+                className="text-warm-text-secondary hover:text-warm-text-primary p-1 rounded-full"
+                title="Close"
+              >
+                <span className="material-icons text-xl">close</span>
+              </button>
+            </div>
+            <div className="space-y-4">
+              {[...revisions].reverse().map((revision) => (
+                <div key={revision.opId} className="rounded-lg border border-warm-border dark:border-border-dark p-3">
+                  <div className="flex flex-wrap items-center gap-2 text-xs text-warm-text-secondary dark:text-slate-400 mb-2">
+                    <span>{new Date(revision.timestamp * 1000).toLocaleString()}</span>
+                    <span>•</span>
+                    <span>lamport {revision.lamport}</span>
+                    <span>•</span>
+                    <span className="font-mono" title={revision.contentCid}>{revision.contentCid.slice(0, 16)}</span>
+                    {revision.current && (
+                      <span className="bg-warm-sidebar dark:bg-surface-lighter px-2 py-0.5 rounded-full border border-warm-border dark:border-slate-700">current</span>
+                    )}
+                  </div>
+                  {revision.title && (
+                    <p className="text-sm font-semibold text-warm-text-primary dark:text-white mb-2">{revision.title}</p>
+                  )}
+                  {revision.diff && revision.diff.length > 0 ? (
+                    <pre className="text-xs font-mono whitespace-pre-wrap break-words">
+                      {revision.diff.map((chunk, i) => (
+                        <div
+                          key={i}
+                          className={
+                            chunk.op === 'insert'
+                              ? 'bg-green-50 dark:bg-green-900/20 text-green-700 dark:text-green-400'
+                              : chunk.op === 'delete'
+                                ? 'bg-red-50 dark:bg-red-900/20 text-red-700 dark:text-red-400 line-through'
+                                : 'text-warm-text-secondary dark:text-slate-400'
+                          }
+                        >
+                          {chunk.text}
+                        </div>
+                      ))}
+                    </pre>
+                  ) : (
+                    <p className="text-xs italic text-warm-text-secondary dark:text-slate-400">
+                      {revision.bodyAvailable ? 'No body changes.' : 'Body no longer stored.'}
+                    </p>
+                  )}
+                </div>
+              ))}
+            </div>
+          </div>
+        </div>
+      )}
+
       {canDeletePost && deletePostArmed && (
         <div className="fixed inset-0 z-[85] flex items-center justify-center bg-black/60 backdrop-blur-sm px-4 transition-all duration-300" onClick={() => setDeletePostArmed(false)}>
           <div
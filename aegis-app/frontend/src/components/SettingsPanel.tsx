@@ -1,6 +1,6 @@
 import { ChangeEvent, useEffect, useRef, useState } from 'react';
 import { AntiEntropyStats, EntityOpRecord, GovernanceAdmin, ModerationLog, ModerationState, Profile, TombstoneGCResult } from '../types';
-import { CheckForUpdates, GetAntiEntropyStats, GetGovernancePolicy, GetP2PConfig, GetP2PStatus, GetPrivacySettings, GetStorageUsage, GetVersionHistory, ListEntityOps, ResetLocalTestData, RunTombstoneGC, SaveP2PConfig, SetGovernancePolicy, SetPrivacySettings, StartP2P, StopP2P, UpdateProfileDetails } from '../../wailsjs/go/main/App';
+import { CheckForUpdates, GetAntiEntropyStats, GetGovernancePolicy, GetP2PConfig, GetP2PStatus, GetPrivacySettings, GetStorageEvictions, GetStorageQuotas, GetStorageUsage, GetVersionHistory, ListEntityOps, ResetLocalTestData, RunTombstoneGC, SaveP2PConfig, SetGovernancePolicy, SetPrivacySettings, SetStorageQuotas, StartP2P, StopP2P, UpdateProfileDetails } from '../../wailsjs/go/main/App';
 import { EventsOn } from '../../wailsjs/runtime/runtime';
 
 interface SettingsPanelProps {
@@ -36,11 +36,35 @@ type P2PStatusView = {
 type StorageUsageView = {
   privateUsedBytes: number;
   publicUsedBytes: number;
+  mediaUsedBytes: number;
+  pinnedUsedBytes: number;
+  totalUsedBytes: number;
   privateQuota: number;
   publicQuota: number;
+  mediaQuota: number;
+  pinnedQuota: number;
   totalQuota: number;
 };
 
+type StorageEvictionView = {
+  id: number;
+  at: number;
+  kind: string;
+  sizeBytes: number;
+  postId: string;
+  reason: string;
+};
+
+type StorageQuotaInput = {
+  total: string;
+  private: string;
+  public: string;
+  media: string;
+  pinned: string;
+};
+
+const MIB = 1024 * 1024;
+
 type UpdateStatusView = {
   currentVersion: string;
   latestVersion: string;
@@ -102,6 +126,9 @@ export function SettingsPanel({
   const [storageUsage, setStorageUsage] = useState<StorageUsageView | null>(null);
   const [storageLoading, setStorageLoading] = useState(false);
   const [storageMessage, setStorageMessage] = useState('');
+  const [storageQuotaInput, setStorageQuotaInput] = useState<StorageQuotaInput>({ total: '', private: '', public: '', media: '', pinned: '' });
+  const [storageQuotaBusy, setStorageQuotaBusy] = useState(false);
+  const [storageEvictions, setStorageEvictions] = useState<StorageEvictionView[]>([]);
   const [resetBusy, setResetBusy] = useState(false);
   const [resetConfirmArmed, setResetConfirmArmed] = useState(false);
   const [updateStatus, setUpdateStatus] = useState<UpdateStatusView | null>(null);
@@ -235,8 +262,16 @@ export function SettingsPanel({
     if (!hasWailsRuntime()) return;
     setStorageLoading(true);
     try {
-      const usage = await GetStorageUsage();
+      const [usage, quotas, evictions] = await Promise.all([GetStorageUsage(), GetStorageQuotas(), GetStorageEvictions(10)]);
       setStorageUsage(usage);
+      setStorageEvictions(evictions || []);
+      setStorageQuotaInput({
+        total: String(Math.round(quotas.totalBytes / MIB)),
+        private: String(Math.round(quotas.privateBytes / MIB)),
+        public: String(Math.round(quotas.publicBytes / MIB)),
+        media: String(Math.round(quotas.mediaBytes / MIB)),
+        pinned: String(Math.round(quotas.pinnedBytes / MIB)),
+      });
       setStorageMessage('');
     } catch (error) {
       console.error('Failed to load storage usage:', error);
@@ -602,6 +637,30 @@ export function SettingsPanel({
     return port;
   };
 
+  const handleSaveStorageQuotas = async () => {
+    if (!hasWailsRuntime()) return;
+    const values = [storageQuotaInput.total, storageQuotaInput.private, storageQuotaInput.public, storageQuotaInput.media, storageQuotaInput.pinned]
+      .map((raw) => Number.parseInt(raw, 10));
+    if (values.some((value) => !Number.isFinite(value) || value <= 0)) {
+      setStorageMessage('Quotas must be positive numbers of MB.');
+      return;
+    }
+
+    setStorageQuotaBusy(true);
+    setStorageMessage('');
+    try {
+      const [total, privateQuota, publicQuota, media, pinned] = values.map((value) => value * MIB);
+      await SetStorageQuotas(total, privateQuota, publicQuota, media, pinned);
+      await loadStorageUsage();
+      setStorageMessage('Storage quotas saved.');
+    } catch (error) {
+      console.error('Failed to save storage quotas:', error);
+      setStorageMessage(`Failed to save storage quotas: ${String(error)}`);
+    } finally {
+      setStorageQuotaBusy(false);
+    }
+  };
+
   const handleSaveP2PConfig = async () => {
     if (!hasWailsRuntime()) return;
     const port = parseListenPort();
@@ -1249,9 +1308,9 @@ export function SettingsPanel({
                       <p className="text-sm text-warm-text-secondary dark:text-slate-300">{storageMessage}</p>
                     )}
 
-                    <div className="grid gap-4 md:grid-cols-3">
+                    <div className="grid gap-4 md:grid-cols-5">
                       <div className="rounded-lg border border-warm-border dark:border-border-dark bg-warm-bg dark:bg-background-dark p-3">
-                        <p className="text-xs uppercase text-warm-text-secondary dark:text-slate-400">Shared Responsibility</p>
+                        <p className="text-xs uppercase text-warm-text-secondary dark:text-slate-400">Public Zone</p>
                         <p className="mt-1 text-sm font-semibold text-warm-text-primary dark:text-white">
                           {formatBytes(storageUsage?.publicUsedBytes || 0)} / {formatBytes(storageUsage?.publicQuota || 0)}
                         </p>
@@ -1263,7 +1322,7 @@ export function SettingsPanel({
                         </div>
                       </div>
                       <div className="rounded-lg border border-warm-border dark:border-border-dark bg-warm-bg dark:bg-background-dark p-3">
-                        <p className="text-xs uppercase text-warm-text-secondary dark:text-slate-400">Private Responsibility</p>
+                        <p className="text-xs uppercase text-warm-text-secondary dark:text-slate-400">Private Zone</p>
                         <p className="mt-1 text-sm font-semibold text-warm-text-primary dark:text-white">
                           {formatBytes(storageUsage?.privateUsedBytes || 0)} / {formatBytes(storageUsage?.privateQuota || 0)}
                         </p>
@@ -1274,24 +1333,125 @@ export function SettingsPanel({
                           />
                         </div>
                       </div>
+                      <div className="rounded-lg border border-warm-border dark:border-border-dark bg-warm-bg dark:bg-background-dark p-3">
+                        <p className="text-xs uppercase text-warm-text-secondary dark:text-slate-400">Media</p>
+                        <p className="mt-1 text-sm font-semibold text-warm-text-primary dark:text-white">
+                          {formatBytes(storageUsage?.mediaUsedBytes || 0)} / {formatBytes(storageUsage?.mediaQuota || 0)}
+                        </p>
+                        <div className="mt-2 h-2 rounded bg-slate-200 dark:bg-slate-700 overflow-hidden">
+                          <div
+                            className="h-full bg-amber-500"
+                            style={{ width: `${usagePercent(storageUsage?.mediaUsedBytes || 0, storageUsage?.mediaQuota || 0)}%` }}
+                          />
+                        </div>
+                      </div>
+                      <div className="rounded-lg border border-warm-border dark:border-border-dark bg-warm-bg dark:bg-background-dark p-3">
+                        <p className="text-xs uppercase text-warm-text-secondary dark:text-slate-400">Pinned</p>
+                        <p className="mt-1 text-sm font-semibold text-warm-text-primary dark:text-white">
+                          {formatBytes(storageUsage?.pinnedUsedBytes || 0)} / {formatBytes(storageUsage?.pinnedQuota || 0)}
+                        </p>
+                        <div className="mt-2 h-2 rounded bg-slate-200 dark:bg-slate-700 overflow-hidden">
+                          <div
+                            className="h-full bg-sky-500"
+                            style={{ width: `${usagePercent(storageUsage?.pinnedUsedBytes || 0, storageUsage?.pinnedQuota || 0)}%` }}
+                          />
+                        </div>
+                      </div>
                       <div className="rounded-lg border border-warm-border dark:border-border-dark bg-warm-bg dark:bg-background-dark p-3">
                         <p className="text-xs uppercase text-warm-text-secondary dark:text-slate-400">Total Quota</p>
                         <p className="mt-1 text-sm font-semibold text-warm-text-primary dark:text-white">
-                          {formatBytes((storageUsage?.publicUsedBytes || 0) + (storageUsage?.privateUsedBytes || 0))} / {formatBytes(storageUsage?.totalQuota || 0)}
+                          {formatBytes(storageUsage?.totalUsedBytes || 0)} / {formatBytes(storageUsage?.totalQuota || 0)}
                         </p>
                         <div className="mt-2 h-2 rounded bg-slate-200 dark:bg-slate-700 overflow-hidden">
                           <div
                             className="h-full bg-indigo-500"
-                            style={{ width: `${usagePercent((storageUsage?.publicUsedBytes || 0) + (storageUsage?.privateUsedBytes || 0), storageUsage?.totalQuota || 0)}%` }}
+                            style={{ width: `${usagePercent(storageUsage?.totalUsedBytes || 0, storageUsage?.totalQuota || 0)}%` }}
                           />
                         </div>
                       </div>
                     </div>
 
                     <p className="text-xs text-warm-text-secondary dark:text-slate-400">
-                      Shared = data your node may serve to peers. Private = local-only responsibility (including policy-blocked shadow-ban data).
+                      Zone quotas cover post index rows and post bodies in that zone. Media covers images. Pinned covers the bodies and images of pinned posts, which are never evicted. Total covers everything together. When a quota is full, the least recently used unpinned blobs are evicted first.
                     </p>
 
+                    <div className="grid gap-3 md:grid-cols-6 items-end">
+                      <div>
+                        <label className="block text-xs font-medium text-warm-text-secondary dark:text-slate-400 mb-1">Public (MB)</label>
+                        <input
+                          type="number"
+                          min={1}
+                          value={storageQuotaInput.public}
+                          onChange={(e) => setStorageQuotaInput((prev) => ({ ...prev, public: e.target.value }))}
+                          className="w-full px-3 py-2 rounded-lg border border-warm-border dark:border-border-dark bg-white dark:bg-surface-dark text-sm text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent outline-none"
+                        />
+                      </div>
+                      <div>
+                        <label className="block text-xs font-medium text-warm-text-secondary dark:text-slate-400 mb-1">Private (MB)</label>
+                        <input
+                          type="number"
+                          min={1}
+                          value={storageQuotaInput.private}
+                          onChange={(e) => setStorageQuotaInput((prev) => ({ ...prev, private: e.target.value }))}
+                          className="w-full px-3 py-2 rounded-lg border border-warm-border dark:border-border-dark bg-white dark:bg-surface-dark text-sm text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent outline-none"
+                        />
+                      </div>
+                      <div>
+                        <label className="block text-xs font-medium text-warm-text-secondary dark:text-slate-400 mb-1">Media (MB)</label>
+                        <input
+                          type="number"
+                          min={1}
+                          value={storageQuotaInput.media}
+                          onChange={(e) => setStorageQuotaInput((prev) => ({ ...prev, media: e.target.value }))}
+                          className="w-full px-3 py-2 rounded-lg border border-warm-border dark:border-border-dark bg-white dark:bg-surface-dark text-sm text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent outline-none"
+                        />
+                      </div>
+                      <div>
+                        <label className="block text-xs font-medium text-warm-text-secondary dark:text-slate-400 mb-1">Pinned (MB)</label>
+                        <input
+                          type="number"
+                          min={1}
+                          value={storageQuotaInput.pinned}
+                          onChange={(e) => setStorageQuotaInput((prev) => ({ ...prev, pinned: e.target.value }))}
+                          className="w-full px-3 py-2 rounded-lg border border-warm-border dark:border-border-dark bg-white dark:bg-surface-dark text-sm text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent outline-none"
+                        />
+                      </div>
+                      <div>
+                        <label className="block text-xs font-medium text-warm-text-secondary dark:text-slate-400 mb-1">Total (MB)</label>
+                        <input
+                          type="number"
+                          min={1}
+                          value={storageQuotaInput.total}
+                          onChange={(e) => setStorageQuotaInput((prev) => ({ ...prev, total: e.target.value }))}
+                          className="w-full px-3 py-2 rounded-lg border border-warm-border dark:border-border-dark bg-white dark:bg-surface-dark text-sm text-warm-text-primary dark:text-white focus:ring-2 focus:ring-warm-accent focus:border-transparent outline-none"
+                        />
+                      </div>
+                      <button
+                        onClick={() => void handleSaveStorageQuotas()}
+                        disabled={storageQuotaBusy}
+                        className="px-4 py-2 bg-warm-accent hover:bg-warm-accent-hover text-white rounded-lg font-medium transition-colors disabled:opacity-50 disabled:cursor-not-allowed"
+                      >
+                        {storageQuotaBusy ? 'Saving...' : 'Save Quotas'}
+                      </button>
+                    </div>
+
+                    <div className="space-y-2">
+                      <p className="text-xs uppercase text-warm-text-secondary dark:text-slate-400">Recently Evicted</p>
+                      {storageEvictions.length === 0 ? (
+                        <p className="text-xs text-warm-text-secondary dark:text-slate-400">Nothing has been evicted.</p>
+                      ) : (
+                        storageEvictions.map((eviction) => (
+                          <div key={eviction.id} className="bg-warm-bg dark:bg-background-dark rounded-lg p-2 text-xs text-warm-text-secondary dark:text-slate-400">
+                            <span className="font-medium text-warm-text-primary dark:text-white">
+                              {eviction.kind === 'media' ? 'Image' : 'Post body'} {formatBytes(eviction.sizeBytes)}
+                            </span>
+                            {eviction.postId && <span className="font-mono"> · {eviction.postId.slice(0, 12)}</span>}
+                            <span> · {formatDateTime(eviction.at)} · {eviction.reason}</span>
+                          </div>
+                        ))
+                      )}
+                    </div>
+
                     <div className="pt-2 border-t border-warm-border dark:border-border-dark">
                       <div className="flex flex-wrap items-center justify-between gap-3">
                         <div>
@@ -1463,7 +1623,7 @@ export function SettingsPanel({
                       </button>
                       {gcResult && (
                         <span className="text-xs text-warm-text-secondary dark:text-slate-400">
-                          scanned(post/comment): {gcResult.scannedPosts}/{gcResult.scannedComments}, deleted(post/comment): {gcResult.deletedPosts}/{gcResult.deletedComments}
+                          scanned(post/comment): {gcResult.scannedPosts}/{gcResult.scannedComments}, deleted(post/comment): {gcResult.deletedPosts}/{gcResult.deletedComments}, revision bodies released: {gcResult.releasedRevisionBodies}
                         </span>
                       )}
                     </div>
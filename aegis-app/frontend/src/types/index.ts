@@ -33,7 +33,7 @@ export interface Profile {
 }
 
 export interface Identity {
-  mnemonic: string;
+  mnemonic?: string;
   publicKey: string;
 }
 
@@ -54,6 +54,36 @@ export interface Comment {
   timestamp: number;
 }
 
+export type CommentSort = 'new' | 'old' | 'top' | 'controversial';
+
+export interface CommentNode extends Comment {
+  upvotes: number;
+  downvotes: number;
+  replyCount: number;
+  descendantCount: number;
+  replies: CommentNode[];
+  repliesCursor: string;
+}
+
+export interface CommentPage {
+  postId: string;
+  parentId: string;
+  sort: CommentSort;
+  totalCount: number;
+  threadCount: number;
+  comments: CommentNode[];
+  nextCursor: string;
+}
+
+// CommentThread is what a paged thread has left to load: the next page of
+// top-level comments, and per comment its reply count and replies cursor.
+export interface CommentThread {
+  sort: CommentSort;
+  threadCount: number;
+  nextCursor: string;
+  nodes: Record<string, { replyCount: number; repliesCursor: string }>;
+}
+
 export interface CommentAttachment {
   kind: string;
   ref: string;
@@ -113,6 +143,49 @@ export interface TombstoneGCResult {
   deletedPosts: number;
   scannedComments: number;
   deletedComments: number;
+  releasedRevisionBodies: number;
+}
+
+export interface OpCompactionResult {
+  entityOps: number;
+  voteOps: number;
+  favoriteOps: number;
+  moderationLogs: number;
+}
+
+export interface MaintenanceRun {
+  id: number;
+  trigger: 'scheduled' | 'manual';
+  startedAt: number;
+  finishedAt: number;
+  durationMs: number;
+  gc: TombstoneGCResult;
+  compaction: OpCompactionResult;
+  searchRepaired: number;
+  vacuumConverted: boolean;
+  freedPages: number;
+  freelistPages: number;
+  analyzed: boolean;
+  errors: string[];
+}
+
+export interface RevisionDiffChunk {
+  op: 'equal' | 'insert' | 'delete';
+  text: string;
+}
+
+export interface EntityRevision {
+  opId: string;
+  entityType: 'post' | 'comment';
+  entityId: string;
+  author: string;
+  title?: string;
+  contentCid: string;
+  lamport: number;
+  timestamp: number;
+  current: boolean;
+  bodyAvailable: boolean;
+  diff: RevisionDiffChunk[] | null;
 }
 
 export interface ForumMessage {
@@ -137,6 +210,23 @@ export interface ForumMessage {
   visibility: string;
 }
 
+export interface SearchSnippetPart {
+  text: string;
+  match: boolean;
+}
+
+export interface SearchHit {
+  kind: 'post' | 'comment';
+  id: string;
+  postId: string;
+  postTitle: string;
+  subId: string;
+  pubkey: string;
+  timestamp: number;
+  rank: number;
+  snippet: SearchSnippetPart[];
+}
+
 export interface Post extends PostIndex {
   authorProfile?: Profile;
 }
@@ -20,20 +20,38 @@ export function ApplyShadowBan(arg1:string,arg2:string,arg3:string):Promise<void
 
 export function ApplyUnban(arg1:string,arg2:string,arg3:string):Promise<void>;
 
+export function ChangeIdentityPassphrase(arg1:string,arg2:string):Promise<void>;
+
 export function CheckForUpdates():Promise<main.UpdateStatus>;
 
 export function ConnectPeer(arg1:string):Promise<void>;
 
+export function CreateIdentity(arg1:string,arg2:string):Promise<main.Identity>;
+
 export function CreateSub(arg1:string,arg2:string,arg3:string):Promise<main.Sub>;
 
+export function DeleteIdentity(arg1:string,arg2:string):Promise<void>;
+
 export function DownvoteComment(arg1:string):Promise<void>;
 
 export function DownvotePost(arg1:string):Promise<void>;
 
-export function GenerateIdentity():Promise<main.Identity>;
+export function ExportArchive(arg1:string,arg2:main.ArchiveOptions):Promise<main.ArchiveSummary>;
+
+export function ExportIdentityMnemonic(arg1:string):Promise<string>;
+
+export function GenerateIdentity(arg1:string):Promise<main.Identity>;
+
+export function GetAlertHistory(arg1:number,arg2:number,arg3:number):Promise<Array<main.ReleaseAlertEvent>>;
+
+export function GetAlertRules():Promise<main.AlertRulesStatus>;
 
 export function GetAntiEntropyStats():Promise<main.AntiEntropyStats>;
 
+export function GetCommentPage(arg1:main.CommentPageRequest):Promise<main.CommentPage>;
+
+export function GetCommentRevisions(arg1:string):Promise<Array<main.EntityRevision>>;
+
 export function GetCommentsByPost(arg1:string):Promise<Array<main.Comment>>;
 
 export function GetFavoritePostIDs():Promise<Array<string>>;
@@ -54,10 +72,16 @@ export function GetFeedStreamWithStrategy(arg1:number,arg2:string):Promise<main.
 
 export function GetGovernancePolicy():Promise<main.GovernancePolicy>;
 
+export function GetIdentityLockStatus():Promise<main.IdentityLockStatus>;
+
 export function GetIdentityState():Promise<Array<main.IdentityState>>;
 
+export function GetMaintenanceRuns(arg1:number):Promise<Array<main.MaintenanceRun>>;
+
 export function GetMediaByCID(arg1:string):Promise<main.MediaBlob>;
 
+export function GetMetricHistory(arg1:string,arg2:number,arg3:number,arg4:number):Promise<Array<main.MetricHistoryPoint>>;
+
 export function GetModerationLogs(arg1:number):Promise<Array<main.ModerationLog>>;
 
 export function GetModerationState():Promise<Array<main.ModerationState>>;
@@ -76,6 +100,8 @@ export function GetPostIndexByID(arg1:string):Promise<main.PostIndex>;
 
 export function GetPostMediaByID(arg1:string):Promise<main.MediaBlob>;
 
+export function GetPostRevisions(arg1:string):Promise<Array<main.EntityRevision>>;
+
 export function GetPrivacySettings():Promise<main.PrivacySettings>;
 
 export function GetPrivateFeed():Promise<Array<main.ForumMessage>>;
@@ -84,10 +110,16 @@ export function GetProfile(arg1:string):Promise<main.Profile>;
 
 export function GetProfileDetails(arg1:string):Promise<main.ProfileDetails>;
 
+export function GetRecommendationStrategies():Promise<main.RecommendationStrategiesStatus>;
+
 export function GetReleaseAlerts():Promise<Array<main.ReleaseAlert>>;
 
 export function GetReleaseMetrics():Promise<main.ReleaseMetrics>;
 
+export function GetStorageEvictions(arg1:number):Promise<Array<main.StorageEviction>>;
+
+export function GetStorageQuotas():Promise<main.StorageQuotas>;
+
 export function GetStorageUsage():Promise<main.StorageUsage>;
 
 export function GetSubs():Promise<Array<main.Sub>>;
@@ -98,16 +130,28 @@ export function GetTrustedAdmins():Promise<Array<main.GovernanceAdmin>>;
 
 export function GetVersionHistory(arg1:number):Promise<Array<main.VersionHistoryItem>>;
 
-export function ImportIdentityFromMnemonic(arg1:string):Promise<main.Identity>;
+export function ImportArchive(arg1:string):Promise<main.ArchiveSummary>;
+
+export function ImportIdentityFromMnemonic(arg1:string,arg2:string):Promise<main.Identity>;
 
 export function IsDevMode():Promise<boolean>;
 
 export function IsFavorited(arg1:string):Promise<boolean>;
 
+export function IsIdentityUnlocked():Promise<boolean>;
+
 export function ListEntityOps(arg1:string,arg2:string,arg3:number):Promise<Array<main.EntityOpRecord>>;
 
+export function ListIdentities():Promise<Array<main.LocalIdentity>>;
+
+export function ListPinned():Promise<main.PinnedContent>;
+
 export function LoadSavedIdentity():Promise<main.Identity>;
 
+export function LockIdentity():Promise<void>;
+
+export function PinPost(arg1:string):Promise<void>;
+
 export function ProcessIncomingMessage(arg1:Array<number>):Promise<void>;
 
 export function PublishComment(arg1:string,arg2:string,arg3:string,arg4:string):Promise<void>;
@@ -142,14 +186,26 @@ export function PublishShadowBan(arg1:string,arg2:string,arg3:string):Promise<vo
 
 export function PublishUnban(arg1:string,arg2:string,arg3:string):Promise<void>;
 
+export function ReloadAlertRules():Promise<main.AlertRulesStatus>;
+
+export function ReloadRecommendationStrategies():Promise<main.RecommendationStrategiesStatus>;
+
 export function RemoveFavorite(arg1:string):Promise<void>;
 
+export function RenameIdentity(arg1:string,arg2:string):Promise<void>;
+
 export function ResetLocalTestData():Promise<void>;
 
+export function RunMaintenanceNow():Promise<main.MaintenanceRun>;
+
 export function RunTombstoneGC(arg1:number,arg2:number,arg3:number):Promise<main.TombstoneGCResult>;
 
 export function SaveP2PConfig(arg1:number,arg2:Array<string>,arg3:boolean):Promise<main.P2PConfig>;
 
+export function Search(arg1:main.SearchRequest):Promise<main.SearchPage>;
+
+export function SearchNetwork(arg1:main.SearchRequest):Promise<main.NetworkSearchResult>;
+
 export function SearchPosts(arg1:string,arg2:string,arg3:number):Promise<Array<main.ForumMessage>>;
 
 export function SearchSubs(arg1:string,arg2:number):Promise<Array<main.Sub>>;
@@ -160,7 +216,10 @@ export function SetGovernancePolicy(arg1:boolean):Promise<main.GovernancePolicy>
 
 export function SetPrivacySettings(arg1:boolean,arg2:boolean):Promise<main.PrivacySettings>;
 
-export function SignMessage(arg1:string,arg2:string):Promise<string>;
+export function SetStorageQuotas(arg1:number,arg2:number,arg3:number,arg4:number,arg5:number):Promise<main.StorageQuotas>;
+
+export function SetSubPinPolicy(arg1:string,arg2:boolean):Promise<void>;
+
 
 export function StartP2P(arg1:number,arg2:Array<string>):Promise<main.P2PStatus>;
 
@@ -170,12 +229,18 @@ export function StoreCommentImageDataURL(arg1:string):Promise<main.CommentAttach
 
 export function SubscribeSub(arg1:string):Promise<main.Sub>;
 
+export function SwitchIdentity(arg1:string,arg2:string):Promise<main.Identity>;
+
 export function TriggerAntiEntropySyncNow():Promise<void>;
 
 export function TriggerCommentSyncNow(arg1:string):Promise<void>;
 
 export function TriggerReleaseAlertEvaluationNow():Promise<Array<main.ReleaseAlert>>;
 
+export function UnlockIdentity(arg1:string):Promise<main.Identity>;
+
+export function UnpinPost(arg1:string):Promise<void>;
+
 export function UnsubscribeSub(arg1:string):Promise<void>;
 
 export function UpdateProfile(arg1:string,arg2:string):Promise<main.Profile>;
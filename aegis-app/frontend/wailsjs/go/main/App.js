@@ -38,6 +38,10 @@ export function ApplyUnban(arg1, arg2, arg3) {
   return window['go']['main']['App']['ApplyUnban'](arg1, arg2, arg3);
 }
 
+export function ChangeIdentityPassphrase(arg1, arg2) {
+  return window['go']['main']['App']['ChangeIdentityPassphrase'](arg1, arg2);
+}
+
 export function CheckForUpdates() {
   return window['go']['main']['App']['CheckForUpdates']();
 }
@@ -46,10 +50,18 @@ export function ConnectPeer(arg1) {
   return window['go']['main']['App']['ConnectPeer'](arg1);
 }
 
+export function CreateIdentity(arg1, arg2) {
+  return window['go']['main']['App']['CreateIdentity'](arg1, arg2);
+}
+
 export function CreateSub(arg1, arg2, arg3) {
   return window['go']['main']['App']['CreateSub'](arg1, arg2, arg3);
 }
 
+export function DeleteIdentity(arg1, arg2) {
+  return window['go']['main']['App']['DeleteIdentity'](arg1, arg2);
+}
+
 export function DownvoteComment(arg1) {
   return window['go']['main']['App']['DownvoteComment'](arg1);
 }
@@ -58,14 +70,38 @@ export function DownvotePost(arg1) {
   return window['go']['main']['App']['DownvotePost'](arg1);
 }
 
-export function GenerateIdentity() {
-  return window['go']['main']['App']['GenerateIdentity']();
+export function ExportArchive(arg1, arg2) {
+  return window['go']['main']['App']['ExportArchive'](arg1, arg2);
+}
+
+export function ExportIdentityMnemonic(arg1) {
+  return window['go']['main']['App']['ExportIdentityMnemonic'](arg1);
+}
+
+export function GenerateIdentity(arg1) {
+  return window['go']['main']['App']['GenerateIdentity'](arg1);
+}
+
+export function GetAlertHistory(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetAlertHistory'](arg1, arg2, arg3);
+}
+
+export function GetAlertRules() {
+  return window['go']['main']['App']['GetAlertRules']();
 }
 
 export function GetAntiEntropyStats() {
   return window['go']['main']['App']['GetAntiEntropyStats']();
 }
 
+export function GetCommentPage(arg1) {
+  return window['go']['main']['App']['GetCommentPage'](arg1);
+}
+
+export function GetCommentRevisions(arg1) {
+  return window['go']['main']['App']['GetCommentRevisions'](arg1);
+}
+
 export function GetCommentsByPost(arg1) {
   return window['go']['main']['App']['GetCommentsByPost'](arg1);
 }
@@ -106,14 +142,26 @@ export function GetGovernancePolicy() {
   return window['go']['main']['App']['GetGovernancePolicy']();
 }
 
+export function GetIdentityLockStatus() {
+  return window['go']['main']['App']['GetIdentityLockStatus']();
+}
+
 export function GetIdentityState() {
   return window['go']['main']['App']['GetIdentityState']();
 }
 
+export function GetMaintenanceRuns(arg1) {
+  return window['go']['main']['App']['GetMaintenanceRuns'](arg1);
+}
+
 export function GetMediaByCID(arg1) {
   return window['go']['main']['App']['GetMediaByCID'](arg1);
 }
 
+export function GetMetricHistory(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['GetMetricHistory'](arg1, arg2, arg3, arg4);
+}
+
 export function GetModerationLogs(arg1) {
   return window['go']['main']['App']['GetModerationLogs'](arg1);
 }
@@ -150,6 +198,10 @@ export function GetPostMediaByID(arg1) {
   return window['go']['main']['App']['GetPostMediaByID'](arg1);
 }
 
+export function GetPostRevisions(arg1) {
+  return window['go']['main']['App']['GetPostRevisions'](arg1);
+}
+
 export function GetPrivacySettings() {
   return window['go']['main']['App']['GetPrivacySettings']();
 }
@@ -166,6 +218,10 @@ export function GetProfileDetails(arg1) {
   return window['go']['main']['App']['GetProfileDetails'](arg1);
 }
 
+export function GetRecommendationStrategies() {
+  return window['go']['main']['App']['GetRecommendationStrategies']();
+}
+
 export function GetReleaseAlerts() {
   return window['go']['main']['App']['GetReleaseAlerts']();
 }
@@ -174,6 +230,14 @@ export function GetReleaseMetrics() {
   return window['go']['main']['App']['GetReleaseMetrics']();
 }
 
+export function GetStorageEvictions(arg1) {
+  return window['go']['main']['App']['GetStorageEvictions'](arg1);
+}
+
+export function GetStorageQuotas() {
+  return window['go']['main']['App']['GetStorageQuotas']();
+}
+
 export function GetStorageUsage() {
   return window['go']['main']['App']['GetStorageUsage']();
 }
@@ -194,8 +258,12 @@ export function GetVersionHistory(arg1) {
   return window['go']['main']['App']['GetVersionHistory'](arg1);
 }
 
-export function ImportIdentityFromMnemonic(arg1) {
-  return window['go']['main']['App']['ImportIdentityFromMnemonic'](arg1);
+export function ImportArchive(arg1) {
+  return window['go']['main']['App']['ImportArchive'](arg1);
+}
+
+export function ImportIdentityFromMnemonic(arg1, arg2) {
+  return window['go']['main']['App']['ImportIdentityFromMnemonic'](arg1, arg2);
 }
 
 export function IsDevMode() {
@@ -206,14 +274,34 @@ export function IsFavorited(arg1) {
   return window['go']['main']['App']['IsFavorited'](arg1);
 }
 
+export function IsIdentityUnlocked() {
+  return window['go']['main']['App']['IsIdentityUnlocked']();
+}
+
 export function ListEntityOps(arg1, arg2, arg3) {
   return window['go']['main']['App']['ListEntityOps'](arg1, arg2, arg3);
 }
 
+export function ListIdentities() {
+  return window['go']['main']['App']['ListIdentities']();
+}
+
+export function ListPinned() {
+  return window['go']['main']['App']['ListPinned']();
+}
+
 export function LoadSavedIdentity() {
   return window['go']['main']['App']['LoadSavedIdentity']();
 }
 
+export function LockIdentity() {
+  return window['go']['main']['App']['LockIdentity']();
+}
+
+export function PinPost(arg1) {
+  return window['go']['main']['App']['PinPost'](arg1);
+}
+
 export function ProcessIncomingMessage(arg1) {
   return window['go']['main']['App']['ProcessIncomingMessage'](arg1);
 }
@@ -282,14 +370,30 @@ export function PublishUnban(arg1, arg2, arg3) {
   return window['go']['main']['App']['PublishUnban'](arg1, arg2, arg3);
 }
 
+export function ReloadAlertRules() {
+  return window['go']['main']['App']['ReloadAlertRules']();
+}
+
+export function ReloadRecommendationStrategies() {
+  return window['go']['main']['App']['ReloadRecommendationStrategies']();
+}
+
 export function RemoveFavorite(arg1) {
   return window['go']['main']['App']['RemoveFavorite'](arg1);
 }
 
+export function RenameIdentity(arg1, arg2) {
+  return window['go']['main']['App']['RenameIdentity'](arg1, arg2);
+}
+
 export function ResetLocalTestData() {
   return window['go']['main']['App']['ResetLocalTestData']();
 }
 
+export function RunMaintenanceNow() {
+  return window['go']['main']['App']['RunMaintenanceNow']();
+}
+
 export function RunTombstoneGC(arg1, arg2, arg3) {
   return window['go']['main']['App']['RunTombstoneGC'](arg1, arg2, arg3);
 }
@@ -298,6 +402,14 @@ export function SaveP2PConfig(arg1, arg2, arg3) {
   return window['go']['main']['App']['SaveP2PConfig'](arg1, arg2, arg3);
 }
 
+export function Search(arg1) {
+  return window['go']['main']['App']['Search'](arg1);
+}
+
+export function SearchNetwork(arg1) {
+  return window['go']['main']['App']['SearchNetwork'](arg1);
+}
+
 export function SearchPosts(arg1, arg2, arg3) {
   return window['go']['main']['App']['SearchPosts'](arg1, arg2, arg3);
 }
@@ -318,8 +430,12 @@ export function SetPrivacySettings(arg1, arg2) {
   return window['go']['main']['App']['SetPrivacySettings'](arg1, arg2);
 }
 
-export function SignMessage(arg1, arg2) {
-  return window['go']['main']['App']['SignMessage'](arg1, arg2);
+export function SetStorageQuotas(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['SetStorageQuotas'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function SetSubPinPolicy(arg1, arg2) {
+  return window['go']['main']['App']['SetSubPinPolicy'](arg1, arg2);
 }
 
 export function StartP2P(arg1, arg2) {
@@ -338,6 +454,10 @@ export function SubscribeSub(arg1) {
   return window['go']['main']['App']['SubscribeSub'](arg1);
 }
 
+export function SwitchIdentity(arg1, arg2) {
+  return window['go']['main']['App']['SwitchIdentity'](arg1, arg2);
+}
+
 export function TriggerAntiEntropySyncNow() {
   return window['go']['main']['App']['TriggerAntiEntropySyncNow']();
 }
@@ -350,6 +470,14 @@ export function TriggerReleaseAlertEvaluationNow() {
   return window['go']['main']['App']['TriggerReleaseAlertEvaluationNow']();
 }
 
+export function UnlockIdentity(arg1) {
+  return window['go']['main']['App']['UnlockIdentity'](arg1);
+}
+
+export function UnpinPost(arg1) {
+  return window['go']['main']['App']['UnpinPost'](arg1);
+}
+
 export function UnsubscribeSub(arg1) {
   return window['go']['main']['App']['UnsubscribeSub'](arg1);
 }
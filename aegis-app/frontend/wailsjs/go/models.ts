@@ -1,5 +1,65 @@
 export namespace main {
 	
+	export class AlertRule {
+	    key: string;
+	    metric: string;
+	    comparator: string;
+	    threshold: number;
+	    windowSec: number;
+	    severity: string;
+	    minSamples: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AlertRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this.metric = source["metric"];
+	        this.comparator = source["comparator"];
+	        this.threshold = source["threshold"];
+	        this.windowSec = source["windowSec"];
+	        this.severity = source["severity"];
+	        this.minSamples = source["minSamples"];
+	    }
+	}
+	export class AlertRulesStatus {
+	    source: string;
+	    loadedAt: number;
+	    error: string;
+	    rules: AlertRule[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AlertRulesStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.source = source["source"];
+	        this.loadedAt = source["loadedAt"];
+	        this.error = source["error"];
+	        this.rules = this.convertValues(source["rules"], AlertRule);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class AntiEntropyStats {
 	    syncRequestsSent: number;
 	    syncRequestsReceived: number;
@@ -12,6 +72,10 @@ export namespace main {
 	    lastSyncAt: number;
 	    lastRemoteSummaryTs: number;
 	    lastObservedSyncLagSec: number;
+	    reconcileSessions: number;
+	    reconcileRanges: number;
+	    reconcileItemsFetched: number;
+	    lastReconcileAt: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new AntiEntropyStats(source);
@@ -30,8 +94,106 @@ export namespace main {
 	        this.lastSyncAt = source["lastSyncAt"];
 	        this.lastRemoteSummaryTs = source["lastRemoteSummaryTs"];
 	        this.lastObservedSyncLagSec = source["lastObservedSyncLagSec"];
+	        this.reconcileSessions = source["reconcileSessions"];
+	        this.reconcileRanges = source["reconcileRanges"];
+	        this.reconcileItemsFetched = source["reconcileItemsFetched"];
+	        this.lastReconcileAt = source["lastReconcileAt"];
+	    }
+	}
+	export class ArchiveManifest {
+	    format: string;
+	    version: number;
+	    createdAt: number;
+	    pubkey: string;
+	    options: ArchiveOptions;
+	
+	    static createFrom(source: any = {}) {
+	        return new ArchiveManifest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.format = source["format"];
+	        this.version = source["version"];
+	        this.createdAt = source["createdAt"];
+	        this.pubkey = source["pubkey"];
+	        this.options = this.convertValues(source["options"], ArchiveOptions);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class ArchiveOptions {
+	    excludeMedia: boolean;
+	    excludePrivate: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ArchiveOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.excludeMedia = source["excludeMedia"];
+	        this.excludePrivate = source["excludePrivate"];
+	    }
+	}
+	export class ArchiveSummary {
+	    path: string;
+	    manifest: ArchiveManifest;
+	    records: Record<string, number>;
+	    skipped?: Record<string, number>;
+	    sizeBytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ArchiveSummary(source);
 	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.manifest = this.convertValues(source["manifest"], ArchiveManifest);
+	        this.records = source["records"];
+	        this.skipped = source["skipped"];
+	        this.sizeBytes = source["sizeBytes"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
+	
+	
 	export class CommentAttachment {
 	    kind: string;
 	    ref: string;
@@ -107,6 +269,140 @@ export namespace main {
 		}
 	}
 	
+	export class CommentNode {
+	    id: string;
+	    postId: string;
+	    parentId: string;
+	    pubkey: string;
+	    opId?: string;
+	    body: string;
+	    attachments?: CommentAttachment[];
+	    score: number;
+	    timestamp: number;
+	    lamport: number;
+	    deletedAt?: number;
+	    deletedBy?: string;
+	    upvotes: number;
+	    downvotes: number;
+	    replyCount: number;
+	    descendantCount: number;
+	    replies: CommentNode[];
+	    repliesCursor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CommentNode(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.postId = source["postId"];
+	        this.parentId = source["parentId"];
+	        this.pubkey = source["pubkey"];
+	        this.opId = source["opId"];
+	        this.body = source["body"];
+	        this.attachments = this.convertValues(source["attachments"], CommentAttachment);
+	        this.score = source["score"];
+	        this.timestamp = source["timestamp"];
+	        this.lamport = source["lamport"];
+	        this.deletedAt = source["deletedAt"];
+	        this.deletedBy = source["deletedBy"];
+	        this.upvotes = source["upvotes"];
+	        this.downvotes = source["downvotes"];
+	        this.replyCount = source["replyCount"];
+	        this.descendantCount = source["descendantCount"];
+	        this.replies = this.convertValues(source["replies"], CommentNode);
+	        this.repliesCursor = source["repliesCursor"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class CommentPage {
+	    postId: string;
+	    parentId: string;
+	    sort: string;
+	    totalCount: number;
+	    threadCount: number;
+	    comments: CommentNode[];
+	    nextCursor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CommentPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.postId = source["postId"];
+	        this.parentId = source["parentId"];
+	        this.sort = source["sort"];
+	        this.totalCount = source["totalCount"];
+	        this.threadCount = source["threadCount"];
+	        this.comments = this.convertValues(source["comments"], CommentNode);
+	        this.nextCursor = source["nextCursor"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class CommentPageRequest {
+	    postId: string;
+	    parentId: string;
+	    sort: string;
+	    limit: number;
+	    cursor: string;
+	    depth: number;
+	    replyLimit: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CommentPageRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.postId = source["postId"];
+	        this.parentId = source["parentId"];
+	        this.sort = source["sort"];
+	        this.limit = source["limit"];
+	        this.cursor = source["cursor"];
+	        this.depth = source["depth"];
+	        this.replyLimit = source["replyLimit"];
+	    }
+	}
 	export class EntityOpRecord {
 	    opId: string;
 	    entityType: string;
@@ -137,6 +433,58 @@ export namespace main {
 	        this.payloadJson = source["payloadJson"];
 	    }
 	}
+	export class EntityRevision {
+	    opId: string;
+	    entityType: string;
+	    entityId: string;
+	    author: string;
+	    title?: string;
+	    contentCid: string;
+	    lamport: number;
+	    timestamp: number;
+	    current: boolean;
+	    bodyAvailable: boolean;
+	    diff: RevisionDiffChunk[];
+	
+	    static createFrom(source: any = {}) {
+	        return new EntityRevision(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.opId = source["opId"];
+	        this.entityType = source["entityType"];
+	        this.entityId = source["entityId"];
+	        this.author = source["author"];
+	        this.title = source["title"];
+	        this.contentCid = source["contentCid"];
+	        this.lamport = source["lamport"];
+	        this.timestamp = source["timestamp"];
+	        this.current = source["current"];
+	        this.bodyAvailable = source["bodyAvailable"];
+	        this.diff = this.convertValues(source["diff"], RevisionDiffChunk);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
 	export class ForumMessage {
 	    id: string;
 	    pubkey: string;
@@ -294,7 +642,7 @@ export namespace main {
 	    }
 	}
 	export class Identity {
-	    mnemonic: string;
+	    mnemonic?: string;
 	    publicKey: string;
 	
 	    static createFrom(source: any = {}) {
@@ -307,10 +655,28 @@ export namespace main {
 	        this.publicKey = source["publicKey"];
 	    }
 	}
-	export class IdentityState {
-	    pubkey: string;
-	    state: string;
-	    storageCommitBytes: number;
+	export class IdentityLockStatus {
+	    hasIdentity: boolean;
+	    encrypted: boolean;
+	    unlocked: boolean;
+	    publicKey: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new IdentityLockStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.hasIdentity = source["hasIdentity"];
+	        this.encrypted = source["encrypted"];
+	        this.unlocked = source["unlocked"];
+	        this.publicKey = source["publicKey"];
+	    }
+	}
+	export class IdentityState {
+	    pubkey: string;
+	    state: string;
+	    storageCommitBytes: number;
 	    publicQuotaBytes: number;
 	    privateQuotaBytes: number;
 	    updatedAt: number;
@@ -329,6 +695,86 @@ export namespace main {
 	        this.updatedAt = source["updatedAt"];
 	    }
 	}
+	export class LocalIdentity {
+	    publicKey: string;
+	    label: string;
+	    active: boolean;
+	    encrypted: boolean;
+	    unlocked: boolean;
+	    createdAt: number;
+	    updatedAt: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new LocalIdentity(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.publicKey = source["publicKey"];
+	        this.label = source["label"];
+	        this.active = source["active"];
+	        this.encrypted = source["encrypted"];
+	        this.unlocked = source["unlocked"];
+	        this.createdAt = source["createdAt"];
+	        this.updatedAt = source["updatedAt"];
+	    }
+	}
+	export class MaintenanceRun {
+	    id: number;
+	    trigger: string;
+	    startedAt: number;
+	    finishedAt: number;
+	    durationMs: number;
+	    gc: TombstoneGCResult;
+	    compaction: OpCompactionResult;
+	    searchRepaired: number;
+	    vacuumConverted: boolean;
+	    freedPages: number;
+	    freelistPages: number;
+	    analyzed: boolean;
+	    errors: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new MaintenanceRun(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.trigger = source["trigger"];
+	        this.startedAt = source["startedAt"];
+	        this.finishedAt = source["finishedAt"];
+	        this.durationMs = source["durationMs"];
+	        this.gc = this.convertValues(source["gc"], TombstoneGCResult);
+	        this.compaction = this.convertValues(source["compaction"], OpCompactionResult);
+	        this.searchRepaired = source["searchRepaired"];
+	        this.vacuumConverted = source["vacuumConverted"];
+	        this.freedPages = source["freedPages"];
+	        this.freelistPages = source["freelistPages"];
+	        this.analyzed = source["analyzed"];
+	        this.errors = source["errors"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
 	export class MediaBlob {
 	    contentCid: string;
 	    dataBase64: string;
@@ -353,6 +799,26 @@ export namespace main {
 	        this.isThumbnail = source["isThumbnail"];
 	    }
 	}
+	export class MetricHistoryPoint {
+	    timestamp: number;
+	    value: number;
+	    min: number;
+	    max: number;
+	    samples: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MetricHistoryPoint(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.timestamp = source["timestamp"];
+	        this.value = source["value"];
+	        this.min = source["min"];
+	        this.max = source["max"];
+	        this.samples = source["samples"];
+	    }
+	}
 	export class ModerationLog {
 	    id: number;
 	    targetPubkey: string;
@@ -401,6 +867,82 @@ export namespace main {
 	        this.reason = source["reason"];
 	    }
 	}
+	export class NetworkSearchHit {
+	    postId: string;
+	    title: string;
+	    subId: string;
+	    pubkey: string;
+	    timestamp: number;
+	    peers: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkSearchHit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.postId = source["postId"];
+	        this.title = source["title"];
+	        this.subId = source["subId"];
+	        this.pubkey = source["pubkey"];
+	        this.timestamp = source["timestamp"];
+	        this.peers = source["peers"];
+	    }
+	}
+	export class NetworkSearchResult {
+	    hits: NetworkSearchHit[];
+	    responders: number;
+	    inserted: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkSearchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.hits = this.convertValues(source["hits"], NetworkSearchHit);
+	        this.responders = source["responders"];
+	        this.inserted = source["inserted"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class OpCompactionResult {
+	    entityOps: number;
+	    voteOps: number;
+	    favoriteOps: number;
+	    moderationLogs: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new OpCompactionResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entityOps = source["entityOps"];
+	        this.voteOps = source["voteOps"];
+	        this.favoriteOps = source["favoriteOps"];
+	        this.moderationLogs = source["moderationLogs"];
+	    }
+	}
 	export class P2PConfig {
 	    listenPort: number;
 	    relayPeers: string[];
@@ -426,6 +968,7 @@ export namespace main {
 	    announceAddrs: string[];
 	    connectedPeers: string[];
 	    topic: string;
+	    subTopics: string[];
 	
 	    static createFrom(source: any = {}) {
 	        return new P2PStatus(source);
@@ -439,6 +982,79 @@ export namespace main {
 	        this.announceAddrs = source["announceAddrs"];
 	        this.connectedPeers = source["connectedPeers"];
 	        this.topic = source["topic"];
+	        this.subTopics = source["subTopics"];
+	    }
+	}
+	export class PinnedContent {
+	    posts: PinnedPost[];
+	    subs: PinnedSub[];
+	    usedBytes: number;
+	    budgetBytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PinnedContent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.posts = this.convertValues(source["posts"], PinnedPost);
+	        this.subs = this.convertValues(source["subs"], PinnedSub);
+	        this.usedBytes = source["usedBytes"];
+	        this.budgetBytes = source["budgetBytes"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class PinnedPost {
+	    postId: string;
+	    title: string;
+	    subId: string;
+	    pinnedAt: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PinnedPost(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.postId = source["postId"];
+	        this.title = source["title"];
+	        this.subId = source["subId"];
+	        this.pinnedAt = source["pinnedAt"];
+	    }
+	}
+	export class PinnedSub {
+	    subId: string;
+	    postCount: number;
+	    pinnedAt: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PinnedSub(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.subId = source["subId"];
+	        this.postCount = source["postCount"];
+	        this.pinnedAt = source["pinnedAt"];
 	    }
 	}
 	export class PostBodyBlob {
@@ -585,6 +1201,60 @@ export namespace main {
 	        this.updatedAt = source["updatedAt"];
 	    }
 	}
+	export class RecommendationStrategiesStatus {
+	    dir: string;
+	    loadedAt: number;
+	    strategies: RecommendationStrategyInfo[];
+	    errors: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RecommendationStrategiesStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.dir = source["dir"];
+	        this.loadedAt = source["loadedAt"];
+	        this.strategies = this.convertValues(source["strategies"], RecommendationStrategyInfo);
+	        this.errors = source["errors"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class RecommendationStrategyInfo {
+	    name: string;
+	    source: string;
+	    description: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RecommendationStrategyInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.source = source["source"];
+	        this.description = source["description"];
+	    }
+	}
 	export class ReleaseAlert {
 	    key: string;
 	    metric: string;
@@ -609,6 +1279,34 @@ export namespace main {
 	        this.triggeredAt = source["triggeredAt"];
 	    }
 	}
+	export class ReleaseAlertEvent {
+	    id: number;
+	    key: string;
+	    metric: string;
+	    level: string;
+	    event: string;
+	    value: number;
+	    threshold: number;
+	    windowSec: number;
+	    at: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReleaseAlertEvent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.key = source["key"];
+	        this.metric = source["metric"];
+	        this.level = source["level"];
+	        this.event = source["event"];
+	        this.value = source["value"];
+	        this.threshold = source["threshold"];
+	        this.windowSec = source["windowSec"];
+	        this.at = source["at"];
+	    }
+	}
 	export class ReleaseMetrics {
 	    content_fetch_success_rate: number;
 	    content_fetch_latency_p95: number;
@@ -637,11 +1335,208 @@ export namespace main {
 	        this.blob_cache_misses = source["blob_cache_misses"];
 	    }
 	}
+	export class RevisionDiffChunk {
+	    op: string;
+	    text: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RevisionDiffChunk(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.op = source["op"];
+	        this.text = source["text"];
+	    }
+	}
+	export class SearchHit {
+	    kind: string;
+	    id: string;
+	    postId: string;
+	    postTitle: string;
+	    subId: string;
+	    pubkey: string;
+	    timestamp: number;
+	    rank: number;
+	    snippet: SearchSnippetPart[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchHit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.id = source["id"];
+	        this.postId = source["postId"];
+	        this.postTitle = source["postTitle"];
+	        this.subId = source["subId"];
+	        this.pubkey = source["pubkey"];
+	        this.timestamp = source["timestamp"];
+	        this.rank = source["rank"];
+	        this.snippet = this.convertValues(source["snippet"], SearchSnippetPart);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class SearchPage {
+	    hits: SearchHit[];
+	    nextCursor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.hits = this.convertValues(source["hits"], SearchHit);
+	        this.nextCursor = source["nextCursor"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class SearchRequest {
+	    query: string;
+	    kind: string;
+	    subId: string;
+	    author: string;
+	    since: number;
+	    until: number;
+	    limit: number;
+	    cursor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.query = source["query"];
+	        this.kind = source["kind"];
+	        this.subId = source["subId"];
+	        this.author = source["author"];
+	        this.since = source["since"];
+	        this.until = source["until"];
+	        this.limit = source["limit"];
+	        this.cursor = source["cursor"];
+	    }
+	}
+	export class SearchSnippetPart {
+	    text: string;
+	    match: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchSnippetPart(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.text = source["text"];
+	        this.match = source["match"];
+	    }
+	}
+	export class StorageEviction {
+	    id: number;
+	    at: number;
+	    kind: string;
+	    contentCid: string;
+	    sizeBytes: number;
+	    scope: string;
+	    policy: string;
+	    score: number;
+	    postId: string;
+	    subId: string;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new StorageEviction(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.at = source["at"];
+	        this.kind = source["kind"];
+	        this.contentCid = source["contentCid"];
+	        this.sizeBytes = source["sizeBytes"];
+	        this.scope = source["scope"];
+	        this.policy = source["policy"];
+	        this.score = source["score"];
+	        this.postId = source["postId"];
+	        this.subId = source["subId"];
+	        this.reason = source["reason"];
+	    }
+	}
+	export class StorageQuotas {
+	    totalBytes: number;
+	    privateBytes: number;
+	    publicBytes: number;
+	    mediaBytes: number;
+	    pinnedBytes: number;
+	    updatedAt: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StorageQuotas(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.totalBytes = source["totalBytes"];
+	        this.privateBytes = source["privateBytes"];
+	        this.publicBytes = source["publicBytes"];
+	        this.mediaBytes = source["mediaBytes"];
+	        this.pinnedBytes = source["pinnedBytes"];
+	        this.updatedAt = source["updatedAt"];
+	    }
+	}
 	export class StorageUsage {
 	    privateUsedBytes: number;
 	    publicUsedBytes: number;
+	    mediaUsedBytes: number;
+	    pinnedUsedBytes: number;
+	    messageBytes: number;
+	    contentBytes: number;
+	    totalUsedBytes: number;
 	    privateQuota: number;
 	    publicQuota: number;
+	    mediaQuota: number;
+	    pinnedQuota: number;
 	    totalQuota: number;
 	
 	    static createFrom(source: any = {}) {
@@ -652,8 +1547,15 @@ export namespace main {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.privateUsedBytes = source["privateUsedBytes"];
 	        this.publicUsedBytes = source["publicUsedBytes"];
+	        this.mediaUsedBytes = source["mediaUsedBytes"];
+	        this.pinnedUsedBytes = source["pinnedUsedBytes"];
+	        this.messageBytes = source["messageBytes"];
+	        this.contentBytes = source["contentBytes"];
+	        this.totalUsedBytes = source["totalUsedBytes"];
 	        this.privateQuota = source["privateQuota"];
 	        this.publicQuota = source["publicQuota"];
+	        this.mediaQuota = source["mediaQuota"];
+	        this.pinnedQuota = source["pinnedQuota"];
 	        this.totalQuota = source["totalQuota"];
 	    }
 	}
@@ -680,6 +1582,7 @@ export namespace main {
 	    deletedPosts: number;
 	    scannedComments: number;
 	    deletedComments: number;
+	    releasedRevisionBodies: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new TombstoneGCResult(source);
@@ -691,6 +1594,7 @@ export namespace main {
 	        this.deletedPosts = source["deletedPosts"];
 	        this.scannedComments = source["scannedComments"];
 	        this.deletedComments = source["deletedComments"];
+	        this.releasedRevisionBodies = source["releasedRevisionBodies"];
 	    }
 	}
 	export class UpdateStatus {
@@ -0,0 +1,645 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	adminDelegationGrant  = "GRANT"
+	adminDelegationRevoke = "REVOKE"
+
+	adminRoleGenesis   = "genesis"
+	adminRoleAdmin     = "admin"
+	adminRoleModerator = "moderator"
+
+	adminSourceLocal      = "local"
+	adminSourceDelegation = "delegation"
+
+	// governanceDelegationSyncLimit caps the newest delegations by lamport a
+	// governance sync response carries next to the grant chains of current
+	// delegates.
+	governanceDelegationSyncLimit = 500
+	// adminDelegationPendingLimit caps the delegations held until the grants
+	// they depend on arrive.
+	adminDelegationPendingLimit = 1000
+)
+
+var (
+	errAdminDelegationInvalid      = errors.New("invalid admin delegation")
+	errAdminDelegationUnauthorized = errors.New("admin delegation grantor is not authorized")
+	errGovernancePolicyNotRoot     = errors.New("only a genesis admin may update governance policy")
+)
+
+// AdminDelegation is a signed grant or revoke of a governance role. Genesis
+// admins (AEGIS_TRUSTED_ADMINS) are the roots; a delegation only takes effect
+// when its grantor held a delegating role at the delegation's lamport.
+type AdminDelegation struct {
+	OpID          string `json:"opId"`
+	GrantorPubkey string `json:"grantorPubkey"`
+	GranteePubkey string `json:"granteePubkey"`
+	Action        string `json:"action"`
+	Role          string `json:"role,omitempty"`
+	Timestamp     int64  `json:"timestamp"`
+	Lamport       int64  `json:"lamport"`
+	Signature     string `json:"signature"`
+	Valid         bool   `json:"valid"`
+}
+
+type adminDelegationReplay struct {
+	roles     map[string]string
+	grantedBy map[string]AdminDelegation
+	valid     map[string]bool
+}
+
+func normalizeAdminDelegationRole(role string) (string, error) {
+	switch strings.TrimSpace(strings.ToLower(role)) {
+	case "", adminRoleModerator:
+		return adminRoleModerator, nil
+	case adminRoleAdmin:
+		return adminRoleAdmin, nil
+	default:
+		return "", errors.New("invalid delegated admin role")
+	}
+}
+
+func adminDelegationFromMessage(message IncomingMessage) AdminDelegation {
+	return AdminDelegation{
+		OpID:          strings.TrimSpace(message.OpID),
+		GrantorPubkey: strings.TrimSpace(message.AdminPubkey),
+		GranteePubkey: strings.TrimSpace(message.TargetPubkey),
+		Action:        strings.ToUpper(strings.TrimSpace(message.OpType)),
+		Role:          strings.TrimSpace(strings.ToLower(message.AdminRole)),
+		Timestamp:     message.Timestamp,
+		Lamport:       message.Lamport,
+		Signature:     strings.TrimSpace(message.Signature),
+	}
+}
+
+func adminDelegationMessage(delegation AdminDelegation) IncomingMessage {
+	return IncomingMessage{
+		Type:         messageTypeAdminDelegation,
+		OpType:       delegation.Action,
+		OpID:         delegation.OpID,
+		AdminPubkey:  delegation.GrantorPubkey,
+		TargetPubkey: delegation.GranteePubkey,
+		AdminRole:    delegation.Role,
+		Timestamp:    delegation.Timestamp,
+		Lamport:      delegation.Lamport,
+		Signature:    delegation.Signature,
+	}
+}
+
+func validateAdminDelegation(delegation AdminDelegation) error {
+	if delegation.OpID == "" || delegation.GrantorPubkey == "" || delegation.GranteePubkey == "" {
+		return errAdminDelegationInvalid
+	}
+	if delegation.GrantorPubkey == delegation.GranteePubkey {
+		return errAdminDelegationInvalid
+	}
+	if delegation.Lamport <= 0 || delegation.Timestamp <= 0 {
+		return errAdminDelegationInvalid
+	}
+	switch delegation.Action {
+	case adminDelegationGrant:
+		if _, err := normalizeAdminDelegationRole(delegation.Role); err != nil {
+			return err
+		}
+	case adminDelegationRevoke:
+		if delegation.Role != "" {
+			return errAdminDelegationInvalid
+		}
+	default:
+		return errAdminDelegationInvalid
+	}
+	return nil
+}
+
+func sortAdminDelegations(delegations []AdminDelegation) {
+	sort.SliceStable(delegations, func(i int, j int) bool {
+		left := delegations[i]
+		right := delegations[j]
+		if left.Lamport != right.Lamport {
+			return left.Lamport < right.Lamport
+		}
+		if left.Timestamp != right.Timestamp {
+			return left.Timestamp < right.Timestamp
+		}
+		return left.OpID < right.OpID
+	})
+}
+
+// replayAdminDelegations walks the delegations in lamport order starting from
+// the genesis roots. Genesis admins and delegated "admin" roles may grant and
+// revoke; moderators may only moderate. Genesis roots cannot be revoked, and
+// revoking or downgrading an admin also drops everyone it delegated to.
+func replayAdminDelegations(roots map[string]bool, delegations []AdminDelegation) adminDelegationReplay {
+	ordered := append([]AdminDelegation(nil), delegations...)
+	sortAdminDelegations(ordered)
+
+	replay := adminDelegationReplay{
+		roles:     make(map[string]string),
+		grantedBy: make(map[string]AdminDelegation),
+		valid:     make(map[string]bool, len(ordered)),
+	}
+	for _, delegation := range ordered {
+		grantor := delegation.GrantorPubkey
+		grantee := delegation.GranteePubkey
+		if !roots[grantor] && replay.roles[grantor] != adminRoleAdmin {
+			continue
+		}
+		if roots[grantee] {
+			continue
+		}
+
+		switch delegation.Action {
+		case adminDelegationGrant:
+			if _, held := replay.roles[grantee]; !held {
+				replay.grantedBy[grantee] = delegation
+			}
+			replay.roles[grantee] = delegation.Role
+			if delegation.Role != adminRoleAdmin {
+				replay.dropDelegates(grantee)
+			}
+		case adminDelegationRevoke:
+			delete(replay.roles, grantee)
+			delete(replay.grantedBy, grantee)
+			replay.dropDelegates(grantee)
+		default:
+			continue
+		}
+		replay.valid[delegation.OpID] = true
+	}
+	return replay
+}
+
+func (replay adminDelegationReplay) dropDelegates(grantor string) {
+	for grantee, grant := range replay.grantedBy {
+		if grant.GrantorPubkey != grantor {
+			continue
+		}
+		delete(replay.roles, grantee)
+		delete(replay.grantedBy, grantee)
+		replay.dropDelegates(grantee)
+	}
+}
+
+func (a *App) listGenesisAdmins() (map[string]bool, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	rows, err := a.db.Query(`
+		SELECT admin_pubkey
+		FROM governance_admins
+		WHERE active = 1 AND source = ? AND role = ?;
+	`, adminSourceLocal, adminRoleGenesis)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roots := make(map[string]bool)
+	for rows.Next() {
+		var pubkey string
+		if err = rows.Scan(&pubkey); err != nil {
+			return nil, err
+		}
+		roots[pubkey] = true
+	}
+	return roots, rows.Err()
+}
+
+// isRootAdmin reports whether pubkey is an active genesis admin. Delegated
+// admins and moderators are trusted to moderate but not to change policy.
+func (a *App) isRootAdmin(pubkey string) (bool, error) {
+	pubkey = strings.TrimSpace(pubkey)
+	if pubkey == "" {
+		return false, nil
+	}
+	roots, err := a.listGenesisAdmins()
+	if err != nil {
+		return false, err
+	}
+	return roots[pubkey], nil
+}
+
+func (a *App) listAdminDelegations(limit int) ([]AdminDelegation, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	query := `
+		SELECT op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature
+		FROM governance_delegations
+		ORDER BY lamport ASC, timestamp ASC, op_id ASC`
+	args := make([]interface{}, 0, 1)
+	if limit > 0 {
+		// Keep the newest delegations, revocations included, and return them
+		// in chain order.
+		query = `
+			SELECT op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature
+			FROM (
+				SELECT op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature
+				FROM governance_delegations
+				ORDER BY lamport DESC, timestamp DESC, op_id DESC
+				LIMIT ?
+			)
+			ORDER BY lamport ASC, timestamp ASC, op_id ASC`
+		args = append(args, limit)
+	}
+
+	rows, err := a.db.Query(query+`;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]AdminDelegation, 0)
+	for rows.Next() {
+		var row AdminDelegation
+		if err = rows.Scan(&row.OpID, &row.GrantorPubkey, &row.GranteePubkey, &row.Action, &row.Role, &row.Timestamp, &row.Lamport, &row.Signature); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// listAdminDelegationsForSync returns the newest limit delegations plus every
+// grant on a current delegate's chain back to its genesis admin, in chain
+// order, so a fresh node can verify each delegate however old its chain is.
+func (a *App) listAdminDelegationsForSync(limit int) ([]AdminDelegation, error) {
+	roots, err := a.listGenesisAdmins()
+	if err != nil {
+		return nil, err
+	}
+	delegations, err := a.listAdminDelegations(0)
+	if err != nil {
+		return nil, err
+	}
+
+	include := make(map[string]bool)
+	for _, grant := range replayAdminDelegations(roots, delegations).grantedBy {
+		include[grant.OpID] = true
+	}
+	newest := 0
+	if limit > 0 && len(delegations) > limit {
+		newest = len(delegations) - limit
+	}
+	result := make([]AdminDelegation, 0, len(delegations)-newest+len(include))
+	for i, delegation := range delegations {
+		if i >= newest || include[delegation.OpID] {
+			result = append(result, delegation)
+		}
+	}
+	return result, nil
+}
+
+func (a *App) listPendingAdminDelegations() ([]AdminDelegation, error) {
+	rows, err := a.db.Query(`
+		SELECT op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature
+		FROM governance_delegations_pending;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]AdminDelegation, 0)
+	for rows.Next() {
+		var row AdminDelegation
+		if err = rows.Scan(&row.OpID, &row.GrantorPubkey, &row.GranteePubkey, &row.Action, &row.Role, &row.Timestamp, &row.Lamport, &row.Signature); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// holdAdminDelegation keeps a verified delegation whose grantor is not
+// authorized by the stored chain, since the grants it depends on may simply
+// not have arrived yet. Only the newest adminDelegationPendingLimit are kept.
+func (a *App) holdAdminDelegation(delegation AdminDelegation) error {
+	if _, err := a.db.Exec(`
+		INSERT INTO governance_delegations_pending (op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(op_id) DO NOTHING;
+	`, delegation.OpID, delegation.GrantorPubkey, delegation.GranteePubkey, delegation.Action, delegation.Role, delegation.Timestamp, delegation.Lamport, delegation.Signature, time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := a.db.Exec(`
+		DELETE FROM governance_delegations_pending
+		WHERE op_id NOT IN (
+			SELECT op_id FROM governance_delegations_pending
+			ORDER BY received_at DESC, lamport DESC, op_id DESC
+			LIMIT ?
+		);
+	`, adminDelegationPendingLimit)
+	return err
+}
+
+// releasePendingAdminDelegations stores the held delegations that the grown
+// chain now authorizes. Replaying stored and held delegations together
+// releases a whole run of out-of-order grants at once.
+func (a *App) releasePendingAdminDelegations() (int, error) {
+	pending, err := a.listPendingAdminDelegations()
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	roots, err := a.listGenesisAdmins()
+	if err != nil {
+		return 0, err
+	}
+	stored, err := a.listAdminDelegations(0)
+	if err != nil {
+		return 0, err
+	}
+	replay := replayAdminDelegations(roots, append(stored, pending...))
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	released := 0
+	maxLamport := int64(0)
+	for _, delegation := range pending {
+		if !replay.valid[delegation.OpID] {
+			continue
+		}
+		if _, err = tx.Exec(`
+			INSERT INTO governance_delegations (op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature, received_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(op_id) DO NOTHING;
+		`, delegation.OpID, delegation.GrantorPubkey, delegation.GranteePubkey, delegation.Action, delegation.Role, delegation.Timestamp, delegation.Lamport, delegation.Signature, time.Now().Unix()); err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec(`DELETE FROM governance_delegations_pending WHERE op_id = ?;`, delegation.OpID); err != nil {
+			return 0, err
+		}
+		if delegation.Lamport > maxLamport {
+			maxLamport = delegation.Lamport
+		}
+		released++
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	if released > 0 {
+		if err = a.observeLamport(maxLamport); err != nil {
+			return released, err
+		}
+	}
+	return released, nil
+}
+
+// rebuildDelegatedAdmins recomputes the delegated rows of governance_admins
+// from the genesis roots and the stored delegation chain. Locally configured
+// admins always win over delegated roles for the same key.
+func (a *App) rebuildDelegatedAdmins() error {
+	roots, err := a.listGenesisAdmins()
+	if err != nil {
+		return err
+	}
+	delegations, err := a.listAdminDelegations(0)
+	if err != nil {
+		return err
+	}
+	replay := replayAdminDelegations(roots, delegations)
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM governance_admins WHERE source = ?;`, adminSourceDelegation); err != nil {
+		return err
+	}
+	for pubkey, role := range replay.roles {
+		if _, err = tx.Exec(`
+			INSERT INTO governance_admins (admin_pubkey, role, active, source)
+			VALUES (?, ?, 1, ?)
+			ON CONFLICT(admin_pubkey) DO NOTHING;
+		`, pubkey, role, adminSourceDelegation); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// applyAdminDelegation verifies a signed delegation, checks that its grantor
+// was authorized at that point of the chain and stores it, along with any held
+// delegations it authorizes in turn. One whose grantor is not authorized is
+// held for a later retry and reported as unauthorized. It reports whether the
+// delegation was newly stored.
+func (a *App) applyAdminDelegation(delegation AdminDelegation) (bool, error) {
+	if a.db == nil {
+		return false, errors.New("database not initialized")
+	}
+
+	if delegation.Action == adminDelegationGrant {
+		role, err := normalizeAdminDelegationRole(delegation.Role)
+		if err != nil {
+			return false, err
+		}
+		delegation.Role = role
+	}
+	if err := validateAdminDelegation(delegation); err != nil {
+		return false, err
+	}
+	if err := a.verifyIncomingMessageSignature(adminDelegationMessage(delegation)); err != nil {
+		return false, err
+	}
+	if delegation.Signature == "" {
+		return false, errMessageSignatureRequired
+	}
+
+	var exists int
+	err := a.db.QueryRow(`SELECT 1 FROM governance_delegations WHERE op_id = ?;`, delegation.OpID).Scan(&exists)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	roots, err := a.listGenesisAdmins()
+	if err != nil {
+		return false, err
+	}
+	existing, err := a.listAdminDelegations(0)
+	if err != nil {
+		return false, err
+	}
+	replay := replayAdminDelegations(roots, append(existing, delegation))
+	if !replay.valid[delegation.OpID] {
+		if err = a.holdAdminDelegation(delegation); err != nil {
+			return false, err
+		}
+		return false, errAdminDelegationUnauthorized
+	}
+
+	if err = a.observeLamport(delegation.Lamport); err != nil {
+		return false, err
+	}
+	if _, err = a.db.Exec(`
+		INSERT INTO governance_delegations (op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(op_id) DO NOTHING;
+	`, delegation.OpID, delegation.GrantorPubkey, delegation.GranteePubkey, delegation.Action, delegation.Role, delegation.Timestamp, delegation.Lamport, delegation.Signature, time.Now().Unix()); err != nil {
+		return false, err
+	}
+	released, err := a.releasePendingAdminDelegations()
+	if err != nil {
+		return false, err
+	}
+
+	if err = a.rebuildDelegatedAdmins(); err != nil {
+		return false, err
+	}
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "governance.delegation applied op_id=%s action=%s grantor=%s grantee=%s role=%s released=%d", delegation.OpID, delegation.Action, delegation.GrantorPubkey, delegation.GranteePubkey, delegation.Role, released)
+	}
+	return true, nil
+}
+
+func (a *App) GrantAdminRole(granteePubkey string, role string) (AdminDelegation, error) {
+	role, err := normalizeAdminDelegationRole(role)
+	if err != nil {
+		return AdminDelegation{}, err
+	}
+	return a.publishAdminDelegation(adminDelegationGrant, granteePubkey, role)
+}
+
+func (a *App) RevokeAdminRole(granteePubkey string) (AdminDelegation, error) {
+	return a.publishAdminDelegation(adminDelegationRevoke, granteePubkey, "")
+}
+
+func (a *App) publishAdminDelegation(action string, granteePubkey string, role string) (AdminDelegation, error) {
+	if a.db == nil {
+		return AdminDelegation{}, errors.New("database not initialized")
+	}
+
+	granteePubkey = strings.TrimSpace(granteePubkey)
+	if granteePubkey == "" {
+		return AdminDelegation{}, errors.New("grantee pubkey is required")
+	}
+
+	identity, err := a.getLocalIdentity()
+	if err != nil {
+		return AdminDelegation{}, err
+	}
+	grantor := strings.TrimSpace(identity.PublicKey)
+
+	now := time.Now().Unix()
+	lamport, err := a.nextLamport()
+	if err != nil {
+		return AdminDelegation{}, err
+	}
+	msg := IncomingMessage{
+		Type:         messageTypeAdminDelegation,
+		OpType:       action,
+		OpID:         generateOperationID(granteePubkey, grantor, lamport),
+		AdminPubkey:  grantor,
+		TargetPubkey: granteePubkey,
+		AdminRole:    role,
+		Timestamp:    now,
+		Lamport:      lamport,
+	}
+	if err = a.signIncomingMessage(&msg); err != nil {
+		return AdminDelegation{}, err
+	}
+
+	delegation := adminDelegationFromMessage(msg)
+	if _, err = a.applyAdminDelegation(delegation); err != nil {
+		return AdminDelegation{}, err
+	}
+	delegation.Valid = true
+
+	a.p2pMu.Lock()
+	topic := a.p2pTopic
+	ctx := a.p2pCtx
+	a.p2pMu.Unlock()
+	if topic == nil || ctx == nil {
+		return delegation, nil
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return delegation, err
+	}
+	return delegation, topic.Publish(ctx, payload)
+}
+
+// GetAdminDelegations returns every stored delegation in chain order, each
+// flagged with whether it is valid when replayed from the local genesis roots.
+func (a *App) GetAdminDelegations() ([]AdminDelegation, error) {
+	roots, err := a.listGenesisAdmins()
+	if err != nil {
+		return nil, err
+	}
+	delegations, err := a.listAdminDelegations(0)
+	if err != nil {
+		return nil, err
+	}
+
+	replay := replayAdminDelegations(roots, delegations)
+	for i := range delegations {
+		delegations[i].Valid = replay.valid[delegations[i].OpID]
+	}
+	return delegations, nil
+}
+
+// GetAdminDelegationChain returns the grants linking adminPubkey back to a
+// genesis admin, root first. It is empty for genesis admins themselves.
+func (a *App) GetAdminDelegationChain(adminPubkey string) ([]AdminDelegation, error) {
+	adminPubkey = strings.TrimSpace(adminPubkey)
+	if adminPubkey == "" {
+		return nil, errors.New("admin pubkey is required")
+	}
+
+	roots, err := a.listGenesisAdmins()
+	if err != nil {
+		return nil, err
+	}
+	if roots[adminPubkey] {
+		return []AdminDelegation{}, nil
+	}
+	delegations, err := a.listAdminDelegations(0)
+	if err != nil {
+		return nil, err
+	}
+
+	replay := replayAdminDelegations(roots, delegations)
+	chain := make([]AdminDelegation, 0)
+	visited := make(map[string]bool)
+	current := adminPubkey
+	for !roots[current] {
+		grant, ok := replay.grantedBy[current]
+		if !ok || visited[current] {
+			return nil, errors.New("admin has no valid delegation chain")
+		}
+		visited[current] = true
+		grant.Valid = true
+		chain = append(chain, grant)
+		current = grant.GrantorPubkey
+	}
+
+	for left, right := 0, len(chain)-1; left < right; left, right = left+1, right-1 {
+		chain[left], chain[right] = chain[right], chain[left]
+	}
+	return chain, nil
+}
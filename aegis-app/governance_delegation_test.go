@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func grantDelegation(opID string, grantor string, grantee string, role string, lamport int64) AdminDelegation {
+	return AdminDelegation{OpID: opID, GrantorPubkey: grantor, GranteePubkey: grantee, Action: adminDelegationGrant, Role: role, Lamport: lamport}
+}
+
+func revokeDelegation(opID string, grantor string, grantee string, lamport int64) AdminDelegation {
+	return AdminDelegation{OpID: opID, GrantorPubkey: grantor, GranteePubkey: grantee, Action: adminDelegationRevoke, Lamport: lamport}
+}
+
+func TestReplayAdminDelegations(t *testing.T) {
+	roots := map[string]bool{"root": true}
+	cases := []struct {
+		name        string
+		delegations []AdminDelegation
+		roles       map[string]string
+		valid       []string
+	}{
+		{
+			name: "admin grants moderator",
+			delegations: []AdminDelegation{
+				grantDelegation("g1", "root", "alice", adminRoleAdmin, 1),
+				grantDelegation("g2", "alice", "bob", adminRoleModerator, 2),
+			},
+			roles: map[string]string{"alice": adminRoleAdmin, "bob": adminRoleModerator},
+			valid: []string{"g1", "g2"},
+		},
+		{
+			name: "moderator cannot grant",
+			delegations: []AdminDelegation{
+				grantDelegation("g1", "root", "bob", adminRoleModerator, 1),
+				grantDelegation("g2", "bob", "carol", adminRoleModerator, 2),
+			},
+			roles: map[string]string{"bob": adminRoleModerator},
+			valid: []string{"g1"},
+		},
+		{
+			name: "replay follows lamport, not input order",
+			delegations: []AdminDelegation{
+				grantDelegation("g2", "alice", "bob", adminRoleModerator, 2),
+				grantDelegation("g1", "root", "alice", adminRoleAdmin, 1),
+			},
+			roles: map[string]string{"alice": adminRoleAdmin, "bob": adminRoleModerator},
+			valid: []string{"g1", "g2"},
+		},
+		{
+			name: "grant before its grantor is authorized",
+			delegations: []AdminDelegation{
+				grantDelegation("g1", "alice", "bob", adminRoleModerator, 1),
+				grantDelegation("g2", "root", "alice", adminRoleAdmin, 2),
+			},
+			roles: map[string]string{"alice": adminRoleAdmin},
+			valid: []string{"g2"},
+		},
+		{
+			name: "revoking an admin drops its delegates",
+			delegations: []AdminDelegation{
+				grantDelegation("g1", "root", "alice", adminRoleAdmin, 1),
+				grantDelegation("g2", "alice", "bob", adminRoleAdmin, 2),
+				grantDelegation("g3", "bob", "carol", adminRoleModerator, 3),
+				revokeDelegation("r1", "root", "alice", 4),
+			},
+			roles: map[string]string{},
+			valid: []string{"g1", "g2", "g3", "r1"},
+		},
+		{
+			name: "downgrading an admin drops its delegates",
+			delegations: []AdminDelegation{
+				grantDelegation("g1", "root", "alice", adminRoleAdmin, 1),
+				grantDelegation("g2", "alice", "bob", adminRoleModerator, 2),
+				grantDelegation("g3", "root", "alice", adminRoleModerator, 3),
+			},
+			roles: map[string]string{"alice": adminRoleModerator},
+			valid: []string{"g1", "g2", "g3"},
+		},
+		{
+			name: "roots cannot be revoked",
+			delegations: []AdminDelegation{
+				grantDelegation("g1", "root", "alice", adminRoleAdmin, 1),
+				revokeDelegation("r1", "alice", "root", 2),
+			},
+			roles: map[string]string{"alice": adminRoleAdmin},
+			valid: []string{"g1"},
+		},
+	}
+	for _, tc := range cases {
+		replay := replayAdminDelegations(roots, tc.delegations)
+		if !reflect.DeepEqual(replay.roles, tc.roles) {
+			t.Fatalf("%s: unexpected roles %v", tc.name, replay.roles)
+		}
+		valid := make(map[string]bool, len(tc.valid))
+		for _, opID := range tc.valid {
+			valid[opID] = true
+		}
+		if !reflect.DeepEqual(replay.valid, valid) {
+			t.Fatalf("%s: unexpected valid set %v", tc.name, replay.valid)
+		}
+	}
+}
+
+func TestListAdminDelegationsKeepsNewest(t *testing.T) {
+	app := newTestApp(t)
+	for i := 1; i <= 5; i++ {
+		if _, err := app.db.Exec(`
+			INSERT INTO governance_delegations (op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature, received_at)
+			VALUES (?, 'root', ?, ?, ?, 0, ?, 'sig', 0);
+		`, fmt.Sprintf("op-%d", i), fmt.Sprintf("grantee-%d", i), adminDelegationGrant, adminRoleModerator, int64(i)); err != nil {
+			t.Fatalf("insert delegation: %v", err)
+		}
+	}
+
+	delegations, err := app.listAdminDelegations(2)
+	if err != nil {
+		t.Fatalf("list delegations: %v", err)
+	}
+	opIDs := make([]string, 0, len(delegations))
+	for _, delegation := range delegations {
+		opIDs = append(opIDs, delegation.OpID)
+	}
+	if !reflect.DeepEqual(opIDs, []string{"op-4", "op-5"}) {
+		t.Fatalf("expected the newest delegations in chain order, got %v", opIDs)
+	}
+}
+
+func TestGovernancePolicyUpdateRequiresRootAdmin(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	if err := app.AddTrustedAdmin("root", adminRoleGenesis); err != nil {
+		t.Fatalf("add root: %v", err)
+	}
+	if _, err := app.db.Exec(`INSERT INTO governance_admins (admin_pubkey, role, active, source) VALUES ('delegate', ?, 1, ?);`, adminRoleAdmin, adminSourceDelegation); err != nil {
+		t.Fatalf("add delegate: %v", err)
+	}
+
+	update := IncomingMessage{Type: "GOVERNANCE_POLICY_UPDATE", AdminPubkey: "delegate", HideHistoryOnShadowBan: false, Timestamp: 1}
+	if err := app.applyIncomingMessage(update); !errors.Is(err, errGovernancePolicyNotRoot) {
+		t.Fatalf("expected delegated admin to be refused, got %v", err)
+	}
+	update.AdminPubkey = "root"
+	if err := app.applyIncomingMessage(update); err != nil {
+		t.Fatalf("root policy update: %v", err)
+	}
+}
+
+func TestOutOfOrderDelegationIsHeldUntilItsGrantArrives(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "0")
+	signers := make(map[string]*App)
+	pubkeys := make(map[string]string)
+	for _, name := range []string{"root", "alice"} {
+		signer := newTestApp(t)
+		identity, err := signer.CreateIdentity("", "correct horse battery")
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		signers[name] = signer
+		pubkeys[name] = identity.PublicKey
+	}
+	sign := func(opID string, grantor string, grantee string, role string, lamport int64) AdminDelegation {
+		message := adminDelegationMessage(AdminDelegation{OpID: opID, GrantorPubkey: pubkeys[grantor], GranteePubkey: grantee, Action: adminDelegationGrant, Role: role, Timestamp: lamport, Lamport: lamport})
+		if err := signers[grantor].signIncomingMessage(&message); err != nil {
+			t.Fatalf("sign %s: %v", opID, err)
+		}
+		return adminDelegationFromMessage(message)
+	}
+	toAlice := sign("g1", "root", pubkeys["alice"], adminRoleAdmin, 1)
+	toBob := sign("g2", "alice", "bob", adminRoleModerator, 2)
+
+	app := newTestApp(t)
+	if err := app.AddTrustedAdmin(pubkeys["root"], adminRoleGenesis); err != nil {
+		t.Fatalf("add root: %v", err)
+	}
+	if _, err := app.applyAdminDelegation(toBob); !errors.Is(err, errAdminDelegationUnauthorized) {
+		t.Fatalf("expected the grant to bob to wait for alice's, got %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM governance_delegations_pending WHERE op_id = 'g2';`); count != 1 {
+		t.Fatalf("out-of-order delegation was not held")
+	}
+
+	if inserted, err := app.applyAdminDelegation(toAlice); err != nil || !inserted {
+		t.Fatalf("apply grant to alice: inserted=%v err=%v", inserted, err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM governance_delegations_pending;`); count != 0 {
+		t.Fatalf("held delegation was not released")
+	}
+	if trusted, err := app.isTrustedAdmin("bob"); err != nil || !trusted {
+		t.Fatalf("expected bob to moderate once the chain is complete, trusted=%v err=%v", trusted, err)
+	}
+}
+
+func TestDelegationSyncCarriesFullChainsOfCurrentDelegates(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.AddTrustedAdmin("root", adminRoleGenesis); err != nil {
+		t.Fatalf("add root: %v", err)
+	}
+	for _, delegation := range []AdminDelegation{
+		grantDelegation("g1", "root", "alice", adminRoleAdmin, 1),
+		grantDelegation("g2", "alice", "bob", adminRoleModerator, 2),
+		grantDelegation("g3", "root", "carol", adminRoleModerator, 3),
+		revokeDelegation("r1", "root", "carol", 4),
+		revokeDelegation("r2", "root", "dave", 5),
+	} {
+		if _, err := app.db.Exec(`
+			INSERT INTO governance_delegations (op_id, grantor_pubkey, grantee_pubkey, action, role, timestamp, lamport, signature, received_at)
+			VALUES (?, ?, ?, ?, ?, 0, ?, 'sig', 0);
+		`, delegation.OpID, delegation.GrantorPubkey, delegation.GranteePubkey, delegation.Action, delegation.Role, delegation.Lamport); err != nil {
+			t.Fatalf("insert delegation: %v", err)
+		}
+	}
+
+	delegations, err := app.listAdminDelegationsForSync(1)
+	if err != nil {
+		t.Fatalf("list delegations: %v", err)
+	}
+	opIDs := make([]string, 0, len(delegations))
+	for _, delegation := range delegations {
+		opIDs = append(opIDs, delegation.OpID)
+	}
+	if !reflect.DeepEqual(opIDs, []string{"g1", "g2", "r2"}) {
+		t.Fatalf("expected bob's chain and the newest delegation, got %v", opIDs)
+	}
+}
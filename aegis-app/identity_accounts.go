@@ -0,0 +1,382 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tyler-smith/go-bip39"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const maxIdentityLabelLength = 64
+
+// LocalIdentity describes one persona held by this node. Only one identity is
+// active at a time; it is the viewer and the signer for local operations.
+type LocalIdentity struct {
+	PublicKey string `json:"publicKey"`
+	Label     string `json:"label"`
+	Active    bool   `json:"active"`
+	Encrypted bool   `json:"encrypted"`
+	Unlocked  bool   `json:"unlocked"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// migrateLocalIdentityTables rebuilds tables from the single-identity era:
+// local_identity lost its CHECK (id = 1) and sub_subscriptions became keyed
+// by the subscribing identity.
+func migrateLocalIdentityTables(db *sql.DB) error {
+	var identityDDL string
+	err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'local_identity';`).Scan(&identityDDL)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if strings.Contains(strings.ReplaceAll(identityDDL, " ", ""), "CHECK(id=1)") {
+		if err = rebuildTable(db, []string{
+			`CREATE TABLE local_identity_v2 (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				pubkey TEXT NOT NULL UNIQUE,
+				label TEXT NOT NULL DEFAULT '',
+				mnemonic TEXT NOT NULL DEFAULT '',
+				sealed_mnemonic TEXT NOT NULL DEFAULT '',
+				is_active INTEGER NOT NULL DEFAULT 0,
+				created_at INTEGER NOT NULL DEFAULT 0,
+				updated_at INTEGER NOT NULL
+			);`,
+			`INSERT INTO local_identity_v2 (pubkey, mnemonic, sealed_mnemonic, is_active, created_at, updated_at)
+			SELECT pubkey, mnemonic, sealed_mnemonic, 1, updated_at, updated_at
+			FROM local_identity
+			WHERE COALESCE(TRIM(pubkey), '') <> '';`,
+			`DROP TABLE local_identity;`,
+			`ALTER TABLE local_identity_v2 RENAME TO local_identity;`,
+		}); err != nil {
+			return err
+		}
+	}
+
+	var hasPubkey int
+	if err = db.QueryRow(`SELECT COUNT(1) FROM pragma_table_info('sub_subscriptions') WHERE name = 'pubkey';`).Scan(&hasPubkey); err != nil {
+		return err
+	}
+	if hasPubkey == 0 {
+		if err = rebuildTable(db, []string{
+			`CREATE TABLE sub_subscriptions_v2 (
+				pubkey TEXT NOT NULL DEFAULT '',
+				sub_id TEXT NOT NULL,
+				subscribed_at INTEGER NOT NULL,
+				PRIMARY KEY (pubkey, sub_id),
+				FOREIGN KEY(sub_id) REFERENCES subs(id) ON DELETE CASCADE
+			);`,
+			`INSERT INTO sub_subscriptions_v2 (pubkey, sub_id, subscribed_at)
+			SELECT COALESCE((SELECT pubkey FROM local_identity WHERE is_active = 1 LIMIT 1), ''), sub_id, subscribed_at
+			FROM sub_subscriptions;`,
+			`DROP TABLE sub_subscriptions;`,
+			`ALTER TABLE sub_subscriptions_v2 RENAME TO sub_subscriptions;`,
+			`CREATE INDEX IF NOT EXISTS idx_sub_subscriptions_subscribed_at ON sub_subscriptions(subscribed_at DESC);`,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if _, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_sub_subscriptions_pubkey ON sub_subscriptions(pubkey, subscribed_at DESC);`); err != nil {
+		return err
+	}
+	return nil
+}
+
+func rebuildTable(db *sql.DB, statements []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// setActiveIdentityTx marks pubkey as the only active identity. Subscriptions
+// made before any identity existed are adopted by it.
+func setActiveIdentityTx(tx *sql.Tx, pubkey string) error {
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(1) FROM local_identity WHERE pubkey = ?;`, pubkey).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return errIdentityNotFound
+	}
+
+	if _, err := tx.Exec(`UPDATE local_identity SET is_active = CASE WHEN pubkey = ? THEN 1 ELSE 0 END;`, pubkey); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`UPDATE OR IGNORE sub_subscriptions SET pubkey = ? WHERE pubkey = '';`, pubkey)
+	return err
+}
+
+func (a *App) setActiveIdentity(pubkey string) error {
+	if a.db == nil {
+		return errors.New("database not initialized")
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = setActiveIdentityTx(tx, strings.TrimSpace(pubkey)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// activeIdentityPubkey returns the active identity pubkey, or "" when no
+// identity exists yet. Per-identity rows written without an identity use "".
+func (a *App) activeIdentityPubkey() string {
+	identity, err := a.getLocalIdentity()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(identity.PublicKey)
+}
+
+func normalizeIdentityLabel(label string) (string, error) {
+	label = strings.TrimSpace(label)
+	if len([]rune(label)) > maxIdentityLabelLength {
+		return "", errors.New("identity label is too long")
+	}
+	return label, nil
+}
+
+// CreateIdentity generates a new identity sealed with passphrase and makes it
+// the active one. The returned mnemonic is shown once for backup.
+func (a *App) CreateIdentity(label string, passphrase string) (Identity, error) {
+	label, err := normalizeIdentityLabel(label)
+	if err != nil {
+		return Identity{}, err
+	}
+	if err = validateIdentityPassphrase(passphrase); err != nil {
+		return Identity{}, err
+	}
+
+	entropy, err := bip39.NewEntropy(128)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	identity, err := a.storeAndUnlockIdentity(mnemonic, passphrase)
+	if err != nil {
+		return Identity{}, err
+	}
+	if label != "" {
+		if err = a.RenameIdentity(identity.PublicKey, label); err != nil {
+			return Identity{}, err
+		}
+	}
+	a.emitIdentitySwitched(identity.PublicKey)
+
+	identity.Mnemonic = mnemonic
+	return identity, nil
+}
+
+func (a *App) ListIdentities() ([]LocalIdentity, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	rows, err := a.db.Query(`
+		SELECT pubkey, label, is_active, sealed_mnemonic <> '', created_at, updated_at
+		FROM local_identity
+		ORDER BY created_at ASC, id ASC;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	a.identityMu.RLock()
+	unlockedPubkey := a.identityPubkey
+	a.identityMu.RUnlock()
+
+	result := make([]LocalIdentity, 0)
+	for rows.Next() {
+		var identity LocalIdentity
+		var active int
+		if err = rows.Scan(&identity.PublicKey, &identity.Label, &active, &identity.Encrypted, &identity.CreatedAt, &identity.UpdatedAt); err != nil {
+			return nil, err
+		}
+		identity.Active = active == 1
+		identity.Unlocked = unlockedPubkey != "" && unlockedPubkey == identity.PublicKey
+		result = append(result, identity)
+	}
+	return result, rows.Err()
+}
+
+func (a *App) RenameIdentity(pubkey string, label string) error {
+	if a.db == nil {
+		return errors.New("database not initialized")
+	}
+
+	label, err := normalizeIdentityLabel(label)
+	if err != nil {
+		return err
+	}
+
+	result, err := a.db.Exec(`
+		UPDATE local_identity
+		SET label = ?, updated_at = ?
+		WHERE pubkey = ?;
+	`, label, time.Now().Unix(), strings.TrimSpace(pubkey))
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errIdentityNotFound
+	}
+	return nil
+}
+
+// SwitchIdentity unlocks the identity for pubkey and makes it the active one.
+// The previously unlocked key is wiped from memory.
+func (a *App) SwitchIdentity(pubkey string, passphrase string) (Identity, error) {
+	pubkey = strings.TrimSpace(pubkey)
+	if pubkey == "" {
+		return Identity{}, errors.New("identity pubkey is required")
+	}
+
+	identity, err := a.unlockStoredIdentity(pubkey, passphrase)
+	if err != nil {
+		return Identity{}, err
+	}
+	a.emitIdentitySwitched(identity.PublicKey)
+	return identity, nil
+}
+
+// DeleteIdentity removes an identity and its local-only settings after
+// checking its passphrase. A legacy plaintext identity has no passphrase to
+// check, so it must be unlocked once, which seals it, before it can be
+// deleted. Signed network state (posts, votes, favorites) is left in place.
+// Deleting the active identity activates the most recently used remaining
+// one, still locked.
+func (a *App) DeleteIdentity(pubkey string, passphrase string) error {
+	if a.db == nil {
+		return errors.New("database not initialized")
+	}
+
+	pubkey = strings.TrimSpace(pubkey)
+	if pubkey == "" {
+		return errors.New("identity pubkey is required")
+	}
+	if passphrase == "" {
+		return errIdentityPassphraseRequired
+	}
+
+	_, _, sealed, err := a.loadLocalIdentitySecret(pubkey)
+	if err != nil {
+		return err
+	}
+	if sealed == "" {
+		return errIdentityNotSealed
+	}
+	if _, err = openSealedMnemonic(sealed, pubkey, passphrase); err != nil {
+		return err
+	}
+
+	wasActive := a.activeIdentityPubkey() == pubkey
+	subIDs, err := a.listIdentitySubIDs(pubkey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, statement := range []string{
+		`DELETE FROM local_identity WHERE pubkey = ?;`,
+		`DELETE FROM sub_subscriptions WHERE pubkey = ?;`,
+		`DELETE FROM privacy_settings WHERE pubkey = ?;`,
+	} {
+		if _, err = tx.Exec(statement, pubkey); err != nil {
+			return err
+		}
+	}
+
+	nextActive := ""
+	if wasActive {
+		err = tx.QueryRow(`SELECT pubkey FROM local_identity ORDER BY updated_at DESC, id DESC LIMIT 1;`).Scan(&nextActive)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if nextActive != "" {
+			if err = setActiveIdentityTx(tx, nextActive); err != nil {
+				return err
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	a.identityMu.RLock()
+	unlocked := a.identityPubkey == pubkey
+	a.identityMu.RUnlock()
+	if unlocked {
+		a.LockIdentity()
+	}
+
+	for _, subID := range subIDs {
+		a.syncSubTopicMembership(subID)
+	}
+	if wasActive {
+		a.emitIdentitySwitched(nextActive)
+	}
+	return nil
+}
+
+func (a *App) listIdentitySubIDs(pubkey string) ([]string, error) {
+	rows, err := a.db.Query(`SELECT sub_id FROM sub_subscriptions WHERE pubkey = ?;`, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var subID string
+		if err = rows.Scan(&subID); err != nil {
+			return nil, err
+		}
+		result = append(result, subID)
+	}
+	return result, rows.Err()
+}
+
+func (a *App) emitIdentitySwitched(pubkey string) {
+	if a.ctx == nil {
+		return
+	}
+	runtime.LogInfof(a.ctx, "identity.switched pubkey=%s", pubkey)
+	runtime.EventsEmit(a.ctx, "identity:switched", map[string]string{"publicKey": pubkey})
+	runtime.EventsEmit(a.ctx, "subs:subscriptions_updated")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestDeleteLegacyIdentityRequiresMigration(t *testing.T) {
+	app := newTestApp(t)
+	const mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	publicKey, _, err := app.deriveKeypairFromMnemonic(mnemonic)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	pubkey := hex.EncodeToString(publicKey)
+	if _, err = app.db.Exec(`
+		INSERT INTO local_identity (pubkey, mnemonic, sealed_mnemonic, is_active, created_at, updated_at)
+		VALUES (?, ?, '', 1, 1, 1);
+	`, pubkey, mnemonic); err != nil {
+		t.Fatalf("insert legacy identity: %v", err)
+	}
+
+	if err = app.DeleteIdentity(pubkey, "any passphrase"); !errors.Is(err, errIdentityNotSealed) {
+		t.Fatalf("expected a legacy identity to need migration first, got %v", err)
+	}
+
+	// Unlocking seals the mnemonic with the passphrase and clears the
+	// plaintext copy.
+	if _, err = app.UnlockIdentity("correct horse battery"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM local_identity WHERE pubkey = ? AND mnemonic = '' AND sealed_mnemonic <> '';`, pubkey); count != 1 {
+		t.Fatalf("expected the identity to be sealed after unlock")
+	}
+
+	if err = app.DeleteIdentity(pubkey, "wrong horse battery"); !errors.Is(err, errIdentityPassphraseInvalid) {
+		t.Fatalf("expected the wrong passphrase to be refused, got %v", err)
+	}
+	if err = app.DeleteIdentity(pubkey, "correct horse battery"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM local_identity;`); count != 0 {
+		t.Fatalf("expected the identity to be deleted, %d rows left", count)
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	sealedMnemonicVersion = 1
+	sealedMnemonicKDF     = "scrypt"
+	sealedMnemonicAEAD    = "xchacha20poly1305"
+
+	scryptCostN      = 1 << 15
+	scryptBlockSizeR = 8
+	scryptParallelP  = 1
+	scryptSaltBytes  = 16
+	scryptKeyBytes   = chacha20poly1305.KeySize
+
+	minIdentityPassphraseLength = 8
+)
+
+var (
+	errIdentityLocked             = errors.New("identity is locked")
+	errIdentityNotFound           = errors.New("identity not found")
+	errIdentityPassphraseRequired = errors.New("identity passphrase is required")
+	errIdentityPassphraseTooShort = errors.New("identity passphrase is too short")
+	errIdentityPassphraseInvalid  = errors.New("invalid identity passphrase")
+	errIdentityNotSealed          = errors.New("identity must be unlocked once to encrypt it first")
+)
+
+// sealedMnemonic is the at-rest form of the identity mnemonic. The key is
+// derived from the user passphrase with scrypt and the mnemonic is sealed with
+// XChaCha20-Poly1305, using the public key as associated data so a sealed blob
+// cannot be swapped onto a different identity row.
+type sealedMnemonic struct {
+	Version    int    `json:"v"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`
+	AEAD       string `json:"aead"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type IdentityLockStatus struct {
+	HasIdentity bool   `json:"hasIdentity"`
+	Encrypted   bool   `json:"encrypted"`
+	Unlocked    bool   `json:"unlocked"`
+	PublicKey   string `json:"publicKey"`
+}
+
+func resolveIdentityPassphraseFromEnv() string {
+	return os.Getenv("AEGIS_IDENTITY_PASSPHRASE")
+}
+
+func validateIdentityPassphrase(passphrase string) error {
+	if passphrase == "" {
+		return errIdentityPassphraseRequired
+	}
+	if len([]rune(passphrase)) < minIdentityPassphraseLength {
+		return errIdentityPassphraseTooShort
+	}
+	return nil
+}
+
+func sealMnemonic(mnemonic string, pubkey string, passphrase string) (string, error) {
+	salt := make([]byte, scryptSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptCostN, scryptBlockSizeR, scryptParallelP, scryptKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ciphertext := aead.Seal(nil, nonce, []byte(mnemonic), []byte(pubkey))
+
+	raw, err := json.Marshal(sealedMnemonic{
+		Version:    sealedMnemonicVersion,
+		KDF:        sealedMnemonicKDF,
+		N:          scryptCostN,
+		R:          scryptBlockSizeR,
+		P:          scryptParallelP,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		AEAD:       sealedMnemonicAEAD,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func openSealedMnemonic(sealed string, pubkey string, passphrase string) (string, error) {
+	var envelope sealedMnemonic
+	if err := json.Unmarshal([]byte(sealed), &envelope); err != nil {
+		return "", errors.New("invalid sealed identity")
+	}
+	if envelope.Version != sealedMnemonicVersion || envelope.KDF != sealedMnemonicKDF || envelope.AEAD != sealedMnemonicAEAD {
+		return "", errors.New("unsupported sealed identity format")
+	}
+	if envelope.N <= 1 || envelope.R <= 0 || envelope.P <= 0 {
+		return "", errors.New("invalid sealed identity parameters")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(envelope.Salt)
+	if err != nil {
+		return "", errors.New("invalid sealed identity salt")
+	}
+	nonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	if err != nil {
+		return "", errors.New("invalid sealed identity nonce")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return "", errors.New("invalid sealed identity ciphertext")
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, envelope.N, envelope.R, envelope.P, scryptKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return "", errors.New("invalid sealed identity nonce")
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(pubkey))
+	if err != nil {
+		return "", errIdentityPassphraseInvalid
+	}
+	return string(plaintext), nil
+}
+
+// loadLocalIdentitySecret returns a stored identity row, the active one when
+// pubkey is empty. Exactly one of plaintextMnemonic (legacy, pre-encryption
+// rows) and sealed is non-empty.
+func (a *App) loadLocalIdentitySecret(pubkey string) (string, string, string, error) {
+	if a.db == nil {
+		return "", "", "", errors.New("database not initialized")
+	}
+
+	pubkey = strings.TrimSpace(pubkey)
+	if pubkey == "" {
+		identity, err := a.getLocalIdentity()
+		if err != nil {
+			return "", "", "", err
+		}
+		pubkey = identity.PublicKey
+	}
+
+	var plaintextMnemonic, sealed string
+	err := a.db.QueryRow(`
+		SELECT mnemonic, sealed_mnemonic
+		FROM local_identity
+		WHERE pubkey = ?;
+	`, pubkey).Scan(&plaintextMnemonic, &sealed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", "", errIdentityNotFound
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	return strings.TrimSpace(pubkey), strings.TrimSpace(plaintextMnemonic), strings.TrimSpace(sealed), nil
+}
+
+func (a *App) setUnlockedIdentityKey(pubkey string, privateKey ed25519.PrivateKey) {
+	a.identityMu.Lock()
+	defer a.identityMu.Unlock()
+	for i := range a.identityKey {
+		a.identityKey[i] = 0
+	}
+	a.identityPubkey = pubkey
+	a.identityKey = privateKey
+}
+
+// sealIdentity derives the keypair of mnemonic and seals it with passphrase.
+func (a *App) sealIdentity(mnemonic string, passphrase string) (string, string, ed25519.PrivateKey, error) {
+	if err := validateIdentityPassphrase(passphrase); err != nil {
+		return "", "", nil, err
+	}
+	publicKey, privateKey, err := a.deriveKeypairFromMnemonic(mnemonic)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	pubkey := hex.EncodeToString(publicKey)
+	sealed, err := sealMnemonic(mnemonic, pubkey, passphrase)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return pubkey, sealed, privateKey, nil
+}
+
+// storeAndUnlockIdentity seals mnemonic with passphrase, persists it and keeps
+// the derived signing key in memory.
+func (a *App) storeAndUnlockIdentity(mnemonic string, passphrase string) (Identity, error) {
+	pubkey, sealed, privateKey, err := a.sealIdentity(mnemonic, passphrase)
+	if err != nil {
+		return Identity{}, err
+	}
+	if err = a.saveLocalIdentity(pubkey, sealed); err != nil {
+		return Identity{}, err
+	}
+
+	a.setUnlockedIdentityKey(pubkey, privateKey)
+	return Identity{PublicKey: pubkey}, nil
+}
+
+// migratePlaintextIdentity seals a legacy plaintext mnemonic and scrubs the
+// plaintext from disk. Blanking the column alone leaves the old cell in freed
+// pages and in the WAL, so the overwrite runs on a connection with
+// secure_delete on, which zeroes what it frees, and is followed by a
+// checkpoint that truncates the WAL and a VACUUM that rebuilds the file.
+func (a *App) migratePlaintextIdentity(mnemonic string, passphrase string) (Identity, error) {
+	pubkey, sealed, privateKey, err := a.sealIdentity(mnemonic, passphrase)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	ctx := context.Background()
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	defer conn.Close()
+
+	for _, pragma := range []string{"PRAGMA busy_timeout = 5000;", "PRAGMA secure_delete = ON;"} {
+		if _, err = conn.ExecContext(ctx, pragma); err != nil {
+			return Identity{}, err
+		}
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return Identity{}, err
+	}
+	if err = saveLocalIdentityTx(tx, pubkey, sealed); err != nil {
+		_ = tx.Rollback()
+		return Identity{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return Identity{}, err
+	}
+	a.setUnlockedIdentityKey(pubkey, privateKey)
+
+	for _, statement := range []string{"PRAGMA wal_checkpoint(TRUNCATE);", "VACUUM;", "PRAGMA wal_checkpoint(TRUNCATE);"} {
+		if _, err = conn.ExecContext(ctx, statement); err != nil {
+			return Identity{}, fmt.Errorf("scrub plaintext mnemonic: %w", err)
+		}
+	}
+	return Identity{PublicKey: pubkey}, nil
+}
+
+// UnlockIdentity decrypts the active identity's mnemonic with passphrase and
+// keeps the signing key in memory until LockIdentity. A legacy plaintext row is
+// sealed with passphrase on its first unlock.
+func (a *App) UnlockIdentity(passphrase string) (Identity, error) {
+	return a.unlockStoredIdentity("", passphrase)
+}
+
+// unlockStoredIdentity unlocks the identity for pubkey (the active one when
+// empty) and makes it the active identity.
+func (a *App) unlockStoredIdentity(pubkey string, passphrase string) (Identity, error) {
+	if passphrase == "" {
+		return Identity{}, errIdentityPassphraseRequired
+	}
+
+	pubkey, plaintextMnemonic, sealed, err := a.loadLocalIdentitySecret(pubkey)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if sealed == "" {
+		if plaintextMnemonic == "" {
+			return Identity{}, errIdentityNotFound
+		}
+		identity, migrateErr := a.migratePlaintextIdentity(plaintextMnemonic, passphrase)
+		if migrateErr != nil {
+			return Identity{}, migrateErr
+		}
+		if a.ctx != nil {
+			runtime.LogInfof(a.ctx, "identity.migrated_to_sealed pubkey=%s", identity.PublicKey)
+		}
+		return identity, nil
+	}
+
+	mnemonic, err := openSealedMnemonic(sealed, pubkey, passphrase)
+	if err != nil {
+		return Identity{}, err
+	}
+	publicKey, privateKey, err := a.deriveKeypairFromMnemonic(mnemonic)
+	if err != nil {
+		return Identity{}, err
+	}
+	if hex.EncodeToString(publicKey) != pubkey {
+		return Identity{}, errors.New("sealed identity does not match public key")
+	}
+	if err = a.setActiveIdentity(pubkey); err != nil {
+		return Identity{}, err
+	}
+
+	a.setUnlockedIdentityKey(pubkey, privateKey)
+	return Identity{PublicKey: pubkey}, nil
+}
+
+func (a *App) LockIdentity() {
+	a.identityMu.Lock()
+	defer a.identityMu.Unlock()
+	for i := range a.identityKey {
+		a.identityKey[i] = 0
+	}
+	a.identityKey = nil
+	a.identityPubkey = ""
+}
+
+func (a *App) IsIdentityUnlocked() bool {
+	a.identityMu.RLock()
+	defer a.identityMu.RUnlock()
+	return len(a.identityKey) == ed25519.PrivateKeySize
+}
+
+func (a *App) GetIdentityLockStatus() (IdentityLockStatus, error) {
+	pubkey, _, sealed, err := a.loadLocalIdentitySecret("")
+	if errors.Is(err, errIdentityNotFound) {
+		return IdentityLockStatus{}, nil
+	}
+	if err != nil {
+		return IdentityLockStatus{}, err
+	}
+
+	a.identityMu.RLock()
+	unlocked := len(a.identityKey) == ed25519.PrivateKeySize && a.identityPubkey == pubkey
+	a.identityMu.RUnlock()
+
+	return IdentityLockStatus{
+		HasIdentity: true,
+		Encrypted:   sealed != "",
+		Unlocked:    unlocked,
+		PublicKey:   pubkey,
+	}, nil
+}
+
+func (a *App) ChangeIdentityPassphrase(currentPassphrase string, newPassphrase string) error {
+	if err := validateIdentityPassphrase(newPassphrase); err != nil {
+		return err
+	}
+
+	pubkey, plaintextMnemonic, sealed, err := a.loadLocalIdentitySecret("")
+	if err != nil {
+		return err
+	}
+	if sealed == "" {
+		_, err = a.migratePlaintextIdentity(plaintextMnemonic, newPassphrase)
+		return err
+	}
+	mnemonic, err := openSealedMnemonic(sealed, pubkey, currentPassphrase)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.storeAndUnlockIdentity(mnemonic, newPassphrase)
+	return err
+}
+
+// ExportIdentityMnemonic reveals the mnemonic for an explicit user backup. It
+// always re-checks the passphrase, even while the identity is unlocked.
+func (a *App) ExportIdentityMnemonic(passphrase string) (string, error) {
+	pubkey, _, sealed, err := a.loadLocalIdentitySecret("")
+	if err != nil {
+		return "", err
+	}
+	if sealed == "" {
+		return "", errors.New("identity must be unlocked once to encrypt it before export")
+	}
+	return openSealedMnemonic(sealed, pubkey, passphrase)
+}
+
+// signWithLocalIdentity signs payload with the unlocked in-memory key. The
+// signer must be named and must be the unlocked identity; payloads are built
+// by the publish paths, never taken from the UI.
+func (a *App) signWithLocalIdentity(signer string, payload string) (string, error) {
+	a.identityMu.RLock()
+	defer a.identityMu.RUnlock()
+
+	if len(a.identityKey) != ed25519.PrivateKeySize {
+		return "", errIdentityLocked
+	}
+	if signer = strings.TrimSpace(signer); signer == "" || signer != a.identityPubkey {
+		return "", errMessageSignerMismatch
+	}
+
+	signature := ed25519.Sign(a.identityKey, []byte(payload))
+	return hex.EncodeToString(signature), nil
+}
+
+func (a *App) unlockIdentityFromEnv() {
+	passphrase := resolveIdentityPassphraseFromEnv()
+	if passphrase == "" {
+		return
+	}
+	if _, err := a.UnlockIdentity(passphrase); err != nil && !errors.Is(err, errIdentityNotFound) {
+		if a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "identity.env_unlock failed err=%v", err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+func TestSealedMnemonicRoundTrip(t *testing.T) {
+	const mnemonic = "abandon ability able about above absent absorb abstract absurd abuse access accident"
+	sealed, err := sealMnemonic(mnemonic, "pubkey-a", "correct horse battery")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	opened, err := openSealedMnemonic(sealed, "pubkey-a", "correct horse battery")
+	if err != nil || opened != mnemonic {
+		t.Fatalf("unexpected unseal %q, err %v", opened, err)
+	}
+
+	if _, err = openSealedMnemonic(sealed, "pubkey-a", "wrong horse battery"); !errors.Is(err, errIdentityPassphraseInvalid) {
+		t.Fatalf("expected wrong passphrase to fail, got %v", err)
+	}
+	if _, err = openSealedMnemonic(sealed, "pubkey-b", "correct horse battery"); !errors.Is(err, errIdentityPassphraseInvalid) {
+		t.Fatalf("expected a sealed blob moved to another identity to fail, got %v", err)
+	}
+
+	var envelope sealedMnemonic
+	if err = json.Unmarshal([]byte(sealed), &envelope); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	corrupt := func(mutate func(*sealedMnemonic)) string {
+		copied := envelope
+		mutate(&copied)
+		raw, _ := json.Marshal(copied)
+		return string(raw)
+	}
+	for name, blob := range map[string]string{
+		"not json": "{sealed",
+		"ciphertext": corrupt(func(e *sealedMnemonic) {
+			raw, _ := base64.StdEncoding.DecodeString(e.Ciphertext)
+			raw[0] ^= 1
+			e.Ciphertext = base64.StdEncoding.EncodeToString(raw)
+		}),
+		"truncated nonce": corrupt(func(e *sealedMnemonic) { e.Nonce = base64.StdEncoding.EncodeToString([]byte("short")) }),
+		"bad salt":        corrupt(func(e *sealedMnemonic) { e.Salt = "%%%" }),
+		"version":         corrupt(func(e *sealedMnemonic) { e.Version = sealedMnemonicVersion + 1 }),
+		"kdf params":      corrupt(func(e *sealedMnemonic) { e.N = 0 }),
+	} {
+		if _, err = openSealedMnemonic(blob, "pubkey-a", "correct horse battery"); err == nil {
+			t.Fatalf("%s: expected corrupted blob to fail", name)
+		}
+	}
+}
+
+func TestIdentityLockUnlockAndCorruptedKeystore(t *testing.T) {
+	app := newTestApp(t)
+	identity, err := app.CreateIdentity("", "correct horse battery")
+	if err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+	if _, err = app.signWithLocalIdentity("", "payload"); !errors.Is(err, errMessageSignerMismatch) {
+		t.Fatalf("expected an unnamed signer to be refused, got %v", err)
+	}
+
+	app.LockIdentity()
+	if _, err = app.signWithLocalIdentity(identity.PublicKey, "payload"); !errors.Is(err, errIdentityLocked) {
+		t.Fatalf("expected signing while locked to fail, got %v", err)
+	}
+	if _, err = app.UnlockIdentity("wrong horse battery"); !errors.Is(err, errIdentityPassphraseInvalid) || app.IsIdentityUnlocked() {
+		t.Fatalf("expected wrong passphrase to keep the identity locked, got %v", err)
+	}
+	if _, err = app.UnlockIdentity("correct horse battery"); err != nil || !app.IsIdentityUnlocked() {
+		t.Fatalf("unlock: %v", err)
+	}
+	signature, err := app.signWithLocalIdentity(identity.PublicKey, "payload")
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if valid, verifyErr := app.VerifyMessage(identity.PublicKey, "payload", signature); verifyErr != nil || !valid {
+		t.Fatalf("expected signature to verify, err %v", verifyErr)
+	}
+
+	app.LockIdentity()
+	if _, err = app.db.Exec(`UPDATE local_identity SET sealed_mnemonic = ? WHERE pubkey = ?;`, `{"v":1,"kdf":"scrypt"`, identity.PublicKey); err != nil {
+		t.Fatalf("corrupt keystore: %v", err)
+	}
+	if _, err = app.UnlockIdentity("correct horse battery"); err == nil || app.IsIdentityUnlocked() {
+		t.Fatalf("expected a corrupted keystore to stay locked, got %v", err)
+	}
+}
+
+func TestPlaintextIdentityMigrationScrubsTheMnemonicFromDisk(t *testing.T) {
+	app := newTestApp(t)
+	entropy, err := bip39.NewEntropy(128)
+	if err != nil {
+		t.Fatalf("entropy: %v", err)
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		t.Fatalf("mnemonic: %v", err)
+	}
+	publicKey, _, err := app.deriveKeypairFromMnemonic(mnemonic)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	pubkey := hex.EncodeToString(publicKey)
+	if _, err = app.db.Exec(`
+		INSERT INTO local_identity (pubkey, mnemonic, sealed_mnemonic, is_active, created_at, updated_at)
+		VALUES (?, ?, '', 1, 1, 1);
+	`, pubkey, mnemonic); err != nil {
+		t.Fatalf("seed legacy identity: %v", err)
+	}
+
+	if _, err = app.UnlockIdentity("correct horse battery"); err != nil {
+		t.Fatalf("unlock legacy identity: %v", err)
+	}
+	status, err := app.GetIdentityLockStatus()
+	if err != nil || !status.Encrypted || !status.Unlocked {
+		t.Fatalf("expected a sealed, unlocked identity, got %+v (%v)", status, err)
+	}
+	for _, path := range []string{app.dbPath, app.dbPath + "-wal"} {
+		data, readErr := os.ReadFile(path)
+		if errors.Is(readErr, os.ErrNotExist) {
+			continue
+		}
+		if readErr != nil {
+			t.Fatalf("read %s: %v", path, readErr)
+		}
+		if bytes.Contains(data, []byte(mnemonic)) {
+			t.Fatalf("plaintext mnemonic survived migration in %s", filepath.Base(path))
+		}
+	}
+}
@@ -11,12 +11,18 @@ import (
 )
 
 func main() {
+	os.Exit(runRelayCLI(os.Args[1:]))
+}
+
+// runRelayNode starts the node and serves until SIGINT or SIGTERM.
+func runRelayNode() int {
 	app := NewApp()
 
 	if err := app.initDatabase(); err != nil {
 		fmt.Printf("relay init database failed: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
+	app.unlockIdentityFromEnv()
 
 	listenPort := resolveAutoStartP2PPort()
 	bootstrapPeers := resolveBootstrapPeers()
@@ -25,10 +31,11 @@ func main() {
 	if err != nil {
 		fmt.Printf("relay start p2p failed: %v\n", err)
 		_ = app.db.Close()
-		os.Exit(1)
+		return 1
 	}
 
 	fmt.Printf("relay started: peer_id=%s topic=%s\n", status.PeerID, status.Topic)
+	fmt.Printf("sub_topics: %s\n", strings.Join(status.SubTopics, ", "))
 	if len(status.ListenAddrs) == 0 {
 		fmt.Println("listen_addrs: none")
 	} else {
@@ -47,12 +54,38 @@ func main() {
 		}
 	}
 
+	adminAddr, err := app.startAdminAPI()
+	if err != nil {
+		fmt.Printf("relay start admin api failed: %v\n", err)
+		_ = app.StopP2P()
+		_ = app.db.Close()
+		return 1
+	}
+	if adminAddr != "" {
+		fmt.Printf("admin_api: http://%s (bearer token from AEGIS_ADMIN_API_TOKEN or %s next to the database)\n", adminAddr, adminAPITokenFileName)
+	}
+
+	metricsAddr, err := app.startMetricsExporter()
+	if err != nil {
+		fmt.Printf("relay start metrics exporter failed: %v\n", err)
+		_ = app.stopAdminAPI()
+		_ = app.StopP2P()
+		_ = app.db.Close()
+		return 1
+	}
+	if metricsAddr != "" {
+		fmt.Printf("metrics: http://%s/metrics\n", metricsAddr)
+	}
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
 
+	_ = app.stopMetricsExporter()
+	_ = app.stopAdminAPI()
 	_ = app.StopP2P()
 	if app.db != nil {
 		_ = app.db.Close()
 	}
+	return 0
 }
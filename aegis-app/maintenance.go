@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// A maintenance run collects tombstones, compacts op logs, repairs the
+// search index, reclaims free pages and refreshes the query planner
+// statistics. The worker runs one on a timer and RunMaintenanceNow runs one
+// on demand. Every run is written to maintenance_runs, including the steps
+// that failed, and a failed step does not stop the ones after it.
+//
+// Compaction only drops op rows whose outcome is already held by a state
+// row, and only once they are older than the op retention horizon. Vote op
+// IDs guard against replays; once one is dropped, vote_clocks and the
+// horizon itself keep a replayed vote from applying again. The
+// favorite and moderation reconcile sets leave out the same rows past the
+// horizon whether or not they were compacted yet, so peers stop offering
+// each other ops one side has already dropped.
+const (
+	defaultMaintenanceInterval        = 6 * time.Hour
+	maintenanceInitialDelay           = 2 * time.Minute
+	defaultMaintenanceOpRetentionDays = 30
+	defaultMaintenanceVacuumPages     = 2000
+	maintenanceAnalysisLimit          = 1000
+	maintenanceRunLogKeep             = 500
+
+	defaultMaintenanceRunLimit = 20
+	maxMaintenanceRunLimit     = 500
+
+	maintenanceTriggerScheduled = "scheduled"
+	maintenanceTriggerManual    = "manual"
+
+	// sqliteAutoVacuumIncremental is what PRAGMA auto_vacuum reports once
+	// the database is in incremental mode.
+	sqliteAutoVacuumIncremental = 2
+)
+
+var errMaintenanceRunning = errors.New("maintenance is already running")
+
+// OpCompactionResult counts the op rows a run removed from each log.
+type OpCompactionResult struct {
+	EntityOps      int64 `json:"entityOps"`
+	VoteOps        int64 `json:"voteOps"`
+	FavoriteOps    int64 `json:"favoriteOps"`
+	ModerationLogs int64 `json:"moderationLogs"`
+}
+
+type MaintenanceRun struct {
+	ID         int64              `json:"id"`
+	Trigger    string             `json:"trigger"`
+	StartedAt  int64              `json:"startedAt"`
+	FinishedAt int64              `json:"finishedAt"`
+	DurationMs int64              `json:"durationMs"`
+	GC         TombstoneGCResult  `json:"gc"`
+	Compaction OpCompactionResult `json:"compaction"`
+	// SearchRepaired counts the search index entries the run rebuilt or
+	// refreshed because a reindex after a write had failed.
+	SearchRepaired int64 `json:"searchRepaired"`
+	// VacuumConverted is set on the run that switched an older database to
+	// incremental auto-vacuum, which takes one full VACUUM.
+	VacuumConverted bool     `json:"vacuumConverted"`
+	FreedPages      int64    `json:"freedPages"`
+	FreelistPages   int64    `json:"freelistPages"`
+	Analyzed        bool     `json:"analyzed"`
+	Errors          []string `json:"errors"`
+}
+
+func resolveMaintenanceInterval() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("AEGIS_MAINTENANCE_INTERVAL_SEC"))
+	if raw != "" {
+		if seconds, err := strconv.Atoi(raw); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultMaintenanceInterval
+}
+
+func resolveMaintenanceOpRetentionDays() int {
+	raw := strings.TrimSpace(os.Getenv("AEGIS_MAINTENANCE_OP_RETENTION_DAYS"))
+	if raw != "" {
+		if days, err := strconv.Atoi(raw); err == nil && days > 0 {
+			return days
+		}
+	}
+	return defaultMaintenanceOpRetentionDays
+}
+
+// maintenanceOpCutoff returns the op retention horizon as of now.
+func maintenanceOpCutoff(now time.Time) int64 {
+	return now.Unix() - int64(resolveMaintenanceOpRetentionDays())*24*3600
+}
+
+func resolveMaintenanceVacuumPages() int {
+	raw := strings.TrimSpace(os.Getenv("AEGIS_MAINTENANCE_VACUUM_PAGES"))
+	if raw != "" {
+		if pages, err := strconv.Atoi(raw); err == nil && pages > 0 {
+			return pages
+		}
+	}
+	return defaultMaintenanceVacuumPages
+}
+
+func (a *App) runMaintenanceWorker(ctx context.Context) {
+	ticker := time.NewTicker(resolveMaintenanceInterval())
+	defer ticker.Stop()
+
+	initialTimer := time.NewTimer(maintenanceInitialDelay)
+	defer initialTimer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-initialTimer.C:
+			a.runScheduledMaintenance(ctx)
+		case <-ticker.C:
+			a.runScheduledMaintenance(ctx)
+		}
+	}
+}
+
+func (a *App) runScheduledMaintenance(ctx context.Context) {
+	if _, err := a.runMaintenance(ctx, maintenanceTriggerScheduled); err != nil &&
+		!errors.Is(err, errMaintenanceRunning) && a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "maintenance.run failed err=%v", err)
+	}
+}
+
+// RunMaintenanceNow runs every maintenance step right away and returns the
+// recorded run.
+func (a *App) RunMaintenanceNow() (MaintenanceRun, error) {
+	return a.runMaintenance(context.Background(), maintenanceTriggerManual)
+}
+
+func (a *App) runMaintenance(ctx context.Context, trigger string) (MaintenanceRun, error) {
+	if a.db == nil {
+		return MaintenanceRun{}, errors.New("database not initialized")
+	}
+	if !a.maintenanceMu.TryLock() {
+		return MaintenanceRun{}, errMaintenanceRunning
+	}
+	defer a.maintenanceMu.Unlock()
+
+	started := time.Now()
+	run := MaintenanceRun{Trigger: trigger, StartedAt: started.Unix(), Errors: []string{}}
+	fail := func(step string, err error) {
+		run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", step, err))
+	}
+
+	gc, err := a.RunTombstoneGC(0, 0, 0)
+	if err != nil {
+		fail("gc", err)
+	}
+	run.GC = gc
+
+	cutoff := maintenanceOpCutoff(started)
+	compaction, err := a.compactOpLogs(cutoff)
+	if err != nil {
+		fail("compact", err)
+	}
+	run.Compaction = compaction
+
+	if run.SearchRepaired, err = repairSearchIndex(a.db); err != nil {
+		fail("search", err)
+	}
+
+	if err = a.reclaimDatabaseSpace(ctx, resolveMaintenanceVacuumPages(), &run); err != nil {
+		fail("vacuum", err)
+	}
+
+	finished := time.Now()
+	run.FinishedAt = finished.Unix()
+	run.DurationMs = finished.Sub(started).Milliseconds()
+	if run.ID, err = a.recordMaintenanceRun(run); err != nil {
+		return run, err
+	}
+
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "maintenance.run trigger=%s gc_posts=%d gc_comments=%d entity_ops=%d vote_ops=%d favorite_ops=%d moderation_logs=%d search_repaired=%d freed_pages=%d errors=%d",
+			trigger, run.GC.DeletedPosts, run.GC.DeletedComments, run.Compaction.EntityOps, run.Compaction.VoteOps,
+			run.Compaction.FavoriteOps, run.Compaction.ModerationLogs, run.SearchRepaired, run.FreedPages, len(run.Errors))
+	}
+	return run, nil
+}
+
+// compactOpLogs drops op rows older than cutoff whose outcome is already
+// materialized:
+//   - entity ops other than the current op of a post or comment;
+//   - vote op IDs, since a replay of a vote op past the horizon is dropped
+//     by acceptVoteOpTx;
+//   - favorite ops other than the last op of their favorite state;
+//   - moderation logs that were ignored as older, or that an applied action
+//     for the same target has since replaced.
+func (a *App) compactOpLogs(cutoff int64) (OpCompactionResult, error) {
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return OpCompactionResult{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result := OpCompactionResult{}
+	steps := []struct {
+		count *int64
+		query string
+	}{
+		{&result.EntityOps, `
+			DELETE FROM entity_ops
+			WHERE timestamp < ?
+			  AND (
+				(entity_type = 'post' AND EXISTS (
+					SELECT 1 FROM messages m WHERE m.id = entity_ops.entity_id AND m.current_op_id <> entity_ops.op_id
+				))
+				OR (entity_type = 'comment' AND EXISTS (
+					SELECT 1 FROM comments c WHERE c.id = entity_ops.entity_id AND c.current_op_id <> entity_ops.op_id
+				))
+			  );
+		`},
+		{&result.VoteOps, `DELETE FROM vote_ops WHERE created_at < ?;`},
+		{&result.FavoriteOps, `
+			DELETE FROM post_favorite_ops
+			WHERE created_at < ?
+			  AND EXISTS (
+				SELECT 1 FROM post_favorites_state s
+				WHERE s.pubkey = post_favorite_ops.pubkey
+				  AND s.post_id = post_favorite_ops.post_id
+				  AND s.last_op_id <> post_favorite_ops.op_id
+			  );
+		`},
+		{&result.ModerationLogs, `
+			DELETE FROM moderation_logs
+			WHERE timestamp < ?
+			  AND (
+				result <> 'applied'
+				OR EXISTS (
+					SELECT 1 FROM moderation s
+					WHERE s.target_pubkey = moderation_logs.target_pubkey AND s.timestamp > moderation_logs.timestamp
+				)
+			  );
+		`},
+	}
+	for _, step := range steps {
+		deleted, err := tx.Exec(step.query, cutoff)
+		if err != nil {
+			return OpCompactionResult{}, err
+		}
+		if *step.count, err = deleted.RowsAffected(); err != nil {
+			return OpCompactionResult{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return OpCompactionResult{}, err
+	}
+	return result, nil
+}
+
+// reclaimDatabaseSpace returns up to pages free pages to the file system and
+// re-runs ANALYZE. A database created before incremental auto-vacuum was
+// enabled is converted first, which rewrites the whole file once.
+func (a *App) reclaimDatabaseSpace(ctx context.Context, pages int, run *MaintenanceRun) error {
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "PRAGMA busy_timeout = 5000;"); err != nil {
+		return err
+	}
+
+	var mode int
+	if err = conn.QueryRowContext(ctx, "PRAGMA auto_vacuum;").Scan(&mode); err != nil {
+		return err
+	}
+	if mode != sqliteAutoVacuumIncremental {
+		if _, err = conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL;"); err != nil {
+			return err
+		}
+		if _, err = conn.ExecContext(ctx, "VACUUM;"); err != nil {
+			return err
+		}
+		run.VacuumConverted = true
+	}
+
+	before, err := sqliteFreelistCount(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if _, err = conn.ExecContext(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d);", pages)); err != nil {
+		return err
+	}
+	if run.FreelistPages, err = sqliteFreelistCount(ctx, conn); err != nil {
+		return err
+	}
+	run.FreedPages = before - run.FreelistPages
+
+	if _, err = conn.ExecContext(ctx, fmt.Sprintf("PRAGMA analysis_limit = %d;", maintenanceAnalysisLimit)); err != nil {
+		return err
+	}
+	if _, err = conn.ExecContext(ctx, "ANALYZE;"); err != nil {
+		return err
+	}
+	run.Analyzed = true
+	return nil
+}
+
+func sqliteFreelistCount(ctx context.Context, conn *sql.Conn) (int64, error) {
+	var count int64
+	err := conn.QueryRowContext(ctx, "PRAGMA freelist_count;").Scan(&count)
+	return count, err
+}
+
+func (a *App) recordMaintenanceRun(run MaintenanceRun) (int64, error) {
+	encoded, err := json.Marshal(run)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	inserted, err := tx.Exec(`
+		INSERT INTO maintenance_runs (triggered_by, started_at, finished_at, error_count, result_json)
+		VALUES (?, ?, ?, ?, ?);
+	`, run.Trigger, run.StartedAt, run.FinishedAt, len(run.Errors), string(encoded))
+	if err != nil {
+		return 0, err
+	}
+	id, err := inserted.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(`DELETE FROM maintenance_runs WHERE id <= ?;`, id-maintenanceRunLogKeep); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// GetMaintenanceRuns returns the most recent maintenance runs, newest first.
+func (a *App) GetMaintenanceRuns(limit int) ([]MaintenanceRun, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if limit <= 0 {
+		limit = defaultMaintenanceRunLimit
+	}
+	if limit > maxMaintenanceRunLimit {
+		limit = maxMaintenanceRunLimit
+	}
+
+	rows, err := a.db.Query(`
+		SELECT id, result_json
+		FROM maintenance_runs
+		ORDER BY id DESC
+		LIMIT ?;
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]MaintenanceRun, 0)
+	for rows.Next() {
+		var id int64
+		var encoded string
+		if err = rows.Scan(&id, &encoded); err != nil {
+			return nil, err
+		}
+		var run MaintenanceRun
+		if err = json.Unmarshal([]byte(encoded), &run); err != nil {
+			return nil, err
+		}
+		run.ID = id
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
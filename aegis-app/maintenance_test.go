@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceRunCompactsOpLogsAndRecordsRun(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	now := time.Now().Unix()
+	old := now - 40*24*3600
+
+	for _, version := range []ForumMessage{
+		{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Plan", Body: "one", Timestamp: old, Lamport: 10, Zone: "public", SubID: defaultSubID},
+		{ID: "p-1", Pubkey: "alice", OpID: "op-2", Title: "Plan v2", Body: "two", Timestamp: old + 60, Lamport: 20, Zone: "public", SubID: defaultSubID},
+		{ID: "p-2", Pubkey: "alice", OpID: "op-3", Title: "Recent", Body: "one", Timestamp: now - 60, Lamport: 30, Zone: "public", SubID: defaultSubID},
+		{ID: "p-2", Pubkey: "alice", OpID: "op-4", Title: "Recent v2", Body: "two", Timestamp: now - 30, Lamport: 40, Zone: "public", SubID: defaultSubID},
+	} {
+		if _, err := app.insertMessage(version); err != nil {
+			t.Fatalf("insert %s: %v", version.OpID, err)
+		}
+	}
+	for _, op := range []struct {
+		id        string
+		createdAt int64
+	}{{"vote-old", old}, {"vote-new", now}} {
+		if _, err := app.db.Exec(`INSERT INTO vote_ops (op_id, created_at) VALUES (?, ?);`, op.id, op.createdAt); err != nil {
+			t.Fatalf("insert vote op: %v", err)
+		}
+	}
+	for _, record := range []FavoriteOpRecord{
+		{OpID: "fav-1", Pubkey: "alice", PostID: "p-1", Op: "ADD", CreatedAt: old},
+		{OpID: "fav-2", Pubkey: "alice", PostID: "p-1", Op: "REMOVE", CreatedAt: old + 60},
+	} {
+		if _, err := app.applyFavoriteOperation(record, false); err != nil {
+			t.Fatalf("apply favorite %s: %v", record.OpID, err)
+		}
+	}
+	for _, action := range []struct {
+		action    string
+		timestamp int64
+	}{{"SHADOW_BAN", old}, {"UNBAN", old + 60}, {"SHADOW_BAN", old - 60}} {
+		if err := app.upsertModeration("mallory", action.action, "admin", action.timestamp, 0, "", ""); err != nil {
+			t.Fatalf("moderate: %v", err)
+		}
+	}
+
+	// Reconcile leaves out what compaction is about to drop, so peers do not
+	// offer it back afterwards.
+	favorites, err := app.loadFavoriteReconcileSet("alice")
+	if err != nil || len(favorites.items) != 1 || favorites.items[0].ID != "fav-2" {
+		t.Fatalf("unexpected favorite reconcile set %+v, err %v", favorites, err)
+	}
+	moderation, err := app.loadModerationReconcileSet()
+	if err != nil || len(moderation.items) != 2 {
+		t.Fatalf("expected the state and the applied unban log only, got %+v, err %v", moderation, err)
+	}
+
+	// Start from a database created before incremental auto-vacuum.
+	for _, statement := range []string{`PRAGMA auto_vacuum = NONE;`, `VACUUM;`} {
+		if _, err := app.db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	run, err := app.RunMaintenanceNow()
+	if err != nil {
+		t.Fatalf("run maintenance: %v", err)
+	}
+	want := OpCompactionResult{EntityOps: 1, VoteOps: 1, FavoriteOps: 1, ModerationLogs: 2}
+	if run.Compaction != want || len(run.Errors) != 0 || !run.VacuumConverted || !run.Analyzed {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM entity_ops WHERE op_id IN ('op-2', 'op-3', 'op-4');`); count != 3 {
+		t.Fatalf("expected current and recent entity ops to stay, got %d", count)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM moderation_logs WHERE action = 'UNBAN';`); count != 1 {
+		t.Fatalf("expected the applied unban log to stay, got %d", count)
+	}
+	var mode int
+	if err = app.db.QueryRow(`PRAGMA auto_vacuum;`).Scan(&mode); err != nil || mode != sqliteAutoVacuumIncremental {
+		t.Fatalf("expected incremental auto-vacuum, got %d, err %v", mode, err)
+	}
+
+	again, err := app.RunMaintenanceNow()
+	if err != nil || again.Compaction != (OpCompactionResult{}) || again.VacuumConverted {
+		t.Fatalf("unexpected second run %+v, err %v", again, err)
+	}
+	runs, err := app.GetMaintenanceRuns(0)
+	if err != nil || len(runs) != 2 || runs[0].ID != again.ID || runs[1].Compaction != want || runs[1].Trigger != maintenanceTriggerManual {
+		t.Fatalf("unexpected recorded runs %+v, err %v", runs, err)
+	}
+}
+
+func TestVoteReplayAfterCompactionIsDropped(t *testing.T) {
+	app := newTestApp(t)
+	now := time.Now().Unix()
+	if _, err := app.insertMessage(ForumMessage{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Plan", Body: "one", Timestamp: now, Lamport: 1, Zone: "public", SubID: defaultSubID}); err != nil {
+		t.Fatalf("insert post: %v", err)
+	}
+	score := func() int {
+		return countRows(t, app, `SELECT score FROM messages WHERE id = 'p-1';`)
+	}
+
+	// bob upvotes, then clears the vote; an earlier op that arrives late is
+	// stale.
+	for _, op := range []struct {
+		id      string
+		state   string
+		votedAt int64
+	}{{"v-up", voteStateUp, now - 20}, {"v-clear", voteStateNone, now - 10}, {"v-late", voteStateDown, now - 15}} {
+		if err := app.applyPostVoteState("bob", "p-1", op.state, op.id, op.votedAt); err != nil {
+			t.Fatalf("apply %s: %v", op.id, err)
+		}
+	}
+	if got := score(); got != 0 {
+		t.Fatalf("expected the cleared vote to stand, got score %d", got)
+	}
+
+	// With every vote op ID compacted away, replaying the upvote changes
+	// nothing, and neither does an op from before the horizon.
+	if _, err := app.compactOpLogs(now + 1); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM vote_ops;`); count != 0 {
+		t.Fatalf("expected vote ops to be compacted, got %d", count)
+	}
+	if err := app.applyPostVoteState("bob", "p-1", voteStateUp, "v-up", now-20); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if err := app.applyPostVoteState("carol", "p-1", voteStateUp, "v-ancient", maintenanceOpCutoff(time.Now())-60); err != nil {
+		t.Fatalf("apply ancient vote: %v", err)
+	}
+	if got := score(); got != 0 {
+		t.Fatalf("expected replayed and expired votes to be dropped, got score %d", got)
+	}
+
+	// A newer op still applies.
+	if err := app.applyPostVoteState("bob", "p-1", voteStateUp, "v-again", now); err != nil {
+		t.Fatalf("apply new vote: %v", err)
+	}
+	if got := score(); got != 1 {
+		t.Fatalf("expected the new upvote to count, got score %d", got)
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+const messageSignatureDomain = "aegis-msg-v1"
+
+var (
+	errMessageSignatureRequired = errors.New("message signature is required")
+	errMessageSignatureInvalid  = errors.New("invalid message signature")
+	errMessageSignerMismatch    = errors.New("message signer does not match local identity")
+)
+
+// messageSigningEnvelope is the canonical, type-scoped view of an IncomingMessage
+// that gets signed. Only fields that define the operation are copied in, so the
+// same bytes can be rebuilt from a sync digest. Post bodies are bound through
+// their content CID; display hints riding on posts/comments are not signed.
+type messageSigningEnvelope struct {
+	Type             string `json:"type"`
+	OpType           string `json:"op_type,omitempty"`
+	OpID             string `json:"op_id,omitempty"`
+	ID               string `json:"id,omitempty"`
+	Pubkey           string `json:"pubkey,omitempty"`
+	VoterPubkey      string `json:"voter_pubkey,omitempty"`
+	VoteState        string `json:"vote_state,omitempty"`
+	PostID           string `json:"post_id,omitempty"`
+	CommentID        string `json:"comment_id,omitempty"`
+	ParentID         string `json:"parent_id,omitempty"`
+	DisplayName      string `json:"display_name,omitempty"`
+	AvatarURL        string `json:"avatar_url,omitempty"`
+	Title            string `json:"title,omitempty"`
+	Body             string `json:"body,omitempty"`
+	AttachmentsJSON  string `json:"attachments,omitempty"`
+	ContentCID       string `json:"content_cid,omitempty"`
+	ImageCID         string `json:"image_cid,omitempty"`
+	ThumbCID         string `json:"thumb_cid,omitempty"`
+	ImageMIME        string `json:"image_mime,omitempty"`
+	ImageSize        int64  `json:"image_size,omitempty"`
+	ImageWidth       int    `json:"image_width,omitempty"`
+	ImageHeight      int    `json:"image_height,omitempty"`
+	SubID            string `json:"sub_id,omitempty"`
+	SubTitle         string `json:"sub_title,omitempty"`
+	SubDesc          string `json:"sub_desc,omitempty"`
+	TargetPubkey     string `json:"target_pubkey,omitempty"`
+	AdminPubkey      string `json:"admin_pubkey,omitempty"`
+	AdminRole        string `json:"admin_role,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+	HideHistory      *bool  `json:"hide_history_on_shadowban,omitempty"`
+	Timestamp        int64  `json:"timestamp"`
+	Lamport          int64  `json:"lamport,omitempty"`
+	DeletedAtLamport int64  `json:"deleted_at_lamport,omitempty"`
+}
+
+// isSignedMessageType reports whether a message type carries an authored
+// operation. Fetch/sync request and response frames are transport only.
+func isSignedMessageType(messageType string) bool {
+	switch strings.ToUpper(strings.TrimSpace(messageType)) {
+	case "POST", "COMMENT", "POST_DELETE", "COMMENT_DELETE",
+		"POST_UPVOTE", "POST_DOWNVOTE", "POST_VOTE_SET",
+		"COMMENT_UPVOTE", "COMMENT_DOWNVOTE", "COMMENT_VOTE_SET",
+		"PROFILE_UPDATE", "SUB_CREATE", messageTypeFavoriteOp,
+		"SHADOW_BAN", "UNBAN", "GOVERNANCE_POLICY_UPDATE", messageTypeAdminDelegation:
+		return true
+	default:
+		return false
+	}
+}
+
+func resolveAllowUnsignedMessages() bool {
+	raw := strings.TrimSpace(strings.ToLower(os.Getenv("AEGIS_ALLOW_UNSIGNED_MESSAGES")))
+	switch raw {
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+func incomingMessageSigner(message IncomingMessage) string {
+	switch strings.ToUpper(strings.TrimSpace(message.Type)) {
+	case "POST_UPVOTE", "POST_DOWNVOTE", "POST_VOTE_SET", "COMMENT_UPVOTE", "COMMENT_DOWNVOTE", "COMMENT_VOTE_SET":
+		if voter := strings.TrimSpace(message.VoterPubkey); voter != "" {
+			return voter
+		}
+		return strings.TrimSpace(message.Pubkey)
+	case "SHADOW_BAN", "UNBAN", "GOVERNANCE_POLICY_UPDATE", messageTypeAdminDelegation:
+		return strings.TrimSpace(message.AdminPubkey)
+	default:
+		return strings.TrimSpace(message.Pubkey)
+	}
+}
+
+func buildIncomingMessageSignaturePayload(message IncomingMessage) (string, error) {
+	messageType := strings.ToUpper(strings.TrimSpace(message.Type))
+	if messageType == messageTypeFavoriteOp {
+		op, err := normalizeFavoriteOperation(message.FavoriteOp)
+		if err != nil {
+			return "", err
+		}
+		return buildFavoriteSignaturePayload(message.Pubkey, message.PostID, op, message.Timestamp, message.FavoriteOpID), nil
+	}
+
+	envelope := messageSigningEnvelope{
+		Type:      messageType,
+		OpID:      strings.TrimSpace(message.OpID),
+		Timestamp: message.Timestamp,
+		Lamport:   message.Lamport,
+	}
+
+	switch messageType {
+	case "POST":
+		body := strings.TrimSpace(message.Body)
+		if body == "" {
+			body = strings.TrimSpace(message.Content)
+		}
+		contentCID := strings.TrimSpace(message.ContentCID)
+		if contentCID == "" && body != "" {
+			contentCID = buildContentCID(body)
+		}
+		envelope.ID = strings.TrimSpace(message.ID)
+		envelope.Pubkey = strings.TrimSpace(message.Pubkey)
+		envelope.Title = strings.TrimSpace(message.Title)
+		envelope.ContentCID = contentCID
+		envelope.ImageCID = strings.TrimSpace(message.ImageCID)
+		envelope.ThumbCID = strings.TrimSpace(message.ThumbCID)
+		envelope.ImageMIME = strings.TrimSpace(message.ImageMIME)
+		envelope.ImageSize = message.ImageSize
+		envelope.ImageWidth = message.ImageWidth
+		envelope.ImageHeight = message.ImageHeight
+		envelope.SubID = normalizeSubID(message.SubID)
+	case "COMMENT":
+		attachmentsJSON, err := encodeCommentAttachmentsJSON(normalizeCommentAttachments(message.CommentAttachments))
+		if err != nil {
+			return "", err
+		}
+		envelope.ID = strings.TrimSpace(message.ID)
+		envelope.Pubkey = strings.TrimSpace(message.Pubkey)
+		envelope.PostID = strings.TrimSpace(message.PostID)
+		envelope.ParentID = strings.TrimSpace(message.ParentID)
+		envelope.Body = strings.TrimSpace(message.Body)
+		envelope.AttachmentsJSON = attachmentsJSON
+	case "POST_DELETE":
+		envelope.Pubkey = strings.TrimSpace(message.Pubkey)
+		envelope.PostID = strings.TrimSpace(message.PostID)
+		envelope.DeletedAtLamport = message.DeletedAtLamport
+	case "COMMENT_DELETE":
+		envelope.Pubkey = strings.TrimSpace(message.Pubkey)
+		envelope.PostID = strings.TrimSpace(message.PostID)
+		envelope.CommentID = strings.TrimSpace(message.CommentID)
+		envelope.DeletedAtLamport = message.DeletedAtLamport
+	case "POST_UPVOTE", "POST_DOWNVOTE", "POST_VOTE_SET", "COMMENT_UPVOTE", "COMMENT_DOWNVOTE", "COMMENT_VOTE_SET":
+		envelope.Pubkey = strings.TrimSpace(message.Pubkey)
+		envelope.VoterPubkey = incomingMessageSigner(message)
+		envelope.PostID = strings.TrimSpace(message.PostID)
+		envelope.CommentID = strings.TrimSpace(message.CommentID)
+		envelope.VoteState = strings.TrimSpace(message.VoteState)
+	case "PROFILE_UPDATE":
+		envelope.Pubkey = strings.TrimSpace(message.Pubkey)
+		envelope.DisplayName = strings.TrimSpace(message.DisplayName)
+		envelope.AvatarURL = strings.TrimSpace(message.AvatarURL)
+	case "SUB_CREATE":
+		envelope.Pubkey = strings.TrimSpace(message.Pubkey)
+		envelope.SubID = normalizeSubID(message.SubID)
+		envelope.SubTitle = strings.TrimSpace(message.SubTitle)
+		envelope.SubDesc = strings.TrimSpace(message.SubDesc)
+	case "SHADOW_BAN", "UNBAN":
+		envelope.AdminPubkey = strings.TrimSpace(message.AdminPubkey)
+		envelope.TargetPubkey = strings.TrimSpace(message.TargetPubkey)
+		envelope.Reason = strings.TrimSpace(message.Reason)
+	case "GOVERNANCE_POLICY_UPDATE":
+		hideHistory := message.HideHistoryOnShadowBan
+		envelope.AdminPubkey = strings.TrimSpace(message.AdminPubkey)
+		envelope.HideHistory = &hideHistory
+	case messageTypeAdminDelegation:
+		envelope.OpType = strings.ToUpper(strings.TrimSpace(message.OpType))
+		envelope.AdminPubkey = strings.TrimSpace(message.AdminPubkey)
+		envelope.TargetPubkey = strings.TrimSpace(message.TargetPubkey)
+		envelope.AdminRole = strings.TrimSpace(strings.ToLower(message.AdminRole))
+	default:
+		return "", errors.New("unsupported signed message type")
+	}
+
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return messageSignatureDomain + "|" + string(raw), nil
+}
+
+// signIncomingMessage fills message.Signature using the local identity. The
+// message signer (author, voter or admin) must be the local identity.
+func (a *App) signIncomingMessage(message *IncomingMessage) error {
+	if message == nil {
+		return errors.New("message is required")
+	}
+	if !isSignedMessageType(message.Type) {
+		return nil
+	}
+
+	identity, err := a.getLocalIdentity()
+	if err != nil {
+		return err
+	}
+	signer := incomingMessageSigner(*message)
+	if signer == "" || signer != strings.TrimSpace(identity.PublicKey) {
+		return errMessageSignerMismatch
+	}
+
+	payload, err := buildIncomingMessageSignaturePayload(*message)
+	if err != nil {
+		return err
+	}
+	signature, err := a.signWithLocalIdentity(signer, payload)
+	if err != nil {
+		return err
+	}
+	message.Signature = signature
+	return nil
+}
+
+func (a *App) verifyIncomingMessageSignature(message IncomingMessage) error {
+	if !isSignedMessageType(message.Type) {
+		return nil
+	}
+
+	signature := strings.TrimSpace(message.Signature)
+	if signature == "" {
+		if resolveAllowUnsignedMessages() {
+			return nil
+		}
+		return errMessageSignatureRequired
+	}
+
+	signer := incomingMessageSigner(message)
+	if signer == "" {
+		return errMessageSignatureInvalid
+	}
+	payload, err := buildIncomingMessageSignaturePayload(message)
+	if err != nil {
+		return err
+	}
+	valid, err := a.VerifyMessage(signer, payload, signature)
+	if err != nil || !valid {
+		return errMessageSignatureInvalid
+	}
+	return nil
+}
+
+// postDigestSigningMessage rebuilds the signed message a post digest was
+// derived from, so the digest signature can be checked without the body.
+func postDigestSigningMessage(digest SyncPostDigest) IncomingMessage {
+	if digest.Deleted || normalizeOperationType(digest.OpType, postOpTypeCreate) == postOpTypeDelete {
+		return IncomingMessage{
+			Type:             "POST_DELETE",
+			OpID:             digest.OpID,
+			Pubkey:           digest.Pubkey,
+			PostID:           digest.ID,
+			Timestamp:        digest.Timestamp,
+			Lamport:          digest.Lamport,
+			DeletedAtLamport: digest.DeletedAtLamport,
+			Signature:        digest.Signature,
+		}
+	}
+
+	return IncomingMessage{
+		Type:        "POST",
+		OpID:        digest.OpID,
+		ID:          digest.ID,
+		Pubkey:      digest.Pubkey,
+		Title:       digest.Title,
+		ContentCID:  digest.ContentCID,
+		ImageCID:    digest.ImageCID,
+		ThumbCID:    digest.ThumbCID,
+		ImageMIME:   digest.ImageMIME,
+		ImageSize:   digest.ImageSize,
+		ImageWidth:  digest.ImageWidth,
+		ImageHeight: digest.ImageHeight,
+		SubID:       digest.SubID,
+		Timestamp:   digest.Timestamp,
+		Lamport:     digest.Lamport,
+		Signature:   digest.Signature,
+	}
+}
+
+func commentDigestSigningMessage(digest SyncCommentDigest) IncomingMessage {
+	if digest.Deleted || normalizeOperationType(digest.OpType, postOpTypeCreate) == postOpTypeDelete {
+		return IncomingMessage{
+			Type:             "COMMENT_DELETE",
+			OpID:             digest.OpID,
+			Pubkey:           digest.Pubkey,
+			PostID:           digest.PostID,
+			CommentID:        digest.ID,
+			Timestamp:        digest.Timestamp,
+			Lamport:          digest.Lamport,
+			DeletedAtLamport: digest.DeletedAtLamport,
+			Signature:        digest.Signature,
+		}
+	}
+
+	return IncomingMessage{
+		Type:               "COMMENT",
+		OpID:               digest.OpID,
+		ID:                 digest.ID,
+		Pubkey:             digest.Pubkey,
+		PostID:             digest.PostID,
+		ParentID:           digest.ParentID,
+		Body:               digest.Body,
+		CommentAttachments: digest.Attachments,
+		Timestamp:          digest.Timestamp,
+		Lamport:            digest.Lamport,
+		Signature:          digest.Signature,
+	}
+}
+
+// moderationSigningMessage rebuilds the SHADOW_BAN/UNBAN message a stored
+// moderation row came from, so replayed governance state can be verified.
+func moderationSigningMessage(action string, targetPubkey string, sourceAdmin string, timestamp int64, lamport int64, reason string, signature string) IncomingMessage {
+	return IncomingMessage{
+		Type:         strings.ToUpper(strings.TrimSpace(action)),
+		TargetPubkey: targetPubkey,
+		AdminPubkey:  sourceAdmin,
+		Reason:       reason,
+		Timestamp:    timestamp,
+		Lamport:      lamport,
+		Signature:    signature,
+	}
+}
+
+func (a *App) verifyModerationStateSignature(state ModerationState) error {
+	return a.verifyIncomingMessageSignature(moderationSigningMessage(state.Action, state.TargetPubkey, state.SourceAdmin, state.Timestamp, state.Lamport, state.Reason, state.Signature))
+}
+
+func (a *App) verifyModerationLogSignature(log ModerationLog) error {
+	return a.verifyIncomingMessageSignature(moderationSigningMessage(log.Action, log.TargetPubkey, log.SourceAdmin, log.Timestamp, log.Lamport, log.Reason, log.Signature))
+}
+
+// signLocalModeration signs a moderation action applied directly on this node
+// when the acting admin is the local identity. Actions taken on behalf of
+// another admin stay unsigned and are rejected by peers on replay.
+func (a *App) signLocalModeration(action string, targetPubkey string, adminPubkey string, timestamp int64, lamport int64, reason string) string {
+	message := moderationSigningMessage(action, strings.TrimSpace(targetPubkey), strings.TrimSpace(adminPubkey), timestamp, lamport, strings.TrimSpace(reason), "")
+	if err := a.signIncomingMessage(&message); err != nil {
+		return ""
+	}
+	return message.Signature
+}
+
+func (a *App) verifyPostDigestSignature(digest SyncPostDigest) error {
+	return a.verifyIncomingMessageSignature(postDigestSigningMessage(digest))
+}
+
+func (a *App) verifyCommentDigestSignature(digest SyncCommentDigest) error {
+	return a.verifyIncomingMessageSignature(commentDigestSigningMessage(digest))
+}
+
+// recordIncomingMessageSignature keeps the signature of a post/comment
+// operation so it can be replayed inside sync digests.
+func (a *App) recordIncomingMessageSignature(message IncomingMessage) error {
+	if a.db == nil {
+		return errors.New("database not initialized")
+	}
+
+	signature := strings.TrimSpace(message.Signature)
+	opID := strings.TrimSpace(message.OpID)
+	if signature == "" || opID == "" {
+		return nil
+	}
+
+	entityType := ""
+	entityID := ""
+	switch strings.ToUpper(strings.TrimSpace(message.Type)) {
+	case "POST":
+		entityType, entityID = entityTypePost, strings.TrimSpace(message.ID)
+	case "POST_DELETE":
+		entityType, entityID = entityTypePost, strings.TrimSpace(message.PostID)
+	case "COMMENT":
+		entityType, entityID = entityTypeComment, strings.TrimSpace(message.ID)
+	case "COMMENT_DELETE":
+		entityType, entityID = entityTypeComment, strings.TrimSpace(message.CommentID)
+	default:
+		return nil
+	}
+	if entityID == "" {
+		return nil
+	}
+
+	_, err := a.db.Exec(`
+		INSERT INTO entity_signatures (op_id, entity_type, entity_id, signer_pubkey, signature, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(op_id) DO NOTHING;
+	`, opID, entityType, entityID, incomingMessageSigner(message), signature, time.Now().Unix())
+	return err
+}
+
+func (a *App) lookupEntitySignatures(opIDs []string) (map[string]string, error) {
+	result := make(map[string]string, len(opIDs))
+	if a.db == nil {
+		return result, errors.New("database not initialized")
+	}
+
+	args := make([]interface{}, 0, len(opIDs))
+	for _, opID := range opIDs {
+		opID = strings.TrimSpace(opID)
+		if opID == "" {
+			continue
+		}
+		args = append(args, opID)
+	}
+	if len(args) == 0 {
+		return result, nil
+	}
+
+	rows, err := a.db.Query(`SELECT op_id, signature FROM entity_signatures WHERE op_id IN (`+makeSQLPlaceholders(len(args))+`);`, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var opID, signature string
+		if err = rows.Scan(&opID, &signature); err != nil {
+			return result, err
+		}
+		result[opID] = signature
+	}
+	return result, rows.Err()
+}
+
+func (a *App) attachPostDigestSignatures(digests []SyncPostDigest) error {
+	opIDs := make([]string, 0, len(digests))
+	for _, digest := range digests {
+		opIDs = append(opIDs, digest.OpID)
+	}
+	signatures, err := a.lookupEntitySignatures(opIDs)
+	if err != nil {
+		return err
+	}
+	for i := range digests {
+		digests[i].Signature = signatures[strings.TrimSpace(digests[i].OpID)]
+	}
+	return nil
+}
+
+func (a *App) attachCommentDigestSignatures(digests []SyncCommentDigest) error {
+	opIDs := make([]string, 0, len(digests))
+	for _, digest := range digests {
+		opIDs = append(opIDs, digest.OpID)
+	}
+	signatures, err := a.lookupEntitySignatures(opIDs)
+	if err != nil {
+		return err
+	}
+	for i := range digests {
+		digests[i].Signature = signatures[strings.TrimSpace(digests[i].OpID)]
+	}
+	return nil
+}
+
+// signLocalEntityMessage signs a locally authored post/comment operation and
+// records the signature so later sync digests for it verify on other nodes.
+func (a *App) signLocalEntityMessage(message *IncomingMessage) error {
+	if err := a.signIncomingMessage(message); err != nil {
+		return err
+	}
+	return a.recordIncomingMessageSignature(*message)
+}
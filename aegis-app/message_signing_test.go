@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestIncomingMessageSignatureRoundTrip(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "0")
+	app := newTestApp(t)
+	identity, err := app.GenerateIdentity("correct horse battery")
+	if err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+
+	message := IncomingMessage{Type: "POST", OpID: "op-1", ID: "p-1", Pubkey: identity.PublicKey, Title: "Hello", Body: "signed body", SubID: defaultSubID, Timestamp: 100, Lamport: 7}
+	if err = app.signIncomingMessage(&message); err != nil || message.Signature == "" {
+		t.Fatalf("sign: %v", err)
+	}
+	if err = app.verifyIncomingMessageSignature(message); err != nil {
+		t.Fatalf("verify signed message: %v", err)
+	}
+
+	tampered := message
+	tampered.Title = "Hello, edited"
+	if err = app.verifyIncomingMessageSignature(tampered); !errors.Is(err, errMessageSignatureInvalid) {
+		t.Fatalf("expected tampered title to fail, got %v", err)
+	}
+	tampered = message
+	tampered.Body = "other body"
+	if err = app.verifyIncomingMessageSignature(tampered); !errors.Is(err, errMessageSignatureInvalid) {
+		t.Fatalf("expected tampered body to fail, got %v", err)
+	}
+
+	// The signature does not carry over to another claimed author, and the
+	// node refuses to sign for one.
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate second key: %v", err)
+	}
+	reattributed := message
+	reattributed.Pubkey = hex.EncodeToString(otherKey)
+	if err = app.verifyIncomingMessageSignature(reattributed); !errors.Is(err, errMessageSignatureInvalid) {
+		t.Fatalf("expected reattributed message to fail, got %v", err)
+	}
+	foreign := IncomingMessage{Type: "POST", OpID: "op-2", ID: "p-2", Pubkey: hex.EncodeToString(otherKey), Title: "Hi", Body: "x", Timestamp: 101}
+	if err = app.signIncomingMessage(&foreign); !errors.Is(err, errMessageSignerMismatch) {
+		t.Fatalf("expected signer mismatch, got %v", err)
+	}
+
+	unsigned := message
+	unsigned.Signature = ""
+	if err = app.verifyIncomingMessageSignature(unsigned); !errors.Is(err, errMessageSignatureRequired) {
+		t.Fatalf("expected unsigned message to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyPostDigestSignature(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "0")
+	app := newTestApp(t)
+	identity, err := app.GenerateIdentity("correct horse battery")
+	if err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+
+	message := IncomingMessage{Type: "POST", OpID: "op-1", ID: "p-1", Pubkey: identity.PublicKey, Title: "Hello", Body: "signed body", SubID: defaultSubID, Timestamp: 100, Lamport: 7}
+	if err = app.signIncomingMessage(&message); err != nil {
+		t.Fatalf("sign post: %v", err)
+	}
+	digest := SyncPostDigest{ID: "p-1", Pubkey: identity.PublicKey, OpID: "op-1", Title: "Hello", ContentCID: buildContentCID("signed body"), SubID: defaultSubID, Timestamp: 100, Lamport: 7, Signature: message.Signature}
+	if err = app.verifyPostDigestSignature(digest); err != nil {
+		t.Fatalf("verify digest: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*SyncPostDigest){
+		"content cid": func(d *SyncPostDigest) { d.ContentCID = buildContentCID("forged body") },
+		"sub":         func(d *SyncPostDigest) { d.SubID = "other" },
+		"lamport":     func(d *SyncPostDigest) { d.Lamport++ },
+		"as delete":   func(d *SyncPostDigest) { d.Deleted = true },
+		"signature": func(d *SyncPostDigest) {
+			flipped := []byte(d.Signature)
+			flipped[0] ^= 1
+			d.Signature = string(flipped)
+		},
+	} {
+		forged := digest
+		mutate(&forged)
+		if err = app.verifyPostDigestSignature(forged); !errors.Is(err, errMessageSignatureInvalid) {
+			t.Fatalf("%s: expected forged digest to fail, got %v", name, err)
+		}
+	}
+	digest.Signature = ""
+	if err = app.verifyPostDigestSignature(digest); !errors.Is(err, errMessageSignatureRequired) {
+		t.Fatalf("expected unsigned digest to be rejected, got %v", err)
+	}
+
+	// A delete tombstone verifies against its own POST_DELETE signature.
+	deletion := IncomingMessage{Type: "POST_DELETE", OpID: "op-del", Pubkey: identity.PublicKey, PostID: "p-1", Timestamp: 200, Lamport: 9, DeletedAtLamport: 9}
+	if err = app.signIncomingMessage(&deletion); err != nil {
+		t.Fatalf("sign delete: %v", err)
+	}
+	tombstone := SyncPostDigest{ID: "p-1", Pubkey: identity.PublicKey, OpID: "op-del", Deleted: true, DeletedAtLamport: 9, Timestamp: 200, Lamport: 9, Signature: deletion.Signature}
+	if err = app.verifyPostDigestSignature(tombstone); err != nil {
+		t.Fatalf("verify tombstone: %v", err)
+	}
+}
+
+func TestUnsignedOpTypeDoesNotSteerFallbackOpID(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "0")
+	app := newTestApp(t)
+	identity, err := app.GenerateIdentity("correct horse battery")
+	if err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+
+	message := IncomingMessage{Type: "POST", ID: "p-1", Pubkey: identity.PublicKey, Title: "Hello", Body: "signed body", SubID: defaultSubID, Timestamp: 100, Lamport: 7}
+	if err = app.signIncomingMessage(&message); err != nil {
+		t.Fatalf("sign post: %v", err)
+	}
+	// op_type rides outside the signature on posts, so a relay can rewrite it.
+	message.OpType = postOpTypeUpdate
+	if err = app.verifyIncomingMessageSignature(message); err != nil {
+		t.Fatalf("verify relayed post: %v", err)
+	}
+	if err = app.applyIncomingMessage(message); err != nil {
+		t.Fatalf("apply post: %v", err)
+	}
+
+	want := fallbackOperationID("p-1", identity.PublicKey, 7, postOpTypeCreate)
+	if got := countRows(t, app, `SELECT COUNT(1) FROM messages WHERE id = ? AND current_op_id = ?;`, "p-1", want); got != 1 {
+		t.Fatalf("expected fallback op id %q derived from signed fields only", want)
+	}
+}
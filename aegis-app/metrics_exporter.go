@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Node metrics are exported in the Prometheus/OpenMetrics text format. They
+// are read from the same in-memory stats the Wails getters use, at scrape
+// time, so the exporter adds no bookkeeping of its own. /metrics is served by
+// the admin API behind its token, and on AEGIS_METRICS_ADDR without
+// authentication for scrapers that cannot send one.
+const metricsNamespace = "aegis"
+
+// contentFetchLatencyBucketsMs are the upper bounds of the fetch latency
+// histogram, in milliseconds.
+var contentFetchLatencyBucketsMs = []int64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+type appMetricsCollector struct {
+	app *App
+}
+
+func metricDesc(name string, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", name), help, labels, nil)
+}
+
+var (
+	metricContentFetchAttempts = metricDesc("content_fetch_attempts_total", "Content blob fetches started from the network.")
+	metricContentFetchResults  = metricDesc("content_fetch_results_total", "Content blob fetches finished, by result.", "result")
+	metricContentFetchLatency  = metricDesc("content_fetch_latency_seconds", "Latency of content blob fetches from the network.")
+	metricBlobCacheLookups     = metricDesc("blob_cache_lookups_total", "Local blob lookups, by result.", "result")
+	metricIncomingMessages     = metricDesc("incoming_messages_total", "Gossip messages received from peers, by outcome.", "outcome")
+
+	metricSyncRequestsSent      = metricDesc("sync_requests_sent_total", "Window sync requests published.")
+	metricSyncRequestsReceived  = metricDesc("sync_requests_received_total", "Window sync requests received.")
+	metricSyncResponses         = metricDesc("sync_responses_received_total", "Sync responses applied.")
+	metricSyncSummaries         = metricDesc("sync_summaries_received_total", "Post digests received through sync.")
+	metricIndexInsertions       = metricDesc("sync_index_insertions_total", "Post index rows created or updated by sync.")
+	metricSyncBlobFetches       = metricDesc("sync_blob_fetches_total", "Blob fetches started by sync, by result.", "result")
+	metricReconcileSessions     = metricDesc("reconcile_sessions_total", "Completed range reconciliation sessions.")
+	metricReconcileRanges       = metricDesc("reconcile_ranges_total", "Key ranges sent during reconciliation.")
+	metricReconcileItemsFetched = metricDesc("reconcile_items_fetched_total", "Items fetched during reconciliation.")
+	metricLastSync              = metricDesc("sync_last_timestamp_seconds", "Unix time of the last applied sync response.")
+	metricLastReconcile         = metricDesc("reconcile_last_timestamp_seconds", "Unix time of the last completed reconciliation.")
+	metricSyncLag               = metricDesc("sync_lag_seconds", "Observed lag between the newest remote and local post.")
+
+	metricP2PStarted     = metricDesc("p2p_started", "Whether the P2P host is running.")
+	metricConnectedPeers = metricDesc("p2p_connected_peers", "Currently connected peers.")
+	metricSubTopics      = metricDesc("p2p_sub_topics", "Subscribed per-sub gossip topics.")
+	metricPeerPolicy     = metricDesc("peer_policy_entries", "Peers currently blocked, by list.", "list")
+
+	metricActiveAlerts = metricDesc("release_alerts_active", "Active release alerts, by key and level.", "key", "level")
+
+	metricDatabaseBytes = metricDesc("database_size_bytes", "Size of the SQLite database files on disk.")
+	metricStorageUsed   = metricDesc("storage_used_bytes", "Bytes stored against each storage quota, by scope.", "scope")
+	metricStorageQuota  = metricDesc("storage_quota_bytes", "Storage quota, by scope.", "scope")
+)
+
+func (c appMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c appMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	a := c.app
+
+	a.observabilityMu.Lock()
+	observed := a.observabilityStats
+	buckets := append([]uint64(nil), observed.ContentFetchLatencyBuckets...)
+	incoming := make(map[string]int64, len(observed.IncomingMessages))
+	for outcome, count := range observed.IncomingMessages {
+		incoming[outcome] = count
+	}
+	a.observabilityMu.Unlock()
+
+	counter := func(desc *prometheus.Desc, value int64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), labels...)
+	}
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	counter(metricContentFetchAttempts, observed.ContentFetchAttempts)
+	counter(metricContentFetchResults, observed.ContentFetchSuccess, "success")
+	counter(metricContentFetchResults, observed.ContentFetchFailures, "failure")
+	counter(metricBlobCacheLookups, observed.BlobCacheHits, "hit")
+	counter(metricBlobCacheLookups, observed.BlobCacheMisses, "miss")
+	for outcome, count := range incoming {
+		counter(metricIncomingMessages, count, outcome)
+	}
+
+	histogram := make(map[float64]uint64, len(contentFetchLatencyBucketsMs))
+	for index, bound := range contentFetchLatencyBucketsMs {
+		if index < len(buckets) {
+			histogram[float64(bound)/1000] = buckets[index]
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(metricContentFetchLatency, observed.ContentFetchLatencyCount, float64(observed.ContentFetchLatencySumMs)/1000, histogram)
+
+	stats := a.GetAntiEntropyStats()
+	counter(metricSyncRequestsSent, stats.SyncRequestsSent)
+	counter(metricSyncRequestsReceived, stats.SyncRequestsReceived)
+	counter(metricSyncResponses, stats.SyncResponsesReceived)
+	counter(metricSyncSummaries, stats.SyncSummariesReceived)
+	counter(metricIndexInsertions, stats.IndexInsertions)
+	counter(metricSyncBlobFetches, stats.BlobFetchSuccess, "success")
+	counter(metricSyncBlobFetches, stats.BlobFetchFailures, "failure")
+	counter(metricReconcileSessions, stats.ReconcileSessions)
+	counter(metricReconcileRanges, stats.ReconcileRanges)
+	counter(metricReconcileItemsFetched, stats.ReconcileItemsFetched)
+	gauge(metricLastSync, float64(stats.LastSyncAt))
+	gauge(metricLastReconcile, float64(stats.LastReconcileAt))
+	gauge(metricSyncLag, float64(stats.LastObservedSyncLagSec))
+
+	status := a.GetP2PStatus()
+	started := 0.0
+	if status.Started {
+		started = 1
+	}
+	gauge(metricP2PStarted, started)
+	gauge(metricConnectedPeers, float64(len(status.ConnectedPeers)))
+	gauge(metricSubTopics, float64(len(status.SubTopics)))
+	greylisted, blacklisted := a.peerPolicySizes()
+	gauge(metricPeerPolicy, float64(greylisted), "greylist")
+	gauge(metricPeerPolicy, float64(blacklisted), "blacklist")
+
+	for _, alert := range a.GetReleaseAlerts() {
+		gauge(metricActiveAlerts, 1, alert.Key, alert.Level)
+	}
+
+	if size, ok := a.databaseSizeBytes(); ok {
+		gauge(metricDatabaseBytes, float64(size))
+	}
+	if usage, err := a.GetStorageUsage(); err == nil {
+		gauge(metricStorageUsed, float64(usage.PrivateUsedBytes), "private")
+		gauge(metricStorageUsed, float64(usage.PublicUsedBytes), "public")
+		gauge(metricStorageUsed, float64(usage.MediaUsedBytes), "media")
+		gauge(metricStorageUsed, float64(usage.PinnedUsedBytes), "pinned")
+		gauge(metricStorageUsed, float64(usage.TotalUsedBytes), "total")
+		gauge(metricStorageQuota, float64(usage.PrivateQuota), "private")
+		gauge(metricStorageQuota, float64(usage.PublicQuota), "public")
+		gauge(metricStorageQuota, float64(usage.MediaQuota), "media")
+		gauge(metricStorageQuota, float64(usage.PinnedQuota), "pinned")
+		gauge(metricStorageQuota, float64(usage.TotalQuota), "total")
+	}
+}
+
+// peerPolicySizes counts unexpired greylist entries and blacklist entries.
+func (a *App) peerPolicySizes() (int, int) {
+	now := time.Now().Unix()
+	a.peerPolicyMu.Lock()
+	defer a.peerPolicyMu.Unlock()
+
+	greylisted := 0
+	for _, until := range a.peerGreylist {
+		if until > now {
+			greylisted++
+		}
+	}
+	return greylisted, len(a.peerBlacklist)
+}
+
+func (a *App) databaseSizeBytes() (int64, bool) {
+	if strings.TrimSpace(a.dbPath) == "" {
+		return 0, false
+	}
+	info, err := os.Stat(a.dbPath)
+	if err != nil {
+		return 0, false
+	}
+	total := info.Size()
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if extra, statErr := os.Stat(a.dbPath + suffix); statErr == nil {
+			total += extra.Size()
+		}
+	}
+	return total, true
+}
+
+func (a *App) metricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		appMetricsCollector{app: a},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// startMetricsExporter serves /metrics on AEGIS_METRICS_ADDR when it is set
+// and returns the address it listens on, or "" when it is disabled.
+func (a *App) startMetricsExporter() (string, error) {
+	addr := strings.TrimSpace(os.Getenv("AEGIS_METRICS_ADDR"))
+	if addr == "" {
+		return "", nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", a.metricsHandler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	a.adminAPIMu.Lock()
+	if a.metricsServer != nil {
+		a.adminAPIMu.Unlock()
+		_ = listener.Close()
+		return "", errors.New("metrics exporter already started")
+	}
+	a.metricsServer = server
+	a.adminAPIMu.Unlock()
+
+	go func() {
+		if serveErr := server.Serve(listener); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) && a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "metrics.serve failed err=%v", serveErr)
+		}
+	}()
+
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "metrics.started addr=%s", listener.Addr().String())
+	}
+	return listener.Addr().String(), nil
+}
+
+func (a *App) stopMetricsExporter() error {
+	a.adminAPIMu.Lock()
+	server := a.metricsServer
+	a.metricsServer = nil
+	a.adminAPIMu.Unlock()
+	if server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandlerExportsNodeMetrics(t *testing.T) {
+	app := newTestApp(t)
+	app.noteContentFetchResult(true, 120*time.Millisecond)
+	app.noteContentFetchResult(false, 3*time.Second)
+	app.noteIncomingMessage("accepted")
+	app.noteIncomingMessage("rate_limited")
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	recorder := httptest.NewRecorder()
+	app.metricsHandler().ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`aegis_content_fetch_latency_seconds_bucket{le="0.25"} 1`,
+		`aegis_content_fetch_latency_seconds_bucket{le="5"} 2`,
+		`aegis_content_fetch_latency_seconds_count 2`,
+		`aegis_incoming_messages_total{outcome="rate_limited"} 1`,
+		`aegis_storage_quota_bytes{scope="total"}`,
+		`aegis_database_size_bytes`,
+		`aegis_p2p_connected_peers 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alert metrics are sampled into metric_history every alert evaluation and
+// pre-aggregated into coarser tiers as they are written: each row keeps the
+// count, sum, min and max of the samples in its bucket, so queries can merge
+// buckets without losing extremes. Finer tiers are pruned sooner. Alert
+// fire/resolve transitions are appended to release_alert_events.
+const (
+	defaultMetricHistoryRetentionDays = 180
+	metricHistoryPruneInterval        = time.Hour
+	maxMetricHistoryPoints            = 720
+	defaultAlertHistoryLimit          = 200
+	maxAlertHistoryLimit              = 2000
+
+	releaseAlertEventFired    = "fired"
+	releaseAlertEventResolved = "resolved"
+)
+
+var (
+	errUnknownHistoryMetric   = errors.New("invalid metric name")
+	errInvalidHistoryTimeSpan = errors.New("invalid time range")
+)
+
+type metricHistoryTier struct {
+	ResolutionSec int64
+	RetentionSec  int64
+}
+
+type MetricHistoryPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Samples   int64   `json:"samples"`
+}
+
+type ReleaseAlertEvent struct {
+	ID        int64   `json:"id"`
+	Key       string  `json:"key"`
+	Metric    string  `json:"metric"`
+	Level     string  `json:"level"`
+	Event     string  `json:"event"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	WindowSec int64   `json:"windowSec"`
+	At        int64   `json:"at"`
+}
+
+// metricHistoryTiers returns the storage tiers from finest to coarsest. Raw
+// samples are kept for a day and 5-minute buckets for two weeks, both capped
+// by AEGIS_METRIC_HISTORY_RETENTION_DAYS, which also bounds hourly buckets
+// and alert events.
+func metricHistoryTiers() []metricHistoryTier {
+	retention := resolveMetricHistoryRetention()
+	capped := func(sec int64) int64 {
+		if sec > retention {
+			return retention
+		}
+		return sec
+	}
+	return []metricHistoryTier{
+		{ResolutionSec: 1, RetentionSec: capped(24 * 3600)},
+		{ResolutionSec: 300, RetentionSec: capped(14 * 24 * 3600)},
+		{ResolutionSec: 3600, RetentionSec: retention},
+	}
+}
+
+func resolveMetricHistoryRetention() int64 {
+	days := defaultMetricHistoryRetentionDays
+	raw := strings.TrimSpace(os.Getenv("AEGIS_METRIC_HISTORY_RETENTION_DAYS"))
+	if raw != "" {
+		if value, err := strconv.Atoi(raw); err == nil && value > 0 {
+			days = value
+		}
+	}
+	return int64(days) * 24 * 3600
+}
+
+func (a *App) recordMetricHistoryAt(now int64, snapshot alertMetricSnapshot) error {
+	if a.db == nil {
+		return errors.New("database not initialized")
+	}
+
+	tiers := metricHistoryTiers()
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, name := range alertMetricNames {
+		value, ok := snapshot.Values[name]
+		if !ok {
+			continue
+		}
+		for _, tier := range tiers {
+			bucket := now / tier.ResolutionSec * tier.ResolutionSec
+			if _, err := tx.Exec(`
+				INSERT INTO metric_history (metric, resolution_sec, bucket_ts, sample_count, value_sum, value_min, value_max)
+				VALUES (?, ?, ?, 1, ?, ?, ?)
+				ON CONFLICT(metric, resolution_sec, bucket_ts) DO UPDATE SET
+					sample_count = sample_count + 1,
+					value_sum = value_sum + excluded.value_sum,
+					value_min = MIN(value_min, excluded.value_min),
+					value_max = MAX(value_max, excluded.value_max);
+			`, name, tier.ResolutionSec, bucket, value, value, value); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (a *App) recordReleaseAlertEvents(now int64, event string, alerts []ReleaseAlert) error {
+	if a.db == nil || len(alerts) == 0 {
+		return nil
+	}
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, alert := range alerts {
+		if _, err := tx.Exec(`
+			INSERT INTO release_alert_events (alert_key, metric, level, event, value, threshold, window_sec, at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+		`, alert.Key, alert.Metric, alert.Level, event, alert.Value, alert.Threshold, alert.WindowSec, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// pruneMetricHistoryAt drops buckets and alert events past their tier's
+// retention and returns the number of rows removed.
+func (a *App) pruneMetricHistoryAt(now int64) (int64, error) {
+	if a.db == nil {
+		return 0, errors.New("database not initialized")
+	}
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	var removed int64
+	for _, tier := range metricHistoryTiers() {
+		result, err := a.db.Exec(`DELETE FROM metric_history WHERE resolution_sec = ? AND bucket_ts < ?;`, tier.ResolutionSec, now-tier.RetentionSec)
+		if err != nil {
+			return removed, err
+		}
+		if affected, err := result.RowsAffected(); err == nil {
+			removed += affected
+		}
+	}
+
+	result, err := a.db.Exec(`DELETE FROM release_alert_events WHERE at < ?;`, now-resolveMetricHistoryRetention())
+	if err != nil {
+		return removed, err
+	}
+	if affected, err := result.RowsAffected(); err == nil {
+		removed += affected
+	}
+	return removed, nil
+}
+
+// GetMetricHistory returns metric samples between from and to (unix seconds,
+// inclusive) merged into step-second buckets. Value is the mean of the
+// samples in the bucket. It reads the coarsest tier that still covers from
+// and evenly divides step, rounding step up to that tier's resolution when
+// only a coarse tier reaches back far enough. step <= 0 picks one that yields
+// at most 720 points. to <= 0 means now, and from <= 0 means one hour before
+// to.
+func (a *App) GetMetricHistory(metric string, from int64, to int64, step int64) ([]MetricHistoryPoint, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	metric = strings.TrimSpace(metric)
+	if !isAlertMetricName(metric) {
+		return nil, errUnknownHistoryMetric
+	}
+
+	now := time.Now().Unix()
+	if to <= 0 {
+		to = now
+	}
+	if from <= 0 {
+		from = to - 3600
+	}
+	if from > to {
+		return nil, errInvalidHistoryTimeSpan
+	}
+	if step <= 0 {
+		step = (to - from + maxMetricHistoryPoints - 1) / maxMetricHistoryPoints
+	}
+	if step < 1 {
+		step = 1
+	}
+
+	tiers := metricHistoryTiers()
+	tier := tiers[len(tiers)-1]
+	for index := len(tiers) - 1; index >= 0; index-- {
+		candidate := tiers[index]
+		if from < now-candidate.RetentionSec {
+			continue
+		}
+		tier = candidate
+		if step%candidate.ResolutionSec == 0 {
+			break
+		}
+	}
+	if step%tier.ResolutionSec != 0 {
+		step = (step/tier.ResolutionSec + 1) * tier.ResolutionSec
+	}
+
+	rows, err := a.db.Query(`
+		SELECT (bucket_ts / ?) * ? AS slot, SUM(sample_count), SUM(value_sum), MIN(value_min), MAX(value_max)
+		FROM metric_history
+		WHERE metric = ? AND resolution_sec = ? AND bucket_ts >= ? AND bucket_ts <= ?
+		GROUP BY slot
+		ORDER BY slot ASC;
+	`, step, step, metric, tier.ResolutionSec, from/tier.ResolutionSec*tier.ResolutionSec, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := make([]MetricHistoryPoint, 0)
+	for rows.Next() {
+		var point MetricHistoryPoint
+		var sum float64
+		if err := rows.Scan(&point.Timestamp, &point.Samples, &sum, &point.Min, &point.Max); err != nil {
+			return nil, err
+		}
+		if point.Samples > 0 {
+			point.Value = sum / float64(point.Samples)
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// GetAlertHistory returns alert fire/resolve events between from and to,
+// newest first. to <= 0 means now, and from <= 0 means no lower bound.
+func (a *App) GetAlertHistory(from int64, to int64, limit int) ([]ReleaseAlertEvent, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if to <= 0 {
+		to = time.Now().Unix()
+	}
+	if from > to {
+		return nil, errInvalidHistoryTimeSpan
+	}
+	if limit <= 0 {
+		limit = defaultAlertHistoryLimit
+	}
+	if limit > maxAlertHistoryLimit {
+		limit = maxAlertHistoryLimit
+	}
+
+	rows, err := a.db.Query(`
+		SELECT id, alert_key, metric, level, event, value, threshold, window_sec, at
+		FROM release_alert_events
+		WHERE at >= ? AND at <= ?
+		ORDER BY at DESC, id DESC
+		LIMIT ?;
+	`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]ReleaseAlertEvent, 0)
+	for rows.Next() {
+		var event ReleaseAlertEvent
+		if err := rows.Scan(&event.ID, &event.Key, &event.Metric, &event.Level, &event.Event, &event.Value, &event.Threshold, &event.WindowSec, &event.At); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricHistoryDownsamplesIntoTiers(t *testing.T) {
+	app := newTestApp(t)
+	base := time.Now().Unix() / 3600 * 3600
+
+	for offset := int64(0); offset < 600; offset += 30 {
+		snapshot := releaseAlertSnapshot(ReleaseMetrics{SyncLagSeconds: offset, ContentFetchAttempts: 1, ContentFetchSuccessRate: 1})
+		if err := app.recordMetricHistoryAt(base-3600+offset, snapshot); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	raw, err := app.GetMetricHistory("sync_lag_seconds", base-3600, base, 30)
+	if err != nil {
+		t.Fatalf("raw history: %v", err)
+	}
+	if len(raw) != 20 || raw[0].Value != 0 || raw[19].Value != 570 {
+		t.Fatalf("unexpected raw history %+v", raw)
+	}
+
+	buckets, err := app.GetMetricHistory("sync_lag_seconds", base-3600, base, 300)
+	if err != nil {
+		t.Fatalf("bucketed history: %v", err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("expected 2 five-minute buckets, got %+v", buckets)
+	}
+	first := buckets[0]
+	if first.Samples != 10 || first.Min != 0 || first.Max != 270 || first.Value != 135 {
+		t.Fatalf("unexpected first bucket %+v", first)
+	}
+
+	if got := countRows(t, app, `SELECT COUNT(1) FROM metric_history WHERE metric = 'sync_lag_seconds' AND resolution_sec = 3600`); got != 1 {
+		t.Fatalf("expected one hourly bucket, got %d", got)
+	}
+
+	// Raw samples older than a day are pruned; the coarser tiers keep them.
+	if _, err := app.pruneMetricHistoryAt(base + 2*24*3600); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if got := countRows(t, app, `SELECT COUNT(1) FROM metric_history WHERE resolution_sec = 1`); got != 0 {
+		t.Fatalf("expected raw tier pruned, %d rows left", got)
+	}
+	if got := countRows(t, app, `SELECT COUNT(1) FROM metric_history WHERE resolution_sec = 300 AND metric = 'sync_lag_seconds'`); got != 2 {
+		t.Fatalf("expected five-minute tier kept, got %d rows", got)
+	}
+
+	if _, err := app.GetMetricHistory("no_such_metric", 0, 0, 0); err != errUnknownHistoryMetric {
+		t.Fatalf("expected unknown metric error, got %v", err)
+	}
+}
+
+func TestAlertHistoryRecordsFireAndResolve(t *testing.T) {
+	app := newTestApp(t)
+	now := time.Now().Unix()
+
+	lagging := releaseAlertSnapshot(ReleaseMetrics{SyncLagSeconds: 700})
+	app.evaluateReleaseAlertsAt(now-1000, lagging)
+	app.evaluateReleaseAlertsAt(now-700, lagging)
+	app.evaluateReleaseAlertsAt(now, releaseAlertSnapshot(ReleaseMetrics{}))
+
+	events, err := app.GetAlertHistory(now-3600, now, 0)
+	if err != nil {
+		t.Fatalf("alert history: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected fire and resolve for two rules, got %+v", events)
+	}
+	for _, event := range events[:2] {
+		if event.Event != releaseAlertEventResolved || event.At != now {
+			t.Fatalf("expected resolved events first, got %+v", event)
+		}
+	}
+	for _, event := range events[2:] {
+		if event.Event != releaseAlertEventFired || event.At != now-700 || event.Metric != "sync_lag_seconds" {
+			t.Fatalf("unexpected fired event %+v", event)
+		}
+	}
+}
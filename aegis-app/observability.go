@@ -8,7 +8,13 @@ import (
 	"time"
 )
 
-const maxFetchLatencySamples = 512
+const (
+	maxFetchLatencySamples = 512
+
+	// peer_greylist_growth counts peers newly greylisted within this window.
+	greylistGrowthWindowSec = 600
+	maxGreylistAdditions    = 4096
+)
 
 type ObservabilityStats struct {
 	ContentFetchAttempts int64
@@ -17,6 +23,16 @@ type ObservabilityStats struct {
 	BlobCacheHits        int64
 	BlobCacheMisses      int64
 	ContentFetchLatency  []int64
+
+	// Cumulative histogram of every fetch latency since start, bucketed by
+	// contentFetchLatencyBucketsMs, for the metrics exporter.
+	ContentFetchLatencyBuckets []uint64
+	ContentFetchLatencySumMs   int64
+	ContentFetchLatencyCount   uint64
+
+	IncomingMessages map[string]int64
+
+	GreylistAdditions []int64
 }
 
 type ReleaseMetrics struct {
@@ -69,6 +85,53 @@ func (a *App) noteContentFetchResult(success bool, latency time.Duration) {
 		overflow := len(a.observabilityStats.ContentFetchLatency) - maxFetchLatencySamples
 		a.observabilityStats.ContentFetchLatency = a.observabilityStats.ContentFetchLatency[overflow:]
 	}
+
+	if a.observabilityStats.ContentFetchLatencyBuckets == nil {
+		a.observabilityStats.ContentFetchLatencyBuckets = make([]uint64, len(contentFetchLatencyBucketsMs))
+	}
+	for index, bound := range contentFetchLatencyBucketsMs {
+		if latencyMs <= bound {
+			a.observabilityStats.ContentFetchLatencyBuckets[index]++
+		}
+	}
+	a.observabilityStats.ContentFetchLatencySumMs += latencyMs
+	a.observabilityStats.ContentFetchLatencyCount++
+}
+
+// noteIncomingMessage counts a gossip message by what happened to it:
+// accepted, too_large, rate_limited or blocked.
+func (a *App) noteIncomingMessage(outcome string) {
+	a.observabilityMu.Lock()
+	defer a.observabilityMu.Unlock()
+
+	if a.observabilityStats.IncomingMessages == nil {
+		a.observabilityStats.IncomingMessages = make(map[string]int64)
+	}
+	a.observabilityStats.IncomingMessages[outcome]++
+}
+
+func (a *App) noteGreylistAddition(now int64) {
+	a.observabilityMu.Lock()
+	defer a.observabilityMu.Unlock()
+
+	additions := append(a.observabilityStats.GreylistAdditions, now)
+	if overflow := len(additions) - maxGreylistAdditions; overflow > 0 {
+		additions = additions[overflow:]
+	}
+	a.observabilityStats.GreylistAdditions = additions
+}
+
+func (a *App) recentGreylistAdditions(now int64) int {
+	a.observabilityMu.Lock()
+	defer a.observabilityMu.Unlock()
+
+	additions := a.observabilityStats.GreylistAdditions
+	first := 0
+	for first < len(additions) && additions[first] <= now-greylistGrowthWindowSec {
+		first++
+	}
+	a.observabilityStats.GreylistAdditions = additions[first:]
+	return len(additions) - first
 }
 
 func (a *App) GetReleaseMetrics() ReleaseMetrics {
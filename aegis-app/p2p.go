@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -37,10 +36,15 @@ const (
 	messageTypeGovernanceSyncRequest  = "GOVERNANCE_SYNC_REQUEST"
 	messageTypeGovernanceSyncResponse = "GOVERNANCE_SYNC_RESPONSE"
 	messageTypeFavoriteOp             = "FAVORITE_OP"
+	messageTypeAdminDelegation        = "ADMIN_DELEGATION"
 	messageTypeFavoriteSyncRequest    = "FAVORITE_SYNC_REQUEST"
 	messageTypeFavoriteSyncResponse   = "FAVORITE_SYNC_RESPONSE"
 	messageTypePeerExchangeRequest    = "PEER_EXCHANGE_REQUEST"
 	messageTypePeerExchangeResponse   = "PEER_EXCHANGE_RESPONSE"
+	messageTypeSearchRequest          = "SEARCH_REQUEST"
+	messageTypeSearchResponse         = "SEARCH_RESPONSE"
+	messageTypeRevisionSyncRequest    = "REVISION_SYNC_REQUEST"
+	messageTypeRevisionSyncResponse   = "REVISION_SYNC_RESPONSE"
 )
 
 var (
@@ -68,6 +72,7 @@ type P2PStatus struct {
 	AnnounceAddrs  []string `json:"announceAddrs"`
 	ConnectedPeers []string `json:"connectedPeers"`
 	Topic          string   `json:"topic"`
+	SubTopics      []string `json:"subTopics"`
 }
 
 func (a *App) StartP2P(listenPort int, bootstrapPeers []string) (P2PStatus, error) {
@@ -178,6 +183,9 @@ func (a *App) startP2POnPortLocked(listenPort int, bootstrapPeers []string) (P2P
 	a.p2pCtx = ctx
 	a.p2pCancel = cancel
 	a.p2pHost = host
+	a.p2pPubsub = gossip
+	host.SetStreamHandler(blobProtocolID, a.handleBlobStream)
+	host.SetStreamHandler(reconcileProtocolID, a.handleReconcileStream)
 	a.p2pTopic = topic
 	a.p2pSub = subscription
 
@@ -189,9 +197,11 @@ func (a *App) startP2POnPortLocked(listenPort int, bootstrapPeers []string) (P2P
 	}
 
 	go a.consumeP2PMessages(ctx, host.ID(), subscription)
+	a.joinFollowedSubTopicsLocked()
 	go a.runAntiEntropySyncWorker(ctx, host.ID())
 	go a.runPeerExchangeWorker(ctx, host.ID())
 	go a.runReleaseAlertWorker(ctx)
+	go a.runMaintenanceWorker(ctx)
 
 	knownBootstraps := a.getKnownPeerBootstrapAddresses(knownPeerBootstrapLimit)
 	bootstrapTargets := mergePeerAddressLists(bootstrapPeers, knownBootstraps)
@@ -215,6 +225,9 @@ func (a *App) StopP2P() error {
 	if a.p2pCancel != nil {
 		a.p2pCancel()
 	}
+	if closeErr := a.leaveAllSubTopicsLocked(); closeErr != nil {
+		firstErr = errors.Join(firstErr, closeErr)
+	}
 	if a.p2pSub != nil {
 		a.p2pSub.Cancel()
 	}
@@ -238,6 +251,7 @@ func (a *App) StopP2P() error {
 	a.p2pCancel = nil
 	a.p2pSub = nil
 	a.p2pTopic = nil
+	a.p2pPubsub = nil
 	a.p2pHost = nil
 	a.mdnsSvc = nil
 
@@ -395,9 +409,7 @@ func (a *App) scheduleVoteStateBroadcast(voterPubkey string, postID string, comm
 		delete(a.voteBroadcastSeq, voteKey)
 		a.voteBroadcastMu.Unlock()
 
-		a.p2pMu.Lock()
-		topic := a.p2pTopic
-		a.p2pMu.Unlock()
+		topic, _ := a.topicForPost(pid)
 		if topic == nil {
 			return
 		}
@@ -420,6 +432,12 @@ func (a *App) scheduleVoteStateBroadcast(voterPubkey string, postID string, comm
 				VoteState:   state,
 				Timestamp:   now,
 			}
+			if err = a.signIncomingMessage(&msg); err != nil {
+				if a.ctx != nil {
+					runtime.LogWarningf(a.ctx, "vote state sign failed (post): %v", err)
+				}
+				return
+			}
 			payload, err := json.Marshal(msg)
 			if err != nil {
 				return
@@ -445,6 +463,12 @@ func (a *App) scheduleVoteStateBroadcast(voterPubkey string, postID string, comm
 			VoteState:   state,
 			Timestamp:   now,
 		}
+		if err = a.signIncomingMessage(&msg); err != nil {
+			if a.ctx != nil {
+				runtime.LogWarningf(a.ctx, "vote state sign failed (comment): %v", err)
+			}
+			return
+		}
 		payload, err := json.Marshal(msg)
 		if err != nil {
 			return
@@ -454,9 +478,16 @@ func (a *App) scheduleVoteStateBroadcast(voterPubkey string, postID string, comm
 }
 
 func (a *App) PublishPostStructuredToSub(pubkey string, title string, body string, subID string) error {
+	_, err := a.publishPostStructuredToSub(pubkey, title, body, subID)
+	return err
+}
+
+// publishPostStructuredToSub stores a post locally, broadcasts it on the topic
+// of its sub and returns the stored post.
+func (a *App) publishPostStructuredToSub(pubkey string, title string, body string, subID string) (ForumMessage, error) {
 	pubkey = strings.TrimSpace(pubkey)
 	if pubkey == "" {
-		return errors.New("pubkey is required")
+		return ForumMessage{}, errors.New("pubkey is required")
 	}
 	title = strings.TrimSpace(title)
 	body = strings.TrimSpace(body)
@@ -464,22 +495,17 @@ func (a *App) PublishPostStructuredToSub(pubkey string, title string, body strin
 		title = deriveTitle(body)
 	}
 	if title == "" || body == "" {
-		return errors.New("title and body are required")
+		return ForumMessage{}, errors.New("title and body are required")
 	}
 
 	shadowBanned, err := a.isShadowBanned(pubkey)
 	if err != nil {
-		return err
+		return ForumMessage{}, err
 	}
 	if shadowBanned {
-		_, err = a.AddLocalPostStructuredToSub(pubkey, title, body, "public", subID)
-		return err
+		return a.AddLocalPostStructuredToSub(pubkey, title, body, "public", subID)
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	a.p2pMu.Unlock()
-
 	profile, profileErr := a.GetProfile(pubkey)
 	if profileErr != nil {
 		profile = Profile{}
@@ -487,7 +513,7 @@ func (a *App) PublishPostStructuredToSub(pubkey string, title string, body strin
 
 	localPost, err := a.AddLocalPostStructuredToSub(pubkey, title, body, "public", subID)
 	if err != nil {
-		return err
+		return ForumMessage{}, err
 	}
 
 	msg := IncomingMessage{
@@ -507,16 +533,19 @@ func (a *App) PublishPostStructuredToSub(pubkey string, title string, body strin
 		SubID:         normalizeSubID(subID),
 		Timestamp:     localPost.Timestamp,
 		Lamport:       localPost.Lamport,
-		Signature:     "",
+	}
+	if err = a.signLocalEntityMessage(&msg); err != nil {
+		return ForumMessage{}, err
 	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
-		return err
+		return ForumMessage{}, err
 	}
 
+	topic, _ := a.topicForSub(msg.SubID)
 	a.publishPayloadAsync(topic, payload, "POST")
-	return nil
+	return localPost, nil
 }
 
 func (a *App) PublishPostWithImageToSub(pubkey string, title string, body string, imageBase64 string, imageMIME string, subID string) error {
@@ -542,10 +571,6 @@ func (a *App) PublishPostWithImageToSub(pubkey string, title string, body string
 		return err
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	a.p2pMu.Unlock()
-
 	profile, profileErr := a.GetProfile(pubkey)
 	if profileErr != nil {
 		profile = Profile{}
@@ -579,7 +604,9 @@ func (a *App) PublishPostWithImageToSub(pubkey string, title string, body string
 		SubID:         normalizeSubID(subID),
 		Timestamp:     localPost.Timestamp,
 		Lamport:       localPost.Lamport,
-		Signature:     "",
+	}
+	if err = a.signLocalEntityMessage(&msg); err != nil {
+		return err
 	}
 
 	payload, err := json.Marshal(msg)
@@ -587,6 +614,7 @@ func (a *App) PublishPostWithImageToSub(pubkey string, title string, body string
 		return err
 	}
 
+	topic, _ := a.topicForSub(msg.SubID)
 	a.publishPayloadAsync(topic, payload, "POST_WITH_IMAGE")
 	return nil
 }
@@ -601,14 +629,23 @@ func (a *App) PublishCreateSub(subID string, title string, description string) e
 		return errors.New("sub id is required")
 	}
 
+	identity, err := a.getLocalIdentity()
+	if err != nil {
+		return err
+	}
+
 	now := time.Now().Unix()
 	msg := IncomingMessage{
 		Type:      "SUB_CREATE",
+		Pubkey:    strings.TrimSpace(identity.PublicKey),
 		SubID:     subID,
 		SubTitle:  strings.TrimSpace(title),
 		SubDesc:   strings.TrimSpace(description),
 		Timestamp: now,
 	}
+	if err = a.signIncomingMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
@@ -632,22 +669,28 @@ func (a *App) PublishCreateSub(subID string, title string, description string) e
 }
 
 func (a *App) PublishComment(pubkey string, postID string, parentID string, body string) error {
+	_, err := a.publishComment(pubkey, postID, parentID, body)
+	return err
+}
+
+// publishComment stores a comment locally, broadcasts it on the topic of the
+// post's sub and returns the stored comment.
+func (a *App) publishComment(pubkey string, postID string, parentID string, body string) (Comment, error) {
 	pubkey = strings.TrimSpace(pubkey)
 	postID = strings.TrimSpace(postID)
 	parentID = strings.TrimSpace(parentID)
 	body = strings.TrimSpace(body)
 
 	if pubkey == "" || postID == "" || body == "" {
-		return errors.New("pubkey, post id and body are required")
+		return Comment{}, errors.New("pubkey, post id and body are required")
 	}
 
 	shadowBanned, err := a.isShadowBanned(pubkey)
 	if err != nil {
-		return err
+		return Comment{}, err
 	}
 	if shadowBanned {
-		_, err = a.AddLocalComment(pubkey, postID, parentID, body)
-		return err
+		return a.AddLocalComment(pubkey, postID, parentID, body)
 	}
 
 	profile, profileErr := a.GetProfile(pubkey)
@@ -656,7 +699,7 @@ func (a *App) PublishComment(pubkey string, postID string, parentID string, body
 	}
 	localComment, err := a.AddLocalComment(pubkey, postID, parentID, body)
 	if err != nil {
-		return err
+		return Comment{}, err
 	}
 	msg := IncomingMessage{
 		Type:               "COMMENT",
@@ -675,17 +718,18 @@ func (a *App) PublishComment(pubkey string, postID string, parentID string, body
 		Timestamp:          localComment.Timestamp,
 		Lamport:            localComment.Lamport,
 	}
+	if err = a.signLocalEntityMessage(&msg); err != nil {
+		return Comment{}, err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
-		return err
+		return Comment{}, err
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	a.p2pMu.Unlock()
+	topic, _ := a.topicForPost(postID)
 	a.publishPayloadAsync(topic, payload, "COMMENT")
-	return nil
+	return localComment, nil
 }
 
 func (a *App) PublishCommentWithAttachments(pubkey string, postID string, parentID string, body string, localImageDataURLs []string, externalImageURLs []string) error {
@@ -768,15 +812,16 @@ func (a *App) PublishCommentWithAttachments(pubkey string, postID string, parent
 		Timestamp:          localComment.Timestamp,
 		Lamport:            localComment.Lamport,
 	}
+	if err = a.signLocalEntityMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	a.p2pMu.Unlock()
+	topic, _ := a.topicForPost(postID)
 	a.publishPayloadAsync(topic, payload, "COMMENT_WITH_ATTACHMENTS")
 	return nil
 }
@@ -798,13 +843,6 @@ func (a *App) PublishDeletePost(pubkey string, postID string) error {
 		return err
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	a.p2pMu.Unlock()
-	if topic == nil {
-		return nil
-	}
-
 	msg := IncomingMessage{
 		Type:             "POST_DELETE",
 		OpType:           postOpTypeDelete,
@@ -817,6 +855,15 @@ func (a *App) PublishDeletePost(pubkey string, postID string) error {
 		Lamport:          lamport,
 		DeletedAtLamport: lamport,
 	}
+	if err = a.signLocalEntityMessage(&msg); err != nil {
+		return err
+	}
+
+	topic, _ := a.topicForPost(postID)
+	if topic == nil {
+		return nil
+	}
+
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return err
@@ -843,13 +890,6 @@ func (a *App) PublishDeleteComment(pubkey string, commentID string) error {
 		return err
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	a.p2pMu.Unlock()
-	if topic == nil {
-		return nil
-	}
-
 	msg := IncomingMessage{
 		Type:             "COMMENT_DELETE",
 		OpType:           postOpTypeDelete,
@@ -863,6 +903,15 @@ func (a *App) PublishDeleteComment(pubkey string, commentID string) error {
 		Lamport:          lamport,
 		DeletedAtLamport: lamport,
 	}
+	if err = a.signLocalEntityMessage(&msg); err != nil {
+		return err
+	}
+
+	topic, _ := a.topicForPost(postID)
+	if topic == nil {
+		return nil
+	}
+
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return err
@@ -886,6 +935,9 @@ func (a *App) PublishPostUpvote(pubkey string, postID string) error {
 		PostID:      postID,
 		Timestamp:   time.Now().Unix(),
 	}
+	if err := a.signIncomingMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
@@ -915,6 +967,9 @@ func (a *App) PublishPostDownvote(pubkey string, postID string) error {
 		PostID:      postID,
 		Timestamp:   time.Now().Unix(),
 	}
+	if err := a.signIncomingMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
@@ -946,6 +1001,9 @@ func (a *App) PublishCommentUpvote(pubkey string, postID string, commentID strin
 		CommentID:   commentID,
 		Timestamp:   time.Now().Unix(),
 	}
+	if err := a.signIncomingMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
@@ -977,6 +1035,9 @@ func (a *App) PublishCommentDownvote(pubkey string, postID string, commentID str
 		CommentID:   commentID,
 		Timestamp:   time.Now().Unix(),
 	}
+	if err := a.signIncomingMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
@@ -1042,6 +1103,9 @@ func (a *App) PublishProfileUpdate(pubkey string, displayName string, avatarURL
 		AvatarURL:   strings.TrimSpace(avatarURL),
 		Timestamp:   now,
 	}
+	if err := a.signIncomingMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
@@ -1090,6 +1154,9 @@ func (a *App) publishLocalProfileUpdateLocked() {
 		AvatarURL:   strings.TrimSpace(profile.AvatarURL),
 		Timestamp:   time.Now().Unix(),
 	}
+	if err = a.signIncomingMessage(&msg); err != nil {
+		return
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
@@ -1106,12 +1173,12 @@ func (a *App) PublishGovernancePolicy(hideHistoryOnShadowBan bool) error {
 	}
 
 	adminPubkey := strings.TrimSpace(identity.PublicKey)
-	trusted, err := a.isTrustedAdmin(adminPubkey)
+	root, err := a.isRootAdmin(adminPubkey)
 	if err != nil {
 		return err
 	}
-	if !trusted {
-		return errors.New("admin pubkey is not trusted")
+	if !root {
+		return errGovernancePolicyNotRoot
 	}
 
 	a.p2pMu.Lock()
@@ -1130,6 +1197,9 @@ func (a *App) PublishGovernancePolicy(hideHistoryOnShadowBan bool) error {
 		HideHistoryOnShadowBan: hideHistoryOnShadowBan,
 		Timestamp:              now,
 	}
+	if err = a.signIncomingMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
@@ -1190,8 +1260,11 @@ func (a *App) publishGovernanceMessage(action string, targetPubkey string, admin
 		Lamport:      lamport,
 		Reason:       strings.TrimSpace(reason),
 	}
+	if err = a.signIncomingMessage(&msg); err != nil {
+		return err
+	}
 
-	if err = a.upsertModeration(msg.TargetPubkey, action, msg.AdminPubkey, now, lamport, msg.Reason); err != nil {
+	if err = a.upsertModeration(msg.TargetPubkey, action, msg.AdminPubkey, now, lamport, msg.Reason, msg.Signature); err != nil {
 		return err
 	}
 
@@ -1221,92 +1294,22 @@ func (a *App) fetchContentBlobFromNetwork(contentCID string, timeout time.Durati
 	a.noteContentFetchAttempt()
 
 	_, err, shared := a.contentFetchGroup.Do("cid:"+contentCID, func() (any, error) {
-		a.p2pMu.Lock()
-		topic := a.p2pTopic
-		ctx := a.p2pCtx
-		host := a.p2pHost
-		a.p2pMu.Unlock()
-
-		if topic == nil || ctx == nil || host == nil {
-			return nil, errors.New("p2p not started")
-		}
-		if len(host.Network().Peers()) == 0 {
-			return nil, errContentFetchNoPeers
-		}
-
-		requestID := buildMessageID(host.ID().String(), contentCID, time.Now().UnixNano())
-		responseCh := make(chan IncomingMessage, 1)
-
-		a.p2pMu.Lock()
-		a.contentFetchWaiters[requestID] = responseCh
-		a.p2pMu.Unlock()
-
-		defer func() {
-			a.p2pMu.Lock()
-			delete(a.contentFetchWaiters, requestID)
-			a.p2pMu.Unlock()
-		}()
-
-		request := IncomingMessage{
-			Type:            messageTypeContentFetchRequest,
-			RequestID:       requestID,
-			RequesterPeerID: host.ID().String(),
-			ContentCID:      contentCID,
-			Timestamp:       time.Now().Unix(),
-		}
 		if a.ctx != nil {
 			runtime.LogInfof(
 				a.ctx,
-				"content_fetch.request request_id=%s cid=%s peer_count=%d timeout_ms=%d retry_budget=%d",
-				requestID,
+				"content_fetch.request cid=%s protocol=%s timeout_ms=%d retry_budget=%d",
 				contentCID,
-				len(host.Network().Peers()),
+				blobProtocolID,
 				timeout.Milliseconds(),
 				resolveFetchRetryAttempts()-1,
 			)
 		}
 
-		payload, marshalErr := json.Marshal(request)
-		if marshalErr != nil {
-			return nil, marshalErr
-		}
-
-		if publishErr := topic.Publish(ctx, payload); publishErr != nil {
-			return nil, publishErr
-		}
-
-		deadline := time.Now().Add(timeout)
-		notFoundCount := 0
-		peerCount := len(host.Network().Peers())
-		for {
-			remaining := time.Until(deadline)
-			if remaining <= 0 {
-				if notFoundCount > 0 {
-					return nil, errContentFetchNotFound
-				}
-				return nil, errContentFetchTimeout
-			}
-
-			select {
-			case response := <-responseCh:
-				if !response.Found {
-					notFoundCount += 1
-					if peerCount > 0 && notFoundCount >= peerCount {
-						return nil, errContentFetchNotFound
-					}
-					continue
-				}
-				if upsertErr := a.upsertContentBlob(response.ContentCID, response.Content, response.SizeBytes); upsertErr != nil {
-					return nil, upsertErr
-				}
-				return nil, nil
-			case <-time.After(remaining):
-				if notFoundCount > 0 {
-					return nil, errContentFetchNotFound
-				}
-				return nil, errContentFetchTimeout
-			}
+		header, data, fetchErr := a.fetchBlobFromPeers(blobKindContent, contentCID, timeout)
+		if fetchErr != nil {
+			return nil, fetchErr
 		}
+		return nil, a.upsertContentBlob(contentCID, string(data), header.Size)
 	})
 	elapsedMs := time.Since(startedAt).Milliseconds()
 	if err != nil {
@@ -1347,96 +1350,22 @@ func (a *App) fetchMediaBlobFromNetwork(contentCID string, timeout time.Duration
 	}
 
 	_, err, _ := a.mediaFetchGroup.Do("media:"+contentCID, func() (any, error) {
-		a.p2pMu.Lock()
-		topic := a.p2pTopic
-		ctx := a.p2pCtx
-		host := a.p2pHost
-		a.p2pMu.Unlock()
-
-		if topic == nil || ctx == nil || host == nil {
-			return nil, errors.New("p2p not started")
-		}
-		if len(host.Network().Peers()) == 0 {
-			return nil, errMediaFetchNoPeers
-		}
-
-		requestID := buildMessageID(host.ID().String(), "media:"+contentCID, time.Now().UnixNano())
-		responseCh := make(chan IncomingMessage, 1)
-
-		a.p2pMu.Lock()
-		a.mediaFetchWaiters[requestID] = responseCh
-		a.p2pMu.Unlock()
-
-		defer func() {
-			a.p2pMu.Lock()
-			delete(a.mediaFetchWaiters, requestID)
-			a.p2pMu.Unlock()
-		}()
-
-		request := IncomingMessage{
-			Type:            messageTypeMediaFetchRequest,
-			RequestID:       requestID,
-			RequesterPeerID: host.ID().String(),
-			ContentCID:      contentCID,
-			Timestamp:       time.Now().Unix(),
-		}
 		if a.ctx != nil {
 			runtime.LogInfof(
 				a.ctx,
-				"media_fetch.request request_id=%s cid=%s peer_count=%d timeout_ms=%d retry_budget=%d",
-				requestID,
+				"media_fetch.request cid=%s protocol=%s timeout_ms=%d retry_budget=%d",
 				contentCID,
-				len(host.Network().Peers()),
+				blobProtocolID,
 				timeout.Milliseconds(),
 				resolveFetchRetryAttempts()-1,
 			)
 		}
 
-		payload, marshalErr := json.Marshal(request)
-		if marshalErr != nil {
-			return nil, marshalErr
-		}
-
-		if publishErr := topic.Publish(ctx, payload); publishErr != nil {
-			return nil, publishErr
-		}
-
-		deadline := time.Now().Add(timeout)
-		notFoundCount := 0
-		peerCount := len(host.Network().Peers())
-		for {
-			remaining := time.Until(deadline)
-			if remaining <= 0 {
-				if notFoundCount > 0 {
-					return nil, errMediaFetchNotFound
-				}
-				return nil, errMediaFetchTimeout
-			}
-
-			select {
-			case response := <-responseCh:
-				if !response.Found {
-					notFoundCount += 1
-					if peerCount > 0 && notFoundCount >= peerCount {
-						return nil, errMediaFetchNotFound
-					}
-					continue
-				}
-				raw, decodeErr := base64.StdEncoding.DecodeString(strings.TrimSpace(response.ImageDataBase64))
-				if decodeErr != nil || len(raw) == 0 {
-					return nil, errors.New("invalid media response payload")
-				}
-				if upsertErr := a.upsertMediaBlobRaw(response.ContentCID, response.ImageMIME, raw, response.ImageWidth, response.ImageHeight, response.IsThumbnail); upsertErr != nil {
-					return nil, upsertErr
-				}
-				return nil, nil
-			case <-time.After(remaining):
-				if notFoundCount > 0 {
-					return nil, errMediaFetchNotFound
-				}
-				return nil, errMediaFetchTimeout
-			}
+		header, data, fetchErr := a.fetchBlobFromPeers(blobKindMedia, contentCID, timeout)
+		if fetchErr != nil {
+			return nil, fetchErr
 		}
+		return nil, a.upsertMediaBlobRaw(contentCID, header.Mime, data, header.Width, header.Height, header.IsThumbnail)
 	})
 	if a.ctx != nil {
 		if err != nil {
@@ -1449,8 +1378,14 @@ func (a *App) fetchMediaBlobFromNetwork(contentCID string, timeout time.Duration
 	return err
 }
 
+// TriggerAntiEntropySyncNow reconciles with one peer right away, falling back
+// to the timestamp-window requests when no peer speaks the reconcile protocol.
 func (a *App) TriggerAntiEntropySyncNow() error {
-	return a.publishSyncSummaryRequest()
+	err := a.runReconcileRound()
+	if errors.Is(err, errReconcileNoPeers) {
+		return a.publishSyncSummaryRequest()
+	}
+	return err
 }
 
 func (a *App) TriggerCommentSyncNow(postID string) error {
@@ -1472,61 +1407,54 @@ func (a *App) runAntiEntropySyncWorker(ctx context.Context, localPeerID peer.ID)
 		case <-ctx.Done():
 			return
 		case <-initialTimer.C:
-			if err := a.publishSyncSummaryRequest(); err != nil &&
-				!errors.Is(err, errAntiEntropyNoPeers) &&
-				!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
-				a.ctx != nil {
-				runtime.LogWarningf(a.ctx, "anti-entropy initial sync failed: %v", err)
-			}
-			if err := a.publishCommentSyncRequest(""); err != nil &&
-				!errors.Is(err, errCommentSyncNoPeers) &&
-				!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
-				a.ctx != nil {
-				runtime.LogWarningf(a.ctx, "comment sync initial request failed: %v", err)
-			}
-			if err := a.publishGovernanceSyncRequest(); err != nil &&
-				!errors.Is(err, errGovernanceSyncNoPeers) &&
-				!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
-				a.ctx != nil {
-				runtime.LogWarningf(a.ctx, "governance sync initial request failed: %v", err)
-			}
-			if err := a.publishFavoriteSyncRequest(); err != nil &&
-				!errors.Is(err, errFavoriteSyncNoPeers) &&
-				!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
-				!strings.Contains(strings.ToLower(err.Error()), "identity not found") &&
-				a.ctx != nil {
-				runtime.LogWarningf(a.ctx, "favorite sync initial request failed: %v", err)
-			}
+			a.runAntiEntropyRound("initial")
 		case <-ticker.C:
-			if err := a.publishSyncSummaryRequest(); err != nil &&
-				!errors.Is(err, errAntiEntropyNoPeers) &&
-				!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
-				a.ctx != nil {
-				runtime.LogWarningf(a.ctx, "anti-entropy periodic sync failed: %v", err)
-			}
-			if err := a.publishCommentSyncRequest(""); err != nil &&
-				!errors.Is(err, errCommentSyncNoPeers) &&
-				!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
-				a.ctx != nil {
-				runtime.LogWarningf(a.ctx, "comment sync periodic request failed: %v", err)
-			}
-			if err := a.publishGovernanceSyncRequest(); err != nil &&
-				!errors.Is(err, errGovernanceSyncNoPeers) &&
-				!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
-				a.ctx != nil {
-				runtime.LogWarningf(a.ctx, "governance sync periodic request failed: %v", err)
-			}
-			if err := a.publishFavoriteSyncRequest(); err != nil &&
-				!errors.Is(err, errFavoriteSyncNoPeers) &&
-				!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
-				!strings.Contains(strings.ToLower(err.Error()), "identity not found") &&
-				a.ctx != nil {
-				runtime.LogWarningf(a.ctx, "favorite sync periodic request failed: %v", err)
-			}
+			a.runAntiEntropyRound("periodic")
 		}
 	}
 }
 
+// runAntiEntropyRound reconciles with one peer. The timestamp-window requests
+// only go out while no connected peer speaks the reconcile protocol.
+func (a *App) runAntiEntropyRound(phase string) {
+	err := a.runReconcileRound()
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, errReconcileNoPeers) {
+		if !strings.Contains(strings.ToLower(err.Error()), "p2p not started") && a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "anti-entropy %s reconcile failed: %v", phase, err)
+		}
+		return
+	}
+
+	if err := a.publishSyncSummaryRequest(); err != nil &&
+		!errors.Is(err, errAntiEntropyNoPeers) &&
+		!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
+		a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "anti-entropy %s sync failed: %v", phase, err)
+	}
+	if err := a.publishCommentSyncRequest(""); err != nil &&
+		!errors.Is(err, errCommentSyncNoPeers) &&
+		!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
+		a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "comment sync %s request failed: %v", phase, err)
+	}
+	if err := a.publishGovernanceSyncRequest(); err != nil &&
+		!errors.Is(err, errGovernanceSyncNoPeers) &&
+		!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
+		a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "governance sync %s request failed: %v", phase, err)
+	}
+	if err := a.publishFavoriteSyncRequest(); err != nil &&
+		!errors.Is(err, errFavoriteSyncNoPeers) &&
+		!strings.Contains(strings.ToLower(err.Error()), "p2p not started") &&
+		!strings.Contains(strings.ToLower(err.Error()), "identity not found") &&
+		a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "favorite sync %s request failed: %v", phase, err)
+	}
+}
+
 func resolveAntiEntropyInterval() time.Duration {
 	raw := strings.TrimSpace(os.Getenv("AEGIS_ANTI_ENTROPY_INTERVAL_SEC"))
 	if raw != "" {
@@ -1612,19 +1540,33 @@ func (a *App) updateAntiEntropyStats(apply func(stats *AntiEntropyStats)) {
 
 func (a *App) publishSyncSummaryRequest() error {
 	a.p2pMu.Lock()
-	topic := a.p2pTopic
 	ctx := a.p2pCtx
 	host := a.p2pHost
 	a.p2pMu.Unlock()
 
-	if topic == nil || ctx == nil || host == nil {
+	if ctx == nil || host == nil {
 		return errors.New("p2p not started")
 	}
 	if len(host.Network().Peers()) == 0 {
 		return errAntiEntropyNoPeers
 	}
 
-	latestTimestamp, latestErr := a.getLatestPublicPostTimestamp()
+	var firstErr error
+	for _, subID := range a.followedSubTopicIDs() {
+		if err := a.publishSubSyncSummaryRequest(host.ID().String(), subID); err != nil {
+			firstErr = errors.Join(firstErr, err)
+		}
+	}
+	return firstErr
+}
+
+func (a *App) publishSubSyncSummaryRequest(localPeerID string, subID string) error {
+	topic, ctx := a.topicForSub(subID)
+	if topic == nil || ctx == nil {
+		return errors.New("p2p not started")
+	}
+
+	latestTimestamp, latestErr := a.getLatestPublicPostTimestampForSub(subID)
 	if latestErr != nil {
 		return latestErr
 	}
@@ -1641,8 +1583,9 @@ func (a *App) publishSyncSummaryRequest() error {
 
 	request := IncomingMessage{
 		Type:               messageTypeSyncSummaryRequest,
-		RequestID:          buildMessageID(host.ID().String(), "sync-summary", time.Now().UnixNano()),
-		RequesterPeerID:    host.ID().String(),
+		RequestID:          buildMessageID(localPeerID, "sync-summary:"+subID, time.Now().UnixNano()),
+		RequesterPeerID:    localPeerID,
+		SubID:              subID,
 		SyncSinceTimestamp: sinceTimestamp,
 		SyncWindowSeconds:  windowSeconds,
 		SyncBatchSize:      batchSize,
@@ -1658,7 +1601,7 @@ func (a *App) publishSyncSummaryRequest() error {
 		stats.SyncRequestsSent++
 	})
 	if a.ctx != nil {
-		runtime.LogInfof(a.ctx, "anti_entropy.request sent request_id=%s since=%d window=%d batch=%d", request.RequestID, request.SyncSinceTimestamp, request.SyncWindowSeconds, request.SyncBatchSize)
+		runtime.LogInfof(a.ctx, "anti_entropy.request sent request_id=%s sub_id=%s since=%d window=%d batch=%d", request.RequestID, subID, request.SyncSinceTimestamp, request.SyncWindowSeconds, request.SyncBatchSize)
 	}
 
 	return topic.Publish(ctx, payload)
@@ -1666,19 +1609,40 @@ func (a *App) publishSyncSummaryRequest() error {
 
 func (a *App) publishCommentSyncRequest(postID string) error {
 	a.p2pMu.Lock()
-	topic := a.p2pTopic
 	ctx := a.p2pCtx
 	host := a.p2pHost
 	a.p2pMu.Unlock()
 
-	if topic == nil || ctx == nil || host == nil {
+	if ctx == nil || host == nil {
 		return errors.New("p2p not started")
 	}
 	if len(host.Network().Peers()) == 0 {
 		return errCommentSyncNoPeers
 	}
 
-	latestTimestamp, err := a.getLatestPublicCommentTimestamp()
+	postID = strings.TrimSpace(postID)
+	if postID != "" {
+		return a.publishSubCommentSyncRequest(host.ID().String(), a.lookupPostSubID(postID), postID)
+	}
+
+	var firstErr error
+	for _, subID := range a.followedSubTopicIDs() {
+		if err := a.publishSubCommentSyncRequest(host.ID().String(), subID, ""); err != nil {
+			firstErr = errors.Join(firstErr, err)
+		}
+	}
+	return firstErr
+}
+
+// publishSubCommentSyncRequest asks the peers of subID for recent comments,
+// optionally limited to postID. An empty subID goes to the control topic.
+func (a *App) publishSubCommentSyncRequest(localPeerID string, subID string, postID string) error {
+	topic, ctx := a.topicForSub(subID)
+	if topic == nil || ctx == nil {
+		return errors.New("p2p not started")
+	}
+
+	latestTimestamp, err := a.getLatestPublicCommentTimestampForSub(subID)
 	if err != nil {
 		return err
 	}
@@ -1695,9 +1659,10 @@ func (a *App) publishCommentSyncRequest(postID string) error {
 	batchSize := resolveAntiEntropyBatchSize()
 	request := IncomingMessage{
 		Type:             messageTypeCommentSyncRequest,
-		RequestID:        buildMessageID(host.ID().String(), "comment-sync", time.Now().UnixNano()),
-		RequesterPeerID:  host.ID().String(),
-		PostID:           strings.TrimSpace(postID),
+		RequestID:        buildMessageID(localPeerID, "comment-sync:"+subID, time.Now().UnixNano()),
+		RequesterPeerID:  localPeerID,
+		PostID:           postID,
+		SubID:            subID,
 		CommentSinceTs:   sinceTimestamp,
 		CommentBatchSize: batchSize,
 		Timestamp:        time.Now().Unix(),
@@ -1709,7 +1674,7 @@ func (a *App) publishCommentSyncRequest(postID string) error {
 	}
 
 	if a.ctx != nil {
-		runtime.LogInfof(a.ctx, "comment_sync.request sent request_id=%s post_id=%s since=%d batch=%d", request.RequestID, request.PostID, request.CommentSinceTs, request.CommentBatchSize)
+		runtime.LogInfof(a.ctx, "comment_sync.request sent request_id=%s sub_id=%s post_id=%s since=%d batch=%d", request.RequestID, subID, request.PostID, request.CommentSinceTs, request.CommentBatchSize)
 	}
 
 	return topic.Publish(ctx, payload)
@@ -1856,6 +1821,14 @@ func (a *App) getP2PStatusLocked() P2PStatus {
 		status.ConnectedPeers = append(status.ConnectedPeers, connectedPeer.String())
 	}
 
+	status.SubTopics = make([]string, 0, len(a.p2pSubTopics))
+	for subID, handle := range a.p2pSubTopics {
+		if handle.sub != nil {
+			status.SubTopics = append(status.SubTopics, subTopicName(subID))
+		}
+	}
+	sort.Strings(status.SubTopics)
+
 	return status
 }
 
@@ -1895,9 +1868,18 @@ func (a *App) consumeP2PMessages(ctx context.Context, localPeerID peer.ID, sub *
 			if a.ctx != nil {
 				runtime.LogWarningf(a.ctx, "drop message from blocked peer peer=%s reason=%s", remotePeerID, reason)
 			}
+			a.noteIncomingMessage("blocked")
 			continue
 		}
 
+		// Gossip relays frames, so the peer that delivered one need not be
+		// the one that wrote it. Requests and answers are attributed to the
+		// origin, which the router authenticates with the frame signature.
+		originPeerID := remotePeerID
+		if origin := message.GetFrom(); origin != "" {
+			originPeerID = origin.String()
+		}
+
 		var incoming IncomingMessage
 		_ = json.Unmarshal(message.Data, &incoming)
 		messageType := strings.ToUpper(strings.TrimSpace(incoming.Type))
@@ -1909,17 +1891,8 @@ func (a *App) consumeP2PMessages(ctx context.Context, localPeerID peer.ID, sub *
 		case messageTypePeerExchangeResponse:
 			a.handlePeerExchangeResponse(localPeerID.String(), incoming)
 			continue
-		case messageTypeContentFetchRequest:
-			a.handleContentFetchRequest(localPeerID.String(), message.ReceivedFrom.String(), incoming)
-			continue
-		case messageTypeContentFetchResponse:
-			a.handleContentFetchResponse(localPeerID.String(), incoming)
-			continue
-		case messageTypeMediaFetchRequest:
-			a.handleMediaFetchRequest(localPeerID.String(), message.ReceivedFrom.String(), incoming)
-			continue
-		case messageTypeMediaFetchResponse:
-			a.handleMediaFetchResponse(localPeerID.String(), incoming)
+		case messageTypeContentFetchRequest, messageTypeContentFetchResponse, messageTypeMediaFetchRequest, messageTypeMediaFetchResponse:
+			// Blob fetches moved to blobProtocolID streams; legacy gossip fetches are dropped.
 			continue
 		case messageTypeSyncSummaryRequest:
 			a.handleSyncSummaryRequest(localPeerID.String(), incoming)
@@ -1945,6 +1918,18 @@ func (a *App) consumeP2PMessages(ctx context.Context, localPeerID peer.ID, sub *
 		case messageTypeFavoriteSyncResponse:
 			a.handleFavoriteSyncResponse(localPeerID.String(), incoming)
 			continue
+		case messageTypeSearchRequest:
+			a.handleSearchRequest(localPeerID.String(), originPeerID, incoming)
+			continue
+		case messageTypeSearchResponse:
+			a.handleSearchResponse(localPeerID.String(), originPeerID, incoming)
+			continue
+		case messageTypeRevisionSyncRequest:
+			a.handleRevisionSyncRequest(localPeerID.String(), originPeerID, incoming)
+			continue
+		case messageTypeRevisionSyncResponse:
+			a.handleRevisionSyncResponse(localPeerID.String(), originPeerID, incoming)
+			continue
 		}
 
 		if err = a.ProcessIncomingMessage(message.Data); err != nil {
@@ -1954,6 +1939,10 @@ func (a *App) consumeP2PMessages(ctx context.Context, localPeerID peer.ID, sub *
 			continue
 		}
 
+		if messageType == "SUB_CREATE" && resolveJoinAllSubTopics() {
+			a.syncSubTopicMembership(incoming.SubID)
+		}
+
 		if a.ctx != nil {
 			if messageType == messageTypeFavoriteOp {
 				continue
@@ -1975,194 +1964,6 @@ func (a *App) consumeP2PMessages(ctx context.Context, localPeerID peer.ID, sub *
 	}
 }
 
-func (a *App) handleContentFetchRequest(localPeerID string, remotePeerID string, message IncomingMessage) {
-	requester := strings.TrimSpace(remotePeerID)
-	contentCID := strings.TrimSpace(message.ContentCID)
-	requestID := strings.TrimSpace(message.RequestID)
-	if requester == "" || contentCID == "" || requestID == "" {
-		return
-	}
-	if requester == localPeerID {
-		return
-	}
-	if !a.allowFetchRequest(requester, "content") {
-		return
-	}
-
-	shareable, shareErr := a.canServeContentBlobToNetwork(contentCID)
-	if shareErr != nil || !shareable {
-		if a.ctx != nil && shareErr == nil {
-			runtime.LogInfof(a.ctx, "content_fetch.policy_block request_id=%s cid=%s requester=%s", requestID, contentCID, requester)
-		}
-		a.publishContentFetchNotFound(localPeerID, requester, requestID, contentCID)
-		return
-	}
-
-	body, err := a.getContentBlobLocal(contentCID)
-	if err != nil {
-		a.publishContentFetchNotFound(localPeerID, requester, requestID, contentCID)
-		return
-	}
-
-	response := IncomingMessage{
-		Type:            messageTypeContentFetchResponse,
-		RequestID:       requestID,
-		RequesterPeerID: requester,
-		ResponderPeerID: localPeerID,
-		ContentCID:      body.ContentCID,
-		Content:         body.Body,
-		SizeBytes:       body.SizeBytes,
-		Found:           true,
-		Timestamp:       time.Now().Unix(),
-	}
-
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	ctx := a.p2pCtx
-	a.p2pMu.Unlock()
-	if topic == nil || ctx == nil {
-		return
-	}
-
-	payload, marshalErr := json.Marshal(response)
-	if marshalErr != nil {
-		return
-	}
-	_ = topic.Publish(ctx, payload)
-}
-
-func (a *App) publishContentFetchNotFound(localPeerID string, requester string, requestID string, contentCID string) {
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	ctx := a.p2pCtx
-	a.p2pMu.Unlock()
-	if topic == nil || ctx == nil {
-		return
-	}
-
-	response := IncomingMessage{
-		Type:            messageTypeContentFetchResponse,
-		RequestID:       requestID,
-		RequesterPeerID: requester,
-		ResponderPeerID: localPeerID,
-		ContentCID:      contentCID,
-		Found:           false,
-		Timestamp:       time.Now().Unix(),
-	}
-	payload, marshalErr := json.Marshal(response)
-	if marshalErr != nil {
-		return
-	}
-	_ = topic.Publish(ctx, payload)
-}
-
-func (a *App) handleContentFetchResponse(localPeerID string, message IncomingMessage) {
-	requester := strings.TrimSpace(message.RequesterPeerID)
-	if requester == "" || requester != localPeerID {
-		return
-	}
-	requestID := strings.TrimSpace(message.RequestID)
-	if requestID == "" {
-		return
-	}
-
-	a.p2pMu.Lock()
-	waiter, ok := a.contentFetchWaiters[requestID]
-	a.p2pMu.Unlock()
-	if !ok {
-		return
-	}
-
-	select {
-	case waiter <- message:
-	default:
-	}
-}
-
-func (a *App) handleMediaFetchRequest(localPeerID string, remotePeerID string, message IncomingMessage) {
-	requester := strings.TrimSpace(remotePeerID)
-	contentCID := strings.TrimSpace(message.ContentCID)
-	requestID := strings.TrimSpace(message.RequestID)
-	if requester == "" || contentCID == "" || requestID == "" {
-		return
-	}
-	if requester == localPeerID {
-		return
-	}
-	if !a.allowFetchRequest(requester, "media") {
-		return
-	}
-
-	shareable, shareErr := a.canServeMediaBlobToNetwork(contentCID)
-	if shareErr != nil || !shareable {
-		if a.ctx != nil && shareErr == nil {
-			runtime.LogInfof(a.ctx, "media_fetch.policy_block request_id=%s cid=%s requester=%s", requestID, contentCID, requester)
-		}
-		a.publishMediaFetchNotFound(localPeerID, requester, requestID, contentCID)
-		return
-	}
-
-	media, raw, err := a.getMediaBlobRawLocal(contentCID)
-	if err != nil {
-		a.publishMediaFetchNotFound(localPeerID, requester, requestID, contentCID)
-		return
-	}
-
-	response := IncomingMessage{
-		Type:            messageTypeMediaFetchResponse,
-		RequestID:       requestID,
-		RequesterPeerID: requester,
-		ResponderPeerID: localPeerID,
-		ContentCID:      media.ContentCID,
-		ImageDataBase64: base64.StdEncoding.EncodeToString(raw),
-		ImageMIME:       media.Mime,
-		ImageWidth:      media.Width,
-		ImageHeight:     media.Height,
-		IsThumbnail:     media.IsThumbnail,
-		Found:           true,
-		Timestamp:       time.Now().Unix(),
-	}
-
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	ctx := a.p2pCtx
-	a.p2pMu.Unlock()
-	if topic == nil || ctx == nil {
-		return
-	}
-
-	payload, marshalErr := json.Marshal(response)
-	if marshalErr != nil {
-		return
-	}
-	_ = topic.Publish(ctx, payload)
-}
-
-func (a *App) publishMediaFetchNotFound(localPeerID string, requester string, requestID string, contentCID string) {
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	ctx := a.p2pCtx
-	a.p2pMu.Unlock()
-	if topic == nil || ctx == nil {
-		return
-	}
-
-	response := IncomingMessage{
-		Type:            messageTypeMediaFetchResponse,
-		RequestID:       requestID,
-		RequesterPeerID: requester,
-		ResponderPeerID: localPeerID,
-		ContentCID:      contentCID,
-		Found:           false,
-		Timestamp:       time.Now().Unix(),
-	}
-	payload, marshalErr := json.Marshal(response)
-	if marshalErr != nil {
-		return
-	}
-	_ = topic.Publish(ctx, payload)
-}
-
 func resolveFetchRateLimitPerWindow() int {
 	raw := strings.TrimSpace(os.Getenv("AEGIS_FETCH_REQUEST_LIMIT"))
 	if raw == "" {
@@ -2247,6 +2048,7 @@ func (a *App) allowIncomingMessage(peerID string, payloadSize int) bool {
 			runtime.LogWarningf(a.ctx, "incoming message too large peer=%s size=%d max=%d", peerID, payloadSize, maxBytes)
 		}
 		a.markPeerGreylisted(peerID, "incoming-message-too-large")
+		a.noteIncomingMessage("too_large")
 		return false
 	}
 
@@ -2261,6 +2063,7 @@ func (a *App) allowIncomingMessage(peerID string, payloadSize int) bool {
 	window, exists := a.fetchRateState[stateKey]
 	if !exists || now-window.StartedAt >= windowSec {
 		a.fetchRateState[stateKey] = fetchRateWindow{StartedAt: now, Count: 1}
+		a.noteIncomingMessage("accepted")
 		return true
 	}
 
@@ -2269,11 +2072,13 @@ func (a *App) allowIncomingMessage(peerID string, payloadSize int) bool {
 			runtime.LogWarningf(a.ctx, "incoming message rate limited peer=%s count=%d limit=%d window_sec=%d", peerID, window.Count, limit, windowSec)
 		}
 		a.markPeerGreylisted(peerID, "incoming-message-rate-limit")
+		a.noteIncomingMessage("rate_limited")
 		return false
 	}
 
 	window.Count += 1
 	a.fetchRateState[stateKey] = window
+	a.noteIncomingMessage("accepted")
 	return true
 }
 
@@ -2311,29 +2116,6 @@ func (a *App) allowFetchRequest(peerID string, requestType string) bool {
 	return true
 }
 
-func (a *App) handleMediaFetchResponse(localPeerID string, message IncomingMessage) {
-	requester := strings.TrimSpace(message.RequesterPeerID)
-	if requester == "" || requester != localPeerID {
-		return
-	}
-	requestID := strings.TrimSpace(message.RequestID)
-	if requestID == "" {
-		return
-	}
-
-	a.p2pMu.Lock()
-	waiter, ok := a.mediaFetchWaiters[requestID]
-	a.p2pMu.Unlock()
-	if !ok {
-		return
-	}
-
-	select {
-	case waiter <- message:
-	default:
-	}
-}
-
 func (a *App) handleSyncSummaryRequest(localPeerID string, message IncomingMessage) {
 	requester := strings.TrimSpace(message.RequesterPeerID)
 	requestID := strings.TrimSpace(message.RequestID)
@@ -2351,7 +2133,13 @@ func (a *App) handleSyncSummaryRequest(localPeerID string, message IncomingMessa
 		sinceTimestamp = 0
 	}
 
-	summaries, err := a.listPublicPostDigestsSince(sinceTimestamp, batchSize)
+	// Requests without a sub come from nodes that predate per-sub topics.
+	subID := ""
+	if strings.TrimSpace(message.SubID) != "" {
+		subID = normalizeSubID(message.SubID)
+	}
+
+	summaries, err := a.listPublicPostDigestsForSubSince(subID, sinceTimestamp, batchSize)
 	if err != nil {
 		if a.ctx != nil {
 			runtime.LogWarningf(a.ctx, "anti-entropy build summary failed: %v", err)
@@ -2363,7 +2151,7 @@ func (a *App) handleSyncSummaryRequest(localPeerID string, message IncomingMessa
 		stats.SyncRequestsReceived++
 	})
 	if a.ctx != nil {
-		runtime.LogInfof(a.ctx, "anti_entropy.request received request_id=%s since=%d batch=%d summaries=%d", requestID, sinceTimestamp, batchSize, len(summaries))
+		runtime.LogInfof(a.ctx, "anti_entropy.request received request_id=%s sub_id=%s since=%d batch=%d summaries=%d", requestID, subID, sinceTimestamp, batchSize, len(summaries))
 	}
 
 	response := IncomingMessage{
@@ -2373,16 +2161,14 @@ func (a *App) handleSyncSummaryRequest(localPeerID string, message IncomingMessa
 		RequestID:          requestID,
 		RequesterPeerID:    requester,
 		ResponderPeerID:    localPeerID,
+		SubID:              subID,
 		SyncSinceTimestamp: sinceTimestamp,
 		SyncBatchSize:      batchSize,
 		Summaries:          summaries,
 		Timestamp:          time.Now().Unix(),
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	ctx := a.p2pCtx
-	a.p2pMu.Unlock()
+	topic, ctx := a.topicForSub(subID)
 	if topic == nil || ctx == nil {
 		return
 	}
@@ -2433,6 +2219,7 @@ func (a *App) handleSyncSummaryResponse(localPeerID string, message IncomingMess
 	seenCIDs := make(map[string]struct{}, bodyFetchBudget)
 	seenMediaCIDs := make(map[string]struct{}, mediaFetchBudget)
 	indexInsertions := int64(0)
+	signatureRejects := 0
 	remoteMaxTimestamp := int64(0)
 	viewerPubkey := ""
 	if identity, err := a.getLocalIdentity(); err == nil {
@@ -2450,6 +2237,10 @@ func (a *App) handleSyncSummaryResponse(localPeerID string, message IncomingMess
 		if !allowed {
 			continue
 		}
+		if err := a.verifyPostDigestSignature(digest); err != nil {
+			signatureRejects++
+			continue
+		}
 
 		if digest.Timestamp > remoteMaxTimestamp {
 			remoteMaxTimestamp = digest.Timestamp
@@ -2463,6 +2254,7 @@ func (a *App) handleSyncSummaryResponse(localPeerID string, message IncomingMess
 			if inserted {
 				insertedAny = true
 				indexInsertions++
+				_ = a.recordIncomingMessageSignature(postDigestSigningMessage(digest))
 			}
 			indexBudget--
 		}
@@ -2505,7 +2297,7 @@ func (a *App) handleSyncSummaryResponse(localPeerID string, message IncomingMess
 	})
 
 	if a.ctx != nil {
-		runtime.LogInfof(a.ctx, "anti_entropy.response applied request_id=%s summaries=%d inserted=%d text_fetch_candidates=%d media_fetch_candidates=%d", strings.TrimSpace(message.RequestID), len(summaries), indexInsertions, len(missingCIDs), len(missingMediaCIDs))
+		runtime.LogInfof(a.ctx, "anti_entropy.response applied request_id=%s summaries=%d inserted=%d signature_rejects=%d text_fetch_candidates=%d media_fetch_candidates=%d", strings.TrimSpace(message.RequestID), len(summaries), indexInsertions, signatureRejects, len(missingCIDs), len(missingMediaCIDs))
 	}
 
 	for _, contentCID := range missingCIDs {
@@ -2603,10 +2395,14 @@ func (a *App) handleCommentSyncRequest(localPeerID string, message IncomingMessa
 	}
 
 	requestPostID := strings.TrimSpace(message.PostID)
+	subID := ""
+	if strings.TrimSpace(message.SubID) != "" {
+		subID = normalizeSubID(message.SubID)
+	}
 	var comments []SyncCommentDigest
 	var err error
 	if requestPostID == "" {
-		comments, err = a.listPublicCommentDigestsSince(sinceTimestamp, batchSize)
+		comments, err = a.listPublicCommentDigestsForSubSince(subID, sinceTimestamp, batchSize)
 	} else {
 		comments, err = a.listPublicCommentDigestsByPostSince(requestPostID, sinceTimestamp, batchSize)
 	}
@@ -2625,16 +2421,14 @@ func (a *App) handleCommentSyncRequest(localPeerID string, message IncomingMessa
 		RequesterPeerID:  requester,
 		ResponderPeerID:  localPeerID,
 		PostID:           requestPostID,
+		SubID:            subID,
 		CommentSinceTs:   sinceTimestamp,
 		CommentBatchSize: batchSize,
 		CommentSummaries: comments,
 		Timestamp:        time.Now().Unix(),
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	ctx := a.p2pCtx
-	a.p2pMu.Unlock()
+	topic, ctx := a.topicForSub(subID)
 	if topic == nil || ctx == nil {
 		return
 	}
@@ -2645,7 +2439,7 @@ func (a *App) handleCommentSyncRequest(localPeerID string, message IncomingMessa
 	}
 
 	if a.ctx != nil {
-		runtime.LogInfof(a.ctx, "comment_sync.response sent request_id=%s post_id=%s since=%d batch=%d comments=%d", requestID, requestPostID, sinceTimestamp, batchSize, len(comments))
+		runtime.LogInfof(a.ctx, "comment_sync.response sent request_id=%s sub_id=%s post_id=%s since=%d batch=%d comments=%d", requestID, subID, requestPostID, sinceTimestamp, batchSize, len(comments))
 	}
 
 	_ = topic.Publish(ctx, payload)
@@ -2684,6 +2478,7 @@ func (a *App) handleCommentSyncResponse(localPeerID string, message IncomingMess
 
 	inserted := 0
 	updatedProfiles := 0
+	signatureRejects := 0
 	updatedPostIDs := make(map[string]struct{})
 	viewerPubkey := ""
 	if identity, err := a.getLocalIdentity(); err == nil {
@@ -2696,9 +2491,13 @@ func (a *App) handleCommentSyncResponse(localPeerID string, message IncomingMess
 				continue
 			}
 		}
+		if err := a.verifyCommentDigestSignature(digest); err != nil {
+			signatureRejects++
+			continue
+		}
 
 		if strings.TrimSpace(digest.DisplayName) != "" || strings.TrimSpace(digest.AvatarURL) != "" {
-			if _, err := a.upsertProfile(digest.Pubkey, digest.DisplayName, digest.AvatarURL, digest.Timestamp); err == nil {
+			if err := a.upsertProfileHint(digest.Pubkey, digest.DisplayName, digest.AvatarURL, digest.Timestamp); err == nil {
 				updatedProfiles++
 			}
 		}
@@ -2728,12 +2527,13 @@ func (a *App) handleCommentSyncResponse(localPeerID string, message IncomingMess
 			}
 		}
 
+		_ = a.recordIncomingMessageSignature(commentDigestSigningMessage(digest))
 		inserted++
 		updatedPostIDs[strings.TrimSpace(digest.PostID)] = struct{}{}
 	}
 
 	if a.ctx != nil {
-		runtime.LogInfof(a.ctx, "comment_sync.response applied request_id=%s post_id=%s received=%d inserted=%d profiles=%d", strings.TrimSpace(message.RequestID), strings.TrimSpace(message.PostID), len(commentSummaries), inserted, updatedProfiles)
+		runtime.LogInfof(a.ctx, "comment_sync.response applied request_id=%s post_id=%s received=%d inserted=%d profiles=%d signature_rejects=%d", strings.TrimSpace(message.RequestID), strings.TrimSpace(message.PostID), len(commentSummaries), inserted, updatedProfiles, signatureRejects)
 	}
 
 	if inserted == 0 && updatedProfiles == 0 {
@@ -2790,19 +2590,27 @@ func (a *App) handleGovernanceSyncRequest(localPeerID string, message IncomingMe
 		}
 		return
 	}
+	delegations, err := a.listAdminDelegationsForSync(governanceDelegationSyncLimit)
+	if err != nil {
+		if a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "governance_sync.build_delegations failed request_id=%s err=%v", requestID, err)
+		}
+		return
+	}
 
 	response := IncomingMessage{
-		Type:                 messageTypeGovernanceSyncResponse,
-		RequestID:            requestID,
-		RequesterPeerID:      requester,
-		ResponderPeerID:      localPeerID,
-		GovernanceSinceTs:    sinceTs,
-		GovernanceBatchSize:  batchSize,
-		GovernanceLogSinceTs: logSinceTs,
-		GovernanceLogLimit:   logLimit,
-		GovernanceStates:     states,
-		GovernanceLogs:       logs,
-		Timestamp:            time.Now().Unix(),
+		Type:                  messageTypeGovernanceSyncResponse,
+		RequestID:             requestID,
+		RequesterPeerID:       requester,
+		ResponderPeerID:       localPeerID,
+		GovernanceSinceTs:     sinceTs,
+		GovernanceBatchSize:   batchSize,
+		GovernanceLogSinceTs:  logSinceTs,
+		GovernanceLogLimit:    logLimit,
+		GovernanceStates:      states,
+		GovernanceLogs:        logs,
+		GovernanceDelegations: delegations,
+		Timestamp:             time.Now().Unix(),
 	}
 
 	a.p2pMu.Lock()
@@ -2820,7 +2628,7 @@ func (a *App) handleGovernanceSyncRequest(localPeerID string, message IncomingMe
 	_ = topic.Publish(ctx, payload)
 
 	if a.ctx != nil {
-		runtime.LogInfof(a.ctx, "governance_sync.response sent request_id=%s state_since=%d states=%d log_since=%d logs=%d", requestID, sinceTs, len(states), logSinceTs, len(logs))
+		runtime.LogInfof(a.ctx, "governance_sync.response sent request_id=%s state_since=%d states=%d log_since=%d logs=%d delegations=%d", requestID, sinceTs, len(states), logSinceTs, len(logs), len(delegations))
 	}
 }
 
@@ -2830,6 +2638,21 @@ func (a *App) handleGovernanceSyncResponse(localPeerID string, message IncomingM
 		return
 	}
 
+	// Delegations go first: they decide which admins the states below trust.
+	delegations := append([]AdminDelegation(nil), message.GovernanceDelegations...)
+	sortAdminDelegations(delegations)
+	delegationsApplied := 0
+	for _, row := range delegations {
+		inserted, err := a.applyAdminDelegation(row)
+		if err != nil {
+			continue
+		}
+		if inserted {
+			delegationsApplied++
+		}
+	}
+
+	signatureRejects := 0
 	states := make([]ModerationState, 0, len(message.GovernanceStates))
 	for _, row := range message.GovernanceStates {
 		if strings.TrimSpace(row.TargetPubkey) == "" || strings.TrimSpace(row.SourceAdmin) == "" {
@@ -2870,7 +2693,14 @@ func (a *App) handleGovernanceSyncResponse(localPeerID string, message IncomingM
 			}
 			continue
 		}
-		if err := a.upsertModeration(row.TargetPubkey, row.Action, row.SourceAdmin, row.Timestamp, row.Lamport, row.Reason); err != nil {
+		if err := a.verifyModerationStateSignature(row); err != nil {
+			signatureRejects++
+			if a.ctx != nil {
+				runtime.LogWarningf(a.ctx, "governance_sync.skip_unsigned target=%s admin=%s action=%s err=%v", row.TargetPubkey, row.SourceAdmin, row.Action, err)
+			}
+			continue
+		}
+		if err := a.upsertModeration(row.TargetPubkey, row.Action, row.SourceAdmin, row.Timestamp, row.Lamport, row.Reason, row.Signature); err != nil {
 			if a.ctx != nil {
 				runtime.LogWarningf(a.ctx, "governance_sync.apply_failed target=%s action=%s err=%v", row.TargetPubkey, row.Action, err)
 			}
@@ -2899,6 +2729,11 @@ func (a *App) handleGovernanceSyncResponse(localPeerID string, message IncomingM
 			}
 			continue
 		}
+		row.Action = action
+		if err := a.verifyModerationLogSignature(row); err != nil {
+			signatureRejects++
+			continue
+		}
 
 		inserted, err := a.insertModerationLogIfAbsent(ModerationLog{
 			TargetPubkey: row.TargetPubkey,
@@ -2908,6 +2743,7 @@ func (a *App) handleGovernanceSyncResponse(localPeerID string, message IncomingM
 			Lamport:      row.Lamport,
 			Reason:       row.Reason,
 			Result:       "applied",
+			Signature:    row.Signature,
 		})
 		if err != nil {
 			if a.ctx != nil {
@@ -2921,8 +2757,8 @@ func (a *App) handleGovernanceSyncResponse(localPeerID string, message IncomingM
 	}
 
 	if a.ctx != nil {
-		runtime.LogInfof(a.ctx, "governance_sync.response applied request_id=%s states=%d applied=%d logs=%d logs_inserted=%d", strings.TrimSpace(message.RequestID), len(states), applied, len(message.GovernanceLogs), logsInserted)
-		if applied > 0 || logsInserted > 0 {
+		runtime.LogInfof(a.ctx, "governance_sync.response applied request_id=%s states=%d applied=%d logs=%d logs_inserted=%d delegations=%d delegations_applied=%d signature_rejects=%d", strings.TrimSpace(message.RequestID), len(states), applied, len(message.GovernanceLogs), logsInserted, len(delegations), delegationsApplied, signatureRejects)
+		if applied > 0 || logsInserted > 0 || delegationsApplied > 0 {
 			runtime.EventsEmit(a.ctx, "feed:updated")
 		}
 	}
@@ -3303,14 +3139,20 @@ func (a *App) markPeerGreylisted(peerID string, reason string) {
 		return
 	}
 
-	until := time.Now().Unix() + resolveGreylistTTLSeconds()
+	now := time.Now().Unix()
+	until := now + resolveGreylistTTLSeconds()
 	a.peerPolicyMu.Lock()
 	if a.peerGreylist == nil {
 		a.peerGreylist = make(map[string]int64)
 	}
+	previous, listed := a.peerGreylist[peerID]
 	a.peerGreylist[peerID] = until
 	a.peerPolicyMu.Unlock()
 
+	if !listed || previous <= now {
+		a.noteGreylistAddition(now)
+	}
+
 	if a.ctx != nil {
 		runtime.LogWarningf(a.ctx, "peer moved to greylist peer=%s reason=%s until=%d", peerID, strings.TrimSpace(reason), until)
 	}
@@ -3363,10 +3205,6 @@ func (a *App) PublishPostUpdate(pubkey string, postID string, title string, body
 		return err
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	a.p2pMu.Unlock()
-
 	profile, profileErr := a.GetProfile(pubkey)
 	if profileErr != nil {
 		profile = Profile{}
@@ -3376,6 +3214,10 @@ func (a *App) PublishPostUpdate(pubkey string, postID string, title string, body
 	if err != nil {
 		return err
 	}
+	fullBody, err := a.getContentBlobLocal(localPost.ContentCID)
+	if err != nil {
+		return err
+	}
 
 	msg := IncomingMessage{
 		Type:          "POST",
@@ -3388,7 +3230,7 @@ func (a *App) PublishPostUpdate(pubkey string, postID string, title string, body
 		DisplayName:   strings.TrimSpace(profile.DisplayName),
 		AvatarURL:     strings.TrimSpace(profile.AvatarURL),
 		Title:         localPost.Title,
-		Body:          localPost.Body,
+		Body:          fullBody.Body,
 		ContentCID:    localPost.ContentCID,
 		ImageCID:      localPost.ImageCID,
 		ThumbCID:      localPost.ThumbCID,
@@ -3400,7 +3242,9 @@ func (a *App) PublishPostUpdate(pubkey string, postID string, title string, body
 		SubID:         normalizeSubID(localPost.SubID),
 		Timestamp:     localPost.Timestamp,
 		Lamport:       localPost.Lamport,
-		Signature:     "",
+	}
+	if err = a.signLocalEntityMessage(&msg); err != nil {
+		return err
 	}
 
 	payload, err := json.Marshal(msg)
@@ -3408,6 +3252,7 @@ func (a *App) PublishPostUpdate(pubkey string, postID string, title string, body
 		return err
 	}
 
+	topic, _ := a.topicForSub(msg.SubID)
 	a.publishPayloadAsync(topic, payload, "POST_UPDATE")
 	return nil
 }
@@ -3429,10 +3274,6 @@ func (a *App) PublishCommentUpdate(pubkey string, commentID string, body string)
 		return err
 	}
 
-	a.p2pMu.Lock()
-	topic := a.p2pTopic
-	a.p2pMu.Unlock()
-
 	profile, profileErr := a.GetProfile(pubkey)
 	if profileErr != nil {
 		profile = Profile{}
@@ -3460,12 +3301,16 @@ func (a *App) PublishCommentUpdate(pubkey string, commentID string, body string)
 		Timestamp:          localComment.Timestamp,
 		Lamport:            localComment.Lamport,
 	}
+	if err = a.signLocalEntityMessage(&msg); err != nil {
+		return err
+	}
 
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
 
+	topic, _ := a.topicForPost(localComment.PostID)
 	a.publishPayloadAsync(topic, payload, "COMMENT_UPDATE")
 	return nil
 }
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Blob bodies travel over a direct stream to the requester only. A request is
+// one JSON line; the response is one JSON header line followed by the raw
+// bytes from the requested offset to the end of the blob, written in chunks.
+// An interrupted transfer keeps its prefix so the next attempt, on any peer,
+// resumes from that offset; the assembled blob must hash to the requested CID.
+const blobProtocolID = protocol.ID("/aegis/blob/1.0.0")
+
+const (
+	blobKindContent = "content"
+	blobKindMedia   = "media"
+
+	blobChunkSize        = 64 * 1024
+	blobMaxHeaderBytes   = 4 * 1024
+	blobMaxBytes         = 32 * 1024 * 1024
+	blobPartialTTL       = 10 * time.Minute
+	blobPartialMaxCount  = 64
+	blobStreamIdleWindow = 10 * time.Second
+
+	blobMismatchStrikeLimit = 2
+)
+
+var (
+	errBlobCIDMismatch   = errors.New("blob cid mismatch")
+	errBlobInvalidHeader = errors.New("invalid blob response header")
+)
+
+type blobRequest struct {
+	Kind   string `json:"kind"`
+	CID    string `json:"cid"`
+	Offset int64  `json:"offset"`
+}
+
+type blobResponseHeader struct {
+	Found       bool   `json:"found"`
+	Kind        string `json:"kind"`
+	CID         string `json:"cid"`
+	Size        int64  `json:"size"`
+	Offset      int64  `json:"offset"`
+	Mime        string `json:"mime,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	IsThumbnail bool   `json:"isThumbnail,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// blobPartial is the verified-later prefix of an interrupted transfer. Spans
+// record which peer sent which byte range, so a prefix that fails its CID
+// check can be blamed on the peers whose bytes were wrong.
+type blobPartial struct {
+	header    blobResponseHeader
+	data      []byte
+	spans     []blobSpan
+	updatedAt time.Time
+}
+
+// blobSpan is the byte range [Start, End) of a partial that Peer sent.
+type blobSpan struct {
+	Peer  string
+	Start int64
+	End   int64
+}
+
+// sources returns the peers that contributed to the partial.
+func (partial *blobPartial) sources() map[string]struct{} {
+	sources := make(map[string]struct{}, len(partial.spans))
+	for _, span := range partial.spans {
+		sources[span.Peer] = struct{}{}
+	}
+	return sources
+}
+
+func resolveBlobFetchPeerFanout() int {
+	raw := strings.TrimSpace(os.Getenv("AEGIS_BLOB_FETCH_PEERS"))
+	if raw != "" {
+		if value, err := strconv.Atoi(raw); err == nil && value > 0 && value <= 32 {
+			return value
+		}
+	}
+
+	return 6
+}
+
+func blobPartialKey(kind string, cid string) string {
+	return kind + ":" + cid
+}
+
+func (a *App) takeBlobPartial(kind string, cid string) *blobPartial {
+	a.blobPartialMu.Lock()
+	defer a.blobPartialMu.Unlock()
+
+	key := blobPartialKey(kind, cid)
+	partial, exists := a.blobPartials[key]
+	if !exists || time.Since(partial.updatedAt) > blobPartialTTL {
+		delete(a.blobPartials, key)
+		return &blobPartial{}
+	}
+	delete(a.blobPartials, key)
+	return partial
+}
+
+func (a *App) keepBlobPartial(kind string, cid string, partial *blobPartial) {
+	if partial == nil || len(partial.data) == 0 {
+		return
+	}
+
+	a.blobPartialMu.Lock()
+	defer a.blobPartialMu.Unlock()
+
+	now := time.Now()
+	for key, item := range a.blobPartials {
+		if now.Sub(item.updatedAt) > blobPartialTTL {
+			delete(a.blobPartials, key)
+		}
+	}
+	if len(a.blobPartials) >= blobPartialMaxCount {
+		return
+	}
+	partial.updatedAt = now
+	a.blobPartials[blobPartialKey(kind, cid)] = partial
+}
+
+// blobFetchCandidates lists connected peers, those on the topic of subID first.
+func (a *App) blobFetchCandidates(subID string) []peer.ID {
+	a.p2pMu.Lock()
+	defer a.p2pMu.Unlock()
+
+	if a.p2pHost == nil {
+		return nil
+	}
+
+	connected := a.p2pHost.Network().Peers()
+	result := make([]peer.ID, 0, len(connected))
+	seen := make(map[peer.ID]struct{}, len(connected))
+	if handle, exists := a.p2pSubTopics[normalizeSubID(subID)]; exists && strings.TrimSpace(subID) != "" {
+		for _, candidate := range handle.topic.ListPeers() {
+			if a.p2pHost.Network().Connectedness(candidate) != network.Connected {
+				continue
+			}
+			seen[candidate] = struct{}{}
+			result = append(result, candidate)
+		}
+	}
+	for _, candidate := range connected {
+		if _, exists := seen[candidate]; exists {
+			continue
+		}
+		result = append(result, candidate)
+	}
+	return result
+}
+
+// fetchBlobFromPeers asks peers in turn for a blob until one delivers bytes
+// that hash to cid. It returns the response header and the verified data.
+func (a *App) fetchBlobFromPeers(kind string, cid string, timeout time.Duration) (blobResponseHeader, []byte, error) {
+	noPeers := errContentFetchNoPeers
+	if kind == blobKindMedia {
+		noPeers = errMediaFetchNoPeers
+	}
+
+	a.p2pMu.Lock()
+	host := a.p2pHost
+	baseCtx := a.p2pCtx
+	a.p2pMu.Unlock()
+	if host == nil || baseCtx == nil {
+		return blobResponseHeader{}, nil, errors.New("p2p not started")
+	}
+
+	candidates := a.blobFetchCandidates(a.lookupBlobSubID(cid))
+	if len(candidates) == 0 {
+		return blobResponseHeader{}, nil, noPeers
+	}
+	if fanout := resolveBlobFetchPeerFanout(); len(candidates) > fanout {
+		candidates = candidates[:fanout]
+	}
+
+	ctx, cancel := context.WithTimeout(baseCtx, timeout)
+	defer cancel()
+	return a.fetchBlobFromCandidates(ctx, kind, cid, candidates, a.readBlobFromPeer)
+}
+
+// blobPeerReader streams the rest of a blob from one peer into partial, as
+// readBlobFromPeer does.
+type blobPeerReader func(ctx context.Context, remote peer.ID, kind string, cid string, partial *blobPartial) (bool, error)
+
+// fetchBlobFromCandidates reads the blob from candidates in order, resuming
+// any kept partial. When the bytes fail the CID check the transfer restarts
+// from scratch on the next candidate. A sole source is greylisted at once;
+// the sources of a resumed transfer are judged against the verified copy if
+// one arrives, and share the blame otherwise.
+func (a *App) fetchBlobFromCandidates(ctx context.Context, kind string, cid string, candidates []peer.ID, read blobPeerReader) (blobResponseHeader, []byte, error) {
+	notFound, timedOut := errContentFetchNotFound, errContentFetchTimeout
+	if kind == blobKindMedia {
+		notFound, timedOut = errMediaFetchNotFound, errMediaFetchTimeout
+	}
+
+	partial := a.takeBlobPartial(kind, cid)
+	defer func() {
+		a.keepBlobPartial(kind, cid, partial)
+	}()
+
+	notFoundCount := 0
+	var mismatch error
+	suspects := make([]*blobPartial, 0)
+	defer func() {
+		for _, suspect := range suspects {
+			a.noteBlobCIDMismatch(suspect.sources())
+		}
+	}()
+	for _, candidate := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		if blocked, _ := a.isPeerBlocked(candidate.String()); blocked {
+			continue
+		}
+
+		found, err := read(ctx, candidate, kind, cid, partial)
+		if err != nil {
+			if a.ctx != nil {
+				runtime.LogDebugf(a.ctx, "blob_fetch.peer_failed kind=%s cid=%s peer=%s offset=%d err=%v", kind, cid, candidate.String(), len(partial.data), err)
+			}
+			continue
+		}
+		if !found {
+			notFoundCount++
+			continue
+		}
+
+		if err = verifyBlobCID(kind, cid, partial.data); err != nil {
+			if a.ctx != nil {
+				runtime.LogWarningf(a.ctx, "blob_fetch.cid_mismatch kind=%s cid=%s peer=%s spans=%d", kind, cid, candidate.String(), len(partial.spans))
+			}
+			if sources := partial.sources(); len(sources) == 1 {
+				a.noteBlobCIDMismatch(sources)
+			} else {
+				suspects = append(suspects, partial)
+			}
+			partial = &blobPartial{}
+			mismatch = err
+			continue
+		}
+
+		header, data := partial.header, partial.data
+		for _, suspect := range suspects {
+			a.blameBlobSpans(suspect, data)
+		}
+		suspects = nil
+		partial = nil
+		return header, data, nil
+	}
+
+	if mismatch != nil {
+		return blobResponseHeader{}, nil, mismatch
+	}
+	if notFoundCount > 0 && notFoundCount == len(candidates) {
+		return blobResponseHeader{}, nil, notFound
+	}
+	return blobResponseHeader{}, nil, timedOut
+}
+
+// readBlobFromPeer streams the rest of a blob from one peer into partial.
+// It reports false without error when the peer does not have the blob.
+func (a *App) readBlobFromPeer(ctx context.Context, remote peer.ID, kind string, cid string, partial *blobPartial) (bool, error) {
+	a.p2pMu.Lock()
+	host := a.p2pHost
+	a.p2pMu.Unlock()
+	if host == nil {
+		return false, errors.New("p2p not started")
+	}
+
+	stream, err := host.NewStream(network.WithAllowLimitedConn(ctx, "blob"), remote, blobProtocolID)
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+
+	request := blobRequest{Kind: kind, CID: cid, Offset: int64(len(partial.data))}
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+	if _, err = stream.Write(append(encoded, '\n')); err != nil {
+		_ = stream.Reset()
+		return false, err
+	}
+	if err = stream.CloseWrite(); err != nil {
+		_ = stream.Reset()
+		return false, err
+	}
+
+	reader := bufio.NewReader(stream)
+	line, err := readBlobLine(reader)
+	if err != nil {
+		_ = stream.Reset()
+		return false, err
+	}
+	var header blobResponseHeader
+	if err = json.Unmarshal(line, &header); err != nil {
+		_ = stream.Reset()
+		return false, errBlobInvalidHeader
+	}
+	if !header.Found {
+		return false, nil
+	}
+	if header.CID != cid || header.Kind != kind || header.Offset != request.Offset || header.Size <= 0 || header.Size > blobMaxBytes || header.Offset > header.Size {
+		_ = stream.Reset()
+		return false, errBlobInvalidHeader
+	}
+	if len(partial.data) > 0 && partial.header.Size != header.Size {
+		partial.data = nil
+		partial.spans = nil
+		_ = stream.Reset()
+		return false, errBlobInvalidHeader
+	}
+	partial.header = header
+	partial.spans = append(partial.spans, blobSpan{Peer: remote.String(), Start: int64(len(partial.data)), End: int64(len(partial.data))})
+	span := &partial.spans[len(partial.spans)-1]
+
+	chunk := make([]byte, blobChunkSize)
+	for int64(len(partial.data)) < header.Size {
+		want := header.Size - int64(len(partial.data))
+		if want > blobChunkSize {
+			want = blobChunkSize
+		}
+		read, readErr := io.ReadFull(reader, chunk[:want])
+		partial.data = append(partial.data, chunk[:read]...)
+		span.End = int64(len(partial.data))
+		if readErr != nil {
+			_ = stream.Reset()
+			return false, readErr
+		}
+	}
+	return true, nil
+}
+
+func readBlobLine(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > blobMaxHeaderBytes {
+		return nil, errBlobInvalidHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+func verifyBlobCID(kind string, cid string, data []byte) error {
+	var actual string
+	if kind == blobKindMedia {
+		actual = buildBinaryCID(data)
+	} else {
+		actual = buildContentCID(string(data))
+	}
+	if actual != strings.TrimSpace(cid) {
+		return errBlobCIDMismatch
+	}
+	return nil
+}
+
+// blameBlobSpans greylists the peers whose spans of a partial that failed its
+// CID check differ from the verified blob. Peers that sent correct bytes are
+// not charged. When the sizes differ, every source agreed on the wrong size
+// and all of them are greylisted.
+func (a *App) blameBlobSpans(suspect *blobPartial, verified []byte) {
+	for _, span := range suspect.spans {
+		if len(suspect.data) == len(verified) && span.End <= int64(len(verified)) &&
+			string(suspect.data[span.Start:span.End]) == string(verified[span.Start:span.End]) {
+			continue
+		}
+		a.markPeerGreylisted(span.Peer, "blob-cid-mismatch")
+	}
+}
+
+// noteBlobCIDMismatch charges the peers that delivered a blob failing its CID
+// check when there is no verified copy to tell them apart. A sole source is
+// greylisted at once; peers that only contributed to a resumed transfer share
+// the blame and are greylisted on a repeat.
+func (a *App) noteBlobCIDMismatch(sources map[string]struct{}) {
+	if len(sources) == 1 {
+		for peerID := range sources {
+			a.markPeerGreylisted(peerID, "blob-cid-mismatch")
+		}
+		return
+	}
+
+	greylist := make([]string, 0, len(sources))
+	a.peerPolicyMu.Lock()
+	if a.blobMismatchStrikes == nil {
+		a.blobMismatchStrikes = make(map[string]int)
+	}
+	for peerID := range sources {
+		a.blobMismatchStrikes[peerID]++
+		if a.blobMismatchStrikes[peerID] >= blobMismatchStrikeLimit {
+			delete(a.blobMismatchStrikes, peerID)
+			greylist = append(greylist, peerID)
+		}
+	}
+	a.peerPolicyMu.Unlock()
+
+	for _, peerID := range greylist {
+		a.markPeerGreylisted(peerID, "blob-cid-mismatch")
+	}
+}
+
+func (a *App) handleBlobStream(stream network.Stream) {
+	defer stream.Close()
+
+	remote := stream.Conn().RemotePeer().String()
+	if blocked, _ := a.isPeerBlocked(remote); blocked {
+		_ = stream.Reset()
+		return
+	}
+	_ = stream.SetReadDeadline(time.Now().Add(blobStreamIdleWindow))
+
+	line, err := readBlobLine(bufio.NewReaderSize(stream, blobMaxHeaderBytes))
+	if err != nil {
+		_ = stream.Reset()
+		return
+	}
+	var request blobRequest
+	if err = json.Unmarshal(line, &request); err != nil {
+		_ = stream.Reset()
+		return
+	}
+	request.Kind = strings.ToLower(strings.TrimSpace(request.Kind))
+	request.CID = strings.TrimSpace(request.CID)
+	if (request.Kind != blobKindContent && request.Kind != blobKindMedia) || request.CID == "" || request.Offset < 0 {
+		_ = stream.Reset()
+		return
+	}
+	if !a.allowFetchRequest(remote, request.Kind) {
+		_ = stream.Reset()
+		return
+	}
+
+	header, data := a.loadServableBlob(request.Kind, request.CID)
+	if header.Found && request.Offset > header.Size {
+		header = blobResponseHeader{Kind: request.Kind, CID: request.CID, Error: "offset out of range"}
+	}
+	header.Offset = request.Offset
+
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		_ = stream.Reset()
+		return
+	}
+	_ = stream.SetWriteDeadline(time.Now().Add(blobStreamIdleWindow))
+	if _, err = stream.Write(append(encoded, '\n')); err != nil || !header.Found {
+		return
+	}
+
+	sent := int64(0)
+	for offset := request.Offset; offset < header.Size; offset += blobChunkSize {
+		end := offset + blobChunkSize
+		if end > header.Size {
+			end = header.Size
+		}
+		_ = stream.SetWriteDeadline(time.Now().Add(blobStreamIdleWindow))
+		if _, err = stream.Write(data[offset:end]); err != nil {
+			_ = stream.Reset()
+			return
+		}
+		sent += end - offset
+	}
+
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "blob_serve.sent kind=%s cid=%s requester=%s offset=%d bytes=%d", request.Kind, request.CID, remote, request.Offset, sent)
+	}
+}
+
+// loadServableBlob returns a local blob that policy allows sharing, or a
+// not-found header.
+func (a *App) loadServableBlob(kind string, cid string) (blobResponseHeader, []byte) {
+	header := blobResponseHeader{Kind: kind, CID: cid}
+
+	if kind == blobKindMedia {
+		shareable, err := a.canServeMediaBlobToNetwork(cid)
+		if err != nil || !shareable {
+			if err == nil && a.ctx != nil {
+				runtime.LogInfof(a.ctx, "media_fetch.policy_block cid=%s", cid)
+			}
+			return header, nil
+		}
+		media, raw, err := a.getMediaBlobRawLocal(cid)
+		if err != nil || len(raw) == 0 {
+			return header, nil
+		}
+		header.Found = true
+		header.Size = int64(len(raw))
+		header.Mime = media.Mime
+		header.Width = media.Width
+		header.Height = media.Height
+		header.IsThumbnail = media.IsThumbnail
+		return header, raw
+	}
+
+	shareable, err := a.canServeContentBlobToNetwork(cid)
+	if err != nil || !shareable {
+		if err == nil && a.ctx != nil {
+			runtime.LogInfof(a.ctx, "content_fetch.policy_block cid=%s", cid)
+		}
+		return header, nil
+	}
+	body, err := a.getContentBlobLocal(cid)
+	if err != nil || body.Body == "" {
+		return header, nil
+	}
+	header.Found = true
+	header.Size = int64(len(body.Body))
+	return header, []byte(body.Body)
+}
@@ -0,0 +1,938 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Anti-entropy runs as range-based set reconciliation over a direct stream.
+// Every domain (posts and comments of a sub, moderation, the favorites of an
+// identity) is a set of items ordered by (lamport, id), each carrying a short
+// hash of the version stored locally. The requester sends fingerprints of key
+// ranges; the responder skips ranges that match, lists the items of small
+// mismatched ranges and splits large ones into fingerprinted sub-ranges. Only
+// mismatched ranges are descended into, so the exchange grows with the size
+// of the difference instead of the set or a time window. Items found missing
+// or different are then fetched as the signed payloads the gossip sync
+// responses carry and applied through the same handlers. An item that still
+// differs once applied was rejected or is older than the local version; its
+// remote hash is remembered for reconcileSkipTTL so later rounds do not fetch
+// it again until the remote version changes.
+const reconcileProtocolID = protocol.ID("/aegis/reconcile/1.0.0")
+
+const (
+	reconcileDomainPosts      = "posts"
+	reconcileDomainComments   = "comments"
+	reconcileDomainModeration = "moderation"
+	reconcileDomainFavorites  = "favorites"
+
+	reconcileBranchFactor     = 16
+	reconcileLeafSize         = 32
+	reconcileMaxFrames        = 256
+	reconcileMaxRangesPerMsg  = 512
+	reconcileMaxSetsPerStream = 64
+	reconcileFetchBatchSize   = 200
+	reconcileMaxFrameBytes    = 8 * 1024 * 1024
+	reconcileStreamIdleWindow = 15 * time.Second
+	reconcileSessionTimeout   = 2 * time.Minute
+	reconcileSkipTTL          = 6 * time.Hour
+)
+
+var (
+	errReconcileNoPeers      = errors.New("no reconcile peers")
+	errReconcileInvalidFrame = errors.New("invalid reconcile frame")
+)
+
+type reconcileItem struct {
+	Lamport int64  `json:"l"`
+	ID      string `json:"i"`
+	Hash    string `json:"h"`
+}
+
+type reconcileBound struct {
+	Lamport int64  `json:"l"`
+	ID      string `json:"i"`
+}
+
+// reconcileRange covers the keys from From (inclusive) up to To (exclusive);
+// a nil bound is open. Leaf ranges carry every item the sender holds in them.
+type reconcileRange struct {
+	From        *reconcileBound `json:"from,omitempty"`
+	To          *reconcileBound `json:"to,omitempty"`
+	Count       int             `json:"count"`
+	Fingerprint string          `json:"fp,omitempty"`
+	Leaf        bool            `json:"leaf,omitempty"`
+	Items       []reconcileItem `json:"items,omitempty"`
+}
+
+// reconcileFrame is one newline-delimited JSON message of a session. The
+// requester sends either ranges to compare or item IDs to fetch; the responder
+// answers with the ranges still to descend into, or with the sync payload.
+type reconcileFrame struct {
+	Domain  string           `json:"domain"`
+	Scope   string           `json:"scope,omitempty"`
+	Ranges  []reconcileRange `json:"ranges,omitempty"`
+	Fetch   []string         `json:"fetch,omitempty"`
+	Payload *IncomingMessage `json:"payload,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// reconcileSet is a domain snapshot sorted by key, with a loader for the
+// sync payload of a subset of its items.
+type reconcileSet struct {
+	items []reconcileItem
+	fetch func(ids []string) (IncomingMessage, error)
+}
+
+// reconcileSkip is a remote item version that did not take locally.
+type reconcileSkip struct {
+	hash  string
+	until time.Time
+}
+
+type reconcileResult struct {
+	Ranges  int
+	Fetched int
+	Skipped int
+}
+
+func reconcileItemHash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+func reconcileFingerprint(items []reconcileItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	hasher := sha256.New()
+	for _, item := range items {
+		hasher.Write([]byte(item.Hash))
+	}
+	return hex.EncodeToString(hasher.Sum(nil)[:16])
+}
+
+func compareReconcileKey(lamport int64, id string, bound reconcileBound) int {
+	if lamport != bound.Lamport {
+		if lamport < bound.Lamport {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(id, bound.ID)
+}
+
+func sortReconcileItems(items []reconcileItem) {
+	sort.Slice(items, func(i int, j int) bool {
+		return compareReconcileKey(items[i].Lamport, items[i].ID, reconcileBound{Lamport: items[j].Lamport, ID: items[j].ID}) < 0
+	})
+}
+
+// itemsIn returns the items whose keys fall inside [from, to).
+func (s *reconcileSet) itemsIn(from *reconcileBound, to *reconcileBound) []reconcileItem {
+	start := 0
+	if from != nil {
+		start = sort.Search(len(s.items), func(i int) bool {
+			return compareReconcileKey(s.items[i].Lamport, s.items[i].ID, *from) >= 0
+		})
+	}
+	end := len(s.items)
+	if to != nil {
+		end = sort.Search(len(s.items), func(i int) bool {
+			return compareReconcileKey(s.items[i].Lamport, s.items[i].ID, *to) >= 0
+		})
+	}
+	if end < start {
+		end = start
+	}
+	return s.items[start:end]
+}
+
+// describeRange answers one mismatched range: the full item list when it is
+// small, otherwise fingerprints of up to reconcileBranchFactor sub-ranges
+// holding equal shares of the local items.
+func (s *reconcileSet) describeRange(from *reconcileBound, to *reconcileBound) []reconcileRange {
+	items := s.itemsIn(from, to)
+	if len(items) <= reconcileLeafSize {
+		return []reconcileRange{{From: from, To: to, Count: len(items), Fingerprint: reconcileFingerprint(items), Leaf: true, Items: items}}
+	}
+
+	step := (len(items) + reconcileBranchFactor - 1) / reconcileBranchFactor
+	result := make([]reconcileRange, 0, reconcileBranchFactor)
+	for start := 0; start < len(items); start += step {
+		end := start + step
+		if end > len(items) {
+			end = len(items)
+		}
+		part := reconcileRange{From: from, To: to, Count: end - start, Fingerprint: reconcileFingerprint(items[start:end])}
+		if start > 0 {
+			part.From = &reconcileBound{Lamport: items[start].Lamport, ID: items[start].ID}
+		}
+		if end < len(items) {
+			part.To = &reconcileBound{Lamport: items[end].Lamport, ID: items[end].ID}
+		}
+		result = append(result, part)
+	}
+	return result
+}
+
+// compareRanges checks the ranges a responder returned against the local set.
+// It returns the sub-ranges that still differ and the remote leaf items that
+// are absent locally or held in another version; seen drops repeats.
+func (s *reconcileSet) compareRanges(remote []reconcileRange, seen map[string]struct{}) ([]reconcileRange, []reconcileItem) {
+	next := make([]reconcileRange, 0, len(remote))
+	missing := make([]reconcileItem, 0)
+	for _, item := range remote {
+		mine := s.itemsIn(item.From, item.To)
+		if item.Leaf {
+			hashes := make(map[string]string, len(mine))
+			for _, local := range mine {
+				hashes[local.ID] = local.Hash
+			}
+			for _, candidate := range item.Items {
+				if candidate.ID == "" || len(candidate.ID) > 256 || hashes[candidate.ID] == candidate.Hash {
+					continue
+				}
+				if _, exists := seen[candidate.ID]; exists {
+					continue
+				}
+				seen[candidate.ID] = struct{}{}
+				missing = append(missing, candidate)
+			}
+			continue
+		}
+		if len(mine) == item.Count && reconcileFingerprint(mine) == item.Fingerprint {
+			continue
+		}
+		next = append(next, reconcileRange{From: item.From, To: item.To, Count: len(mine), Fingerprint: reconcileFingerprint(mine)})
+	}
+	return next, missing
+}
+
+func normalizeReconcileScope(domain string, scope string) (string, string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	scope = strings.TrimSpace(scope)
+	switch domain {
+	case reconcileDomainPosts, reconcileDomainComments:
+		if scope == "" {
+			return "", "", errReconcileInvalidFrame
+		}
+		return domain, normalizeSubID(scope), nil
+	case reconcileDomainModeration:
+		return domain, "", nil
+	case reconcileDomainFavorites:
+		if scope == "" {
+			return "", "", errReconcileInvalidFrame
+		}
+		return domain, scope, nil
+	}
+	return "", "", errReconcileInvalidFrame
+}
+
+func reconcilePayloadType(domain string) string {
+	switch domain {
+	case reconcileDomainPosts:
+		return messageTypeSyncSummaryResponse
+	case reconcileDomainComments:
+		return messageTypeCommentSyncResponse
+	case reconcileDomainModeration:
+		return messageTypeGovernanceSyncResponse
+	case reconcileDomainFavorites:
+		return messageTypeFavoriteSyncResponse
+	}
+	return ""
+}
+
+func (a *App) loadReconcileSet(domain string, scope string) (*reconcileSet, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	switch domain {
+	case reconcileDomainPosts:
+		return a.loadPostReconcileSet(scope)
+	case reconcileDomainComments:
+		return a.loadCommentReconcileSet(scope)
+	case reconcileDomainModeration:
+		return a.loadModerationReconcileSet()
+	case reconcileDomainFavorites:
+		return a.loadFavoriteReconcileSet(scope)
+	}
+	return nil, errReconcileInvalidFrame
+}
+
+func (a *App) loadPostReconcileSet(subID string) (*reconcileSet, error) {
+	policy, err := a.GetGovernancePolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := a.db.Query(`
+		SELECT m.id, m.current_op_id, m.lamport, m.visibility
+		FROM messages m
+		LEFT JOIN moderation mo ON mo.target_pubkey = m.pubkey
+		WHERE m.zone = 'public' AND m.sub_id = ?
+		  AND `+shadowBanVisibleClause(policy, "m")+`;
+	`, subID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := &reconcileSet{items: make([]reconcileItem, 0)}
+	for rows.Next() {
+		var item reconcileItem
+		var opID, visibility string
+		if err = rows.Scan(&item.ID, &opID, &item.Lamport, &visibility); err != nil {
+			return nil, err
+		}
+		deleted := strings.EqualFold(strings.TrimSpace(visibility), "deleted")
+		item.Hash = reconcileItemHash(item.ID, opID, strconv.FormatInt(item.Lamport, 10), strconv.FormatBool(deleted))
+		set.items = append(set.items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	sortReconcileItems(set.items)
+
+	set.fetch = func(ids []string) (IncomingMessage, error) {
+		digests, fetchErr := a.listPublicPostDigestsByIDs(ids)
+		if fetchErr != nil {
+			return IncomingMessage{}, fetchErr
+		}
+		return IncomingMessage{Type: messageTypeSyncSummaryResponse, SubID: subID, Summaries: digests}, nil
+	}
+	return set, nil
+}
+
+func (a *App) loadCommentReconcileSet(subID string) (*reconcileSet, error) {
+	policy, err := a.GetGovernancePolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := a.db.Query(`
+		SELECT c.id, c.current_op_id, c.lamport, c.deleted_at
+		FROM comments c
+		JOIN messages m ON m.id = c.post_id
+		LEFT JOIN moderation mo ON mo.target_pubkey = c.pubkey
+		WHERE m.zone = 'public' AND m.sub_id = ?
+		  AND `+shadowBanVisibleClause(policy, "c")+`;
+	`, subID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := &reconcileSet{items: make([]reconcileItem, 0)}
+	for rows.Next() {
+		var item reconcileItem
+		var opID string
+		var deletedAt int64
+		if err = rows.Scan(&item.ID, &opID, &item.Lamport, &deletedAt); err != nil {
+			return nil, err
+		}
+		item.Hash = reconcileItemHash(item.ID, opID, strconv.FormatInt(item.Lamport, 10), strconv.FormatBool(deletedAt > 0))
+		set.items = append(set.items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	sortReconcileItems(set.items)
+
+	set.fetch = func(ids []string) (IncomingMessage, error) {
+		digests, fetchErr := a.listPublicCommentDigestsByIDs(ids)
+		if fetchErr != nil {
+			return IncomingMessage{}, fetchErr
+		}
+		return IncomingMessage{Type: messageTypeCommentSyncResponse, SubID: subID, CommentSummaries: digests}, nil
+	}
+	return set, nil
+}
+
+// loadModerationReconcileSet covers moderation states, applied moderation logs
+// and admin delegations. Logs have no network-wide ID, so theirs is derived
+// from the fields insertModerationLogIfAbsent deduplicates on. Logs that a
+// later state replaced are left out past the op retention horizon, where
+// compactOpLogs drops them.
+func (a *App) loadModerationReconcileSet() (*reconcileSet, error) {
+	states := make(map[string]ModerationState)
+	logs := make(map[string]ModerationLog)
+	delegations := make(map[string]AdminDelegation)
+	set := &reconcileSet{items: make([]reconcileItem, 0)}
+
+	stateRows, err := a.db.Query(`
+		SELECT target_pubkey, action, source_admin, timestamp, lamport, reason, signature
+		FROM moderation;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for stateRows.Next() {
+		var row ModerationState
+		if err = stateRows.Scan(&row.TargetPubkey, &row.Action, &row.SourceAdmin, &row.Timestamp, &row.Lamport, &row.Reason, &row.Signature); err != nil {
+			stateRows.Close()
+			return nil, err
+		}
+		id := "state:" + row.TargetPubkey
+		states[id] = row
+		set.items = append(set.items, reconcileItem{
+			Lamport: row.Lamport,
+			ID:      id,
+			Hash:    reconcileItemHash(id, strings.ToUpper(row.Action), row.SourceAdmin, strconv.FormatInt(row.Lamport, 10), strconv.FormatInt(row.Timestamp, 10)),
+		})
+	}
+	err = stateRows.Err()
+	stateRows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	logRows, err := a.db.Query(`
+		SELECT id, target_pubkey, action, source_admin, timestamp, lamport, reason, result, signature
+		FROM moderation_logs
+		WHERE result = 'applied'
+		  AND (
+			timestamp >= ?
+			OR NOT EXISTS (
+				SELECT 1 FROM moderation s
+				WHERE s.target_pubkey = moderation_logs.target_pubkey AND s.timestamp > moderation_logs.timestamp
+			)
+		  );
+	`, maintenanceOpCutoff(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	for logRows.Next() {
+		var row ModerationLog
+		if err = logRows.Scan(&row.ID, &row.TargetPubkey, &row.Action, &row.SourceAdmin, &row.Timestamp, &row.Lamport, &row.Reason, &row.Result, &row.Signature); err != nil {
+			logRows.Close()
+			return nil, err
+		}
+		id := "log:" + reconcileItemHash(row.TargetPubkey, row.Action, row.SourceAdmin, strconv.FormatInt(row.Timestamp, 10), row.Reason, row.Result)
+		logs[id] = row
+		set.items = append(set.items, reconcileItem{Lamport: row.Lamport, ID: id, Hash: reconcileItemHash(id)})
+	}
+	err = logRows.Err()
+	logRows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	delegationRows, err := a.listAdminDelegations(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range delegationRows {
+		id := "delegation:" + row.OpID
+		delegations[id] = row
+		set.items = append(set.items, reconcileItem{Lamport: row.Lamport, ID: id, Hash: reconcileItemHash(id)})
+	}
+	sortReconcileItems(set.items)
+
+	set.fetch = func(ids []string) (IncomingMessage, error) {
+		payload := IncomingMessage{Type: messageTypeGovernanceSyncResponse}
+		for _, id := range ids {
+			if row, exists := states[id]; exists {
+				payload.GovernanceStates = append(payload.GovernanceStates, row)
+			} else if row, exists := logs[id]; exists {
+				payload.GovernanceLogs = append(payload.GovernanceLogs, row)
+			} else if row, exists := delegations[id]; exists {
+				payload.GovernanceDelegations = append(payload.GovernanceDelegations, row)
+			}
+		}
+		return payload, nil
+	}
+	return set, nil
+}
+
+// loadFavoriteReconcileSet covers the favorite ops of pubkey. Ops that are no
+// longer the last op of their favorite are left out past the op retention
+// horizon, where compactOpLogs drops them.
+func (a *App) loadFavoriteReconcileSet(pubkey string) (*reconcileSet, error) {
+	rows, err := a.db.Query(`
+		SELECT op_id, pubkey, post_id, op, created_at, signature
+		FROM post_favorite_ops
+		WHERE pubkey = ?
+		  AND (
+			created_at >= ?
+			OR NOT EXISTS (
+				SELECT 1 FROM post_favorites_state s
+				WHERE s.pubkey = post_favorite_ops.pubkey
+				  AND s.post_id = post_favorite_ops.post_id
+				  AND s.last_op_id <> post_favorite_ops.op_id
+			)
+		  );
+	`, pubkey, maintenanceOpCutoff(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(map[string]FavoriteOpRecord)
+	set := &reconcileSet{items: make([]reconcileItem, 0)}
+	for rows.Next() {
+		var record FavoriteOpRecord
+		if err = rows.Scan(&record.OpID, &record.Pubkey, &record.PostID, &record.Op, &record.CreatedAt, &record.Signature); err != nil {
+			return nil, err
+		}
+		records[record.OpID] = record
+		set.items = append(set.items, reconcileItem{
+			Lamport: record.CreatedAt,
+			ID:      record.OpID,
+			Hash:    reconcileItemHash(record.OpID, record.PostID, record.Op),
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	sortReconcileItems(set.items)
+
+	set.fetch = func(ids []string) (IncomingMessage, error) {
+		payload := IncomingMessage{Type: messageTypeFavoriteSyncResponse, Pubkey: pubkey}
+		for _, id := range ids {
+			if record, exists := records[id]; exists {
+				payload.FavoriteOps = append(payload.FavoriteOps, record)
+			}
+		}
+		return payload, nil
+	}
+	return set, nil
+}
+
+func readReconcileFrame(reader *bufio.Reader, frame *reconcileFrame) error {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > reconcileMaxFrameBytes {
+			return errReconcileInvalidFrame
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		break
+	}
+	if err := json.Unmarshal(line, frame); err != nil {
+		return errReconcileInvalidFrame
+	}
+	return nil
+}
+
+func writeReconcileFrame(stream network.Stream, frame reconcileFrame) error {
+	encoded, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	_ = stream.SetWriteDeadline(time.Now().Add(reconcileStreamIdleWindow))
+	_, err = stream.Write(append(encoded, '\n'))
+	return err
+}
+
+func (a *App) handleReconcileStream(stream network.Stream) {
+	defer stream.Close()
+
+	remote := stream.Conn().RemotePeer().String()
+	if blocked, _ := a.isPeerBlocked(remote); blocked {
+		_ = stream.Reset()
+		return
+	}
+	if !a.allowFetchRequest(remote, "reconcile") {
+		_ = stream.Reset()
+		return
+	}
+
+	localPeerID := stream.Conn().LocalPeer().String()
+	reader := bufio.NewReader(stream)
+	sets := make(map[string]*reconcileSet)
+	sessionDeadline := time.Now().Add(reconcileSessionTimeout)
+	ranges, fetched := 0, 0
+	for frames := 0; frames < reconcileMaxFrames; frames++ {
+		readDeadline := time.Now().Add(reconcileStreamIdleWindow)
+		if readDeadline.After(sessionDeadline) {
+			readDeadline = sessionDeadline
+		}
+		_ = stream.SetReadDeadline(readDeadline)
+
+		var request reconcileFrame
+		if err := readReconcileFrame(reader, &request); err != nil {
+			if !errors.Is(err, io.EOF) {
+				_ = stream.Reset()
+			}
+			break
+		}
+		ranges += len(request.Ranges)
+		fetched += len(request.Fetch)
+
+		response := a.serveReconcileFrame(localPeerID, sets, request)
+		if err := writeReconcileFrame(stream, response); err != nil {
+			_ = stream.Reset()
+			return
+		}
+	}
+
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "anti_entropy.reconcile_served requester=%s sets=%d ranges=%d fetched=%d", remote, len(sets), ranges, fetched)
+	}
+}
+
+func (a *App) serveReconcileFrame(localPeerID string, sets map[string]*reconcileSet, request reconcileFrame) reconcileFrame {
+	domain, scope, err := normalizeReconcileScope(request.Domain, request.Scope)
+	if err != nil {
+		return reconcileFrame{Domain: request.Domain, Scope: request.Scope, Error: err.Error()}
+	}
+	response := reconcileFrame{Domain: domain, Scope: scope}
+
+	// Favorites are private to their owner: only the node holding that
+	// identity answers for them, as with FAVORITE_SYNC_REQUEST.
+	if domain == reconcileDomainFavorites {
+		identity, identityErr := a.getLocalIdentity()
+		if identityErr != nil || strings.TrimSpace(identity.PublicKey) != scope {
+			response.Error = "scope not served"
+			return response
+		}
+	}
+
+	key := domain + "/" + scope
+	set, exists := sets[key]
+	if !exists {
+		if len(sets) >= reconcileMaxSetsPerStream {
+			response.Error = "too many sets"
+			return response
+		}
+		set, err = a.loadReconcileSet(domain, scope)
+		if err != nil {
+			response.Error = err.Error()
+			return response
+		}
+		sets[key] = set
+	}
+
+	if len(request.Fetch) > 0 {
+		ids := request.Fetch
+		if len(ids) > reconcileFetchBatchSize {
+			ids = ids[:reconcileFetchBatchSize]
+		}
+		payload, fetchErr := set.fetch(ids)
+		if fetchErr != nil {
+			response.Error = fetchErr.Error()
+			return response
+		}
+		payload.ResponderPeerID = localPeerID
+		payload.Timestamp = time.Now().Unix()
+		response.Payload = &payload
+		return response
+	}
+
+	requested := request.Ranges
+	if len(requested) > reconcileMaxRangesPerMsg {
+		requested = requested[:reconcileMaxRangesPerMsg]
+	}
+	for _, item := range requested {
+		local := set.itemsIn(item.From, item.To)
+		if len(local) == item.Count && reconcileFingerprint(local) == item.Fingerprint {
+			continue
+		}
+		response.Ranges = append(response.Ranges, set.describeRange(item.From, item.To)...)
+	}
+	return response
+}
+
+// reconcileSession is the requesting side of one stream.
+type reconcileSession struct {
+	stream network.Stream
+	reader *bufio.Reader
+}
+
+func (s *reconcileSession) exchange(request reconcileFrame) (reconcileFrame, error) {
+	if err := writeReconcileFrame(s.stream, request); err != nil {
+		return reconcileFrame{}, err
+	}
+	_ = s.stream.SetReadDeadline(time.Now().Add(reconcileStreamIdleWindow))
+
+	var response reconcileFrame
+	if err := readReconcileFrame(s.reader, &response); err != nil {
+		return reconcileFrame{}, err
+	}
+	if response.Error != "" {
+		return reconcileFrame{}, errors.New(response.Error)
+	}
+	if response.Domain != request.Domain || response.Scope != request.Scope {
+		return reconcileFrame{}, errReconcileInvalidFrame
+	}
+	return response, nil
+}
+
+// reconcileDomain narrows down the items of one domain that the remote holds
+// in a version this node lacks, then fetches and applies them.
+func (a *App) reconcileDomain(session *reconcileSession, localPeerID string, domain string, scope string) (reconcileResult, error) {
+	result := reconcileResult{}
+	local, err := a.loadReconcileSet(domain, scope)
+	if err != nil {
+		return result, err
+	}
+
+	pending := []reconcileRange{{Count: len(local.items), Fingerprint: reconcileFingerprint(local.items)}}
+	missing := make([]reconcileItem, 0)
+	seen := make(map[string]struct{})
+	for frames := 0; len(pending) > 0 && frames < reconcileMaxFrames; frames++ {
+		batch := pending
+		if len(batch) > reconcileMaxRangesPerMsg {
+			batch = batch[:reconcileMaxRangesPerMsg]
+		}
+		pending = pending[len(batch):]
+		result.Ranges += len(batch)
+
+		response, exchangeErr := session.exchange(reconcileFrame{Domain: domain, Scope: scope, Ranges: batch})
+		if exchangeErr != nil {
+			return result, exchangeErr
+		}
+
+		next, found := local.compareRanges(response.Ranges, seen)
+		missing = append(missing, found...)
+		pending = append(pending, next...)
+	}
+
+	found := len(missing)
+	missing = a.withoutReconcileSkips(domain, scope, missing)
+	result.Skipped = found - len(missing)
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	// Post digests past the index budget are dropped unapplied, so a batch
+	// never asks for more than one response can index.
+	batchSize := reconcileFetchBatchSize
+	if budget := resolveAntiEntropyIndexInsertBudget(); domain == reconcileDomainPosts && budget < batchSize {
+		batchSize = budget
+	}
+	for start := 0; start < len(missing); start += batchSize {
+		end := start + batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		ids := make([]string, 0, end-start)
+		for _, item := range missing[start:end] {
+			ids = append(ids, item.ID)
+		}
+		response, exchangeErr := session.exchange(reconcileFrame{Domain: domain, Scope: scope, Fetch: ids})
+		if exchangeErr != nil {
+			return result, exchangeErr
+		}
+		if response.Payload == nil || response.Payload.Type != reconcilePayloadType(domain) {
+			return result, errReconcileInvalidFrame
+		}
+		payload := *response.Payload
+		payload.RequesterPeerID = localPeerID
+		result.Fetched += len(payload.Summaries) + len(payload.CommentSummaries) + len(payload.GovernanceStates) +
+			len(payload.GovernanceLogs) + len(payload.GovernanceDelegations) + len(payload.FavoriteOps)
+		a.applyReconcilePayload(localPeerID, payload)
+	}
+
+	applied, err := a.loadReconcileSet(domain, scope)
+	if err != nil {
+		return result, err
+	}
+	a.recordReconcileSkips(domain, scope, missing, applied)
+	return result, nil
+}
+
+func reconcileSkipKey(domain string, scope string, id string) string {
+	return domain + "/" + scope + "/" + id
+}
+
+// withoutReconcileSkips drops the items whose remote version was fetched
+// before and did not take locally.
+func (a *App) withoutReconcileSkips(domain string, scope string, items []reconcileItem) []reconcileItem {
+	now := time.Now()
+	a.reconcileSkipMu.Lock()
+	defer a.reconcileSkipMu.Unlock()
+
+	kept := items[:0]
+	for _, item := range items {
+		skip, exists := a.reconcileSkips[reconcileSkipKey(domain, scope, item.ID)]
+		if exists && skip.hash == item.Hash && now.Before(skip.until) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+// recordReconcileSkips remembers the fetched items that local still holds in
+// another version, because they were rejected or are older than ours.
+func (a *App) recordReconcileSkips(domain string, scope string, fetched []reconcileItem, local *reconcileSet) {
+	hashes := make(map[string]string, len(local.items))
+	for _, item := range local.items {
+		hashes[item.ID] = item.Hash
+	}
+
+	now := time.Now()
+	a.reconcileSkipMu.Lock()
+	defer a.reconcileSkipMu.Unlock()
+	if a.reconcileSkips == nil {
+		a.reconcileSkips = make(map[string]reconcileSkip)
+	}
+	for key, skip := range a.reconcileSkips {
+		if !now.Before(skip.until) {
+			delete(a.reconcileSkips, key)
+		}
+	}
+	for _, item := range fetched {
+		key := reconcileSkipKey(domain, scope, item.ID)
+		if hashes[item.ID] == item.Hash {
+			delete(a.reconcileSkips, key)
+			continue
+		}
+		a.reconcileSkips[key] = reconcileSkip{hash: item.Hash, until: now.Add(reconcileSkipTTL)}
+	}
+}
+
+func (a *App) applyReconcilePayload(localPeerID string, payload IncomingMessage) {
+	switch payload.Type {
+	case messageTypeSyncSummaryResponse:
+		a.handleSyncSummaryResponse(localPeerID, payload)
+	case messageTypeCommentSyncResponse:
+		a.handleCommentSyncResponse(localPeerID, payload)
+	case messageTypeGovernanceSyncResponse:
+		a.handleGovernanceSyncResponse(localPeerID, payload)
+	case messageTypeFavoriteSyncResponse:
+		a.handleFavoriteSyncResponse(localPeerID, payload)
+	}
+}
+
+// reconcileCandidates lists connected, unblocked peers that speak the
+// reconcile protocol, in random order.
+func (a *App) reconcileCandidates() []peer.ID {
+	a.p2pMu.Lock()
+	host := a.p2pHost
+	a.p2pMu.Unlock()
+	if host == nil {
+		return nil
+	}
+
+	result := make([]peer.ID, 0)
+	for _, candidate := range host.Network().Peers() {
+		if blocked, _ := a.isPeerBlocked(candidate.String()); blocked {
+			continue
+		}
+		supported, err := host.Peerstore().SupportsProtocols(candidate, reconcileProtocolID)
+		if err != nil || len(supported) == 0 {
+			continue
+		}
+		result = append(result, candidate)
+	}
+	rand.Shuffle(len(result), func(i int, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	return result
+}
+
+// reconcileWithPeer runs one session against remote: moderation first so the
+// trusted admin set is current, then posts before comments for every followed
+// sub, then the favorites of the active identity.
+func (a *App) reconcileWithPeer(remote peer.ID) (reconcileResult, error) {
+	a.p2pMu.Lock()
+	host := a.p2pHost
+	baseCtx := a.p2pCtx
+	a.p2pMu.Unlock()
+	if host == nil || baseCtx == nil {
+		return reconcileResult{}, errors.New("p2p not started")
+	}
+	localPeerID := host.ID().String()
+
+	ctx, cancel := context.WithTimeout(baseCtx, reconcileSessionTimeout)
+	defer cancel()
+
+	stream, err := host.NewStream(network.WithAllowLimitedConn(ctx, "reconcile"), remote, reconcileProtocolID)
+	if err != nil {
+		return reconcileResult{}, err
+	}
+	defer stream.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+	session := &reconcileSession{stream: stream, reader: bufio.NewReader(stream)}
+
+	type domainScope struct{ domain, scope string }
+	plan := []domainScope{{domain: reconcileDomainModeration}}
+	for _, subID := range a.followedSubTopicIDs() {
+		plan = append(plan, domainScope{reconcileDomainPosts, subID}, domainScope{reconcileDomainComments, subID})
+	}
+	if pubkey := a.activeIdentityPubkey(); pubkey != "" {
+		plan = append(plan, domainScope{reconcileDomainFavorites, pubkey})
+	}
+
+	total := reconcileResult{}
+	for _, step := range plan {
+		result, stepErr := a.reconcileDomain(session, localPeerID, step.domain, step.scope)
+		total.Ranges += result.Ranges
+		total.Fetched += result.Fetched
+		total.Skipped += result.Skipped
+		if stepErr == nil {
+			continue
+		}
+		if step.domain == reconcileDomainFavorites && stepErr.Error() == "scope not served" {
+			continue
+		}
+		_ = stream.Reset()
+		return total, stepErr
+	}
+	_ = stream.CloseWrite()
+
+	a.updateAntiEntropyStats(func(stats *AntiEntropyStats) {
+		stats.ReconcileSessions++
+		stats.ReconcileRanges += int64(total.Ranges)
+		stats.ReconcileItemsFetched += int64(total.Fetched)
+		stats.LastReconcileAt = time.Now().Unix()
+	})
+	return total, nil
+}
+
+// runReconcileRound reconciles with one randomly chosen peer, moving on to the
+// next candidate when a session fails.
+func (a *App) runReconcileRound() error {
+	candidates := a.reconcileCandidates()
+	if len(candidates) == 0 {
+		return errReconcileNoPeers
+	}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		started := time.Now()
+		result, err := a.reconcileWithPeer(candidate)
+		if err != nil {
+			lastErr = err
+			if a.ctx != nil {
+				runtime.LogWarningf(a.ctx, "anti_entropy.reconcile_failed peer=%s ranges=%d fetched=%d err=%v", candidate.String(), result.Ranges, result.Fetched, err)
+			}
+			continue
+		}
+		if a.ctx != nil {
+			runtime.LogInfof(a.ctx, "anti_entropy.reconcile peer=%s ranges=%d fetched=%d skipped=%d duration_ms=%d", candidate.String(), result.Ranges, result.Fetched, result.Skipped, time.Since(started).Milliseconds())
+		}
+		return nil
+	}
+	return lastErr
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newReconcileTestSet(count int, skip map[int]bool, changed map[int]bool) *reconcileSet {
+	set := &reconcileSet{items: make([]reconcileItem, 0, count)}
+	for i := 0; i < count; i++ {
+		if skip[i] {
+			continue
+		}
+		id := fmt.Sprintf("post-%05d", i)
+		version := "v1"
+		if changed[i] {
+			version = "v2"
+		}
+		set.items = append(set.items, reconcileItem{Lamport: int64(i / 3), ID: id, Hash: reconcileItemHash(id, version)})
+	}
+	sortReconcileItems(set.items)
+	return set
+}
+
+// runReconcileRounds drives the range exchange between two in-memory sets the
+// way reconcileDomain does over a stream and returns the IDs to fetch along
+// with the number of ranges the requester sent.
+func runReconcileRounds(t *testing.T, local *reconcileSet, remote *reconcileSet) ([]string, int) {
+	t.Helper()
+
+	app := NewApp()
+	sets := map[string]*reconcileSet{reconcileDomainModeration + "/": remote}
+	pending := []reconcileRange{{Count: len(local.items), Fingerprint: reconcileFingerprint(local.items)}}
+	seen := make(map[string]struct{})
+	missing := make([]string, 0)
+	sent := 0
+	for frames := 0; len(pending) > 0; frames++ {
+		if frames >= reconcileMaxFrames {
+			t.Fatalf("reconcile did not converge")
+		}
+		sent += len(pending)
+		response := app.serveReconcileFrame("local", sets, reconcileFrame{Domain: reconcileDomainModeration, Ranges: pending})
+		if response.Error != "" {
+			t.Fatalf("serve frame: %s", response.Error)
+		}
+		var found []reconcileItem
+		pending, found = local.compareRanges(response.Ranges, seen)
+		for _, item := range found {
+			missing = append(missing, item.ID)
+		}
+	}
+	sort.Strings(missing)
+	return missing, sent
+}
+
+func TestReconcileFindsDifferenceOutsideAnyWindow(t *testing.T) {
+	remote := newReconcileTestSet(20000, nil, map[int]bool{12: true})
+	local := newReconcileTestSet(20000, map[int]bool{3: true, 19990: true}, nil)
+
+	missing, sent := runReconcileRounds(t, local, remote)
+	expected := []string{"post-00003", "post-00012", "post-19990"}
+	if fmt.Sprint(missing) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected missing items %v", missing)
+	}
+	if sent > 3*reconcileBranchFactor*4 {
+		t.Fatalf("exchange not proportional to difference, ranges sent=%d", sent)
+	}
+}
+
+func TestReconcileIdenticalSetsStopAfterOneRange(t *testing.T) {
+	missing, sent := runReconcileRounds(t, newReconcileTestSet(5000, nil, nil), newReconcileTestSet(5000, nil, nil))
+	if len(missing) != 0 || sent != 1 {
+		t.Fatalf("identical sets exchanged ranges=%d missing=%v", sent, missing)
+	}
+}
+
+func TestReconcileEmptyLocalSetFetchesEverything(t *testing.T) {
+	missing, _ := runReconcileRounds(t, &reconcileSet{}, newReconcileTestSet(1000, nil, nil))
+	if len(missing) != 1000 {
+		t.Fatalf("expected 1000 missing items, got %d", len(missing))
+	}
+}
+
+func reconcileSetIDs(set *reconcileSet) []string {
+	ids := make([]string, 0, len(set.items))
+	for _, item := range set.items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func reconcileSetHash(set *reconcileSet, id string) string {
+	for _, item := range set.items {
+		if item.ID == id {
+			return item.Hash
+		}
+	}
+	return ""
+}
+
+func TestPostReconcileSetCoversVisiblePublicPostsOfTheSub(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	if _, err := app.db.Exec(`INSERT INTO moderation (target_pubkey, action, source_admin, timestamp, lamport) VALUES ('mallory', 'SHADOW_BAN', 'admin', 5, 5);`); err != nil {
+		t.Fatalf("seed ban: %v", err)
+	}
+	for _, post := range []ForumMessage{
+		{ID: "p-2", Pubkey: "alice", OpID: "op-2", Title: "Post", Body: "Second.", Timestamp: 20, Lamport: 20, Zone: "public", SubID: "tech"},
+		{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Post", Body: "First.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "tech"},
+		{ID: "p-other", Pubkey: "alice", OpID: "op-3", Title: "Post", Body: "Elsewhere.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "art"},
+		{ID: "p-private", Pubkey: "alice", OpID: "op-4", Title: "Post", Body: "Private.", Timestamp: 10, Lamport: 10, Zone: "private", SubID: "tech"},
+		{ID: "p-banned", Pubkey: "mallory", OpID: "op-5", Title: "Post", Body: "Banned.", Timestamp: 30, Lamport: 30, Zone: "public", SubID: "tech"},
+	} {
+		if _, err := app.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+
+	set, err := app.loadPostReconcileSet("tech")
+	if err != nil {
+		t.Fatalf("load set: %v", err)
+	}
+	if got := fmt.Sprint(reconcileSetIDs(set)); got != "[p-1 p-2]" {
+		t.Fatalf("unexpected post set %s", got)
+	}
+	payload, err := set.fetch([]string{"p-2"})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if payload.Type != messageTypeSyncSummaryResponse || len(payload.Summaries) != 1 || payload.Summaries[0].ID != "p-2" {
+		t.Fatalf("unexpected fetch payload %+v", payload)
+	}
+
+	before := reconcileSetHash(set, "p-1")
+	if _, err = app.db.Exec(`UPDATE messages SET visibility = 'deleted' WHERE id = 'p-1';`); err != nil {
+		t.Fatalf("delete p-1: %v", err)
+	}
+	if set, err = app.loadPostReconcileSet("tech"); err != nil {
+		t.Fatalf("reload set: %v", err)
+	}
+	if reconcileSetHash(set, "p-1") == before {
+		t.Fatalf("deleting a post did not change its hash")
+	}
+}
+
+func TestCommentReconcileSetCoversCommentsOnPublicPostsOfTheSub(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	for _, post := range []ForumMessage{
+		{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Post", Body: "Public.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "tech"},
+		{ID: "p-private", Pubkey: "alice", OpID: "op-2", Title: "Post", Body: "Private.", Timestamp: 10, Lamport: 10, Zone: "private", SubID: "tech"},
+	} {
+		if _, err := app.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+	for _, statement := range []string{
+		`INSERT INTO comments (id, post_id, pubkey, current_op_id, body, timestamp, lamport) VALUES ('c-2', 'p-1', 'bob', 'cop-2', 'Second.', 12, 12);`,
+		`INSERT INTO comments (id, post_id, pubkey, current_op_id, body, timestamp, lamport) VALUES ('c-1', 'p-1', 'bob', 'cop-1', 'First.', 11, 11);`,
+		`INSERT INTO comments (id, post_id, pubkey, current_op_id, body, timestamp, lamport) VALUES ('c-private', 'p-private', 'bob', 'cop-3', 'Hidden.', 11, 11);`,
+	} {
+		if _, err := app.db.Exec(statement); err != nil {
+			t.Fatalf("seed comments: %v", err)
+		}
+	}
+
+	set, err := app.loadCommentReconcileSet("tech")
+	if err != nil {
+		t.Fatalf("load set: %v", err)
+	}
+	if got := fmt.Sprint(reconcileSetIDs(set)); got != "[c-1 c-2]" {
+		t.Fatalf("unexpected comment set %s", got)
+	}
+	payload, err := set.fetch([]string{"c-1"})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if payload.Type != messageTypeCommentSyncResponse || len(payload.CommentSummaries) != 1 || payload.CommentSummaries[0].ID != "c-1" {
+		t.Fatalf("unexpected fetch payload %+v", payload)
+	}
+
+	before := reconcileSetHash(set, "c-1")
+	if _, err = app.db.Exec(`UPDATE comments SET deleted_at = 20 WHERE id = 'c-1';`); err != nil {
+		t.Fatalf("delete c-1: %v", err)
+	}
+	if set, err = app.loadCommentReconcileSet("tech"); err != nil {
+		t.Fatalf("reload set: %v", err)
+	}
+	if reconcileSetHash(set, "c-1") == before {
+		t.Fatalf("deleting a comment did not change its hash")
+	}
+}
+
+func TestModerationReconcileSetLeavesOutRejectedAndCompactableLogs(t *testing.T) {
+	app := newTestApp(t)
+	old := maintenanceOpCutoff(time.Now()) - 100
+	recent := time.Now().Unix()
+	for _, statement := range []string{
+		fmt.Sprintf(`INSERT INTO moderation (target_pubkey, action, source_admin, timestamp, lamport) VALUES ('mallory', 'SHADOW_BAN', 'admin', %d, 3);`, recent),
+		fmt.Sprintf(`INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, result) VALUES ('mallory', 'SHADOW_BAN', 'admin', %d, 1, 'applied');`, old),
+		fmt.Sprintf(`INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, result) VALUES ('mallory', 'SHADOW_BAN', 'admin', %d, 3, 'applied');`, recent),
+		fmt.Sprintf(`INSERT INTO moderation_logs (target_pubkey, action, source_admin, timestamp, lamport, result) VALUES ('mallory', 'UNBAN', 'stranger', %d, 4, 'rejected');`, recent),
+	} {
+		if _, err := app.db.Exec(statement); err != nil {
+			t.Fatalf("seed moderation: %v", err)
+		}
+	}
+
+	set, err := app.loadModerationReconcileSet()
+	if err != nil {
+		t.Fatalf("load set: %v", err)
+	}
+	ids := reconcileSetIDs(set)
+	if len(ids) != 2 || reconcileSetHash(set, "state:mallory") == "" {
+		t.Fatalf("expected the state and the recent applied log, got %v", ids)
+	}
+	payload, err := set.fetch(ids)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(payload.GovernanceStates) != 1 || len(payload.GovernanceLogs) != 1 || payload.GovernanceLogs[0].Timestamp != recent {
+		t.Fatalf("unexpected fetch payload %+v", payload)
+	}
+}
+
+func TestFavoriteReconcileSetLeavesOutOtherIdentitiesAndCompactableOps(t *testing.T) {
+	app := newTestApp(t)
+	old := maintenanceOpCutoff(time.Now()) - 100
+	recent := time.Now().Unix()
+	for _, statement := range []string{
+		fmt.Sprintf(`INSERT INTO post_favorite_ops (op_id, pubkey, post_id, op, created_at) VALUES ('fav-old', 'me', 'p-1', 'ADD', %d);`, old),
+		fmt.Sprintf(`INSERT INTO post_favorite_ops (op_id, pubkey, post_id, op, created_at) VALUES ('fav-new', 'me', 'p-1', 'REMOVE', %d);`, recent),
+		fmt.Sprintf(`INSERT INTO post_favorite_ops (op_id, pubkey, post_id, op, created_at) VALUES ('fav-kept', 'me', 'p-2', 'ADD', %d);`, old),
+		fmt.Sprintf(`INSERT INTO post_favorite_ops (op_id, pubkey, post_id, op, created_at) VALUES ('fav-other', 'bob', 'p-1', 'ADD', %d);`, recent),
+		fmt.Sprintf(`INSERT INTO post_favorites_state (pubkey, post_id, state, updated_at, last_op_id) VALUES ('me', 'p-1', 'removed', %d, 'fav-new');`, recent),
+		fmt.Sprintf(`INSERT INTO post_favorites_state (pubkey, post_id, state, updated_at, last_op_id) VALUES ('me', 'p-2', 'active', %d, 'fav-kept');`, old),
+	} {
+		if _, err := app.db.Exec(statement); err != nil {
+			t.Fatalf("seed favorites: %v", err)
+		}
+	}
+
+	set, err := app.loadFavoriteReconcileSet("me")
+	if err != nil {
+		t.Fatalf("load set: %v", err)
+	}
+	if got := fmt.Sprint(reconcileSetIDs(set)); got != "[fav-kept fav-new]" {
+		t.Fatalf("unexpected favorite set %s", got)
+	}
+	payload, err := set.fetch([]string{"fav-new", "fav-other"})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if payload.Pubkey != "me" || len(payload.FavoriteOps) != 1 || payload.FavoriteOps[0].OpID != "fav-new" {
+		t.Fatalf("unexpected fetch payload %+v", payload)
+	}
+}
+
+func TestReconcileDoesNotRefetchRejectedItems(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	t.Setenv("AEGIS_JOIN_ALL_SUB_TOPICS", "0")
+	responder := newTestApp(t)
+	for _, post := range []ForumMessage{
+		{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Post", Body: "Accepted.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "tech"},
+		{ID: "p-banned", Pubkey: "mallory", OpID: "op-2", Title: "Post", Body: "Rejected.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "tech"},
+	} {
+		if _, err := responder.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+	requester := newTestApp(t)
+	if _, err := requester.db.Exec(`INSERT INTO moderation (target_pubkey, action, source_admin, timestamp, lamport) VALUES ('mallory', 'SHADOW_BAN', 'admin', 5, 5);`); err != nil {
+		t.Fatalf("seed ban: %v", err)
+	}
+
+	startTestP2P(t, responder)
+	responder.p2pHost.SetStreamHandler(reconcileProtocolID, responder.handleReconcileStream)
+	startTestP2P(t, requester)
+	if _, err := requester.SubscribeSub("tech"); err != nil {
+		t.Fatalf("follow tech: %v", err)
+	}
+	if err := requester.p2pHost.Connect(context.Background(), peer.AddrInfo{ID: responder.p2pHost.ID(), Addrs: responder.p2pHost.Addrs()}); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	first, err := requester.reconcileWithPeer(responder.p2pHost.ID())
+	if err != nil {
+		t.Fatalf("first reconcile: %v", err)
+	}
+	if first.Fetched != 2 || countRows(t, requester, `SELECT COUNT(1) FROM messages WHERE id = 'p-1';`) != 1 {
+		t.Fatalf("unexpected first round %+v", first)
+	}
+
+	// The banned post still differs, but its version is known not to take.
+	second, err := requester.reconcileWithPeer(responder.p2pHost.ID())
+	if err != nil {
+		t.Fatalf("second reconcile: %v", err)
+	}
+	if second.Fetched != 0 || second.Skipped != 1 {
+		t.Fatalf("rejected item fetched again: %+v", second)
+	}
+
+	// A new version of it is fetched again.
+	if _, err = responder.insertMessage(ForumMessage{ID: "p-banned", Pubkey: "mallory", OpID: "op-3", Title: "Post", Body: "Edited.", Timestamp: 20, Lamport: 20, Zone: "public", SubID: "tech"}); err != nil {
+		t.Fatalf("update p-banned: %v", err)
+	}
+	third, err := requester.reconcileWithPeer(responder.p2pHost.ID())
+	if err != nil {
+		t.Fatalf("third reconcile: %v", err)
+	}
+	if third.Fetched != 1 {
+		t.Fatalf("changed version was not fetched: %+v", third)
+	}
+}
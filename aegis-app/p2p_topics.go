@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Content traffic (posts, comments, votes, fetches and their sync) travels on
+// one gossip topic per sub. forumTopicName stays joined by every node as the
+// control topic for discovery, profiles, favorites and governance, and as
+// the fallback when the sub of an entity is not known locally.
+const subTopicPrefix = "aegis-forum-sub/"
+
+// subTopicPublishOnlyLimit caps the topics joined only to publish into subs
+// this node does not follow. The least recently used one is closed when
+// another is needed.
+const subTopicPublishOnlyLimit = 16
+
+// subTopic is a joined per-sub topic. sub is nil when the topic was joined
+// only to publish into a sub this node does not follow; usedAt is when such a
+// topic was last handed out. listenUntil is set while an unfollowed sub is
+// subscribed only to receive answers to a request.
+type subTopic struct {
+	topic       *pubsub.Topic
+	sub         *pubsub.Subscription
+	cancel      context.CancelFunc
+	usedAt      time.Time
+	listenUntil time.Time
+}
+
+func subTopicName(subID string) string {
+	return subTopicPrefix + normalizeSubID(subID)
+}
+
+// resolveJoinAllSubTopics reports whether this node follows every known sub,
+// which relays need to bridge communities. Defaults to the relay service flag.
+func resolveJoinAllSubTopics() bool {
+	raw := strings.TrimSpace(strings.ToLower(os.Getenv("AEGIS_JOIN_ALL_SUB_TOPICS")))
+	switch raw {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return resolveRelayServiceEnabled()
+}
+
+// listFollowedSubIDs returns the subs whose topics this node carries: the
+// default sub plus every sub followed by any local identity.
+func (a *App) listFollowedSubIDs() ([]string, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	query := `SELECT DISTINCT sub_id FROM sub_subscriptions;`
+	if resolveJoinAllSubTopics() {
+		query = `SELECT id FROM subs;`
+	}
+	rows, err := a.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := map[string]struct{}{defaultSubID: {}}
+	result := []string{defaultSubID}
+	for rows.Next() {
+		var subID string
+		if err = rows.Scan(&subID); err != nil {
+			return nil, err
+		}
+		subID = normalizeSubID(subID)
+		if _, exists := seen[subID]; exists {
+			continue
+		}
+		seen[subID] = struct{}{}
+		result = append(result, subID)
+	}
+	return result, rows.Err()
+}
+
+func (a *App) isSubFollowed(subID string) (bool, error) {
+	subID = normalizeSubID(subID)
+	if subID == defaultSubID || resolveJoinAllSubTopics() {
+		return true, nil
+	}
+	if a.db == nil {
+		return false, errors.New("database not initialized")
+	}
+
+	var count int
+	if err := a.db.QueryRow(`SELECT COUNT(1) FROM sub_subscriptions WHERE sub_id = ?;`, subID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// joinSubTopicLocked joins the topic of subID. With subscribe set, the topic
+// is also subscribed and its messages are consumed like the control topic.
+func (a *App) joinSubTopicLocked(subID string, subscribe bool) (*pubsub.Topic, error) {
+	if a.p2pPubsub == nil || a.p2pCtx == nil || a.p2pHost == nil {
+		return nil, errors.New("p2p not started")
+	}
+
+	subID = normalizeSubID(subID)
+	handle, exists := a.p2pSubTopics[subID]
+	if !exists {
+		topic, err := a.p2pPubsub.Join(subTopicName(subID))
+		if err != nil {
+			return nil, err
+		}
+		handle = &subTopic{topic: topic}
+		a.p2pSubTopics[subID] = handle
+	}
+	if !subscribe || handle.sub != nil {
+		return handle.topic, nil
+	}
+
+	subscription, err := handle.topic.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(a.p2pCtx)
+	handle.sub = subscription
+	handle.cancel = cancel
+	go a.consumeP2PMessages(ctx, a.p2pHost.ID(), subscription)
+
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "p2p.sub_topic joined sub_id=%s topic=%s", subID, subTopicName(subID))
+	}
+	return handle.topic, nil
+}
+
+func (a *App) leaveSubTopicLocked(subID string) error {
+	subID = normalizeSubID(subID)
+	handle, exists := a.p2pSubTopics[subID]
+	if !exists {
+		return nil
+	}
+	delete(a.p2pSubTopics, subID)
+
+	if handle.cancel != nil {
+		handle.cancel()
+	}
+	if handle.sub != nil {
+		handle.sub.Cancel()
+	}
+	if err := handle.topic.Close(); err != nil {
+		return err
+	}
+
+	if a.ctx != nil && handle.sub != nil {
+		runtime.LogInfof(a.ctx, "p2p.sub_topic left sub_id=%s topic=%s", subID, subTopicName(subID))
+	}
+	return nil
+}
+
+func (a *App) leaveAllSubTopicsLocked() error {
+	var firstErr error
+	for subID := range a.p2pSubTopics {
+		if err := a.leaveSubTopicLocked(subID); err != nil {
+			firstErr = errors.Join(firstErr, err)
+		}
+	}
+	return firstErr
+}
+
+func (a *App) joinFollowedSubTopicsLocked() {
+	subIDs, err := a.listFollowedSubIDs()
+	if err != nil {
+		if a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "p2p.sub_topic list failed: %v", err)
+		}
+		subIDs = []string{defaultSubID}
+	}
+	for _, subID := range subIDs {
+		if _, err = a.joinSubTopicLocked(subID, true); err != nil && a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "p2p.sub_topic join failed sub_id=%s err=%v", subID, err)
+		}
+	}
+}
+
+// syncSubTopicMembership joins or leaves the topic of subID after the set of
+// local subscriptions changed. Topics joined only for publishing are kept.
+func (a *App) syncSubTopicMembership(subID string) {
+	followed, err := a.isSubFollowed(subID)
+	if err != nil {
+		return
+	}
+
+	a.p2pMu.Lock()
+	defer a.p2pMu.Unlock()
+	if a.p2pPubsub == nil {
+		return
+	}
+
+	if followed {
+		if _, err = a.joinSubTopicLocked(subID, true); err == nil {
+			a.p2pSubTopics[normalizeSubID(subID)].listenUntil = time.Time{}
+		}
+	} else if handle, exists := a.p2pSubTopics[normalizeSubID(subID)]; exists && handle.sub != nil && !time.Now().Before(handle.listenUntil) {
+		err = a.leaveSubTopicLocked(subID)
+	}
+	if err != nil && a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "p2p.sub_topic membership failed sub_id=%s err=%v", subID, err)
+	}
+}
+
+// topicForSub returns the topic to publish sub traffic on, joining it without
+// subscribing when needed. An empty subID selects the control topic.
+func (a *App) topicForSub(subID string) (*pubsub.Topic, context.Context) {
+	a.p2pMu.Lock()
+	defer a.p2pMu.Unlock()
+
+	if strings.TrimSpace(subID) == "" || a.p2pPubsub == nil {
+		return a.p2pTopic, a.p2pCtx
+	}
+	topic, err := a.joinSubTopicLocked(subID, false)
+	if err != nil {
+		if a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "p2p.sub_topic publish join failed sub_id=%s err=%v", subID, err)
+		}
+		return a.p2pTopic, a.p2pCtx
+	}
+	if handle := a.p2pSubTopics[normalizeSubID(subID)]; handle.sub == nil {
+		handle.usedAt = time.Now()
+		a.trimPublishOnlySubTopicsLocked()
+	}
+	return topic, a.p2pCtx
+}
+
+// listenOnSubTopic is topicForSub for a request whose answers come back on
+// the sub topic: an unfollowed sub is subscribed for window so the answers
+// reach this node, then left again.
+func (a *App) listenOnSubTopic(subID string, window time.Duration) (*pubsub.Topic, context.Context) {
+	a.p2pMu.Lock()
+	defer a.p2pMu.Unlock()
+
+	if strings.TrimSpace(subID) == "" || a.p2pPubsub == nil {
+		return a.p2pTopic, a.p2pCtx
+	}
+	subID = normalizeSubID(subID)
+	if handle, exists := a.p2pSubTopics[subID]; exists && handle.sub != nil && handle.listenUntil.IsZero() {
+		return handle.topic, a.p2pCtx
+	}
+	topic, err := a.joinSubTopicLocked(subID, true)
+	if err != nil {
+		if a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "p2p.sub_topic listen join failed sub_id=%s err=%v", subID, err)
+		}
+		return a.p2pTopic, a.p2pCtx
+	}
+	a.p2pSubTopics[subID].listenUntil = time.Now().Add(window)
+	time.AfterFunc(window, func() { a.syncSubTopicMembership(subID) })
+	return topic, a.p2pCtx
+}
+
+// trimPublishOnlySubTopicsLocked closes the least recently used publish-only
+// topics beyond subTopicPublishOnlyLimit.
+func (a *App) trimPublishOnlySubTopicsLocked() {
+	publishOnly := make([]string, 0, len(a.p2pSubTopics))
+	for subID, handle := range a.p2pSubTopics {
+		if handle.sub == nil {
+			publishOnly = append(publishOnly, subID)
+		}
+	}
+	if len(publishOnly) <= subTopicPublishOnlyLimit {
+		return
+	}
+	sort.Slice(publishOnly, func(i int, j int) bool {
+		return a.p2pSubTopics[publishOnly[i]].usedAt.Before(a.p2pSubTopics[publishOnly[j]].usedAt)
+	})
+	for _, subID := range publishOnly[:len(publishOnly)-subTopicPublishOnlyLimit] {
+		if err := a.leaveSubTopicLocked(subID); err != nil && a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "p2p.sub_topic close failed sub_id=%s err=%v", subID, err)
+		}
+	}
+}
+
+func (a *App) topicForPost(postID string) (*pubsub.Topic, context.Context) {
+	return a.topicForSub(a.lookupPostSubID(postID))
+}
+
+// followedSubTopicIDs lists the subs whose topics are currently subscribed.
+func (a *App) followedSubTopicIDs() []string {
+	a.p2pMu.Lock()
+	defer a.p2pMu.Unlock()
+
+	result := make([]string, 0, len(a.p2pSubTopics))
+	for subID, handle := range a.p2pSubTopics {
+		if handle.sub != nil {
+			result = append(result, subID)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// lookupPostSubID returns the sub of a locally known post, or "" when the post
+// is unknown and traffic about it should go to the control topic.
+func (a *App) lookupPostSubID(postID string) string {
+	postID = strings.TrimSpace(postID)
+	if a.db == nil || postID == "" {
+		return ""
+	}
+
+	var subID string
+	err := a.db.QueryRow(`SELECT sub_id FROM messages WHERE id = ?;`, postID).Scan(&subID)
+	if err != nil {
+		return ""
+	}
+	return normalizeSubID(subID)
+}
+
+// lookupBlobSubID returns the sub of the first post referencing contentCID as
+// its body, image or thumbnail, or "" when no local post does.
+func (a *App) lookupBlobSubID(contentCID string) string {
+	contentCID = strings.TrimSpace(contentCID)
+	if a.db == nil || contentCID == "" {
+		return ""
+	}
+
+	var subID string
+	err := a.db.QueryRow(`
+		SELECT sub_id
+		FROM messages
+		WHERE content_cid = ? OR image_cid = ? OR thumb_cid = ?
+		LIMIT 1;
+	`, contentCID, contentCID, contentCID).Scan(&subID)
+	if err == nil {
+		return normalizeSubID(subID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return ""
+	}
+
+	err = a.db.QueryRow(`
+		SELECT m.sub_id
+		FROM comments c
+		JOIN messages m ON m.id = c.post_id
+		WHERE c.attachments_json LIKE ?
+		LIMIT 1;
+	`, "%"+contentCID+"%").Scan(&subID)
+	if err != nil {
+		return ""
+	}
+	return normalizeSubID(subID)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSubscribeJoinsAndUnsubscribeLeavesSubTopic(t *testing.T) {
+	t.Setenv("AEGIS_JOIN_ALL_SUB_TOPICS", "0")
+	app := newTestApp(t)
+	startTestP2P(t, app)
+	app.p2pMu.Lock()
+	app.joinFollowedSubTopicsLocked()
+	app.p2pMu.Unlock()
+
+	// joined reports whether the topic is joined, and subscribed whether
+	// the router also carries its messages to this node.
+	joined := func(subID string) bool {
+		app.p2pMu.Lock()
+		defer app.p2pMu.Unlock()
+		_, exists := app.p2pSubTopics[subID]
+		return exists
+	}
+	subscribed := func(subID string) bool {
+		return slices.Contains(app.p2pPubsub.GetTopics(), subTopicName(subID))
+	}
+	if got := app.followedSubTopicIDs(); !reflect.DeepEqual(got, []string{defaultSubID}) {
+		t.Fatalf("expected only the default sub topic at start, got %v", got)
+	}
+
+	if _, err := app.SubscribeSub("tech"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if got := app.followedSubTopicIDs(); !reflect.DeepEqual(got, []string{defaultSubID, "tech"}) || !subscribed("tech") {
+		t.Fatalf("expected the tech topic to be subscribed, got %v", got)
+	}
+
+	// A topic joined only to publish into an unfollowed sub is kept when
+	// another sub is left.
+	if topic, _ := app.topicForSub("art"); topic == nil || topic.String() != subTopicName("art") {
+		t.Fatalf("expected a publish-only art topic")
+	}
+	if err := app.UnsubscribeSub("tech"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if got := app.followedSubTopicIDs(); !reflect.DeepEqual(got, []string{defaultSubID}) || joined("tech") || subscribed("tech") {
+		t.Fatalf("expected the tech topic to be left, got %v", got)
+	}
+	if !joined("art") || subscribed("art") {
+		t.Fatalf("expected the publish-only art topic to stay joined without a subscription")
+	}
+
+	// The default sub is always followed.
+	if err := app.UnsubscribeSub(defaultSubID); err != nil {
+		t.Fatalf("unsubscribe default: %v", err)
+	}
+	if got := app.followedSubTopicIDs(); !reflect.DeepEqual(got, []string{defaultSubID}) {
+		t.Fatalf("expected the default sub topic to stay subscribed, got %v", got)
+	}
+}
+
+func TestTopicForPostRoutesBySub(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	if _, err := app.insertMessage(ForumMessage{ID: "p-tech", Pubkey: "alice", OpID: "op-tech", Title: "Kernels", Body: "body", Timestamp: 10, Lamport: 1, Zone: "public", SubID: "tech"}); err != nil {
+		t.Fatalf("insert post: %v", err)
+	}
+
+	if topic, _ := app.topicForPost("p-tech"); topic != nil {
+		t.Fatalf("expected no topic before P2P starts")
+	}
+
+	startTestP2P(t, app)
+	cases := []struct {
+		postID string
+		topic  string
+	}{
+		{"p-tech", subTopicName("tech")},
+		{"p-unknown", forumTopicName},
+		{"", forumTopicName},
+	}
+	for _, tc := range cases {
+		topic, ctx := app.topicForPost(tc.postID)
+		if topic == nil || ctx == nil || topic.String() != tc.topic {
+			t.Fatalf("post %q: expected topic %s, got %v", tc.postID, tc.topic, topic)
+		}
+	}
+
+	// A peer following the sub gets what is published for the post.
+	observer := newTestPubsubPeer(t, app)
+	tech := observer.subscribe(t, app, subTopicName("tech"))
+	topic, ctx := app.topicForPost("p-tech")
+	if err := topic.Publish(ctx, []byte(`{"type":"PING"}`)); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if message := observer.next(tech); message == nil || string(message.Data) != `{"type":"PING"}` {
+		t.Fatalf("expected the message on the tech topic, got %v", message)
+	}
+}
+
+func TestPublishOnlySubTopicsAreBounded(t *testing.T) {
+	app := newTestApp(t)
+	startTestP2P(t, app)
+
+	joined := func(subID string) bool {
+		app.p2pMu.Lock()
+		defer app.p2pMu.Unlock()
+		_, exists := app.p2pSubTopics[subID]
+		return exists
+	}
+	publish := func(subID string) {
+		t.Helper()
+		if topic, _ := app.topicForSub(subID); topic == nil || topic.String() != subTopicName(subID) {
+			t.Fatalf("expected a publish-only topic for %s", subID)
+		}
+	}
+
+	// sub-00 is used again after sub-01, so sub-01 is the least recently
+	// used topic once the limit is passed.
+	publish("sub-00")
+	publish("sub-01")
+	publish("sub-00")
+	for i := 2; i <= subTopicPublishOnlyLimit; i++ {
+		publish(fmt.Sprintf("sub-%02d", i))
+	}
+
+	if joined("sub-01") {
+		t.Fatalf("expected the least recently used topic to be closed")
+	}
+	for i := 0; i <= subTopicPublishOnlyLimit; i++ {
+		if subID := fmt.Sprintf("sub-%02d", i); subID != "sub-01" && !joined(subID) {
+			t.Fatalf("expected %s to stay joined", subID)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Pinned posts keep their blobs for offline reading: the body, image,
+// thumbnail and the media attached to the post's comments. A post is pinned
+// on its own (pinned_posts) or because its sub has the pin-everything policy
+// (pinned_subs). The pinned column of content_blobs and media_blobs is kept
+// in step with those tables, so eviction skips pinned blobs and pinned bytes
+// count against the pinned budget instead of their zone or media quota.
+// Pinning fails when it would overrun the budget; blobs that arrive later for
+// a pinned post are pinned while the budget has room and otherwise stored
+// like any other blob until a later pin change frees room.
+var errPinnedBudgetExceeded = errors.New("pinned storage budget exceeded")
+
+// pinnedBlobsSQL selects the (kind, cid) pairs of every blob that belongs to
+// a pinned post, whether or not the blob is stored.
+const pinnedBlobsSQL = `
+	WITH pinned AS (
+		SELECT post_id AS id FROM pinned_posts
+		UNION
+		SELECT id FROM messages WHERE sub_id IN (SELECT sub_id FROM pinned_subs)
+	)
+	SELECT 'content' AS kind, m.content_cid AS cid FROM messages m JOIN pinned p ON p.id = m.id WHERE m.content_cid <> ''
+	UNION
+	SELECT 'media', m.image_cid FROM messages m JOIN pinned p ON p.id = m.id WHERE m.image_cid <> ''
+	UNION
+	SELECT 'media', m.thumb_cid FROM messages m JOIN pinned p ON p.id = m.id WHERE m.thumb_cid <> ''
+	UNION
+	SELECT 'media', r.content_cid
+	FROM comment_media_refs r
+	JOIN comments c ON c.id = r.comment_id
+	JOIN pinned p ON p.id = c.post_id`
+
+type PinnedPost struct {
+	PostID   string `json:"postId"`
+	Title    string `json:"title"`
+	SubID    string `json:"subId"`
+	PinnedAt int64  `json:"pinnedAt"`
+}
+
+type PinnedSub struct {
+	SubID     string `json:"subId"`
+	PostCount int64  `json:"postCount"`
+	PinnedAt  int64  `json:"pinnedAt"`
+}
+
+type PinnedContent struct {
+	Posts       []PinnedPost `json:"posts"`
+	Subs        []PinnedSub  `json:"subs"`
+	UsedBytes   int64        `json:"usedBytes"`
+	BudgetBytes int64        `json:"budgetBytes"`
+}
+
+// PinPost keeps the post's body, image, thumbnail and comment media from
+// being evicted.
+func (a *App) PinPost(postID string) error {
+	postID = strings.TrimSpace(postID)
+	if postID == "" {
+		return errors.New("post id is required")
+	}
+	return a.updatePins("pin_post", postID, func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(1) FROM messages WHERE id = ?;`, postID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return errors.New("post not found")
+		}
+		_, err := tx.Exec(`
+			INSERT INTO pinned_posts (post_id, pinned_at)
+			VALUES (?, ?)
+			ON CONFLICT(post_id) DO NOTHING;
+		`, postID, time.Now().Unix())
+		return err
+	})
+}
+
+// UnpinPost releases the post's blobs back to their quotas, unless its sub is
+// pinned or another pinned post shares them.
+func (a *App) UnpinPost(postID string) error {
+	postID = strings.TrimSpace(postID)
+	if postID == "" {
+		return errors.New("post id is required")
+	}
+	return a.updatePins("unpin_post", postID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM pinned_posts WHERE post_id = ?;`, postID)
+		return err
+	})
+}
+
+// SetSubPinPolicy turns the pin-everything policy of a sub on or off. While it
+// is on, every post of the sub, including posts synced later, is pinned.
+func (a *App) SetSubPinPolicy(subID string, enabled bool) error {
+	subID = normalizeSubID(subID)
+	action := "unpin_sub"
+	if enabled {
+		action = "pin_sub"
+	}
+	return a.updatePins(action, subID, func(tx *sql.Tx) error {
+		if !enabled {
+			_, err := tx.Exec(`DELETE FROM pinned_subs WHERE sub_id = ?;`, subID)
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO pinned_subs (sub_id, pinned_at)
+			VALUES (?, ?)
+			ON CONFLICT(sub_id) DO NOTHING;
+		`, subID, time.Now().Unix())
+		return err
+	})
+}
+
+// updatePins applies change, re-derives the pinned blob flags and checks the
+// pinned budget, all in one transaction. Only blobs the change itself pins
+// are held to the budget; blobs of pinned posts that were left unpinned for
+// lack of room are pinned afterwards while the budget has room, so releasing
+// pins never fails. Blobs released by the change count against their quotas
+// again, which may evict other blobs.
+func (a *App) updatePins(action string, target string, change func(tx *sql.Tx) error) error {
+	if a.db == nil {
+		return errors.New("database not initialized")
+	}
+
+	quotas := a.currentStorageQuotas()
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	backlog, err := pendingBlobPinsTx(tx)
+	if err != nil {
+		return err
+	}
+	waiting := make(map[pendingBlobPin]bool, len(backlog))
+	for _, blob := range backlog {
+		waiting[blob] = true
+	}
+
+	if err = change(tx); err != nil {
+		return err
+	}
+	if err = releaseBlobPinsTx(tx); err != nil {
+		return err
+	}
+	pending, err := pendingBlobPinsTx(tx)
+	if err != nil {
+		return err
+	}
+	added := 0
+	for _, blob := range pending {
+		if waiting[blob] {
+			continue
+		}
+		if _, err = tx.Exec(`UPDATE `+blobTableForKind(blob.kind)+` SET pinned = 1 WHERE content_cid = ?;`, blob.cid); err != nil {
+			return err
+		}
+		added++
+	}
+	pinned, err := pinnedStorageUsedTx(tx)
+	if err != nil {
+		return err
+	}
+	if added > 0 && pinned > quotas.PinnedBytes {
+		return fmt.Errorf("%w: %d of %d bytes would be pinned", errPinnedBudgetExceeded, pinned, quotas.PinnedBytes)
+	}
+	for _, blob := range pending {
+		if !waiting[blob] {
+			continue
+		}
+		if err = pinArrivedBlobsTx(tx, quotas, blob.kind, blob.cid); err != nil {
+			return err
+		}
+	}
+	if pinned, err = pinnedStorageUsedTx(tx); err != nil {
+		return err
+	}
+	overQuota := a.newStorageEvictor(tx, quotas).enforce()
+	if overQuota != nil && !errors.Is(overQuota, errStorageQuotaExceeded) {
+		return overQuota
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "storage.pins_updated action=%s target=%s pinned_bytes=%d", action, target, pinned)
+		if overQuota != nil {
+			runtime.LogWarningf(a.ctx, "storage.quotas_over err=%v", overQuota)
+		}
+		runtime.EventsEmit(a.ctx, "pins:updated")
+	}
+	return nil
+}
+
+// pendingBlobPin is a stored blob that belongs to a pinned post but is not
+// pinned.
+type pendingBlobPin struct {
+	kind string
+	cid  string
+}
+
+func blobTableForKind(kind string) string {
+	if kind == storageBlobKindMedia {
+		return "media_blobs"
+	}
+	return "content_blobs"
+}
+
+// releaseBlobPinsTx clears the pinned flag of every stored blob that no
+// longer belongs to a pinned post.
+func releaseBlobPinsTx(tx *sql.Tx) error {
+	for _, kind := range []string{storageBlobKindContent, storageBlobKindMedia} {
+		if _, err := tx.Exec(`
+			WITH wanted AS (`+pinnedBlobsSQL+`)
+			UPDATE `+blobTableForKind(kind)+`
+			SET pinned = 0
+			WHERE pinned = 1 AND content_cid NOT IN (SELECT cid FROM wanted WHERE kind = ?);
+		`, kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingBlobPinsTx lists the stored blobs that belong to a pinned post but
+// are not pinned, oldest first.
+func pendingBlobPinsTx(tx *sql.Tx) ([]pendingBlobPin, error) {
+	rows, err := tx.Query(`
+		WITH wanted AS (`+pinnedBlobsSQL+`)
+		SELECT ?, content_cid, created_at FROM content_blobs
+		WHERE pinned = 0 AND content_cid IN (SELECT cid FROM wanted WHERE kind = ?)
+		UNION ALL
+		SELECT ?, content_cid, created_at FROM media_blobs
+		WHERE pinned = 0 AND content_cid IN (SELECT cid FROM wanted WHERE kind = ?)
+		ORDER BY 3 ASC, 2 ASC;
+	`, storageBlobKindContent, storageBlobKindContent, storageBlobKindMedia, storageBlobKindMedia)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := make([]pendingBlobPin, 0)
+	for rows.Next() {
+		var blob pendingBlobPin
+		var createdAt int64
+		if err = rows.Scan(&blob.kind, &blob.cid, &createdAt); err != nil {
+			return nil, err
+		}
+		pending = append(pending, blob)
+	}
+	return pending, rows.Err()
+}
+
+// pinArrivedBlobsTx pins the given stored blobs that belong to a pinned post,
+// in order, while the pinned budget has room. It is called by write paths
+// after a blob, post or comment is stored.
+func pinArrivedBlobsTx(tx *sql.Tx, quotas StorageQuotas, kind string, cids ...string) error {
+	table := blobTableForKind(kind)
+	for _, cid := range cids {
+		cid = strings.TrimSpace(cid)
+		if cid == "" {
+			continue
+		}
+
+		var size int64
+		err := tx.QueryRow(`
+			WITH wanted AS (`+pinnedBlobsSQL+`)
+			SELECT size_bytes FROM `+table+`
+			WHERE content_cid = ? AND pinned = 0
+				AND EXISTS (SELECT 1 FROM wanted WHERE kind = ? AND cid = ?);
+		`, cid, kind, cid).Scan(&size)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		pinned, err := pinnedStorageUsedTx(tx)
+		if err != nil {
+			return err
+		}
+		if pinned+size > quotas.PinnedBytes {
+			continue
+		}
+		if _, err = tx.Exec(`UPDATE `+table+` SET pinned = 1 WHERE content_cid = ?;`, cid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPinned returns the pinned posts and subs and how much of the pinned
+// budget their blobs take.
+func (a *App) ListPinned() (PinnedContent, error) {
+	if a.db == nil {
+		return PinnedContent{}, errors.New("database not initialized")
+	}
+
+	quotas, err := a.GetStorageQuotas()
+	if err != nil {
+		return PinnedContent{}, err
+	}
+	result := PinnedContent{Posts: make([]PinnedPost, 0), Subs: make([]PinnedSub, 0), BudgetBytes: quotas.PinnedBytes}
+
+	rows, err := a.db.Query(`
+		SELECT p.post_id, COALESCE(m.title, ''), COALESCE(m.sub_id, ''), p.pinned_at
+		FROM pinned_posts p
+		LEFT JOIN messages m ON m.id = p.post_id
+		ORDER BY p.pinned_at DESC, p.post_id ASC;
+	`)
+	if err != nil {
+		return PinnedContent{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var post PinnedPost
+		if err = rows.Scan(&post.PostID, &post.Title, &post.SubID, &post.PinnedAt); err != nil {
+			return PinnedContent{}, err
+		}
+		result.Posts = append(result.Posts, post)
+	}
+	if err = rows.Err(); err != nil {
+		return PinnedContent{}, err
+	}
+
+	subRows, err := a.db.Query(`
+		SELECT s.sub_id, s.pinned_at, (SELECT COUNT(1) FROM messages m WHERE m.sub_id = s.sub_id)
+		FROM pinned_subs s
+		ORDER BY s.pinned_at DESC, s.sub_id ASC;
+	`)
+	if err != nil {
+		return PinnedContent{}, err
+	}
+	defer subRows.Close()
+	for subRows.Next() {
+		var sub PinnedSub
+		if err = subRows.Scan(&sub.SubID, &sub.PinnedAt, &sub.PostCount); err != nil {
+			return PinnedContent{}, err
+		}
+		result.Subs = append(result.Subs, sub)
+	}
+	if err = subRows.Err(); err != nil {
+		return PinnedContent{}, err
+	}
+
+	err = a.db.QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM content_blobs WHERE pinned = 1) +
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM media_blobs WHERE pinned = 1);
+	`).Scan(&result.UsedBytes)
+	if err != nil {
+		return PinnedContent{}, err
+	}
+	return result, nil
+}
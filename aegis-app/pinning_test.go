@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPinPostCascadesToMediaAndRespectsBudget(t *testing.T) {
+	app := newTestApp(t)
+	if _, err := app.SetStorageQuotas(1000, 500, 500, 500, 40); err != nil {
+		t.Fatalf("set quotas: %v", err)
+	}
+
+	image := []byte{0x89, 'P', 'N', 'G', 1, 1, 1, 1}
+	thumb := []byte{0x89, 'P', 'N', 'G', 2, 2}
+	attachment := []byte{0x89, 'P', 'N', 'G', 3, 3, 3}
+	for _, data := range [][]byte{image, thumb, attachment} {
+		if err := app.upsertMediaBlobRaw(buildBinaryCID(data), "image/png", data, 1, 1, false); err != nil {
+			t.Fatalf("store media: %v", err)
+		}
+	}
+	for _, body := range []string{"pinned body", "tech post body text"} {
+		if err := app.upsertContentBlob(buildContentCID(body), body, 0); err != nil {
+			t.Fatalf("store body: %v", err)
+		}
+	}
+	for _, statement := range []struct {
+		query string
+		args  []any
+	}{
+		{`INSERT INTO messages (id, pubkey, content_cid, image_cid, thumb_cid, content, timestamp, size_bytes, zone, sub_id) VALUES ('p-1', 'a', ?, ?, ?, '', 1, 0, 'public', ?);`,
+			[]any{buildContentCID("pinned body"), buildBinaryCID(image), buildBinaryCID(thumb), defaultSubID}},
+		{`INSERT INTO messages (id, pubkey, content_cid, content, timestamp, size_bytes, zone, sub_id) VALUES ('p-2', 'a', ?, '', 1, 0, 'public', 'tech');`,
+			[]any{buildContentCID("tech post body text")}},
+		{`INSERT INTO comments (id, post_id, pubkey, body, timestamp) VALUES ('c-1', 'p-1', 'b', 'see image', 1);`, nil},
+		{`INSERT INTO comment_media_refs (comment_id, content_cid) VALUES ('c-1', ?);`, []any{buildBinaryCID(attachment)}},
+	} {
+		if _, err := app.db.Exec(statement.query, statement.args...); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	if err := app.PinPost("missing"); err == nil {
+		t.Fatalf("expected pinning an unknown post to fail")
+	}
+	if err := app.PinPost("p-1"); err != nil {
+		t.Fatalf("pin post: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM media_blobs WHERE pinned = 1;`); count != 3 {
+		t.Fatalf("expected image, thumbnail and attachment to be pinned, got %d", count)
+	}
+	pins, err := app.ListPinned()
+	if err != nil {
+		t.Fatalf("list pins: %v", err)
+	}
+	if len(pins.Posts) != 1 || pins.Posts[0].PostID != "p-1" || pins.UsedBytes != 32 || pins.BudgetBytes != 40 {
+		t.Fatalf("unexpected pins %+v", pins)
+	}
+
+	// The tech body does not fit next to what is already pinned.
+	if err = app.SetSubPinPolicy("tech", true); !errors.Is(err, errPinnedBudgetExceeded) {
+		t.Fatalf("expected pinned budget error, got %v", err)
+	}
+	if err = app.UnpinPost("p-1"); err != nil {
+		t.Fatalf("unpin post: %v", err)
+	}
+	if err = app.SetSubPinPolicy("tech", true); err != nil {
+		t.Fatalf("pin sub: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE pinned = 1;`); count != 1 {
+		t.Fatalf("expected only the tech body to be pinned, got %d", count)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM media_blobs WHERE pinned = 1;`); count != 0 {
+		t.Fatalf("expected media to be unpinned, got %d", count)
+	}
+
+	// A body that arrives later for a post in the pinned sub is pinned too.
+	late := "late tech body"
+	if _, err = app.db.Exec(`INSERT INTO messages (id, pubkey, content_cid, content, timestamp, size_bytes, zone, sub_id) VALUES ('p-3', 'a', ?, '', 1, 0, 'public', 'tech');`, buildContentCID(late)); err != nil {
+		t.Fatalf("index late post: %v", err)
+	}
+	if err = app.upsertContentBlob(buildContentCID(late), late, 0); err != nil {
+		t.Fatalf("store late body: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE content_cid = ? AND pinned = 1;`, buildContentCID(late)); count != 1 {
+		t.Fatalf("late body of a pinned sub was not pinned")
+	}
+
+	if _, err = app.SetStorageQuotas(1000, 500, 500, 500, 20); !errors.Is(err, errInvalidStorageQuota) {
+		t.Fatalf("expected lowering the pinned budget below usage to fail, got %v", err)
+	}
+}
+
+func TestUnpinSucceedsWhileAnotherPinnedSubHasUnpinnedBlobs(t *testing.T) {
+	app := newTestApp(t)
+	if _, err := app.SetStorageQuotas(1000, 500, 500, 500, 40); err != nil {
+		t.Fatalf("set quotas: %v", err)
+	}
+
+	seed := func(postID string, subID string, body string) {
+		t.Helper()
+		if _, err := app.db.Exec(`INSERT INTO messages (id, pubkey, content_cid, content, timestamp, size_bytes, zone, sub_id) VALUES (?, 'a', ?, '', 1, 0, 'public', ?);`, postID, buildContentCID(body), subID); err != nil {
+			t.Fatalf("index %s: %v", postID, err)
+		}
+		if err := app.upsertContentBlob(buildContentCID(body), body, 0); err != nil {
+			t.Fatalf("store %s body: %v", postID, err)
+		}
+	}
+	seed("p-other", "other", strings.Repeat("o", 30))
+	seed("p-tech", "tech", strings.Repeat("t", 10))
+	if err := app.SetSubPinPolicy("other", true); err != nil {
+		t.Fatalf("pin other: %v", err)
+	}
+	if err := app.SetSubPinPolicy("tech", true); err != nil {
+		t.Fatalf("pin tech: %v", err)
+	}
+
+	// Bodies arriving for tech while the budget is full stay unpinned.
+	seed("p-late-1", "tech", strings.Repeat("a", 20))
+	seed("p-late-2", "tech", strings.Repeat("b", 25))
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE pinned = 1;`); count != 2 {
+		t.Fatalf("expected the late bodies to stay unpinned, got %d pinned", count)
+	}
+
+	// Unpinning other frees room for one of them, not both.
+	if err := app.SetSubPinPolicy("other", false); err != nil {
+		t.Fatalf("unpin other: %v", err)
+	}
+	pins, err := app.ListPinned()
+	if err != nil {
+		t.Fatalf("list pins: %v", err)
+	}
+	if len(pins.Subs) != 1 || pins.Subs[0].SubID != "tech" || pins.UsedBytes > pins.BudgetBytes {
+		t.Fatalf("unexpected pins %+v", pins)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE pinned = 1;`); count != 2 {
+		t.Fatalf("expected the tech body and one late body to be pinned, got %d", count)
+	}
+	if err = app.SetSubPinPolicy("tech", false); err != nil {
+		t.Fatalf("unpin tech: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE pinned = 1;`); count != 0 {
+		t.Fatalf("expected nothing pinned, got %d", count)
+	}
+}
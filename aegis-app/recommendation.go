@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"math"
 	"sort"
 	"strings"
 	"sync"
@@ -14,6 +15,27 @@ type RecommendationStrategy interface {
 	Rank(candidates []ForumMessage, viewerPubkey string, now int64) ([]FeedStreamItem, error)
 }
 
+// SignalRankingStrategy is implemented by strategies that rank on votes or on
+// the viewer's history. The feed loads RankingSignals for the candidates and
+// calls RankWithSignals instead of Rank.
+type SignalRankingStrategy interface {
+	RecommendationStrategy
+	RankWithSignals(candidates []ForumMessage, signals RankingSignals, viewerPubkey string, now int64) ([]FeedStreamItem, error)
+}
+
+// CandidateWindowStrategy is implemented by strategies that need a different
+// candidate pool than the latest posts.
+type CandidateWindowStrategy interface {
+	CandidateWindow(now int64) RecommendationCandidateWindow
+}
+
+// RecommendationCandidateWindow limits candidates to posts created at or after
+// Since (0 for no limit), taking the highest scored first when ByScore is set.
+type RecommendationCandidateWindow struct {
+	Since   int64
+	ByScore bool
+}
+
 // StrategyRegistry manages available recommendation strategies
 type StrategyRegistry struct {
 	mu         sync.RWMutex
@@ -51,6 +73,19 @@ func GetStrategy(name string) (RecommendationStrategy, error) {
 	return nil, errors.New("strategy not found and fallback unavailable")
 }
 
+// registeredStrategyNames lists the registered strategies by name.
+func registeredStrategyNames() []string {
+	globalStrategyRegistry.mu.RLock()
+	defer globalStrategyRegistry.mu.RUnlock()
+
+	names := make([]string, 0, len(globalStrategyRegistry.strategies))
+	for name := range globalStrategyRegistry.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // HotV1Strategy implements the classic Hacker News / Reddit style gravity decay
 type HotV1Strategy struct{}
 
@@ -108,7 +143,168 @@ func (s *NewStrategy) Rank(candidates []ForumMessage, viewerPubkey string, now i
 	return items, nil
 }
 
+const (
+	topDayWindow   int64 = 24 * 3600
+	topWeekWindow  int64 = 7 * topDayWindow
+	topMonthWindow int64 = 30 * topDayWindow
+
+	risingVoteWindow      int64 = 6 * 3600
+	risingCandidateWindow int64 = 2 * topDayWindow
+
+	personalizedSubWeight    = 2.0
+	personalizedAuthorWeight = 3.0
+	personalizedTermWeight   = 1.5
+)
+
+// TopStrategy ranks posts in a time window by score, like a "top of the week"
+// listing. A zero window covers all time.
+type TopStrategy struct {
+	name   string
+	window int64
+}
+
+func (s *TopStrategy) Name() string {
+	return s.name
+}
+
+func (s *TopStrategy) CandidateWindow(now int64) RecommendationCandidateWindow {
+	window := RecommendationCandidateWindow{ByScore: true}
+	if s.window > 0 {
+		window.Since = now - s.window
+	}
+	return window
+}
+
+func (s *TopStrategy) Rank(candidates []ForumMessage, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	since := s.CandidateWindow(now).Since
+	items := make([]FeedStreamItem, 0, len(candidates))
+	for _, post := range candidates {
+		if post.Timestamp < since {
+			continue
+		}
+		items = append(items, FeedStreamItem{
+			Post:                post,
+			Reason:              "recommended_top",
+			RecommendationScore: float64(post.Score),
+		})
+	}
+	sortFeedItemsByScore(items)
+	return items, nil
+}
+
+// RisingStrategy ranks recent posts by vote velocity: net votes received in
+// the last risingVoteWindow per hour, counting only the hours a post has been
+// up for.
+type RisingStrategy struct{}
+
+func (s *RisingStrategy) Name() string {
+	return "rising"
+}
+
+func (s *RisingStrategy) CandidateWindow(now int64) RecommendationCandidateWindow {
+	return RecommendationCandidateWindow{Since: now - risingCandidateWindow}
+}
+
+func (s *RisingStrategy) Rank(candidates []ForumMessage, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	return s.RankWithSignals(candidates, RankingSignals{}, viewerPubkey, now)
+}
+
+func (s *RisingStrategy) RankWithSignals(candidates []ForumMessage, signals RankingSignals, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	items := make([]FeedStreamItem, 0, len(candidates))
+	for _, post := range candidates {
+		hours := math.Min(math.Max(float64(now-post.Timestamp)/3600.0, 1), float64(risingVoteWindow)/3600.0)
+		net := signals.RecentUpvotes[post.ID] - signals.RecentDownvotes[post.ID]
+		items = append(items, FeedStreamItem{
+			Post:                post,
+			Reason:              "recommended_rising",
+			RecommendationScore: float64(net) / hours,
+		})
+	}
+	sortFeedItemsByScore(items)
+	return items, nil
+}
+
+// ControversialStrategy ranks posts with both up and down votes by total
+// votes, scaled by how evenly they split.
+type ControversialStrategy struct{}
+
+func (s *ControversialStrategy) Name() string {
+	return "controversial"
+}
+
+func (s *ControversialStrategy) Rank(candidates []ForumMessage, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	return s.RankWithSignals(candidates, RankingSignals{}, viewerPubkey, now)
+}
+
+func (s *ControversialStrategy) RankWithSignals(candidates []ForumMessage, signals RankingSignals, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	items := make([]FeedStreamItem, 0, len(candidates))
+	for _, post := range candidates {
+		items = append(items, FeedStreamItem{
+			Post:                post,
+			Reason:              "recommended_controversial",
+			RecommendationScore: controversyScore(signals.Upvotes[post.ID], signals.Downvotes[post.ID]),
+		})
+	}
+	sortFeedItemsByScore(items)
+	return items, nil
+}
+
+// PersonalizedStrategy is hot-v1 with each post's score boosted by how close
+// it is to what the viewer upvoted or favorited: the same sub, the same author
+// or shared title terms.
+type PersonalizedStrategy struct{}
+
+func (s *PersonalizedStrategy) Name() string {
+	return "personalized"
+}
+
+func (s *PersonalizedStrategy) Rank(candidates []ForumMessage, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	return s.RankWithSignals(candidates, RankingSignals{}, viewerPubkey, now)
+}
+
+func (s *PersonalizedStrategy) RankWithSignals(candidates []ForumMessage, signals RankingSignals, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	items := make([]FeedStreamItem, 0, len(candidates))
+	for _, post := range candidates {
+		// Shift the score so a post nobody voted on yet can still be boosted.
+		base := float64(post.Score) + 1
+		if base > 0 {
+			base *= 1 + signals.Affinity.boost(post)
+		}
+		ageHours := math.Max(float64(now-post.Timestamp)/3600.0, 0)
+		items = append(items, FeedStreamItem{
+			Post:                post,
+			Reason:              "recommended_personalized",
+			RecommendationScore: base / math.Pow(ageHours+2, 1.2),
+		})
+	}
+	sortFeedItemsByScore(items)
+	return items, nil
+}
+
+func controversyScore(upvotes int64, downvotes int64) float64 {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	return float64(upvotes+downvotes) * float64(min(upvotes, downvotes)) / float64(max(upvotes, downvotes))
+}
+
+func sortFeedItemsByScore(items []FeedStreamItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].RecommendationScore == items[j].RecommendationScore {
+			return items[i].Post.Timestamp > items[j].Post.Timestamp
+		}
+		return items[i].RecommendationScore > items[j].RecommendationScore
+	})
+}
+
 func init() {
 	RegisterStrategy(&HotV1Strategy{})
 	RegisterStrategy(&NewStrategy{})
+	RegisterStrategy(&TopStrategy{name: "top-day", window: topDayWindow})
+	RegisterStrategy(&TopStrategy{name: "top-week", window: topWeekWindow})
+	RegisterStrategy(&TopStrategy{name: "top-month", window: topMonthWindow})
+	RegisterStrategy(&TopStrategy{name: "top-all"})
+	RegisterStrategy(&RisingStrategy{})
+	RegisterStrategy(&ControversialStrategy{})
+	RegisterStrategy(&PersonalizedStrategy{})
 }
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gopkg.in/yaml.v3"
+)
+
+// Declarative strategies come from the YAML or JSON files (by extension) in
+// the directory named by AEGIS_REC_STRATEGY_DIR, one strategy per file. The
+// directory is re-read whenever the feed asks for a strategy and a file was
+// added, removed or changed. A file that fails validation is rejected and the
+// version of it that loaded last, if any, stays active.
+const (
+	recommendationStrategySourceBuiltin = "builtin"
+	maxRecommendationStrategyNameLength = 64
+	maxRecommendationStrategyGravity    = 5.0
+)
+
+var (
+	recommendationStrategyNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	errNoRecommendationWeights        = errors.New("strategy sets no weights or subs")
+)
+
+type RecommendationStrategyInfo struct {
+	Name        string `json:"name"`
+	Source      string `json:"source"`
+	Description string `json:"description"`
+}
+
+type RecommendationStrategiesStatus struct {
+	Dir        string                       `json:"dir"`
+	LoadedAt   int64                        `json:"loadedAt"`
+	Strategies []RecommendationStrategyInfo `json:"strategies"`
+	Errors     []string                     `json:"errors"`
+}
+
+// RecommendationWeights multiply the features of a post: its net score, its
+// age in hours, its comment count, its author's reputation and whether the
+// viewer subscribes to its sub (1 or 0).
+type RecommendationWeights struct {
+	Score      float64 `json:"score" yaml:"score"`
+	AgeHours   float64 `json:"ageHours" yaml:"ageHours"`
+	Comments   float64 `json:"comments" yaml:"comments"`
+	Reputation float64 `json:"reputation" yaml:"reputation"`
+	Subscribed float64 `json:"subscribed" yaml:"subscribed"`
+}
+
+// recommendationStrategySpec is a strategy as written in its file. window
+// takes a duration such as "72h" or a number of seconds.
+type recommendationStrategySpec struct {
+	Name        string                `json:"name" yaml:"name"`
+	Description string                `json:"description" yaml:"description"`
+	Window      string                `json:"window" yaml:"window"`
+	Candidates  string                `json:"candidates" yaml:"candidates"`
+	Weights     RecommendationWeights `json:"weights" yaml:"weights"`
+	Subs        map[string]float64    `json:"subs" yaml:"subs"`
+	Gravity     float64               `json:"gravity" yaml:"gravity"`
+}
+
+// DeclarativeStrategy ranks posts by a weighted sum of their features plus a
+// bonus for the sub they are in, divided by (ageHours + 2) ^ gravity.
+type DeclarativeStrategy struct {
+	name        string
+	description string
+	window      int64
+	byScore     bool
+	weights     RecommendationWeights
+	subs        map[string]float64
+	gravity     float64
+}
+
+func (s *DeclarativeStrategy) Name() string {
+	return s.name
+}
+
+func (s *DeclarativeStrategy) CandidateWindow(now int64) RecommendationCandidateWindow {
+	window := RecommendationCandidateWindow{ByScore: s.byScore}
+	if s.window > 0 {
+		window.Since = now - s.window
+	}
+	return window
+}
+
+func (s *DeclarativeStrategy) Rank(candidates []ForumMessage, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	return s.RankWithSignals(candidates, RankingSignals{}, viewerPubkey, now)
+}
+
+func (s *DeclarativeStrategy) RankWithSignals(candidates []ForumMessage, signals RankingSignals, viewerPubkey string, now int64) ([]FeedStreamItem, error) {
+	since := s.CandidateWindow(now).Since
+	items := make([]FeedStreamItem, 0, len(candidates))
+	for _, post := range candidates {
+		if post.Timestamp < since {
+			continue
+		}
+		items = append(items, FeedStreamItem{
+			Post:                post,
+			Reason:              "recommended_" + s.name,
+			RecommendationScore: s.score(post, signals, now),
+		})
+	}
+	sortFeedItemsByScore(items)
+	return items, nil
+}
+
+func (s *DeclarativeStrategy) score(post ForumMessage, signals RankingSignals, now int64) float64 {
+	ageHours := math.Max(float64(now-post.Timestamp)/3600.0, 0)
+	value := s.weights.Score*float64(post.Score) +
+		s.weights.AgeHours*ageHours +
+		s.weights.Comments*float64(signals.Comments[post.ID]) +
+		s.weights.Reputation*signals.Reputation[post.Pubkey] +
+		s.subs[normalizeSubID(post.SubID)]
+	if signals.Subscribed[normalizeSubID(post.SubID)] {
+		value += s.weights.Subscribed
+	}
+	if s.gravity > 0 {
+		value /= math.Pow(ageHours+2, s.gravity)
+	}
+	return value
+}
+
+func (s *DeclarativeStrategy) info(source string) RecommendationStrategyInfo {
+	return RecommendationStrategyInfo{Name: s.name, Source: source, Description: s.description}
+}
+
+// recommendationStrategyFile is one file of the directory as last read.
+// Strategy is the version that loaded last, which stays active when a later
+// version is rejected.
+type recommendationStrategyFile struct {
+	ModTime  time.Time
+	Size     int64
+	Strategy *DeclarativeStrategy
+	Error    string
+}
+
+// recommendationStrategySet is the declarative strategies in effect, guarded
+// by recStrategyMu. Conflicts holds the files left out for reusing the name
+// of an earlier file.
+type recommendationStrategySet struct {
+	Dir       string
+	Files     map[string]recommendationStrategyFile
+	Active    map[string]*DeclarativeStrategy
+	Sources   map[string]string
+	Conflicts map[string]string
+	DirError  string
+	LoadedAt  int64
+}
+
+func resolveRecommendationStrategyDir() string {
+	return strings.TrimSpace(os.Getenv("AEGIS_REC_STRATEGY_DIR"))
+}
+
+func isRecommendationStrategyFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// parseRecommendationStrategy decodes and validates a strategy file. Unknown
+// fields are errors so that a misspelled weight does not silently count as 0.
+func parseRecommendationStrategy(path string, raw []byte) (*DeclarativeStrategy, error) {
+	var spec recommendationStrategySpec
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+		}
+	}
+	return spec.toStrategy()
+}
+
+func (spec recommendationStrategySpec) toStrategy() (*DeclarativeStrategy, error) {
+	strategy := &DeclarativeStrategy{
+		name:        strings.ToLower(strings.TrimSpace(spec.Name)),
+		description: strings.TrimSpace(spec.Description),
+		weights:     spec.Weights,
+		subs:        make(map[string]float64, len(spec.Subs)),
+		gravity:     spec.Gravity,
+	}
+
+	if strategy.name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(strategy.name) > maxRecommendationStrategyNameLength || !recommendationStrategyNamePattern.MatchString(strategy.name) {
+		return nil, fmt.Errorf("name %q may only contain letters, digits, - and _", spec.Name)
+	}
+	for _, builtin := range registeredStrategyNames() {
+		if strategy.name == builtin {
+			return nil, fmt.Errorf("name %q is a built-in strategy", strategy.name)
+		}
+	}
+
+	window := strings.TrimSpace(spec.Window)
+	if window != "" {
+		if seconds, err := strconv.ParseInt(window, 10, 64); err == nil {
+			strategy.window = seconds
+		} else if duration, err := time.ParseDuration(window); err == nil {
+			strategy.window = int64(duration / time.Second)
+		} else {
+			return nil, fmt.Errorf("invalid window %q", spec.Window)
+		}
+	}
+	if strategy.window < 0 {
+		return nil, errors.New("window must not be negative")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(spec.Candidates)) {
+	case "", "new":
+	case "top":
+		strategy.byScore = true
+	default:
+		return nil, fmt.Errorf("candidates must be new or top, got %q", spec.Candidates)
+	}
+
+	weights := map[string]float64{
+		"weights.score":      spec.Weights.Score,
+		"weights.ageHours":   spec.Weights.AgeHours,
+		"weights.comments":   spec.Weights.Comments,
+		"weights.reputation": spec.Weights.Reputation,
+		"weights.subscribed": spec.Weights.Subscribed,
+		"gravity":            spec.Gravity,
+	}
+	for subID, weight := range spec.Subs {
+		if strings.TrimSpace(subID) == "" {
+			return nil, errors.New("subs has an empty sub ID")
+		}
+		weights["subs."+subID] = weight
+		strategy.subs[normalizeSubID(subID)] = weight
+	}
+	hasWeight := false
+	for field, weight := range weights {
+		if math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("%s must be a finite number", field)
+		}
+		hasWeight = hasWeight || (weight != 0 && field != "gravity")
+	}
+	if !hasWeight {
+		return nil, errNoRecommendationWeights
+	}
+	if strategy.gravity < 0 || strategy.gravity > maxRecommendationStrategyGravity {
+		return nil, fmt.Errorf("gravity must be between 0 and %g", maxRecommendationStrategyGravity)
+	}
+	return strategy, nil
+}
+
+// loadRecommendationStrategyFile reads and validates a strategy file without
+// registering it.
+func loadRecommendationStrategyFile(path string) (*DeclarativeStrategy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRecommendationStrategy(path, raw)
+}
+
+// refreshRecommendationStrategies re-reads the files of the strategy
+// directory that changed since the last load, or all of them when force is
+// set. It returns an error when the directory cannot be read or a file was
+// rejected.
+func (a *App) refreshRecommendationStrategies(force bool) (RecommendationStrategiesStatus, error) {
+	dir := resolveRecommendationStrategyDir()
+	a.recStrategyMu.Lock()
+	defer a.recStrategyMu.Unlock()
+
+	current := a.recStrategies
+	if dir == "" {
+		if current.Dir != "" || current.Active != nil {
+			a.recStrategies = recommendationStrategySet{LoadedAt: time.Now().Unix()}
+		}
+		return a.recommendationStrategiesStatusLocked(), nil
+	}
+	if current.Dir != dir {
+		current = recommendationStrategySet{Dir: dir}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		// Keep the strategies in effect and report the directory error once.
+		if current.DirError != err.Error() && a.ctx != nil {
+			runtime.LogErrorf(a.ctx, "recommendation_strategies.load_failed dir=%s err=%v", dir, err)
+		}
+		current.DirError = err.Error()
+		a.recStrategies = current
+		return a.recommendationStrategiesStatusLocked(), err
+	}
+
+	files := make(map[string]recommendationStrategyFile)
+	names := make([]string, 0, len(entries))
+	changed := current.DirError != "" || current.Files == nil
+	for _, entry := range entries {
+		if entry.IsDir() || !isRecommendationStrategyFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		names = append(names, entry.Name())
+		previous, known := current.Files[entry.Name()]
+		if known && !force && previous.Size == info.Size() && previous.ModTime.Equal(info.ModTime()) {
+			files[entry.Name()] = previous
+			continue
+		}
+
+		changed = true
+		file := recommendationStrategyFile{ModTime: info.ModTime(), Size: info.Size(), Strategy: previous.Strategy}
+		path := filepath.Join(dir, entry.Name())
+		if strategy, err := loadRecommendationStrategyFile(path); err != nil {
+			file.Error = err.Error()
+			if previous.Error != file.Error && a.ctx != nil {
+				runtime.LogErrorf(a.ctx, "recommendation_strategies.load_failed path=%s err=%v", path, err)
+			}
+		} else {
+			file.Strategy = strategy
+		}
+		files[entry.Name()] = file
+	}
+	if len(files) != len(current.Files) {
+		changed = true
+	}
+	if !changed {
+		return a.recommendationStrategiesStatusLocked(), a.recommendationStrategyErrorLocked()
+	}
+
+	// Files are applied in name order; a later file that reuses a name is
+	// rejected.
+	sort.Strings(names)
+	active := make(map[string]*DeclarativeStrategy, len(names))
+	sources := make(map[string]string, len(names))
+	conflicts := make(map[string]string)
+	for _, name := range names {
+		file := files[name]
+		if file.Strategy == nil {
+			continue
+		}
+		if other, taken := sources[file.Strategy.name]; taken {
+			conflicts[name] = fmt.Sprintf("duplicate name %q, also defined in %s", file.Strategy.name, filepath.Base(other))
+			continue
+		}
+		active[file.Strategy.name] = file.Strategy
+		sources[file.Strategy.name] = filepath.Join(dir, name)
+	}
+
+	a.recStrategies = recommendationStrategySet{
+		Dir:       dir,
+		Files:     files,
+		Active:    active,
+		Sources:   sources,
+		Conflicts: conflicts,
+		LoadedAt:  time.Now().Unix(),
+	}
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "recommendation_strategies.loaded dir=%s strategies=%d", dir, len(active))
+	}
+	return a.recommendationStrategiesStatusLocked(), a.recommendationStrategyErrorLocked()
+}
+
+func (a *App) recommendationStrategyErrorLocked() error {
+	status := a.recommendationStrategiesStatusLocked()
+	if len(status.Errors) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(status.Errors, "; "))
+}
+
+func (a *App) recommendationStrategiesStatusLocked() RecommendationStrategiesStatus {
+	set := a.recStrategies
+	status := RecommendationStrategiesStatus{
+		Dir:        set.Dir,
+		LoadedAt:   set.LoadedAt,
+		Strategies: make([]RecommendationStrategyInfo, 0),
+		Errors:     make([]string, 0),
+	}
+	for _, name := range registeredStrategyNames() {
+		status.Strategies = append(status.Strategies, RecommendationStrategyInfo{Name: name, Source: recommendationStrategySourceBuiltin})
+	}
+	names := make([]string, 0, len(set.Active))
+	for name := range set.Active {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		status.Strategies = append(status.Strategies, set.Active[name].info(set.Sources[name]))
+	}
+
+	if set.DirError != "" {
+		status.Errors = append(status.Errors, set.DirError)
+	}
+	files := make([]string, 0, len(set.Files))
+	for name := range set.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		if message := set.Files[name].Error; message != "" {
+			status.Errors = append(status.Errors, name+": "+message)
+		} else if message := set.Conflicts[name]; message != "" {
+			status.Errors = append(status.Errors, name+": "+message)
+		}
+	}
+	return status
+}
+
+// resolveRecommendationStrategy looks a strategy up among the declarative
+// strategies, then the registered ones.
+func (a *App) resolveRecommendationStrategy(name string) (RecommendationStrategy, error) {
+	_, _ = a.refreshRecommendationStrategies(false)
+	a.recStrategyMu.Lock()
+	strategy, ok := a.recStrategies.Active[strings.ToLower(strings.TrimSpace(name))]
+	a.recStrategyMu.Unlock()
+	if ok {
+		return strategy, nil
+	}
+	return GetStrategy(name)
+}
+
+// GetRecommendationStrategies lists the strategies GetFeedStreamWithStrategy
+// accepts and the errors of rejected strategy files.
+func (a *App) GetRecommendationStrategies() RecommendationStrategiesStatus {
+	status, _ := a.refreshRecommendationStrategies(false)
+	return status
+}
+
+// ReloadRecommendationStrategies re-reads every strategy file now.
+func (a *App) ReloadRecommendationStrategies() (RecommendationStrategiesStatus, error) {
+	return a.refreshRecommendationStrategies(true)
+}
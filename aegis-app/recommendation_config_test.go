@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRecommendationStrategyValidates(t *testing.T) {
+	strategy, err := parseRecommendationStrategy("discussed.yaml", []byte(`
+name: Discussed
+description: threads people are talking about
+window: 72h
+candidates: top
+weights:
+  score: 1
+  comments: 2
+  reputation: 0.5
+  ageHours: -0.1
+subs:
+  Art: 3
+gravity: 1.2
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if strategy.name != "discussed" || strategy.window != 72*3600 || !strategy.byScore || strategy.subs["art"] != 3 || strategy.gravity != 1.2 {
+		t.Fatalf("unexpected strategy %+v", strategy)
+	}
+	if _, err = parseRecommendationStrategy("subs.json", []byte(`{"name":"art-first","window":"3600","subs":{"art":1}}`)); err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+
+	for name, body := range map[string]string{
+		"missing name":      `{"weights":{"score":1}}`,
+		"bad name":          `{"name":"top week","weights":{"score":1}}`,
+		"built-in name":     `{"name":"hot-v1","weights":{"score":1}}`,
+		"no weights":        `{"name":"x","gravity":1}`,
+		"unknown weight":    `{"name":"x","weights":{"votes":1}}`,
+		"unknown field":     `{"name":"x","weights":{"score":1},"decay":1}`,
+		"bad window":        `{"name":"x","weights":{"score":1},"window":"soon"}`,
+		"negative window":   `{"name":"x","weights":{"score":1},"window":"-1h"}`,
+		"bad candidates":    `{"name":"x","weights":{"score":1},"candidates":"random"}`,
+		"gravity too large": `{"name":"x","weights":{"score":1},"gravity":9}`,
+		"empty sub":         `{"name":"x","subs":{" ":1}}`,
+	} {
+		if _, err := parseRecommendationStrategy("strategy.json", []byte(body)); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestRecommendationStrategiesHotReloadIntoFeed(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	dir := t.TempDir()
+	t.Setenv("AEGIS_REC_STRATEGY_DIR", dir)
+	now := time.Now().Unix()
+
+	for i, post := range []ForumMessage{
+		{ID: "quiet", Pubkey: "alice", Title: "Quiet", SubID: "tech", Timestamp: now - 60},
+		{ID: "talked", Pubkey: "bob", Title: "Talked", SubID: "tech", Timestamp: now - 120},
+		{ID: "art", Pubkey: "carol", Title: "Art", SubID: "art", Timestamp: now - 180},
+	} {
+		post.OpID = "op-" + post.ID
+		post.Body = "body"
+		post.Lamport = int64(i + 1)
+		post.Zone = "public"
+		if _, err := app.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		comment := Comment{ID: "c" + string(rune('a'+i)), PostID: "talked", Pubkey: "dave", Body: "reply", Timestamp: now, Lamport: int64(10 + i)}
+		comment.OpID = "op-" + comment.ID
+		if _, err := app.insertComment(comment); err != nil {
+			t.Fatalf("insert comment: %v", err)
+		}
+	}
+
+	path := filepath.Join(dir, "discussed.yaml")
+	writeStrategy := func(name, body string, modTime time.Time) {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(body), 0o600); err != nil {
+			t.Fatalf("write strategy: %v", err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	feed := func(algorithm string) []string {
+		t.Helper()
+		stream, err := app.GetFeedStreamWithStrategy(10, algorithm)
+		if err != nil {
+			t.Fatalf("feed %s: %v", algorithm, err)
+		}
+		return feedItemPostIDs(stream.Items)
+	}
+	base := time.Now().Add(-time.Hour)
+
+	writeStrategy("discussed.yaml", "name: discussed\nweights:\n  comments: 1\n", base)
+	if got := feed("discussed"); !reflect.DeepEqual(got, []string{"talked", "quiet", "art"}) {
+		t.Fatalf("unexpected discussed feed %v", got)
+	}
+
+	// An edit that fails validation keeps the last good version.
+	writeStrategy("discussed.yaml", "name: discussed\nweights:\n  coments: 1\n", base.Add(time.Minute))
+	if got := feed("discussed"); !reflect.DeepEqual(got, []string{"talked", "quiet", "art"}) {
+		t.Fatalf("expected the last good version to stay, got %v", got)
+	}
+	status := app.GetRecommendationStrategies()
+	if len(status.Errors) != 1 || status.Strategies[len(status.Strategies)-1].Source != path {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	writeStrategy("discussed.yaml", "name: discussed\nsubs:\n  art: 5\n", base.Add(2*time.Minute))
+	writeStrategy("zz-copy.json", `{"name":"discussed","weights":{"score":1}}`, base)
+	if got := feed("discussed"); !reflect.DeepEqual(got, []string{"art", "quiet", "talked"}) {
+		t.Fatalf("expected the edit to apply, got %v", got)
+	}
+	if status, err := app.ReloadRecommendationStrategies(); err == nil || len(status.Errors) != 1 {
+		t.Fatalf("expected the later duplicate to be rejected, got %+v, err %v", status, err)
+	}
+
+	// Removing the file falls back to hot-v1, which ranks by hot score.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "zz-copy.json")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if got := feed("discussed"); !reflect.DeepEqual(got, []string{"quiet", "talked", "art"}) {
+		t.Fatalf("expected the hot-v1 fallback, got %v", got)
+	}
+	if status := app.GetRecommendationStrategies(); len(status.Errors) != 0 || len(status.Strategies) != len(registeredStrategyNames()) {
+		t.Fatalf("unexpected status after removal %+v", status)
+	}
+}
+
+func TestDeclarativeStrategyWeighsViewerSubscriptions(t *testing.T) {
+	app := newTestApp(t)
+	now := time.Now().Unix()
+	if _, err := app.db.Exec(`
+		INSERT INTO subs (id, title, created_at) VALUES ('art', 'Art', 1), ('tech', 'Tech', 1);
+		INSERT INTO sub_subscriptions (pubkey, sub_id, subscribed_at) VALUES ('me', 'art', 1), ('other', 'tech', 1);
+	`); err != nil {
+		t.Fatalf("seed subscriptions: %v", err)
+	}
+
+	posts := []ForumMessage{
+		{ID: "tech", Pubkey: "alice", SubID: "tech", Score: 1, Timestamp: now - 60},
+		{ID: "art", Pubkey: "bob", SubID: "Art", Timestamp: now - 120},
+	}
+	signals, err := app.loadRankingSignals(posts, "me", now)
+	if err != nil {
+		t.Fatalf("load signals: %v", err)
+	}
+	if !signals.Subscribed["art"] || signals.Subscribed["tech"] {
+		t.Fatalf("unexpected subscriptions %v", signals.Subscribed)
+	}
+	if anonymous, err := app.loadRankingSignals(posts, "", now); err != nil || len(anonymous.Subscribed) != 0 {
+		t.Fatalf("expected no subscriptions without a viewer, got %v, err %v", anonymous.Subscribed, err)
+	}
+
+	strategy, err := parseRecommendationStrategy("mine.json", []byte(`{"name":"mine","weights":{"score":1,"subscribed":2}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	items, err := strategy.RankWithSignals(posts, signals, "me", now)
+	if err != nil {
+		t.Fatalf("rank: %v", err)
+	}
+	if got := feedItemPostIDs(items); !reflect.DeepEqual(got, []string{"art", "tech"}) {
+		t.Fatalf("expected the subscribed sub first, got %v", got)
+	}
+	if items, _ = strategy.Rank(posts, "me", now); !reflect.DeepEqual(feedItemPostIDs(items), []string{"tech", "art"}) {
+		t.Fatalf("expected score order without signals, got %v", feedItemPostIDs(items))
+	}
+}
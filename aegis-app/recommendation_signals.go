@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// viewerAffinityHistoryLimit caps how many of the viewer's latest upvotes
+	// and favorites feed the personalized strategy.
+	viewerAffinityHistoryLimit = 200
+	// recommendationMinTermRunes drops short title words such as "a" or "of"
+	// from the viewer's topics.
+	recommendationMinTermRunes = 3
+)
+
+// RankingSignals carries what signal strategies rank on besides the posts
+// themselves. Maps are keyed by post ID, except Reputation, which is keyed by
+// author pubkey, and Subscribed, which holds the sub IDs the viewer
+// subscribes to; a missing key reads as zero.
+type RankingSignals struct {
+	Upvotes         map[string]int64
+	Downvotes       map[string]int64
+	RecentUpvotes   map[string]int64
+	RecentDownvotes map[string]int64
+	Comments        map[string]int64
+	Reputation      map[string]float64
+	Subscribed      map[string]bool
+	Affinity        ViewerAffinity
+}
+
+// ViewerAffinity weights the subs, authors and title terms of the posts the
+// viewer upvoted or favorited. Each weight is the share of those posts that
+// have it, from 0 to 1.
+type ViewerAffinity struct {
+	Subs    map[string]float64
+	Authors map[string]float64
+	Terms   map[string]float64
+}
+
+// boost scores how well a post matches the affinity. Term weights are summed
+// over the post's title terms and capped at 1.
+func (affinity ViewerAffinity) boost(post ForumMessage) float64 {
+	terms := 0.0
+	for _, term := range recommendationTerms(post.Title) {
+		terms += affinity.Terms[term]
+	}
+	return personalizedSubWeight*affinity.Subs[normalizeSubID(post.SubID)] +
+		personalizedAuthorWeight*affinity.Authors[post.Pubkey] +
+		personalizedTermWeight*min(terms, 1)
+}
+
+// recommendationTerms returns the distinct lowercased title words long enough
+// to say something about a topic.
+func recommendationTerms(title string) []string {
+	seen := make(map[string]struct{})
+	terms := make([]string, 0)
+	for _, token := range searchTokens(strings.ToLower(title)) {
+		if len([]rune(token)) < recommendationMinTermRunes {
+			continue
+		}
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		terms = append(terms, token)
+	}
+	return terms
+}
+
+// loadRankingSignals counts the votes on the candidates, all time and within
+// risingVoteWindow by the time each vote was cast, and their comments. It
+// rates each author and loads the viewer's subscriptions and affinity when
+// there is a viewer.
+func (a *App) loadRankingSignals(candidates []ForumMessage, viewerPubkey string, now int64) (RankingSignals, error) {
+	signals := RankingSignals{
+		Upvotes:         make(map[string]int64),
+		Downvotes:       make(map[string]int64),
+		RecentUpvotes:   make(map[string]int64),
+		RecentDownvotes: make(map[string]int64),
+		Comments:        make(map[string]int64),
+		Reputation:      make(map[string]float64),
+		Subscribed:      make(map[string]bool),
+	}
+	if len(candidates) > 0 {
+		args := make([]interface{}, 0, len(candidates)+1)
+		args = append(args, now-risingVoteWindow)
+		for _, post := range candidates {
+			args = append(args, post.ID)
+		}
+		placeholders := makeSQLPlaceholders(len(candidates))
+		for _, table := range []struct {
+			name   string
+			total  map[string]int64
+			recent map[string]int64
+		}{
+			{"post_votes", signals.Upvotes, signals.RecentUpvotes},
+			{"post_downvotes", signals.Downvotes, signals.RecentDownvotes},
+		} {
+			if err := a.countPostVotes(table.name, placeholders, args, table.total, table.recent); err != nil {
+				return RankingSignals{}, err
+			}
+		}
+		if err := a.countPostComments(placeholders, args[1:], signals.Comments); err != nil {
+			return RankingSignals{}, err
+		}
+		if err := a.loadAuthorReputation(candidates, signals.Reputation); err != nil {
+			return RankingSignals{}, err
+		}
+	}
+
+	viewerPubkey = strings.TrimSpace(viewerPubkey)
+	if viewerPubkey == "" {
+		return signals, nil
+	}
+	if err := a.loadViewerSubscriptions(viewerPubkey, signals.Subscribed); err != nil {
+		return RankingSignals{}, err
+	}
+	affinity, err := a.loadViewerAffinity(viewerPubkey)
+	if err != nil {
+		return RankingSignals{}, err
+	}
+	signals.Affinity = affinity
+	return signals, nil
+}
+
+func (a *App) countPostVotes(table string, placeholders string, args []interface{}, total map[string]int64, recent map[string]int64) error {
+	rows, err := a.db.Query(fmt.Sprintf(`
+		SELECT post_id, COUNT(1), SUM(CASE WHEN timestamp >= ? THEN 1 ELSE 0 END)
+		FROM %s
+		WHERE post_id IN (%s)
+		GROUP BY post_id;
+	`, table, placeholders), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID string
+		var count, recentCount int64
+		if err = rows.Scan(&postID, &count, &recentCount); err != nil {
+			return err
+		}
+		total[postID] = count
+		recent[postID] = recentCount
+	}
+	return rows.Err()
+}
+
+func (a *App) countPostComments(placeholders string, postIDs []interface{}, counts map[string]int64) error {
+	rows, err := a.db.Query(fmt.Sprintf(`
+		SELECT post_id, COUNT(1)
+		FROM comments
+		WHERE post_id IN (%s) AND deleted_at = 0
+		GROUP BY post_id;
+	`, placeholders), postIDs...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID string
+		var count int64
+		if err = rows.Scan(&postID, &count); err != nil {
+			return err
+		}
+		counts[postID] = count
+	}
+	return rows.Err()
+}
+
+// loadAuthorReputation rates each candidate author by the total score of
+// their posts and comments, as log2(1 + total) so that a few prolific authors
+// do not drown out everyone else. A negative total rates as 0.
+func (a *App) loadAuthorReputation(candidates []ForumMessage, reputation map[string]float64) error {
+	authors := make([]interface{}, 0, len(candidates))
+	seen := make(map[string]struct{}, len(candidates))
+	for _, post := range candidates {
+		if _, ok := seen[post.Pubkey]; ok {
+			continue
+		}
+		seen[post.Pubkey] = struct{}{}
+		authors = append(authors, post.Pubkey)
+	}
+	placeholders := makeSQLPlaceholders(len(authors))
+	args := append(append(make([]interface{}, 0, 2*len(authors)), authors...), authors...)
+
+	rows, err := a.db.Query(fmt.Sprintf(`
+		SELECT pubkey, SUM(score)
+		FROM (
+			SELECT pubkey, score FROM messages WHERE pubkey IN (%s) AND visibility != 'deleted'
+			UNION ALL
+			SELECT pubkey, score FROM comments WHERE pubkey IN (%s) AND deleted_at = 0
+		)
+		GROUP BY pubkey;
+	`, placeholders, placeholders), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var author string
+		var total int64
+		if err = rows.Scan(&author, &total); err != nil {
+			return err
+		}
+		reputation[author] = math.Log2(1 + float64(max(total, 0)))
+	}
+	return rows.Err()
+}
+
+func (a *App) loadViewerSubscriptions(viewerPubkey string, subscribed map[string]bool) error {
+	rows, err := a.db.Query(`SELECT sub_id FROM sub_subscriptions WHERE pubkey = ?;`, viewerPubkey)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subID string
+		if err = rows.Scan(&subID); err != nil {
+			return err
+		}
+		subscribed[normalizeSubID(subID)] = true
+	}
+	return rows.Err()
+}
+
+// loadViewerAffinity reads the viewer's latest upvoted and favorited posts.
+// The viewer's own posts count toward subs and terms but not authors.
+func (a *App) loadViewerAffinity(viewerPubkey string) (ViewerAffinity, error) {
+	rows, err := a.db.Query(`
+		SELECT m.sub_id, m.pubkey, m.title
+		FROM messages m
+		JOIN (
+			SELECT post_id, MAX(at) AS at
+			FROM (
+				SELECT post_id, timestamp AS at FROM post_votes WHERE voter_pubkey = ?
+				UNION ALL
+				SELECT post_id, updated_at AS at FROM post_favorites_state WHERE pubkey = ? AND state = 'active'
+			)
+			GROUP BY post_id
+		) liked ON liked.post_id = m.id
+		WHERE m.visibility != 'deleted'
+		ORDER BY liked.at DESC
+		LIMIT ?;
+	`, viewerPubkey, viewerPubkey, viewerAffinityHistoryLimit)
+	if err != nil {
+		return ViewerAffinity{}, err
+	}
+	defer rows.Close()
+
+	affinity := ViewerAffinity{
+		Subs:    make(map[string]float64),
+		Authors: make(map[string]float64),
+		Terms:   make(map[string]float64),
+	}
+	total := 0
+	for rows.Next() {
+		var subID, author, title string
+		if err = rows.Scan(&subID, &author, &title); err != nil {
+			return ViewerAffinity{}, err
+		}
+		total++
+		affinity.Subs[normalizeSubID(subID)]++
+		if author != viewerPubkey {
+			affinity.Authors[author]++
+		}
+		for _, term := range recommendationTerms(title) {
+			affinity.Terms[term]++
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return ViewerAffinity{}, err
+	}
+
+	for _, weights := range []map[string]float64{affinity.Subs, affinity.Authors, affinity.Terms} {
+		for key := range weights {
+			weights[key] /= float64(total)
+		}
+	}
+	return affinity, nil
+}
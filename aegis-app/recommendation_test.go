@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func feedItemPostIDs(items []FeedStreamItem) []string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Post.ID)
+	}
+	return ids
+}
+
+func TestRecommendationStrategiesRankOnWindowsVotesAndAffinity(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	now := time.Now().Unix()
+	day := int64(24 * 3600)
+
+	posts := []struct {
+		post  ForumMessage
+		score int64
+	}{
+		{ForumMessage{ID: "old", Pubkey: "alice", Title: "Archive", SubID: "tech", Timestamp: now - 10*day}, 50},
+		{ForumMessage{ID: "hot", Pubkey: "alice", Title: "Launch", SubID: "tech", Timestamp: now - 2*3600}, 5},
+		{ForumMessage{ID: "split", Pubkey: "bob", Title: "Tabs", SubID: "art", Timestamp: now - 3*day}, 0},
+		{ForumMessage{ID: "mild", Pubkey: "bob", Title: "Sketches", SubID: "art", Timestamp: now - day}, 3},
+	}
+	for i, entry := range posts {
+		post := entry.post
+		post.OpID = "op-" + post.ID
+		post.Body = "body"
+		post.Lamport = int64(i + 1)
+		post.Zone = "public"
+		if _, err := app.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+		if _, err := app.db.Exec(`UPDATE messages SET score = ? WHERE id = ?;`, entry.score, post.ID); err != nil {
+			t.Fatalf("score: %v", err)
+		}
+	}
+	vote := func(table, postID, voter string, at int64) {
+		if _, err := app.db.Exec(`INSERT INTO `+table+` (post_id, voter_pubkey, timestamp) VALUES (?, ?, ?);`, postID, voter, at); err != nil {
+			t.Fatalf("vote: %v", err)
+		}
+	}
+	for _, voter := range []string{"v1", "v2", "v3", "v4", "v5"} {
+		vote("post_votes", "hot", voter, now-600)
+	}
+	for _, voter := range []string{"v1", "v2", "v3", "v4"} {
+		vote("post_votes", "split", voter, now-3*day)
+		vote("post_downvotes", "split", "d-"+voter, now-3*day)
+	}
+
+	order := map[string][]string{
+		"top-week":      {"hot", "mild", "split"},
+		"top-all":       {"old", "hot", "mild", "split"},
+		"rising":        {"hot", "mild"},
+		"controversial": {"split", "hot", "mild", "old"},
+	}
+	for algorithm, want := range order {
+		stream, err := app.GetFeedStreamWithStrategy(10, algorithm)
+		if err != nil || !reflect.DeepEqual(feedItemPostIDs(stream.Items), want) || stream.Items[0].Reason != "recommended_"+algorithm {
+			t.Fatalf("%s: unexpected feed %v, err %v", algorithm, feedItemPostIDs(stream.Items), err)
+		}
+	}
+
+	// The viewer upvoted a watercolor post in art by carol, so a fresh
+	// watercolor post in art outranks an equal post elsewhere.
+	if _, err := app.insertMessage(ForumMessage{ID: "liked", Pubkey: "carol", OpID: "op-liked", Title: "Watercolor techniques", Body: "body", Timestamp: now - 5*day, Lamport: 10, Zone: "public", SubID: "art"}); err != nil {
+		t.Fatalf("insert liked: %v", err)
+	}
+	vote("post_votes", "liked", "viewer", now-day)
+	candidates := []ForumMessage{
+		{ID: "p-b", Pubkey: "erin", Title: "Kernel news", SubID: "tech", Timestamp: now - 3600},
+		{ID: "p-a", Pubkey: "dave", Title: "Watercolor landscapes", SubID: "art", Timestamp: now - 3600},
+	}
+	signals, err := app.loadRankingSignals(candidates, "viewer", now)
+	if err != nil || signals.Affinity.Subs["art"] != 1 || signals.Affinity.Authors["carol"] != 1 || signals.Affinity.Terms["watercolor"] != 1 {
+		t.Fatalf("unexpected signals %+v, err %v", signals, err)
+	}
+	ranked, err := (&PersonalizedStrategy{}).RankWithSignals(candidates, signals, "viewer", now)
+	if err != nil || !reflect.DeepEqual(feedItemPostIDs(ranked), []string{"p-a", "p-b"}) || ranked[0].RecommendationScore <= ranked[1].RecommendationScore {
+		t.Fatalf("unexpected personalized ranking %+v, err %v", ranked, err)
+	}
+}
+
+func TestRisingCountsVotesByTheirOwnTimestamp(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	now := time.Now().Unix()
+
+	post := ForumMessage{ID: "p-1", Pubkey: "alice", OpID: "op-p1", Title: "Synced late", Body: "body", SubID: "tech", Timestamp: now - 2*risingVoteWindow, Lamport: 1, Zone: "public"}
+	if _, err := app.insertMessage(post); err != nil {
+		t.Fatalf("insert post: %v", err)
+	}
+	// An old vote that only reaches this node now, and one whose clock runs
+	// ahead of ours.
+	votes := []IncomingMessage{
+		{Type: "POST_VOTE_SET", OpID: "v-old", Pubkey: "bob", VoterPubkey: "bob", PostID: "p-1", VoteState: voteStateUp, Timestamp: now - 2*risingVoteWindow},
+		{Type: "POST_VOTE_SET", OpID: "v-ahead", Pubkey: "carol", VoterPubkey: "carol", PostID: "p-1", VoteState: voteStateUp, Timestamp: now + 3600},
+	}
+	for _, vote := range votes {
+		if err := app.applyIncomingMessage(vote); err != nil {
+			t.Fatalf("apply %s: %v", vote.OpID, err)
+		}
+	}
+
+	signals, err := app.loadRankingSignals([]ForumMessage{post}, "", now)
+	if err != nil {
+		t.Fatalf("load signals: %v", err)
+	}
+	if signals.Upvotes["p-1"] != 2 || signals.RecentUpvotes["p-1"] != 1 {
+		t.Fatalf("expected two votes with one recent, got %d and %d", signals.Upvotes["p-1"], signals.RecentUpvotes["p-1"])
+	}
+	if at := countRows(t, app, `SELECT timestamp FROM post_votes WHERE voter_pubkey = 'carol';`); int64(at) > time.Now().Unix() {
+		t.Fatalf("expected a future vote to be capped at now, got %d", at)
+	}
+}
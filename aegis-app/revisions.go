@@ -0,0 +1,868 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Revision history keeps every version of a post or comment that reaches
+// this node, keyed by op ID in entity_revisions. insertMessage and
+// insertComment record the version they apply and, before overwriting, the
+// version they replace; versions that arrive after a newer one are recorded
+// as history only. A revision row holds the signed digest of its version, so
+// it can be handed to peers and verified there, and the CID of its body.
+// Bodies live in content_blobs: post bodies already do, and a comment body is
+// copied there once a newer version replaces it. Bodies outside the retention
+// policy are released when the entity gets a new revision and on every
+// tombstone GC run, and the storage quota may evict them like any other
+// unreferenced blob; the revision stays listed without a diff.
+//
+// Peers exchange history with REVISION_SYNC_REQUEST/RESPONSE on the topic
+// of the post's sub. Opening the history of a public post or comment asks
+// peers for the revisions this node lacks, at most once per
+// revisionSyncInterval, and listens on the sub topic for
+// revisionSyncListenWindow so the answers reach it when it does not follow
+// the sub.
+const (
+	revisionDefaultKeep          = 10
+	revisionDefaultRetentionDays = 90
+	revisionDiffMaxCells         = 1 << 20
+	revisionSyncInterval         = 60 * time.Second
+	revisionSyncListenWindow     = 10 * time.Second
+	revisionSyncMaxRecords       = 20
+	revisionSyncMaxBytes         = 256 * 1024
+
+	revisionDiffEqual  = "equal"
+	revisionDiffInsert = "insert"
+	revisionDiffDelete = "delete"
+)
+
+var errRevisionAuthorMismatch = errors.New("revision author does not match")
+
+type RevisionDiffChunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// EntityRevision is one version of a post or comment. Diff compares its body
+// with the previous revision, line by line; it is empty when either body is
+// no longer stored.
+type EntityRevision struct {
+	OpID          string              `json:"opId"`
+	EntityType    string              `json:"entityType"`
+	EntityID      string              `json:"entityId"`
+	Author        string              `json:"author"`
+	Title         string              `json:"title,omitempty"`
+	ContentCID    string              `json:"contentCid"`
+	Lamport       int64               `json:"lamport"`
+	Timestamp     int64               `json:"timestamp"`
+	Current       bool                `json:"current"`
+	BodyAvailable bool                `json:"bodyAvailable"`
+	Diff          []RevisionDiffChunk `json:"diff"`
+}
+
+type revisionRetention struct {
+	Keep          int
+	RetentionDays int
+}
+
+// revisionRecord is a row of entity_revisions. digest is the SyncPostDigest
+// or SyncCommentDigest of the version, without signature or comment body.
+type revisionRecord struct {
+	entityType string
+	entityID   string
+	opID       string
+	author     string
+	lamport    int64
+	timestamp  int64
+	contentCID string
+	digest     any
+}
+
+func resolveRevisionRetention() revisionRetention {
+	policy := revisionRetention{Keep: revisionDefaultKeep, RetentionDays: revisionDefaultRetentionDays}
+	if value, err := strconv.Atoi(strings.TrimSpace(os.Getenv("AEGIS_REVISION_KEEP"))); err == nil && value > 0 {
+		policy.Keep = value
+	}
+	if value, err := strconv.Atoi(strings.TrimSpace(os.Getenv("AEGIS_REVISION_RETENTION_DAYS"))); err == nil && value > 0 {
+		policy.RetentionDays = value
+	}
+	return policy
+}
+
+func postRevisionRecord(digest SyncPostDigest) revisionRecord {
+	digest.Signature = ""
+	digest.OpType = ""
+	return revisionRecord{
+		entityType: entityTypePost,
+		entityID:   digest.ID,
+		opID:       digest.OpID,
+		author:     digest.Pubkey,
+		lamport:    digest.Lamport,
+		timestamp:  digest.Timestamp,
+		contentCID: digest.ContentCID,
+		digest:     digest,
+	}
+}
+
+func commentRevisionRecord(digest SyncCommentDigest) revisionRecord {
+	contentCID := ""
+	if digest.Body != "" {
+		contentCID = buildContentCID(digest.Body)
+	}
+	digest.Body = ""
+	digest.Signature = ""
+	digest.OpType = ""
+	digest.DisplayName = ""
+	digest.AvatarURL = ""
+	digest.Score = 0
+	return revisionRecord{
+		entityType: entityTypeComment,
+		entityID:   digest.ID,
+		opID:       digest.OpID,
+		author:     digest.Pubkey,
+		lamport:    digest.Lamport,
+		timestamp:  digest.Timestamp,
+		contentCID: contentCID,
+		digest:     digest,
+	}
+}
+
+func postRevisionDigest(message ForumMessage) SyncPostDigest {
+	return SyncPostDigest{
+		ID:          message.ID,
+		Pubkey:      message.Pubkey,
+		OpID:        message.OpID,
+		Title:       message.Title,
+		ContentCID:  message.ContentCID,
+		ImageCID:    message.ImageCID,
+		ThumbCID:    message.ThumbCID,
+		ImageMIME:   message.ImageMIME,
+		ImageSize:   message.ImageSize,
+		ImageWidth:  message.ImageWidth,
+		ImageHeight: message.ImageHeight,
+		Timestamp:   message.Timestamp,
+		Lamport:     message.Lamport,
+		SubID:       normalizeSubID(message.SubID),
+	}
+}
+
+func commentRevisionDigest(comment Comment) SyncCommentDigest {
+	return SyncCommentDigest{
+		ID:          comment.ID,
+		PostID:      comment.PostID,
+		ParentID:    comment.ParentID,
+		Pubkey:      comment.Pubkey,
+		OpID:        comment.OpID,
+		Body:        comment.Body,
+		Attachments: comment.Attachments,
+		Timestamp:   comment.Timestamp,
+		Lamport:     comment.Lamport,
+	}
+}
+
+func insertRevisionTx(tx *sql.Tx, record revisionRecord) (bool, error) {
+	if record.entityID == "" || record.opID == "" || record.author == "" {
+		return false, errors.New("invalid revision")
+	}
+	digest, err := json.Marshal(record.digest)
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.Exec(`
+		INSERT INTO entity_revisions (op_id, entity_type, entity_id, author_pubkey, lamport, timestamp, content_cid, digest_json, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(op_id) DO NOTHING;
+	`, record.opID, record.entityType, record.entityID, record.author, record.lamport, record.timestamp, record.contentCID, string(digest), time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// storeRevisionBodyTx keeps body as a content blob. A body that does not fit
+// the storage quota is dropped; the revision is still recorded.
+func (a *App) storeRevisionBodyTx(tx *sql.Tx, quotas StorageQuotas, body string) error {
+	if body == "" {
+		return nil
+	}
+	cid := buildContentCID(body)
+	size := int64(len([]byte(body)))
+	if err := a.newStorageEvictor(tx, quotas).ensure(storageWrite{ContentCID: cid, ContentBytes: size}); err != nil {
+		if errors.Is(err, errStorageQuotaExceeded) {
+			return nil
+		}
+		return err
+	}
+	now := time.Now().Unix()
+	_, err := tx.Exec(`
+		INSERT INTO content_blobs (content_cid, body, size_bytes, created_at, last_accessed_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(content_cid) DO NOTHING;
+	`, cid, body, size, now, now)
+	return err
+}
+
+// snapshotCurrentRevisionTx records the stored version of an entity before
+// it is replaced, so entities from before revision history, or whose history
+// was synced without their current version, keep it. A comment body is
+// copied into content_blobs since the comment row is about to change.
+func (a *App) snapshotCurrentRevisionTx(tx *sql.Tx, quotas StorageQuotas, entityType string, entityID string) error {
+	switch entityType {
+	case entityTypePost:
+		var (
+			message   ForumMessage
+			deletedAt int64
+		)
+		err := tx.QueryRow(`
+			SELECT id, pubkey, current_op_id, title, content_cid, image_cid, thumb_cid, image_mime, image_size, image_width, image_height, timestamp, lamport, sub_id, deleted_at_lamport
+			FROM messages
+			WHERE id = ?;
+		`, entityID).Scan(&message.ID, &message.Pubkey, &message.OpID, &message.Title, &message.ContentCID, &message.ImageCID, &message.ThumbCID, &message.ImageMIME, &message.ImageSize, &message.ImageWidth, &message.ImageHeight, &message.Timestamp, &message.Lamport, &message.SubID, &deletedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if deletedAt > 0 || strings.TrimSpace(message.ContentCID) == "" {
+			return nil
+		}
+		message.OpID = resolveCurrentVersion(message.Lamport, message.Pubkey, message.OpID, message.ID).OpID
+		_, err = insertRevisionTx(tx, postRevisionRecord(postRevisionDigest(message)))
+		return err
+	case entityTypeComment:
+		var (
+			comment         Comment
+			attachmentsJSON string
+			deletedAt       int64
+		)
+		err := tx.QueryRow(`
+			SELECT id, post_id, parent_id, pubkey, current_op_id, body, attachments_json, timestamp, lamport, deleted_at_lamport
+			FROM comments
+			WHERE id = ?;
+		`, entityID).Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Pubkey, &comment.OpID, &comment.Body, &attachmentsJSON, &comment.Timestamp, &comment.Lamport, &deletedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if deletedAt > 0 {
+			return nil
+		}
+		comment.OpID = resolveCurrentVersion(comment.Lamport, comment.Pubkey, comment.OpID, comment.ID).OpID
+		comment.Attachments = decodeCommentAttachmentsJSON(attachmentsJSON)
+		if err = a.storeRevisionBodyTx(tx, quotas, comment.Body); err != nil {
+			return err
+		}
+		_, err = insertRevisionTx(tx, commentRevisionRecord(commentRevisionDigest(comment)))
+		return err
+	}
+	return fmt.Errorf("unknown revision entity type %q", entityType)
+}
+
+// recordPastRevision records a version older than the one stored, together
+// with its body. It is a no-op for unknown or deleted entities and for
+// versions that are not older than the stored one; those arrive through
+// normal sync.
+func (a *App) recordPastRevision(record revisionRecord, body string) (bool, error) {
+	if a.db == nil {
+		return false, errors.New("database not initialized")
+	}
+
+	quotas := a.currentStorageQuotas()
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	table := "messages"
+	if record.entityType == entityTypeComment {
+		table = "comments"
+	}
+	var (
+		author    string
+		opID      string
+		lamport   int64
+		deletedAt int64
+	)
+	err = tx.QueryRow(`SELECT pubkey, current_op_id, lamport, deleted_at_lamport FROM `+table+` WHERE id = ?;`, record.entityID).Scan(&author, &opID, &lamport, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(author) != record.author {
+		return false, errRevisionAuthorMismatch
+	}
+	current := resolveCurrentVersion(lamport, author, opID, record.entityID)
+	incoming := LamportVersion{Lamport: record.lamport, Author: record.author, OpID: record.opID}
+	if deletedAt > 0 || compareLamportVersion(incoming, current) >= 0 {
+		return false, nil
+	}
+
+	if err = a.snapshotCurrentRevisionTx(tx, quotas, record.entityType, record.entityID); err != nil {
+		return false, err
+	}
+	inserted, err := insertRevisionTx(tx, record)
+	if err != nil || !inserted {
+		return false, err
+	}
+	if err = a.storeRevisionBodyTx(tx, quotas, body); err != nil {
+		return false, err
+	}
+	if _, err = pruneRevisionBodiesTx(tx, resolveRevisionRetention(), time.Now().Unix(), record.entityType, record.entityID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// pruneRevisionBodiesTx deletes the bodies of revisions outside the retention
+// policy: beyond the newest Keep revisions of their entity, or replaced by a
+// newer revision more than RetentionDays ago. The newest revision and bodies
+// still shown by a post are always kept. An empty entityID prunes every
+// entity.
+func pruneRevisionBodiesTx(tx *sql.Tx, policy revisionRetention, now int64, entityType string, entityID string) (int, error) {
+	cutoff := now - int64(policy.RetentionDays)*24*3600
+	rows, err := tx.Query(`
+		SELECT content_cid
+		FROM (
+			SELECT content_cid,
+				ROW_NUMBER() OVER newest_first AS position,
+				LAG(timestamp) OVER newest_first AS superseded_at
+			FROM entity_revisions
+			WHERE ? = '' OR (entity_type = ? AND entity_id = ?)
+			WINDOW newest_first AS (PARTITION BY entity_type, entity_id ORDER BY lamport DESC, author_pubkey DESC, op_id DESC)
+		)
+		WHERE content_cid <> ''
+		GROUP BY content_cid
+		HAVING MAX(CASE WHEN position = 1 OR (position <= ? AND superseded_at >= ?) THEN 1 ELSE 0 END) = 0;
+	`, entityID, entityType, entityID, policy.Keep, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	cids := make([]string, 0)
+	for rows.Next() {
+		var cid string
+		if err = rows.Scan(&cid); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		cids = append(cids, cid)
+	}
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	// Pruning one entity must not release a body another entity's history
+	// still keeps; a full pass has already weighed every revision.
+	otherHistory := ``
+	if entityID != "" {
+		otherHistory = `AND NOT EXISTS (
+			SELECT 1 FROM entity_revisions r
+			WHERE r.content_cid = content_blobs.content_cid AND NOT (r.entity_type = ? AND r.entity_id = ?)
+		)`
+	}
+	released := 0
+	for _, cid := range cids {
+		args := []any{cid}
+		if entityID != "" {
+			args = append(args, entityType, entityID)
+		}
+		result, err := tx.Exec(`
+			DELETE FROM content_blobs
+			WHERE content_cid = ? AND pinned = 0
+				AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.content_cid = content_blobs.content_cid)
+				`+otherHistory+`;
+		`, args...)
+		if err != nil {
+			return released, err
+		}
+		if affected, err := result.RowsAffected(); err == nil {
+			released += int(affected)
+		}
+	}
+	return released, nil
+}
+
+// deleteEntityRevisionsTx drops the history of an entity whose tombstone is
+// collected, with the bodies nothing else uses.
+func deleteEntityRevisionsTx(tx *sql.Tx, entityType string, entityID string) error {
+	if _, err := tx.Exec(`
+		DELETE FROM content_blobs
+		WHERE pinned = 0
+			AND content_cid IN (SELECT content_cid FROM entity_revisions WHERE entity_type = ? AND entity_id = ?)
+			AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.content_cid = content_blobs.content_cid)
+			AND NOT EXISTS (
+				SELECT 1 FROM entity_revisions r
+				WHERE r.content_cid = content_blobs.content_cid AND NOT (r.entity_type = ? AND r.entity_id = ?)
+			);
+	`, entityType, entityID, entityType, entityID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM entity_revisions WHERE entity_type = ? AND entity_id = ?;`, entityType, entityID)
+	return err
+}
+
+// GetPostRevisions lists the versions of a post, oldest first.
+func (a *App) GetPostRevisions(postID string) ([]EntityRevision, error) {
+	return a.getEntityRevisions(entityTypePost, postID)
+}
+
+// GetCommentRevisions lists the versions of a comment, oldest first.
+func (a *App) GetCommentRevisions(commentID string) ([]EntityRevision, error) {
+	return a.getEntityRevisions(entityTypeComment, commentID)
+}
+
+func (a *App) getEntityRevisions(entityType string, entityID string) ([]EntityRevision, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	entityID = strings.TrimSpace(entityID)
+	if entityID == "" {
+		return nil, fmt.Errorf("%s id is required", entityType)
+	}
+
+	var (
+		author      string
+		opID        string
+		lamport     int64
+		deletedAt   int64
+		currentBody string
+		postID      string
+		zone        string
+	)
+	var err error
+	if entityType == entityTypePost {
+		postID = entityID
+		err = a.db.QueryRow(`
+			SELECT pubkey, current_op_id, lamport, deleted_at_lamport, zone
+			FROM messages
+			WHERE id = ?;
+		`, entityID).Scan(&author, &opID, &lamport, &deletedAt, &zone)
+	} else {
+		err = a.db.QueryRow(`
+			SELECT c.pubkey, c.current_op_id, c.lamport, c.deleted_at_lamport, c.body, c.post_id, COALESCE(m.zone, '')
+			FROM comments c
+			LEFT JOIN messages m ON m.id = c.post_id
+			WHERE c.id = ?;
+		`, entityID).Scan(&author, &opID, &lamport, &deletedAt, &currentBody, &postID, &zone)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s not found", entityType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt > 0 {
+		return []EntityRevision{}, nil
+	}
+	currentOpID := resolveCurrentVersion(lamport, author, opID, entityID).OpID
+
+	rows, err := a.db.Query(`
+		SELECT r.op_id, r.author_pubkey, r.lamport, r.timestamp, r.content_cid, r.digest_json, cb.body
+		FROM entity_revisions r
+		LEFT JOIN content_blobs cb ON cb.content_cid = r.content_cid
+		WHERE r.entity_type = ? AND r.entity_id = ?
+		ORDER BY r.lamport ASC, r.author_pubkey ASC, r.op_id ASC;
+	`, entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]EntityRevision, 0)
+	knownOpIDs := make([]string, 0)
+	previousBody, previousAvailable := "", true
+	for rows.Next() {
+		var (
+			revision   EntityRevision
+			digestJSON string
+			body       sql.NullString
+		)
+		if err = rows.Scan(&revision.OpID, &revision.Author, &revision.Lamport, &revision.Timestamp, &revision.ContentCID, &digestJSON, &body); err != nil {
+			return nil, err
+		}
+		revision.EntityType = entityType
+		revision.EntityID = entityID
+		revision.Current = revision.OpID == currentOpID
+		if entityType == entityTypePost {
+			var digest SyncPostDigest
+			if json.Unmarshal([]byte(digestJSON), &digest) == nil {
+				revision.Title = digest.Title
+			}
+		}
+
+		text, available := body.String, body.Valid
+		switch {
+		case revision.ContentCID == "":
+			text, available = "", true
+		case entityType == entityTypeComment && revision.Current:
+			text, available = currentBody, true
+		}
+		revision.BodyAvailable = available
+		if available && previousAvailable {
+			revision.Diff = diffRevisionLines(previousBody, text)
+		}
+		previousBody, previousAvailable = text, available
+
+		revisions = append(revisions, revision)
+		knownOpIDs = append(knownOpIDs, revision.OpID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if zone == "public" {
+		go a.requestRevisionSync(entityType, entityID, postID, knownOpIDs)
+	}
+	return revisions, nil
+}
+
+// diffRevisionLines compares two bodies line by line and returns runs of
+// equal, deleted and inserted lines. Large changes that would need more than
+// revisionDiffMaxCells comparisons are shown as a full replacement.
+func diffRevisionLines(before string, after string) []RevisionDiffChunk {
+	oldLines := splitRevisionLines(before)
+	newLines := splitRevisionLines(after)
+
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	chunks := make([]RevisionDiffChunk, 0, 4)
+	add := func(op string, line string) {
+		if last := len(chunks) - 1; last >= 0 && chunks[last].Op == op {
+			chunks[last].Text += "\n" + line
+			return
+		}
+		chunks = append(chunks, RevisionDiffChunk{Op: op, Text: line})
+	}
+
+	for _, line := range oldLines[:prefix] {
+		add(revisionDiffEqual, line)
+	}
+	removed := oldLines[prefix : len(oldLines)-suffix]
+	added := newLines[prefix : len(newLines)-suffix]
+	i, j := 0, 0
+	if len(removed)*len(added) <= revisionDiffMaxCells {
+		// lcs[i][j] is the longest common subsequence of removed[i:] and added[j:].
+		lcs := make([][]int, len(removed)+1)
+		for row := range lcs {
+			lcs[row] = make([]int, len(added)+1)
+		}
+		for row := len(removed) - 1; row >= 0; row-- {
+			for col := len(added) - 1; col >= 0; col-- {
+				if removed[row] == added[col] {
+					lcs[row][col] = lcs[row+1][col+1] + 1
+				} else {
+					lcs[row][col] = max(lcs[row+1][col], lcs[row][col+1])
+				}
+			}
+		}
+		for i < len(removed) && j < len(added) {
+			switch {
+			case removed[i] == added[j]:
+				add(revisionDiffEqual, removed[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				add(revisionDiffDelete, removed[i])
+				i++
+			default:
+				add(revisionDiffInsert, added[j])
+				j++
+			}
+		}
+	}
+	for ; i < len(removed); i++ {
+		add(revisionDiffDelete, removed[i])
+	}
+	for ; j < len(added); j++ {
+		add(revisionDiffInsert, added[j])
+	}
+	for _, line := range oldLines[len(oldLines)-suffix:] {
+		add(revisionDiffEqual, line)
+	}
+	return chunks
+}
+
+func splitRevisionLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// requestRevisionSync asks connected peers for the revisions of an entity
+// that are not in knownOpIDs. postID is the entity's post, and the request
+// goes to the topic of its sub, where the answers come back.
+func (a *App) requestRevisionSync(entityType string, entityID string, postID string, knownOpIDs []string) {
+	a.p2pMu.Lock()
+	host := a.p2pHost
+	a.p2pMu.Unlock()
+	if host == nil || len(host.Network().Peers()) == 0 {
+		return
+	}
+
+	key := entityType + ":" + entityID
+	now := time.Now()
+	a.revisionSyncMu.Lock()
+	if a.revisionSyncAsked == nil {
+		a.revisionSyncAsked = make(map[string]time.Time)
+	}
+	if last, ok := a.revisionSyncAsked[key]; ok && now.Sub(last) < revisionSyncInterval {
+		a.revisionSyncMu.Unlock()
+		return
+	}
+	a.revisionSyncAsked[key] = now
+	for other, at := range a.revisionSyncAsked {
+		if now.Sub(at) >= revisionSyncInterval {
+			delete(a.revisionSyncAsked, other)
+		}
+	}
+	a.revisionSyncMu.Unlock()
+
+	topic, ctx := a.listenOnSubTopic(a.lookupPostSubID(postID), revisionSyncListenWindow)
+	if topic == nil || ctx == nil {
+		return
+	}
+	localPeerID := host.ID().String()
+	request := IncomingMessage{
+		Type:            messageTypeRevisionSyncRequest,
+		SchemaVersion:   lamportSchemaV2,
+		AuthScope:       authScopeUser,
+		RequestID:       buildMessageID(localPeerID, "revision-sync:"+key, now.UnixNano()),
+		RequesterPeerID: localPeerID,
+		PostID:          postID,
+		RevisionOpIDs:   knownOpIDs,
+		Timestamp:       now.Unix(),
+	}
+	if entityType == entityTypeComment {
+		request.CommentID = entityID
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return
+	}
+	_ = topic.Publish(ctx, payload)
+}
+
+func (a *App) handleRevisionSyncRequest(localPeerID string, sender string, message IncomingMessage) {
+	requester := strings.TrimSpace(message.RequesterPeerID)
+	requestID := strings.TrimSpace(message.RequestID)
+	sender = strings.TrimSpace(sender)
+	if requester == "" || requestID == "" || requester == localPeerID || sender == "" {
+		return
+	}
+	if !a.allowFetchRequest(sender, messageTypeRevisionSyncRequest) {
+		return
+	}
+
+	response := IncomingMessage{
+		Type:            messageTypeRevisionSyncResponse,
+		SchemaVersion:   lamportSchemaV2,
+		AuthScope:       authScopeUser,
+		RequestID:       requestID,
+		RequesterPeerID: requester,
+		ResponderPeerID: localPeerID,
+		PostID:          strings.TrimSpace(message.PostID),
+		CommentID:       strings.TrimSpace(message.CommentID),
+		Timestamp:       time.Now().Unix(),
+	}
+	var err error
+	response.Summaries, response.CommentSummaries, response.RevisionBodies, err = a.revisionSyncPayload(message)
+	if err != nil {
+		if a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "revision.sync_request failed request_id=%s err=%v", requestID, err)
+		}
+		return
+	}
+	if len(response.Summaries) == 0 && len(response.CommentSummaries) == 0 {
+		return
+	}
+
+	topic, ctx := a.topicForPost(response.PostID)
+	if topic == nil || ctx == nil {
+		return
+	}
+	payload, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	_ = topic.Publish(ctx, payload)
+}
+
+// revisionSyncPayload collects the revisions a request asks for: those of a
+// public post, or of a comment on one, that the requester does not list,
+// newest first and within the response limits. Post revisions come with
+// their bodies keyed by CID; comment digests carry their body.
+func (a *App) revisionSyncPayload(message IncomingMessage) ([]SyncPostDigest, []SyncCommentDigest, map[string]string, error) {
+	postID := strings.TrimSpace(message.PostID)
+	commentID := strings.TrimSpace(message.CommentID)
+	if postID == "" {
+		return nil, nil, nil, nil
+	}
+	visible, err := a.listPublicPostDigestsByIDs([]string{postID})
+	if err != nil || len(visible) == 0 || visible[0].Deleted {
+		return nil, nil, nil, err
+	}
+	entityType, entityID := entityTypePost, postID
+	if commentID != "" {
+		var commentPostID string
+		var deletedAt int64
+		err = a.db.QueryRow(`SELECT post_id, deleted_at_lamport FROM comments WHERE id = ?;`, commentID).Scan(&commentPostID, &deletedAt)
+		if errors.Is(err, sql.ErrNoRows) || commentPostID != postID || deletedAt > 0 {
+			return nil, nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		entityType, entityID = entityTypeComment, commentID
+	}
+
+	known := make(map[string]bool, len(message.RevisionOpIDs))
+	for _, opID := range message.RevisionOpIDs {
+		known[strings.TrimSpace(opID)] = true
+	}
+
+	rows, err := a.db.Query(`
+		SELECT r.op_id, r.digest_json, COALESCE(cb.body, ''), COALESCE(s.signature, '')
+		FROM entity_revisions r
+		LEFT JOIN content_blobs cb ON cb.content_cid = r.content_cid
+		LEFT JOIN entity_signatures s ON s.op_id = r.op_id
+		WHERE r.entity_type = ? AND r.entity_id = ?
+		ORDER BY r.lamport DESC, r.author_pubkey DESC, r.op_id DESC;
+	`, entityType, entityID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+
+	var (
+		posts    []SyncPostDigest
+		comments []SyncCommentDigest
+		bodies   = make(map[string]string)
+		size     int
+	)
+	for rows.Next() && len(posts)+len(comments) < revisionSyncMaxRecords {
+		var opID, digestJSON, body, signature string
+		if err = rows.Scan(&opID, &digestJSON, &body, &signature); err != nil {
+			return nil, nil, nil, err
+		}
+		if known[opID] {
+			continue
+		}
+		size += len(digestJSON) + len(body)
+		if size > revisionSyncMaxBytes {
+			break
+		}
+		if entityType == entityTypePost {
+			var digest SyncPostDigest
+			if err = json.Unmarshal([]byte(digestJSON), &digest); err != nil {
+				continue
+			}
+			digest.Signature = signature
+			posts = append(posts, digest)
+			if body != "" {
+				bodies[digest.ContentCID] = body
+			}
+			continue
+		}
+		var digest SyncCommentDigest
+		if err = json.Unmarshal([]byte(digestJSON), &digest); err != nil {
+			continue
+		}
+		digest.Signature = signature
+		digest.Body = body
+		comments = append(comments, digest)
+	}
+	return posts, comments, bodies, rows.Err()
+}
+
+func (a *App) handleRevisionSyncResponse(localPeerID string, sender string, message IncomingMessage) {
+	if strings.TrimSpace(message.RequesterPeerID) != strings.TrimSpace(localPeerID) {
+		return
+	}
+	responder := strings.TrimSpace(sender)
+	if responder == "" || responder == localPeerID {
+		return
+	}
+	if !a.allowFetchRequest(responder, messageTypeRevisionSyncResponse) {
+		return
+	}
+
+	recorded, err := a.applyRevisionSync(message)
+	if err != nil && a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "revision.sync_response failed responder=%s err=%v", responder, err)
+	}
+	if recorded == 0 || a.ctx == nil {
+		return
+	}
+	runtime.LogInfof(a.ctx, "revision.sync_response responder=%s recorded=%d", responder, recorded)
+	runtime.EventsEmit(a.ctx, "revisions:updated", map[string]string{"postId": strings.TrimSpace(message.PostID), "commentId": strings.TrimSpace(message.CommentID)})
+}
+
+// applyRevisionSync verifies the revisions of a response against their
+// signatures and bodies and records those this node lacks. Revisions that
+// fail verification are skipped.
+func (a *App) applyRevisionSync(message IncomingMessage) (int, error) {
+	recorded := 0
+	var firstErr error
+	keep := func(record revisionRecord, body string, signed IncomingMessage) {
+		ok, err := a.recordPastRevision(record, body)
+		if err == nil && ok {
+			err = a.recordIncomingMessageSignature(signed)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if ok {
+			recorded++
+		}
+	}
+
+	postID := strings.TrimSpace(message.PostID)
+	for _, digest := range message.Summaries {
+		if digest.ID != postID || digest.Deleted || a.verifyPostDigestSignature(digest) != nil {
+			continue
+		}
+		body := message.RevisionBodies[digest.ContentCID]
+		if body != "" && verifyBlobCID(blobKindContent, digest.ContentCID, []byte(body)) != nil {
+			continue
+		}
+		keep(postRevisionRecord(digest), body, postDigestSigningMessage(digest))
+	}
+
+	commentID := strings.TrimSpace(message.CommentID)
+	for _, digest := range message.CommentSummaries {
+		if digest.ID != commentID || digest.PostID != postID || digest.Deleted || a.verifyCommentDigestSignature(digest) != nil {
+			continue
+		}
+		keep(commentRevisionRecord(digest), digest.Body, commentDigestSigningMessage(digest))
+	}
+	return recorded, firstErr
+}
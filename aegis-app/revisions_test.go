@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestRevisionHistoryRecordsDiffsAndSyncs(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	t.Setenv("AEGIS_REVISION_KEEP", "2")
+	source := newTestApp(t)
+	now := time.Now().Unix()
+
+	versions := []ForumMessage{
+		{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Plan", Body: "one\ntwo\nthree", Timestamp: now - 30, Lamport: 10, Zone: "public", SubID: defaultSubID},
+		{ID: "p-1", Pubkey: "alice", OpID: "op-3", Title: "Plan v3", Body: "one\n2\nthree\nfour", Timestamp: now - 10, Lamport: 30, Zone: "public", SubID: defaultSubID},
+		// Arrives after the newer version and is kept as history only.
+		{ID: "p-1", Pubkey: "alice", OpID: "op-2", Title: "Plan v2", Body: "one\ntwo\nthree\nfour", Timestamp: now - 20, Lamport: 20, Zone: "public", SubID: defaultSubID},
+	}
+	for _, version := range versions {
+		if _, err := source.insertMessage(version); err != nil {
+			t.Fatalf("insert %s: %v", version.OpID, err)
+		}
+	}
+	if post, err := source.GetPostBodyByID("p-1"); err != nil || post.Body != "one\n2\nthree\nfour" {
+		t.Fatalf("late version replaced the post: %+v, err %v", post, err)
+	}
+
+	revisions, err := source.GetPostRevisions("p-1")
+	if err != nil {
+		t.Fatalf("get revisions: %v", err)
+	}
+	opIDs := make([]string, 0, len(revisions))
+	for _, revision := range revisions {
+		opIDs = append(opIDs, revision.OpID)
+	}
+	if !reflect.DeepEqual(opIDs, []string{"op-1", "op-2", "op-3"}) || !revisions[2].Current || revisions[1].Title != "Plan v2" {
+		t.Fatalf("unexpected revisions %+v", revisions)
+	}
+	// The oldest body falls outside AEGIS_REVISION_KEEP=2.
+	if revisions[0].BodyAvailable || revisions[0].Diff != nil {
+		t.Fatalf("expected the oldest body to be released, got %+v", revisions[0])
+	}
+	want := []RevisionDiffChunk{{Op: "equal", Text: "one"}, {Op: "delete", Text: "two"}, {Op: "insert", Text: "2"}, {Op: "equal", Text: "three\nfour"}}
+	if !reflect.DeepEqual(revisions[2].Diff, want) {
+		t.Fatalf("unexpected diff %+v", revisions[2].Diff)
+	}
+
+	// A comment keeps the body it had before an edit.
+	for _, comment := range []Comment{
+		{ID: "c-1", PostID: "p-1", Pubkey: "bob", OpID: "op-c1", Body: "first take", Timestamp: now - 5, Lamport: 40},
+		{ID: "c-1", PostID: "p-1", Pubkey: "bob", OpID: "op-c2", Body: "second take", Timestamp: now - 4, Lamport: 41},
+	} {
+		if _, err = source.insertComment(comment); err != nil {
+			t.Fatalf("insert comment %s: %v", comment.OpID, err)
+		}
+	}
+	commentRevisions, err := source.GetCommentRevisions("c-1")
+	if err != nil || len(commentRevisions) != 2 {
+		t.Fatalf("unexpected comment revisions %+v, err %v", commentRevisions, err)
+	}
+	want = []RevisionDiffChunk{{Op: "delete", Text: "first take"}, {Op: "insert", Text: "second take"}}
+	if !reflect.DeepEqual(commentRevisions[1].Diff, want) {
+		t.Fatalf("unexpected comment diff %+v", commentRevisions[1].Diff)
+	}
+
+	// A peer that only saw the current version fills in the history.
+	target := newTestApp(t)
+	if _, err = target.insertMessage(versions[1]); err != nil {
+		t.Fatalf("seed target: %v", err)
+	}
+	summaries, _, bodies, err := source.revisionSyncPayload(IncomingMessage{PostID: "p-1", RevisionOpIDs: []string{"op-3"}})
+	if err != nil || len(summaries) != 2 {
+		t.Fatalf("unexpected sync payload %+v, err %v", summaries, err)
+	}
+	recorded, err := target.applyRevisionSync(IncomingMessage{PostID: "p-1", Summaries: summaries, RevisionBodies: bodies})
+	if err != nil || recorded != 2 {
+		t.Fatalf("expected 2 synced revisions, got %d, err %v", recorded, err)
+	}
+	synced, err := target.GetPostRevisions("p-1")
+	if err != nil || len(synced) != 3 || synced[1].OpID != "op-2" || !synced[1].BodyAvailable {
+		t.Fatalf("unexpected synced revisions %+v, err %v", synced, err)
+	}
+	if recorded, _ = target.applyRevisionSync(IncomingMessage{PostID: "p-1", Summaries: summaries, RevisionBodies: bodies}); recorded != 0 {
+		t.Fatalf("second sync recorded %d revisions", recorded)
+	}
+}
+
+func TestRevisionSyncTravelsOnThePostSubTopic(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	t.Setenv("AEGIS_FETCH_REQUEST_LIMIT", "1")
+	app := newTestApp(t)
+	if _, err := app.insertMessage(ForumMessage{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Draft", Body: "First version.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "tech"}); err != nil {
+		t.Fatalf("insert post: %v", err)
+	}
+	startTestP2P(t, app)
+	observer := newTestPubsubPeer(t, app)
+	control := observer.subscribe(t, app, forumTopicName)
+	tech := observer.subscribe(t, app, subTopicName("tech"))
+
+	expectOnSubTopic := func(messageType string) {
+		t.Helper()
+		message := observer.next(tech)
+		if message == nil {
+			t.Fatalf("expected %s on the sub topic", messageType)
+		}
+		var incoming IncomingMessage
+		if err := json.Unmarshal(message.Data, &incoming); err != nil || incoming.Type != messageType {
+			t.Fatalf("expected %s on the sub topic, got %q err=%v", messageType, incoming.Type, err)
+		}
+	}
+
+	app.requestRevisionSync(entityTypePost, "p-1", "p-1", nil)
+	expectOnSubTopic(messageTypeRevisionSyncRequest)
+
+	app.handleRevisionSyncRequest(app.p2pHost.ID().String(), observer.host.ID().String(), IncomingMessage{
+		Type:            messageTypeRevisionSyncRequest,
+		RequestID:       "req-1",
+		RequesterPeerID: "claimed-requester",
+		PostID:          "p-1",
+	})
+	expectOnSubTopic(messageTypeRevisionSyncResponse)
+	// The request counts against the peer that published it, whatever
+	// requester it names.
+	if app.allowFetchRequest(observer.host.ID().String(), messageTypeRevisionSyncRequest) {
+		t.Fatalf("expected the sender to have used its request budget")
+	}
+
+	if message := observer.next(control); message != nil {
+		t.Fatalf("expected nothing on the control topic, got %s", message.Data)
+	}
+}
+
+func TestRevisionSyncAnswerReachesRequesterOutsideTheSub(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	t.Setenv("AEGIS_JOIN_ALL_SUB_TOPICS", "0")
+	versions := []ForumMessage{
+		{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Draft", Body: "First version.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: "tech"},
+		{ID: "p-1", Pubkey: "alice", OpID: "op-2", Title: "Draft", Body: "Second version.", Timestamp: 20, Lamport: 20, Zone: "public", SubID: "tech"},
+	}
+	responder := newTestApp(t)
+	for _, version := range versions {
+		if _, err := responder.insertMessage(version); err != nil {
+			t.Fatalf("insert %s: %v", version.OpID, err)
+		}
+	}
+	requester := newTestApp(t)
+	if _, err := requester.insertMessage(versions[1]); err != nil {
+		t.Fatalf("seed requester: %v", err)
+	}
+
+	startTestP2P(t, responder)
+	if _, err := responder.SubscribeSub("tech"); err != nil {
+		t.Fatalf("follow tech: %v", err)
+	}
+	startTestP2P(t, requester)
+	if err := requester.p2pHost.Connect(context.Background(), peer.AddrInfo{ID: responder.p2pHost.ID(), Addrs: responder.p2pHost.Addrs()}); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	waitForTestCondition(t, "requester to see the responder on the tech topic", func() bool {
+		topic, _ := requester.topicForSub("tech")
+		return topicHasPeer(topic.ListPeers(), responder.p2pHost.ID())
+	})
+
+	// The requester does not follow tech, yet the answer on the tech topic
+	// still reaches it.
+	requester.requestRevisionSync(entityTypePost, "p-1", "p-1", []string{"op-2"})
+	waitForTestCondition(t, "the missing revision", func() bool {
+		return countRows(t, requester, `SELECT COUNT(1) FROM entity_revisions WHERE op_id = 'op-1';`) == 1
+	})
+	if got := requester.followedSubTopicIDs(); !slices.Contains(got, "tech") {
+		t.Fatalf("expected tech to be subscribed while the answer is awaited, got %v", got)
+	}
+
+	// Once the window has passed the topic is left again.
+	requester.p2pMu.Lock()
+	requester.p2pSubTopics["tech"].listenUntil = time.Now()
+	requester.p2pMu.Unlock()
+	requester.syncSubTopicMembership("tech")
+	if got := requester.followedSubTopicIDs(); slices.Contains(got, "tech") {
+		t.Fatalf("expected tech to be left after the listen window, got %v", got)
+	}
+}
@@ -0,0 +1,624 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Full-text search over posts and comments. search_docs has one row per
+// indexed post or comment with the columns searches filter on, and the FTS5
+// table search_fts holds its title and body under the same rowid. The index
+// is refreshed from the messages and comments rows after every write that
+// can change them, and tombstoned entities are dropped from it. A post body
+// is indexed once its content blob is stored and stays searchable after the
+// blob is evicted.
+//
+// The refresh runs after the write commits, so it can fail on its own. A
+// failed refresh marks the index stale, and the next maintenance run
+// rebuilds a stale index. Maintenance also compares the index with the
+// messages and comments rows and refreshes any entry that has drifted, which
+// catches a failure whose stale mark was lost too.
+//
+// unicode61 splits on spaces and punctuation, which leaves a run of Chinese
+// or Japanese text as one token. Text is therefore segmented before it is
+// indexed or queried: every CJK character becomes its own token, and a CJK
+// query term becomes a phrase, so "搜索" matches the adjacent characters
+// 搜 and 索 anywhere in a sentence.
+const (
+	searchIndexVersion = 1
+
+	searchKindPost    = "post"
+	searchKindComment = "comment"
+
+	searchSegmentBreak = '\u200b'
+	searchSnippetStart = "\x02"
+	searchSnippetEnd   = "\x03"
+	searchSnippetWords = 24
+)
+
+var errInvalidSearchCursor = errors.New("invalid search cursor")
+
+type SearchRequest struct {
+	Query  string `json:"query"`
+	Kind   string `json:"kind"`
+	SubID  string `json:"subId"`
+	Author string `json:"author"`
+	Since  int64  `json:"since"`
+	Until  int64  `json:"until"`
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+}
+
+type SearchSnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match"`
+}
+
+type SearchHit struct {
+	Kind      string              `json:"kind"`
+	ID        string              `json:"id"`
+	PostID    string              `json:"postId"`
+	PostTitle string              `json:"postTitle"`
+	SubID     string              `json:"subId"`
+	Pubkey    string              `json:"pubkey"`
+	Timestamp int64               `json:"timestamp"`
+	Rank      float64             `json:"rank"`
+	Snippet   []SearchSnippetPart `json:"snippet"`
+}
+
+type SearchPage struct {
+	Hits       []SearchHit `json:"hits"`
+	NextCursor string      `json:"nextCursor"`
+}
+
+func ensureSearchIndex(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS search_docs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			post_id TEXT NOT NULL,
+			pubkey TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			UNIQUE(kind, entity_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_search_docs_post ON search_docs(post_id);`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS search_fts USING fts5(
+			title,
+			body,
+			tokenize = 'unicode61 remove_diacritics 2',
+			prefix = '2 3'
+		);`,
+		`CREATE TABLE IF NOT EXISTS search_index_state (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			version INTEGER NOT NULL,
+			rebuilt_at INTEGER NOT NULL
+		);`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	var version int
+	err := db.QueryRow(`SELECT version FROM search_index_state WHERE id = 1;`).Scan(&version)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if version == searchIndexVersion {
+		return nil
+	}
+	return rebuildSearchIndex(db)
+}
+
+// rebuildSearchIndex reindexes every post and comment. It runs when the
+// index is first created and whenever searchIndexVersion changes.
+func rebuildSearchIndex(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM search_docs;`); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM search_fts;`); err != nil {
+		return err
+	}
+
+	for _, source := range []struct{ kind, query string }{
+		{searchKindPost, `SELECT id FROM messages WHERE visibility <> 'deleted';`},
+		{searchKindComment, `SELECT id FROM comments WHERE deleted_at_lamport = 0 AND deleted_at = 0;`},
+	} {
+		ids, err := querySearchIDsTx(tx, source.query)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err = refreshSearchDocTx(tx, source.kind, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO search_index_state (id, version, rebuilt_at)
+		VALUES (1, ?, strftime('%s', 'now'))
+		ON CONFLICT(id) DO UPDATE SET
+			version = excluded.version,
+			rebuilt_at = excluded.rebuilt_at;
+	`, searchIndexVersion); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func querySearchIDsTx(tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// reindexForSearch refreshes the index entry of a post or comment after a
+// write. It runs outside the write's transaction, so a broken index never
+// fails a post, comment or sync; a failure marks the index stale for the
+// next maintenance run to rebuild.
+func (a *App) reindexForSearch(kind string, entityIDs ...string) {
+	if a.db == nil || len(entityIDs) == 0 {
+		return
+	}
+
+	err := func() error {
+		tx, err := a.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for _, entityID := range entityIDs {
+			if err = refreshSearchDocTx(tx, kind, strings.TrimSpace(entityID)); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	}()
+	if err == nil {
+		return
+	}
+	markErr := markSearchIndexStale(a.db)
+	if a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "search.index_failed kind=%s ids=%d err=%v mark_err=%v", kind, len(entityIDs), err, markErr)
+	}
+}
+
+// markSearchIndexStale clears the recorded index version, so the next
+// maintenance run or start rebuilds the index.
+func markSearchIndexStale(db *sql.DB) error {
+	_, err := db.Exec(`UPDATE search_index_state SET version = 0 WHERE id = 1;`)
+	return err
+}
+
+// repairSearchIndex rebuilds a stale index, or otherwise refreshes every
+// entry that no longer matches its row: live posts and comments with no
+// entry or an outdated one, and entries whose row is gone or tombstoned. It
+// returns how many entries it rebuilt or refreshed.
+func repairSearchIndex(db *sql.DB) (int64, error) {
+	var version int
+	err := db.QueryRow(`SELECT version FROM search_index_state WHERE id = 1;`).Scan(&version)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if version != searchIndexVersion {
+		if err = rebuildSearchIndex(db); err != nil {
+			return 0, err
+		}
+		var rebuilt int64
+		err = db.QueryRow(`SELECT COUNT(*) FROM search_docs;`).Scan(&rebuilt)
+		return rebuilt, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var repaired int64
+	for _, source := range []struct{ kind, query string }{
+		{searchKindPost, `
+			SELECT m.id
+			FROM messages m
+			LEFT JOIN search_docs d ON d.kind = 'post' AND d.entity_id = m.id
+			WHERE m.visibility <> 'deleted'
+			  AND (d.id IS NULL OR d.pubkey <> m.pubkey OR d.timestamp <> m.timestamp)
+			UNION
+			SELECT d.entity_id
+			FROM search_docs d
+			WHERE d.kind = 'post'
+			  AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.id = d.entity_id AND m.visibility <> 'deleted');
+		`},
+		{searchKindComment, `
+			SELECT c.id
+			FROM comments c
+			LEFT JOIN search_docs d ON d.kind = 'comment' AND d.entity_id = c.id
+			WHERE c.deleted_at_lamport = 0 AND c.deleted_at = 0
+			  AND (d.id IS NULL OR d.post_id <> c.post_id OR d.pubkey <> c.pubkey OR d.timestamp <> c.timestamp)
+			UNION
+			SELECT d.entity_id
+			FROM search_docs d
+			WHERE d.kind = 'comment'
+			  AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.id = d.entity_id AND c.deleted_at_lamport = 0 AND c.deleted_at = 0);
+		`},
+	} {
+		ids, err := querySearchIDsTx(tx, source.query)
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range ids {
+			if err = refreshSearchDocTx(tx, source.kind, id); err != nil {
+				return 0, err
+			}
+		}
+		repaired += int64(len(ids))
+	}
+	return repaired, tx.Commit()
+}
+
+// reindexPostsWithContent refreshes the posts whose body is the given blob.
+func (a *App) reindexPostsWithContent(contentCID string) {
+	if a.db == nil {
+		return
+	}
+
+	rows, err := a.db.Query(`SELECT id FROM messages WHERE content_cid = ? AND visibility <> 'deleted';`, contentCID)
+	if err != nil {
+		return
+	}
+	postIDs := make([]string, 0, 1)
+	for rows.Next() {
+		var postID string
+		if rows.Scan(&postID) == nil {
+			postIDs = append(postIDs, postID)
+		}
+	}
+	rows.Close()
+	a.reindexForSearch(searchKindPost, postIDs...)
+}
+
+// refreshSearchDocTx makes the index entry of a post or comment match its
+// current row, removing it when the row is gone or tombstoned.
+func refreshSearchDocTx(tx *sql.Tx, kind string, entityID string) error {
+	if entityID == "" {
+		return nil
+	}
+
+	var (
+		postID, pubkey, title, body string
+		timestamp                   int64
+		err                         error
+	)
+	switch kind {
+	case searchKindPost:
+		postID = entityID
+		err = tx.QueryRow(`
+			SELECT m.pubkey, m.title, COALESCE(cb.body, m.body), m.timestamp
+			FROM messages m
+			LEFT JOIN content_blobs cb ON cb.content_cid = m.content_cid AND m.content_cid <> ''
+			WHERE m.id = ? AND m.visibility <> 'deleted';
+		`, entityID).Scan(&pubkey, &title, &body, &timestamp)
+	case searchKindComment:
+		err = tx.QueryRow(`
+			SELECT c.post_id, c.pubkey, c.body, c.timestamp
+			FROM comments c
+			WHERE c.id = ? AND c.deleted_at_lamport = 0 AND c.deleted_at = 0;
+		`, entityID).Scan(&postID, &pubkey, &body, &timestamp)
+	default:
+		return errors.New("invalid search kind")
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return removeSearchDocTx(tx, kind, entityID)
+	}
+	if err != nil {
+		return err
+	}
+
+	var docID int64
+	if err = tx.QueryRow(`
+		INSERT INTO search_docs (kind, entity_id, post_id, pubkey, timestamp)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(kind, entity_id) DO UPDATE SET
+			post_id = excluded.post_id,
+			pubkey = excluded.pubkey,
+			timestamp = excluded.timestamp
+		RETURNING id;
+	`, kind, entityID, postID, pubkey, timestamp).Scan(&docID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM search_fts WHERE rowid = ?;`, docID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO search_fts (rowid, title, body) VALUES (?, ?, ?);`, docID, segmentSearchText(title), segmentSearchText(body))
+	return err
+}
+
+func removeSearchDocTx(tx *sql.Tx, kind string, entityID string) error {
+	var docID int64
+	err := tx.QueryRow(`SELECT id FROM search_docs WHERE kind = ? AND entity_id = ?;`, kind, entityID).Scan(&docID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM search_fts WHERE rowid = ?;`, docID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM search_docs WHERE id = ?;`, docID)
+	return err
+}
+
+// Search runs a full-text query over public posts and their comments, best
+// match first. Terms are ANDed; "quoted text" is a phrase, a trailing * makes
+// a term a prefix, and OR between two terms matches either. Kind, SubID,
+// Author, Since and Until narrow the results, and NextCursor, when set,
+// fetches the next page of the same query.
+func (a *App) Search(request SearchRequest) (SearchPage, error) {
+	if a.db == nil {
+		return SearchPage{}, errors.New("database not initialized")
+	}
+
+	viewerPubkey := ""
+	if identity, err := a.getLocalIdentity(); err == nil {
+		viewerPubkey = strings.TrimSpace(identity.PublicKey)
+	}
+	return a.searchIndex(request, viewerPubkey)
+}
+
+// searchIndex runs a search as seen by viewerPubkey, whose own hidden or
+// shadowed posts still match. With no viewer only posts with normal
+// visibility match.
+func (a *App) searchIndex(request SearchRequest, viewerPubkey string) (SearchPage, error) {
+	if a.db == nil {
+		return SearchPage{}, errors.New("database not initialized")
+	}
+
+	page := SearchPage{Hits: make([]SearchHit, 0)}
+	match := buildSearchMatchQuery(request.Query)
+	if match == "" {
+		return page, nil
+	}
+
+	kind := strings.TrimSpace(strings.ToLower(request.Kind))
+	if kind != "" && kind != searchKindPost && kind != searchKindComment {
+		return SearchPage{}, errors.New("invalid search kind")
+	}
+	subID := strings.TrimSpace(request.SubID)
+	if subID != "" {
+		subID = normalizeSubID(subID)
+	}
+	limit := normalizeSearchLimit(request.Limit)
+
+	hasCursor := 0
+	var cursorRank float64
+	var cursorID int64
+	if cursor := strings.TrimSpace(request.Cursor); cursor != "" {
+		var err error
+		if cursorRank, cursorID, err = decodeSearchCursor(cursor); err != nil {
+			return SearchPage{}, err
+		}
+		hasCursor = 1
+	}
+
+	// Title matches weigh five times as much as body matches.
+	rows, err := a.db.Query(`
+		SELECT d.id, d.kind, d.entity_id, d.post_id, m.title, m.sub_id, d.pubkey, d.timestamp,
+			bm25(search_fts, 5.0, 1.0),
+			snippet(search_fts, 1, ?, ?, '…', ?)
+		FROM search_fts
+		JOIN search_docs d ON d.id = search_fts.rowid
+		JOIN messages m ON m.id = d.post_id
+		WHERE search_fts MATCH ?
+			AND m.zone = 'public'
+			AND (m.visibility = 'normal' OR (? <> '' AND m.pubkey = ?))
+			AND (? = '' OR d.kind = ?)
+			AND (? = '' OR m.sub_id = ?)
+			AND (? = '' OR d.pubkey = ?)
+			AND (? <= 0 OR d.timestamp >= ?)
+			AND (? <= 0 OR d.timestamp <= ?)
+			AND (? = 0 OR bm25(search_fts, 5.0, 1.0) > ? OR (bm25(search_fts, 5.0, 1.0) = ? AND d.id > ?))
+		ORDER BY bm25(search_fts, 5.0, 1.0) ASC, d.id ASC
+		LIMIT ?;
+	`,
+		searchSnippetStart, searchSnippetEnd, searchSnippetWords,
+		match,
+		viewerPubkey, viewerPubkey,
+		kind, kind,
+		subID, subID,
+		strings.TrimSpace(request.Author), strings.TrimSpace(request.Author),
+		request.Since, request.Since,
+		request.Until, request.Until,
+		hasCursor, cursorRank, cursorRank, cursorID,
+		limit+1,
+	)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "fts5") {
+			return SearchPage{}, errors.New("invalid search query")
+		}
+		return SearchPage{}, err
+	}
+	defer rows.Close()
+
+	var lastID int64
+	for rows.Next() {
+		var hit SearchHit
+		var docID int64
+		var snippet string
+		if err = rows.Scan(&docID, &hit.Kind, &hit.ID, &hit.PostID, &hit.PostTitle, &hit.SubID, &hit.Pubkey, &hit.Timestamp, &hit.Rank, &snippet); err != nil {
+			return SearchPage{}, err
+		}
+		if len(page.Hits) == limit {
+			last := page.Hits[limit-1]
+			page.NextCursor = encodeSearchCursor(last.Rank, lastID)
+			break
+		}
+		hit.Snippet = splitSearchSnippet(snippet)
+		page.Hits = append(page.Hits, hit)
+		lastID = docID
+	}
+	return page, rows.Err()
+}
+
+func encodeSearchCursor(rank float64, docID int64) string {
+	raw := strconv.FormatFloat(rank, 'g', -1, 64) + ":" + strconv.FormatInt(docID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string) (float64, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, errInvalidSearchCursor
+	}
+	rankText, idText, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, 0, errInvalidSearchCursor
+	}
+	rank, err := strconv.ParseFloat(rankText, 64)
+	if err != nil {
+		return 0, 0, errInvalidSearchCursor
+	}
+	docID, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		return 0, 0, errInvalidSearchCursor
+	}
+	return rank, docID, nil
+}
+
+func isSearchCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// segmentSearchText puts a zero-width space around every CJK character.
+// unicode61 treats it as a separator, so each character is indexed as a
+// token, and removing it restores the original text.
+func segmentSearchText(text string) string {
+	var builder strings.Builder
+	builder.Grow(len(text) + len(text)/2)
+	for _, r := range text {
+		if r == searchSegmentBreak {
+			continue
+		}
+		if isSearchCJK(r) {
+			builder.WriteRune(searchSegmentBreak)
+			builder.WriteRune(r)
+			builder.WriteRune(searchSegmentBreak)
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// searchTokens splits text the way the index does: on anything that is not
+// a letter, digit or mark, with CJK characters as single tokens.
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(segmentSearchText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
+
+// buildSearchMatchQuery turns user input into an FTS5 MATCH expression. Every
+// term is quoted, so FTS5 operators and column filters typed by the user are
+// searched for as text. It returns "" when the input has nothing to match.
+func buildSearchMatchQuery(query string) string {
+	terms := make([]string, 0)
+	pendingOr := false
+	for len(query) > 0 {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if query == "" {
+			break
+		}
+
+		var text string
+		if query[0] == '"' {
+			end := strings.IndexByte(query[1:], '"')
+			if end < 0 {
+				text, query = query[1:], ""
+			} else {
+				text, query = query[1:end+1], query[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(query, unicode.IsSpace)
+			if end < 0 {
+				end = len(query)
+			}
+			text, query = query[:end], query[end:]
+			if text == "OR" {
+				pendingOr = len(terms) > 0
+				continue
+			}
+		}
+
+		prefix := strings.HasSuffix(text, "*")
+		tokens := searchTokens(strings.TrimSuffix(text, "*"))
+		if len(tokens) == 0 {
+			continue
+		}
+		term := `"` + strings.Join(tokens, " ") + `"`
+		if prefix {
+			term += "*"
+		}
+		if pendingOr {
+			terms[len(terms)-1] = terms[len(terms)-1] + " OR " + term
+			pendingOr = false
+			continue
+		}
+		terms = append(terms, term)
+	}
+
+	for index, term := range terms {
+		if strings.Contains(term, " OR ") {
+			terms[index] = "(" + term + ")"
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// splitSearchSnippet turns an FTS5 snippet into plain and matched parts and
+// drops the breaks segmentSearchText put around CJK characters.
+func splitSearchSnippet(snippet string) []SearchSnippetPart {
+	snippet = strings.ReplaceAll(snippet, string(searchSegmentBreak), "")
+	parts := make([]SearchSnippetPart, 0, 3)
+	for index, chunk := range strings.Split(snippet, searchSnippetStart) {
+		text, rest, matched := strings.Cut(chunk, searchSnippetEnd)
+		if index == 0 || !matched {
+			text, rest = chunk, ""
+		}
+		if text != "" {
+			parts = append(parts, SearchSnippetPart{Text: text, Match: index > 0 && matched})
+		}
+		if rest != "" {
+			parts = append(parts, SearchSnippetPart{Text: rest})
+		}
+	}
+	return parts
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Network search asks peers for posts the local index does not hold yet. The
+// requester publishes SEARCH_REQUEST on the global topic; every peer that
+// serves searches runs the query against its own index and answers with
+// signed post digests, best match first. The requester collects answers for
+// a short window, merges them by post, verifies them like anti-entropy
+// digests and adds them to the post index, where later syncs and body
+// fetches pick them up. Peers serve searches when AEGIS_SEARCH_SERVICE says
+// so, and otherwise when the active identity allows search in its privacy
+// settings. Only posts with normal visibility are offered to peers, so a
+// node never hands out its own hidden or shadow-banned posts. Both sides
+// count requests against the fetch rate limit, and both rate limits and the
+// merge key each answer by the peer that delivered the pubsub message rather
+// than the peer ID the message claims.
+const (
+	networkSearchMaxResults = 50
+	networkSearchWaitSec    = 3
+)
+
+var errNetworkSearchNoPeers = errors.New("network search no peers")
+
+type NetworkSearchHit struct {
+	PostID    string `json:"postId"`
+	Title     string `json:"title"`
+	SubID     string `json:"subId"`
+	Pubkey    string `json:"pubkey"`
+	Timestamp int64  `json:"timestamp"`
+	Peers     int    `json:"peers"`
+}
+
+type NetworkSearchResult struct {
+	Hits       []NetworkSearchHit `json:"hits"`
+	Responders int                `json:"responders"`
+	Inserted   int                `json:"inserted"`
+}
+
+// networkSearchCandidate is one post as merged from the answers: the newest
+// version seen, how many peers returned it and its best rank among them.
+type networkSearchCandidate struct {
+	digest   SyncPostDigest
+	peers    map[string]struct{}
+	bestRank int
+}
+
+func resolveSearchServiceSetting() (bool, bool) {
+	raw := strings.TrimSpace(strings.ToLower(os.Getenv("AEGIS_SEARCH_SERVICE")))
+	switch raw {
+	case "1", "true", "yes", "on":
+		return true, true
+	case "0", "false", "no", "off":
+		return false, true
+	}
+	return false, false
+}
+
+func resolveNetworkSearchWait() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("AEGIS_SEARCH_WAIT_SEC"))
+	if raw == "" {
+		return networkSearchWaitSec * time.Second
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 || value > 60 {
+		return networkSearchWaitSec * time.Second
+	}
+	return time.Duration(value) * time.Second
+}
+
+// searchServiceEnabled reports whether this node answers search requests.
+func (a *App) searchServiceEnabled() bool {
+	if enabled, set := resolveSearchServiceSetting(); set {
+		return enabled
+	}
+	settings, err := a.GetPrivacySettings()
+	if err != nil {
+		return false
+	}
+	return settings.AllowSearch
+}
+
+// SearchNetwork sends the query to connected peers, waits for their answers
+// and indexes the posts they return. Hits are ordered by how many peers
+// returned them, then by their best rank.
+func (a *App) SearchNetwork(request SearchRequest) (NetworkSearchResult, error) {
+	if a.db == nil {
+		return NetworkSearchResult{}, errors.New("database not initialized")
+	}
+	query := strings.TrimSpace(request.Query)
+	if buildSearchMatchQuery(query) == "" {
+		return NetworkSearchResult{}, errors.New("search query is required")
+	}
+	subID := strings.TrimSpace(request.SubID)
+	if subID != "" {
+		subID = normalizeSubID(subID)
+	}
+	limit := normalizeSearchLimit(request.Limit)
+	if limit > networkSearchMaxResults {
+		limit = networkSearchMaxResults
+	}
+
+	a.p2pMu.Lock()
+	host := a.p2pHost
+	topic := a.p2pTopic
+	ctx := a.p2pCtx
+	a.p2pMu.Unlock()
+	if host == nil || topic == nil || ctx == nil {
+		return NetworkSearchResult{}, errors.New("p2p not started")
+	}
+	if len(host.Network().Peers()) == 0 {
+		return NetworkSearchResult{}, errNetworkSearchNoPeers
+	}
+
+	localPeerID := host.ID().String()
+	now := time.Now()
+	message := IncomingMessage{
+		Type:            messageTypeSearchRequest,
+		RequestID:       buildMessageID(localPeerID, fmt.Sprintf("search|%s|%d", query, now.UnixNano()), now.Unix()),
+		RequesterPeerID: localPeerID,
+		SearchQuery:     query,
+		SearchAuthor:    strings.TrimSpace(request.Author),
+		SearchSinceTs:   request.Since,
+		SearchUntilTs:   request.Until,
+		SearchLimit:     limit,
+		SubID:           subID,
+		Timestamp:       now.Unix(),
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return NetworkSearchResult{}, err
+	}
+
+	responses := make(chan IncomingMessage, 32)
+	a.searchWaitMu.Lock()
+	if a.searchWaiters == nil {
+		a.searchWaiters = make(map[string]chan IncomingMessage)
+	}
+	a.searchWaiters[message.RequestID] = responses
+	a.searchWaitMu.Unlock()
+	defer func() {
+		a.searchWaitMu.Lock()
+		delete(a.searchWaiters, message.RequestID)
+		a.searchWaitMu.Unlock()
+	}()
+
+	if err = topic.Publish(ctx, payload); err != nil {
+		return NetworkSearchResult{}, err
+	}
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "search.network_request sent request_id=%s sub_id=%s limit=%d", message.RequestID, subID, limit)
+	}
+
+	candidates := make(map[string]*networkSearchCandidate)
+	responders := make(map[string]struct{})
+	timer := time.NewTimer(resolveNetworkSearchWait())
+	defer timer.Stop()
+collect:
+	for {
+		select {
+		case <-ctx.Done():
+			break collect
+		case <-timer.C:
+			break collect
+		case response := <-responses:
+			responder := strings.TrimSpace(response.ResponderPeerID)
+			if _, seen := responders[responder]; seen {
+				continue
+			}
+			responders[responder] = struct{}{}
+			a.mergeNetworkSearchResponse(candidates, responder, response.Summaries, limit)
+		}
+	}
+
+	result := a.indexNetworkSearchCandidates(candidates, limit)
+	result.Responders = len(responders)
+
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "search.network_done request_id=%s responders=%d hits=%d inserted=%d", message.RequestID, result.Responders, len(result.Hits), result.Inserted)
+		if result.Inserted > 0 {
+			runtime.EventsEmit(a.ctx, "feed:updated")
+		}
+	}
+	return result, nil
+}
+
+// mergeNetworkSearchResponse folds one peer's ranked digests into
+// candidates, keeping the newest verified version of every post.
+func (a *App) mergeNetworkSearchResponse(candidates map[string]*networkSearchCandidate, responder string, digests []SyncPostDigest, limit int) {
+	if len(digests) > limit {
+		digests = digests[:limit]
+	}
+	for rank, digest := range digests {
+		digest.ID = strings.TrimSpace(digest.ID)
+		if digest.ID == "" || strings.TrimSpace(digest.Pubkey) == "" || digest.Deleted || strings.TrimSpace(digest.ContentCID) == "" {
+			continue
+		}
+		if err := a.verifyPostDigestSignature(digest); err != nil {
+			continue
+		}
+
+		candidate, exists := candidates[digest.ID]
+		if !exists {
+			candidates[digest.ID] = &networkSearchCandidate{
+				digest:   digest,
+				peers:    map[string]struct{}{responder: {}},
+				bestRank: rank,
+			}
+			continue
+		}
+		candidate.peers[responder] = struct{}{}
+		if rank < candidate.bestRank {
+			candidate.bestRank = rank
+		}
+		current := LamportVersion{Lamport: candidate.digest.Lamport, Author: candidate.digest.Pubkey, OpID: candidate.digest.OpID}
+		incoming := LamportVersion{Lamport: digest.Lamport, Author: digest.Pubkey, OpID: digest.OpID}
+		if compareLamportVersion(incoming, current) > 0 {
+			candidate.digest = digest
+		}
+	}
+}
+
+// indexNetworkSearchCandidates ranks the merged posts and adds up to limit
+// of them to the post index, skipping authors the local policy rejects.
+func (a *App) indexNetworkSearchCandidates(candidates map[string]*networkSearchCandidate, limit int) NetworkSearchResult {
+	ordered := make([]*networkSearchCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		ordered = append(ordered, candidate)
+	}
+	sort.Slice(ordered, func(i int, j int) bool {
+		if len(ordered[i].peers) != len(ordered[j].peers) {
+			return len(ordered[i].peers) > len(ordered[j].peers)
+		}
+		if ordered[i].bestRank != ordered[j].bestRank {
+			return ordered[i].bestRank < ordered[j].bestRank
+		}
+		if ordered[i].digest.Timestamp != ordered[j].digest.Timestamp {
+			return ordered[i].digest.Timestamp > ordered[j].digest.Timestamp
+		}
+		return ordered[i].digest.ID < ordered[j].digest.ID
+	})
+
+	viewerPubkey := ""
+	if identity, identityErr := a.getLocalIdentity(); identityErr == nil {
+		viewerPubkey = strings.TrimSpace(identity.PublicKey)
+	}
+	result := NetworkSearchResult{Hits: make([]NetworkSearchHit, 0, len(ordered))}
+	for _, candidate := range ordered {
+		if len(result.Hits) >= limit {
+			break
+		}
+		digest := candidate.digest
+		allowed, allowErr := a.shouldAcceptPublicContent(digest.Pubkey, digest.Lamport, digest.Timestamp, digest.ID, viewerPubkey)
+		if allowErr != nil || !allowed {
+			continue
+		}
+		inserted, upsertErr := a.upsertPublicPostIndexFromDigest(digest)
+		if upsertErr != nil {
+			continue
+		}
+		if inserted {
+			result.Inserted++
+			_ = a.recordIncomingMessageSignature(postDigestSigningMessage(digest))
+		}
+		result.Hits = append(result.Hits, NetworkSearchHit{
+			PostID:    digest.ID,
+			Title:     digest.Title,
+			SubID:     digest.SubID,
+			Pubkey:    digest.Pubkey,
+			Timestamp: digest.Timestamp,
+			Peers:     len(candidate.peers),
+		})
+	}
+	return result
+}
+
+func (a *App) handleSearchRequest(localPeerID string, sender string, message IncomingMessage) {
+	requester := strings.TrimSpace(message.RequesterPeerID)
+	requestID := strings.TrimSpace(message.RequestID)
+	sender = strings.TrimSpace(sender)
+	if requester == "" || requestID == "" || requester == localPeerID || sender == "" {
+		return
+	}
+	if !a.searchServiceEnabled() {
+		return
+	}
+	if !a.allowFetchRequest(sender, messageTypeSearchRequest) {
+		return
+	}
+
+	summaries, err := a.searchDigestsForRequest(message)
+	if err != nil {
+		if a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "search.network_request failed request_id=%s err=%v", requestID, err)
+		}
+		return
+	}
+	if len(summaries) == 0 {
+		return
+	}
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "search.network_request answered request_id=%s requester=%s results=%d", requestID, requester, len(summaries))
+	}
+
+	response := IncomingMessage{
+		Type:            messageTypeSearchResponse,
+		SchemaVersion:   lamportSchemaV2,
+		AuthScope:       authScopeUser,
+		RequestID:       requestID,
+		RequesterPeerID: requester,
+		ResponderPeerID: localPeerID,
+		Summaries:       summaries,
+		Timestamp:       time.Now().Unix(),
+	}
+
+	a.p2pMu.Lock()
+	topic := a.p2pTopic
+	ctx := a.p2pCtx
+	a.p2pMu.Unlock()
+	if topic == nil || ctx == nil {
+		return
+	}
+	payload, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	_ = topic.Publish(ctx, payload)
+}
+
+// searchDigestsForRequest runs a remote query against the local index and
+// returns the digests of the matching public posts in rank order. Comment
+// hits stand in for their post; the first hit decides the rank. The query
+// runs without a viewer, so only posts with normal visibility match.
+func (a *App) searchDigestsForRequest(message IncomingMessage) ([]SyncPostDigest, error) {
+	limit := normalizeSearchLimit(message.SearchLimit)
+	if limit > networkSearchMaxResults {
+		limit = networkSearchMaxResults
+	}
+	page, err := a.searchIndex(SearchRequest{
+		Query:  message.SearchQuery,
+		SubID:  message.SubID,
+		Author: message.SearchAuthor,
+		Since:  message.SearchSinceTs,
+		Until:  message.SearchUntilTs,
+		Limit:  limit,
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	postIDs := make([]string, 0, len(page.Hits))
+	ranks := make(map[string]int, len(page.Hits))
+	for _, hit := range page.Hits {
+		if _, seen := ranks[hit.PostID]; seen || strings.TrimSpace(hit.PostID) == "" {
+			continue
+		}
+		ranks[hit.PostID] = len(postIDs)
+		postIDs = append(postIDs, hit.PostID)
+	}
+	if len(postIDs) == 0 {
+		return []SyncPostDigest{}, nil
+	}
+	summaries, err := a.listPublicPostDigestsByIDs(postIDs)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(summaries, func(i int, j int) bool {
+		return ranks[summaries[i].ID] < ranks[summaries[j].ID]
+	})
+	return summaries, nil
+}
+
+func (a *App) handleSearchResponse(localPeerID string, sender string, message IncomingMessage) {
+	if strings.TrimSpace(message.RequesterPeerID) != strings.TrimSpace(localPeerID) {
+		return
+	}
+	responder := strings.TrimSpace(sender)
+	if responder == "" || responder == localPeerID {
+		return
+	}
+	// The answer is merged under the peer that published it; the
+	// ResponderPeerID it carries is only the sender's claim.
+	message.ResponderPeerID = responder
+
+	a.searchWaitMu.Lock()
+	waiter := a.searchWaiters[strings.TrimSpace(message.RequestID)]
+	a.searchWaitMu.Unlock()
+	if waiter == nil {
+		return
+	}
+	if !a.allowFetchRequest(responder, messageTypeSearchResponse) {
+		return
+	}
+	select {
+	case waiter <- message:
+	default:
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNetworkSearchMergesPeerAnswersIntoIndex(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	responder := newTestApp(t)
+	requester := newTestApp(t)
+
+	for _, post := range []ForumMessage{
+		{ID: "p-raft", Pubkey: "alice", OpID: "op-raft", Title: "Raft consensus notes", Body: "Leader election and log replication.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: defaultSubID},
+		{ID: "p-paxos", Pubkey: "bob", OpID: "op-paxos", Title: "Paxos", Body: "Compared with raft, paxos is harder to follow.", Timestamp: 20, Lamport: 20, Zone: "public", SubID: defaultSubID},
+		{ID: "p-other", Pubkey: "bob", OpID: "op-other", Title: "Gardening", Body: "Tomatoes.", Timestamp: 30, Lamport: 30, Zone: "public", SubID: defaultSubID},
+	} {
+		if _, err := responder.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+	if _, err := responder.insertComment(Comment{ID: "c-1", PostID: "p-other", Pubkey: "carol", OpID: "op-c1", Body: "Off topic, but raft is great", Timestamp: 40, Lamport: 40}); err != nil {
+		t.Fatalf("insert comment: %v", err)
+	}
+
+	digests, err := responder.searchDigestsForRequest(IncomingMessage{SearchQuery: "raft", SearchLimit: 10})
+	if err != nil {
+		t.Fatalf("answer search: %v", err)
+	}
+	ids := make([]string, 0, len(digests))
+	for _, digest := range digests {
+		ids = append(ids, digest.ID)
+	}
+	// The comment hit stands in for its post.
+	if !reflect.DeepEqual(ids, []string{"p-raft", "p-other", "p-paxos"}) {
+		t.Fatalf("unexpected answer order %v", ids)
+	}
+
+	// A second peer only knows the paxos post, so it is returned by both.
+	candidates := make(map[string]*networkSearchCandidate)
+	requester.mergeNetworkSearchResponse(candidates, "peer-1", digests, 10)
+	requester.mergeNetworkSearchResponse(candidates, "peer-2", digests[2:], 10)
+	result := requester.indexNetworkSearchCandidates(candidates, 10)
+	ids = ids[:0]
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.PostID)
+	}
+	if !reflect.DeepEqual(ids, []string{"p-paxos", "p-raft", "p-other"}) || result.Inserted != 3 {
+		t.Fatalf("unexpected merge %+v", result)
+	}
+	if count := countRows(t, requester, `SELECT COUNT(1) FROM messages WHERE id IN ('p-raft', 'p-paxos', 'p-other');`); count != 3 {
+		t.Fatalf("expected the answers in the post index, got %d", count)
+	}
+
+	// Titles are searchable locally before the bodies arrive.
+	page, err := requester.Search(SearchRequest{Query: "consensus"})
+	if err != nil || len(page.Hits) != 1 || page.Hits[0].ID != "p-raft" {
+		t.Fatalf("unexpected local search %+v, err %v", page, err)
+	}
+}
+
+func TestNetworkSearchOffersOnlyNormalPosts(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	identity, err := app.CreateIdentity("", "correct horse battery")
+	if err != nil {
+		t.Fatalf("create identity: %v", err)
+	}
+	for _, post := range []ForumMessage{
+		{ID: "p-open", Pubkey: identity.PublicKey, OpID: "op-open", Title: "Orchard open day", Body: "All welcome.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: defaultSubID},
+		{ID: "p-shadowed", Pubkey: identity.PublicKey, OpID: "op-shadowed", Title: "Orchard rumours", Body: "Not for everyone.", Timestamp: 20, Lamport: 20, Zone: "public", SubID: defaultSubID},
+	} {
+		if _, err = app.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+	if _, err = app.db.Exec(`UPDATE messages SET visibility = 'shadowed' WHERE id = 'p-shadowed';`); err != nil {
+		t.Fatalf("shadow post: %v", err)
+	}
+
+	// The author still finds their own shadowed post locally.
+	page, err := app.Search(SearchRequest{Query: "orchard"})
+	if err != nil || len(page.Hits) != 2 {
+		t.Fatalf("expected both posts in a local search, got %+v err=%v", page.Hits, err)
+	}
+	digests, err := app.searchDigestsForRequest(IncomingMessage{SearchQuery: "orchard", SearchLimit: 10})
+	if err != nil {
+		t.Fatalf("answer search: %v", err)
+	}
+	if len(digests) != 1 || digests[0].ID != "p-open" {
+		t.Fatalf("expected only the normal post to be offered, got %+v", digests)
+	}
+}
+
+func TestNetworkSearchResponseKeyedByPublishingPeer(t *testing.T) {
+	t.Setenv("AEGIS_FETCH_REQUEST_LIMIT", "1")
+	app := newTestApp(t)
+	responses := make(chan IncomingMessage, 4)
+	app.searchWaiters = map[string]chan IncomingMessage{"req-1": responses}
+
+	answer := IncomingMessage{Type: messageTypeSearchResponse, RequestID: "req-1", RequesterPeerID: "local", ResponderPeerID: "claimed-peer"}
+	app.handleSearchResponse("local", "peer-a", answer)
+	select {
+	case response := <-responses:
+		if response.ResponderPeerID != "peer-a" {
+			t.Fatalf("expected the answer keyed by the publishing peer, got %q", response.ResponderPeerID)
+		}
+	default:
+		t.Fatalf("expected the answer to reach the waiter")
+	}
+
+	// A second answer from the same peer under another claimed ID shares its
+	// rate limit.
+	answer.ResponderPeerID = "another-claim"
+	app.handleSearchResponse("local", "peer-a", answer)
+	if len(responses) != 0 {
+		t.Fatalf("expected the publishing peer to be rate limited")
+	}
+}
+
+func TestNetworkSearchTellsRespondersBehindOneHopApart(t *testing.T) {
+	app := newTestApp(t)
+	startTestP2P(t, app)
+	relay := newTestPubsubPeer(t, app)
+	relay.subscribe(t, app, forumTopicName)
+
+	// Both responders reach app only through relay.
+	responders := make(map[string]*pubsub.Topic)
+	for i := 0; i < 2; i++ {
+		node, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		if err != nil {
+			t.Fatalf("start responder host: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(func() {
+			cancel()
+			_ = node.Close()
+		})
+		gossip, err := pubsub.NewGossipSub(ctx, node)
+		if err != nil {
+			t.Fatalf("start responder gossipsub: %v", err)
+		}
+		if err = node.Connect(ctx, peer.AddrInfo{ID: relay.host.ID(), Addrs: relay.host.Addrs()}); err != nil {
+			t.Fatalf("connect responder: %v", err)
+		}
+		topic, err := gossip.Join(forumTopicName)
+		if err != nil {
+			t.Fatalf("join control topic: %v", err)
+		}
+		if _, err = topic.Subscribe(); err != nil {
+			t.Fatalf("subscribe control topic: %v", err)
+		}
+		waitForTestCondition(t, "responder and relay to see each other", func() bool {
+			return topicHasPeer(relay.gossip.ListPeers(forumTopicName), node.ID()) && topicHasPeer(topic.ListPeers(), relay.host.ID())
+		})
+		responders[node.ID().String()] = topic
+	}
+
+	responses := make(chan IncomingMessage, 4)
+	app.searchWaitMu.Lock()
+	app.searchWaiters = map[string]chan IncomingMessage{"req-1": responses}
+	app.searchWaitMu.Unlock()
+	payload, err := json.Marshal(IncomingMessage{Type: messageTypeSearchResponse, RequestID: "req-1", RequesterPeerID: app.p2pHost.ID().String(), ResponderPeerID: "claimed-peer"})
+	if err != nil {
+		t.Fatalf("encode answer: %v", err)
+	}
+	// A fresh mesh may drop the first frames, so each responder repeats its
+	// answer until app has it.
+	seen := make(map[string]bool)
+	polls := 0
+	waitForTestCondition(t, "both answers", func() bool {
+	drain:
+		for {
+			select {
+			case response := <-responses:
+				if responders[response.ResponderPeerID] == nil {
+					t.Fatalf("expected answers keyed by their publishers, got %q", response.ResponderPeerID)
+				}
+				seen[response.ResponderPeerID] = true
+			default:
+				break drain
+			}
+		}
+		if polls%10 == 0 {
+			for id, topic := range responders {
+				if !seen[id] {
+					_ = topic.Publish(context.Background(), payload)
+				}
+			}
+		}
+		polls++
+		return len(seen) == len(responders)
+	})
+}
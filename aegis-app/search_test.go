@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchRanksSegmentsAndFollowsWrites(t *testing.T) {
+	app := newTestApp(t)
+
+	posts := []ForumMessage{
+		{ID: "p-go", Pubkey: "alice", OpID: "op-go", Title: "Golang tips", Body: "Notes about goroutines and channels.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: defaultSubID},
+		{ID: "p-zh", Pubkey: "bob", OpID: "op-zh", Title: "全文搜索", Body: "我们需要支持中文全文搜索和排序。", Timestamp: 20, Lamport: 20, Zone: "public", SubID: "tech"},
+		{ID: "p-misc", Pubkey: "alice", OpID: "op-misc", Title: "Weekend", Body: "Hiking, then golang in the evening.", Timestamp: 30, Lamport: 30, Zone: "public", SubID: defaultSubID},
+	}
+	for _, post := range posts {
+		if _, err := app.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+	if _, err := app.insertComment(Comment{ID: "c-1", PostID: "p-go", Pubkey: "carol", OpID: "op-c1", Body: "Channels close golang loops nicely", Timestamp: 40, Lamport: 40}); err != nil {
+		t.Fatalf("insert comment: %v", err)
+	}
+
+	hitIDs := func(request SearchRequest) []string {
+		t.Helper()
+		page, err := app.Search(request)
+		if err != nil {
+			t.Fatalf("search %+v: %v", request, err)
+		}
+		ids := make([]string, 0, len(page.Hits))
+		for _, hit := range page.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	// A title match outranks body matches.
+	if ids := hitIDs(SearchRequest{Query: "golang", Kind: searchKindPost}); !reflect.DeepEqual(ids, []string{"p-go", "p-misc"}) {
+		t.Fatalf("unexpected ranking %v", ids)
+	}
+	if ids := hitIDs(SearchRequest{Query: "gorout*"}); !reflect.DeepEqual(ids, []string{"p-go"}) {
+		t.Fatalf("prefix query returned %v", ids)
+	}
+	if ids := hitIDs(SearchRequest{Query: `"close golang"`}); !reflect.DeepEqual(ids, []string{"c-1"}) {
+		t.Fatalf("phrase query returned %v", ids)
+	}
+	if ids := hitIDs(SearchRequest{Query: "golang", Author: "alice", Since: 20}); !reflect.DeepEqual(ids, []string{"p-misc"}) {
+		t.Fatalf("author and date filters returned %v", ids)
+	}
+
+	// Chinese text is found by any run of adjacent characters, and the
+	// snippet reads as the original text.
+	page, err := app.Search(SearchRequest{Query: "中文", SubID: "tech"})
+	if err != nil {
+		t.Fatalf("search chinese: %v", err)
+	}
+	if len(page.Hits) != 1 || page.Hits[0].ID != "p-zh" {
+		t.Fatalf("unexpected chinese hits %+v", page.Hits)
+	}
+	want := []SearchSnippetPart{{Text: "我们需要支持"}, {Text: "中文", Match: true}, {Text: "全文搜索和排序。"}}
+	if !reflect.DeepEqual(page.Hits[0].Snippet, want) {
+		t.Fatalf("unexpected snippet %+v", page.Hits[0].Snippet)
+	}
+	if ids := hitIDs(SearchRequest{Query: "文中"}); len(ids) != 0 {
+		t.Fatalf("non-adjacent characters should not match, got %v", ids)
+	}
+
+	// Pages follow the ranking without overlap.
+	first, err := app.Search(SearchRequest{Query: "golang", Limit: 2})
+	if err != nil || len(first.Hits) != 2 || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v, err %v", first, err)
+	}
+	second, err := app.Search(SearchRequest{Query: "golang", Limit: 2, Cursor: first.NextCursor})
+	if err != nil || len(second.Hits) != 1 || second.NextCursor != "" {
+		t.Fatalf("unexpected second page %+v, err %v", second, err)
+	}
+	for _, hit := range first.Hits {
+		if hit.ID == second.Hits[0].ID {
+			t.Fatalf("pages overlap on %s", hit.ID)
+		}
+	}
+
+	// Edits replace the indexed text and tombstones drop it.
+	if _, err = app.insertMessage(ForumMessage{ID: "p-misc", Pubkey: "alice", OpID: "op-misc-2", Title: "Weekend", Body: "Hiking only.", Timestamp: 50, Lamport: 50, Zone: "public", SubID: defaultSubID}); err != nil {
+		t.Fatalf("update post: %v", err)
+	}
+	if err = app.deleteLocalPostAsAuthor("alice", "p-go", 60, 60, "op-delete-go"); err != nil {
+		t.Fatalf("delete post: %v", err)
+	}
+	if ids := hitIDs(SearchRequest{Query: "golang", Kind: searchKindPost}); len(ids) != 0 {
+		t.Fatalf("expected no posts after edit and delete, got %v", ids)
+	}
+	if ids := hitIDs(SearchRequest{Query: "hiking"}); !reflect.DeepEqual(ids, []string{"p-misc"}) {
+		t.Fatalf("edited text not indexed, got %v", ids)
+	}
+}
+
+func TestSearchRepairCatchesFailedReindex(t *testing.T) {
+	app := newTestApp(t)
+
+	if _, err := app.insertMessage(ForumMessage{ID: "p-1", Pubkey: "alice", OpID: "op-1", Title: "Repair", Body: "Lost entries come back.", Timestamp: 10, Lamport: 10, Zone: "public", SubID: defaultSubID}); err != nil {
+		t.Fatalf("insert post: %v", err)
+	}
+	found := func(query string) bool {
+		t.Helper()
+		page, err := app.Search(SearchRequest{Query: query})
+		if err != nil {
+			t.Fatalf("search %q: %v", query, err)
+		}
+		return len(page.Hits) > 0
+	}
+
+	// An entry lost without a stale mark is found by comparing the index
+	// with the rows.
+	if err := func() error {
+		tx, err := app.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err = removeSearchDocTx(tx, searchKindPost, "p-1"); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}(); err != nil {
+		t.Fatalf("drop entry: %v", err)
+	}
+	if found("repair") {
+		t.Fatalf("expected the dropped entry to be missing")
+	}
+	repaired, err := repairSearchIndex(app.db)
+	if err != nil || repaired != 1 {
+		t.Fatalf("expected one repaired entry, got %d err=%v", repaired, err)
+	}
+	if !found("repair") {
+		t.Fatalf("expected the repaired entry to be searchable")
+	}
+
+	// A refresh that fails after the write commits marks the index stale,
+	// and maintenance rebuilds it.
+	if _, err = app.db.Exec(`CREATE TRIGGER search_docs_fail BEFORE INSERT ON search_docs BEGIN SELECT RAISE(ABORT, 'index unavailable'); END;`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	if _, err = app.insertComment(Comment{ID: "c-1", PostID: "p-1", Pubkey: "bob", OpID: "op-c1", Body: "Quokka sighting", Timestamp: 20, Lamport: 20}); err != nil {
+		t.Fatalf("insert comment: %v", err)
+	}
+	if _, err = app.db.Exec(`DROP TRIGGER search_docs_fail;`); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	if found("quokka") {
+		t.Fatalf("expected the failed reindex to leave the comment out")
+	}
+	if version := countRows(t, app, `SELECT version FROM search_index_state WHERE id = 1;`); version != 0 {
+		t.Fatalf("expected the index to be marked stale, got version %d", version)
+	}
+
+	run, err := app.RunMaintenanceNow()
+	if err != nil {
+		t.Fatalf("maintenance: %v", err)
+	}
+	if run.SearchRepaired != 2 || len(run.Errors) != 0 {
+		t.Fatalf("expected a rebuild of both entries, got %d errors=%v", run.SearchRepaired, run.Errors)
+	}
+	if !found("quokka") {
+		t.Fatalf("expected the rebuilt index to find the comment")
+	}
+	if version := countRows(t, app, `SELECT version FROM search_index_state WHERE id = 1;`); version != searchIndexVersion {
+		t.Fatalf("expected the rebuild to restore the index version, got %d", version)
+	}
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// When a quota is full, blobs are evicted in the order an evictionScorer
+// ranks them, lowest score first. Blobs referenced by a favorited post, by a
+// post or comment one of our identities wrote, or by a protected post are
+// never offered to the scorer, and neither are pinned blobs. If evicting
+// blobs is not enough, the message rows of posts whose bodies are gone are
+// ranked the same way and evicted next, under the same exemptions. Every
+// eviction is written to storage_evictions in the same transaction, so a
+// write that rolls back leaves no log entry for data it did not end up
+// removing.
+const (
+	evictionPolicyWeighted = "weighted"
+	evictionPolicyLRU      = "lru"
+
+	defaultStorageEvictionLimit = 100
+	maxStorageEvictionLimit     = 1000
+	storageEvictionLogKeep      = 5000
+
+	storageEvictionKindMessage = "message"
+
+	// The weighted scorer treats each signal as extra seconds of recency.
+	evictionSubscribedBonusSec = 3 * 24 * 3600
+	evictionVoteBonusSec       = 2 * 3600
+	evictionMaxVotes           = 50
+)
+
+type StorageEviction struct {
+	ID         int64   `json:"id"`
+	At         int64   `json:"at"`
+	Kind       string  `json:"kind"`
+	ContentCID string  `json:"contentCid"`
+	SizeBytes  int64   `json:"sizeBytes"`
+	Scope      string  `json:"scope"`
+	Policy     string  `json:"policy"`
+	Score      float64 `json:"score"`
+	PostID     string  `json:"postId"`
+	SubID      string  `json:"subId"`
+	Reason     string  `json:"reason"`
+}
+
+// storageEvictionCandidate is an unpinned blob or message row and the
+// signals the scorer weighs. PostID and SubID name one referencing post, if
+// any; for a message row CID and PostID are both the message ID.
+type storageEvictionCandidate struct {
+	Kind           string
+	CID            string
+	SizeBytes      int64
+	LastAccessedAt int64
+	CreatedAt      int64
+	PostID         string
+	SubID          string
+	Votes          int64
+	Subscribed     bool
+	Protected      bool
+}
+
+func (c storageEvictionCandidate) lastUsedAt() int64 {
+	if c.LastAccessedAt > 0 {
+		return c.LastAccessedAt
+	}
+	return c.CreatedAt
+}
+
+// evictionScorer ranks eviction candidates: the lower the score, the sooner
+// a blob is evicted.
+type evictionScorer interface {
+	name() string
+	score(now int64, candidate storageEvictionCandidate) float64
+}
+
+// lruEvictionScorer evicts the least recently used blob first.
+type lruEvictionScorer struct{}
+
+func (lruEvictionScorer) name() string {
+	return evictionPolicyLRU
+}
+
+func (lruEvictionScorer) score(now int64, candidate storageEvictionCandidate) float64 {
+	return float64(candidate.lastUsedAt())
+}
+
+// weightedEvictionScorer starts from the last use like LRU and credits blobs
+// of subscribed subs and well-voted posts, so they outlive idle blobs of the
+// same age.
+type weightedEvictionScorer struct{}
+
+func (weightedEvictionScorer) name() string {
+	return evictionPolicyWeighted
+}
+
+func (weightedEvictionScorer) score(now int64, candidate storageEvictionCandidate) float64 {
+	score := float64(candidate.lastUsedAt())
+	if candidate.Subscribed {
+		score += evictionSubscribedBonusSec
+	}
+	votes := candidate.Votes
+	if votes > evictionMaxVotes {
+		votes = evictionMaxVotes
+	}
+	if votes < -evictionMaxVotes {
+		votes = -evictionMaxVotes
+	}
+	return score + float64(votes*evictionVoteBonusSec)
+}
+
+// resolveEvictionScorer picks the scorer named by AEGIS_EVICTION_POLICY,
+// weighted by default.
+func resolveEvictionScorer() evictionScorer {
+	switch strings.TrimSpace(strings.ToLower(os.Getenv("AEGIS_EVICTION_POLICY"))) {
+	case evictionPolicyLRU:
+		return lruEvictionScorer{}
+	default:
+		return weightedEvictionScorer{}
+	}
+}
+
+// storageEvictor enforces quotas inside one write transaction.
+type storageEvictor struct {
+	tx     *sql.Tx
+	quotas StorageQuotas
+	scorer evictionScorer
+	now    int64
+	keep   map[string]bool
+}
+
+func (a *App) newStorageEvictor(tx *sql.Tx, quotas StorageQuotas) *storageEvictor {
+	scorer := a.evictionScorer
+	if scorer == nil {
+		scorer = resolveEvictionScorer()
+	}
+	return &storageEvictor{tx: tx, quotas: quotas, scorer: scorer, now: time.Now().Unix(), keep: map[string]bool{}}
+}
+
+// ensure makes room for write under the zone, media and node quotas, in
+// that order. The message and blobs being written are never evicted to make
+// room for themselves.
+func (e *storageEvictor) ensure(write storageWrite) error {
+	write.MessageID = strings.TrimSpace(write.MessageID)
+	write.ContentCID = strings.TrimSpace(write.ContentCID)
+	write.MediaCID = strings.TrimSpace(write.MediaCID)
+	if write.Zone != "" && write.Zone != "private" {
+		write.Zone = "public"
+	}
+	if write.MessageID != "" {
+		e.keep[storageEvictionKindMessage+":"+write.MessageID] = true
+	}
+	if write.ContentCID != "" {
+		e.keep[storageBlobKindContent+":"+write.ContentCID] = true
+	}
+	if write.MediaCID != "" {
+		e.keep[storageBlobKindMedia+":"+write.MediaCID] = true
+	}
+
+	contentIncoming, err := incomingBlobBytes(e.tx, "content_blobs", write.ContentCID, write.ContentBytes)
+	if err != nil {
+		return err
+	}
+	mediaIncoming, err := incomingBlobBytes(e.tx, "media_blobs", write.MediaCID, write.MediaBytes)
+	if err != nil {
+		return err
+	}
+
+	if write.Zone != "" {
+		zoneIncoming := write.MessageBytes
+		if write.ContentCID != "" {
+			var referenced int
+			if err = e.tx.QueryRow(`
+				SELECT COUNT(1) FROM content_blobs cb
+				WHERE cb.content_cid = ?
+					AND EXISTS (SELECT 1 FROM messages m WHERE m.zone = ? AND m.content_cid = cb.content_cid);
+			`, write.ContentCID, write.Zone).Scan(&referenced); err != nil {
+				return err
+			}
+			if referenced == 0 {
+				zoneIncoming += write.ContentBytes
+			}
+		}
+		if err = e.ensureZone(write.Zone, zoneIncoming); err != nil {
+			return err
+		}
+	}
+	if write.MediaCID != "" {
+		if err = e.ensureMedia(mediaIncoming); err != nil {
+			return err
+		}
+	}
+	return e.ensureTotal(write.MessageBytes + contentIncoming + mediaIncoming)
+}
+
+// enforce evicts until current usage fits every quota. Scopes that
+// stay over quota are reported in the returned error but do not stop the
+// others from being enforced.
+func (e *storageEvictor) enforce() error {
+	var overQuota error
+	checks := []func() error{
+		func() error { return e.ensureZone("private", 0) },
+		func() error { return e.ensureZone("public", 0) },
+		func() error { return e.ensureMedia(0) },
+		func() error { return e.ensureTotal(0) },
+	}
+	for _, check := range checks {
+		if err := check(); err != nil {
+			if !errors.Is(err, errStorageQuotaExceeded) {
+				return err
+			}
+			overQuota = err
+		}
+	}
+	return overQuota
+}
+
+func (e *storageEvictor) ensureZone(zone string, incoming int64) error {
+	used, err := zoneStorageUsedTx(e.tx, zone)
+	if err != nil {
+		return err
+	}
+	return e.evictToFit(zone, e.quotas.zoneBytes(zone), used, incoming, storageBlobKindContent, zone)
+}
+
+func (e *storageEvictor) ensureMedia(incoming int64) error {
+	used, err := mediaStorageUsedTx(e.tx)
+	if err != nil {
+		return err
+	}
+	return e.evictToFit("media", e.quotas.MediaBytes, used, incoming, storageBlobKindMedia, "")
+}
+
+func (e *storageEvictor) ensureTotal(incoming int64) error {
+	used, err := totalStorageUsedTx(e.tx)
+	if err != nil {
+		return err
+	}
+	return e.evictToFit("total", e.quotas.TotalBytes, used, incoming, "", "")
+}
+
+// evictToFit deletes the lowest scored candidates until used+incoming fits
+// quota, blobs first and then, unless only media counts, message rows whose
+// bodies are gone. It fails with errStorageQuotaExceeded when it cannot.
+func (e *storageEvictor) evictToFit(scope string, quota int64, used int64, incoming int64, kind string, zone string) error {
+	if incoming > quota {
+		return fmt.Errorf("%w: %d bytes exceed the %s quota of %d bytes", errStorageQuotaExceeded, incoming, scope, quota)
+	}
+	if used+incoming <= quota {
+		return nil
+	}
+
+	candidates, err := listStorageEvictionCandidates(e.tx, kind, zone)
+	if err != nil {
+		return err
+	}
+	if used, err = e.evictCandidates(scope, quota, used, incoming, candidates); err != nil {
+		return err
+	}
+	if used+incoming > quota && kind != storageBlobKindMedia {
+		if candidates, err = listMessageEvictionCandidates(e.tx, zone); err != nil {
+			return err
+		}
+		if used, err = e.evictCandidates(scope, quota, used, incoming, candidates); err != nil {
+			return err
+		}
+	}
+
+	if used+incoming > quota {
+		return fmt.Errorf("%w: %s quota of %d bytes is full and nothing is evictable", errStorageQuotaExceeded, scope, quota)
+	}
+	return nil
+}
+
+// evictCandidates deletes candidates in score order until used+incoming fits
+// quota and returns the usage left.
+func (e *storageEvictor) evictCandidates(scope string, quota int64, used int64, incoming int64, candidates []storageEvictionCandidate) (int64, error) {
+	scores := make(map[string]float64, len(candidates))
+	evictable := candidates[:0]
+	for _, candidate := range candidates {
+		if candidate.Protected || e.keep[candidate.Kind+":"+candidate.CID] {
+			continue
+		}
+		scores[candidate.Kind+":"+candidate.CID] = e.scorer.score(e.now, candidate)
+		evictable = append(evictable, candidate)
+	}
+	sort.SliceStable(evictable, func(i, j int) bool {
+		return scores[evictable[i].Kind+":"+evictable[i].CID] < scores[evictable[j].Kind+":"+evictable[j].CID]
+	})
+
+	for _, candidate := range evictable {
+		if used+incoming <= quota {
+			break
+		}
+
+		deleted, err := e.deleteCandidate(candidate)
+		if err != nil {
+			return used, err
+		}
+		if !deleted {
+			continue
+		}
+		used -= candidate.SizeBytes
+		if err = e.logEviction(scope, candidate, scores[candidate.Kind+":"+candidate.CID]); err != nil {
+			return used, err
+		}
+	}
+	return used, nil
+}
+
+func (e *storageEvictor) deleteCandidate(candidate storageEvictionCandidate) (bool, error) {
+	var (
+		result sql.Result
+		err    error
+	)
+	switch candidate.Kind {
+	case storageEvictionKindMessage:
+		result, err = e.tx.Exec(`DELETE FROM messages WHERE id = ? AND visibility <> 'deleted';`, candidate.CID)
+	case storageBlobKindMedia:
+		result, err = e.tx.Exec(`DELETE FROM media_blobs WHERE content_cid = ? AND pinned = 0;`, candidate.CID)
+	default:
+		result, err = e.tx.Exec(`DELETE FROM content_blobs WHERE content_cid = ? AND pinned = 0;`, candidate.CID)
+	}
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, nil
+	}
+	if candidate.Kind == storageEvictionKindMessage {
+		if err = deleteEntityRevisionsTx(e.tx, entityTypePost, candidate.CID); err != nil {
+			return false, err
+		}
+		if err = removeSearchDocTx(e.tx, searchKindPost, candidate.CID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (e *storageEvictor) logEviction(scope string, candidate storageEvictionCandidate, score float64) error {
+	reasons := []string{fmt.Sprintf("%s quota full", scope), fmt.Sprintf("idle %s", time.Duration(e.now-candidate.lastUsedAt())*time.Second)}
+	switch {
+	case candidate.PostID == "":
+		reasons = append(reasons, "not referenced by any post")
+	case candidate.Subscribed:
+		reasons = append(reasons, "subscribed sub")
+	default:
+		reasons = append(reasons, "not subscribed")
+	}
+	if candidate.PostID != "" {
+		reasons = append(reasons, fmt.Sprintf("%d votes", candidate.Votes))
+	}
+
+	contentCID := candidate.CID
+	if candidate.Kind == storageEvictionKindMessage {
+		contentCID = ""
+	}
+	if _, err := e.tx.Exec(`
+		INSERT INTO storage_evictions (at, kind, content_cid, size_bytes, scope, policy, score, post_id, sub_id, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`, e.now, candidate.Kind, contentCID, candidate.SizeBytes, scope, e.scorer.name(), score, candidate.PostID, candidate.SubID, strings.Join(reasons, ", ")); err != nil {
+		return err
+	}
+	_, err := e.tx.Exec(`DELETE FROM storage_evictions WHERE id <= (SELECT MAX(id) FROM storage_evictions) - ?;`, storageEvictionLogKeep)
+	return err
+}
+
+// listStorageEvictionCandidates returns unpinned blobs with their scoring
+// signals. kind limits the list to content or media blobs, and zone limits
+// content blobs to those referenced by that zone only, so one zone's quota
+// never evicts a blob another zone still shows. A media blob takes its
+// signals from the posts that show it and the posts of comments that attach
+// it; a comment counts as our own content when one of our identities wrote
+// it.
+func listStorageEvictionCandidates(tx *sql.Tx, kind string, zone string) ([]storageEvictionCandidate, error) {
+	blobs := `
+		SELECT 'content' AS kind, content_cid AS cid, size_bytes, last_accessed_at, created_at FROM content_blobs WHERE pinned = 0
+		UNION ALL
+		SELECT 'media', content_cid, size_bytes, last_accessed_at, created_at FROM media_blobs WHERE pinned = 0`
+	args := []any{}
+	switch {
+	case kind == storageBlobKindContent && zone != "":
+		blobs = `
+			SELECT 'content' AS kind, cb.content_cid AS cid, cb.size_bytes, cb.last_accessed_at, cb.created_at
+			FROM content_blobs cb
+			WHERE cb.pinned = 0
+				AND EXISTS (SELECT 1 FROM messages m WHERE m.zone = ? AND m.content_cid = cb.content_cid)
+				AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.zone <> ? AND m.content_cid = cb.content_cid)`
+		args = append(args, zone, zone)
+	case kind == storageBlobKindMedia:
+		blobs = `SELECT 'media' AS kind, content_cid AS cid, size_bytes, last_accessed_at, created_at FROM media_blobs WHERE pinned = 0`
+	}
+
+	rows, err := tx.Query(`
+		WITH blobs AS (`+blobs+`),
+		refs AS (
+			SELECT 'content' AS kind, m.content_cid AS cid, m.id AS post_id, m.sub_id, m.score, m.pubkey AS author, m.is_protected
+			FROM messages m WHERE m.content_cid IN (SELECT cid FROM blobs WHERE kind = 'content')
+			UNION ALL
+			SELECT 'media', m.image_cid, m.id, m.sub_id, m.score, m.pubkey, m.is_protected
+			FROM messages m WHERE m.image_cid IN (SELECT cid FROM blobs WHERE kind = 'media')
+			UNION ALL
+			SELECT 'media', m.thumb_cid, m.id, m.sub_id, m.score, m.pubkey, m.is_protected
+			FROM messages m WHERE m.thumb_cid IN (SELECT cid FROM blobs WHERE kind = 'media')
+			UNION ALL
+			SELECT 'media', r.content_cid, m.id, m.sub_id, m.score, c.pubkey, 0
+			FROM comment_media_refs r
+			JOIN comments c ON c.id = r.comment_id
+			JOIN messages m ON m.id = c.post_id
+			WHERE r.content_cid IN (SELECT cid FROM blobs WHERE kind = 'media')
+		),
+		signals AS (
+			SELECT
+				kind,
+				cid,
+				MIN(post_id) AS post_id,
+				MIN(sub_id) AS sub_id,
+				MAX(score) AS votes,
+				MAX(EXISTS (SELECT 1 FROM sub_subscriptions s WHERE s.sub_id = refs.sub_id)) AS subscribed,
+				MAX(
+					is_protected = 1
+					OR author IN (SELECT pubkey FROM local_identity)
+					OR EXISTS (
+						SELECT 1 FROM post_favorites_state f
+						WHERE f.post_id = refs.post_id AND f.state = 'active'
+							AND f.pubkey IN (SELECT pubkey FROM local_identity)
+					)
+				) AS protected
+			FROM refs
+			GROUP BY kind, cid
+		)
+		SELECT b.kind, b.cid, b.size_bytes, b.last_accessed_at, b.created_at,
+			COALESCE(s.post_id, ''), COALESCE(s.sub_id, ''), COALESCE(s.votes, 0),
+			COALESCE(s.subscribed, 0), COALESCE(s.protected, 0)
+		FROM blobs b
+		LEFT JOIN signals s ON s.kind = b.kind AND s.cid = b.cid
+		ORDER BY b.last_accessed_at ASC, b.created_at ASC;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := make([]storageEvictionCandidate, 0)
+	for rows.Next() {
+		var (
+			candidate  storageEvictionCandidate
+			subscribed int
+			protected  int
+		)
+		if err = rows.Scan(&candidate.Kind, &candidate.CID, &candidate.SizeBytes, &candidate.LastAccessedAt, &candidate.CreatedAt,
+			&candidate.PostID, &candidate.SubID, &candidate.Votes, &subscribed, &protected); err != nil {
+			return nil, err
+		}
+		candidate.Subscribed = subscribed == 1
+		candidate.Protected = protected == 1
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// listMessageEvictionCandidates returns the message rows that may be evicted,
+// limited to zone unless it is empty. A row qualifies only once its body is
+// gone, and never when the post is protected, pinned, favorited or written
+// by one of our identities, or when one of our identities commented on it.
+func listMessageEvictionCandidates(tx *sql.Tx, zone string) ([]storageEvictionCandidate, error) {
+	rows, err := tx.Query(`
+		SELECT m.id, m.size_bytes, m.timestamp, m.sub_id, m.score,
+			EXISTS (SELECT 1 FROM sub_subscriptions s WHERE s.sub_id = m.sub_id)
+		FROM messages m
+		WHERE m.size_bytes > 0
+			AND m.visibility <> 'deleted'
+			AND (? = '' OR m.zone = ?)
+			AND m.is_protected = 0
+			AND m.pubkey NOT IN (SELECT pubkey FROM local_identity)
+			AND NOT EXISTS (SELECT 1 FROM content_blobs cb WHERE cb.content_cid = m.content_cid)
+			AND NOT EXISTS (
+				SELECT 1 FROM post_favorites_state f
+				WHERE f.post_id = m.id AND f.state = 'active'
+					AND f.pubkey IN (SELECT pubkey FROM local_identity)
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM comments c
+				WHERE c.post_id = m.id AND c.pubkey IN (SELECT pubkey FROM local_identity)
+			)
+			AND m.id NOT IN (SELECT post_id FROM pinned_posts)
+			AND m.sub_id NOT IN (SELECT sub_id FROM pinned_subs)
+		ORDER BY m.timestamp ASC, m.id ASC;
+	`, zone, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := make([]storageEvictionCandidate, 0)
+	for rows.Next() {
+		var (
+			candidate  storageEvictionCandidate
+			subscribed int
+		)
+		if err = rows.Scan(&candidate.CID, &candidate.SizeBytes, &candidate.CreatedAt, &candidate.SubID, &candidate.Votes, &subscribed); err != nil {
+			return nil, err
+		}
+		candidate.Kind = storageEvictionKindMessage
+		candidate.PostID = candidate.CID
+		candidate.Subscribed = subscribed == 1
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// GetStorageEvictions returns the blobs and message rows most recently
+// evicted to stay within the storage quotas, newest first, with the scope,
+// policy, score and the signals behind each choice.
+func (a *App) GetStorageEvictions(limit int) ([]StorageEviction, error) {
+	if a.db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if limit <= 0 {
+		limit = defaultStorageEvictionLimit
+	}
+	if limit > maxStorageEvictionLimit {
+		limit = maxStorageEvictionLimit
+	}
+
+	rows, err := a.db.Query(`
+		SELECT id, at, kind, content_cid, size_bytes, scope, policy, score, post_id, sub_id, reason
+		FROM storage_evictions
+		ORDER BY id DESC
+		LIMIT ?;
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	evictions := make([]StorageEviction, 0)
+	for rows.Next() {
+		var eviction StorageEviction
+		if err = rows.Scan(&eviction.ID, &eviction.At, &eviction.Kind, &eviction.ContentCID, &eviction.SizeBytes, &eviction.Scope,
+			&eviction.Policy, &eviction.Score, &eviction.PostID, &eviction.SubID, &eviction.Reason); err != nil {
+			return nil, err
+		}
+		evictions = append(evictions, eviction)
+	}
+	return evictions, rows.Err()
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestWeightedEvictionProtectsFavoritesAndOwnContent(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, statement := range []string{
+		`INSERT INTO local_identity (pubkey, is_active, updated_at) VALUES ('me', 1, 1);`,
+		`INSERT INTO subs (id, title, created_at) VALUES ('tech', 'Tech', 1);`,
+		`INSERT INTO sub_subscriptions (pubkey, sub_id, subscribed_at) VALUES ('me', 'tech', 1);`,
+		`INSERT INTO post_favorites_state (pubkey, post_id, state, updated_at, last_op_id) VALUES ('me', 'p-fav', 'active', 1, 'op');`,
+	} {
+		if _, err := app.db.Exec(statement); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	posts := []struct {
+		id, pubkey, subID, body string
+		lastAccessedAt          int64
+	}{
+		{"p-idle", "other", defaultSubID, "body-idle1", 100},
+		{"p-sub", "other", "tech", "body-subs1", 90},
+		{"p-fav", "other", defaultSubID, "body-favs1", 50},
+		{"p-own", "me", defaultSubID, "body-mine1", 60},
+	}
+	for _, post := range posts {
+		cid := buildContentCID(post.body)
+		if err := app.upsertContentBlob(cid, post.body, 0); err != nil {
+			t.Fatalf("store %s: %v", post.id, err)
+		}
+		if _, err := app.db.Exec(`UPDATE content_blobs SET last_accessed_at = ? WHERE content_cid = ?;`, post.lastAccessedAt, cid); err != nil {
+			t.Fatalf("age %s: %v", post.id, err)
+		}
+		if _, err := app.db.Exec(`
+			INSERT INTO messages (id, pubkey, content_cid, content, timestamp, size_bytes, zone, sub_id)
+			VALUES (?, ?, ?, '', 1, 0, 'public', ?);
+		`, post.id, post.pubkey, cid, post.subID); err != nil {
+			t.Fatalf("index %s: %v", post.id, err)
+		}
+	}
+
+	// Plain LRU would evict the subscribed post's body, which is idle
+	// longest; the weighted scorer evicts the idle post instead.
+	if _, err := app.SetStorageQuotas(1000, 100, 45, 100, 100); err != nil {
+		t.Fatalf("set quotas: %v", err)
+	}
+	if err := app.upsertContentBlob(buildContentCID("body-new01"), "body-new01", 0); err != nil {
+		t.Fatalf("store new body: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE content_cid = ?;`, buildContentCID("body-idle1")); count != 0 {
+		t.Fatalf("idle body was not evicted")
+	}
+	evictions, err := app.GetStorageEvictions(0)
+	if err != nil {
+		t.Fatalf("get evictions: %v", err)
+	}
+	if len(evictions) != 1 || evictions[0].PostID != "p-idle" || evictions[0].Scope != "public" || evictions[0].Policy != evictionPolicyWeighted {
+		t.Fatalf("unexpected eviction log %+v", evictions)
+	}
+
+	// Shrinking the zone below what the favorite and our own post take
+	// evicts everything else but never those two.
+	if _, err = app.SetStorageQuotas(1000, 100, 15, 100, 100); err != nil {
+		t.Fatalf("shrink quotas: %v", err)
+	}
+	for _, body := range []string{"body-favs1", "body-mine1"} {
+		if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE content_cid = ?;`, buildContentCID(body)); count != 1 {
+			t.Fatalf("protected body %q was evicted", body)
+		}
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs WHERE content_cid = ?;`, buildContentCID("body-subs1")); count != 0 {
+		t.Fatalf("subscribed body should be evicted once nothing else is left")
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM storage_evictions;`); count != 2 {
+		t.Fatalf("expected 2 logged evictions, got %d", count)
+	}
+}
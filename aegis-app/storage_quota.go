@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Storage quotas bound what the node keeps on disk. A zone quota covers the
+// zone's message rows plus the unpinned content blobs its posts reference,
+// the media budget covers unpinned media_blobs, the pinned budget covers
+// pinned blobs of both kinds (see pinning.go), and the node quota covers
+// messages, content blobs and media blobs together. The quotas live in the
+// single-row storage_quotas table and fall back to the defaults below until
+// saved. A write that would exceed a quota first evicts blobs, then the rows
+// of posts whose bodies are gone (see storage_eviction.go), and fails only
+// when nothing evictable is left.
+const (
+	defaultTotalQuotaBytes   int64 = 100 * 1024 * 1024
+	defaultPrivateQuotaBytes int64 = 20 * 1024 * 1024
+	defaultPublicQuotaBytes  int64 = 80 * 1024 * 1024
+	defaultMediaQuotaBytes   int64 = 100 * 1024 * 1024
+	defaultPinnedQuotaBytes  int64 = 50 * 1024 * 1024
+
+	storageBlobKindContent = "content"
+	storageBlobKindMedia   = "media"
+)
+
+var (
+	errInvalidStorageQuota  = errors.New("invalid storage quota")
+	errStorageQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+type StorageQuotas struct {
+	TotalBytes   int64 `json:"totalBytes"`
+	PrivateBytes int64 `json:"privateBytes"`
+	PublicBytes  int64 `json:"publicBytes"`
+	MediaBytes   int64 `json:"mediaBytes"`
+	PinnedBytes  int64 `json:"pinnedBytes"`
+	UpdatedAt    int64 `json:"updatedAt"`
+}
+
+// storageWrite describes the bytes a pending write adds. Zone applies to the
+// message and content blob; an empty ID or CID means that part is not
+// written.
+type storageWrite struct {
+	Zone         string
+	MessageID    string
+	MessageBytes int64
+	ContentCID   string
+	ContentBytes int64
+	MediaCID     string
+	MediaBytes   int64
+}
+
+func defaultStorageQuotas() StorageQuotas {
+	return StorageQuotas{
+		TotalBytes:   defaultTotalQuotaBytes,
+		PrivateBytes: defaultPrivateQuotaBytes,
+		PublicBytes:  defaultPublicQuotaBytes,
+		MediaBytes:   defaultMediaQuotaBytes,
+		PinnedBytes:  defaultPinnedQuotaBytes,
+	}
+}
+
+func (q StorageQuotas) zoneBytes(zone string) int64 {
+	if zone == "private" {
+		return q.PrivateBytes
+	}
+	return q.PublicBytes
+}
+
+func validateStorageQuotas(q StorageQuotas) error {
+	if q.TotalBytes <= 0 || q.PrivateBytes <= 0 || q.PublicBytes <= 0 || q.MediaBytes <= 0 || q.PinnedBytes <= 0 {
+		return fmt.Errorf("%w: quotas must be positive", errInvalidStorageQuota)
+	}
+	if q.PrivateBytes > q.TotalBytes || q.PublicBytes > q.TotalBytes || q.MediaBytes > q.TotalBytes || q.PinnedBytes > q.TotalBytes {
+		return fmt.Errorf("%w: zone, media and pinned quotas must not exceed the total quota", errInvalidStorageQuota)
+	}
+	return nil
+}
+
+// GetStorageQuotas returns the saved storage quotas, or the defaults when
+// none have been saved.
+func (a *App) GetStorageQuotas() (StorageQuotas, error) {
+	if a.db == nil {
+		return StorageQuotas{}, errors.New("database not initialized")
+	}
+
+	var quotas StorageQuotas
+	err := a.db.QueryRow(`
+		SELECT total_bytes, private_bytes, public_bytes, media_bytes, pinned_bytes, updated_at
+		FROM storage_quotas
+		WHERE id = 1;
+	`).Scan(&quotas.TotalBytes, &quotas.PrivateBytes, &quotas.PublicBytes, &quotas.MediaBytes, &quotas.PinnedBytes, &quotas.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaultStorageQuotas(), nil
+	}
+	if err != nil {
+		return StorageQuotas{}, err
+	}
+	if quotas.PinnedBytes <= 0 {
+		quotas.PinnedBytes = defaultPinnedQuotaBytes
+	}
+	return quotas, nil
+}
+
+// SetStorageQuotas saves new quotas and evicts until usage fits them.
+// Lowering a quota below what pinned blobs and kept message rows already take
+// is allowed; later writes to that scope fail until usage drops. The pinned
+// budget cannot be lowered below what is already pinned.
+func (a *App) SetStorageQuotas(totalBytes int64, privateBytes int64, publicBytes int64, mediaBytes int64, pinnedBytes int64) (StorageQuotas, error) {
+	if a.db == nil {
+		return StorageQuotas{}, errors.New("database not initialized")
+	}
+
+	quotas := StorageQuotas{
+		TotalBytes:   totalBytes,
+		PrivateBytes: privateBytes,
+		PublicBytes:  publicBytes,
+		MediaBytes:   mediaBytes,
+		PinnedBytes:  pinnedBytes,
+		UpdatedAt:    time.Now().Unix(),
+	}
+	if err := validateStorageQuotas(quotas); err != nil {
+		return StorageQuotas{}, err
+	}
+
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return StorageQuotas{}, err
+	}
+	defer tx.Rollback()
+
+	pinned, err := pinnedStorageUsedTx(tx)
+	if err != nil {
+		return StorageQuotas{}, err
+	}
+	if pinned > quotas.PinnedBytes {
+		return StorageQuotas{}, fmt.Errorf("%w: %d bytes are already pinned", errInvalidStorageQuota, pinned)
+	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO storage_quotas (id, total_bytes, private_bytes, public_bytes, media_bytes, pinned_bytes, updated_at)
+		VALUES (1, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			total_bytes = excluded.total_bytes,
+			private_bytes = excluded.private_bytes,
+			public_bytes = excluded.public_bytes,
+			media_bytes = excluded.media_bytes,
+			pinned_bytes = excluded.pinned_bytes,
+			updated_at = excluded.updated_at;
+	`, quotas.TotalBytes, quotas.PrivateBytes, quotas.PublicBytes, quotas.MediaBytes, quotas.PinnedBytes, quotas.UpdatedAt); err != nil {
+		return StorageQuotas{}, err
+	}
+
+	overQuota := a.newStorageEvictor(tx, quotas).enforce()
+	if overQuota != nil && !errors.Is(overQuota, errStorageQuotaExceeded) {
+		return StorageQuotas{}, overQuota
+	}
+	if err = tx.Commit(); err != nil {
+		return StorageQuotas{}, err
+	}
+
+	if overQuota != nil && a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "storage.quotas_over err=%v", overQuota)
+	}
+	if a.ctx != nil {
+		runtime.LogInfof(a.ctx, "storage.quotas_updated total=%d private=%d public=%d media=%d pinned=%d", quotas.TotalBytes, quotas.PrivateBytes, quotas.PublicBytes, quotas.MediaBytes, quotas.PinnedBytes)
+	}
+	return quotas, nil
+}
+
+// currentStorageQuotas is GetStorageQuotas for write paths, which fall back
+// to the defaults rather than failing the write on a read error.
+func (a *App) currentStorageQuotas() StorageQuotas {
+	quotas, err := a.GetStorageQuotas()
+	if err != nil {
+		return defaultStorageQuotas()
+	}
+	return quotas
+}
+
+// incomingBlobBytes is the number of bytes a blob write adds: zero when the
+// blob is already stored.
+func incomingBlobBytes(tx *sql.Tx, table string, cid string, size int64) (int64, error) {
+	if cid == "" {
+		return 0, nil
+	}
+	var existing int
+	if err := tx.QueryRow(`SELECT COUNT(1) FROM `+table+` WHERE content_cid = ?;`, cid).Scan(&existing); err != nil {
+		return 0, err
+	}
+	if existing > 0 {
+		return 0, nil
+	}
+	return size, nil
+}
+
+func zoneStorageUsedTx(tx *sql.Tx, zone string) (int64, error) {
+	var messages, blobs int64
+	if err := tx.QueryRow(`SELECT COALESCE(SUM(size_bytes), 0) FROM messages WHERE zone = ?;`, zone).Scan(&messages); err != nil {
+		return 0, err
+	}
+	if err := tx.QueryRow(`
+		SELECT COALESCE(SUM(cb.size_bytes), 0)
+		FROM content_blobs cb
+		WHERE cb.pinned = 0
+			AND EXISTS (SELECT 1 FROM messages m WHERE m.zone = ? AND m.content_cid = cb.content_cid);
+	`, zone).Scan(&blobs); err != nil {
+		return 0, err
+	}
+	return messages + blobs, nil
+}
+
+func mediaStorageUsedTx(tx *sql.Tx) (int64, error) {
+	var used int64
+	err := tx.QueryRow(`SELECT COALESCE(SUM(size_bytes), 0) FROM media_blobs WHERE pinned = 0;`).Scan(&used)
+	return used, err
+}
+
+func pinnedStorageUsedTx(tx *sql.Tx) (int64, error) {
+	var used int64
+	err := tx.QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM content_blobs WHERE pinned = 1) +
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM media_blobs WHERE pinned = 1);
+	`).Scan(&used)
+	return used, err
+}
+
+func totalStorageUsedTx(tx *sql.Tx) (int64, error) {
+	var total int64
+	err := tx.QueryRow(`
+		SELECT
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM messages) +
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM content_blobs) +
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM media_blobs);
+	`).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetStorageQuotasValidatesAndPersists(t *testing.T) {
+	app := newTestApp(t)
+
+	quotas, err := app.GetStorageQuotas()
+	if err != nil {
+		t.Fatalf("get default quotas: %v", err)
+	}
+	if quotas != defaultStorageQuotas() {
+		t.Fatalf("unexpected defaults %+v", quotas)
+	}
+
+	if _, err = app.SetStorageQuotas(100, 200, 50, 50, 50); !errors.Is(err, errInvalidStorageQuota) {
+		t.Fatalf("expected zone above total to be rejected, got %v", err)
+	}
+	if _, err = app.SetStorageQuotas(100, 50, 0, 50, 50); !errors.Is(err, errInvalidStorageQuota) {
+		t.Fatalf("expected zero quota to be rejected, got %v", err)
+	}
+
+	if _, err = app.SetStorageQuotas(1000, 100, 400, 300, 200); err != nil {
+		t.Fatalf("set quotas: %v", err)
+	}
+	usage, err := app.GetStorageUsage()
+	if err != nil {
+		t.Fatalf("get usage: %v", err)
+	}
+	if usage.TotalQuota != 1000 || usage.PrivateQuota != 100 || usage.PublicQuota != 400 || usage.MediaQuota != 300 || usage.PinnedQuota != 200 {
+		t.Fatalf("usage does not report saved quotas: %+v", usage)
+	}
+}
+
+func TestStorageQuotasEvictLeastRecentlyUsedBlobs(t *testing.T) {
+	app := newTestApp(t)
+	if _, err := app.SetStorageQuotas(40, 40, 40, 20, 40); err != nil {
+		t.Fatalf("set quotas: %v", err)
+	}
+
+	media := [][]byte{
+		{0x89, 'P', 'N', 'G', 1, 1, 1, 1},
+		{0x89, 'P', 'N', 'G', 2, 2, 2, 2},
+		{0x89, 'P', 'N', 'G', 3, 3, 3, 3},
+	}
+	for index, data := range media[:2] {
+		cid := buildBinaryCID(data)
+		if err := app.upsertMediaBlobRaw(cid, "image/png", data, 1, 1, false); err != nil {
+			t.Fatalf("store media %d: %v", index, err)
+		}
+		if _, err := app.db.Exec(`UPDATE media_blobs SET last_accessed_at = ? WHERE content_cid = ?;`, int64(100+index), cid); err != nil {
+			t.Fatalf("age media %d: %v", index, err)
+		}
+	}
+
+	// The media budget holds two 8-byte blobs, so the third evicts the
+	// least recently used one.
+	if err := app.upsertMediaBlobRaw(buildBinaryCID(media[2]), "image/png", media[2], 1, 1, false); err != nil {
+		t.Fatalf("store third media: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM media_blobs WHERE content_cid = ?;`, buildBinaryCID(media[0])); count != 0 {
+		t.Fatalf("oldest media was not evicted")
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM media_blobs;`); count != 2 {
+		t.Fatalf("expected 2 media blobs, got %d", count)
+	}
+
+	// A 30-byte body fits its zone but not the node quota next to 16 bytes
+	// of media, so media is evicted to make room.
+	body := "thirty bytes of post body text"
+	if err := app.upsertContentBlob(buildContentCID(body), body, 0); err != nil {
+		t.Fatalf("store body: %v", err)
+	}
+	usage, err := app.GetStorageUsage()
+	if err != nil {
+		t.Fatalf("get usage: %v", err)
+	}
+	if usage.ContentBytes != 30 || usage.MediaUsedBytes != 8 || usage.TotalUsedBytes != 38 {
+		t.Fatalf("unexpected usage after node quota eviction: %+v", usage)
+	}
+
+	// Pinned blobs leave the media budget but still count toward the node
+	// quota, and are never evicted to make room.
+	for _, table := range []string{"content_blobs", "media_blobs"} {
+		if _, err = app.db.Exec(`UPDATE ` + table + ` SET pinned = 1;`); err != nil {
+			t.Fatalf("pin %s: %v", table, err)
+		}
+	}
+	large := []byte{0x89, 'P', 'N', 'G', 4, 4, 4, 4, 4, 4, 4, 4, 4, 4}
+	if err = app.upsertMediaBlobRaw(buildBinaryCID(large), "image/png", large, 1, 1, false); !errors.Is(err, errStorageQuotaExceeded) {
+		t.Fatalf("expected quota error with only pinned blobs left, got %v", err)
+	}
+}
+
+func TestZoneQuotaCountsIndexRowsAndEvictsUnkeptOnes(t *testing.T) {
+	t.Setenv("AEGIS_ALLOW_UNSIGNED_MESSAGES", "1")
+	app := newTestApp(t)
+	for _, statement := range []string{
+		`INSERT INTO local_identity (pubkey, is_active, updated_at) VALUES ('me', 1, 1);`,
+		`INSERT INTO post_favorites_state (pubkey, post_id, state, updated_at, last_op_id) VALUES ('me', 'p-fav', 'active', 1, 'op');`,
+	} {
+		if _, err := app.db.Exec(statement); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	// Every post is a 30-byte index row plus a 30-byte body.
+	posts := []struct{ id, pubkey, body string }{
+		{"p-own", "me", "own body, thirty bytes long..."},
+		{"p-fav", "alice", "fav body, thirty bytes long..."},
+		{"p-old", "alice", "old body, thirty bytes long..."},
+		{"p-new", "alice", "new body, thirty bytes long..."},
+	}
+	for i, entry := range posts {
+		post := ForumMessage{ID: entry.id, Pubkey: entry.pubkey, OpID: "op-" + entry.id, Title: "Post", Body: entry.body, Timestamp: int64(10 + i), Lamport: int64(10 + i), Zone: "public", SubID: defaultSubID}
+		if _, err := app.insertMessage(post); err != nil {
+			t.Fatalf("insert %s: %v", post.ID, err)
+		}
+	}
+	usage, err := app.GetStorageUsage()
+	if err != nil {
+		t.Fatalf("get usage: %v", err)
+	}
+	if usage.MessageBytes != 120 || usage.PublicUsedBytes != 240 || usage.TotalUsedBytes != 240 {
+		t.Fatalf("index rows are not counted: %+v", usage)
+	}
+
+	// Dropping the unkept bodies leaves 180 bytes, so the index rows of
+	// those posts go too, oldest first, while our own and the favorited
+	// post stay whole.
+	if _, err = app.SetStorageQuotas(1000, 100, 150, 100, 100); err != nil {
+		t.Fatalf("lower quotas: %v", err)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM messages WHERE id = 'p-old';`); count != 0 {
+		t.Fatalf("oldest unkept index row was not evicted")
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM messages WHERE id IN ('p-own', 'p-fav', 'p-new');`); count != 3 {
+		t.Fatalf("expected own, favorited and newest rows to stay, got %d", count)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM content_blobs;`); count != 2 {
+		t.Fatalf("expected only kept bodies to stay, got %d", count)
+	}
+	if count := countRows(t, app, `SELECT COUNT(1) FROM storage_evictions WHERE kind = ? AND post_id = 'p-old' AND content_cid = '';`, storageEvictionKindMessage); count != 1 {
+		t.Fatalf("index row eviction was not logged")
+	}
+	if usage, err = app.GetStorageUsage(); err != nil {
+		t.Fatalf("get usage: %v", err)
+	}
+	if usage.PublicUsedBytes != 150 {
+		t.Fatalf("unexpected usage after eviction %+v", usage)
+	}
+}